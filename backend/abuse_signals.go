@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	// minimumFormTime is how long a human needs at least to fill in the login or register form.
+	minimumFormTime = 3 * time.Second
+	// formTokenTTL bounds how old a form token may be; older ones count as missing.
+	formTokenTTL = 24 * time.Hour
+
+	abuseScoreHoneypot   = 5
+	abuseScoreTooFast    = 2
+	abuseScoreNoFormTime = 1
+	// abuseChallengeScore escalates to the interactive captcha, abuseRejectScore refuses the request.
+	abuseChallengeScore = 2
+	abuseRejectScore    = 5
+
+	loginChallengeAction = "login-challenge"
+)
+
+// formSignals are the bot traps submitted with the login and register forms: a honeypot input
+// hidden from people and the server-issued token recording when the form was rendered.
+type formSignals struct {
+	Honeypot  string `json:"website"`
+	FormToken string `json:"form_token"`
+}
+
+// abuseAssessment is the outcome of scoring a form submission.
+type abuseAssessment struct {
+	score   int
+	signals []string
+}
+
+func (a abuseAssessment) verdict() string {
+	switch {
+	case a.score >= abuseRejectScore:
+		return "reject"
+	case a.score >= abuseChallengeScore:
+		return "challenge"
+	}
+	return "allow"
+}
+
+// issueFormToken signs the time the form was rendered so the submission can prove its age.
+func (s *Server) issueFormToken(now time.Time) string {
+	stamp := strconv.FormatInt(now.UnixMilli(), 10)
+	return stamp + "." + s.signFormStamp(stamp)
+}
+
+func (s *Server) signFormStamp(stamp string) string {
+	mac := hmac.New(sha256.New, s.formTokenKey)
+	mac.Write([]byte("form:" + stamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// formTokenAge returns how long ago a valid token was issued.
+func (s *Server) formTokenAge(token string, now time.Time) (time.Duration, bool) {
+	stamp, signature, found := strings.Cut(strings.TrimSpace(token), ".")
+	if !found || len(s.formTokenKey) == 0 || !hmac.Equal([]byte(signature), []byte(s.signFormStamp(stamp))) {
+		return 0, false
+	}
+	millis, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	age := now.Sub(time.UnixMilli(millis))
+	if age < 0 || age > formTokenTTL {
+		return 0, false
+	}
+	return age, true
+}
+
+// assessForm scores the bot signals of a form submission.
+func (s *Server) assessForm(signals formSignals, now time.Time) abuseAssessment {
+	var a abuseAssessment
+	if strings.TrimSpace(signals.Honeypot) != "" {
+		a.score += abuseScoreHoneypot
+		a.signals = append(a.signals, "honeypot")
+	}
+	age, ok := s.formTokenAge(signals.FormToken, now)
+	switch {
+	case !ok:
+		// Older clients and expired tabs lack a usable token, so on its own this only counts a little.
+		a.score += abuseScoreNoFormTime
+		a.signals = append(a.signals, "form_token_missing")
+	case age < minimumFormTime:
+		a.score += abuseScoreTooFast
+		a.signals = append(a.signals, "form_too_fast")
+	}
+	return a
+}
+
+// screenForm scores the submission, logs suspicious ones and rejects those over the threshold.
+// It returns whether the request may continue and whether it must pass the interactive captcha.
+func (s *Server) screenForm(c *gin.Context, action, email string, signals formSignals) (requireChallenge, ok bool) {
+	assessment := s.assessForm(signals, time.Now())
+	verdict := assessment.verdict()
+	if assessment.score > 0 {
+		log.Printf("abuse signals: tx=%s action=%s ip=%s email=%q score=%d signals=%s verdict=%s",
+			transactionID(c.Request.Context()), action, s.clientSource(c.Request), email, assessment.score, strings.Join(assessment.signals, ","), verdict)
+	}
+	switch verdict {
+	case "reject":
+		// Answer like a failed captcha so the trap is not revealed.
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nieprawidłowa weryfikacja CAPTCHA."})
+		return false, false
+	case "challenge":
+		return true, true
+	}
+	return false, true
+}
+
+// handleFormToken issues the token the login and register forms send back as form_token.
+func (s *Server) handleFormToken(c *gin.Context) {
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"form_token": s.issueFormToken(time.Now())})
+}
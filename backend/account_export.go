@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// accountExport is everything stored about a user, returned by GET /api/account/export.
+type accountExport struct {
+	ExportedAt     time.Time               `json:"exported_at"`
+	User           userResponse            `json:"user"`
+	CreatedAt      time.Time               `json:"created_at"`
+	AgeAttestation *storage.AgeAttestation `json:"age_attestation"`
+	Pixels         []storage.Pixel         `json:"pixels"`
+	Payments       []storage.Payment       `json:"payments"`
+	Licenses       []storage.PixelLicense  `json:"licenses"`
+}
+
+func (s *Server) handleAccountExport(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	export := accountExport{
+		ExportedAt: time.Now().UTC(),
+		User:       sanitizeUser(user),
+		CreatedAt:  user.CreatedAt,
+	}
+	var err error
+	if export.Pixels, err = s.store.GetPixelsByOwner(ctx, user.ID); err != nil {
+		log.Printf("export: load pixels for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account"})
+		return
+	}
+	if export.Payments, err = s.store.ListPaymentsByUser(ctx, user.ID); err != nil {
+		log.Printf("export: load payments for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account"})
+		return
+	}
+	if export.Licenses, err = s.store.ListPixelLicensesByUser(ctx, user.ID); err != nil {
+		log.Printf("export: load licenses for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account"})
+		return
+	}
+	attestation, err := s.store.GetAgeAttestation(ctx, user.ID)
+	switch {
+	case err == nil:
+		export.AgeAttestation = &attestation
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("export: load age attestation for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export account"})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="kuppixel-account-%d.json"`, user.ID))
+	c.JSON(http.StatusOK, export)
+}
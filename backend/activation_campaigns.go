@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const maxActivationCodeBatch = 1000
+
+var campaignIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type createActivationCodesRequest struct {
+	Count      int    `json:"count"`
+	Value      int64  `json:"value"`
+	CampaignID string `json:"campaign_id"`
+	BatchID    string `json:"batch_id"`
+}
+
+func (s *Server) handleCreateActivationCodes(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	var req createActivationCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	campaign := strings.TrimSpace(req.CampaignID)
+	batchID := strings.TrimSpace(req.BatchID)
+	if !campaignIDPattern.MatchString(campaign) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign id"})
+		return
+	}
+	if batchID != "" && !campaignIDPattern.MatchString(batchID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	if req.Count <= 0 || req.Count > maxActivationCodeBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", maxActivationCodeBatch)})
+		return
+	}
+	if req.Value <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "value must be positive"})
+		return
+	}
+
+	codes := make([]string, 0, req.Count)
+	seen := make(map[string]struct{}, req.Count)
+	for len(codes) < req.Count {
+		code, err := s.codeFormat.Generate()
+		if err != nil {
+			log.Printf("create activation codes: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate codes"})
+			return
+		}
+		if _, dup := seen[code]; dup {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+
+	batch := storage.ActivationCodeBatch{CampaignID: campaign, BatchID: batchID, Value: req.Value, Codes: codes}
+	if err := s.store.CreateActivationCodeBatch(c.Request.Context(), batch); err != nil {
+		log.Printf("create activation codes: campaign=%s batch=%s err=%v", campaign, batchID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store codes"})
+		return
+	}
+
+	log.Printf("activation codes created: campaign=%s batch=%s count=%d value=%d", campaign, batchID, len(codes), req.Value)
+	c.JSON(http.StatusCreated, gin.H{
+		"campaign_id": campaign,
+		"batch_id":    batchID,
+		"value":       req.Value,
+		"codes":       codes,
+	})
+}
+
+func (s *Server) handleCampaignStats(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	campaign := strings.TrimSpace(c.Param("id"))
+	if !campaignIDPattern.MatchString(campaign) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid campaign id"})
+		return
+	}
+
+	stats, err := s.store.GetCampaignStats(c.Request.Context(), campaign)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "campaign not found"})
+			return
+		}
+		log.Printf("campaign stats: campaign=%s err=%v", campaign, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load campaign stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/activationcode"
+	"github.com/example/kup-piksel/internal/qrcode"
+)
+
+const (
+	defaultQRScale  = 8
+	maxQRScale      = 32
+	maxQRBatchCodes = 1000
+)
+
+type activationQRBatchRequest struct {
+	Codes []string `json:"codes"`
+	Scale int      `json:"scale"`
+}
+
+func buildRedeemLink(base, code string) (string, error) {
+	trimmed := strings.TrimRight(base, "/")
+	if trimmed == "" {
+		trimmed = defaultVerificationBaseURL
+	}
+	if _, err := url.Parse(trimmed); err != nil {
+		return "", fmt.Errorf("invalid base url: %w", err)
+	}
+	return fmt.Sprintf("%s/redeem?code=%s", trimmed, url.QueryEscape(code)), nil
+}
+
+func (s *Server) renderActivationQR(code string, scale int) ([]byte, error) {
+	link, err := buildRedeemLink(s.redeemBaseURL, code)
+	if err != nil {
+		return nil, err
+	}
+	qr, err := qrcode.Encode([]byte(link))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := qr.WritePNG(&buf, scale); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func clampQRScale(scale int) int {
+	if scale <= 0 {
+		return defaultQRScale
+	}
+	if scale > maxQRScale {
+		return maxQRScale
+	}
+	return scale
+}
+
+func (s *Server) handleActivationCodeQR(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	query := c.Request.URL.Query()
+	code := activationcode.Normalize(query.Get("code"))
+	if !s.codeFormat.Valid(code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid activation code format"})
+		return
+	}
+	scale, _ := strconv.Atoi(query.Get("scale"))
+
+	data, err := s.renderActivationQR(code, clampQRScale(scale))
+	if err != nil {
+		log.Printf("render activation qr for %s: %v", code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render qr code"})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "image/png")
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", code+".png"))
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(data)
+}
+
+func (s *Server) handleActivationCodeQRBatch(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	var req activationQRBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(req.Codes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no codes provided"})
+		return
+	}
+	if len(req.Codes) > maxQRBatchCodes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d codes per batch", maxQRBatchCodes)})
+		return
+	}
+
+	scale := clampQRScale(req.Scale)
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	seen := make(map[string]struct{}, len(req.Codes))
+	for _, raw := range req.Codes {
+		code := activationcode.Normalize(raw)
+		if !s.codeFormat.Valid(code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid activation code format: %s", raw)})
+			return
+		}
+		if _, dup := seen[code]; dup {
+			continue
+		}
+		seen[code] = struct{}{}
+
+		data, err := s.renderActivationQR(code, scale)
+		if err != nil {
+			log.Printf("render activation qr for %s: %v", code, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render qr code"})
+			return
+		}
+		entry, err := zw.Create(code + ".png")
+		if err != nil {
+			log.Printf("create zip entry for %s: %v", code, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build archive"})
+			return
+		}
+		if _, err := entry.Write(data); err != nil {
+			log.Printf("write zip entry for %s: %v", code, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build archive"})
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("finalise qr archive: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build archive"})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/zip")
+	c.Writer.Header().Set("Content-Disposition", `attachment; filename="activation-codes.zip"`)
+	c.Writer.WriteHeader(http.StatusOK)
+	_, _ = c.Writer.Write(archive.Bytes())
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func newAdminSet(emails []string) map[string]struct{} {
+	admins := make(map[string]struct{}, len(emails))
+	for _, address := range emails {
+		if trimmed := strings.ToLower(strings.TrimSpace(address)); trimmed != "" {
+			admins[trimmed] = struct{}{}
+		}
+	}
+	return admins
+}
+
+func (s *Server) isAdmin(user storage.User) bool {
+	_, ok := s.adminEmails[strings.ToLower(strings.TrimSpace(user.Email))]
+	return ok
+}
+
+func (s *Server) requireAdmin(c *gin.Context) (storage.User, bool) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return storage.User{}, false
+	}
+	if !s.isAdmin(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin privileges required"})
+		return storage.User{}, false
+	}
+	return user, true
+}
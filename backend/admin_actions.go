@@ -0,0 +1,146 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	maxAdminActionReasonLength = 500
+	adminActionsDefaultLimit   = 100
+	adminActionsMaxLimit       = 1000
+)
+
+type forceFreeRequest struct {
+	PixelIDs []int `json:"pixel_ids"`
+	// Domain frees every pixel whose link points to the domain or one of its subdomains; it
+	// cannot be combined with PixelIDs.
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+// handleForceFreePixels lets an admin free pixels whoever owns them, either the listed ones or
+// all pixels linking to a domain, e.g. to take down a scam site. Nothing is refunded; the action
+// is recorded in the audit trail with the reason.
+func (s *Server) handleForceFreePixels(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req forceFreeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" || utf8.RuneCountInString(req.Reason) > maxAdminActionReasonLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required and limited to 500 characters"})
+		return
+	}
+	if (req.Domain != "") == (len(req.PixelIDs) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide either pixel_ids or domain"})
+		return
+	}
+	for _, id := range req.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel id"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	action := storage.AdminAction{
+		AdminID:  admin.ID,
+		Action:   storage.AdminActionForceFree,
+		Target:   "pixels",
+		PixelIDs: req.PixelIDs,
+		Reason:   req.Reason,
+	}
+	if req.Domain != "" {
+		domain, ok := normalizeCustomDomain(req.Domain)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid domain"})
+			return
+		}
+		state, err := s.store.GetAllPixels(ctx)
+		if err != nil {
+			log.Printf("force free: load pixels: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to free pixels"})
+			return
+		}
+		action.Target = "domain:" + domain
+		action.PixelIDs = nil
+		for _, pixel := range state.Pixels {
+			if pixel.Status != "free" && linksToDomain(pixel.URL, domain) {
+				action.PixelIDs = append(action.PixelIDs, pixel.ID)
+			}
+		}
+		if len(action.PixelIDs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no pixels link to the domain"})
+			return
+		}
+	}
+
+	action, pixels, err := s.store.ForceFreePixels(ctx, action)
+	if err != nil {
+		log.Printf("force free: admin_id=%d target=%s: %v", admin.ID, action.Target, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to free pixels"})
+		return
+	}
+	if len(pixels) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "the pixels are already free", "code": "already_free"})
+		return
+	}
+
+	now := time.Now().UTC()
+	freed := make([]storage.Pixel, len(pixels))
+	for i, pixel := range pixels {
+		freed[i] = storage.Pixel{ID: pixel.ID, Status: "free", UpdatedAt: now}
+	}
+	s.pixelsChanged(ctx, freed)
+	log.Printf("force free: id=%d admin_id=%d target=%s pixels=%d", action.ID, action.AdminID, action.Target, len(action.PixelIDs))
+	c.JSON(http.StatusCreated, gin.H{"action": action})
+}
+
+// linksToDomain reports whether link points to domain or one of its subdomains.
+func linksToDomain(link, domain string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// handleListAdminActions returns the audit trail of admin actions, newest first. ?limit caps the
+// number of entries (100 by default).
+func (s *Server) handleListAdminActions(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	limit := adminActionsDefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > adminActionsMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = value
+	}
+	actions, err := s.store.ListAdminActions(c.Request.Context(), limit)
+	if err != nil {
+		log.Printf("admin actions: list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load audit trail"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"actions": actions})
+}
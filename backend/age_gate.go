@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const minimumBirthYear = 1900
+
+type ageAttestationRequest struct {
+	AgeAttestation bool `json:"age_attestation"`
+	BirthYear      int  `json:"birth_year"`
+}
+
+// ageAttestationFromRequest validates the attestation checkbox and declared birth year against
+// the configured minimum age. Only the year is collected, so users turning the minimum age later
+// this year are accepted; the checkbox is the binding statement.
+func (s *Server) ageAttestationFromRequest(req ageAttestationRequest, now time.Time) (storage.AgeAttestation, error) {
+	if !req.AgeAttestation {
+		return storage.AgeAttestation{}, fmt.Errorf("you must confirm that you are at least %d years old", s.minimumAge)
+	}
+	year := now.UTC().Year()
+	if req.BirthYear < minimumBirthYear || req.BirthYear > year {
+		return storage.AgeAttestation{}, errors.New("birth_year is invalid")
+	}
+	if year-req.BirthYear < s.minimumAge {
+		return storage.AgeAttestation{}, fmt.Errorf("you must be at least %d years old", s.minimumAge)
+	}
+	return storage.AgeAttestation{BirthYear: req.BirthYear, MinimumAge: s.minimumAge, AttestedAt: now.UTC()}, nil
+}
+
+// requireAgeAttestation blocks purchases until the user has attested the configured minimum age.
+func (s *Server) requireAgeAttestation(c *gin.Context, user storage.User) bool {
+	if s.minimumAge <= 0 {
+		return true
+	}
+	attestation, err := s.store.GetAgeAttestation(c.Request.Context(), user.ID)
+	if err == nil && attestation.MinimumAge >= s.minimumAge {
+		return true
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("load age attestation for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify age attestation"})
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       fmt.Sprintf("confirm that you are at least %d years old before purchasing", s.minimumAge),
+		"code":        "age_attestation_required",
+		"minimum_age": s.minimumAge,
+	})
+	return false
+}
+
+// handleAgeAttestation lets existing users attest their age, e.g. after the gate was enabled.
+func (s *Server) handleAgeAttestation(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if s.rejectWrites(c) {
+		return
+	}
+	if s.minimumAge <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "age attestation is not required"})
+		return
+	}
+
+	var req ageAttestationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	attestation, err := s.ageAttestationFromRequest(req, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "age_attestation_invalid"})
+		return
+	}
+	attestation.UserID = user.ID
+	if err := s.store.SaveAgeAttestation(c.Request.Context(), attestation); err != nil {
+		log.Printf("save age attestation for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save age attestation"})
+		return
+	}
+	log.Printf("age attestation: user_id=%d birth_year=%d minimum_age=%d", user.ID, attestation.BirthYear, attestation.MinimumAge)
+	c.JSON(http.StatusOK, gin.H{"age_attestation": attestation})
+}
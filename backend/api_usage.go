@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+const (
+	apiUsagePath         = "/api/account/usage"
+	apiUsagePruneIdle    = 48 * time.Hour
+	apiUsageUnmatchedKey = "unmatched"
+)
+
+// apiPlan returns the usage plan of userID. The user is only loaded when an admin plan is
+// configured, so tracking stays free of database reads in the common case.
+func (s *Server) apiPlan(ctx context.Context, userID int64) (string, config.APIPlan) {
+	name := s.apiPlans.DefaultPlan
+	if _, ok := s.apiPlans.Plans[s.apiPlans.AdminPlan]; ok && len(s.adminEmails) > 0 {
+		if user, err := s.store.GetUserByID(ctx, userID); err == nil && s.isAdmin(user) {
+			name = s.apiPlans.AdminPlan
+		}
+	}
+	return name, s.apiPlans.Plans[name]
+}
+
+// apiUsageMiddleware counts /api calls of signed-in users per route and rejects calls over the
+// daily quota of their plan with 429. The usage endpoint itself is neither counted nor limited.
+func (s *Server) apiUsageMiddleware(c *gin.Context) {
+	path := c.Request.URL.Path
+	if s.apiUsage == nil || !strings.HasPrefix(path, "/api/") || path == apiUsagePath {
+		c.Next()
+		return
+	}
+	sessionID, ok, err := readSessionCookie(c.Request)
+	if err != nil || !ok {
+		c.Next()
+		return
+	}
+	userID, ok := s.sessions.Get(sessionID)
+	if !ok {
+		c.Next()
+		return
+	}
+
+	route := c.FullPath()
+	if route == "" {
+		route = apiUsageUnmatchedKey
+	}
+	planName, plan := s.apiPlan(c.Request.Context(), userID)
+	snapshot, allowed := s.apiUsage.Hit(userID, c.Request.Method+" "+route, plan.DailyRequests)
+	if plan.DailyRequests > 0 {
+		remaining := plan.DailyRequests - snapshot.Today
+		if remaining < 0 {
+			remaining = 0
+		}
+		header := c.Writer.Header()
+		header.Set("X-Quota-Limit", strconv.FormatInt(plan.DailyRequests, 10))
+		header.Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+		header.Set("X-Quota-Reset", strconv.FormatInt(snapshot.ResetAt.Unix(), 10))
+	}
+	if !allowed {
+		s.metrics.Counter("kuppixel_api_quota_rejections_total", "API calls rejected because the plan quota was used up.", "plan", planName).Inc()
+		retryAfter := int(time.Until(snapshot.ResetAt).Seconds()) + 1
+		c.Writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":    "daily API quota exceeded",
+			"code":     "quota_exceeded",
+			"plan":     planName,
+			"limit":    plan.DailyRequests,
+			"reset_at": snapshot.ResetAt,
+		})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) handleAccountUsage(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	planName, plan := s.apiPlan(c.Request.Context(), user.ID)
+	snapshot := s.apiUsage.Usage(user.ID)
+
+	resp := gin.H{
+		"plan":        planName,
+		"daily_limit": plan.DailyRequests,
+		"usage":       snapshot,
+	}
+	if plan.DailyRequests > 0 {
+		remaining := plan.DailyRequests - snapshot.Today
+		if remaining < 0 {
+			remaining = 0
+		}
+		resp["remaining"] = remaining
+	}
+	c.JSON(http.StatusOK, resp)
+}
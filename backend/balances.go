@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// balanceReport is the outcome of recomputing the balances from the points ledger.
+type balanceReport struct {
+	CheckedAt time.Time              `json:"checked_at"`
+	Repair    bool                   `json:"repair"`
+	Drifts    []storage.BalanceDrift `json:"drifts"`
+}
+
+// handleGetBalances recomputes every balance from the points ledger and lists the users whose
+// stored balance differs, without changing anything.
+func (s *Server) handleGetBalances(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	s.recomputeBalances(c, false)
+}
+
+// handleRecomputeBalances recomputes the balances like handleGetBalances. It only reports by
+// default; ?dry_run=false also sets the drifting balances to the ledger's.
+func (s *Server) handleRecomputeBalances(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	dryRun := true
+	if raw := c.Query("dry_run"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+		dryRun = value
+	}
+	s.recomputeBalances(c, !dryRun)
+}
+
+func (s *Server) recomputeBalances(c *gin.Context, repair bool) {
+	drifts, err := s.store.RecomputeBalances(c.Request.Context(), repair)
+	if err != nil {
+		log.Printf("balances: recompute failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recompute balances"})
+		return
+	}
+	for _, drift := range drifts {
+		log.Printf("balances: user_id=%d balance=%d ledger=%d repaired=%t", drift.UserID, drift.Balance, drift.Ledger, repair)
+	}
+	c.JSON(http.StatusOK, balanceReport{CheckedAt: time.Now().UTC(), Repair: repair, Drifts: drifts})
+}
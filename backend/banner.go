@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	bannerSettingName = "banner"
+	maxBannerLength   = 500
+)
+
+var bannerSeverities = map[string]struct{}{"info": {}, "warning": {}, "critical": {}}
+
+// siteBanner is a site-wide announcement managed by admins.
+type siteBanner struct {
+	Text      string     `json:"text"`
+	Severity  string     `json:"severity"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type bannerRequest struct {
+	Text      string     `json:"text"`
+	Severity  string     `json:"severity"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// activeBanner returns the current banner or nil when none is set or it has expired.
+func (s *Server) activeBanner(ctx context.Context) *siteBanner {
+	raw, err := s.store.GetSetting(ctx, bannerSettingName)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("load banner: %v", err)
+		}
+		return nil
+	}
+	var banner siteBanner
+	if err := json.Unmarshal([]byte(raw), &banner); err != nil {
+		log.Printf("decode banner: %v", err)
+		return nil
+	}
+	if banner.ExpiresAt != nil && !banner.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return &banner
+}
+
+func (s *Server) handlePutBanner(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	var req bannerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	banner := siteBanner{
+		Text:      strings.TrimSpace(req.Text),
+		Severity:  strings.ToLower(strings.TrimSpace(req.Severity)),
+		ExpiresAt: req.ExpiresAt,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if banner.Text == "" || len([]rune(banner.Text)) > maxBannerLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "banner text must be between 1 and 500 characters"})
+		return
+	}
+	if banner.Severity == "" {
+		banner.Severity = "info"
+	}
+	if _, ok := bannerSeverities[banner.Severity]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "severity must be one of info, warning, critical"})
+		return
+	}
+	if banner.ExpiresAt != nil {
+		if !banner.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+		expires := banner.ExpiresAt.UTC()
+		banner.ExpiresAt = &expires
+	}
+
+	data, err := json.Marshal(banner)
+	if err != nil {
+		log.Printf("encode banner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save banner"})
+		return
+	}
+	if err := s.store.PutSetting(c.Request.Context(), bannerSettingName, string(data)); err != nil {
+		log.Printf("save banner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save banner"})
+		return
+	}
+
+	log.Printf("banner updated: severity=%s expires_at=%v", banner.Severity, banner.ExpiresAt)
+	c.JSON(http.StatusOK, gin.H{"banner": banner})
+}
+
+func (s *Server) handleDeleteBanner(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	if err := s.store.DeleteSetting(c.Request.Context(), bannerSettingName); err != nil {
+		log.Printf("delete banner: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete banner"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handleConfig exposes public, runtime-adjustable settings the frontend needs on load.
+func (s *Server) handleConfig(c *gin.Context) {
+	active, upcoming := s.maintenanceStatus(c.Request.Context())
+	c.JSON(http.StatusOK, gin.H{
+		"pixel_cost_points": s.pixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, s.pixelCostPoints),
+		"banner":            s.activeBanner(c.Request.Context()),
+		"read_only":         s.readOnlyStatus(c.Request.Context()),
+		"minimum_age":       s.minimumAge,
+		"tile_size":         s.pixelTileSize(),
+		"invites_required":  s.invitesRequired,
+		"maintenance": gin.H{
+			"active":   active,
+			"upcoming": upcoming,
+		},
+	})
+}
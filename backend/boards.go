@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/moderation"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// boardSummary describes a board in GET /api/boards. Boards are grids kept in the same store as the
+// main one, each with its own pixel price; users and their points are shared.
+type boardSummary struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	PixelCostPoints int64  `json:"pixel_cost_points"`
+	PriceVersion    string `json:"price_version"`
+}
+
+type boardPurchaseRequest struct {
+	Pixels []PixelUpdate `json:"pixels"`
+}
+
+// board returns the configured board the request names, answering 404 when there is none.
+func (s *Server) board(c *gin.Context) (config.Board, bool) {
+	id := c.Param("id")
+	for _, board := range s.boards {
+		if board.ID == id {
+			return board, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "board not found"})
+	return config.Board{}, false
+}
+
+// boardPrices are the prices the pixels of board are charged by.
+func boardPrices(board config.Board) priceList {
+	return newPriceList(int64(board.PixelCostPoints), 0)
+}
+
+// handleListBoards lists the configured boards and their pixel prices.
+func (s *Server) handleListBoards(c *gin.Context) {
+	boards := make([]boardSummary, 0, len(s.boards))
+	for _, board := range s.boards {
+		prices := boardPrices(board)
+		boards = append(boards, boardSummary{ID: board.ID, Name: board.Name, PixelCostPoints: prices.PixelCostPoints, PriceVersion: prices.Version})
+	}
+	c.JSON(http.StatusOK, gin.H{"boards": boards})
+}
+
+// handleGetBoardPixels returns the whole grid of a board; ?fields= works as for GET /api/pixels.
+func (s *Server) handleGetBoardPixels(c *gin.Context) {
+	board, ok := s.board(c)
+	if !ok {
+		return
+	}
+	fields, err := storage.ParsePixelFields(c.Query("fields"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key := fmt.Sprintf("board=%s fields=%d", board.ID, fields)
+	s.serveGrid(c, key, "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		state, err := s.store.GetBoardPixels(ctx, board.ID)
+		if err != nil {
+			return nil, err
+		}
+		return func(w io.Writer) error { return state.WriteJSONFields(w, fields) }, nil
+	})
+}
+
+// handleUpdateBoardPixels buys or frees pixels of a board at the board's price. The pixels go
+// through the same content, link and content moderation checks as those of the main grid, but are
+// bought for good: they cannot be rented, held, gifted or found by search. The moderation queue and
+// takedowns cover the main grid only, so boards cannot be bought while the queue is on.
+func (s *Server) handleUpdateBoardPixels(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) || !s.requireAgeAttestation(c, user) {
+		return
+	}
+	board, ok := s.board(c)
+	if !ok {
+		return
+	}
+
+	if s.purchaseMaxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.purchaseMaxBytes)
+	}
+	var req boardPurchaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "zaznaczenie jest zbyt duże. Podziel zakup na mniejsze części.", "code": "payload_too_large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if len(req.Pixels) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no pixels provided"})
+		return
+	}
+
+	purchase := s.applyBoardPixelUpdates(c.Request.Context(), board, user, req)
+	if !purchase.updated {
+		c.JSON(purchase.status(), purchase.response(nil, nil))
+		return
+	}
+	response := purchase.response(s.pointsPrice(c, purchase.costPoints), s.pointsPrice(c, purchase.spent))
+	response["board_id"] = board.ID
+	c.JSON(http.StatusOK, response)
+}
+
+// applyBoardPixelUpdates writes the requested pixels of board for user in chunks, like
+// applyPixelUpdates does for the main grid.
+func (s *Server) applyBoardPixelUpdates(ctx context.Context, board config.Board, user storage.User, req boardPurchaseRequest) pixelPurchase {
+	prices := boardPrices(board)
+	results := make([]PixelUpdateResult, len(req.Pixels))
+	purchase := pixelPurchase{results: results, user: user, costPoints: prices.PixelCostPoints, priceVersion: prices.Version}
+	statuses := make([]int, len(req.Pixels))
+
+	checkedLinks := make(map[string]string)
+	moderated := make(map[moderation.Submission]moderationVerdict)
+	pending := make([]int, 0, len(req.Pixels))
+	pixels := make([]storage.Pixel, 0, len(req.Pixels))
+	for i, item := range req.Pixels {
+		results[i].ID = item.ID
+		if item.ID < 0 || item.ID >= storage.TotalPixels {
+			results[i].Error, statuses[i] = "invalid pixel id", http.StatusBadRequest
+			continue
+		}
+		pixel := storage.Pixel{ID: item.ID, Status: "free"}
+		if strings.ToLower(item.Status) == "taken" {
+			if s.moderation.Enabled {
+				results[i].Error, statuses[i], results[i].Code = "boards cannot be bought while the moderation queue is on", http.StatusForbidden, "board_moderation_unavailable"
+				continue
+			}
+			color := strings.TrimSpace(item.Color)
+			url := strings.TrimSpace(item.URL)
+			if color == "" || url == "" {
+				results[i].Error, statuses[i] = "taken pixels require color and url", http.StatusBadRequest
+				continue
+			}
+			title := strings.TrimSpace(item.Title)
+			description := strings.TrimSpace(strings.ReplaceAll(item.Description, "\r\n", "\n"))
+			if reason := s.pixelContentError(url, title, description); reason != "" {
+				results[i].Error, statuses[i] = reason, http.StatusBadRequest
+				continue
+			}
+			if reason := s.pixelLinkError(ctx, checkedLinks, url); reason != "" {
+				results[i].Error, statuses[i] = reason, http.StatusBadRequest
+				continue
+			}
+			if reason, status := s.pixelModerationError(ctx, moderated, moderation.Submission{URL: url, Color: color}); reason != "" {
+				results[i].Error, statuses[i] = reason, status
+				continue
+			}
+			pixel = storage.Pixel{ID: item.ID, Status: "taken", Color: color, URL: url, Title: title, Description: description}
+		}
+		pending = append(pending, i)
+		pixels = append(pixels, pixel)
+	}
+
+	chunkSize := s.purchaseChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultPurchaseChunkSize
+	}
+	for start := 0; start < len(pixels); start += chunkSize {
+		end := min(start+chunkSize, len(pixels))
+		outcomes, updatedUser, err := s.store.UpdateBoardPixelsForUser(ctx, board.ID, user.ID, pixels[start:end], prices.PixelCostPoints, prices.Version)
+		if err != nil {
+			log.Printf("update board %s pixels %d-%d of %d for user %d: %v", board.ID, start, end, len(pixels), user.ID, err)
+			for _, i := range pending[start:end] {
+				results[i].Error, statuses[i] = "failed to update pixel", http.StatusInternalServerError
+			}
+			continue
+		}
+		for j, outcome := range outcomes {
+			i := pending[start+j]
+			if outcome.Err != nil {
+				results[i].Error, statuses[i] = pixelUpdateError(results[i].ID, outcome.Err)
+				if errors.Is(outcome.Err, storage.ErrPixelQuotaExceeded) {
+					results[i].Code = "pixel_quota_exceeded"
+				}
+				continue
+			}
+			updated := outcome.Pixel
+			results[i].Pixel = &updated
+			purchase.updated = true
+		}
+		purchase.user = updatedUser
+	}
+
+	for i, status := range statuses {
+		if status != 0 {
+			purchase.errStatus, purchase.errMessage, purchase.errCode = status, results[i].Error, results[i].Code
+			break
+		}
+	}
+	if !purchase.updated {
+		return purchase
+	}
+	s.gridCache.Invalidate()
+	s.gridVersion.Bump()
+
+	for _, result := range results {
+		if result.Pixel != nil && result.Pixel.Status == "taken" {
+			purchase.purchased++
+		}
+	}
+	purchase.spent = int64(purchase.purchased) * prices.PixelCostPoints
+	if purchase.purchased > 0 {
+		log.Printf("board purchase: board_id=%s user_id=%d pixels=%d points_spent=%d price_version=%s", board.ID, user.ID, purchase.purchased, purchase.spent, purchase.priceVersion)
+	}
+	return purchase
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+// buildVersion and buildCommit are set at link time:
+//
+//	go build -ldflags "-X main.buildVersion=1.4.0 -X main.buildCommit=$(git rev-parse HEAD)"
+//
+// Without them the commit is taken from the VCS stamp Go embeds when building inside a checkout.
+var (
+	buildVersion = "dev"
+	buildCommit  = ""
+)
+
+// buildManifest identifies the running build, so it can be confirmed which backend and frontend
+// are live.
+type buildManifest struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit"`
+	FrontendHash string `json:"frontend_hash"`
+	GoVersion    string `json:"go_version"`
+}
+
+// currentBuild is computed once; the embedded frontend cannot change while the process runs.
+var currentBuild = sync.OnceValue(func() buildManifest {
+	manifest := buildManifest{
+		Version:   buildVersion,
+		Commit:    buildCommit,
+		GoVersion: runtime.Version(),
+	}
+	if manifest.Commit == "" {
+		manifest.Commit = vcsRevision()
+	}
+	hash, err := frontendHash(frontendFS, "frontend_dist")
+	if err != nil {
+		log.Printf("build manifest: hash frontend: %v", err)
+	}
+	manifest.FrontendHash = hash
+	return manifest
+})
+
+func init() {
+	expvar.Publish("build", expvar.Func(func() any { return currentBuild() }))
+}
+
+// vcsRevision returns the commit recorded by the Go toolchain, suffixed with "-dirty" for builds
+// of a modified tree, or "unknown" when the binary was not built from a checkout.
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// frontendHash returns the SHA-256 over the paths and contents of every file below root, in
+// lexical order, so any change to the frontend build yields a different hash.
+func frontendHash(fsys fs.FS, root string) (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(fsys, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", strings.TrimPrefix(path, root+"/"), len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// handleVersion returns the build manifest. Open clients can poll it and reload once the
+// frontend hash changes.
+func handleVersion(c *gin.Context) {
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, currentBuild())
+}
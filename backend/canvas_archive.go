@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/archive"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	// canvasArchiveCheckInterval bounds how late a snapshot is taken after it is due.
+	canvasArchiveCheckInterval = time.Hour
+	canvasArchiveTimeLayout    = "20060102T150405Z"
+	canvasArchivePrefix        = "canvas-"
+	canvasArchiveBlobSuffix    = ".json.gz"
+	canvasArchivePNGSuffix     = ".png"
+	// canvasArchiveRenderCacheSize is how many rendered archive PNGs are kept in memory; old
+	// snapshots never change, so renders only need bounding.
+	canvasArchiveRenderCacheSize = 8
+)
+
+// canvasArchive takes periodic snapshots of the grid into an archive.Store.
+type canvasArchive struct {
+	store  archive.Store
+	period time.Duration
+	png    bool
+
+	mu      sync.Mutex
+	renders map[string][]byte
+	order   []string
+}
+
+func newCanvasArchive(store archive.Store, period time.Duration, png bool) *canvasArchive {
+	return &canvasArchive{store: store, period: period, png: png, renders: make(map[string][]byte)}
+}
+
+// snapshots returns the times of the archived blobs, oldest first.
+func (a *canvasArchive) snapshots(ctx context.Context) ([]time.Time, error) {
+	names, err := a.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	times := make([]time.Time, 0, len(names))
+	for _, name := range names {
+		stamp, ok := strings.CutPrefix(name, canvasArchivePrefix)
+		if !ok {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, canvasArchiveBlobSuffix)
+		if !ok {
+			continue
+		}
+		if taken, err := time.Parse(canvasArchiveTimeLayout, stamp); err == nil {
+			times = append(times, taken)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times, nil
+}
+
+func canvasArchiveName(taken time.Time, suffix string) string {
+	return canvasArchivePrefix + taken.UTC().Format(canvasArchiveTimeLayout) + suffix
+}
+
+// rendered returns a cached render or renders and caches it.
+func (a *canvasArchive) rendered(key string, render func() ([]byte, error)) ([]byte, error) {
+	a.mu.Lock()
+	body, ok := a.renders[key]
+	a.mu.Unlock()
+	if ok {
+		return body, nil
+	}
+	body, err := render()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.renders[key]; !ok {
+		a.renders[key] = body
+		a.order = append(a.order, key)
+		if len(a.order) > canvasArchiveRenderCacheSize {
+			delete(a.renders, a.order[0])
+			a.order = a.order[1:]
+		}
+	}
+	return body, nil
+}
+
+// archiveCanvas stores a snapshot when the latest one is older than the archive period: every
+// pixel with all its fields as gzip-compressed JSON, the format of GET /api/pixels, and with
+// png the canvas as GET /api/canvas.png renders it.
+func (s *Server) archiveCanvas(ctx context.Context) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	taken, err := s.canvasArchive.snapshots(ctx)
+	if err != nil {
+		return fmt.Errorf("list canvas snapshots: %w", err)
+	}
+	if len(taken) > 0 && now.Sub(taken[len(taken)-1]) < s.canvasArchive.period {
+		return nil
+	}
+
+	state, err := s.loadGrid(ctx)
+	if err != nil {
+		return fmt.Errorf("load grid: %w", err)
+	}
+	var blob bytes.Buffer
+	zw := gzip.NewWriter(&blob)
+	if err := state.WriteJSON(zw); err != nil {
+		return fmt.Errorf("encode canvas snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress canvas snapshot: %w", err)
+	}
+	// The PNG goes first: a snapshot is only listed once its blob exists, so a failed PNG upload
+	// is retried on the next run instead of leaving a snapshot without an image.
+	if s.canvasArchive.png {
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return fmt.Errorf("hide contested pixels: %w", err)
+		}
+		var image bytes.Buffer
+		if err := state.WritePNG(&image, state.Width, state.Height); err != nil {
+			return fmt.Errorf("render canvas snapshot: %w", err)
+		}
+		if err := s.canvasArchive.store.Put(ctx, canvasArchiveName(now, canvasArchivePNGSuffix), "image/png", image.Bytes()); err != nil {
+			return fmt.Errorf("store canvas png: %w", err)
+		}
+	}
+	name := canvasArchiveName(now, canvasArchiveBlobSuffix)
+	if err := s.canvasArchive.store.Put(ctx, name, "application/gzip", blob.Bytes()); err != nil {
+		return fmt.Errorf("store canvas snapshot: %w", err)
+	}
+	log.Printf("canvas archive: stored %s (%d bytes, %d pixels)", name, blob.Len(), len(state.Pixels))
+	return nil
+}
+
+// loadArchivedGrid reads the snapshot taken at the given time.
+func (s *Server) loadArchivedGrid(ctx context.Context, taken time.Time) (storage.PixelState, error) {
+	blob, err := s.canvasArchive.store.Get(ctx, canvasArchiveName(taken, canvasArchiveBlobSuffix))
+	if err != nil {
+		return storage.PixelState{}, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		return storage.PixelState{}, fmt.Errorf("decompress canvas snapshot: %w", err)
+	}
+	defer zr.Close()
+	var state storage.PixelState
+	if err := json.NewDecoder(zr).Decode(&state); err != nil {
+		return storage.PixelState{}, fmt.Errorf("decode canvas snapshot: %w", err)
+	}
+	return state, nil
+}
+
+// parseArchiveTime reads ?at as RFC 3339 or as a date, which stands for the end of that day.
+func parseArchiveTime(raw string) (time.Time, bool) {
+	if at, err := time.Parse(time.RFC3339, raw); err == nil {
+		return at, true
+	}
+	if day, err := time.Parse(time.DateOnly, raw); err == nil {
+		return day.Add(24*time.Hour - time.Nanosecond), true
+	}
+	return time.Time{}, false
+}
+
+// requireCanvasArchive answers 404 while archiving is disabled.
+func (s *Server) requireCanvasArchive(c *gin.Context) bool {
+	if s.canvasArchive == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "canvas archive is disabled"})
+		return false
+	}
+	return true
+}
+
+// handleListCanvasArchive lists when the archived snapshots were taken, oldest first.
+func (s *Server) handleListCanvasArchive(c *gin.Context) {
+	if !s.requireCanvasArchive(c) {
+		return
+	}
+	taken, err := s.canvasArchive.snapshots(c.Request.Context())
+	if err != nil {
+		log.Printf("list canvas snapshots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list snapshots"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": taken})
+}
+
+// handleGetArchivedCanvasPNG renders the canvas as it was on ?at: the latest snapshot taken at or
+// before it, as an RFC 3339 time or a date (the end of that day, UTC). ?width scales it like
+// GET /api/canvas.png. Pixels hidden today are hidden in old snapshots too. The snapshot time is
+// returned in X-Snapshot-Time.
+func (s *Server) handleGetArchivedCanvasPNG(c *gin.Context) {
+	if !s.requireCanvasArchive(c) {
+		return
+	}
+	at, ok := parseArchiveTime(strings.TrimSpace(c.Query("at")))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time or a date"})
+		return
+	}
+	width := storage.GridWidth
+	if raw := c.Query("width"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || !slices.Contains(canvasPNGWidths, value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("width must be one of %v", canvasPNGWidths)})
+			return
+		}
+		width = value
+	}
+
+	ctx := c.Request.Context()
+	times, err := s.canvasArchive.snapshots(ctx)
+	if err != nil {
+		log.Printf("list canvas snapshots: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load snapshot"})
+		return
+	}
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(at) })
+	if i == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no snapshot at that time"})
+		return
+	}
+	taken := times[i-1]
+
+	key := fmt.Sprintf("%s width=%d", taken.Format(canvasArchiveTimeLayout), width)
+	body, err := s.canvasArchive.rendered(key, func() ([]byte, error) {
+		state, err := s.loadArchivedGrid(ctx, taken)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		var image bytes.Buffer
+		err = state.WritePNG(&image, width, width*state.Height/max(state.Width, 1))
+		return image.Bytes(), err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no snapshot at that time"})
+		return
+	}
+	if err != nil {
+		log.Printf("render canvas snapshot %s: %v", taken.Format(canvasArchiveTimeLayout), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load snapshot"})
+		return
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", "image/png")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Set("Cache-Control", "public, max-age=3600")
+	header.Set("X-Snapshot-Time", taken.Format(time.RFC3339))
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := c.Writer.Write(body); err != nil {
+		log.Printf("write canvas snapshot: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// canvasPNGWidths lists the widths /api/canvas.png renders. A fixed list keeps the grid cache
+// at one entry per size.
+var canvasPNGWidths = []int{100, 200, 250, 500, 600, 1000, 1200, 2000}
+
+// handleGetCanvasPNG renders the grid as a PNG so the landing page, social previews and bots can
+// load one image instead of the pixel list. ?width scales it to one of canvasPNGWidths, keeping
+// the aspect ratio; by default every pixel is one image pixel. Pixels are hidden as on the grid:
+// free and awaiting review ones in the canvas background color, ones under a takedown in grey.
+func (s *Server) handleGetCanvasPNG(c *gin.Context) {
+	width := storage.GridWidth
+	if raw := c.Query("width"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || !slices.Contains(canvasPNGWidths, value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("width must be one of %v", canvasPNGWidths)})
+			return
+		}
+		width = value
+	}
+	height := width * storage.GridHeight / storage.GridWidth
+
+	key := fmt.Sprintf("canvas png width=%d", width)
+	s.serveGrid(c, key, "image/png", func(ctx context.Context) (func(io.Writer) error, error) {
+		state, err := s.loadGrid(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		return func(w io.Writer) error { return state.WritePNG(w, width, height) }, nil
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// canvasStatsRecentPurchases is how many of the latest purchases GET /api/stats lists.
+const canvasStatsRecentPurchases = 10
+
+type canvasStatsPurchase struct {
+	ID          int       `json:"id"`
+	X           int       `json:"x"`
+	Y           int       `json:"y"`
+	PurchasedAt time.Time `json:"purchased_at"`
+}
+
+type canvasStatsResponse struct {
+	PixelsTaken     int64                 `json:"pixels_taken"`
+	PixelsFree      int64                 `json:"pixels_free"`
+	Owners          int64                 `json:"owners"`
+	PointsSpent     int64                 `json:"points_spent"`
+	RecentPurchases []canvasStatsPurchase `json:"recent_purchases"`
+}
+
+// handleCanvasStats returns the board's totals and its latest purchases for the public statistics
+// page. The totals come from aggregate queries, never from loading the grid, and the response is
+// cached like the grid itself. Purchases name the pixel only: neither its owner nor its content,
+// which may still be awaiting review.
+func (s *Server) handleCanvasStats(c *gin.Context) {
+	s.serveGrid(c, "stats", "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		stats, err := s.store.GetCanvasStats(ctx, canvasStatsRecentPurchases)
+		if err != nil {
+			return nil, err
+		}
+		response := canvasStatsResponse{
+			PixelsTaken:     stats.PixelsTaken,
+			PixelsFree:      stats.PixelsFree,
+			Owners:          stats.Owners,
+			PointsSpent:     stats.PointsSpent,
+			RecentPurchases: make([]canvasStatsPurchase, 0, len(stats.RecentPurchases)),
+		}
+		for _, change := range stats.RecentPurchases {
+			response.RecentPurchases = append(response.RecentPurchases, canvasStatsPurchase{
+				ID:          change.PixelID,
+				X:           change.PixelID % storage.GridWidth,
+				Y:           change.PixelID / storage.GridWidth,
+				PurchasedAt: change.ChangedAt,
+			})
+		}
+		return func(w io.Writer) error { return json.NewEncoder(w).Encode(response) }, nil
+	})
+}
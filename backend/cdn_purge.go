@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// gridChanged is called after pixels were modified. It marks the in-memory grid stale, invalidates
+// the local grid cache, queues
+// CDN purges of the grid URLs and of the per-pixel URLs of the changed pixels and tells live
+// subscribers which pixels to refetch.
+func (s *Server) gridChanged(pixelIDs ...int) {
+	s.gridSnapshot.Stale()
+	s.purgeGrid(pixelIDs)
+	s.pixelFeed.Publish(pixelChange{IDs: pixelIDs})
+}
+
+func (s *Server) purgeGrid(pixelIDs []int) {
+	s.gridCache.Invalidate()
+	s.gridVersion.Bump()
+	if s.cdnPurger == nil {
+		return
+	}
+	urls := make([]string, 0, len(s.purgeURLs)+len(pixelIDs)*len(s.pixelPurgeURLs))
+	urls = append(urls, s.purgeURLs...)
+	for _, id := range pixelIDs {
+		for _, template := range s.pixelPurgeURLs {
+			urls = append(urls, strings.ReplaceAll(template, "{id}", strconv.Itoa(id)))
+		}
+	}
+	s.cdnPurger.Enqueue(urls...)
+}
+
+func prefixURLs(base string, paths []string) []string {
+	urls := make([]string, 0, len(paths))
+	for _, path := range paths {
+		urls = append(urls, base+path)
+	}
+	return urls
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// consistencyReport is the outcome of one consistency check.
+type consistencyReport struct {
+	CheckedAt time.Time         `json:"checked_at"`
+	Repair    bool              `json:"repair"`
+	Anomalies []storage.Anomaly `json:"anomalies"`
+}
+
+// lastConsistencyReport keeps the report of the latest check for GET /api/admin/consistency.
+type lastConsistencyReport struct {
+	mu     sync.Mutex
+	report *consistencyReport
+}
+
+func (l *lastConsistencyReport) get() *consistencyReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.report
+}
+
+func (l *lastConsistencyReport) set(report consistencyReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report = &report
+}
+
+// checkConsistency looks for orphaned and inconsistent data, logs every anomaly kind found and,
+// when repair is set, fixes it. Freed pixels are pushed to the grid like any other change.
+func (s *Server) checkConsistency(ctx context.Context, repair bool) (consistencyReport, error) {
+	anomalies, err := s.store.CheckConsistency(ctx, repair)
+	if err != nil {
+		return consistencyReport{}, err
+	}
+	report := consistencyReport{CheckedAt: time.Now().UTC(), Repair: repair, Anomalies: anomalies}
+	for _, anomaly := range anomalies {
+		if len(anomaly.IDs) == 0 {
+			continue
+		}
+		log.Printf("consistency: kind=%s found=%d repaired=%d ids=%v", anomaly.Kind, len(anomaly.IDs), anomaly.Repaired, anomaly.IDs)
+		if anomaly.Kind == storage.AnomalyOrphanedPixels && anomaly.Repaired > 0 {
+			ids := make([]int, len(anomaly.IDs))
+			for i, id := range anomaly.IDs {
+				ids[i] = int(id)
+			}
+			s.gridChanged(ids...)
+		}
+	}
+	s.consistency.set(report)
+	return report, nil
+}
+
+// handleGetConsistency returns the report of the latest consistency check, running a dry run
+// first when none has happened since startup.
+func (s *Server) handleGetConsistency(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	if report := s.consistency.get(); report != nil {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	report, err := s.checkConsistency(c.Request.Context(), false)
+	if err != nil {
+		log.Printf("consistency: check failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check consistency"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleCheckConsistency runs a consistency check now. It only reports by default; ?dry_run=false
+// also repairs the anomalies it finds.
+func (s *Server) handleCheckConsistency(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	dryRun := true
+	if raw := c.Query("dry_run"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+		dryRun = value
+	}
+	report, err := s.checkConsistency(c.Request.Context(), !dryRun)
+	if err != nil {
+		log.Printf("consistency: check failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check consistency"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	maxContactNameLength    = 200
+	maxContactSubjectLength = 200
+	maxContactBodyLength    = 5000
+)
+
+type contactRequest struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+	Token   string `json:"turnstile_token"`
+}
+
+type contactReplyRequest struct {
+	Message string `json:"message"`
+}
+
+// handleContact stores a contact form message and forwards it to the admins. Logged-in senders
+// are linked to their account and may omit their email.
+func (s *Server) handleContact(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
+	var req contactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	message := storage.ContactMessage{
+		Name:    strings.TrimSpace(req.Name),
+		Email:   strings.ToLower(strings.TrimSpace(req.Email)),
+		Subject: strings.TrimSpace(req.Subject),
+		Body:    strings.TrimSpace(req.Message),
+	}
+	if user, _, ok := s.getSessionUser(c); ok {
+		message.UserID = &user.ID
+		if message.Email == "" {
+			message.Email = user.Email
+		}
+	}
+	switch {
+	case utf8.RuneCountInString(message.Name) > maxContactNameLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("name must be at most %d characters", maxContactNameLength)})
+		return
+	case message.Subject == "" || utf8.RuneCountInString(message.Subject) > maxContactSubjectLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("subject is required and must be at most %d characters", maxContactSubjectLength)})
+		return
+	case message.Body == "" || utf8.RuneCountInString(message.Body) > maxContactBodyLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("message is required and must be at most %d characters", maxContactBodyLength)})
+		return
+	}
+	if _, err := mail.ParseAddress(message.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+		return
+	}
+	if !s.requireTurnstile(c, req.Token) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	message, err := s.store.CreateContactMessage(ctx, message)
+	if err != nil {
+		log.Printf("contact: store message: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send message"})
+		return
+	}
+	log.Printf("contact: message id=%d from=%s", message.ID, message.Email)
+
+	if sender, ok := s.mailer.(email.NoticeSender); ok {
+		notice := email.Notice{
+			Subject: fmt.Sprintf("Wiadomość z formularza kontaktowego #%d: %s", message.ID, message.Subject),
+			Body:    fmt.Sprintf("Od: %s <%s>\n\n%s", message.Name, message.Email, message.Body),
+		}
+		admins := make([]string, 0, len(s.adminEmails))
+		for address := range s.adminEmails {
+			admins = append(admins, address)
+		}
+		sort.Strings(admins)
+		for _, address := range admins {
+			if err := sender.SendNotice(ctx, address, notice); err != nil {
+				log.Printf("contact: notify admin %s of message %d: %v", address, message.ID, err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": message.ID})
+}
+
+// handleAdminContactMessages lists contact messages; ?status= defaults to open, "all" lists every message.
+func (s *Server) handleAdminContactMessages(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
+	switch status {
+	case "":
+		status = storage.ContactStatusOpen
+	case "all":
+		status = ""
+	case storage.ContactStatusOpen, storage.ContactStatusAnswered:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+	messages, err := s.store.ListContactMessages(c.Request.Context(), status)
+	if err != nil {
+		log.Printf("contact: list messages: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// handleAdminContactMessage returns a message with the replies sent so far.
+func (s *Server) handleAdminContactMessage(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	message, ok := s.loadContactMessageParam(c)
+	if !ok {
+		return
+	}
+	replies, err := s.store.ListContactReplies(c.Request.Context(), message.ID)
+	if err != nil {
+		log.Printf("contact: replies for %d: %v", message.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load message"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "replies": replies})
+}
+
+// handleAdminContactReply emails the reply to the sender and records it; nothing is stored when delivery fails.
+func (s *Server) handleAdminContactReply(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	var req contactReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	body := strings.TrimSpace(req.Message)
+	if body == "" || utf8.RuneCountInString(body) > maxContactBodyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("message is required and must be at most %d characters", maxContactBodyLength)})
+		return
+	}
+	message, ok := s.loadContactMessageParam(c)
+	if !ok {
+		return
+	}
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "configured mailer cannot send replies"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	notice := email.Notice{
+		Subject: "Re: " + message.Subject,
+		Body:    body + "\n\n---\n" + message.Body,
+	}
+	if err := sender.SendNotice(ctx, message.Email, notice); err != nil {
+		log.Printf("contact: reply to message %d: %v", message.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send reply"})
+		return
+	}
+	reply, err := s.store.CreateContactReply(ctx, storage.ContactReply{MessageID: message.ID, AdminID: admin.ID, Body: body})
+	if err != nil {
+		log.Printf("contact: store reply to message %d: %v", message.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "reply sent but could not be recorded"})
+		return
+	}
+	log.Printf("contact: reply id=%d message_id=%d admin_id=%d", reply.ID, message.ID, admin.ID)
+	c.JSON(http.StatusCreated, gin.H{"reply": reply})
+}
+
+func (s *Server) loadContactMessageParam(c *gin.Context) (storage.ContactMessage, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return storage.ContactMessage{}, false
+	}
+	message, err := s.store.GetContactMessage(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message not found"})
+		return storage.ContactMessage{}, false
+	}
+	if err != nil {
+		log.Printf("contact: load message %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load message"})
+		return storage.ContactMessage{}, false
+	}
+	return message, true
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+const (
+	contentBlocklistSettingName = "content_blocklist"
+	maxBlocklistEntries         = 1000
+	maxBlocklistEntryLength     = 200
+)
+
+type contentBlocklistRequest struct {
+	Entries []string `json:"entries"`
+}
+
+// loadContentBlocklist switches pixel content checks to the list admins saved, or to configured
+// when there is none, and reports which one is active: "admin" or "config". An unreadable saved
+// list is logged and skipped.
+func (s *Server) loadContentBlocklist(ctx context.Context, configured []string) (string, error) {
+	raw, err := s.store.GetSetting(ctx, contentBlocklistSettingName)
+	switch {
+	case err == nil:
+		var entries []string
+		if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+			log.Printf("content blocklist: decode saved list: %v", err)
+			break
+		}
+		list, err := newContentBlocklist(entries)
+		if err != nil {
+			log.Printf("content blocklist: saved list: %v", err)
+			break
+		}
+		s.contentBlocklist.Store(list)
+		return "admin", nil
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("content blocklist: load saved list: %v", err)
+	}
+	list, err := newContentBlocklist(configured)
+	if err != nil {
+		return "", err
+	}
+	s.contentBlocklist.Store(list)
+	return "config", nil
+}
+
+// reloadContentBlocklist re-reads pixelContent.blockedTerms from the config file and applies
+// them unless admins saved their own list.
+func (s *Server) reloadContentBlocklist(ctx context.Context) (string, error) {
+	var configured []string
+	if s.configPath != "" {
+		cfg, err := config.Load(s.configPath)
+		if err != nil {
+			return "", fmt.Errorf("read config: %w", err)
+		}
+		configured = cfg.PixelContent.BlockedTerms
+	}
+	return s.loadContentBlocklist(ctx, configured)
+}
+
+func (s *Server) writeContentBlocklist(c *gin.Context, source string) {
+	entries := []string{}
+	if list := s.contentBlocklist.Load(); list != nil {
+		entries = list.Entries
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "source": source})
+}
+
+// respondReloadedBlocklist reloads the blocklist and answers with it. An invalid config file
+// leaves the current list in force.
+func (s *Server) respondReloadedBlocklist(c *gin.Context) {
+	source, err := s.reloadContentBlocklist(c.Request.Context())
+	if err != nil {
+		log.Printf("reload content blocklist: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	s.writeContentBlocklist(c, source)
+}
+
+// handleGetContentBlocklist returns the blocked terms and expressions in force and whether they
+// come from the config file or were saved by an admin.
+func (s *Server) handleGetContentBlocklist(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	source := "config"
+	if _, err := s.store.GetSetting(c.Request.Context(), contentBlocklistSettingName); err == nil {
+		source = "admin"
+	}
+	s.writeContentBlocklist(c, source)
+}
+
+// handlePutContentBlocklist replaces the blocklist at runtime. The list is saved, so it survives
+// restarts and takes precedence over the config file until deleted.
+func (s *Server) handlePutContentBlocklist(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	var req contentBlocklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Entries == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entries must be a list of terms"})
+		return
+	}
+	if len(req.Entries) > maxBlocklistEntries {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d entries are allowed", maxBlocklistEntries)})
+		return
+	}
+	for _, entry := range req.Entries {
+		if utf8.RuneCountInString(entry) > maxBlocklistEntryLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("entries must be at most %d characters", maxBlocklistEntryLength)})
+			return
+		}
+	}
+	list, err := newContentBlocklist(req.Entries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := json.Marshal(list.Entries)
+	if err != nil {
+		log.Printf("encode content blocklist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save blocklist"})
+		return
+	}
+	if err := s.store.PutSetting(c.Request.Context(), contentBlocklistSettingName, string(data)); err != nil {
+		log.Printf("save content blocklist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save blocklist"})
+		return
+	}
+	s.contentBlocklist.Store(list)
+	log.Printf("content blocklist updated by %s: %d entries", admin.Email, len(list.Entries))
+	s.writeContentBlocklist(c, "admin")
+}
+
+// handleDeleteContentBlocklist drops the saved list and goes back to the config file.
+func (s *Server) handleDeleteContentBlocklist(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	if err := s.store.DeleteSetting(c.Request.Context(), contentBlocklistSettingName); err != nil {
+		log.Printf("delete content blocklist: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete blocklist"})
+		return
+	}
+	s.respondReloadedBlocklist(c)
+}
+
+// handleReloadContentBlocklist applies pixelContent.blockedTerms from the config file, or the list
+// saved by an admin, without restarting.
+func (s *Server) handleReloadContentBlocklist(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	s.respondReloadedBlocklist(c)
+}
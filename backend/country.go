@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/geoip"
+)
+
+const (
+	countryOverrideDateLayout  = "20060102"
+	defaultCountryOverrideDays = 30
+	maxCountryOverrideDays     = 365
+)
+
+// countryPolicy decides whether clients from a country may register and pay.
+type countryPolicy struct {
+	allow          map[string]bool
+	deny           map[string]bool
+	header         string
+	db             *geoip.DB
+	blockUnknown   bool
+	overrideSecret []byte
+}
+
+// newCountryPolicy returns nil when no restrictions are configured.
+func newCountryPolicy(cfg config.CountryRestrictions) (*countryPolicy, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	policy := &countryPolicy{
+		allow:          make(map[string]bool, len(cfg.Allow)),
+		deny:           make(map[string]bool, len(cfg.Deny)),
+		header:         cfg.CountryHeader,
+		blockUnknown:   cfg.BlockUnknown,
+		overrideSecret: []byte(cfg.OverrideSecret),
+	}
+	for _, code := range cfg.Allow {
+		policy.allow[code] = true
+	}
+	for _, code := range cfg.Deny {
+		policy.deny[code] = true
+	}
+	if cfg.GeoIPDatabase != "" {
+		db, err := geoip.Open(cfg.GeoIPDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("load geoip database: %w", err)
+		}
+		policy.db = db
+	}
+	return policy, nil
+}
+
+// country returns the client's country from the trusted header, falling back to GeoIP. "" means unknown.
+func (p *countryPolicy) country(r *http.Request) string {
+	if p.header != "" {
+		// Cloudflare reports XX for unknown locations and T1 for Tor exit nodes.
+		code := strings.ToUpper(strings.TrimSpace(r.Header.Get(p.header)))
+		if code != "" && code != "XX" && code != "T1" {
+			return code
+		}
+	}
+	// Not clientSource: that groups addresses into networks for counting, which GeoIP cannot look
+	// up, and the country of an address does not depend on how sources are counted. Both resolve
+	// the client address the same way through extractRemoteIP.
+	return p.db.Country(extractRemoteIP(r))
+}
+
+func (p *countryPolicy) allowed(country string) bool {
+	if country == "" {
+		return !p.blockUnknown
+	}
+	if p.deny[country] {
+		return false
+	}
+	return len(p.allow) == 0 || p.allow[country]
+}
+
+// overrideCode issues a code letting email register and pay regardless of country until expires.
+// The code is stateless: an expiry date plus an HMAC over the email and that date.
+func (p *countryPolicy) overrideCode(email string, expires time.Time) string {
+	date := expires.UTC().Format(countryOverrideDateLayout)
+	return date + "-" + p.overrideMAC(email, date)
+}
+
+func (p *countryPolicy) overrideMAC(email, date string) string {
+	mac := hmac.New(sha256.New, p.overrideSecret)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email)) + "|" + date))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil))[:16]
+}
+
+func (p *countryPolicy) validOverride(email, code string, now time.Time) bool {
+	if len(p.overrideSecret) == 0 {
+		return false
+	}
+	date, mac, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(code)), "-")
+	if !ok {
+		return false
+	}
+	expires, err := time.Parse(countryOverrideDateLayout, date)
+	if err != nil || now.UTC().After(expires.Add(24*time.Hour)) {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(p.overrideMAC(email, date)))
+}
+
+// requireAllowedCountry rejects the request with 451 when the client's country is restricted
+// and no valid override code for email was supplied.
+func (s *Server) requireAllowedCountry(c *gin.Context, action, email, override string) bool {
+	if s.countryPolicy == nil {
+		return true
+	}
+	country := s.countryPolicy.country(c.Request)
+	if s.countryPolicy.allowed(country) {
+		return true
+	}
+	if override != "" && s.countryPolicy.validOverride(email, override, time.Now()) {
+		log.Printf("country restriction: override used action=%s country=%s email=%s", action, country, email)
+		return true
+	}
+	log.Printf("country restriction: blocked action=%s country=%q ip=%s", action, country, s.clientSource(c.Request))
+	c.JSON(http.StatusUnavailableForLegalReasons, gin.H{
+		"error":   "Kup Piksel is not available in your country",
+		"code":    "country_restricted",
+		"country": country,
+	})
+	return false
+}
+
+type countryOverrideRequest struct {
+	Email string `json:"email"`
+	Days  int    `json:"days"`
+}
+
+// handleCreateCountryOverride lets support issue an override code for a customer's email.
+func (s *Server) handleCreateCountryOverride(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	if s.countryPolicy == nil || len(s.countryPolicy.overrideSecret) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "country overrides are not configured"})
+		return
+	}
+
+	var req countryOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+		return
+	}
+	days := req.Days
+	if days == 0 {
+		days = defaultCountryOverrideDays
+	}
+	if days < 1 || days > maxCountryOverrideDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxCountryOverrideDays)})
+		return
+	}
+
+	expires := time.Now().UTC().AddDate(0, 0, days)
+	log.Printf("country restriction: override issued admin_id=%d email=%s expires=%s", admin.ID, email, expires.Format(time.DateOnly))
+	c.JSON(http.StatusCreated, gin.H{
+		"email":      email,
+		"code":       s.countryPolicy.overrideCode(email, expires),
+		"expires_on": expires.Format(time.DateOnly),
+	})
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	// customDomainChallengePrefix is prepended to a domain to name its TXT challenge record.
+	customDomainChallengePrefix = "_kuppiksel-challenge."
+	// customDomainChallengeValue prefixes the token in the TXT record.
+	customDomainChallengeValue = "kuppiksel-verify="
+	customDomainLookupTimeout  = 5 * time.Second
+	maxCustomDomainLength      = 253
+)
+
+type customDomainRequest struct {
+	Domain string `json:"domain"`
+	// PixelID is the pixel visitors of the domain are redirected to. Only admins may leave it out
+	// to serve the board itself under the domain.
+	PixelID *int `json:"pixel_id"`
+}
+
+// customDomainRoute is where a verified domain leads: the board itself when PixelID is nil,
+// otherwise the link of the pixel while UserID still owns it.
+type customDomainRoute struct {
+	UserID  int64
+	PixelID *int
+}
+
+// customDomainRoutes holds the verified domains in memory, so routing a request needs no
+// database read. The check job reloads it.
+type customDomainRoutes struct {
+	mu     sync.RWMutex
+	routes map[string]customDomainRoute
+}
+
+func newCustomDomainRoutes() *customDomainRoutes {
+	return &customDomainRoutes{routes: make(map[string]customDomainRoute)}
+}
+
+func (r *customDomainRoutes) lookup(host string) (customDomainRoute, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	route, ok := r.routes[host]
+	return route, ok
+}
+
+func (r *customDomainRoutes) replace(domains []storage.CustomDomain) {
+	routes := make(map[string]customDomainRoute, len(domains))
+	for _, domain := range domains {
+		routes[domain.Domain] = customDomainRoute{UserID: domain.UserID, PixelID: domain.PixelID}
+	}
+	r.mu.Lock()
+	r.routes = routes
+	r.mu.Unlock()
+}
+
+// normalizeCustomDomain lower-cases a domain name and checks it is a plain host name with at
+// least two labels; IP addresses, ports and URLs are rejected.
+func normalizeCustomDomain(raw string) (string, bool) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if domain == "" || len(domain) > maxCustomDomainLength {
+		return "", false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return "", false
+			}
+		}
+	}
+	if _, err := strconv.Atoi(labels[len(labels)-1]); err == nil {
+		return "", false
+	}
+	return domain, true
+}
+
+// customDomainChallenge describes the TXT record the user has to publish for domain.
+func customDomainChallenge(domain storage.CustomDomain) gin.H {
+	return gin.H{
+		"type":  "TXT",
+		"name":  customDomainChallengePrefix + domain.Domain,
+		"value": customDomainChallengeValue + domain.Token,
+	}
+}
+
+func customDomainResponse(domain storage.CustomDomain) gin.H {
+	return gin.H{"domain": domain, "verified": domain.VerifiedAt != nil, "challenge": customDomainChallenge(domain)}
+}
+
+// requireCustomDomains answers 404 while custom domains are disabled.
+func (s *Server) requireCustomDomains(c *gin.Context) bool {
+	if s.domainRoutes == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "custom domains are disabled"})
+		return false
+	}
+	return true
+}
+
+// handleListCustomDomains returns the signed-in user's domains with their challenges.
+func (s *Server) handleListCustomDomains(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireCustomDomains(c) {
+		return
+	}
+	domains, err := s.store.ListCustomDomains(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("custom domains: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load domains"})
+		return
+	}
+	response := make([]gin.H, len(domains))
+	for i, domain := range domains {
+		response[i] = customDomainResponse(domain)
+	}
+	c.JSON(http.StatusOK, gin.H{"domains": response})
+}
+
+// handleCreateCustomDomain registers a domain for the signed-in user and returns the TXT record
+// proving control over it. The domain is routed once the check job finds the record.
+func (s *Server) handleCreateCustomDomain(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireCustomDomains(c) || s.rejectWrites(c) {
+		return
+	}
+	var req customDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	name, ok := normalizeCustomDomain(req.Domain)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid domain"})
+		return
+	}
+	ctx := c.Request.Context()
+	if req.PixelID == nil {
+		if !s.isAdmin(user) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pixel_id is required"})
+			return
+		}
+	} else if !s.ownsPixel(c, user.ID, *req.PixelID) {
+		return
+	}
+
+	existing, err := s.store.ListCustomDomains(ctx, user.ID)
+	if err != nil {
+		log.Printf("custom domains: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register domain"})
+		return
+	}
+	if len(existing) >= s.customDomains.MaxPerUser && !s.isAdmin(user) {
+		c.JSON(http.StatusConflict, gin.H{"error": "domain limit reached", "code": "domain_limit"})
+		return
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		log.Printf("custom domains: generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register domain"})
+		return
+	}
+	domain, err := s.store.CreateCustomDomain(ctx, storage.CustomDomain{
+		Domain:  name,
+		UserID:  user.ID,
+		PixelID: req.PixelID,
+		Token:   hex.EncodeToString(token),
+	})
+	if errors.Is(err, storage.ErrDomainTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "domain already registered", "code": "domain_taken"})
+		return
+	}
+	if err != nil {
+		log.Printf("custom domains: create user_id=%d domain=%s: %v", user.ID, name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register domain"})
+		return
+	}
+	c.JSON(http.StatusCreated, customDomainResponse(domain))
+}
+
+// ownsPixel answers 404 unless userID owns pixel id.
+func (s *Server) ownsPixel(c *gin.Context, userID int64, id int) bool {
+	if id < 0 || id >= storage.TotalPixels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel id"})
+		return false
+	}
+	owned, err := s.store.GetPixelsByOwner(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("custom domains: owned pixels user_id=%d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return false
+	}
+	for _, pixel := range owned {
+		if pixel.ID == id {
+			return true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+	return false
+}
+
+// handleDeleteCustomDomain removes one of the signed-in user's domains; it stops being routed
+// right away.
+func (s *Server) handleDeleteCustomDomain(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireCustomDomains(c) || s.rejectWrites(c) {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid domain id"})
+		return
+	}
+	ctx := c.Request.Context()
+	if err := s.store.DeleteCustomDomain(ctx, user.ID, id); err != nil {
+		log.Printf("custom domains: delete user_id=%d id=%d: %v", user.ID, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete domain"})
+		return
+	}
+	if err := s.reloadCustomDomains(ctx); err != nil {
+		log.Printf("custom domains: reload: %v", err)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// checkCustomDomains looks up the TXT challenge of every unverified domain, removes domains left
+// unverified for too long and reloads the routing table. Verified domains are checked again, and
+// one whose record is gone is removed, so it stops being routed.
+func (s *Server) checkCustomDomains(ctx context.Context) error {
+	pending, err := s.store.ListCustomDomainsByState(ctx, false)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, domain := range pending {
+		// A missing record is the usual state of a new domain, so lookup errors are not logged.
+		verified, _ := s.customDomainChallengeFound(ctx, domain)
+		err := s.store.MarkCustomDomainChecked(ctx, domain.ID, verified, now)
+		if errors.Is(err, storage.ErrDomainTaken) {
+			log.Printf("custom domains: domain=%s user_id=%d was verified by another user first", domain.Domain, domain.UserID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if verified {
+			log.Printf("custom domains: verified domain=%s user_id=%d", domain.Domain, domain.UserID)
+		}
+	}
+
+	verified, err := s.store.ListCustomDomainsByState(ctx, true)
+	if err != nil {
+		return err
+	}
+	for _, domain := range verified {
+		found, err := s.customDomainChallengeFound(ctx, domain)
+		if err != nil {
+			// The record may well still be there; the domain keeps routing until a lookup answers.
+			log.Printf("custom domains: recheck domain=%s: %v", domain.Domain, err)
+			continue
+		}
+		if found {
+			if err := s.store.MarkCustomDomainChecked(ctx, domain.ID, true, now); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.store.DeleteCustomDomain(ctx, domain.UserID, domain.ID); err != nil {
+			return err
+		}
+		log.Printf("custom domains: removed domain=%s user_id=%d, its TXT record is gone", domain.Domain, domain.UserID)
+	}
+
+	removed, err := s.store.DeleteUnverifiedCustomDomains(ctx, now.Add(-time.Duration(s.customDomains.PendingTTLHours)*time.Hour))
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Printf("custom domains: removed %d domains left unverified", removed)
+	}
+	return s.reloadCustomDomains(ctx)
+}
+
+// customDomainChallengeFound reports whether the TXT challenge of domain is published. The error
+// is set when the lookup failed for another reason than the record or the name not existing.
+func (s *Server) customDomainChallengeFound(ctx context.Context, domain storage.CustomDomain) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, customDomainLookupTimeout)
+	defer cancel()
+	records, err := s.lookupTXT(ctx, customDomainChallengePrefix+domain.Domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	want := customDomainChallengeValue + domain.Token
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Server) reloadCustomDomains(ctx context.Context) error {
+	verified, err := s.store.ListCustomDomainsByState(ctx, true)
+	if err != nil {
+		return err
+	}
+	s.domainRoutes.replace(verified)
+	return nil
+}
+
+// customDomainMiddleware redirects every request for a verified pixel domain to the pixel's link.
+// Domains of the board itself and all other hosts are served as usual.
+func (s *Server) customDomainMiddleware(c *gin.Context) {
+	if s.domainRoutes == nil {
+		c.Next()
+		return
+	}
+	route, ok := s.domainRoutes.lookup(requestHost(c.Request))
+	if !ok || route.PixelID == nil {
+		c.Next()
+		return
+	}
+	c.Abort()
+	s.redirectToPixel(c, strconv.Itoa(*route.PixelID), route.UserID)
+}
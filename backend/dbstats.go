@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const (
+	// dbStatsRequestHeader asks for a database timing breakdown; only honoured for admin sessions.
+	dbStatsRequestHeader = "X-Debug-DB"
+	dbStatsHeader        = "X-DB-Stats"
+	dbSlowestHeader      = "X-DB-Slowest"
+)
+
+// dbStatsWriter adds the statistics gathered so far right before the response headers are sent.
+type dbStatsWriter struct {
+	http.ResponseWriter
+	stats       *sqltrace.Stats
+	wroteHeader bool
+}
+
+func (w *dbStatsWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		summary, slowest := w.stats.Summary()
+		count, total := w.stats.Statements()
+		header := w.Header()
+		header.Set(dbStatsHeader, summary)
+		if slowest != "" {
+			header.Set(dbSlowestHeader, slowest)
+		}
+		header.Add("Server-Timing", fmt.Sprintf(`db;dur=%.3f;desc="%d statements"`, float64(total.Microseconds())/1000, count))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dbStatsWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *dbStatsWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// dbStatsMiddleware records every statement of the request when an admin sends X-Debug-DB
+// and reports the count and timings in response headers, so production slowness can be
+// narrowed down without attaching a profiler.
+func (s *Server) dbStatsMiddleware(c *gin.Context) {
+	if !dbStatsRequested(c.Request.Header.Get(dbStatsRequestHeader)) {
+		c.Next()
+		return
+	}
+	user, _, ok := s.getSessionUser(c)
+	if !ok || !s.isAdmin(user) {
+		c.Next()
+		return
+	}
+
+	ctx, stats := sqltrace.WithStats(c.Request.Context())
+	c.Request = c.Request.WithContext(ctx)
+	c.Writer = &dbStatsWriter{ResponseWriter: c.Writer, stats: stats}
+	c.Next()
+}
+
+func dbStatsRequested(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const adminPprofPrefix = "/api/admin/debug/pprof/"
+
+// diagnosticsHandler serves pprof profiles below prefix (which must end with "/") and expvar
+// variables at vars.
+func diagnosticsHandler(prefix, vars string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(vars, expvar.Handler())
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		switch name {
+		case "":
+			pprof.Index(w, withPath(r, "/debug/pprof/"))
+		case "cmdline":
+			pprof.Cmdline(w, r)
+		case "profile":
+			pprof.Profile(w, r)
+		case "symbol":
+			pprof.Symbol(w, r)
+		case "trace":
+			pprof.Trace(w, r)
+		default:
+			pprof.Handler(name).ServeHTTP(w, r)
+		}
+	})
+	return mux
+}
+
+// withPath returns a shallow copy of r with another URL path; pprof.Index expects the stock prefix.
+func withPath(r *http.Request, path string) *http.Request {
+	clone := r.Clone(r.Context())
+	clone.URL.Path = path
+	return clone
+}
+
+var adminDiagnostics = diagnosticsHandler(adminPprofPrefix, "/api/admin/debug/vars")
+
+// handleAdminDiagnostics exposes pprof and expvar to admin sessions so heap and CPU profiles can
+// be captured from a running instance.
+func (s *Server) handleAdminDiagnostics(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	adminDiagnostics.ServeHTTP(c.Writer, c.Request)
+}
+
+// startDiagnosticsListener serves the same diagnostics on a loopback-only address from config,
+// for use through SSH tunnels or from the host without an admin session.
+func startDiagnosticsListener(addr string) {
+	if addr == "" {
+		return
+	}
+	go func() {
+		log.Printf("diagnostics listener: addr=%s", addr)
+		if err := http.ListenAndServe(addr, diagnosticsHandler("/debug/pprof/", "/debug/vars")); err != nil {
+			log.Printf("diagnostics listener stopped: %v", err)
+		}
+	}()
+}
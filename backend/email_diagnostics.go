@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+)
+
+const emailTestTimeout = 30 * time.Second
+
+type emailTestRequest struct {
+	To string `json:"to"`
+}
+
+// mailerTransport names the configured mailer for diagnostics responses.
+func mailerTransport(m email.Mailer) string {
+	switch m := m.(type) {
+	case *email.SMTPMailer:
+		return "smtp"
+	case *email.MailgunMailer:
+		return "mailgun"
+	case *email.ConsoleMailer:
+		return "console"
+	case *email.Chain:
+		return "chain(" + strings.Join(m.Transports(), ",") + ")"
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
+
+// handleEmailTest sends a test message through the configured mailer and reports each transport
+// phase, so SMTP problems can be debugged from the admin panel. The recipient defaults to the admin.
+func (s *Server) handleEmailTest(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req emailTestRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+	}
+	to := strings.TrimSpace(req.To)
+	if to == "" {
+		to = admin.Email
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient"})
+		return
+	}
+
+	sender, ok := s.mailer.(email.TestSender)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "configured mailer does not support test messages", "transport": mailerTransport(s.mailer)})
+		return
+	}
+
+	var diag email.Diagnostics
+	ctx, cancel := context.WithTimeout(c.Request.Context(), emailTestTimeout)
+	defer cancel()
+	started := time.Now()
+	err := sender.SendTestEmail(email.WithDiagnostics(ctx, &diag), to)
+
+	resp := gin.H{
+		"ok":          err == nil,
+		"transport":   mailerTransport(s.mailer),
+		"to":          to,
+		"phases":      diag.Phases(),
+		"duration_ms": float64(time.Since(started).Microseconds()) / 1000,
+	}
+	if err != nil {
+		log.Printf("email test: admin_id=%d to=%s error=%v", admin.ID, to, err)
+		resp["error"] = err.Error()
+		c.JSON(http.StatusBadGateway, resp)
+		return
+	}
+	log.Printf("email test: admin_id=%d to=%s delivered", admin.ID, to)
+	c.JSON(http.StatusOK, resp)
+}
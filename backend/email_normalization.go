@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/example/kup-piksel/internal/emailaddr"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// normalizeEmail canonicalizes address under the configured policy. Unparseable input is only
+// lowercased so that validation can report it.
+func (s *Server) normalizeEmail(address string) string {
+	canonical, err := s.emailPolicy.Normalize(address)
+	if err != nil {
+		return emailaddr.Legacy(address)
+	}
+	return canonical
+}
+
+// findUserByEmail looks the user up by canonical address, then by the lowercased address that
+// accounts created before the normalization policy was enabled are stored under.
+func (s *Server) findUserByEmail(ctx context.Context, address string) (storage.User, error) {
+	canonical := s.normalizeEmail(address)
+	user, err := s.store.GetUserByEmail(ctx, canonical)
+	if legacy := emailaddr.Legacy(address); errors.Is(err, sql.ErrNoRows) && legacy != canonical {
+		return s.store.GetUserByEmail(ctx, legacy)
+	}
+	return user, err
+}
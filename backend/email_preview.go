@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+)
+
+const emailPreviewToken = "preview-token"
+
+// handleEmailPreview renders a transactional email with sample data so admins can review
+// translations and layout without sending real mail. format=html or format=text returns the
+// raw body; the default is a JSON document with the subject and both bodies.
+func (s *Server) handleEmailPreview(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	query := c.Request.URL.Query()
+	name := strings.TrimSpace(query.Get("template"))
+	if name == "" {
+		name = email.TemplateVerification
+	}
+	lang := strings.ToLower(strings.TrimSpace(query.Get("lang")))
+	if lang == "" {
+		lang = "pl"
+	}
+
+	var link string
+	var err error
+	switch name {
+	case email.TemplateVerification:
+		link, err = buildVerificationLink(s.verificationBaseURL, s.verificationLink, emailPreviewToken, lang)
+	case email.TemplatePasswordReset:
+		link, err = buildPasswordResetLink(s.passwordResetBaseURL, s.passwordResetLink, emailPreviewToken, lang)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown template", "templates": email.Templates()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare preview"})
+		return
+	}
+
+	msg, err := email.Render(name, lang, link)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported language", "languages": email.Languages()})
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(query.Get("format"))) {
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+	case "", "json":
+		c.JSON(http.StatusOK, gin.H{
+			"template": name,
+			"lang":     lang,
+			"subject":  msg.Subject,
+			"text":     msg.Text,
+			"html":     msg.HTML,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, html or text"})
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// engagementReportCheckInterval is how often the job looks for owners due a report.
+const engagementReportCheckInterval = time.Hour
+
+// engagementReport is what one owner's report email says about the reporting period.
+type engagementReport struct {
+	Pixels        int
+	Clicks        int64
+	FillPercent   float64
+	NeighboursNew int
+}
+
+type engagementReportRequest struct {
+	Subscribed *bool `json:"subscribed"`
+}
+
+func (s *Server) engagementReportPeriod() time.Duration {
+	return time.Duration(s.engagementReports.IntervalDays) * 24 * time.Hour
+}
+
+// requireEngagementReports answers 404 while reports are disabled in the configuration.
+func (s *Server) requireEngagementReports(c *gin.Context) bool {
+	if !s.engagementReports.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "engagement reports are disabled"})
+		return false
+	}
+	return true
+}
+
+// handleGetEngagementReports tells the signed-in user whether they receive the report.
+func (s *Server) handleGetEngagementReports(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireEngagementReports(c) {
+		return
+	}
+	subscription, err := s.store.GetEngagementReportSubscription(c.Request.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusOK, gin.H{"subscribed": false, "interval_days": s.engagementReports.IntervalDays})
+		return
+	}
+	if err != nil {
+		log.Printf("engagement reports: load user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load report settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscribed": true, "interval_days": s.engagementReports.IntervalDays, "subscription": subscription})
+}
+
+// handlePutEngagementReports opts the signed-in user in or out of the report with
+// {"subscribed": true|false}.
+func (s *Server) handlePutEngagementReports(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireEngagementReports(c) || s.rejectWrites(c) {
+		return
+	}
+	var req engagementReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Subscribed == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subscribed must be true or false"})
+		return
+	}
+	ctx := c.Request.Context()
+	if !*req.Subscribed {
+		if err := s.store.UnsubscribeEngagementReport(ctx, user.ID); err != nil {
+			log.Printf("engagement reports: unsubscribe user_id=%d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save report settings"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"subscribed": false, "interval_days": s.engagementReports.IntervalDays})
+		return
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("engagement reports: generate token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save report settings"})
+		return
+	}
+	subscription, err := s.store.SubscribeEngagementReport(ctx, user.ID, hex.EncodeToString(buf))
+	if err != nil {
+		log.Printf("engagement reports: subscribe user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save report settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscribed": true, "interval_days": s.engagementReports.IntervalDays, "subscription": subscription})
+}
+
+// handleUnsubscribeEngagementReports opts out the owner whose report email carried ?token, without
+// signing in. It works while reports are disabled, so old links keep their promise.
+func (s *Server) handleUnsubscribeEngagementReports(c *gin.Context) {
+	token := strings.TrimSpace(c.Query("token"))
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	found, err := s.store.UnsubscribeEngagementReportByToken(c.Request.Context(), token)
+	if err != nil {
+		log.Printf("engagement reports: unsubscribe by token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unsubscribe"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown or already used unsubscribe link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unsubscribed": true})
+}
+
+// sendEngagementReports emails every owner due a report a summary of the period since their
+// previous one. Owners without pixels are skipped; delivery failures are only logged.
+func (s *Server) sendEngagementReports(ctx context.Context) error {
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		log.Printf("engagement reports: mailer cannot send notices; reports skipped")
+		return nil
+	}
+	now := time.Now().UTC()
+	period := s.engagementReportPeriod()
+	due, err := s.store.ClaimEngagementReports(ctx, now.Add(-period), now)
+	if err != nil {
+		return fmt.Errorf("claim engagement reports: %w", err)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	grid, err := s.loadGrid(ctx)
+	if err != nil {
+		return fmt.Errorf("load grid: %w", err)
+	}
+	earliest := now.Add(-period)
+	for _, subscription := range due {
+		if subscription.LastSentAt != nil && subscription.LastSentAt.Before(earliest) {
+			earliest = *subscription.LastSentAt
+		}
+	}
+	changed, err := s.store.GetPixelsModifiedSince(ctx, earliest)
+	if err != nil {
+		return fmt.Errorf("load changed pixels: %w", err)
+	}
+
+	sent := 0
+	for _, subscription := range due {
+		since := now.Add(-period)
+		if subscription.LastSentAt != nil {
+			since = *subscription.LastSentAt
+		}
+		sendErr := s.sendEngagementReport(ctx, sender, grid, changed, subscription, since)
+		if errors.Is(sendErr, errNothingToReport) {
+			continue
+		}
+		if sendErr != nil {
+			log.Printf("engagement reports: user_id=%d: %v", subscription.UserID, sendErr)
+			// The claim marked the period as reported; put it back so the next run retries.
+			if err := s.store.ReleaseEngagementReport(ctx, subscription.UserID, subscription.LastSentAt); err != nil {
+				log.Printf("engagement reports: release user_id=%d: %v", subscription.UserID, err)
+			}
+			continue
+		}
+		sent++
+	}
+	log.Printf("engagement reports: sent %d of %d due reports", sent, len(due))
+	return nil
+}
+
+// errNothingToReport is returned by sendEngagementReport for owners without pixels, whose period
+// counts as reported without an email.
+var errNothingToReport = errors.New("no pixels to report")
+
+// sendEngagementReport builds and emails one owner's report for the period since the given time.
+func (s *Server) sendEngagementReport(ctx context.Context, sender email.NoticeSender, grid storage.PixelState, changed []storage.Pixel, subscription storage.EngagementReportSubscription, since time.Time) error {
+	report, err := s.buildEngagementReport(ctx, grid, changed, subscription.UserID, since)
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+	if report.Pixels == 0 {
+		return errNothingToReport
+	}
+	owner, err := s.store.GetUserByID(ctx, subscription.UserID)
+	if err != nil {
+		return fmt.Errorf("load user: %w", err)
+	}
+	if err := sender.SendNotice(ctx, owner.Email, s.engagementReportNotice(report, subscription.Token)); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	return nil
+}
+
+// buildEngagementReport sums the owner's clicks since the given time and counts the pixels
+// bought since then by others right next to the owner's current pixels.
+func (s *Server) buildEngagementReport(ctx context.Context, grid storage.PixelState, changed []storage.Pixel, ownerID int64, since time.Time) (engagementReport, error) {
+	var report engagementReport
+	owned := make(map[int]bool)
+	taken := 0
+	for _, pixel := range grid.Pixels {
+		if pixel.Status != "taken" {
+			continue
+		}
+		taken++
+		if pixel.OwnerID != nil && *pixel.OwnerID == ownerID {
+			owned[pixel.ID] = true
+		}
+	}
+	report.Pixels = len(owned)
+	if cells := grid.Width * grid.Height; cells > 0 {
+		report.FillPercent = float64(taken) * 100 / float64(cells)
+	}
+	if report.Pixels == 0 {
+		return report, nil
+	}
+
+	clicks, err := s.store.ListPixelClicksByOwner(ctx, ownerID, since)
+	if err != nil {
+		return engagementReport{}, err
+	}
+	for _, pixel := range clicks {
+		report.Clicks += pixel.Clicks
+	}
+
+	for _, pixel := range changed {
+		if pixel.Status != "taken" || pixel.UpdatedAt.Before(since) || pixel.OwnerID == nil || *pixel.OwnerID == ownerID {
+			continue
+		}
+		x, y := pixel.ID%grid.Width, pixel.ID/grid.Width
+		if (x > 0 && owned[pixel.ID-1]) || (x < grid.Width-1 && owned[pixel.ID+1]) ||
+			(y > 0 && owned[pixel.ID-grid.Width]) || (y < grid.Height-1 && owned[pixel.ID+grid.Width]) {
+			report.NeighboursNew++
+		}
+	}
+	return report, nil
+}
+
+func (s *Server) engagementReportNotice(report engagementReport, token string) email.Notice {
+	unsubscribe := strings.TrimRight(s.verificationBaseURL, "/") + "/api/reports/unsubscribe?token=" + url.QueryEscape(token)
+	return email.Notice{
+		Subject: "Podsumowanie Twoich pikseli",
+		Body: fmt.Sprintf(
+			"Oto podsumowanie ostatnich %d dni.\n\nTwoje piksele: %d\nKliknięcia w Twoje linki: %d\nZapełnienie siatki: %.1f%%\nNowe piksele kupione obok Twoich: %d\n\nNie chcesz otrzymywać tych wiadomości? Wypisz się: %s",
+			s.engagementReports.IntervalDays, report.Pixels, report.Clicks, report.FillPercent, report.NeighboursNew, unsubscribe,
+		),
+	}
+}
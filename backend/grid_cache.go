@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const gridCacheRefreshTimeout = time.Minute
+
+// gridRenderer loads the grid and returns a function writing the encoded response.
+// Load errors are reported before anything is written so the handler can still answer 500.
+type gridRenderer func(ctx context.Context) (func(io.Writer) error, error)
+
+type gridCacheEntry struct {
+	fill       sync.Mutex
+	body       []byte
+	etag       string
+	version    uint64
+	renderedAt time.Time
+	refreshing bool
+}
+
+// GridCache keeps rendered grid responses per variant. Entries are fresh while the grid
+// version they were rendered for is current and younger than ttl; afterwards they are still
+// served for up to swr while a single background refresh renders the new version.
+type GridCache struct {
+	ttl     time.Duration
+	swr     time.Duration
+	now     func() time.Time
+	version atomic.Uint64
+
+	mu      sync.Mutex
+	entries map[string]*gridCacheEntry
+}
+
+func NewGridCache(ttl, swr time.Duration) *GridCache {
+	return &GridCache{ttl: ttl, swr: swr, now: time.Now, entries: make(map[string]*gridCacheEntry)}
+}
+
+// Invalidate bumps the grid version after pixels changed.
+func (g *GridCache) Invalidate() {
+	if g != nil {
+		g.version.Add(1)
+	}
+}
+
+func (g *GridCache) entry(key string) *gridCacheEntry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	entry, ok := g.entries[key]
+	if !ok {
+		entry = &gridCacheEntry{}
+		g.entries[key] = entry
+	}
+	return entry
+}
+
+// state classifies an entry; callers hold g.mu.
+func (g *GridCache) state(entry *gridCacheEntry, now time.Time) string {
+	switch {
+	case entry.body == nil:
+		return "MISS"
+	case entry.version == g.version.Load() && now.Sub(entry.renderedAt) < g.ttl:
+		return "HIT"
+	case now.Sub(entry.renderedAt) < g.ttl+g.swr:
+		return "STALE"
+	default:
+		return "MISS"
+	}
+}
+
+func (g *GridCache) render(ctx context.Context, entry *gridCacheEntry, render gridRenderer) error {
+	version := g.version.Load()
+	write, err := render(ctx)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	hash := fnv.New64a()
+	hash.Write(buf.Bytes())
+
+	g.mu.Lock()
+	entry.body = buf.Bytes()
+	entry.etag = fmt.Sprintf(`"g%d-%x"`, version, hash.Sum64())
+	entry.version = version
+	entry.renderedAt = g.now()
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *GridCache) refresh(key string, entry *gridCacheEntry, render gridRenderer) {
+	defer func() {
+		g.mu.Lock()
+		entry.refreshing = false
+		g.mu.Unlock()
+	}()
+	entry.fill.Lock()
+	defer entry.fill.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), gridCacheRefreshTimeout)
+	defer cancel()
+	if err := g.render(ctx, entry, render); err != nil {
+		log.Printf("grid cache: refresh key=%s err=%v", key, err)
+	}
+}
+
+// lookup returns the body to serve for key, rendering it synchronously on a miss.
+func (g *GridCache) lookup(ctx context.Context, key string, render gridRenderer) ([]byte, string, string, error) {
+	entry := g.entry(key)
+
+	g.mu.Lock()
+	status := g.state(entry, g.now())
+	if status == "STALE" && !entry.refreshing {
+		entry.refreshing = true
+		go g.refresh(key, entry, render)
+	}
+	body, etag := entry.body, entry.etag
+	g.mu.Unlock()
+	if status != "MISS" {
+		return body, etag, status, nil
+	}
+
+	// Concurrent misses wait for the first renderer instead of loading the grid again.
+	entry.fill.Lock()
+	defer entry.fill.Unlock()
+	g.mu.Lock()
+	status = g.state(entry, g.now())
+	body, etag = entry.body, entry.etag
+	g.mu.Unlock()
+	if status != "MISS" {
+		return body, etag, status, nil
+	}
+	if err := g.render(ctx, entry, render); err != nil {
+		return nil, "", "", err
+	}
+	g.mu.Lock()
+	body, etag = entry.body, entry.etag
+	g.mu.Unlock()
+	return body, etag, "MISS", nil
+}
+
+// GridVersion is the version hash of the grid served by this process: a random boot id and a
+// counter bumped whenever pixels change. ETags built from it can be checked before rendering.
+type GridVersion struct {
+	boot    string
+	counter atomic.Uint64
+}
+
+func NewGridVersion() *GridVersion {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		// A fixed boot id only risks a stale 304 across restarts without pixel changes.
+		log.Printf("grid version: random boot id: %v", err)
+	}
+	return &GridVersion{boot: hex.EncodeToString(buf)}
+}
+
+// Bump records that pixels changed.
+func (v *GridVersion) Bump() {
+	if v != nil {
+		v.counter.Add(1)
+	}
+}
+
+// ETag returns the entity tag of the response variant key at the current version.
+func (v *GridVersion) ETag(key string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return fmt.Sprintf(`"v%s-%d-%x"`, v.boot, v.counter.Load(), hash.Sum32())
+}
+
+// serveGrid writes a grid response through the cache, or streams it directly when caching is off.
+// Uncached responses are tagged with the grid version, so clients holding the current ETag get a
+// 304 without the grid being loaded or encoded.
+func (s *Server) serveGrid(c *gin.Context, key, contentType string, render gridRenderer) {
+	ctx := c.Request.Context()
+	header := c.Writer.Header()
+	if s.gridCache == nil {
+		if s.gridVersion != nil {
+			// Taken before rendering, so a write racing the render only makes the tag older.
+			etag := s.gridVersion.ETag(key)
+			header.Set("Cache-Control", "no-cache")
+			header.Set("ETag", etag)
+			if etagMatches(c.Request.Header.Get("If-None-Match"), etag) {
+				c.Writer.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		write, err := render(ctx)
+		if err != nil {
+			log.Printf("get pixels: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+			return
+		}
+		header.Set("Content-Type", contentType)
+		c.Writer.WriteHeader(http.StatusOK)
+		if err := write(c.Writer); err != nil {
+			log.Printf("write pixels: %v", err)
+		}
+		return
+	}
+
+	body, etag, status, err := s.gridCache.lookup(ctx, key, render)
+	if err != nil {
+		log.Printf("get pixels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", int(s.gridCache.ttl.Seconds()), int(s.gridCache.swr.Seconds())))
+	header.Set("ETag", etag)
+	header.Set("X-Cache", status)
+	if etagMatches(c.Request.Header.Get("If-None-Match"), etag) {
+		c.Writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := c.Writer.Write(body); err != nil {
+		log.Printf("write pixels: %v", err)
+	}
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
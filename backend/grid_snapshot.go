@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	gridSnapshotSaveInterval = time.Minute
+	// gridSnapshotSyncInterval bounds how often reads look for pixels written outside this
+	// process; the process's own purchases are applied immediately through Apply.
+	gridSnapshotSyncInterval = 5 * time.Second
+)
+
+// gridSnapshotFile is the persisted form of a GridSnapshot.
+type gridSnapshotFile struct {
+	SyncedAt time.Time
+	State    storage.PixelState
+}
+
+// GridSnapshot keeps the whole grid in memory so grid renders do not scan the pixels table.
+// Purchases are written through with Apply; other writers are picked up by reading the pixels
+// modified since the previous sync at most every syncEvery. With a path, the state is saved to
+// a file so a restart only replays the writes made after the last save.
+type GridSnapshot struct {
+	store     storage.Store
+	path      string
+	now       func() time.Time
+	syncEvery time.Duration
+
+	mu       sync.Mutex
+	state    storage.PixelState
+	index    map[int]int
+	syncedAt time.Time
+	lastSync time.Time
+	dirty    bool
+}
+
+// LoadGridSnapshot restores the snapshot saved at path and catches it up with the store. An empty
+// path keeps the grid in memory only; a missing or unreadable file falls back to reading the
+// full grid.
+func LoadGridSnapshot(ctx context.Context, store storage.Store, path string) (*GridSnapshot, error) {
+	g := &GridSnapshot{store: store, path: path, now: time.Now, syncEvery: gridSnapshotSyncInterval}
+	saved, err := readGridSnapshot(path)
+	switch {
+	case err == nil:
+		g.reset(saved.State, saved.SyncedAt)
+		if err := g.sync(ctx); err != nil {
+			return nil, err
+		}
+		return g, nil
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		log.Printf("grid snapshot: ignoring %s: %v", path, err)
+	}
+
+	now := g.now()
+	state, err := store.GetAllPixels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g.reset(state, now.UTC().Add(-pixelDeltaOverlap))
+	g.lastSync = now
+	g.dirty = true
+	return g, nil
+}
+
+func readGridSnapshot(path string) (gridSnapshotFile, error) {
+	if path == "" {
+		return gridSnapshotFile{}, fs.ErrNotExist
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return gridSnapshotFile{}, err
+	}
+	defer file.Close()
+	var saved gridSnapshotFile
+	if err := gob.NewDecoder(file).Decode(&saved); err != nil {
+		return gridSnapshotFile{}, fmt.Errorf("decode: %w", err)
+	}
+	if saved.State.Width != storage.GridWidth || saved.State.Height != storage.GridHeight {
+		return gridSnapshotFile{}, fmt.Errorf("grid is %dx%d", saved.State.Width, saved.State.Height)
+	}
+	return saved, nil
+}
+
+func (g *GridSnapshot) reset(state storage.PixelState, syncedAt time.Time) {
+	g.state = state
+	g.syncedAt = syncedAt
+	g.index = make(map[int]int, len(state.Pixels))
+	for i, pixel := range state.Pixels {
+		g.index[pixel.ID] = i
+	}
+}
+
+// sync applies the pixels modified since the last sync; callers hold g.mu or own g.
+func (g *GridSnapshot) sync(ctx context.Context) error {
+	now := g.now()
+	syncedAt := now.UTC().Add(-pixelDeltaOverlap)
+	changed, err := g.store.GetPixelsModifiedSince(ctx, g.syncedAt)
+	if err != nil {
+		return err
+	}
+	g.merge(changed)
+	g.syncedAt = syncedAt
+	g.lastSync = now
+	return nil
+}
+
+// merge replaces or adds pixels; callers hold g.mu or own g.
+func (g *GridSnapshot) merge(pixels []storage.Pixel) {
+	added := false
+	for _, pixel := range pixels {
+		if i, ok := g.index[pixel.ID]; ok {
+			g.state.Pixels[i] = pixel
+			continue
+		}
+		g.state.Pixels = append(g.state.Pixels, pixel)
+		added = true
+	}
+	if added {
+		sort.Slice(g.state.Pixels, func(i, j int) bool { return g.state.Pixels[i].ID < g.state.Pixels[j].ID })
+		g.reset(g.state, g.syncedAt)
+	}
+	g.dirty = g.dirty || len(pixels) > 0
+}
+
+// Apply writes pixels this process just stored into the grid. A sync running concurrently holds
+// the lock for its whole query, so it can never overwrite them with older rows afterwards.
+func (g *GridSnapshot) Apply(pixels []storage.Pixel) {
+	if g == nil || len(pixels) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.merge(pixels)
+}
+
+// Stale makes the next State read the pixels modified since the last sync, for writers that only
+// know which pixels changed.
+func (g *GridSnapshot) Stale() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastSync = time.Time{}
+}
+
+// State returns a copy of the current grid that the caller may modify.
+func (g *GridSnapshot) State(ctx context.Context) (storage.PixelState, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.now().Sub(g.lastSync) >= g.syncEvery {
+		if err := g.sync(ctx); err != nil {
+			return storage.PixelState{}, err
+		}
+	}
+	state := g.state
+	state.Pixels = append([]storage.Pixel(nil), g.state.Pixels...)
+	return state, nil
+}
+
+// Save writes the snapshot to its file when it changed since the last save.
+func (g *GridSnapshot) Save() error {
+	if g.path == "" {
+		return nil
+	}
+	g.mu.Lock()
+	if !g.dirty {
+		g.mu.Unlock()
+		return nil
+	}
+	saved := gridSnapshotFile{SyncedAt: g.syncedAt, State: g.state}
+	saved.State.Pixels = append([]storage.Pixel(nil), g.state.Pixels...)
+	g.dirty = false
+	g.mu.Unlock()
+
+	err := writeGridSnapshot(g.path, saved)
+	if err != nil {
+		g.mu.Lock()
+		g.dirty = true
+		g.mu.Unlock()
+	}
+	return err
+}
+
+// writeGridSnapshot replaces path atomically so a crash mid-write keeps the previous snapshot.
+func writeGridSnapshot(path string, saved gridSnapshotFile) (err error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+	if err = gob.NewEncoder(file).Encode(saved); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// loadGrid returns the grid for rendering, from the snapshot when one is configured.
+func (s *Server) loadGrid(ctx context.Context) (storage.PixelState, error) {
+	if s.gridSnapshot != nil {
+		return s.gridSnapshot.State(ctx)
+	}
+	return s.store.GetAllPixels(ctx)
+}
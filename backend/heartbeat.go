@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+const heartbeatTimeout = 10 * time.Second
+
+type heartbeatReport struct {
+	Status        string  `json:"status"`
+	Error         string  `json:"error,omitempty"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
+	Goroutines    int     `json:"goroutines"`
+	HeapMB        float64 `json:"heap_mb"`
+	DBLatencyMS   float64 `json:"db_latency_ms"`
+}
+
+// heartbeat pings the monitoring URL with liveness stats. A failed database check is reported
+// to url+"/fail" so the monitor alerts even though the backend itself is still reachable.
+func (s *Server) heartbeat(client *http.Client, url string, started time.Time) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+		defer cancel()
+
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		report := heartbeatReport{
+			Status:        "ok",
+			UptimeSeconds: int64(time.Since(started).Seconds()),
+			Goroutines:    runtime.NumGoroutine(),
+			HeapMB:        float64(mem.HeapAlloc) / (1 << 20),
+		}
+		target := url
+		pingStart := time.Now()
+		if err := s.store.Ping(ctx); err != nil {
+			report.Status = "db_down"
+			report.Error = err.Error()
+			target = url + "/fail"
+		}
+		report.DBLatencyMS = float64(time.Since(pingStart).Microseconds()) / 1000
+
+		body, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("heartbeat: %w", err)
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("heartbeat: unexpected status %d", resp.StatusCode)
+		}
+		if report.Error != "" {
+			return fmt.Errorf("heartbeat: database check failed: %s", report.Error)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+// Package activationcode defines the shape of activation codes shared by the generator and validator.
+package activationcode
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	DefaultGroups      = 4
+	DefaultGroupLength = 4
+	DefaultAlphabet    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// Ambiguous lists characters that are easily confused when codes are printed or typed.
+	Ambiguous = "O0I1L"
+
+	// Separator joins the groups of a code.
+	Separator = '-'
+
+	maxGroups      = 8
+	maxGroupLength = 12
+)
+
+// Format describes activation codes as Groups blocks of GroupLength characters from Alphabet.
+// Generated codes skip the Ambiguous characters unless AllowAmbiguous is set; validation accepts
+// the whole alphabet so codes issued before the exclusion keep working.
+// The zero value is equivalent to Default().
+type Format struct {
+	Groups         int
+	GroupLength    int
+	Alphabet       string
+	AllowAmbiguous bool
+}
+
+// Default returns the xxxx-xxxx-xxxx-xxxx format over A-Z and 0-9.
+func Default() Format {
+	return Format{Groups: DefaultGroups, GroupLength: DefaultGroupLength, Alphabet: DefaultAlphabet}
+}
+
+func (f Format) withDefaults() Format {
+	if f.Groups <= 0 {
+		f.Groups = DefaultGroups
+	}
+	if f.GroupLength <= 0 {
+		f.GroupLength = DefaultGroupLength
+	}
+	f.Alphabet = strings.ToUpper(strings.TrimSpace(f.Alphabet))
+	if f.Alphabet == "" {
+		f.Alphabet = DefaultAlphabet
+	}
+	return f
+}
+
+// Validate reports whether the format can be used to generate and check codes.
+func (f Format) Validate() error {
+	f = f.withDefaults()
+	if f.Groups > maxGroups {
+		return fmt.Errorf("groups must be at most %d", maxGroups)
+	}
+	if f.GroupLength > maxGroupLength {
+		return fmt.Errorf("group length must be at most %d", maxGroupLength)
+	}
+	seen := make(map[rune]struct{}, len(f.Alphabet))
+	for _, r := range f.Alphabet {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return fmt.Errorf("alphabet may only contain A-Z and 0-9, got %q", r)
+		}
+		if _, dup := seen[r]; dup {
+			return fmt.Errorf("alphabet contains %q more than once", r)
+		}
+		seen[r] = struct{}{}
+	}
+	if len(f.GenerationAlphabet()) < 2 {
+		return errors.New("alphabet must contain at least two unambiguous characters")
+	}
+	return nil
+}
+
+// GenerationAlphabet returns the characters new codes are drawn from.
+func (f Format) GenerationAlphabet() string {
+	f = f.withDefaults()
+	if f.AllowAmbiguous {
+		return f.Alphabet
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(Ambiguous, r) {
+			return -1
+		}
+		return r
+	}, f.Alphabet)
+}
+
+// Length returns the number of characters in a code including separators.
+func (f Format) Length() int {
+	f = f.withDefaults()
+	return f.Groups*f.GroupLength + f.Groups - 1
+}
+
+// Pattern returns a human readable template such as xxxx-xxxx-xxxx-xxxx.
+func (f Format) Pattern() string {
+	f = f.withDefaults()
+	groups := make([]string, f.Groups)
+	for i := range groups {
+		groups[i] = strings.Repeat("x", f.GroupLength)
+	}
+	return strings.Join(groups, string(Separator))
+}
+
+// Normalize upper-cases and trims user input before validation or lookup.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Valid reports whether the normalised code matches the format.
+func (f Format) Valid(code string) bool {
+	f = f.withDefaults()
+	if len(code) != f.Length() {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if (i+1)%(f.GroupLength+1) == 0 {
+			if code[i] != Separator {
+				return false
+			}
+			continue
+		}
+		if strings.IndexByte(f.Alphabet, code[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Generate returns a new random code using crypto/rand.
+func (f Format) Generate() (string, error) {
+	f = f.withDefaults()
+	alphabet := f.GenerationAlphabet()
+	limit := big.NewInt(int64(len(alphabet)))
+
+	var b strings.Builder
+	b.Grow(f.Length())
+	for i := 0; i < f.Groups*f.GroupLength; i++ {
+		if i > 0 && i%f.GroupLength == 0 {
+			b.WriteByte(Separator)
+		}
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("generate activation code: %w", err)
+		}
+		b.WriteByte(alphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
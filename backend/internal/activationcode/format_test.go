@@ -0,0 +1,77 @@
+package activationcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultFormatValid(t *testing.T) {
+	f := Default()
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		{code: "ABCD-EFGH-IJKL-MNOP", valid: true},
+		{code: "0O1I-2345-6789-WXYZ", valid: true},
+		{code: "ABCD-EFGH-IJKL", valid: false},
+		{code: "ABCD-EFGH-IJKL-MNOPQ", valid: false},
+		{code: "ABCDEFGH-IJKL-MNOP-", valid: false},
+		{code: "abcd-efgh-ijkl-mnop", valid: false},
+		{code: "ABCD_EFGH_IJKL_MNOP", valid: false},
+	}
+	for _, tt := range tests {
+		if got := f.Valid(tt.code); got != tt.valid {
+			t.Fatalf("Valid(%q) = %v, want %v", tt.code, got, tt.valid)
+		}
+	}
+	if (Format{}).Pattern() != "xxxx-xxxx-xxxx-xxxx" {
+		t.Fatalf("unexpected zero-value pattern %q", (Format{}).Pattern())
+	}
+}
+
+func TestGenerateExcludesAmbiguousCharacters(t *testing.T) {
+	f := Format{Groups: 3, GroupLength: 5}
+	for i := 0; i < 200; i++ {
+		code, err := f.Generate()
+		if err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+		if !f.Valid(code) {
+			t.Fatalf("generated code %q does not validate", code)
+		}
+		if strings.ContainsAny(code, Ambiguous) {
+			t.Fatalf("generated code %q contains ambiguous characters", code)
+		}
+	}
+}
+
+func TestCustomAlphabet(t *testing.T) {
+	f := Format{Groups: 2, GroupLength: 6, Alphabet: "0123456789", AllowAmbiguous: true}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	code, err := f.Generate()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(code) != 13 || code[6] != Separator || strings.Trim(code, "0123456789-") != "" {
+		t.Fatalf("unexpected code %q", code)
+	}
+	if f.Valid("ABCDEF-123456") {
+		t.Fatalf("expected letters to be rejected by a numeric alphabet")
+	}
+}
+
+func TestValidateRejectsBadFormats(t *testing.T) {
+	for _, f := range []Format{
+		{Alphabet: "AB-C"},
+		{Alphabet: "AAB"},
+		{Alphabet: "O0I1L"},
+		{Groups: 20},
+		{GroupLength: 40},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", f)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package archive keeps canvas snapshots in a local directory or an S3-compatible bucket. Objects
+// are addressed by flat names; a Store never rewrites an object it did not write itself.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store saves, reads and lists archived objects. Get returns an error wrapping fs.ErrNotExist for
+// a missing object.
+type Store interface {
+	Put(ctx context.Context, name, contentType string, body []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the names of all objects in lexical order.
+	List(ctx context.Context) ([]string, error)
+}
+
+// validName rejects names that could leave the archive: paths, dot files and empty names.
+func validName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid archive name %q", name)
+	}
+	return nil
+}
+
+// Dir is a Store in a local directory.
+type Dir struct {
+	root string
+}
+
+// NewDir returns a Store in root, creating the directory when needed.
+func NewDir(root string) (*Dir, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, errors.New("archive directory must not be empty")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create archive directory: %w", err)
+	}
+	return &Dir{root: root}, nil
+}
+
+// Put writes the object through a temporary file, so readers never see a partial snapshot.
+func (d *Dir) Put(ctx context.Context, name, contentType string, body []byte) (err error) {
+	if err := validName(name); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(d.root, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+	if _, err = file.Write(body); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(d.root, name))
+}
+
+func (d *Dir) Get(ctx context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
+		return nil, fmt.Errorf("%w: %v", fs.ErrNotExist, err)
+	}
+	return os.ReadFile(filepath.Join(d.root, name))
+}
+
+func (d *Dir) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.root)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
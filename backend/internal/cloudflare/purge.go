@@ -0,0 +1,184 @@
+// Package cloudflare purges CDN-cached URLs through the Cloudflare API.
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAPIBase is the Cloudflare v4 API endpoint.
+	DefaultAPIBase = "https://api.cloudflare.com/client/v4"
+	// MaxFilesPerRequest is the number of URLs Cloudflare accepts in one purge call.
+	MaxFilesPerRequest = 30
+
+	defaultInterval = 5 * time.Second
+)
+
+// Config describes the zone to purge.
+type Config struct {
+	ZoneID   string
+	APIToken string
+	// Interval is the minimum time between purge rounds; URLs queued meanwhile are batched.
+	Interval time.Duration
+	// APIBase overrides DefaultAPIBase, mainly for tests.
+	APIBase string
+	// Client sends the requests; nil selects a plain client with a 15 second timeout.
+	Client *http.Client
+}
+
+// Purger batches URLs queued by Enqueue and purges them at most once per interval.
+type Purger struct {
+	zoneID   string
+	token    string
+	apiBase  string
+	interval time.Duration
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	wake    chan struct{}
+}
+
+func NewPurger(cfg Config) (*Purger, error) {
+	zone := strings.TrimSpace(cfg.ZoneID)
+	token := strings.TrimSpace(cfg.APIToken)
+	if zone == "" || token == "" {
+		return nil, errors.New("zone id and api token are required")
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	apiBase := strings.TrimRight(strings.TrimSpace(cfg.APIBase), "/")
+	if apiBase == "" {
+		apiBase = DefaultAPIBase
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Purger{
+		zoneID:   zone,
+		token:    token,
+		apiBase:  apiBase,
+		interval: interval,
+		client:   client,
+		pending:  make(map[string]struct{}),
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// Enqueue schedules urls for the next purge round. It never blocks; a nil Purger ignores calls.
+func (p *Purger) Enqueue(urls ...string) {
+	if p == nil || len(urls) == 0 {
+		return
+	}
+	p.mu.Lock()
+	for _, url := range urls {
+		p.pending[url] = struct{}{}
+	}
+	p.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run purges queued URLs until ctx is cancelled. Rounds are at least one interval apart
+// and URLs of a failed round are queued again.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		}
+		if err := p.Flush(ctx); err != nil {
+			log.Printf("cloudflare purge: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush purges everything queued so far in batches of MaxFilesPerRequest.
+func (p *Purger) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	urls := make([]string, 0, len(p.pending))
+	for url := range p.pending {
+		urls = append(urls, url)
+	}
+	p.pending = make(map[string]struct{})
+	p.mu.Unlock()
+	sort.Strings(urls)
+
+	for start := 0; start < len(urls); start += MaxFilesPerRequest {
+		end := start + MaxFilesPerRequest
+		if end > len(urls) {
+			end = len(urls)
+		}
+		if err := p.purge(ctx, urls[start:end]); err != nil {
+			p.Enqueue(urls[start:]...)
+			return err
+		}
+	}
+	if len(urls) > 0 {
+		log.Printf("cloudflare purge: zone=%s urls=%d", p.zoneID, len(urls))
+	}
+	return nil
+}
+
+type purgeResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (p *Purger) purge(ctx context.Context, files []string) error {
+	body, err := json.Marshal(map[string][]string{"files": files})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/zones/%s/purge_cache", p.apiBase, p.zoneID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("purge request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result purgeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("purge response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || !result.Success {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("purge failed (status %d): %d %s", resp.StatusCode, result.Errors[0].Code, result.Errors[0].Message)
+		}
+		return fmt.Errorf("purge failed (status %d)", resp.StatusCode)
+	}
+	return nil
+}
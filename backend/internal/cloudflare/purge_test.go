@@ -0,0 +1,131 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests [][]string
+	fail     bool
+}
+
+func (f *fakeAPI) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zones/zone-1/purge_cache" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Files []string `json:"files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.requests = append(f.requests, body.Files)
+		if f.fail {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"errors":[]}`))
+	})
+}
+
+func newTestPurger(t *testing.T, api *fakeAPI) *Purger {
+	t.Helper()
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+	purger, err := NewPurger(Config{ZoneID: "zone-1", APIToken: "secret", APIBase: srv.URL, Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewPurger() error = %v", err)
+	}
+	return purger
+}
+
+func TestFlushBatchesAndDeduplicates(t *testing.T) {
+	api := &fakeAPI{}
+	purger := newTestPurger(t, api)
+
+	for i := 0; i < 45; i++ {
+		purger.Enqueue(fmt.Sprintf("https://kuppixel.pl/embed/%02d", i), "https://kuppixel.pl/api/pixels")
+	}
+	if err := purger.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if len(api.requests) != 2 || len(api.requests[0]) != MaxFilesPerRequest || len(api.requests[1]) != 16 {
+		t.Fatalf("unexpected batches: %d requests", len(api.requests))
+	}
+	if err := purger.Flush(context.Background()); err != nil || len(api.requests) != 2 {
+		t.Fatalf("empty flush should not call the API, got %d requests err=%v", len(api.requests), err)
+	}
+}
+
+func TestFailedPurgeIsRetried(t *testing.T) {
+	api := &fakeAPI{fail: true}
+	purger := newTestPurger(t, api)
+
+	purger.Enqueue("https://kuppixel.pl/api/pixels")
+	if err := purger.Flush(context.Background()); err == nil {
+		t.Fatal("expected purge error")
+	}
+
+	api.fail = false
+	if err := purger.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(api.requests) != 2 || api.requests[1][0] != "https://kuppixel.pl/api/pixels" {
+		t.Fatalf("expected queued URL to be retried, got %v", api.requests)
+	}
+}
+
+func TestRunPurgesAtMostOncePerInterval(t *testing.T) {
+	api := &fakeAPI{}
+	purger := newTestPurger(t, api)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go purger.Run(ctx)
+
+	purger.Enqueue("https://kuppixel.pl/a")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		api.mu.Lock()
+		n := len(api.requests)
+		api.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first purge did not happen")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	purger.Enqueue("https://kuppixel.pl/b")
+	time.Sleep(50 * time.Millisecond)
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.requests) != 1 {
+		t.Fatalf("second purge must wait for the interval, got %d requests", len(api.requests))
+	}
+}
+
+func TestNewPurgerRequiresCredentials(t *testing.T) {
+	if _, err := NewPurger(Config{ZoneID: "zone"}); err == nil {
+		t.Fatal("expected missing token to be rejected")
+	}
+}
+
+func TestNilPurgerIgnoresEnqueue(t *testing.T) {
+	var purger *Purger
+	purger.Enqueue("https://kuppixel.pl/api/pixels")
+}
@@ -6,7 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/example/kup-piksel/internal/email"
@@ -14,39 +18,886 @@ import (
 
 // Config represents backend configuration options loaded from disk.
 type Config struct {
-	SMTP                     *email.SMTPConfig `json:"smtp"`
-	DisableVerificationEmail bool              `json:"disableVerificationEmail"`
-	PixelCostPoints          int               `json:"pixelCostPoints"`
-	Database                 *DatabaseConfig   `json:"database"`
-	Email                    EmailConfig       `json:"email"`
-	PasswordReset            PasswordReset     `json:"passwordReset"`
-	Verification             Verification      `json:"verification"`
-	TurnstileSecretKey       string            `json:"turnstileSecretKey"`
+	SMTP                     *email.SMTPConfig    `json:"smtp"`
+	Mailgun                  *email.MailgunConfig `json:"mailgun"`
+	DisableVerificationEmail bool                 `json:"disableVerificationEmail"`
+	PixelCostPoints          int                  `json:"pixelCostPoints"`
+	Database                 *DatabaseConfig      `json:"database"`
+	Email                    EmailConfig          `json:"email"`
+	PasswordReset            PasswordReset        `json:"passwordReset"`
+	Verification             Verification         `json:"verification"`
+	TurnstileSecretKey       string               `json:"turnstileSecretKey"`
+	LinkSigningSecret        string               `json:"linkSigningSecret"`
+	AdminEmails              []string             `json:"adminEmails"`
+	ActivationCodes          ActivationCodes      `json:"activationCodes"`
+	Currency                 Currency             `json:"currency"`
+	Payments                 Payments             `json:"payments"`
+	Diagnostics              Diagnostics          `json:"diagnostics"`
+	GridCache                GridCache            `json:"gridCache"`
+	Cloudflare               Cloudflare           `json:"cloudflare"`
+	Heartbeat                Heartbeat            `json:"heartbeat"`
+	OutboundHTTP             OutboundHTTP         `json:"outboundHttp"`
+	APIUsage                 APIUsage             `json:"apiUsage"`
+	AgeGate                  AgeGate              `json:"ageGate"`
+	CountryRestrictions      CountryRestrictions  `json:"countryRestrictions"`
+	EmailNormalization       EmailNormalization   `json:"emailNormalization"`
+	RegistrationLimits       RegistrationLimits   `json:"registrationLimits"`
+	LoginLimits              LoginLimits          `json:"loginLimits"`
+	IPBuckets                IPBuckets            `json:"ipBuckets"`
+	Purchases                Purchases            `json:"purchases"`
+	Tiles                    Tiles                `json:"tiles"`
+	Consistency              Consistency          `json:"consistency"`
+	PixelContent             PixelContent         `json:"pixelContent"`
+	OwnerWebhooks            OwnerWebhooks        `json:"ownerWebhooks"`
+	Push                     Push                 `json:"push"`
+	Rentals                  Rentals              `json:"rentals"`
+	PriceQuotes              PriceQuotes          `json:"priceQuotes"`
+	PixelHolds               PixelHolds           `json:"pixelHolds"`
+	PixelReleases            PixelReleases        `json:"pixelReleases"`
+	PixelDrafts              PixelDrafts          `json:"pixelDrafts"`
+	Moderation               Moderation           `json:"moderation"`
+	Sessions                 Sessions             `json:"sessions"`
+	LinkPreviews             LinkPreviews         `json:"linkPreviews"`
+	LinkChecks               LinkChecks           `json:"linkChecks"`
+	ContentModeration        ContentModeration    `json:"contentModeration"`
+	EngagementReports        EngagementReports    `json:"engagementReports"`
+	BusinessMetrics          BusinessMetrics      `json:"businessMetrics"`
+	Metrics                  Metrics              `json:"metrics"`
+	ElasticLogs              ElasticLogs          `json:"elasticLogs"`
+	CanvasArchive            CanvasArchive        `json:"canvasArchive"`
+	StartupHooks             StartupHooks         `json:"startupHooks"`
+	Invites                  Invites              `json:"invites"`
+	Leaderboard              Leaderboard          `json:"leaderboard"`
+	Tenants                  []Tenant             `json:"tenants"`
+	Boards                   []Board              `json:"boards"`
+	CustomDomains            CustomDomains        `json:"customDomains"`
+	// ReadOnly blocks purchases and account changes while keeping reads and login available.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// SupportedPaymentCurrencies lists the currencies bundles can be priced in.
+var SupportedPaymentCurrencies = []string{"PLN", "EUR", "USD"}
+
+// GridCache configures the in-process cache of rendered grid responses.
+type GridCache struct {
+	// TTLSeconds is how long a response stays fresh; a negative value disables the cache.
+	TTLSeconds int `json:"ttlSeconds"`
+	// StaleWhileRevalidateSeconds is how long an expired response is still served while it is
+	// re-rendered; a negative value always renders synchronously.
+	StaleWhileRevalidateSeconds int `json:"staleWhileRevalidateSeconds"`
+	// SnapshotPath keeps the grid in memory and saves it to this file, so startup reads only the
+	// pixels changed since the last save instead of the whole table. Empty disables the snapshot.
+	SnapshotPath string `json:"snapshotPath"`
+}
+
+// Cloudflare configures purging of CDN-cached grid URLs after pixels change.
+// Purging is enabled when ZoneID and APIToken are set.
+type Cloudflare struct {
+	ZoneID   string `json:"zoneId"`
+	APIToken string `json:"apiToken"`
+	// SiteURL is the public origin the purged paths are appended to.
+	SiteURL string `json:"siteUrl"`
+	// PurgePaths are purged after any pixel change.
+	PurgePaths []string `json:"purgePaths"`
+	// PixelPurgePaths are purged for every changed pixel with "{id}" replaced by the pixel id.
+	PixelPurgePaths      []string `json:"pixelPurgePaths"`
+	PurgeIntervalSeconds int      `json:"purgeIntervalSeconds"`
+}
+
+// Enabled reports whether Cloudflare credentials are configured.
+func (c Cloudflare) Enabled() bool {
+	return c.ZoneID != "" && c.APIToken != ""
+}
+
+func (c *Cloudflare) normalize() error {
+	c.ZoneID = strings.TrimSpace(c.ZoneID)
+	c.APIToken = strings.TrimSpace(c.APIToken)
+	c.SiteURL = strings.TrimRight(strings.TrimSpace(c.SiteURL), "/")
+	if !c.Enabled() {
+		return nil
+	}
+	if c.SiteURL == "" {
+		return errors.New("siteUrl is required when purging is enabled")
+	}
+	if c.PurgePaths == nil {
+		c.PurgePaths = []string{"/api/pixels", "/api/pixels/colors", "/api/pixels/colors?encoding=rle"}
+	}
+	for _, paths := range [][]string{c.PurgePaths, c.PixelPurgePaths} {
+		for i, path := range paths {
+			path = strings.TrimSpace(path)
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("purge path %q must start with /", path)
+			}
+			paths[i] = path
+		}
+	}
+	if c.PurgeIntervalSeconds <= 0 {
+		c.PurgeIntervalSeconds = 5
+	}
+	return nil
+}
+
+// Heartbeat configures periodic liveness reports to an external monitor (healthchecks.io style).
+type Heartbeat struct {
+	// URL receives a POST with current stats while the backend and database are healthy;
+	// failures are reported to URL + "/fail".
+	URL             string `json:"url"`
+	IntervalSeconds int    `json:"intervalSeconds"`
+}
+
+// OutboundHTTP tunes the clients calling external services such as Turnstile and the heartbeat
+// monitor. MaxRetries 0 selects the default and a negative value disables retries.
+type OutboundHTTP struct {
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// MaxRetries is how often an idempotent call is repeated after a network error or a 502/503/504.
+	MaxRetries   int `json:"maxRetries"`
+	RetryDelayMs int `json:"retryDelayMs"`
+	// FailureThreshold consecutive failures of a destination stop calls to it for OpenSeconds.
+	FailureThreshold int `json:"failureThreshold"`
+	OpenSeconds      int `json:"openSeconds"`
+}
+
+// OwnerWebhooks lets users register a URL that is notified about purchases, clicks and
+// moderation of their pixels.
+type OwnerWebhooks struct {
+	Enabled bool `json:"enabled"`
+	// MaxAttempts is how often a delivery is tried before it is dropped.
+	MaxAttempts    int `json:"maxAttempts"`
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// AllowPrivateTargets permits URLs that resolve to loopback or private addresses.
+	AllowPrivateTargets bool `json:"allowPrivateTargets"`
+}
+
+// Push configures mobile push notifications through Firebase Cloud Messaging.
+type Push struct {
+	// FCMServiceAccountFile is the path of the Firebase service-account JSON key; empty disables push.
+	FCMServiceAccountFile string `json:"fcmServiceAccountFile"`
+}
+
+// Rentals lets users rent pixels for a number of days instead of buying them for good.
+type Rentals struct {
+	Enabled bool `json:"enabled"`
+	// PointsPerDay is the price of renting one pixel for one day.
+	PointsPerDay int64 `json:"pointsPerDay"`
+	MaxDays      int   `json:"maxDays"`
+	// WarnBeforeHours is how long before the rental ends its owner is notified.
+	WarnBeforeHours int `json:"warnBeforeHours"`
+}
+
+// PriceQuotes lets buyers lock the current pixel prices before paying, so a price change in
+// between does not alter what they were shown.
+type PriceQuotes struct {
+	// TTLMinutes is how long a quote is honoured.
+	TTLMinutes int `json:"ttlMinutes"`
+	// Secret signs quotes. Every instance serving purchases needs the same secret for quotes to
+	// survive restarts and blue/green switches; without one each instance signs with a random key.
+	Secret string `json:"secret"`
+}
+
+// PixelHolds lets users reserve free pixels for a short time while they finish a purchase.
+type PixelHolds struct {
+	TTLMinutes int `json:"ttlMinutes"`
+	// MaxPixels caps how many pixels one user may hold at a time.
+	MaxPixels int `json:"maxPixels"`
+}
+
+// PixelDrafts limits the pixel designs users save under /api/drafts without buying them.
+type PixelDrafts struct {
+	MaxPerUser int `json:"maxPerUser"`
+	// MaxPixels caps the pixels of one draft.
+	MaxPixels int `json:"maxPixels"`
+}
+
+// Moderation holds pixels bought or given a new link out of the public grid until an admin
+// approves them under /api/admin/moderation.
+type Moderation struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Sessions configures login sessions. Their cookie lives for a week regardless.
+type Sessions struct {
+	// IdleTimeoutMinutes ends a session unused for that long; a negative value disables it.
+	IdleTimeoutMinutes int `json:"idleTimeoutMinutes"`
+}
+
+// PixelReleases configures owners giving their pixels back.
+type PixelReleases struct {
+	// RefundFraction is the share of the points paid for a pixel refunded when it is released,
+	// from 0 (no refund) to 1.
+	RefundFraction float64 `json:"refundFraction"`
+}
+
+// Tenant is a white-label billboard served by the same process under its own domains. Its config
+// file has the same format as the main one and gives the tenant its own database, and with it
+// separate users, pixels and stats, as well as its own prices, mail and admin settings.
+type Tenant struct {
+	Name string `json:"name"`
+	// Hosts are the domains, without port, whose requests go to the tenant.
+	Hosts []string `json:"hosts"`
+	// ConfigPath is the tenant's config file; a relative path is resolved against the directory
+	// of the main config file.
+	ConfigPath string `json:"configPath"`
+	// BaseURL is the tenant's public origin used in emailed links instead of
+	// VERIFICATION_LINK_BASE_URL.
+	BaseURL string `json:"baseUrl"`
+}
+
+func normalizeTenants(tenants []Tenant, dir string) error {
+	names := make(map[string]bool, len(tenants))
+	hosts := make(map[string]string)
+	for i := range tenants {
+		tenant := &tenants[i]
+		tenant.Name = strings.TrimSpace(tenant.Name)
+		tenant.ConfigPath = strings.TrimSpace(tenant.ConfigPath)
+		tenant.BaseURL = strings.TrimRight(strings.TrimSpace(tenant.BaseURL), "/")
+		switch {
+		case tenant.Name == "":
+			return errors.New("name is required")
+		case !boardIDPattern.MatchString(tenant.Name):
+			return fmt.Errorf("tenant name %q must be lowercase letters, digits and dashes", tenant.Name)
+		case names[tenant.Name]:
+			return fmt.Errorf("duplicate tenant %q", tenant.Name)
+		case tenant.ConfigPath == "":
+			return fmt.Errorf("tenant %q: configPath is required", tenant.Name)
+		case tenant.BaseURL == "":
+			return fmt.Errorf("tenant %q: baseUrl is required", tenant.Name)
+		case len(tenant.Hosts) == 0:
+			return fmt.Errorf("tenant %q: at least one host is required", tenant.Name)
+		}
+		names[tenant.Name] = true
+		if !filepath.IsAbs(tenant.ConfigPath) {
+			tenant.ConfigPath = filepath.Join(dir, tenant.ConfigPath)
+		}
+		for j, host := range tenant.Hosts {
+			host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+			if host == "" || strings.ContainsAny(host, ":/") {
+				return fmt.Errorf("tenant %q: invalid host %q", tenant.Name, tenant.Hosts[j])
+			}
+			if other, taken := hosts[host]; taken {
+				return fmt.Errorf("host %q is used by tenants %q and %q", host, other, tenant.Name)
+			}
+			hosts[host] = tenant.Name
+			tenant.Hosts[j] = host
+		}
+	}
+	return nil
+}
+
+// Board is a further pixel wall of this deployment, e.g. for an event or a community, served
+// under /api/boards/{id}/pixels. Its pixels live in the same database as the main grid, told apart
+// by their board_id, so accounts and points are shared while every board charges its own price.
+type Board struct {
+	// ID names the board in its URLs and in the board_id column of its pixels.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// PixelCostPoints is the price of a pixel of the board; 0 charges the main pixelCostPoints.
+	PixelCostPoints int `json:"pixelCostPoints"`
+}
+
+// boardIDPattern keeps board ids and tenant names, which also end up in URLs such as
+// /metrics/{tenant}, usable as a single URL path segment.
+var boardIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func normalizeBoards(boards []Board, pixelCost int) error {
+	ids := make(map[string]bool, len(boards))
+	for i := range boards {
+		board := &boards[i]
+		board.ID = strings.TrimSpace(board.ID)
+		board.Name = strings.TrimSpace(board.Name)
+		switch {
+		case !boardIDPattern.MatchString(board.ID):
+			return fmt.Errorf("id %q must be lowercase letters, digits and dashes", board.ID)
+		case ids[board.ID]:
+			return fmt.Errorf("duplicate board %q", board.ID)
+		case board.PixelCostPoints < 0:
+			return fmt.Errorf("board %q: pixelCostPoints must not be negative", board.ID)
+		}
+		ids[board.ID] = true
+		if board.Name == "" {
+			board.Name = board.ID
+		}
+		if board.PixelCostPoints == 0 {
+			board.PixelCostPoints = pixelCost
+		}
+	}
+	return nil
+}
+
+// CustomDomains lets owners point their own domains at the service to redirect visitors to one of
+// their pixels, and admins add domains for the board itself. A domain is routed once the DNS TXT
+// challenge shown on registration is found.
+type CustomDomains struct {
+	Enabled    bool `json:"enabled"`
+	MaxPerUser int  `json:"maxPerUser"`
+	// CheckIntervalMinutes is how often unverified domains are looked up.
+	CheckIntervalMinutes int `json:"checkIntervalMinutes"`
+	// PendingTTLHours is how long a domain may stay unverified before it is removed.
+	PendingTTLHours int `json:"pendingTtlHours"`
+}
+
+// LinkPreviews fetches the title and description of the sites pixels link to for pixel pages
+// and hover cards.
+type LinkPreviews struct {
+	Enabled         bool `json:"enabled"`
+	CacheTTLMinutes int  `json:"cacheTTLMinutes"`
+	TimeoutSeconds  int  `json:"timeoutSeconds"`
+	// MaxBytes caps how much of a page is read looking for its title and description.
+	MaxBytes int64 `json:"maxBytes"`
+	// DomainFetchesPerHour caps the fetches per site; cached previews do not count.
+	DomainFetchesPerHour int `json:"domainFetchesPerHour"`
+	// AllowPrivateTargets permits links that resolve to loopback or private addresses.
+	AllowPrivateTargets bool `json:"allowPrivateTargets"`
+}
+
+// LinkChecks sends a request to the link of every pixel being bought before the purchase is
+// charged, rejecting links whose site cannot be reached or answers 404, 410 or a server error.
+type LinkChecks struct {
+	Enabled        bool `json:"enabled"`
+	TimeoutSeconds int  `json:"timeoutSeconds"`
+	// AllowPrivateTargets permits links that resolve to loopback or private addresses.
+	AllowPrivateTargets bool `json:"allowPrivateTargets"`
+}
+
+// ContentModeration passes the link and color of every pixel being bought to a moderation
+// provider before the purchase is charged: "none" accepts everything and "safeBrowsing" refuses
+// links listed by Google Safe Browsing.
+type ContentModeration struct {
+	Provider           string `json:"provider"`
+	SafeBrowsingAPIKey string `json:"safeBrowsingApiKey"`
+	// FailOpen accepts pixels when the provider cannot be reached instead of refusing them.
+	FailOpen bool `json:"failOpen"`
+}
+
+// CanvasArchive stores a snapshot of the grid every IntervalMinutes: the pixels as gzip-compressed
+// JSON for disaster recovery and, with PNG, the rendered canvas. Snapshots go to the S3 bucket
+// when S3.Bucket is set and to Dir otherwise.
+type CanvasArchive struct {
+	Enabled         bool            `json:"enabled"`
+	IntervalMinutes int             `json:"intervalMinutes"`
+	Dir             string          `json:"dir"`
+	PNG             bool            `json:"png"`
+	S3              CanvasArchiveS3 `json:"s3"`
+}
+
+// CanvasArchiveS3 addresses a bucket of Amazon S3 or a compatible service; Endpoint defaults to
+// the AWS endpoint of Region.
+type CanvasArchiveS3 struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"`
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+// Invites makes registration invite-only while Required is set: signing up takes an unused code
+// minted by an admin or shared by a verified user.
+type Invites struct {
+	Required bool `json:"required"`
+	// PerUser is how many codes each verified user may create; a negative value gives them none.
+	PerUser int `json:"perUser"`
+}
+
+// Leaderboard configures GET /api/leaderboard. Size is how many owners it lists, at most
+// MaxLeaderboardSize; a negative value disables it. Rankings are cached for CacheSeconds, and a
+// negative value queries the database on every request.
+type Leaderboard struct {
+	Size         int `json:"size"`
+	CacheSeconds int `json:"cacheSeconds"`
+}
+
+// MaxLeaderboardSize bounds Leaderboard.Size.
+const MaxLeaderboardSize = 100
+
+// StartupHooks are run once the schema is ready, before the server starts. Seed fills a board
+// that has no taken pixels yet: "demo" with three sample pixels, "file" with the pixels of
+// SeedFile (a grid snapshot as served by GET /api/pixels, gzip-compressed when it ends in .gz)
+// and "none" leaves it alone.
+type StartupHooks struct {
+	Seed string `json:"seed"`
+	// SeedFile is resolved relative to the directory of the config file.
+	SeedFile string `json:"seedFile"`
+}
+
+// EngagementReports emails owners who opted in a periodic report on their pixels: clicks, grid fill
+// and pixels bought next to theirs.
+type EngagementReports struct {
+	Enabled bool `json:"enabled"`
+	// IntervalDays between two reports to the same owner.
+	IntervalDays int `json:"intervalDays"`
+}
+
+// BusinessMetrics refreshes the totals of pixels, users and points exported at /metrics.
+type BusinessMetrics struct {
+	// IntervalSeconds between refreshes; a negative value disables the gauges.
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+// Metrics configures the listener serving /metrics. It is kept off the public port because the
+// gauges expose sales and user counts.
+type Metrics struct {
+	// ListenAddr defaults to 127.0.0.1:9100; use 0.0.0.0:9100 only when a scraper on another
+	// host needs it and the port is not published.
+	ListenAddr string `json:"listenAddr"`
+}
+
+// ElasticLogs ships the log to Elasticsearch besides stderr. Lines wait in a buffer of BufferSize
+// and are sent with the bulk API in batches of BatchSize, every FlushIntervalSeconds or as soon as
+// a batch is full, by at most MaxConcurrentFlushes requests at a time. A full buffer drops the
+// oldest lines. An empty URL disables shipping.
+type ElasticLogs struct {
+	URL    string `json:"url"`
+	Index  string `json:"index"`
+	APIKey string `json:"apiKey"`
+
+	BufferSize           int `json:"bufferSize"`
+	BatchSize            int `json:"batchSize"`
+	FlushIntervalSeconds int `json:"flushIntervalSeconds"`
+	MaxConcurrentFlushes int `json:"maxConcurrentFlushes"`
+}
+
+func (e *ElasticLogs) normalize() error {
+	e.URL = strings.TrimRight(strings.TrimSpace(e.URL), "/")
+	e.Index = strings.TrimSpace(e.Index)
+	e.APIKey = strings.TrimSpace(e.APIKey)
+	if e.BufferSize < 0 || e.BatchSize < 0 || e.FlushIntervalSeconds < 0 || e.MaxConcurrentFlushes < 0 {
+		return errors.New("bufferSize, batchSize, flushIntervalSeconds and maxConcurrentFlushes must not be negative")
+	}
+	defaults := Default().ElasticLogs
+	if e.Index == "" {
+		e.Index = defaults.Index
+	}
+	if e.BufferSize == 0 {
+		e.BufferSize = defaults.BufferSize
+	}
+	if e.BatchSize == 0 {
+		e.BatchSize = defaults.BatchSize
+	}
+	if e.FlushIntervalSeconds == 0 {
+		e.FlushIntervalSeconds = defaults.FlushIntervalSeconds
+	}
+	if e.MaxConcurrentFlushes == 0 {
+		e.MaxConcurrentFlushes = defaults.MaxConcurrentFlushes
+	}
+	if e.BatchSize > e.BufferSize {
+		return fmt.Errorf("batchSize %d must not exceed bufferSize %d", e.BatchSize, e.BufferSize)
+	}
+	if e.URL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(e.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url %q must be an http or https address", e.URL)
+	}
+	return nil
+}
+
+func (m *Metrics) normalize() error {
+	m.ListenAddr = strings.TrimSpace(m.ListenAddr)
+	if m.ListenAddr == "" {
+		m.ListenAddr = Default().Metrics.ListenAddr
+	}
+	if _, _, err := net.SplitHostPort(m.ListenAddr); err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", m.ListenAddr, err)
+	}
+	return nil
+}
+
+// Purchases tunes the pixel purchase pipeline for large selections.
+type Purchases struct {
+	// MaxRequestBytes caps the JSON body of POST /api/pixels.
+	MaxRequestBytes int64 `json:"maxRequestBytes"`
+	// ChunkSize is how many pixels are written per database transaction.
+	ChunkSize int `json:"chunkSize"`
+	// AsyncThreshold is the selection size from which purchases run as background jobs;
+	// a negative value keeps every purchase synchronous.
+	AsyncThreshold int `json:"asyncThreshold"`
+	// MaxPixelsPerUser caps how many pixels one account may own; 0 means no limit. Admins can
+	// override it per account.
+	MaxPixelsPerUser int `json:"maxPixelsPerUser"`
+}
+
+// Tiles configures GET /api/pixels/tile/:x/:y.
+type Tiles struct {
+	// Size is the edge length of a square tile in pixels.
+	Size int `json:"size"`
+}
+
+// PixelContent restricts what owners may put on their pixels.
+type PixelContent struct {
+	// BlockedTerms are rejected, ignoring case, anywhere in a pixel's link, title or description.
+	// A term written as /expression/ is a regular expression instead of a phrase.
+	BlockedTerms []string `json:"blockedTerms"`
+	// AllowedDomains, when not empty, are the only domains, with their subdomains, pixels may
+	// link to.
+	AllowedDomains []string `json:"allowedDomains"`
+}
+
+// BlockedTermPattern returns the regular expression of a blocked term written as /expression/.
+func BlockedTermPattern(term string) (string, bool) {
+	term = strings.TrimSpace(term)
+	if len(term) < 3 || !strings.HasPrefix(term, "/") || !strings.HasSuffix(term, "/") {
+		return "", false
+	}
+	return term[1 : len(term)-1], true
+}
+
+// normalizeAllowedDomains lower-cases domains and drops blank entries and trailing dots; a
+// leading "*." is accepted since subdomains are always allowed.
+func normalizeAllowedDomains(domains []string) ([]string, error) {
+	var normalized []string
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), "."), "*.")
+		if domain == "" {
+			continue
+		}
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "/:@ *") {
+			return nil, fmt.Errorf("allowed domain %q must be a host name such as example.com", domain)
+		}
+		normalized = append(normalized, domain)
+	}
+	return normalized, nil
+}
+
+// ValidateBlockedTerms reports the first blocked term that is not a valid regular expression.
+func ValidateBlockedTerms(terms []string) error {
+	for _, term := range terms {
+		if pattern, ok := BlockedTermPattern(term); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("blocked term %s: %w", term, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Consistency configures the periodic check for orphaned and inconsistent data.
+type Consistency struct {
+	// IntervalMinutes between checks; a negative value disables the job.
+	IntervalMinutes int `json:"intervalMinutes"`
+	// Repair fixes the anomalies found by the job; otherwise they are only logged and reported.
+	Repair bool `json:"repair"`
+}
+
+// AgeGate configures the minimum age users must attest to at registration and before purchases.
+type AgeGate struct {
+	// MinimumAge in years; 0 disables the gate.
+	MinimumAge int `json:"minimumAge"`
+}
+
+// EmailNormalization selects how addresses are canonicalized at registration, login and lookup.
+// Lowercasing and punycode conversion of internationalized domains always apply.
+type EmailNormalization struct {
+	// ProviderRules makes Gmail addresses ignore dots and +tags and maps googlemail.com to gmail.com.
+	ProviderRules bool `json:"providerRules"`
+	// StripPlusAliases drops "+tag" from the local part for every domain.
+	StripPlusAliases bool `json:"stripPlusAliases"`
+}
+
+// RegistrationLimits caps how many accounts a single IP address or device cookie may create per day.
+// Zero selects the default and a negative value disables the respective check.
+type RegistrationLimits struct {
+	// PerIPPerDay is the number of accounts one IP address may create within 24 hours.
+	PerIPPerDay int `json:"perIpPerDay"`
+	// PerDevicePerDay is the same limit for the long-lived device cookie.
+	PerDevicePerDay int `json:"perDevicePerDay"`
+	// ChallengeAfter escalates to the interactive captcha once an IP or device has created this
+	// many accounts; fresh devices without a cookie are escalated after the first one.
+	ChallengeAfter int `json:"challengeAfter"`
+}
+
+// LoginLimits locks out an IP address or account after repeated failed logins. Zero selects the
+// default and a negative MaxFailures disables the lockout.
+type LoginLimits struct {
+	// MaxFailures is the number of failed logins within LockoutMinutes after which the IP address
+	// or account has to wait.
+	MaxFailures int `json:"maxFailures"`
+	// LockoutMinutes is the window failures are counted in, and so the longest wait.
+	LockoutMinutes int `json:"lockoutMinutes"`
+}
+
+// IPBuckets sets the networks that registration and redemption limits, abuse scoring and audit
+// logs treat as one client.
+type IPBuckets struct {
+	// IPv4PrefixLength groups IPv4 clients by this many leading bits; 32 keeps every address apart.
+	IPv4PrefixLength int `json:"ipv4PrefixLength"`
+	// IPv6PrefixLength does the same for IPv6, where one subscriber typically gets a whole /64.
+	IPv6PrefixLength int `json:"ipv6PrefixLength"`
+}
+
+// limitOrDefault maps an unset limit to fallback and a negative one to 0 (disabled).
+func limitOrDefault(value, fallback int) int {
+	switch {
+	case value == 0:
+		return fallback
+	case value < 0:
+		return 0
+	}
+	return value
+}
+
+// tokenBytesOrDefault maps an unset token length to fallback and rejects lengths outside the bounds.
+func tokenBytesOrDefault(value, fallback int) (int, error) {
+	if value == 0 {
+		return fallback, nil
+	}
+	if value < MinTokenBytes || value > MaxTokenBytes {
+		return 0, fmt.Errorf("tokenBytes must be between %d and %d", MinTokenBytes, MaxTokenBytes)
+	}
+	return value, nil
+}
+
+// CountryRestrictions limits registration and payments by the client's country.
+type CountryRestrictions struct {
+	// Allow, when non-empty, admits only these ISO 3166-1 alpha-2 countries; Deny rejects the listed ones.
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	// CountryHeader names a header set by a trusted proxy, e.g. CF-IPCountry behind Cloudflare.
+	CountryHeader string `json:"countryHeader"`
+	// GeoIPDatabase is a CSV range database (first_ip,last_ip,country) used when the header is absent.
+	GeoIPDatabase string `json:"geoipDatabase"`
+	// BlockUnknown rejects clients whose country cannot be determined.
+	BlockUnknown bool `json:"blockUnknown"`
+	// OverrideSecret signs the per-email override codes support can issue to affected users.
+	OverrideSecret string `json:"overrideSecret"`
+}
+
+// Enabled reports whether any country is allowed or denied explicitly.
+func (c CountryRestrictions) Enabled() bool {
+	return len(c.Allow) > 0 || len(c.Deny) > 0
+}
+
+func normalizeCountries(list []string) ([]string, error) {
+	out := make([]string, 0, len(list))
+	for _, code := range list {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return nil, fmt.Errorf("invalid country code %q", code)
+		}
+		out = append(out, code)
+	}
+	return out, nil
+}
+
+// APIUsage configures per-account API call tracking and optional daily quotas per plan.
+type APIUsage struct {
+	// Plans maps plan names to their quotas; accounts on a plan missing from the map are unlimited.
+	Plans map[string]APIPlan `json:"plans"`
+	// DefaultPlan applies to regular accounts and AdminPlan to accounts listed in adminEmails.
+	DefaultPlan string `json:"defaultPlan"`
+	AdminPlan   string `json:"adminPlan"`
+}
+
+// APIPlan describes the quota of one plan.
+type APIPlan struct {
+	// DailyRequests limits /api calls per UTC day; 0 means unlimited.
+	DailyRequests int64 `json:"dailyRequests"`
+}
+
+// Diagnostics configures the optional listener serving pprof profiles and expvar variables.
+type Diagnostics struct {
+	// ListenAddr is a loopback address such as 127.0.0.1:6060; empty disables the listener.
+	ListenAddr string `json:"listenAddr"`
+}
+
+func (d *Diagnostics) normalize() error {
+	d.ListenAddr = strings.TrimSpace(d.ListenAddr)
+	if d.ListenAddr == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(d.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", d.ListenAddr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("listen address %q must be a loopback address", d.ListenAddr)
+	}
+	return nil
+}
+
+// Payments configures the points bundles users can buy.
+type Payments struct {
+	// WebhookSecret signs payment provider notifications (HMAC-SHA256 of the request body).
+	WebhookSecret string          `json:"webhookSecret"`
+	Bundles       []PaymentBundle `json:"bundles"`
+}
+
+// PaymentBundle is a fixed number of points sold at a per-currency price in minor units.
+type PaymentBundle struct {
+	ID     string           `json:"id"`
+	Points int64            `json:"points"`
+	Prices map[string]int64 `json:"prices"`
+}
+
+func (p *Payments) normalize() error {
+	p.WebhookSecret = strings.TrimSpace(p.WebhookSecret)
+	seen := make(map[string]struct{}, len(p.Bundles))
+	for i := range p.Bundles {
+		bundle := &p.Bundles[i]
+		bundle.ID = strings.TrimSpace(bundle.ID)
+		if bundle.ID == "" {
+			return errors.New("bundle id must not be empty")
+		}
+		if _, dup := seen[bundle.ID]; dup {
+			return fmt.Errorf("duplicate bundle id %q", bundle.ID)
+		}
+		seen[bundle.ID] = struct{}{}
+		if bundle.Points <= 0 {
+			return fmt.Errorf("bundle %q: points must be positive", bundle.ID)
+		}
+		if len(bundle.Prices) == 0 {
+			return fmt.Errorf("bundle %q: at least one price required", bundle.ID)
+		}
+		prices := make(map[string]int64, len(bundle.Prices))
+		for code, amount := range bundle.Prices {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			supported := false
+			for _, candidate := range SupportedPaymentCurrencies {
+				supported = supported || candidate == code
+			}
+			if !supported {
+				return fmt.Errorf("bundle %q: unsupported currency %q", bundle.ID, code)
+			}
+			if amount <= 0 {
+				return fmt.Errorf("bundle %q: price in %s must be positive", bundle.ID, code)
+			}
+			prices[code] = amount
+		}
+		bundle.Prices = prices
+	}
+	return nil
+}
+
+// Currency configures how point prices are shown in real currency.
+type Currency struct {
+	// Base is the currency PointValue is expressed in (defaults to PLN).
+	Base string `json:"base"`
+	// PointValue is the value of one point in the base currency.
+	PointValue float64 `json:"pointValue"`
+	// Display is the currency prices are shown in unless the client asks for another one.
+	Display         string             `json:"display"`
+	Rates           map[string]float64 `json:"rates"`
+	RatesURL        string             `json:"ratesUrl"`
+	RatesTTLMinutes int                `json:"ratesTtlMinutes"`
+}
+
+// ActivationCodes configures how activation codes are generated and distributed to users.
+type ActivationCodes struct {
+	RedeemBaseURL  string `json:"redeemBaseUrl"`
+	Groups         int    `json:"groups"`
+	GroupLength    int    `json:"groupLength"`
+	Alphabet       string `json:"alphabet"`
+	AllowAmbiguous bool   `json:"allowAmbiguous"`
 }
 
 // EmailConfig controls localisation of transactional emails sent by the backend.
 type EmailConfig struct {
 	Language string `json:"language"`
+	// Transports lists mailers in priority order ("smtp", "mailgun", "console"); each is tried
+	// until one delivers. Empty means smtp when configured and console otherwise.
+	Transports []string `json:"transports"`
 }
 
+// EmailTransports lists the accepted email.transports entries.
+var EmailTransports = []string{"smtp", "mailgun", "console"}
+
+// MinLinkSigningSecretLength is the shortest accepted linkSigningSecret, the key that signs
+// verification and password reset links; shorter keys could be brute-forced from a single link.
+const MinLinkSigningSecretLength = 32
+
 // PasswordReset holds configuration for password reset tokens and links.
 type PasswordReset struct {
 	TokenTTLHours int    `json:"tokenTtlHours"`
 	BaseURL       string `json:"baseUrl"`
+	// TokenBytes is the number of random bytes in a token (sent hex-encoded).
+	TokenBytes int          `json:"tokenBytes"`
+	Link       LinkTemplate `json:"link"`
 }
 
 // Verification holds configuration for verification tokens and links.
 type Verification struct {
 	TokenTTLHours int `json:"tokenTtlHours"`
+	// TokenBytes is the number of random bytes in a token (sent hex-encoded).
+	TokenBytes int          `json:"tokenBytes"`
+	Link       LinkTemplate `json:"link"`
 }
 
+// LinkTemplate shapes an emailed link after the base URL. Path holds LinkTokenPlaceholder exactly
+// once, in the path ("/auth/verify/{token}") or its query ("/verify?token={token}"). Query adds
+// parameters such as a campaign tag. LinkLanguagePlaceholder in Path or a Query value becomes the
+// language of the email.
+type LinkTemplate struct {
+	Path  string            `json:"path"`
+	Query map[string]string `json:"query"`
+}
+
+// Placeholders accepted in LinkTemplate.
+const (
+	LinkTokenPlaceholder    = "{token}"
+	LinkLanguagePlaceholder = "{language}"
+)
+
+func (l *LinkTemplate) normalize(fallback string) error {
+	l.Path = strings.TrimSpace(l.Path)
+	if l.Path == "" {
+		l.Path = fallback
+	}
+	if !strings.HasPrefix(l.Path, "/") {
+		return errors.New("link path must start with /")
+	}
+	if strings.Count(l.Path, LinkTokenPlaceholder) != 1 {
+		return fmt.Errorf("link path must contain %s exactly once", LinkTokenPlaceholder)
+	}
+	sample := strings.NewReplacer(LinkTokenPlaceholder, "token", LinkLanguagePlaceholder, "language").Replace(l.Path)
+	if strings.ContainsAny(sample, "{}") {
+		return fmt.Errorf("link path may only use the %s and %s placeholders", LinkTokenPlaceholder, LinkLanguagePlaceholder)
+	}
+	if strings.Contains(sample, "#") {
+		return errors.New("link path must not contain a fragment")
+	}
+	parsed, err := url.Parse(sample)
+	if err != nil {
+		return fmt.Errorf("link path: %w", err)
+	}
+	pathQuery, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return fmt.Errorf("link path query: %w", err)
+	}
+	for name, value := range l.Query {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("link query parameter names must not be empty")
+		}
+		if pathQuery.Has(name) {
+			return fmt.Errorf("link query parameter %q is already set by the path", name)
+		}
+		if strings.ContainsAny(strings.ReplaceAll(value, LinkLanguagePlaceholder, ""), "{}") {
+			return fmt.Errorf("link query parameter %q may only use the %s placeholder", name, LinkLanguagePlaceholder)
+		}
+	}
+	return nil
+}
+
+// Bounds of Verification.TokenBytes and PasswordReset.TokenBytes.
+const (
+	MinTokenBytes = 16
+	MaxTokenBytes = 64
+)
+
 // DatabaseConfig encapsulates storage backend configuration.
 type DatabaseConfig struct {
 	Driver     string       `json:"driver"`
 	SQLitePath string       `json:"sqlitePath"`
 	MySQL      *MySQLConfig `json:"mysql"`
+	// SlowQueryThresholdMs is the duration after which a statement is logged as slow.
+	// Zero selects the default; a negative value disables slow query logging.
+	SlowQueryThresholdMs int `json:"slowQueryThresholdMs"`
 }
 
+const defaultSlowQueryThresholdMs = 250
+
 // MySQLConfig describes connection settings for MariaDB/MySQL engines.
 type MySQLConfig struct {
 	DSN         string `json:"dsn"`
@@ -55,7 +906,7 @@ type MySQLConfig struct {
 }
 
 func defaultDatabaseConfig() *DatabaseConfig {
-	return &DatabaseConfig{Driver: "sqlite"}
+	return &DatabaseConfig{Driver: "sqlite", SlowQueryThresholdMs: defaultSlowQueryThresholdMs}
 }
 
 func (c *DatabaseConfig) normalize() {
@@ -67,6 +918,9 @@ func (c *DatabaseConfig) normalize() {
 		c.Driver = "sqlite"
 	}
 	c.SQLitePath = strings.TrimSpace(c.SQLitePath)
+	if c.SlowQueryThresholdMs == 0 {
+		c.SlowQueryThresholdMs = defaultSlowQueryThresholdMs
+	}
 	if c.MySQL != nil {
 		c.MySQL.sanitize()
 	}
@@ -87,8 +941,35 @@ func Default() *Config {
 		PixelCostPoints:          10,
 		Database:                 defaultDatabaseConfig(),
 		Email:                    EmailConfig{Language: "pl"},
-		PasswordReset:            PasswordReset{TokenTTLHours: 24},
-		Verification:             Verification{TokenTTLHours: 24},
+		PasswordReset:            PasswordReset{TokenTTLHours: 24, TokenBytes: 32, Link: LinkTemplate{Path: "/reset-password?token={token}"}},
+		Verification:             Verification{TokenTTLHours: 24, TokenBytes: 32, Link: LinkTemplate{Path: "/verify?token={token}"}},
+		Currency:                 Currency{Base: "PLN", PointValue: 0.1, Display: "PLN", RatesTTLMinutes: 60},
+		GridCache:                GridCache{TTLSeconds: 2, StaleWhileRevalidateSeconds: 30},
+		RegistrationLimits:       RegistrationLimits{PerIPPerDay: 5, PerDevicePerDay: 3, ChallengeAfter: 2},
+		LoginLimits:              LoginLimits{MaxFailures: 10, LockoutMinutes: 15},
+		Purchases:                Purchases{MaxRequestBytes: 4 << 20, ChunkSize: 500, AsyncThreshold: 2000},
+		Tiles:                    Tiles{Size: 100},
+		Consistency:              Consistency{IntervalMinutes: 60},
+		OwnerWebhooks:            OwnerWebhooks{MaxAttempts: 5, TimeoutSeconds: 10},
+		Rentals:                  Rentals{PointsPerDay: 1, MaxDays: 365, WarnBeforeHours: 72},
+		PriceQuotes:              PriceQuotes{TTLMinutes: 15},
+		PixelHolds:               PixelHolds{TTLMinutes: 5, MaxPixels: 1000},
+		PixelDrafts:              PixelDrafts{MaxPerUser: 20, MaxPixels: 1000},
+		Sessions:                 Sessions{IdleTimeoutMinutes: 1440},
+		OutboundHTTP:             OutboundHTTP{TimeoutSeconds: 10, MaxRetries: 2, RetryDelayMs: 200, FailureThreshold: 5, OpenSeconds: 30},
+		IPBuckets:                IPBuckets{IPv4PrefixLength: 32, IPv6PrefixLength: 64},
+		CustomDomains:            CustomDomains{MaxPerUser: 3, CheckIntervalMinutes: 5, PendingTTLHours: 72},
+		LinkPreviews:             LinkPreviews{CacheTTLMinutes: 60, TimeoutSeconds: 5, MaxBytes: 256 << 10, DomainFetchesPerHour: 30},
+		LinkChecks:               LinkChecks{TimeoutSeconds: 5},
+		ContentModeration:        ContentModeration{Provider: "none"},
+		EngagementReports:        EngagementReports{IntervalDays: 7},
+		BusinessMetrics:          BusinessMetrics{IntervalSeconds: 60},
+		Metrics:                  Metrics{ListenAddr: "127.0.0.1:9100"},
+		ElasticLogs:              ElasticLogs{Index: "kuppixel-logs", BufferSize: 10000, BatchSize: 500, FlushIntervalSeconds: 5, MaxConcurrentFlushes: 2},
+		CanvasArchive:            CanvasArchive{IntervalMinutes: 1440, Dir: "data/canvas-archive"},
+		StartupHooks:             StartupHooks{Seed: "none"},
+		Invites:                  Invites{PerUser: 3},
+		Leaderboard:              Leaderboard{Size: 10, CacheSeconds: 30},
 	}
 }
 
@@ -152,16 +1033,350 @@ func Load(path string) (*Config, error) {
 		cfg.Email.Language = Default().Email.Language
 	}
 
+	if cfg.Mailgun != nil {
+		cfg.Mailgun.Sanitize()
+		if err := cfg.Mailgun.Validate(); err != nil {
+			return nil, fmt.Errorf("mailgun: %w", err)
+		}
+	}
+	transports := make([]string, 0, len(cfg.Email.Transports))
+	seenTransports := make(map[string]bool)
+	for _, name := range cfg.Email.Transports {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "smtp" && cfg.SMTP == nil:
+			return nil, errors.New("email.transports: smtp requires the smtp section")
+		case name == "mailgun" && cfg.Mailgun == nil:
+			return nil, errors.New("email.transports: mailgun requires the mailgun section")
+		case name != "smtp" && name != "mailgun" && name != "console":
+			return nil, fmt.Errorf("email.transports: unknown transport %q (supported: %s)", name, strings.Join(EmailTransports, ", "))
+		case seenTransports[name]:
+			return nil, fmt.Errorf("email.transports: duplicate transport %q", name)
+		}
+		seenTransports[name] = true
+		transports = append(transports, name)
+	}
+	cfg.Email.Transports = transports
+
 	cfg.TurnstileSecretKey = strings.TrimSpace(cfg.TurnstileSecretKey)
 
+	admins := make([]string, 0, len(cfg.AdminEmails))
+	for _, address := range cfg.AdminEmails {
+		if trimmed := strings.ToLower(strings.TrimSpace(address)); trimmed != "" {
+			admins = append(admins, trimmed)
+		}
+	}
+	cfg.AdminEmails = admins
+	cfg.ActivationCodes.RedeemBaseURL = strings.TrimSpace(cfg.ActivationCodes.RedeemBaseURL)
+	cfg.ActivationCodes.Alphabet = strings.ToUpper(strings.TrimSpace(cfg.ActivationCodes.Alphabet))
+
+	cfg.Currency.Base = strings.ToUpper(strings.TrimSpace(cfg.Currency.Base))
+	if cfg.Currency.Base == "" {
+		cfg.Currency.Base = Default().Currency.Base
+	}
+	if cfg.Currency.PointValue <= 0 {
+		cfg.Currency.PointValue = Default().Currency.PointValue
+	}
+	cfg.Currency.Display = strings.ToUpper(strings.TrimSpace(cfg.Currency.Display))
+	if cfg.Currency.Display == "" {
+		cfg.Currency.Display = cfg.Currency.Base
+	}
+	cfg.Currency.RatesURL = strings.TrimSpace(cfg.Currency.RatesURL)
+	if cfg.Currency.RatesTTLMinutes <= 0 {
+		cfg.Currency.RatesTTLMinutes = Default().Currency.RatesTTLMinutes
+	}
+
+	if err := cfg.Payments.normalize(); err != nil {
+		return nil, fmt.Errorf("payments: %w", err)
+	}
+
+	if cfg.GridCache.TTLSeconds == 0 {
+		cfg.GridCache.TTLSeconds = Default().GridCache.TTLSeconds
+	}
+	switch {
+	case cfg.GridCache.StaleWhileRevalidateSeconds == 0:
+		cfg.GridCache.StaleWhileRevalidateSeconds = Default().GridCache.StaleWhileRevalidateSeconds
+	case cfg.GridCache.StaleWhileRevalidateSeconds < 0:
+		cfg.GridCache.StaleWhileRevalidateSeconds = 0
+	}
+
+	cfg.Heartbeat.URL = strings.TrimRight(strings.TrimSpace(cfg.Heartbeat.URL), "/")
+	if cfg.Heartbeat.IntervalSeconds <= 0 {
+		cfg.Heartbeat.IntervalSeconds = 60
+	}
+
+	if cfg.AgeGate.MinimumAge < 0 || cfg.AgeGate.MinimumAge > 100 {
+		return nil, errors.New("ageGate: minimumAge must be between 0 and 100")
+	}
+
+	if cfg.Purchases.MaxRequestBytes < 0 || cfg.Purchases.ChunkSize < 0 || cfg.Purchases.MaxPixelsPerUser < 0 {
+		return nil, errors.New("purchases: maxRequestBytes, chunkSize and maxPixelsPerUser must not be negative")
+	}
+	if cfg.Purchases.MaxRequestBytes == 0 {
+		cfg.Purchases.MaxRequestBytes = Default().Purchases.MaxRequestBytes
+	}
+	if cfg.Purchases.ChunkSize == 0 {
+		cfg.Purchases.ChunkSize = Default().Purchases.ChunkSize
+	}
+	cfg.Purchases.AsyncThreshold = limitOrDefault(cfg.Purchases.AsyncThreshold, Default().Purchases.AsyncThreshold)
+
+	if cfg.Tiles.Size < 0 {
+		return nil, errors.New("tiles: size must not be negative")
+	}
+	if cfg.Tiles.Size == 0 {
+		cfg.Tiles.Size = Default().Tiles.Size
+	}
+
+	cfg.Consistency.IntervalMinutes = limitOrDefault(cfg.Consistency.IntervalMinutes, Default().Consistency.IntervalMinutes)
+
+	if cfg.OwnerWebhooks.MaxAttempts < 0 || cfg.OwnerWebhooks.TimeoutSeconds < 0 {
+		return nil, errors.New("ownerWebhooks: maxAttempts and timeoutSeconds must not be negative")
+	}
+	if cfg.OwnerWebhooks.MaxAttempts == 0 {
+		cfg.OwnerWebhooks.MaxAttempts = Default().OwnerWebhooks.MaxAttempts
+	}
+	if cfg.OwnerWebhooks.TimeoutSeconds == 0 {
+		cfg.OwnerWebhooks.TimeoutSeconds = Default().OwnerWebhooks.TimeoutSeconds
+	}
+	cfg.Push.FCMServiceAccountFile = strings.TrimSpace(cfg.Push.FCMServiceAccountFile)
+
+	if cfg.Rentals.PointsPerDay < 0 || cfg.Rentals.MaxDays < 0 || cfg.Rentals.WarnBeforeHours < 0 {
+		return nil, errors.New("rentals: pointsPerDay, maxDays and warnBeforeHours must not be negative")
+	}
+	if cfg.Rentals.PointsPerDay == 0 {
+		cfg.Rentals.PointsPerDay = Default().Rentals.PointsPerDay
+	}
+	if cfg.Rentals.MaxDays == 0 {
+		cfg.Rentals.MaxDays = Default().Rentals.MaxDays
+	}
+	if cfg.Rentals.WarnBeforeHours == 0 {
+		cfg.Rentals.WarnBeforeHours = Default().Rentals.WarnBeforeHours
+	}
+
+	if cfg.PriceQuotes.TTLMinutes < 0 {
+		return nil, errors.New("priceQuotes: ttlMinutes must not be negative")
+	}
+	cfg.PriceQuotes.TTLMinutes = limitOrDefault(cfg.PriceQuotes.TTLMinutes, Default().PriceQuotes.TTLMinutes)
+	cfg.PriceQuotes.Secret = strings.TrimSpace(cfg.PriceQuotes.Secret)
+	if cfg.PriceQuotes.Secret != "" && len(cfg.PriceQuotes.Secret) < MinLinkSigningSecretLength {
+		return nil, fmt.Errorf("priceQuotes: secret must be at least %d characters", MinLinkSigningSecretLength)
+	}
+
+	if cfg.PixelHolds.TTLMinutes < 0 || cfg.PixelHolds.MaxPixels < 0 {
+		return nil, errors.New("pixelHolds: ttlMinutes and maxPixels must not be negative")
+	}
+	cfg.PixelHolds.TTLMinutes = limitOrDefault(cfg.PixelHolds.TTLMinutes, Default().PixelHolds.TTLMinutes)
+	cfg.PixelHolds.MaxPixels = limitOrDefault(cfg.PixelHolds.MaxPixels, Default().PixelHolds.MaxPixels)
+
+	if cfg.PixelDrafts.MaxPerUser < 0 || cfg.PixelDrafts.MaxPixels < 0 {
+		return nil, errors.New("pixelDrafts: maxPerUser and maxPixels must not be negative")
+	}
+	cfg.PixelDrafts.MaxPerUser = limitOrDefault(cfg.PixelDrafts.MaxPerUser, Default().PixelDrafts.MaxPerUser)
+	cfg.PixelDrafts.MaxPixels = limitOrDefault(cfg.PixelDrafts.MaxPixels, Default().PixelDrafts.MaxPixels)
+	cfg.Sessions.IdleTimeoutMinutes = limitOrDefault(cfg.Sessions.IdleTimeoutMinutes, Default().Sessions.IdleTimeoutMinutes)
+
+	outbound, outboundDefaults := &cfg.OutboundHTTP, Default().OutboundHTTP
+	if outbound.TimeoutSeconds < 0 || outbound.RetryDelayMs < 0 || outbound.FailureThreshold < 0 || outbound.OpenSeconds < 0 {
+		return nil, errors.New("outboundHttp: timeoutSeconds, retryDelayMs, failureThreshold and openSeconds must not be negative")
+	}
+	outbound.TimeoutSeconds = limitOrDefault(outbound.TimeoutSeconds, outboundDefaults.TimeoutSeconds)
+	outbound.MaxRetries = limitOrDefault(outbound.MaxRetries, outboundDefaults.MaxRetries)
+	outbound.RetryDelayMs = limitOrDefault(outbound.RetryDelayMs, outboundDefaults.RetryDelayMs)
+	outbound.FailureThreshold = limitOrDefault(outbound.FailureThreshold, outboundDefaults.FailureThreshold)
+	outbound.OpenSeconds = limitOrDefault(outbound.OpenSeconds, outboundDefaults.OpenSeconds)
+
+	if cfg.PixelReleases.RefundFraction < 0 || cfg.PixelReleases.RefundFraction > 1 {
+		return nil, errors.New("pixelReleases: refundFraction must be between 0 and 1")
+	}
+
+	previews, previewDefaults := &cfg.LinkPreviews, Default().LinkPreviews
+	if previews.CacheTTLMinutes < 0 || previews.TimeoutSeconds < 0 || previews.MaxBytes < 0 || previews.DomainFetchesPerHour < 0 {
+		return nil, errors.New("linkPreviews: cacheTTLMinutes, timeoutSeconds, maxBytes and domainFetchesPerHour must not be negative")
+	}
+	previews.CacheTTLMinutes = limitOrDefault(previews.CacheTTLMinutes, previewDefaults.CacheTTLMinutes)
+	previews.TimeoutSeconds = limitOrDefault(previews.TimeoutSeconds, previewDefaults.TimeoutSeconds)
+	previews.DomainFetchesPerHour = limitOrDefault(previews.DomainFetchesPerHour, previewDefaults.DomainFetchesPerHour)
+	if previews.MaxBytes == 0 {
+		previews.MaxBytes = previewDefaults.MaxBytes
+	}
+
+	if err := ValidateBlockedTerms(cfg.PixelContent.BlockedTerms); err != nil {
+		return nil, fmt.Errorf("pixelContent: %w", err)
+	}
+	if cfg.PixelContent.AllowedDomains, err = normalizeAllowedDomains(cfg.PixelContent.AllowedDomains); err != nil {
+		return nil, fmt.Errorf("pixelContent: %w", err)
+	}
+
+	if cfg.LinkChecks.TimeoutSeconds < 0 {
+		return nil, errors.New("linkChecks: timeoutSeconds must not be negative")
+	}
+	cfg.LinkChecks.TimeoutSeconds = limitOrDefault(cfg.LinkChecks.TimeoutSeconds, Default().LinkChecks.TimeoutSeconds)
+
+	contentModeration := &cfg.ContentModeration
+	contentModeration.Provider = strings.TrimSpace(contentModeration.Provider)
+	contentModeration.SafeBrowsingAPIKey = strings.TrimSpace(contentModeration.SafeBrowsingAPIKey)
+	switch contentModeration.Provider {
+	case "":
+		contentModeration.Provider = Default().ContentModeration.Provider
+	case "none":
+	case "safeBrowsing":
+		if contentModeration.SafeBrowsingAPIKey == "" {
+			return nil, errors.New("contentModeration: safeBrowsingApiKey is required by the safeBrowsing provider")
+		}
+	default:
+		return nil, fmt.Errorf("contentModeration: unknown provider %q", contentModeration.Provider)
+	}
+
+	if cfg.EngagementReports.IntervalDays < 0 {
+		return nil, errors.New("engagementReports: intervalDays must not be negative")
+	}
+	cfg.EngagementReports.IntervalDays = limitOrDefault(cfg.EngagementReports.IntervalDays, Default().EngagementReports.IntervalDays)
+	cfg.BusinessMetrics.IntervalSeconds = limitOrDefault(cfg.BusinessMetrics.IntervalSeconds, Default().BusinessMetrics.IntervalSeconds)
+
+	canvasArchive := &cfg.CanvasArchive
+	if canvasArchive.IntervalMinutes < 0 {
+		return nil, errors.New("canvasArchive: intervalMinutes must not be negative")
+	}
+	canvasArchive.IntervalMinutes = limitOrDefault(canvasArchive.IntervalMinutes, Default().CanvasArchive.IntervalMinutes)
+	canvasArchive.Dir = strings.TrimSpace(canvasArchive.Dir)
+	if canvasArchive.Dir == "" {
+		canvasArchive.Dir = Default().CanvasArchive.Dir
+	}
+	bucket := &canvasArchive.S3
+	bucket.Bucket = strings.TrimSpace(bucket.Bucket)
+	bucket.Region = strings.TrimSpace(bucket.Region)
+	if bucket.Bucket != "" && (bucket.Region == "" || bucket.AccessKeyID == "" || bucket.SecretAccessKey == "") {
+		return nil, errors.New("canvasArchive: s3 needs region, accessKeyId and secretAccessKey with a bucket")
+	}
+
+	cfg.Invites.PerUser = limitOrDefault(cfg.Invites.PerUser, Default().Invites.PerUser)
+
+	cfg.Leaderboard.Size = limitOrDefault(cfg.Leaderboard.Size, Default().Leaderboard.Size)
+	if cfg.Leaderboard.Size > MaxLeaderboardSize {
+		return nil, fmt.Errorf("leaderboard: size must be at most %d", MaxLeaderboardSize)
+	}
+	cfg.Leaderboard.CacheSeconds = limitOrDefault(cfg.Leaderboard.CacheSeconds, Default().Leaderboard.CacheSeconds)
+
+	hooks := &cfg.StartupHooks
+	hooks.Seed = strings.TrimSpace(hooks.Seed)
+	hooks.SeedFile = strings.TrimSpace(hooks.SeedFile)
+	switch hooks.Seed {
+	case "":
+		hooks.Seed = Default().StartupHooks.Seed
+	case "none", "demo":
+	case "file":
+		if hooks.SeedFile == "" {
+			return nil, errors.New("startupHooks: seedFile is required by the file seed")
+		}
+		if !filepath.IsAbs(hooks.SeedFile) {
+			hooks.SeedFile = filepath.Join(filepath.Dir(path), hooks.SeedFile)
+		}
+	default:
+		return nil, fmt.Errorf("startupHooks: unknown seed %q", hooks.Seed)
+	}
+
+	domains, domainDefaults := &cfg.CustomDomains, Default().CustomDomains
+	if domains.MaxPerUser < 0 || domains.CheckIntervalMinutes < 0 || domains.PendingTTLHours < 0 {
+		return nil, errors.New("customDomains: maxPerUser, checkIntervalMinutes and pendingTtlHours must not be negative")
+	}
+	domains.MaxPerUser = limitOrDefault(domains.MaxPerUser, domainDefaults.MaxPerUser)
+	domains.CheckIntervalMinutes = limitOrDefault(domains.CheckIntervalMinutes, domainDefaults.CheckIntervalMinutes)
+	domains.PendingTTLHours = limitOrDefault(domains.PendingTTLHours, domainDefaults.PendingTTLHours)
+
+	if err := normalizeTenants(cfg.Tenants, filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("tenants: %w", err)
+	}
+	if err := normalizeBoards(cfg.Boards, cfg.PixelCostPoints); err != nil {
+		return nil, fmt.Errorf("boards: %w", err)
+	}
+
+	limits, defaults := &cfg.RegistrationLimits, Default().RegistrationLimits
+	limits.PerIPPerDay = limitOrDefault(limits.PerIPPerDay, defaults.PerIPPerDay)
+	limits.PerDevicePerDay = limitOrDefault(limits.PerDevicePerDay, defaults.PerDevicePerDay)
+	limits.ChallengeAfter = limitOrDefault(limits.ChallengeAfter, defaults.ChallengeAfter)
+
+	login := &cfg.LoginLimits
+	login.MaxFailures = limitOrDefault(login.MaxFailures, Default().LoginLimits.MaxFailures)
+	if login.LockoutMinutes <= 0 {
+		login.LockoutMinutes = Default().LoginLimits.LockoutMinutes
+	}
+
+	buckets := &cfg.IPBuckets
+	if buckets.IPv4PrefixLength < 0 || buckets.IPv4PrefixLength > 32 || buckets.IPv6PrefixLength < 0 || buckets.IPv6PrefixLength > 128 {
+		return nil, errors.New("ipBuckets: ipv4PrefixLength must be between 1 and 32 and ipv6PrefixLength between 1 and 128")
+	}
+	buckets.IPv4PrefixLength = limitOrDefault(buckets.IPv4PrefixLength, Default().IPBuckets.IPv4PrefixLength)
+	buckets.IPv6PrefixLength = limitOrDefault(buckets.IPv6PrefixLength, Default().IPBuckets.IPv6PrefixLength)
+
+	restrictions := &cfg.CountryRestrictions
+	if restrictions.Allow, err = normalizeCountries(restrictions.Allow); err != nil {
+		return nil, fmt.Errorf("countryRestrictions.allow: %w", err)
+	}
+	if restrictions.Deny, err = normalizeCountries(restrictions.Deny); err != nil {
+		return nil, fmt.Errorf("countryRestrictions.deny: %w", err)
+	}
+	restrictions.CountryHeader = strings.TrimSpace(restrictions.CountryHeader)
+	restrictions.GeoIPDatabase = strings.TrimSpace(restrictions.GeoIPDatabase)
+	restrictions.OverrideSecret = strings.TrimSpace(restrictions.OverrideSecret)
+	if restrictions.Enabled() && restrictions.CountryHeader == "" && restrictions.GeoIPDatabase == "" {
+		return nil, errors.New("countryRestrictions: countryHeader or geoipDatabase is required")
+	}
+
+	cfg.APIUsage.DefaultPlan = strings.TrimSpace(cfg.APIUsage.DefaultPlan)
+	if cfg.APIUsage.DefaultPlan == "" {
+		cfg.APIUsage.DefaultPlan = "free"
+	}
+	cfg.APIUsage.AdminPlan = strings.TrimSpace(cfg.APIUsage.AdminPlan)
+	if cfg.APIUsage.AdminPlan == "" {
+		cfg.APIUsage.AdminPlan = "admin"
+	}
+	for name, plan := range cfg.APIUsage.Plans {
+		if plan.DailyRequests < 0 {
+			return nil, fmt.Errorf("apiUsage: plan %q dailyRequests must not be negative", name)
+		}
+	}
+
+	if err := cfg.Cloudflare.normalize(); err != nil {
+		return nil, fmt.Errorf("cloudflare: %w", err)
+	}
+
+	if err := cfg.Diagnostics.normalize(); err != nil {
+		return nil, fmt.Errorf("diagnostics: %w", err)
+	}
+
+	if err := cfg.Metrics.normalize(); err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+
+	if err := cfg.ElasticLogs.normalize(); err != nil {
+		return nil, fmt.Errorf("elasticLogs: %w", err)
+	}
+
 	if cfg.PasswordReset.TokenTTLHours <= 0 {
 		cfg.PasswordReset.TokenTTLHours = Default().PasswordReset.TokenTTLHours
 	}
 	cfg.PasswordReset.BaseURL = strings.TrimSpace(cfg.PasswordReset.BaseURL)
+	if cfg.PasswordReset.TokenBytes, err = tokenBytesOrDefault(cfg.PasswordReset.TokenBytes, Default().PasswordReset.TokenBytes); err != nil {
+		return nil, fmt.Errorf("passwordReset: %w", err)
+	}
+	if err := cfg.PasswordReset.Link.normalize(Default().PasswordReset.Link.Path); err != nil {
+		return nil, fmt.Errorf("passwordReset: %w", err)
+	}
 
 	if cfg.Verification.TokenTTLHours <= 0 {
 		cfg.Verification.TokenTTLHours = Default().Verification.TokenTTLHours
 	}
+	if cfg.Verification.TokenBytes, err = tokenBytesOrDefault(cfg.Verification.TokenBytes, Default().Verification.TokenBytes); err != nil {
+		return nil, fmt.Errorf("verification: %w", err)
+	}
+	if err := cfg.Verification.Link.normalize(Default().Verification.Link.Path); err != nil {
+		return nil, fmt.Errorf("verification: %w", err)
+	}
+
+	cfg.LinkSigningSecret = strings.TrimSpace(cfg.LinkSigningSecret)
+	if cfg.LinkSigningSecret != "" && len(cfg.LinkSigningSecret) < MinLinkSigningSecretLength {
+		return nil, fmt.Errorf("linkSigningSecret must be at least %d characters", MinLinkSigningSecretLength)
+	}
 
 	if cfg.Database == nil {
 		cfg.Database = defaultDatabaseConfig()
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/example/kup-piksel/internal/email"
@@ -195,3 +196,756 @@ func TestWriteFile_DefaultConfig(t *testing.T) {
 		t.Fatalf("expected default verification ttl, got %d", cfg.Verification.TokenTTLHours)
 	}
 }
+
+func TestLoad_ActivationCodeFormat(t *testing.T) {
+	path := writeTempConfig(t, `{
+                "activationCodes": {"groups": 3, "groupLength": 5, "alphabet": " abcdef23456789 "}
+        }`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ActivationCodes.Groups != 3 || cfg.ActivationCodes.GroupLength != 5 {
+		t.Fatalf("unexpected code shape: %+v", cfg.ActivationCodes)
+	}
+	if cfg.ActivationCodes.Alphabet != "ABCDEF23456789" {
+		t.Fatalf("expected normalised alphabet, got %q", cfg.ActivationCodes.Alphabet)
+	}
+}
+
+func TestLoad_PaymentBundles(t *testing.T) {
+	path := writeTempConfig(t, `{
+                "payments": {"bundles": [{"id": " small ", "points": 100, "prices": {"pln": 1000, "EUR": 250}}]}
+        }`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(cfg.Payments.Bundles) != 1 {
+		t.Fatalf("expected one bundle, got %d", len(cfg.Payments.Bundles))
+	}
+	bundle := cfg.Payments.Bundles[0]
+	if bundle.ID != "small" || bundle.Prices["PLN"] != 1000 || bundle.Prices["EUR"] != 250 {
+		t.Fatalf("unexpected bundle %+v", bundle)
+	}
+
+	path = writeTempConfig(t, `{"payments": {"bundles": [{"id": "small", "points": 100, "prices": {"GBP": 100}}]}}`)
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected unsupported currency to be rejected")
+	}
+}
+
+func TestLoad_SlowQueryThreshold(t *testing.T) {
+	path := writeTempConfig(t, `{ "database": { "driver": "sqlite" } }`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Database.SlowQueryThresholdMs != defaultSlowQueryThresholdMs {
+		t.Fatalf("expected default threshold, got %d", cfg.Database.SlowQueryThresholdMs)
+	}
+
+	path = writeTempConfig(t, `{ "database": { "slowQueryThresholdMs": -1 } }`)
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Database.SlowQueryThresholdMs != -1 {
+		t.Fatalf("expected disabled threshold to be kept, got %d", cfg.Database.SlowQueryThresholdMs)
+	}
+}
+
+func TestLoad_DiagnosticsRequiresLoopback(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:6060", "localhost:6060", "[::1]:6060"} {
+		path := writeTempConfig(t, `{"diagnostics": {"listenAddr": " `+addr+` "}}`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) returned error: %v", addr, err)
+		}
+		if cfg.Diagnostics.ListenAddr != addr {
+			t.Fatalf("expected trimmed address %q, got %q", addr, cfg.Diagnostics.ListenAddr)
+		}
+	}
+
+	for _, addr := range []string{"0.0.0.0:6060", ":6060", "10.0.0.5:6060", "6060"} {
+		path := writeTempConfig(t, `{"diagnostics": {"listenAddr": "`+addr+`"}}`)
+		if _, err := Load(path); err == nil {
+			t.Fatalf("expected %q to be rejected", addr)
+		}
+	}
+}
+
+func TestLoad_GridCache(t *testing.T) {
+	path := writeTempConfig(t, `{}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.GridCache != Default().GridCache {
+		t.Fatalf("expected default grid cache, got %+v", cfg.GridCache)
+	}
+
+	path = writeTempConfig(t, `{"gridCache": {"ttlSeconds": -1, "staleWhileRevalidateSeconds": -1, "snapshotPath": "data/grid.snapshot"}}`)
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.GridCache.TTLSeconds != -1 || cfg.GridCache.StaleWhileRevalidateSeconds != 0 || cfg.GridCache.SnapshotPath != "data/grid.snapshot" {
+		t.Fatalf("unexpected grid cache config %+v", cfg.GridCache)
+	}
+}
+
+func TestLoad_Cloudflare(t *testing.T) {
+	path := writeTempConfig(t, `{"cloudflare": {"zoneId": " z ", "apiToken": "t", "siteUrl": "https://kuppixel.pl/", "pixelPurgePaths": [" /embed/{id}"]}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	cf := cfg.Cloudflare
+	if !cf.Enabled() || cf.ZoneID != "z" || cf.SiteURL != "https://kuppixel.pl" {
+		t.Fatalf("unexpected cloudflare config %+v", cf)
+	}
+	if len(cf.PurgePaths) != 3 || cf.PixelPurgePaths[0] != "/embed/{id}" || cf.PurgeIntervalSeconds != 5 {
+		t.Fatalf("unexpected purge defaults %+v", cf)
+	}
+
+	path = writeTempConfig(t, `{"cloudflare": {"zoneId": "z", "apiToken": "t"}}`)
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected missing siteUrl to be rejected")
+	}
+}
+
+func TestLoad_Heartbeat(t *testing.T) {
+	path := writeTempConfig(t, `{"heartbeat": {"url": " https://hc-ping.com/abc/ "}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Heartbeat.URL != "https://hc-ping.com/abc" || cfg.Heartbeat.IntervalSeconds != 60 {
+		t.Fatalf("unexpected heartbeat config %+v", cfg.Heartbeat)
+	}
+}
+
+func TestLoad_EmailTransports(t *testing.T) {
+	path := writeTempConfig(t, `{
+		"email": {"transports": ["SMTP", " mailgun", "console"]},
+		"smtp": {"host": "smtp.example.com", "port": 587, "fromEmail": "noreply@example.com"},
+		"mailgun": {"domain": "mg.example.com", "apiKey": "key", "fromEmail": "noreply@example.com"}
+	}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := strings.Join(cfg.Email.Transports, ","); got != "smtp,mailgun,console" {
+		t.Fatalf("unexpected transports %q", got)
+	}
+	if cfg.Mailgun.APIBase == "" {
+		t.Fatal("expected mailgun api base default")
+	}
+
+	for _, body := range []string{
+		`{"email": {"transports": ["mailgun"]}}`,
+		`{"email": {"transports": ["console", "console"]}}`,
+		`{"email": {"transports": ["sendgrid"]}}`,
+	} {
+		if _, err := Load(writeTempConfig(t, body)); err == nil {
+			t.Fatalf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestLoad_APIUsage(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"apiUsage": {"plans": {"free": {"dailyRequests": 1000}}}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.APIUsage.DefaultPlan != "free" || cfg.APIUsage.AdminPlan != "admin" || cfg.APIUsage.Plans["free"].DailyRequests != 1000 {
+		t.Fatalf("unexpected api usage config %+v", cfg.APIUsage)
+	}
+	if _, err := Load(writeTempConfig(t, `{"apiUsage": {"plans": {"free": {"dailyRequests": -1}}}}`)); err == nil {
+		t.Fatal("expected negative quota to be rejected")
+	}
+}
+
+func TestLoad_AgeGate(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"ageGate": {"minimumAge": 18}}`))
+	if err != nil || cfg.AgeGate.MinimumAge != 18 {
+		t.Fatalf("unexpected age gate %+v err=%v", cfg.AgeGate, err)
+	}
+	if _, err := Load(writeTempConfig(t, `{"ageGate": {"minimumAge": -1}}`)); err == nil {
+		t.Fatal("expected negative minimum age to be rejected")
+	}
+}
+
+func TestLoad_CountryRestrictions(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"countryRestrictions": {"deny": [" ru ", "by"], "countryHeader": "CF-IPCountry"}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := cfg.CountryRestrictions.Deny; len(got) != 2 || got[0] != "RU" || got[1] != "BY" {
+		t.Fatalf("unexpected deny list %v", got)
+	}
+	if !cfg.CountryRestrictions.Enabled() {
+		t.Fatal("expected restrictions to be enabled")
+	}
+	if _, err := Load(writeTempConfig(t, `{"countryRestrictions": {"allow": ["POL"], "countryHeader": "CF-IPCountry"}}`)); err == nil {
+		t.Fatal("expected invalid country code to be rejected")
+	}
+	if _, err := Load(writeTempConfig(t, `{"countryRestrictions": {"deny": ["RU"]}}`)); err == nil {
+		t.Fatal("expected restrictions without a country source to be rejected")
+	}
+}
+
+func TestLoad_EmailNormalization(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"emailNormalization": {"providerRules": true, "stripPlusAliases": true}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.EmailNormalization.ProviderRules || !cfg.EmailNormalization.StripPlusAliases {
+		t.Fatalf("unexpected email normalization %+v", cfg.EmailNormalization)
+	}
+}
+
+func TestLoad_RegistrationLimits(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"registrationLimits": {"perIpPerDay": 10, "perDevicePerDay": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	want := RegistrationLimits{PerIPPerDay: 10, PerDevicePerDay: 0, ChallengeAfter: Default().RegistrationLimits.ChallengeAfter}
+	if cfg.RegistrationLimits != want {
+		t.Fatalf("unexpected registration limits %+v", cfg.RegistrationLimits)
+	}
+}
+
+func TestLoad_Purchases(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"purchases": {"chunkSize": 250, "asyncThreshold": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Purchases.ChunkSize != 250 || cfg.Purchases.MaxRequestBytes != Default().Purchases.MaxRequestBytes || cfg.Purchases.AsyncThreshold != 0 {
+		t.Fatalf("unexpected purchases %+v", cfg.Purchases)
+	}
+	if _, err := Load(writeTempConfig(t, `{"purchases": {"maxRequestBytes": -1}}`)); err == nil {
+		t.Fatal("expected error for negative maxRequestBytes")
+	}
+	if cfg.Purchases.MaxPixelsPerUser != 0 {
+		t.Fatalf("expected no pixel quota by default, got %d", cfg.Purchases.MaxPixelsPerUser)
+	}
+	if _, err := Load(writeTempConfig(t, `{"purchases": {"maxPixelsPerUser": -1}}`)); err == nil {
+		t.Fatal("expected error for negative maxPixelsPerUser")
+	}
+}
+
+func TestLoad_Tiles(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Tiles.Size != Default().Tiles.Size {
+		t.Fatalf("expected default tile size, got %d", cfg.Tiles.Size)
+	}
+	if cfg, err = Load(writeTempConfig(t, `{"tiles": {"size": 250}}`)); err != nil || cfg.Tiles.Size != 250 {
+		t.Fatalf("unexpected tiles %+v (err %v)", cfg.Tiles, err)
+	}
+	if _, err := Load(writeTempConfig(t, `{"tiles": {"size": -1}}`)); err == nil {
+		t.Fatal("expected error for negative tile size")
+	}
+}
+
+func TestLoad_Consistency(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Consistency.IntervalMinutes != 60 || cfg.Consistency.Repair {
+		t.Fatalf("expected an hourly check without repair, got %+v", cfg.Consistency)
+	}
+	if cfg, err = Load(writeTempConfig(t, `{"consistency": {"intervalMinutes": -1, "repair": true}}`)); err != nil || cfg.Consistency.IntervalMinutes != 0 || !cfg.Consistency.Repair {
+		t.Fatalf("unexpected consistency %+v (err %v)", cfg.Consistency, err)
+	}
+}
+
+func TestLoad_LinkSigningSecret(t *testing.T) {
+	secret := strings.Repeat("s", MinLinkSigningSecretLength)
+	cfg, err := Load(writeTempConfig(t, `{"linkSigningSecret": " `+secret+` "}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.LinkSigningSecret != secret {
+		t.Fatalf("expected trimmed secret, got %q", cfg.LinkSigningSecret)
+	}
+	if _, err := Load(writeTempConfig(t, `{"linkSigningSecret": "short"}`)); err == nil {
+		t.Fatalf("expected a short secret to be rejected")
+	}
+}
+
+func TestLoad_TokenBytes(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"passwordReset": {"tokenBytes": 48}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.PasswordReset.TokenBytes != 48 || cfg.Verification.TokenBytes != 32 {
+		t.Fatalf("unexpected token lengths reset=%d verification=%d", cfg.PasswordReset.TokenBytes, cfg.Verification.TokenBytes)
+	}
+	for _, raw := range []string{`{"verification": {"tokenBytes": 8}}`, `{"passwordReset": {"tokenBytes": 65}}`} {
+		if _, err := Load(writeTempConfig(t, raw)); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestLoad_LinkTemplates(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"verification": {"link": {"path": " /auth/verify/{token} ", "query": {"lang": "{language}", "utm_campaign": "welcome"}}}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Verification.Link.Path != "/auth/verify/{token}" || cfg.Verification.Link.Query["utm_campaign"] != "welcome" {
+		t.Fatalf("unexpected verification link %+v", cfg.Verification.Link)
+	}
+	if cfg.PasswordReset.Link.Path != "/reset-password?token={token}" {
+		t.Fatalf("expected the default reset link path, got %q", cfg.PasswordReset.Link.Path)
+	}
+	for _, raw := range []string{
+		`{"verification": {"link": {"path": "auth/verify/{token}"}}}`,
+		`{"verification": {"link": {"path": "/auth/verify"}}}`,
+		`{"verification": {"link": {"path": "/auth/{token}/{token}"}}}`,
+		`{"passwordReset": {"link": {"path": "/reset/{token}/{user}"}}}`,
+		`{"passwordReset": {"link": {"path": "/#/reset/{token}"}}}`,
+		`{"passwordReset": {"link": {"path": "/reset?token={token}", "query": {"token": "x"}}}}`,
+		`{"passwordReset": {"link": {"path": "/reset/{token}", "query": {"t": "{token}"}}}}`,
+	} {
+		if _, err := Load(writeTempConfig(t, raw)); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestLoad_OwnerWebhooks(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"ownerWebhooks": {"enabled": true, "maxAttempts": 3}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.OwnerWebhooks.Enabled || cfg.OwnerWebhooks.MaxAttempts != 3 || cfg.OwnerWebhooks.TimeoutSeconds != 10 {
+		t.Fatalf("unexpected owner webhooks config %+v", cfg.OwnerWebhooks)
+	}
+	if _, err := Load(writeTempConfig(t, `{"ownerWebhooks": {"timeoutSeconds": -1}}`)); err == nil {
+		t.Fatal("expected a negative timeout to be rejected")
+	}
+}
+
+func TestLoad_Rentals(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"rentals": {"enabled": true, "pointsPerDay": 2}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.Rentals.Enabled || cfg.Rentals.PointsPerDay != 2 || cfg.Rentals.MaxDays != 365 || cfg.Rentals.WarnBeforeHours != 72 {
+		t.Fatalf("unexpected rentals config %+v", cfg.Rentals)
+	}
+	if _, err := Load(writeTempConfig(t, `{"rentals": {"maxDays": -1}}`)); err == nil {
+		t.Fatal("expected negative maxDays to be rejected")
+	}
+}
+
+func TestLoad_PriceQuotes(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"priceQuotes": {"secret": " 0123456789abcdef0123456789abcdef "}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.PriceQuotes.TTLMinutes != 15 || cfg.PriceQuotes.Secret != "0123456789abcdef0123456789abcdef" {
+		t.Fatalf("unexpected price quotes config %+v", cfg.PriceQuotes)
+	}
+	if _, err := Load(writeTempConfig(t, `{"priceQuotes": {"secret": "short"}}`)); err == nil {
+		t.Fatal("expected a short secret to be rejected")
+	}
+	if _, err := Load(writeTempConfig(t, `{"priceQuotes": {"ttlMinutes": -1}}`)); err == nil {
+		t.Fatal("expected negative ttlMinutes to be rejected")
+	}
+}
+
+func TestLoad_PixelHolds(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelHolds": {"maxPixels": 50}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.PixelHolds.TTLMinutes != 5 || cfg.PixelHolds.MaxPixels != 50 {
+		t.Fatalf("unexpected pixel holds config %+v", cfg.PixelHolds)
+	}
+	if _, err := Load(writeTempConfig(t, `{"pixelHolds": {"ttlMinutes": -5}}`)); err == nil {
+		t.Fatal("expected negative ttlMinutes to be rejected")
+	}
+}
+
+func TestLoad_OutboundHTTP(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"outboundHttp": {"maxRetries": -1, "openSeconds": 60}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	want := OutboundHTTP{TimeoutSeconds: 10, MaxRetries: 0, RetryDelayMs: 200, FailureThreshold: 5, OpenSeconds: 60}
+	if cfg.OutboundHTTP != want {
+		t.Fatalf("unexpected outbound http config %+v", cfg.OutboundHTTP)
+	}
+	if _, err := Load(writeTempConfig(t, `{"outboundHttp": {"timeoutSeconds": -1}}`)); err == nil {
+		t.Fatal("expected a negative timeout to be rejected")
+	}
+}
+
+func TestLoad_PixelDrafts(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelDrafts": {"maxPerUser": 5}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.PixelDrafts.MaxPerUser != 5 || cfg.PixelDrafts.MaxPixels != 1000 {
+		t.Fatalf("unexpected pixel drafts config %+v", cfg.PixelDrafts)
+	}
+	if _, err := Load(writeTempConfig(t, `{"pixelDrafts": {"maxPixels": -1}}`)); err == nil {
+		t.Fatal("expected negative maxPixels to be rejected")
+	}
+}
+
+func TestLoad_Sessions(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Sessions.IdleTimeoutMinutes != 1440 {
+		t.Fatalf("unexpected default sessions config %+v", cfg.Sessions)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"sessions": {"idleTimeoutMinutes": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Sessions.IdleTimeoutMinutes != 0 {
+		t.Fatalf("expected a negative idle timeout to disable it, got %+v", cfg.Sessions)
+	}
+}
+
+func TestLoad_LoginLimits(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.LoginLimits.MaxFailures != 10 || cfg.LoginLimits.LockoutMinutes != 15 {
+		t.Fatalf("unexpected default login limits %+v", cfg.LoginLimits)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"loginLimits": {"maxFailures": -1, "lockoutMinutes": -5}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.LoginLimits.MaxFailures != 0 || cfg.LoginLimits.LockoutMinutes != 15 {
+		t.Fatalf("expected a negative limit to disable the lockout, got %+v", cfg.LoginLimits)
+	}
+}
+
+func TestLoad_PixelReleases(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelReleases": {"refundFraction": 0.5}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.PixelReleases.RefundFraction != 0.5 {
+		t.Fatalf("unexpected pixel releases config %+v", cfg.PixelReleases)
+	}
+	if _, err := Load(writeTempConfig(t, `{"pixelReleases": {"refundFraction": 1.5}}`)); err == nil {
+		t.Fatal("expected a refund above the price to be rejected")
+	}
+}
+
+func TestLoad_Tenants(t *testing.T) {
+	path := writeTempConfig(t, `{"tenants": [{"name": "partner", "hosts": [" Piksele.Example.COM. "], "configPath": "partner.json", "baseUrl": "https://piksele.example.com/"}]}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	tenant := cfg.Tenants[0]
+	if tenant.Hosts[0] != "piksele.example.com" || tenant.ConfigPath != filepath.Join(filepath.Dir(path), "partner.json") || tenant.BaseURL != "https://piksele.example.com" {
+		t.Fatalf("unexpected tenant %+v", tenant)
+	}
+	for _, body := range []string{
+		`{"tenants": [{"name": "partner", "hosts": ["a.example.com"], "baseUrl": "https://a.example.com"}]}`,
+		`{"tenants": [{"name": "partner", "hosts": ["a.example.com:8080"], "configPath": "a.json", "baseUrl": "https://a.example.com"}]}`,
+		`{"tenants": [{"name": "partner/eu", "hosts": ["a.example.com"], "configPath": "a.json", "baseUrl": "https://a.example.com"}]}`,
+		`{"tenants": [{"name": "Partner", "hosts": ["a.example.com"], "configPath": "a.json", "baseUrl": "https://a.example.com"}]}`,
+		`{"tenants": [{"name": "a", "hosts": ["a.example.com"], "configPath": "a.json", "baseUrl": "https://a.example.com"}, {"name": "b", "hosts": ["A.example.com"], "configPath": "b.json", "baseUrl": "https://b.example.com"}]}`,
+	} {
+		if _, err := Load(writeTempConfig(t, body)); err == nil {
+			t.Fatalf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestLoad_Boards(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelCostPoints": 15, "boards": [{"id": " summer-2026 ", "name": "Summer festival", "pixelCostPoints": 5}, {"id": "club"}]}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(cfg.Boards) != 2 || cfg.Boards[0] != (Board{ID: "summer-2026", Name: "Summer festival", PixelCostPoints: 5}) || cfg.Boards[1] != (Board{ID: "club", Name: "club", PixelCostPoints: 15}) {
+		t.Fatalf("unexpected boards %+v", cfg.Boards)
+	}
+	for _, body := range []string{
+		`{"boards": [{"name": "No id"}]}`,
+		`{"boards": [{"id": "Summer Fest"}]}`,
+		`{"boards": [{"id": "fest"}, {"id": "fest"}]}`,
+		`{"boards": [{"id": "fest", "pixelCostPoints": -1}]}`,
+	} {
+		if _, err := Load(writeTempConfig(t, body)); err == nil {
+			t.Fatalf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestLoad_CustomDomains(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"customDomains": {"enabled": true, "maxPerUser": 1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	domains := cfg.CustomDomains
+	if !domains.Enabled || domains.MaxPerUser != 1 || domains.CheckIntervalMinutes != 5 || domains.PendingTTLHours != 72 {
+		t.Fatalf("unexpected custom domains config %+v", domains)
+	}
+	if _, err := Load(writeTempConfig(t, `{"customDomains": {"checkIntervalMinutes": -1}}`)); err == nil {
+		t.Fatal("expected a negative checkIntervalMinutes to be rejected")
+	}
+}
+
+func TestLoad_IPBuckets(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"ipBuckets": {"ipv6PrefixLength": 56}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.IPBuckets.IPv4PrefixLength != 32 || cfg.IPBuckets.IPv6PrefixLength != 56 {
+		t.Fatalf("unexpected ip buckets config %+v", cfg.IPBuckets)
+	}
+	if _, err := Load(writeTempConfig(t, `{"ipBuckets": {"ipv4PrefixLength": 33}}`)); err == nil {
+		t.Fatal("expected an IPv4 prefix longer than the address to be rejected")
+	}
+}
+
+func TestLoad_LinkChecks(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"linkChecks": {"enabled": true}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.LinkChecks.Enabled || cfg.LinkChecks.TimeoutSeconds != 5 {
+		t.Fatalf("unexpected link checks config %+v", cfg.LinkChecks)
+	}
+	if _, err := Load(writeTempConfig(t, `{"linkChecks": {"timeoutSeconds": -1}}`)); err == nil {
+		t.Fatal("expected a negative timeout to be rejected")
+	}
+}
+
+func TestLoad_ContentModeration(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ContentModeration.Provider != "none" {
+		t.Fatalf("unexpected default provider %q", cfg.ContentModeration.Provider)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"contentModeration": {"provider": " safeBrowsing ", "safeBrowsingApiKey": " key "}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.ContentModeration.Provider != "safeBrowsing" || cfg.ContentModeration.SafeBrowsingAPIKey != "key" {
+		t.Fatalf("unexpected content moderation config %+v", cfg.ContentModeration)
+	}
+	for _, body := range []string{
+		`{"contentModeration": {"provider": "safeBrowsing"}}`,
+		`{"contentModeration": {"provider": "vision"}}`,
+	} {
+		if _, err := Load(writeTempConfig(t, body)); err == nil {
+			t.Fatalf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestLoad_CanvasArchive(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"canvasArchive": {"enabled": true}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.CanvasArchive.IntervalMinutes != 1440 || cfg.CanvasArchive.Dir != "data/canvas-archive" {
+		t.Fatalf("unexpected canvas archive config %+v", cfg.CanvasArchive)
+	}
+	if _, err := Load(writeTempConfig(t, `{"canvasArchive": {"s3": {"bucket": "canvas"}}}`)); err == nil {
+		t.Fatal("expected a bucket without credentials to be rejected")
+	}
+	if _, err := Load(writeTempConfig(t, `{"canvasArchive": {"intervalMinutes": -5}}`)); err == nil {
+		t.Fatal("expected a negative interval to be rejected")
+	}
+}
+
+func TestLoad_StartupHooks(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.StartupHooks.Seed != "none" {
+		t.Fatalf("unexpected default seed %q", cfg.StartupHooks.Seed)
+	}
+	path := writeTempConfig(t, `{"startupHooks": {"seed": "file", "seedFile": "seed.json"}}`)
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if want := filepath.Join(filepath.Dir(path), "seed.json"); cfg.StartupHooks.SeedFile != want {
+		t.Fatalf("expected seed file %q, got %q", want, cfg.StartupHooks.SeedFile)
+	}
+	for _, body := range []string{
+		`{"startupHooks": {"seed": "file"}}`,
+		`{"startupHooks": {"seed": "always"}}`,
+	} {
+		if _, err := Load(writeTempConfig(t, body)); err == nil {
+			t.Fatalf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestLoad_Invites(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"invites": {"required": true}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.Invites.Required || cfg.Invites.PerUser != 3 {
+		t.Fatalf("unexpected invites config %+v", cfg.Invites)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"invites": {"required": true, "perUser": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Invites.PerUser != 0 {
+		t.Fatalf("expected a negative perUser to give no invites, got %d", cfg.Invites.PerUser)
+	}
+}
+
+func TestLoad_Leaderboard(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Leaderboard.Size != 10 || cfg.Leaderboard.CacheSeconds != 30 {
+		t.Fatalf("unexpected default leaderboard %+v", cfg.Leaderboard)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"leaderboard": {"size": -1, "cacheSeconds": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Leaderboard.Size != 0 || cfg.Leaderboard.CacheSeconds != 0 {
+		t.Fatalf("expected negative values to disable the leaderboard and its cache, got %+v", cfg.Leaderboard)
+	}
+	if _, err := Load(writeTempConfig(t, `{"leaderboard": {"size": 101}}`)); err == nil {
+		t.Fatalf("expected an oversized leaderboard to be rejected")
+	}
+}
+
+func TestLoad_PixelContentPatterns(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelContent": {"blockedTerms": ["casino", "/bet\\d+/"]}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if pattern, ok := BlockedTermPattern(cfg.PixelContent.BlockedTerms[1]); !ok || pattern != `bet\d+` {
+		t.Fatalf("unexpected pattern %q %t", pattern, ok)
+	}
+	if _, ok := BlockedTermPattern("casino"); ok {
+		t.Fatal("expected a plain term not to be a pattern")
+	}
+	if _, err := Load(writeTempConfig(t, `{"pixelContent": {"blockedTerms": ["/bet(/"]}}`)); err == nil {
+		t.Fatal("expected an invalid expression to be rejected")
+	}
+}
+
+func TestLoad_PixelContentAllowedDomains(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"pixelContent": {"allowedDomains": [" Corp.Example. ", "*.intranet.example", ""]}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := cfg.PixelContent.AllowedDomains; len(got) != 2 || got[0] != "corp.example" || got[1] != "intranet.example" {
+		t.Fatalf("unexpected allowed domains %q", got)
+	}
+	for _, domain := range []string{"https://corp.example", "localhost"} {
+		if _, err := Load(writeTempConfig(t, `{"pixelContent": {"allowedDomains": ["`+domain+`"]}}`)); err == nil {
+			t.Fatalf("expected %q to be rejected", domain)
+		}
+	}
+}
+
+func TestLoad_EngagementReports(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"engagementReports": {"enabled": true}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !cfg.EngagementReports.Enabled || cfg.EngagementReports.IntervalDays != 7 {
+		t.Fatalf("unexpected engagement reports config %+v", cfg.EngagementReports)
+	}
+	if _, err := Load(writeTempConfig(t, `{"engagementReports": {"intervalDays": -1}}`)); err == nil {
+		t.Fatal("expected a negative interval to be rejected")
+	}
+}
+
+func TestLoad_BusinessMetrics(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.BusinessMetrics.IntervalSeconds != 60 {
+		t.Fatalf("unexpected default interval %d", cfg.BusinessMetrics.IntervalSeconds)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"businessMetrics": {"intervalSeconds": -1}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.BusinessMetrics.IntervalSeconds != 0 {
+		t.Fatalf("expected a negative interval to disable the gauges, got %d", cfg.BusinessMetrics.IntervalSeconds)
+	}
+}
+
+func TestLoad_Metrics(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Metrics.ListenAddr != "127.0.0.1:9100" {
+		t.Fatalf("expected metrics on loopback by default, got %q", cfg.Metrics.ListenAddr)
+	}
+	cfg, err = Load(writeTempConfig(t, `{"metrics": {"listenAddr": " 0.0.0.0:9100 "}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Metrics.ListenAddr != "0.0.0.0:9100" {
+		t.Fatalf("expected trimmed address, got %q", cfg.Metrics.ListenAddr)
+	}
+	if _, err := Load(writeTempConfig(t, `{"metrics": {"listenAddr": "9100"}}`)); err == nil {
+		t.Fatal("expected an address without a port to be rejected")
+	}
+}
+
+func TestLoad_ElasticLogs(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"elasticLogs": {"url": " https://es.example.com:9200/ ", "batchSize": 100}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	logs := cfg.ElasticLogs
+	if logs.URL != "https://es.example.com:9200" || logs.Index != "kuppixel-logs" || logs.BufferSize != 10000 || logs.BatchSize != 100 || logs.FlushIntervalSeconds != 5 || logs.MaxConcurrentFlushes != 2 {
+		t.Fatalf("unexpected elastic logs config %+v", logs)
+	}
+	if _, err := Load(writeTempConfig(t, `{"elasticLogs": {"url": "es.example.com:9200"}}`)); err == nil {
+		t.Fatal("expected a url without a scheme to be rejected")
+	}
+	if _, err := Load(writeTempConfig(t, `{"elasticLogs": {"bufferSize": 10, "batchSize": 50}}`)); err == nil {
+		t.Fatal("expected a batch larger than the buffer to be rejected")
+	}
+}
+
+func TestLoad_LinkPreviews(t *testing.T) {
+	cfg, err := Load(writeTempConfig(t, `{"linkPreviews": {"enabled": true, "domainFetchesPerHour": 5}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	previews := cfg.LinkPreviews
+	if !previews.Enabled || previews.DomainFetchesPerHour != 5 || previews.CacheTTLMinutes != 60 || previews.TimeoutSeconds != 5 || previews.MaxBytes != 256<<10 {
+		t.Fatalf("unexpected link previews config %+v", previews)
+	}
+	if _, err := Load(writeTempConfig(t, `{"linkPreviews": {"maxBytes": -1}}`)); err == nil {
+		t.Fatal("expected negative maxBytes to be rejected")
+	}
+}
@@ -0,0 +1,225 @@
+// Package currency converts point amounts into real currency prices for display.
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBase is the currency points are priced in when no configuration is given.
+const DefaultBase = "PLN"
+
+// ErrUnsupportedCurrency is returned when no exchange rate is known for a currency.
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Price describes a point amount together with its value in a real currency.
+type Price struct {
+	Points      int64  `json:"points"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	Amount      string `json:"amount"`
+	Formatted   string `json:"formatted"`
+}
+
+// Config controls how points are converted.
+type Config struct {
+	// Base is the currency PointValue is expressed in.
+	Base string
+	// PointValue is the price of a single point in the base currency.
+	PointValue float64
+	// Rates maps currency codes to the amount of that currency one unit of Base buys.
+	Rates map[string]float64
+	// RatesURL optionally points at a JSON document of the form {"rates": {"EUR": 0.23}}
+	// with live rates for Base. Fetched rates override Rates and are cached for RatesTTL.
+	RatesURL string
+	RatesTTL time.Duration
+	// Client fetches RatesURL; nil selects a plain client with a 5 second timeout.
+	Client *http.Client
+}
+
+// Converter prices point amounts in the base currency and any currency with a known rate.
+type Converter struct {
+	base       string
+	pointValue float64
+	static     map[string]float64
+	ratesURL   string
+	ttl        time.Duration
+	client     *http.Client
+	now        func() time.Time
+
+	mu        sync.Mutex
+	live      map[string]float64
+	fetchedAt time.Time
+}
+
+// NewConverter validates the configuration and returns a converter.
+func NewConverter(cfg Config) (*Converter, error) {
+	base := strings.ToUpper(strings.TrimSpace(cfg.Base))
+	if base == "" {
+		base = DefaultBase
+	}
+	if cfg.PointValue <= 0 {
+		return nil, errors.New("point value must be positive")
+	}
+	static := make(map[string]float64, len(cfg.Rates)+1)
+	for code, rate := range cfg.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("rate for %s must be positive", code)
+		}
+		static[strings.ToUpper(strings.TrimSpace(code))] = rate
+	}
+	static[base] = 1
+	ttl := cfg.RatesTTL
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Converter{
+		base:       base,
+		pointValue: cfg.PointValue,
+		static:     static,
+		ratesURL:   strings.TrimSpace(cfg.RatesURL),
+		ttl:        ttl,
+		client:     client,
+		now:        time.Now,
+	}, nil
+}
+
+// Base returns the base currency code.
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// Price converts points into the given currency; an empty code means the base currency.
+func (c *Converter) Price(ctx context.Context, points int64, code string) (Price, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = c.base
+	}
+	rate, ok := c.rate(ctx, code)
+	if !ok {
+		return Price{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	minor := int64(math.Round(float64(points) * c.pointValue * rate * 100))
+	return Price{
+		Points:      points,
+		Currency:    code,
+		AmountMinor: minor,
+		Amount:      formatDecimal(minor, '.', ""),
+		Formatted:   Format(minor, code),
+	}, nil
+}
+
+// PointsFor returns how many points an amount (in minor units of code) is worth, rounded down.
+func (c *Converter) PointsFor(ctx context.Context, amountMinor int64, code string) (int64, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = c.base
+	}
+	rate, ok := c.rate(ctx, code)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	return int64(math.Floor(float64(amountMinor)/100/rate/c.pointValue + 1e-9)), nil
+}
+
+func (c *Converter) rate(ctx context.Context, code string) (float64, bool) {
+	if code == c.base {
+		return 1, true
+	}
+	if c.ratesURL != "" {
+		if rates := c.liveRates(ctx); rates != nil {
+			if rate, ok := rates[code]; ok && rate > 0 {
+				return rate, true
+			}
+		}
+	}
+	rate, ok := c.static[code]
+	return rate, ok
+}
+
+// liveRates returns cached live rates, refreshing them once the TTL expires. When a refresh
+// fails the previous rates are kept so a flaky rates provider does not break pricing.
+func (c *Converter) liveRates(ctx context.Context) map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.live != nil && c.now().Sub(c.fetchedAt) < c.ttl {
+		return c.live
+	}
+	rates, err := c.fetchRates(ctx)
+	// Failed refreshes are retried after the TTL as well, so the provider is not hammered.
+	c.fetchedAt = c.now()
+	if err != nil {
+		return c.live
+	}
+	c.live = rates
+	return c.live
+}
+
+func (c *Converter) fetchRates(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ratesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build rates request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch rates: unexpected status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode rates: %w", err)
+	}
+	rates := make(map[string]float64, len(payload.Rates))
+	for code, rate := range payload.Rates {
+		rates[strings.ToUpper(code)] = rate
+	}
+	return rates, nil
+}
+
+// Format renders an amount in minor units the way it is customarily written for the currency.
+func Format(minor int64, code string) string {
+	switch strings.ToUpper(code) {
+	case "PLN":
+		return formatDecimal(minor, ',', " ") + " zł"
+	case "EUR":
+		return formatDecimal(minor, ',', " ") + " €"
+	case "USD":
+		return "$" + formatDecimal(minor, '.', ",")
+	case "GBP":
+		return "£" + formatDecimal(minor, '.', ",")
+	default:
+		return formatDecimal(minor, '.', ",") + " " + strings.ToUpper(code)
+	}
+}
+
+func formatDecimal(minor int64, decimal byte, thousands string) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	whole := strconv.FormatInt(minor/100, 10)
+	if thousands != "" {
+		for i := len(whole) - 3; i > 0; i -= 3 {
+			whole = whole[:i] + thousands + whole[i:]
+		}
+	}
+	return fmt.Sprintf("%s%s%c%02d", sign, whole, decimal, minor%100)
+}
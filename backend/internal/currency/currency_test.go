@@ -0,0 +1,108 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPriceInBaseAndStaticRates(t *testing.T) {
+	conv, err := NewConverter(Config{PointValue: 0.1, Rates: map[string]float64{"eur": 0.23}})
+	if err != nil {
+		t.Fatalf("new converter: %v", err)
+	}
+
+	price, err := conv.Price(context.Background(), 10, "")
+	if err != nil {
+		t.Fatalf("price: %v", err)
+	}
+	if price.Currency != "PLN" || price.AmountMinor != 100 || price.Amount != "1.00" || price.Formatted != "1,00 zł" {
+		t.Fatalf("unexpected base price %+v", price)
+	}
+
+	price, err = conv.Price(context.Background(), 100, "EUR")
+	if err != nil {
+		t.Fatalf("price: %v", err)
+	}
+	if price.AmountMinor != 230 || price.Formatted != "2,30 €" {
+		t.Fatalf("unexpected eur price %+v", price)
+	}
+
+	if _, err := conv.Price(context.Background(), 10, "JPY"); !errors.Is(err, ErrUnsupportedCurrency) {
+		t.Fatalf("expected ErrUnsupportedCurrency, got %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := map[string]string{
+		Format(123456789, "PLN"): "1 234 567,89 zł",
+		Format(5, "USD"):         "$0.05",
+		Format(-250, "EUR"):      "-2,50 €",
+		Format(100000, "CHF"):    "1,000.00 CHF",
+	}
+	for got, want := range tests {
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestLiveRatesAreCached(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"base":"PLN","rates":{"USD":0.25}}`))
+	}))
+	defer srv.Close()
+
+	conv, err := NewConverter(Config{PointValue: 0.1, Rates: map[string]float64{"USD": 0.2}, RatesURL: srv.URL, RatesTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("new converter: %v", err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	conv.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		price, err := conv.Price(context.Background(), 40, "USD")
+		if err != nil {
+			t.Fatalf("price: %v", err)
+		}
+		if price.Formatted != "$1.00" {
+			t.Fatalf("expected live rate to be used, got %+v", price)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected rates to be fetched once, got %d", calls)
+	}
+
+	// A failed refresh keeps the previously fetched rates.
+	now = now.Add(2 * time.Minute)
+	price, err := conv.Price(context.Background(), 40, "USD")
+	if err != nil || price.Formatted != "$1.00" {
+		t.Fatalf("expected cached live rate after failed refresh, got %+v (%v)", price, err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a refresh attempt after ttl, got %d calls", calls)
+	}
+}
+
+func TestPointsFor(t *testing.T) {
+	conv, err := NewConverter(Config{PointValue: 0.1, Rates: map[string]float64{"EUR": 0.25}})
+	if err != nil {
+		t.Fatalf("new converter: %v", err)
+	}
+	points, err := conv.PointsFor(context.Background(), 250, "EUR")
+	if err != nil {
+		t.Fatalf("points for: %v", err)
+	}
+	if points != 100 {
+		t.Fatalf("expected 100 points for 2.50 EUR, got %d", points)
+	}
+}
@@ -0,0 +1,230 @@
+// Package elasticlog ships log lines to Elasticsearch with the bulk API. Lines wait in a bounded
+// buffer that drops the oldest ones when full, at most a fixed number of bulk requests run at a
+// time, and a failed request pauses shipping until the next periodic flush gets through, so a
+// slow or unreachable cluster never holds up logging or piles up goroutines.
+package elasticlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+// Config describes the cluster and the limits of a Shipper. BatchSize must not exceed BufferSize.
+type Config struct {
+	// URL is the base address of the cluster; batches are posted to URL + "/_bulk".
+	URL   string
+	Index string
+	// APIKey is sent as "Authorization: ApiKey ..." when set.
+	APIKey               string
+	BufferSize           int
+	BatchSize            int
+	MaxConcurrentFlushes int
+}
+
+type entry struct {
+	at   time.Time
+	line string
+}
+
+type document struct {
+	Timestamp string `json:"@timestamp"`
+	Message   string `json:"message"`
+}
+
+// Shipper is an io.Writer for log.SetOutput. Write only queues the line and starts a background
+// flush once a batch is ready; Flush is meant to run at an interval to send what is left.
+type Shipper struct {
+	cfg      Config
+	client   *http.Client
+	action   []byte
+	slots    chan struct{}
+	now      func() time.Time
+	shipped  *metrics.Counter
+	dropped  *metrics.Counter
+	rejected *metrics.Counter
+
+	mu     sync.Mutex
+	queue  []entry
+	paused bool
+	// droppedWhilePaused is reported when shipping resumes.
+	droppedWhilePaused int
+}
+
+func New(cfg Config, client *http.Client, registry *metrics.Registry) *Shipper {
+	action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": cfg.Index}})
+	return &Shipper{
+		cfg:      cfg,
+		client:   client,
+		action:   action,
+		slots:    make(chan struct{}, cfg.MaxConcurrentFlushes),
+		now:      time.Now,
+		shipped:  registry.Counter("kuppixel_log_entries_shipped_total", "Log lines delivered to Elasticsearch."),
+		dropped:  registry.Counter("kuppixel_log_entries_dropped_total", "Log lines dropped before reaching Elasticsearch, per reason.", "reason", "buffer_full"),
+		rejected: registry.Counter("kuppixel_log_entries_dropped_total", "Log lines dropped before reaching Elasticsearch, per reason.", "reason", "rejected"),
+	}
+}
+
+// Write queues one log line. When the buffer is full the oldest line is dropped to make room.
+func (s *Shipper) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	s.mu.Lock()
+	if len(s.queue) >= s.cfg.BufferSize {
+		s.queue[0] = entry{}
+		s.queue = s.queue[1:]
+		s.dropped.Inc()
+		if s.paused {
+			s.droppedWhilePaused++
+		}
+	}
+	s.queue = append(s.queue, entry{at: s.now(), line: line})
+	ready := len(s.queue) >= s.cfg.BatchSize && !s.paused
+	s.mu.Unlock()
+	if ready {
+		s.startFlush()
+	}
+	return len(p), nil
+}
+
+// startFlush flushes in the background unless MaxConcurrentFlushes flushes are running already;
+// the lines then wait for one of them or for the next Flush.
+func (s *Shipper) startFlush() {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		defer func() { <-s.slots }()
+		s.flush(context.Background())
+	}()
+}
+
+// Flush sends the buffered lines once a flush slot is free. While shipping is paused it is what
+// tries the cluster again. Failures are logged once per pause, so Flush itself never fails.
+func (s *Shipper) Flush(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.slots }()
+	s.flush(ctx)
+	return nil
+}
+
+func (s *Shipper) flush(ctx context.Context) {
+	for {
+		batch := s.take()
+		if len(batch) == 0 {
+			return
+		}
+		rejected, err := s.send(ctx, batch)
+		if err != nil {
+			s.pause(batch, err)
+			return
+		}
+		s.resume(len(batch) - rejected)
+	}
+}
+
+// take removes up to BatchSize of the oldest lines from the buffer.
+func (s *Shipper) take() []entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(len(s.queue), s.cfg.BatchSize)
+	batch := make([]entry, n)
+	copy(batch, s.queue)
+	clear(s.queue[:n])
+	s.queue = s.queue[n:]
+	return batch
+}
+
+// pause puts a failed batch back in front of the buffer, dropping the oldest lines that no longer
+// fit, and stops background flushes until a Flush gets through.
+func (s *Shipper) pause(batch []entry, err error) {
+	s.mu.Lock()
+	queue := append(batch, s.queue...)
+	if over := len(queue) - s.cfg.BufferSize; over > 0 {
+		queue = queue[over:]
+		s.dropped.Add(int64(over))
+		s.droppedWhilePaused += over
+	}
+	s.queue = queue
+	notice := !s.paused
+	s.paused = true
+	s.mu.Unlock()
+	if notice {
+		log.Printf("elasticlog: shipping paused after a failed flush: %v; retrying at the flush interval, the oldest lines are dropped once %d are buffered", err, s.cfg.BufferSize)
+	}
+}
+
+func (s *Shipper) resume(shipped int) {
+	s.shipped.Add(int64(shipped))
+	s.mu.Lock()
+	notice, dropped := s.paused, s.droppedWhilePaused
+	s.paused, s.droppedWhilePaused = false, 0
+	s.mu.Unlock()
+	if notice {
+		log.Printf("elasticlog: shipping resumed, %d lines were dropped while paused", dropped)
+	}
+}
+
+// send posts batch to the bulk API and returns how many lines the cluster rejected one by one,
+// e.g. for a mapping conflict. Those are counted as dropped and not sent again.
+func (s *Shipper) send(ctx context.Context, batch []entry) (int, error) {
+	var body bytes.Buffer
+	for _, e := range batch {
+		doc, err := json.Marshal(document{Timestamp: e.at.UTC().Format(time.RFC3339Nano), Message: e.line})
+		if err != nil {
+			return 0, fmt.Errorf("encode log line: %w", err)
+		}
+		body.Write(s.action)
+		body.WriteByte('\n')
+		body.Write(doc)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL+"/_bulk?filter_path=errors,items.*.status", &body)
+	if err != nil {
+		return 0, fmt.Errorf("build bulk request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.cfg.APIKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return 0, fmt.Errorf("bulk request: status %d", resp.StatusCode)
+	}
+	var result struct {
+		Errors bool                              `json:"errors"`
+		Items  []map[string]struct{ Status int } `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decode bulk response: %w", err)
+	}
+	rejected := 0
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, outcome := range item {
+				if outcome.Status/100 != 2 {
+					rejected++
+				}
+			}
+		}
+	}
+	s.rejected.Add(int64(rejected))
+	return rejected, nil
+}
@@ -0,0 +1,179 @@
+package elasticlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+// bulkServer records the messages of every bulk request it accepts.
+type bulkServer struct {
+	mu       sync.Mutex
+	messages []string
+	failing  atomic.Bool
+	requests atomic.Int64
+}
+
+func (b *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.requests.Add(1)
+	if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey secret" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if b.failing.Load() {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	scanner := bufio.NewScanner(r.Body)
+	for i := 0; scanner.Scan(); i++ {
+		if i%2 == 0 {
+			continue
+		}
+		var doc document
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		b.mu.Lock()
+		b.messages = append(b.messages, doc.Message)
+		b.mu.Unlock()
+	}
+	fmt.Fprint(w, `{"errors":false}`)
+}
+
+func (b *bulkServer) received() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.messages...)
+}
+
+func newTestShipper(t *testing.T, handler http.Handler, cfg Config) (*Shipper, *metrics.Registry) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cfg.URL, cfg.Index, cfg.APIKey = server.URL, "logs", "secret"
+	registry := metrics.NewRegistry()
+	return New(cfg, server.Client(), registry), registry
+}
+
+func TestShipperSendsBatches(t *testing.T) {
+	cluster := &bulkServer{}
+	shipper, _ := newTestShipper(t, cluster, Config{BufferSize: 10, BatchSize: 2, MaxConcurrentFlushes: 1})
+	for i := 1; i <= 3; i++ {
+		fmt.Fprintf(shipper, "line %d\n", i)
+	}
+	// Flush waits for the background flush of the first batch, then sends the rest.
+	if err := shipper.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := strings.Join(cluster.received(), ","); got != "line 1,line 2,line 3" {
+		t.Fatalf("unexpected shipped lines %q", got)
+	}
+}
+
+func TestShipperDropsOldestWhenFull(t *testing.T) {
+	cluster := &bulkServer{}
+	shipper, registry := newTestShipper(t, cluster, Config{BufferSize: 3, BatchSize: 3, MaxConcurrentFlushes: 1})
+	// A flush in progress holds the only slot, so the lines stay buffered.
+	shipper.slots <- struct{}{}
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(shipper, "line %d\n", i)
+	}
+	<-shipper.slots
+	if err := shipper.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := strings.Join(cluster.received(), ","); got != "line 3,line 4,line 5" {
+		t.Fatalf("expected the oldest lines dropped, got %q", got)
+	}
+	var out bytes.Buffer
+	registry.WriteText(&out)
+	if !strings.Contains(out.String(), `kuppixel_log_entries_dropped_total{reason="buffer_full"} 2`) {
+		t.Fatalf("expected two dropped lines counted, got:\n%s", out.String())
+	}
+}
+
+func TestShipperBoundsConcurrentFlushes(t *testing.T) {
+	release := make(chan struct{})
+	var inFlight, peak atomic.Int64
+	cluster := &bulkServer{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if n <= old || peak.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		<-release
+		cluster.ServeHTTP(w, r)
+	})
+	shipper, _ := newTestShipper(t, handler, Config{BufferSize: 100, BatchSize: 1, MaxConcurrentFlushes: 2})
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(shipper, "line %d\n", i)
+	}
+	close(release)
+	// Background flushes run until the buffer is empty; holding every slot waits for them.
+	for i := 0; i < cap(shipper.slots); i++ {
+		shipper.slots <- struct{}{}
+	}
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("expected at most 2 bulk requests at a time, saw %d", got)
+	}
+	if got := len(cluster.received()); got != 20 {
+		t.Fatalf("expected every line shipped, got %d", got)
+	}
+}
+
+func TestShipperPausesAfterFailure(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	cluster := &bulkServer{}
+	cluster.failing.Store(true)
+	shipper, _ := newTestShipper(t, cluster, Config{BufferSize: 10, BatchSize: 2, MaxConcurrentFlushes: 1})
+	fmt.Fprintln(shipper, "line 1")
+	if err := shipper.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if !strings.Contains(logs.String(), "elasticlog: shipping paused") {
+		t.Fatalf("expected a notice when shipping pauses, got %q", logs.String())
+	}
+
+	// Full batches no longer start flushes while paused.
+	requests := cluster.requests.Load()
+	for i := 2; i <= 5; i++ {
+		fmt.Fprintf(shipper, "line %d\n", i)
+	}
+	if err := shipper.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := cluster.requests.Load(); got != requests+1 {
+		t.Fatalf("expected only the periodic flush to try again, got %d requests", got-requests)
+	}
+
+	cluster.failing.Store(false)
+	if err := shipper.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := strings.Join(cluster.received(), ","); got != "line 1,line 2,line 3,line 4,line 5" {
+		t.Fatalf("expected the buffered lines shipped after resuming, got %q", got)
+	}
+	if !strings.Contains(logs.String(), "elasticlog: shipping resumed") {
+		t.Fatalf("expected a notice when shipping resumes, got %q", logs.String())
+	}
+}
@@ -0,0 +1,101 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Transport is a named mailer taking part in a Chain.
+type Transport struct {
+	Name   string
+	Mailer Mailer
+}
+
+// DeliveryHook observes every attempt made by a Chain; err is nil for the transport that delivered.
+type DeliveryHook func(kind, transport string, err error)
+
+// Chain tries its transports in priority order until one of them delivers the message.
+type Chain struct {
+	transports []Transport
+	hook       DeliveryHook
+}
+
+// NewChain returns a Chain trying transports in the given order.
+func NewChain(transports ...Transport) *Chain {
+	return &Chain{transports: transports}
+}
+
+// SetDeliveryHook registers hook to observe delivery attempts. It is not safe to call concurrently with sends.
+func (c *Chain) SetDeliveryHook(hook DeliveryHook) {
+	c.hook = hook
+}
+
+// Transports returns the transport names in priority order.
+func (c *Chain) Transports() []string {
+	names := make([]string, 0, len(c.transports))
+	for _, t := range c.transports {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// SendVerificationEmail tries each transport until one delivers the verification email.
+func (c *Chain) SendVerificationEmail(ctx context.Context, recipient, verificationLink string) error {
+	return c.send(ctx, "verification", func(m Mailer) error {
+		return m.SendVerificationEmail(ctx, recipient, verificationLink)
+	})
+}
+
+// SendPasswordResetEmail tries each transport until one delivers the password reset email.
+func (c *Chain) SendPasswordResetEmail(ctx context.Context, recipient, resetLink string) error {
+	return c.send(ctx, "password_reset", func(m Mailer) error {
+		return m.SendPasswordResetEmail(ctx, recipient, resetLink)
+	})
+}
+
+// SendTestEmail tries each transport that supports test messages until one delivers.
+func (c *Chain) SendTestEmail(ctx context.Context, recipient string) error {
+	return c.send(ctx, "test", func(m Mailer) error {
+		sender, ok := m.(TestSender)
+		if !ok {
+			return errors.New("transport does not support test messages")
+		}
+		return sender.SendTestEmail(ctx, recipient)
+	})
+}
+
+func (c *Chain) send(ctx context.Context, kind string, deliver func(Mailer) error) error {
+	if len(c.transports) == 0 {
+		return errors.New("no mail transports configured")
+	}
+	trace := diagnosticsFrom(ctx)
+	var errs []error
+	for _, t := range c.transports {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		started := time.Now()
+		err := deliver(t.Mailer)
+		trace.record("transport:"+t.Name, started, err, "")
+		if c.hook != nil {
+			c.hook(kind, t.Name, err)
+		}
+		if err == nil {
+			if len(errs) > 0 {
+				log.Printf("[email] %s email delivered by fallback transport %s after %d failure(s)", kind, t.Name, len(errs))
+			}
+			return nil
+		}
+		log.Printf("[email] %s email via %s failed: %v", kind, t.Name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+	}
+	return fmt.Errorf("all mail transports failed: %w", errors.Join(errs...))
+}
+
+var (
+	_ Mailer     = (*Chain)(nil)
+	_ TestSender = (*Chain)(nil)
+)
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type stubMailer struct {
+	err  error
+	sent int
+}
+
+func (s *stubMailer) SendVerificationEmail(ctx context.Context, recipient, link string) error {
+	s.sent++
+	return s.err
+}
+
+func (s *stubMailer) SendPasswordResetEmail(ctx context.Context, recipient, link string) error {
+	s.sent++
+	return s.err
+}
+
+func TestChainFallsBackToNextTransport(t *testing.T) {
+	primary := &stubMailer{err: errors.New("connection refused")}
+	fallback := &stubMailer{}
+	last := &stubMailer{}
+	chain := NewChain(Transport{"smtp", primary}, Transport{"mailgun", fallback}, Transport{"console", last})
+
+	var attempts []string
+	chain.SetDeliveryHook(func(kind, transport string, err error) {
+		result := "ok"
+		if err != nil {
+			result = "failed"
+		}
+		attempts = append(attempts, kind+":"+transport+":"+result)
+	})
+
+	if err := chain.SendVerificationEmail(context.Background(), "user@example.com", "https://kuppixel.pl/verify?token=x"); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+	if primary.sent != 1 || fallback.sent != 1 || last.sent != 0 {
+		t.Fatalf("unexpected attempts primary=%d fallback=%d last=%d", primary.sent, fallback.sent, last.sent)
+	}
+	if got := strings.Join(attempts, " "); got != "verification:smtp:failed verification:mailgun:ok" {
+		t.Fatalf("unexpected hook calls %q", got)
+	}
+}
+
+func TestChainReportsAllFailures(t *testing.T) {
+	chain := NewChain(
+		Transport{"smtp", &stubMailer{err: errors.New("timeout")}},
+		Transport{"mailgun", &stubMailer{err: errors.New("status 401")}},
+	)
+	var diag Diagnostics
+	err := chain.SendPasswordResetEmail(WithDiagnostics(context.Background(), &diag), "user@example.com", "https://kuppixel.pl/reset-password?token=x")
+	if err == nil || !strings.Contains(err.Error(), "smtp: timeout") || !strings.Contains(err.Error(), "mailgun: status 401") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := phaseStatuses(diag.Phases()); got != "transport:smtp=failed transport:mailgun=failed" {
+		t.Fatalf("unexpected phases %q", got)
+	}
+}
+
+func TestChainStopsWhenContextIsCancelled(t *testing.T) {
+	primary := &stubMailer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewChain(Transport{"smtp", primary}).SendVerificationEmail(ctx, "user@example.com", "https://kuppixel.pl"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context cancellation, got %v", err)
+	}
+	if primary.sent != 0 {
+		t.Fatal("no transport should be tried after cancellation")
+	}
+}
@@ -0,0 +1,79 @@
+package email
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Phase statuses reported in Diagnostics.
+const (
+	PhaseOK      = "ok"
+	PhaseSkipped = "skipped"
+	PhaseFailed  = "failed"
+)
+
+// Phase is one step of a delivery attempt, e.g. the SMTP connect, TLS, auth or DATA phase.
+type Phase struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Detail     string  `json:"detail,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Diagnostics collects the transport phases of deliveries made with a context from WithDiagnostics.
+type Diagnostics struct {
+	mu     sync.Mutex
+	phases []Phase
+}
+
+type diagnosticsKey struct{}
+
+// WithDiagnostics returns a context under which mailers record their transport phases into d.
+func WithDiagnostics(ctx context.Context, d *Diagnostics) context.Context {
+	return context.WithValue(ctx, diagnosticsKey{}, d)
+}
+
+func diagnosticsFrom(ctx context.Context) *Diagnostics {
+	d, _ := ctx.Value(diagnosticsKey{}).(*Diagnostics)
+	return d
+}
+
+// Phases returns the recorded phases in order.
+func (d *Diagnostics) Phases() []Phase {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Phase(nil), d.phases...)
+}
+
+// record appends a phase that started at started; a nil Diagnostics ignores calls.
+func (d *Diagnostics) record(name string, started time.Time, err error, detail string) {
+	if d == nil {
+		return
+	}
+	phase := Phase{Name: name, Status: PhaseOK, Detail: detail, DurationMS: float64(time.Since(started).Microseconds()) / 1000}
+	if err != nil {
+		phase.Status = PhaseFailed
+		phase.Detail = err.Error()
+	}
+	d.mu.Lock()
+	d.phases = append(d.phases, phase)
+	d.mu.Unlock()
+}
+
+func (d *Diagnostics) skip(name, detail string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.phases = append(d.phases, Phase{Name: name, Status: PhaseSkipped, Detail: detail})
+	d.mu.Unlock()
+}
+
+// TestSender is implemented by mailers that can deliver an ad-hoc diagnostic message.
+type TestSender interface {
+	SendTestEmail(ctx context.Context, recipient string) error
+}
@@ -0,0 +1,100 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer speaks just enough SMTP for one transaction; recipients containing "reject" are refused.
+func fakeSMTPServer(t *testing.T) SMTPConfig {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-fake")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "RCPT") && strings.Contains(cmd, "REJECT"):
+				reply("550 mailbox unavailable")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+				}
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: portNum, FromEmail: "noreply@kuppixel.pl"}
+}
+
+func phaseStatuses(phases []Phase) string {
+	parts := make([]string, 0, len(phases))
+	for _, p := range phases {
+		parts = append(parts, p.Name+"="+p.Status)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestSendTestEmailRecordsSMTPPhases(t *testing.T) {
+	mailer, err := NewSMTPMailer(fakeSMTPServer(t), "en")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer() error = %v", err)
+	}
+	var diag Diagnostics
+	if err := mailer.SendTestEmail(WithDiagnostics(context.Background(), &diag), "admin@example.com"); err != nil {
+		t.Fatalf("SendTestEmail() error = %v", err)
+	}
+	want := "connect=ok greeting=ok tls=skipped auth=skipped envelope=ok data=ok quit=ok"
+	if got := phaseStatuses(diag.Phases()); got != want {
+		t.Fatalf("phases %q, want %q", got, want)
+	}
+}
+
+func TestSendTestEmailReportsFailingPhase(t *testing.T) {
+	mailer, err := NewSMTPMailer(fakeSMTPServer(t), "en")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer() error = %v", err)
+	}
+	var diag Diagnostics
+	if err := mailer.SendTestEmail(WithDiagnostics(context.Background(), &diag), "reject@example.com"); err == nil {
+		t.Fatal("expected rejected recipient error")
+	}
+	phases := diag.Phases()
+	last := phases[len(phases)-1]
+	if last.Name != "envelope" || last.Status != PhaseFailed || !strings.Contains(last.Detail, "550") {
+		t.Fatalf("unexpected phases %+v", phases)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Mailer is responsible for delivering transactional emails to users.
@@ -72,35 +74,92 @@ func (m *ConsoleMailer) SendPasswordResetEmail(ctx context.Context, recipient, r
 	return nil
 }
 
+// SendTestEmail logs the test message instead of delivering it.
+func (m *ConsoleMailer) SendTestEmail(ctx context.Context, recipient string) error {
+	started := time.Now()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("[email] To: %s | Subject: %s", strings.TrimSpace(recipient), m.locale.testSubject)
+	diagnosticsFrom(ctx).record("console", started, nil, "message logged, not delivered")
+	return nil
+}
+
+var _ TestSender = (*ConsoleMailer)(nil)
+
 type localeContent struct {
+	greeting            string
 	verificationSubject string
-	verificationBody    string
+	verificationIntro   string
+	verificationAction  string
+	verificationIgnore  string
 	resetSubject        string
-	resetBody           string
+	resetIntro          string
+	resetAction         string
+	resetIgnore         string
+	testSubject         string
+	testBody            string
+	footer              string
 }
 
 var locales = map[string]localeContent{
 	"pl": {
+		greeting:            "Cześć!",
 		verificationSubject: "Potwierdź swój adres e-mail",
-		verificationBody:    "Cześć!\n\nKliknij poniższy link, aby potwierdzić swoje konto w Kup Piksel:\n%s\n\nJeżeli to nie Ty zakładałeś konto, zignoruj tę wiadomość.\n",
+		verificationIntro:   "Kliknij poniższy link, aby potwierdzić swoje konto w Kup Piksel:",
+		verificationAction:  "Potwierdź konto",
+		verificationIgnore:  "Jeżeli to nie Ty zakładałeś konto, zignoruj tę wiadomość.",
 		resetSubject:        "Zresetuj swoje hasło",
-		resetBody:           "Cześć!\n\nKliknij poniższy link, aby ustawić nowe hasło do konta w Kup Piksel:\n%s\n\nJeżeli to nie Ty prosiłeś o reset hasła, zignoruj tę wiadomość.\n",
+		resetIntro:          "Kliknij poniższy link, aby ustawić nowe hasło do konta w Kup Piksel:",
+		resetAction:         "Ustaw nowe hasło",
+		resetIgnore:         "Jeżeli to nie Ty prosiłeś o reset hasła, zignoruj tę wiadomość.",
+		testSubject:         "Wiadomość testowa Kup Piksel",
+		testBody:            "To jest wiadomość testowa wysłana z panelu administratora. Jeśli ją widzisz, wysyłka e-maili działa poprawnie.",
+		footer:              "Wiadomość wysłana automatycznie przez Kup Piksel.",
 	},
 	"en": {
+		greeting:            "Hello!",
 		verificationSubject: "Confirm your email address",
-		verificationBody:    "Hello!\n\nClick the link below to confirm your Kup Piksel account:\n%s\n\nIf you didn't create an account, please ignore this message.\n",
+		verificationIntro:   "Click the link below to confirm your Kup Piksel account:",
+		verificationAction:  "Confirm account",
+		verificationIgnore:  "If you didn't create an account, please ignore this message.",
 		resetSubject:        "Reset your password",
-		resetBody:           "Hello!\n\nClick the link below to set a new password for your Kup Piksel account:\n%s\n\nIf you didn't request a password reset, please ignore this message.\n",
+		resetIntro:          "Click the link below to set a new password for your Kup Piksel account:",
+		resetAction:         "Set a new password",
+		resetIgnore:         "If you didn't request a password reset, please ignore this message.",
+		testSubject:         "Kup Piksel test message",
+		testBody:            "This is a test message sent from the admin panel. If you can read it, email delivery works.",
+		footer:              "This message was sent automatically by Kup Piksel.",
 	},
 }
 
-func resolveLocale(language string) localeContent {
+// Languages returns the supported email languages in sorted order.
+func Languages() []string {
+	langs := make([]string, 0, len(locales))
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func normalizeLanguage(language string) string {
 	lang := strings.ToLower(strings.TrimSpace(language))
 	if lang == "" {
 		lang = "pl"
 	}
-	if content, ok := locales[lang]; ok {
-		return content
+	return lang
+}
+
+// resolveLanguage returns language when it is supported and Polish otherwise.
+func resolveLanguage(language string) string {
+	lang := normalizeLanguage(language)
+	if _, ok := locales[lang]; ok {
+		return lang
 	}
-	return locales["pl"]
+	return "pl"
+}
+
+func resolveLocale(language string) localeContent {
+	return locales[resolveLanguage(language)]
 }
@@ -0,0 +1,157 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMailgunAPIBase is the Mailgun v3 API endpoint for the US region.
+const DefaultMailgunAPIBase = "https://api.mailgun.net/v3"
+
+// MailgunConfig contains configuration for delivering emails through the Mailgun HTTP API.
+type MailgunConfig struct {
+	Domain    string `json:"domain"`
+	APIKey    string `json:"apiKey"`
+	FromEmail string `json:"fromEmail"`
+	FromName  string `json:"fromName"`
+	// APIBase overrides DefaultMailgunAPIBase, e.g. https://api.eu.mailgun.net/v3 for EU domains.
+	APIBase string `json:"apiBase"`
+}
+
+// Sanitize trims whitespace from configuration fields and applies defaults.
+func (c *MailgunConfig) Sanitize() {
+	c.Domain = strings.TrimSpace(c.Domain)
+	c.APIKey = strings.TrimSpace(c.APIKey)
+	c.FromEmail = strings.TrimSpace(c.FromEmail)
+	c.FromName = strings.TrimSpace(c.FromName)
+	if c.FromName == "" {
+		c.FromName = "Kup Piksel"
+	}
+	c.APIBase = strings.TrimRight(strings.TrimSpace(c.APIBase), "/")
+	if c.APIBase == "" {
+		c.APIBase = DefaultMailgunAPIBase
+	}
+}
+
+// Validate checks that the configuration contains mandatory fields.
+func (c *MailgunConfig) Validate() error {
+	if c == nil {
+		return errors.New("mailgun config is nil")
+	}
+	if c.Domain == "" {
+		return errors.New("mailgun domain is required")
+	}
+	if c.APIKey == "" {
+		return errors.New("mailgun api key is required")
+	}
+	if c.FromEmail == "" {
+		return errors.New("mailgun sender email is required")
+	}
+	return nil
+}
+
+// MailgunMailer delivers emails through the Mailgun messages API.
+type MailgunMailer struct {
+	config   MailgunConfig
+	language string
+	locale   localeContent
+	client   *http.Client
+}
+
+// NewMailgunMailer constructs a Mailer backed by the Mailgun HTTP API. client sends the requests;
+// nil selects a plain client with a 15 second timeout.
+func NewMailgunMailer(cfg MailgunConfig, language string, client *http.Client) (*MailgunMailer, error) {
+	cfg.Sanitize()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &MailgunMailer{
+		config:   cfg,
+		language: resolveLanguage(language),
+		locale:   resolveLocale(language),
+		client:   client,
+	}, nil
+}
+
+// SendVerificationEmail sends an activation email with a verification link.
+func (m *MailgunMailer) SendVerificationEmail(ctx context.Context, recipient, verificationLink string) error {
+	message, err := render(TemplateVerification, m.language, m.locale, verificationLink)
+	if err != nil {
+		return err
+	}
+	return m.send(ctx, recipient, message)
+}
+
+// SendPasswordResetEmail sends a password reset link to the user.
+func (m *MailgunMailer) SendPasswordResetEmail(ctx context.Context, recipient, resetLink string) error {
+	message, err := render(TemplatePasswordReset, m.language, m.locale, resetLink)
+	if err != nil {
+		return err
+	}
+	return m.send(ctx, recipient, message)
+}
+
+// SendTestEmail delivers a short diagnostic message.
+func (m *MailgunMailer) SendTestEmail(ctx context.Context, recipient string) error {
+	return m.send(ctx, recipient, testMessage(m.locale))
+}
+
+func (m *MailgunMailer) send(ctx context.Context, recipient string, message Message) error {
+	if m == nil {
+		return errors.New("mailgun mailer is nil")
+	}
+	recipient = strings.TrimSpace(recipient)
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	from := mail.Address{Name: m.config.FromName, Address: m.config.FromEmail}
+	form := url.Values{
+		"from":    {from.String()},
+		"to":      {recipient},
+		"subject": {message.Subject},
+		"text":    {message.Text},
+		"html":    {message.HTML},
+	}
+	endpoint := fmt.Sprintf("%s/%s/messages", m.config.APIBase, url.PathEscape(m.config.Domain))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", m.config.APIKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	started := time.Now()
+	resp, err := m.client.Do(req)
+	if err != nil {
+		diagnosticsFrom(ctx).record("http", started, err, "")
+		return fmt.Errorf("send mailgun email: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("mailgun status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	diagnosticsFrom(ctx).record("http", started, err, fmt.Sprintf("POST %s status=%d", endpoint, resp.StatusCode))
+	if err != nil {
+		return fmt.Errorf("send mailgun email: %w", err)
+	}
+	log.Printf("[mailgun] email %q sent successfully to %s", message.Subject, recipient)
+	return nil
+}
+
+var (
+	_ Mailer     = (*MailgunMailer)(nil)
+	_ TestSender = (*MailgunMailer)(nil)
+)
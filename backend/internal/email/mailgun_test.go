@@ -0,0 +1,72 @@
+package email
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMailgunMailerPostsMessage(t *testing.T) {
+	var form map[string]string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, key, _ := r.BasicAuth()
+		if r.URL.Path != "/mg.kuppixel.pl/messages" || user != "api" || key != "key-123" {
+			t.Errorf("unexpected request %s user=%q key=%q", r.URL.Path, user, key)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form = map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		_, _ = w.Write([]byte(`{"id":"<1@mg>","message":"Queued. Thank you."}`))
+	}))
+	defer api.Close()
+
+	mailer, err := NewMailgunMailer(MailgunConfig{Domain: "mg.kuppixel.pl", APIKey: "key-123", FromEmail: "noreply@kuppixel.pl", APIBase: api.URL}, "en", nil)
+	if err != nil {
+		t.Fatalf("NewMailgunMailer() error = %v", err)
+	}
+	if err := mailer.SendVerificationEmail(context.Background(), "user@example.com", "https://kuppixel.pl/verify?token=abc"); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+	if form["to"] != "user@example.com" || form["subject"] != "Confirm your email address" || form["from"] != `"Kup Piksel" <noreply@kuppixel.pl>` {
+		t.Fatalf("unexpected form %v", form)
+	}
+	if !strings.Contains(form["text"], "https://kuppixel.pl/verify?token=abc") || !strings.Contains(form["html"], "Confirm account") {
+		t.Fatalf("unexpected bodies %v", form)
+	}
+}
+
+func TestMailgunMailerReportsAPIErrors(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden", http.StatusUnauthorized)
+	}))
+	defer api.Close()
+
+	mailer, err := NewMailgunMailer(MailgunConfig{Domain: "mg.kuppixel.pl", APIKey: "bad", FromEmail: "noreply@kuppixel.pl", APIBase: api.URL}, "pl", nil)
+	if err != nil {
+		t.Fatalf("NewMailgunMailer() error = %v", err)
+	}
+	var diag Diagnostics
+	if err := mailer.SendTestEmail(WithDiagnostics(context.Background(), &diag), "user@example.com"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+	if got := phaseStatuses(diag.Phases()); got != "http=failed" {
+		t.Fatalf("unexpected phases %q", got)
+	}
+}
+
+func TestMailgunConfigValidation(t *testing.T) {
+	cfg := MailgunConfig{Domain: " mg.kuppixel.pl ", FromEmail: "noreply@kuppixel.pl"}
+	cfg.Sanitize()
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected missing api key to be rejected")
+	}
+	if cfg.APIBase != DefaultMailgunAPIBase || cfg.FromName != "Kup Piksel" {
+		t.Fatalf("unexpected defaults %+v", cfg)
+	}
+}
@@ -0,0 +1,114 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/mail"
+	"strings"
+)
+
+// Notice is a free-form service message, such as an update about a takedown affecting the recipient's pixels.
+type Notice struct {
+	Subject string
+	Body    string
+}
+
+// NoticeSender is implemented by mailers that can deliver service notices.
+type NoticeSender interface {
+	SendNotice(ctx context.Context, recipient string, notice Notice) error
+}
+
+// noticeMessage wraps the notice body with the locale greeting and footer.
+func noticeMessage(locale localeContent, notice Notice) (Message, error) {
+	subject := strings.TrimSpace(notice.Subject)
+	body := strings.TrimSpace(notice.Body)
+	if subject == "" || body == "" {
+		return Message{}, errors.New("notice subject and body must not be empty")
+	}
+
+	var html strings.Builder
+	html.WriteString("<p>" + htmltemplate.HTMLEscapeString(locale.greeting) + "</p>\n")
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		escaped := htmltemplate.HTMLEscapeString(strings.TrimSpace(paragraph))
+		html.WriteString("<p>" + strings.ReplaceAll(escaped, "\n", "<br>") + "</p>\n")
+	}
+	html.WriteString("<p style=\"font-size:12px;color:#a1a1aa;\">" + htmltemplate.HTMLEscapeString(locale.footer) + "</p>\n")
+
+	return Message{
+		Subject: subject,
+		Text:    locale.greeting + "\n\n" + body + "\n\n" + locale.footer + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// SendNotice delivers a service notice over SMTP.
+func (m *SMTPMailer) SendNotice(ctx context.Context, recipient string, notice Notice) error {
+	if m == nil {
+		return errors.New("smtp mailer is nil")
+	}
+	recipient = strings.TrimSpace(recipient)
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	message, err := noticeMessage(m.locale, notice)
+	if err != nil {
+		return err
+	}
+
+	from := mail.Address{Name: m.config.FromName, Address: m.config.FromEmail}
+	payload, err := buildMessage(from, mail.Address{Address: recipient}, message)
+	if err != nil {
+		return err
+	}
+	if err := m.sendMail(ctx, m.config, m.auth, m.config.FromEmail, []string{recipient}, payload); err != nil {
+		return fmt.Errorf("send smtp email: %w", err)
+	}
+	log.Printf("[smtp] notice %q sent successfully to %s", message.Subject, recipient)
+	return nil
+}
+
+// SendNotice delivers a service notice through the Mailgun API.
+func (m *MailgunMailer) SendNotice(ctx context.Context, recipient string, notice Notice) error {
+	if m == nil {
+		return errors.New("mailgun mailer is nil")
+	}
+	message, err := noticeMessage(m.locale, notice)
+	if err != nil {
+		return err
+	}
+	return m.send(ctx, recipient, message)
+}
+
+// SendNotice logs the notice instead of delivering it.
+func (m *ConsoleMailer) SendNotice(ctx context.Context, recipient string, notice Notice) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	message, err := noticeMessage(m.locale, notice)
+	if err != nil {
+		return err
+	}
+	log.Printf("[email] To: %s | Subject: %s\n%s", strings.TrimSpace(recipient), message.Subject, message.Text)
+	return nil
+}
+
+// SendNotice tries each transport that supports notices until one delivers.
+func (c *Chain) SendNotice(ctx context.Context, recipient string, notice Notice) error {
+	return c.send(ctx, "notice", func(m Mailer) error {
+		sender, ok := m.(NoticeSender)
+		if !ok {
+			return errors.New("transport does not support notices")
+		}
+		return sender.SendNotice(ctx, recipient, notice)
+	})
+}
+
+var (
+	_ NoticeSender = (*SMTPMailer)(nil)
+	_ NoticeSender = (*MailgunMailer)(nil)
+	_ NoticeSender = (*ConsoleMailer)(nil)
+	_ NoticeSender = (*Chain)(nil)
+)
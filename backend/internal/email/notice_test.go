@@ -0,0 +1,37 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNoticeMessageEscapesBody(t *testing.T) {
+	message, err := noticeMessage(locales["pl"], Notice{Subject: "Zgłoszenie", Body: "Piksele <b>1-3</b> ukryte.\n\nDruga linia"})
+	if err != nil {
+		t.Fatalf("noticeMessage() error = %v", err)
+	}
+	if !strings.Contains(message.Text, "Piksele <b>1-3</b> ukryte.") || !strings.Contains(message.Text, locales["pl"].footer) {
+		t.Fatalf("unexpected text %q", message.Text)
+	}
+	if strings.Contains(message.HTML, "<b>") || !strings.Contains(message.HTML, "<p>Druga linia</p>") {
+		t.Fatalf("unexpected html %q", message.HTML)
+	}
+	if _, err := noticeMessage(locales["pl"], Notice{Subject: "x"}); err == nil {
+		t.Fatal("expected empty body to be rejected")
+	}
+}
+
+func TestChainSendNoticeSkipsTransportsWithoutNotices(t *testing.T) {
+	chain := NewChain(Transport{"stub", &stubMailer{}}, Transport{"console", NewConsoleMailer("", "pl")})
+	var attempts []string
+	chain.SetDeliveryHook(func(kind, transport string, err error) {
+		attempts = append(attempts, kind+":"+transport+":"+map[bool]string{true: "ok", false: "failed"}[err == nil])
+	})
+	if err := chain.SendNotice(context.Background(), "user@example.com", Notice{Subject: "Test", Body: "Body"}); err != nil {
+		t.Fatalf("SendNotice() error = %v", err)
+	}
+	if got := strings.Join(attempts, " "); got != "notice:stub:failed notice:console:ok" {
+		t.Fatalf("unexpected attempts %q", got)
+	}
+}
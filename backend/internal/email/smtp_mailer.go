@@ -9,11 +9,14 @@ import (
 	"io"
 	"log"
 	"mime"
+	"mime/multipart"
 	"net"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // SMTPConfig contains configuration required to send transactional emails via SMTP.
@@ -109,6 +112,7 @@ func LoadSMTPConfigFromEnv(getenv func(string) string) (*SMTPConfig, error) {
 type SMTPMailer struct {
 	config   SMTPConfig
 	auth     smtp.Auth
+	language string
 	locale   localeContent
 	sendMail func(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, from string, to []string, msg []byte) error
 }
@@ -126,9 +130,10 @@ func NewSMTPMailer(cfg SMTPConfig, language string) (*SMTPMailer, error) {
 	}
 
 	return &SMTPMailer{
-		config: cfg,
-		auth:   auth,
-		locale: resolveLocale(language),
+		config:   cfg,
+		auth:     auth,
+		language: resolveLanguage(language),
+		locale:   resolveLocale(language),
 		sendMail: func(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, from string, to []string, msg []byte) error {
 			return sendMailWithContext(ctx, cfg, auth, from, to, msg)
 		},
@@ -169,21 +174,14 @@ func (m *SMTPMailer) SendVerificationEmail(ctx context.Context, recipient, verif
 		to.Address,
 	)
 
-	subject := m.locale.verificationSubject
-	encodedSubject := mime.QEncoding.Encode("utf-8", subject)
-	body := fmt.Sprintf(m.locale.verificationBody, verificationLink)
-
-	var msg bytes.Buffer
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", from.String()))
-	msg.WriteString(fmt.Sprintf("To: %s\r\n", to.String()))
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", encodedSubject))
-	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
-
-	payload := msg.Bytes()
+	message, err := render(TemplateVerification, m.language, m.locale, verificationLink)
+	if err != nil {
+		return err
+	}
+	payload, err := buildMessage(from, to, message)
+	if err != nil {
+		return err
+	}
 	log.Printf("[smtp] sending email payload size=%d bytes", len(payload))
 
 	if err := m.sendMail(ctx, m.config, m.auth, m.config.FromEmail, []string{recipient}, payload); err != nil {
@@ -227,27 +225,83 @@ func (m *SMTPMailer) SendPasswordResetEmail(ctx context.Context, recipient, rese
 		to.Address,
 	)
 
-	subject := m.locale.resetSubject
-	encodedSubject := mime.QEncoding.Encode("utf-8", subject)
-	body := fmt.Sprintf(m.locale.resetBody, resetLink)
+	message, err := render(TemplatePasswordReset, m.language, m.locale, resetLink)
+	if err != nil {
+		return err
+	}
+	payload, err := buildMessage(from, to, message)
+	if err != nil {
+		return err
+	}
+	log.Printf("[smtp] sending email payload size=%d bytes", len(payload))
+
+	if err := m.sendMail(ctx, m.config, m.auth, m.config.FromEmail, []string{recipient}, payload); err != nil {
+		return fmt.Errorf("send smtp email: %w", err)
+	}
+	log.Printf("[smtp] password reset email sent successfully to %s", recipient)
+	return nil
+}
+
+// buildMessage encodes message as a multipart/alternative email with text and HTML parts.
+func buildMessage(from, to mail.Address, message Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "8bit")
+		w, err := parts.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
 
 	var msg bytes.Buffer
 	msg.WriteString(fmt.Sprintf("From: %s\r\n", from.String()))
 	msg.WriteString(fmt.Sprintf("To: %s\r\n", to.String()))
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", encodedSubject))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject)))
 	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
-	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q\r\n", parts.Boundary()))
 	msg.WriteString("\r\n")
-	msg.WriteString(body)
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
 
-	payload := msg.Bytes()
-	log.Printf("[smtp] sending email payload size=%d bytes", len(payload))
+// SendTestEmail delivers a short diagnostic message. Transport phases are recorded when ctx
+// carries Diagnostics.
+func (m *SMTPMailer) SendTestEmail(ctx context.Context, recipient string) error {
+	if m == nil {
+		return errors.New("smtp mailer is nil")
+	}
+	recipient = strings.TrimSpace(recipient)
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	from := mail.Address{Name: m.config.FromName, Address: m.config.FromEmail}
+	to := mail.Address{Address: recipient}
+	log.Printf("[smtp] preparing test email via %s from=%s to=%s", m.config.Address(), from.String(), to.Address)
 
+	payload, err := buildMessage(from, to, testMessage(m.locale))
+	if err != nil {
+		return err
+	}
 	if err := m.sendMail(ctx, m.config, m.auth, m.config.FromEmail, []string{recipient}, payload); err != nil {
 		return fmt.Errorf("send smtp email: %w", err)
 	}
-	log.Printf("[smtp] password reset email sent successfully to %s", recipient)
+	log.Printf("[smtp] test email sent successfully to %s", recipient)
 	return nil
 }
 
@@ -264,9 +318,11 @@ func isImplicitTLSPort(port int) bool {
 
 func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, from string, to []string, msg []byte) (err error) {
 	dialer := newNetDialer()
+	trace := diagnosticsFrom(ctx)
 
 	address := cfg.Address()
 	var conn net.Conn
+	started := time.Now()
 	if isImplicitTLSPort(cfg.Port) {
 		log.Printf("[smtp] dialing %s using implicit TLS", address)
 		tlsConfig := &tls.Config{ServerName: cfg.Host}
@@ -275,6 +331,7 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 		log.Printf("[smtp] dialing %s", address)
 		conn, err = dialer.DialContext(ctx, "tcp", address)
 	}
+	trace.record("connect", started, err, address)
 	if err != nil {
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return ctxErr
@@ -283,7 +340,9 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 	}
 	log.Printf("[smtp] connected to %s", address)
 
+	started = time.Now()
 	client, err := smtp.NewClient(conn, cfg.Host)
+	trace.record("greeting", started, err, "")
 	if err != nil {
 		_ = conn.Close()
 		if ctxErr := ctx.Err(); ctxErr != nil {
@@ -308,17 +367,23 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 			_ = client.Close()
 			return
 		}
+		started := time.Now()
 		if quitErr := client.Quit(); quitErr != nil && !errors.Is(quitErr, io.EOF) {
 			err = quitErr
 		}
+		trace.record("quit", started, err, "")
 	}()
 
 	if isImplicitTLSPort(cfg.Port) {
 		log.Printf("[smtp] implicit TLS already negotiated; skipping STARTTLS")
+		trace.record("tls", time.Now(), nil, "implicit TLS negotiated on connect")
 	} else if ok, _ := client.Extension("STARTTLS"); ok {
 		log.Printf("[smtp] attempting STARTTLS for host=%s", cfg.Host)
 		tlsConfig := &tls.Config{ServerName: cfg.Host}
-		if err = client.StartTLS(tlsConfig); err != nil {
+		started = time.Now()
+		err = client.StartTLS(tlsConfig)
+		trace.record("tls", started, err, "STARTTLS")
+		if err != nil {
 			if ctxErr := ctx.Err(); ctxErr != nil {
 				return ctxErr
 			}
@@ -327,12 +392,16 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 		log.Printf("[smtp] STARTTLS negotiation succeeded")
 	} else {
 		log.Printf("[smtp] server does not advertise STARTTLS; continuing without TLS upgrade")
+		trace.skip("tls", "server does not advertise STARTTLS")
 	}
 
 	if auth != nil {
 		if ok, _ := client.Extension("AUTH"); ok {
 			log.Printf("[smtp] authenticating as %s", cfg.Username)
-			if err = client.Auth(auth); err != nil {
+			started = time.Now()
+			err = client.Auth(auth)
+			trace.record("auth", started, err, cfg.Username)
+			if err != nil {
 				if ctxErr := ctx.Err(); ctxErr != nil {
 					return ctxErr
 				}
@@ -341,13 +410,17 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 			log.Printf("[smtp] authentication successful")
 		} else {
 			log.Printf("[smtp] server does not advertise AUTH; skipping authentication")
+			trace.skip("auth", "server does not advertise AUTH")
 		}
 	} else {
 		log.Printf("[smtp] no smtp auth configured; proceeding without authentication")
+		trace.skip("auth", "no credentials configured")
 	}
 
 	log.Printf("[smtp] MAIL FROM %s", from)
+	started = time.Now()
 	if err = client.Mail(from); err != nil {
+		trace.record("envelope", started, err, "")
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return ctxErr
 		}
@@ -357,6 +430,7 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 	for _, addr := range to {
 		log.Printf("[smtp] RCPT TO %s", addr)
 		if err = client.Rcpt(addr); err != nil {
+			trace.record("envelope", started, err, "")
 			if ctxErr := ctx.Err(); ctxErr != nil {
 				return ctxErr
 			}
@@ -364,9 +438,13 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 		}
 	}
 
+	trace.record("envelope", started, nil, fmt.Sprintf("from=%s rcpt=%d", from, len(to)))
+
 	log.Printf("[smtp] entering DATA phase")
+	started = time.Now()
 	wc, err := client.Data()
 	if err != nil {
+		trace.record("data", started, err, "")
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return ctxErr
 		}
@@ -375,13 +453,16 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 
 	if _, err = wc.Write(msg); err != nil {
 		_ = wc.Close()
+		trace.record("data", started, err, "")
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return ctxErr
 		}
 		return err
 	}
 
-	if err = wc.Close(); err != nil {
+	err = wc.Close()
+	trace.record("data", started, err, fmt.Sprintf("%d bytes", len(msg)))
+	if err != nil {
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return ctxErr
 		}
@@ -397,4 +478,7 @@ func sendMailWithContext(ctx context.Context, cfg SMTPConfig, auth smtp.Auth, fr
 	return nil
 }
 
-var _ Mailer = (*SMTPMailer)(nil)
+var (
+	_ Mailer     = (*SMTPMailer)(nil)
+	_ TestSender = (*SMTPMailer)(nil)
+)
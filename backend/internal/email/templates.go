@@ -0,0 +1,98 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template names accepted by Render.
+const (
+	TemplateVerification  = "verification"
+	TemplatePasswordReset = "password_reset"
+)
+
+//go:embed templates/layout.html templates/layout.txt
+var templateFiles embed.FS
+
+var (
+	htmlLayout = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/layout.html"))
+	textLayout = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/layout.txt"))
+)
+
+// Message is a rendered transactional email.
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type templateData struct {
+	Lang     string
+	Subject  string
+	Greeting string
+	Intro    string
+	Action   string
+	Ignore   string
+	Footer   string
+	Link     string
+}
+
+// Templates returns the names of the available email templates.
+func Templates() []string {
+	return []string{TemplateVerification, TemplatePasswordReset}
+}
+
+// Render fills the named template with link in the requested language.
+// Unlike the mailers, it rejects unknown languages instead of falling back to Polish.
+func Render(name, language, link string) (Message, error) {
+	lang := normalizeLanguage(language)
+	locale, ok := locales[lang]
+	if !ok {
+		return Message{}, fmt.Errorf("unsupported language %q", language)
+	}
+	return render(name, lang, locale, link)
+}
+
+func testMessage(locale localeContent) Message {
+	return Message{
+		Subject: locale.testSubject,
+		Text:    locale.testBody + "\n",
+		HTML:    "<p>" + htmltemplate.HTMLEscapeString(locale.testBody) + "</p>\n",
+	}
+}
+
+func render(name, lang string, locale localeContent, link string) (Message, error) {
+	data := templateData{
+		Lang:     lang,
+		Greeting: locale.greeting,
+		Footer:   locale.footer,
+		Link:     strings.TrimSpace(link),
+	}
+	switch name {
+	case TemplateVerification:
+		data.Subject = locale.verificationSubject
+		data.Intro = locale.verificationIntro
+		data.Action = locale.verificationAction
+		data.Ignore = locale.verificationIgnore
+	case TemplatePasswordReset:
+		data.Subject = locale.resetSubject
+		data.Intro = locale.resetIntro
+		data.Action = locale.resetAction
+		data.Ignore = locale.resetIgnore
+	default:
+		return Message{}, fmt.Errorf("unknown template %q", name)
+	}
+
+	var text, html bytes.Buffer
+	if err := textLayout.Execute(&text, data); err != nil {
+		return Message{}, fmt.Errorf("render %s text: %w", name, err)
+	}
+	if err := htmlLayout.Execute(&html, data); err != nil {
+		return Message{}, fmt.Errorf("render %s html: %w", name, err)
+	}
+	return Message{Subject: data.Subject, Text: text.String(), HTML: html.String()}, nil
+}
@@ -0,0 +1,57 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestRenderVerificationTemplate(t *testing.T) {
+	msg, err := Render(TemplateVerification, "EN", "https://kuppixel.pl/verify?token=a&b=<c>")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if msg.Subject != "Confirm your email address" {
+		t.Fatalf("unexpected subject %q", msg.Subject)
+	}
+	wantText := "Hello!\n\nClick the link below to confirm your Kup Piksel account:\nhttps://kuppixel.pl/verify?token=a&b=<c>\n\nIf you didn't create an account, please ignore this message.\n"
+	if msg.Text != wantText {
+		t.Fatalf("unexpected text body:\n%s", msg.Text)
+	}
+	if !strings.Contains(msg.HTML, `<html lang="en">`) || !strings.Contains(msg.HTML, "Confirm account") {
+		t.Fatalf("html body missing localized content:\n%s", msg.HTML)
+	}
+	if strings.Contains(msg.HTML, "<c>") {
+		t.Fatalf("html body must escape the link:\n%s", msg.HTML)
+	}
+}
+
+func TestRenderRejectsUnknownTemplateAndLanguage(t *testing.T) {
+	if _, err := Render("welcome", "pl", "https://kuppixel.pl"); err == nil {
+		t.Fatal("expected unknown template error")
+	}
+	if _, err := Render(TemplatePasswordReset, "de", "https://kuppixel.pl"); err == nil {
+		t.Fatal("expected unsupported language error")
+	}
+	msg, err := Render(TemplatePasswordReset, "", "https://kuppixel.pl/reset-password?token=x")
+	if err != nil || msg.Subject != "Zresetuj swoje hasło" {
+		t.Fatalf("empty language should default to Polish, got %q err=%v", msg.Subject, err)
+	}
+}
+
+func TestBuildMessageIncludesTextAndHTMLParts(t *testing.T) {
+	msg, err := Render(TemplatePasswordReset, "pl", "https://kuppixel.pl/reset-password?token=x")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	payload, err := buildMessage(mail.Address{Address: "noreply@kuppixel.pl"}, mail.Address{Address: "user@example.com"}, msg)
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	body := string(payload)
+	for _, want := range []string{"Content-Type: multipart/alternative; boundary=", "Content-Type: text/plain; charset=UTF-8", "Content-Type: text/html; charset=UTF-8", "Ustaw nowe hasło"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("payload missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package emailaddr canonicalizes email addresses so that aliases of one mailbox map to one account.
+package emailaddr
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for strings that are not a local@domain address.
+var ErrInvalid = errors.New("invalid email address")
+
+// Policy selects the optional canonicalization rules. Lowercasing and IDN to punycode
+// conversion of the domain are always applied.
+type Policy struct {
+	// ProviderRules applies provider-specific aliasing: Gmail ignores dots and +tags in the
+	// local part, and googlemail.com is the same mailbox as gmail.com.
+	ProviderRules bool
+	// StripPlusAliases drops a "+tag" suffix from the local part for every domain.
+	StripPlusAliases bool
+}
+
+var gmailDomains = map[string]bool{"gmail.com": true, "googlemail.com": true}
+
+// Normalize returns the canonical form of address under the policy.
+func (p Policy) Normalize(address string) (string, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	at := strings.LastIndexByte(address, '@')
+	if at <= 0 || at == len(address)-1 {
+		return "", ErrInvalid
+	}
+	local, domain := address[:at], strings.TrimSuffix(address[at+1:], ".")
+
+	domain, err := toASCII(domain)
+	if err != nil {
+		return "", err
+	}
+
+	gmail := p.ProviderRules && gmailDomains[domain]
+	if gmail || p.StripPlusAliases {
+		if plus := strings.IndexByte(local, '+'); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	if gmail {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	if local == "" {
+		return "", ErrInvalid
+	}
+	return local + "@" + domain, nil
+}
+
+// Legacy is the normalization used before policies existed: trimmed and lowercased. Lookups fall
+// back to it so accounts created earlier stay reachable.
+func Legacy(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// toASCII converts every non-ASCII label of domain to its punycode ("xn--") form.
+func toASCII(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", ErrInvalid
+		}
+		ascii, err := encodeLabel(label)
+		if err != nil {
+			return "", err
+		}
+		labels[i] = ascii
+	}
+	return strings.Join(labels, "."), nil
+}
@@ -0,0 +1,35 @@
+package emailaddr
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		policy Policy
+		in     string
+		want   string
+	}{
+		{Policy{}, "  User@Example.COM ", "user@example.com"},
+		{Policy{}, "j.doe+promo@gmail.com", "j.doe+promo@gmail.com"},
+		{Policy{ProviderRules: true}, "J.Doe+promo@GoogleMail.com", "jdoe@gmail.com"},
+		{Policy{ProviderRules: true}, "j.doe+promo@example.com", "j.doe+promo@example.com"},
+		{Policy{StripPlusAliases: true}, "user+1@example.com", "user@example.com"},
+		{Policy{StripPlusAliases: true}, "+tag@example.com", "+tag@example.com"},
+		{Policy{}, "jan@Bücher.example", "jan@xn--bcher-kva.example"},
+		{Policy{}, "jan@münchen.de.", "jan@xn--mnchen-3ya.de"},
+		{Policy{}, "jan@例え.テスト", "jan@xn--r8jz45g.xn--zckzah"},
+	}
+	for _, tc := range cases {
+		got, err := tc.policy.Normalize(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("%+v.Normalize(%q) = %q, %v; want %q", tc.policy, tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestNormalizeRejectsInvalidAddresses(t *testing.T) {
+	for _, in := range []string{"", "user", "@example.com", "user@", "user@example..com", ".@gmail.com"} {
+		if got, err := (Policy{ProviderRules: true}).Normalize(in); err == nil {
+			t.Errorf("Normalize(%q) = %q, expected error", in, got)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package fcm sends push notifications through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating with a Google service-account key.
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAPIBase is the FCM v1 endpoint.
+	DefaultAPIBase = "https://fcm.googleapis.com/v1"
+
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+	messagingScope  = "https://www.googleapis.com/auth/firebase.messaging"
+	assertionTTL    = time.Hour
+	// tokenRefreshMargin renews the access token this long before it expires.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// ErrUnregistered reports a device token FCM no longer accepts; it should be forgotten.
+var ErrUnregistered = errors.New("device token is no longer registered")
+
+// ServiceAccount holds the fields of a Google service-account JSON key that FCM needs.
+type ServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadServiceAccount reads a service-account key file.
+func LoadServiceAccount(path string) (ServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ServiceAccount{}, fmt.Errorf("read service account key: %w", err)
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return ServiceAccount{}, fmt.Errorf("decode service account key: %w", err)
+	}
+	return account, nil
+}
+
+// Message is a notification shown on the device. Data is delivered to the app alongside it.
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Client sends messages for one Firebase project. It is safe for concurrent use.
+type Client struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	apiBase     string
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewClient validates account. apiBase overrides DefaultAPIBase when not empty, mainly for tests.
+// httpClient sends the requests; nil selects a plain client with a 15 second timeout.
+func NewClient(account ServiceAccount, apiBase string, httpClient *http.Client) (*Client, error) {
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("service account key must contain project_id, client_email and private_key")
+	}
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	tokenURI := account.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultTokenURI
+	}
+	apiBase = strings.TrimRight(strings.TrimSpace(apiBase), "/")
+	if apiBase == "" {
+		apiBase = DefaultAPIBase
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{
+		projectID:   account.ProjectID,
+		clientEmail: account.ClientEmail,
+		tokenURI:    tokenURI,
+		apiBase:     apiBase,
+		key:         key,
+		client:      httpClient,
+	}, nil
+}
+
+func parsePrivateKey(raw string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("service account private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, pkcs1Err := x509.ParsePKCS1PrivateKey(block.Bytes); pkcs1Err == nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("parse service account private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+type sendRequest struct {
+	Message sendMessage `json:"message"`
+}
+
+type sendMessage struct {
+	Token        string            `json:"token"`
+	Notification notification      `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// Send delivers msg to one device. It returns ErrUnregistered when FCM reports the token as
+// unregistered.
+func (c *Client) Send(ctx context.Context, deviceToken string, msg Message) error {
+	accessToken, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(sendRequest{Message: sendMessage{
+		Token:        deviceToken,
+		Notification: notification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+	}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/projects/%s/messages:send", c.apiBase, c.projectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fcm send: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil
+	}
+
+	var failure errorResponse
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.mu.Lock()
+		c.accessToken = ""
+		c.mu.Unlock()
+	}
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return fmt.Errorf("%w: %s", ErrUnregistered, failure.Error.Message)
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrUnregistered, failure.Error.Message)
+	}
+	return fmt.Errorf("fcm send failed (status %d): %s %s", resp.StatusCode, failure.Error.Status, failure.Error.Message)
+}
+
+// token returns a cached OAuth access token, exchanging a signed JWT for a new one when needed.
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expiresAt.Add(-tokenRefreshMargin)) {
+		return c.accessToken, nil
+	}
+
+	assertion, err := c.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return "", fmt.Errorf("fcm token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("fcm token request failed (status %d)", resp.StatusCode)
+	}
+	c.accessToken = result.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	return c.accessToken, nil
+}
+
+// assertion builds the RS256-signed JWT the token endpoint exchanges for an access token.
+func (c *Client) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   c.clientEmail,
+		"scope": messagingScope,
+		"aud":   c.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(assertionTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign fcm assertion: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
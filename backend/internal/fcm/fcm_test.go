@@ -0,0 +1,110 @@
+package fcm
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type fakeFCM struct {
+	mu           sync.Mutex
+	tokenCalls   int
+	sent         []sendMessage
+	unknownToken string
+}
+
+func (f *fakeFCM) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.Form.Get("assertion") == "" {
+			t.Errorf("unexpected token request %v (err %v)", r.Form, err)
+		}
+		f.mu.Lock()
+		f.tokenCalls++
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{"access_token":"access-1","expires_in":3600}`))
+	})
+	mux.HandleFunc("/projects/kup-piksel/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		var req sendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode message: %v", err)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if req.Message.Token == f.unknownToken {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","message":"Requested entity was not found.","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			return
+		}
+		f.sent = append(f.sent, req.Message)
+		_, _ = w.Write([]byte(`{"name":"projects/kup-piksel/messages/1"}`))
+	})
+	return mux
+}
+
+func newTestClient(t *testing.T, api *fakeFCM) *Client {
+	t.Helper()
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	client, err := NewClient(ServiceAccount{
+		ProjectID:   "kup-piksel",
+		ClientEmail: "push@kup-piksel.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    srv.URL + "/token",
+	}, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
+
+func TestSendReusesAccessToken(t *testing.T) {
+	api := &fakeFCM{unknownToken: "gone"}
+	client := newTestClient(t, api)
+
+	for i := 0; i < 2; i++ {
+		if err := client.Send(context.Background(), "device-1", Message{Title: "Zakup", Body: "Kupiono 2 piksele", Data: map[string]string{"event": "purchase"}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := client.Send(context.Background(), "gone", Message{Title: "Zakup", Body: "x"}); !errors.Is(err, ErrUnregistered) {
+		t.Fatalf("expected ErrUnregistered, got %v", err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.tokenCalls != 1 {
+		t.Fatalf("expected one token exchange, got %d", api.tokenCalls)
+	}
+	if len(api.sent) != 2 || api.sent[0].Token != "device-1" || api.sent[0].Notification.Title != "Zakup" || api.sent[0].Data["event"] != "purchase" {
+		t.Fatalf("unexpected messages %+v", api.sent)
+	}
+}
+
+func TestNewClientRejectsIncompleteKey(t *testing.T) {
+	if _, err := NewClient(ServiceAccount{ProjectID: "p", ClientEmail: "e"}, "", nil); err == nil {
+		t.Fatal("expected a key without private_key to be rejected")
+	}
+	if _, err := NewClient(ServiceAccount{ProjectID: "p", ClientEmail: "e", PrivateKey: "not pem"}, "", nil); err == nil {
+		t.Fatal("expected a malformed private_key to be rejected")
+	}
+}
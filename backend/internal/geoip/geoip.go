@@ -0,0 +1,105 @@
+// Package geoip maps IP addresses to ISO 3166-1 alpha-2 country codes using a range database
+// in the DB-IP / IP2Location Lite CSV layout ("first_ip,last_ip,country").
+package geoip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	first   netip.Addr
+	last    netip.Addr
+	country string
+}
+
+// DB is an immutable, sorted set of IP ranges.
+type DB struct {
+	ranges []ipRange
+}
+
+// Open loads the CSV database at path.
+func Open(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load parses a CSV database. Lines starting with # and rows with an unparsable address are rejected
+// with the offending line number so a truncated download is noticed at startup.
+func Load(r io.Reader) (*DB, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var ranges []ipRange
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("geoip: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return nil, fmt.Errorf("geoip: line %d: expected first_ip,last_ip,country", line)
+		}
+		first, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("geoip: line %d: %w", line, err)
+		}
+		last, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("geoip: line %d: %w", line, err)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return nil, fmt.Errorf("geoip: line %d: invalid range %s-%s", line, first, last)
+		}
+		ranges = append(ranges, ipRange{first: first, last: last, country: strings.ToUpper(strings.TrimSpace(record[2]))})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+	return &DB{ranges: ranges}, nil
+}
+
+// Len returns the number of ranges in the database.
+func (db *DB) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.ranges)
+}
+
+// Country returns the country of ip, or "" when ip is invalid or not covered. A nil DB knows nothing.
+func (db *DB) Country(ip string) string {
+	if db == nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	// Find the last range starting at or before addr.
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].first) }) - 1
+	if i < 0 {
+		return ""
+	}
+	r := db.ranges[i]
+	if r.first.Is4() != addr.Is4() || r.last.Less(addr) {
+		return ""
+	}
+	return r.country
+}
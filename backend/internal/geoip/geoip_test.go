@@ -0,0 +1,59 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleDB = `# first_ip,last_ip,country
+5.172.0.0,5.175.255.255,PL
+1.0.0.0,1.0.0.255,AU
+2a02:a300::,2a02:a3ff:ffff:ffff:ffff:ffff:ffff:ffff,PL
+"2001:db8::","2001:db8::ffff",ru
+`
+
+func TestCountryLookup(t *testing.T) {
+	db, err := Load(strings.NewReader(sampleDB))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for ip, want := range map[string]string{
+		"5.173.1.2":            "PL",
+		"::ffff:5.175.255.255": "PL",
+		"1.0.0.7":              "AU",
+		"1.0.1.0":              "",
+		"2a02:a310::1":         "PL",
+		"2001:db8::10":         "RU",
+		"2001:db8::1:0":        "",
+		"0.0.0.1":              "",
+		"not-an-ip":            "",
+		"255.255.255.255":      "",
+	} {
+		if got := db.Country(ip); got != want {
+			t.Errorf("Country(%q) = %q, want %q", ip, got, want)
+		}
+	}
+	if db.Len() != 4 {
+		t.Fatalf("Len() = %d", db.Len())
+	}
+}
+
+func TestLoadRejectsMalformedRows(t *testing.T) {
+	for _, input := range []string{
+		"1.0.0.0,1.0.0.255\n",
+		"1.0.0.x,1.0.0.255,AU\n",
+		"1.0.0.255,1.0.0.0,AU\n",
+		"1.0.0.0,2001:db8::,AU\n",
+	} {
+		if _, err := Load(strings.NewReader(input)); err == nil {
+			t.Errorf("expected %q to be rejected", input)
+		}
+	}
+}
+
+func TestNilDB(t *testing.T) {
+	var db *DB
+	if db.Country("1.2.3.4") != "" || db.Len() != 0 {
+		t.Fatal("nil DB must return nothing")
+	}
+}
@@ -15,10 +15,58 @@ type HandlerFunc func(*Context)
 
 type H map[string]interface{}
 
+// Param is a single URL parameter captured from a route pattern such as /users/:id.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is the list of URL parameters captured for a request.
+type Params []Param
+
+// ByName returns the value of the first parameter with the given key.
+func (ps Params) ByName(name string) string {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
 type Context struct {
 	Writer  http.ResponseWriter
 	Request *http.Request
-	params  map[string]string
+	Params  Params
+
+	fullPath string
+	handlers []HandlerFunc
+	index    int
+}
+
+const abortIndex = 1 << 30
+
+// Next runs the remaining handlers of the chain. Middleware calls it to wrap the handlers after it.
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// FullPath returns the matched route pattern, e.g. "/api/admin/maintenance/:id", or "" when no route matched.
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+// Abort prevents the handlers after the current one from running.
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
 }
 
 func (c *Context) JSON(status int, body interface{}) {
@@ -48,7 +96,22 @@ func (c *Context) ShouldBindJSON(out interface{}) error {
 }
 
 func (c *Context) Param(name string) string {
-	return c.params[name]
+	return c.Params.ByName(name)
+}
+
+// Query returns the first value of the named URL query parameter.
+func (c *Context) Query(key string) string {
+	if c.Request == nil || c.Request.URL == nil {
+		return ""
+	}
+	return c.Request.URL.Query().Get(key)
+}
+
+// Data writes raw bytes with the provided content type.
+func (c *Context) Data(status int, contentType string, data []byte) {
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.WriteHeader(status)
+	_, _ = c.Writer.Write(data)
 }
 
 type route struct {
@@ -58,16 +121,18 @@ type route struct {
 }
 
 type Engine struct {
-	routes  []route
-	noRoute HandlerFunc
+	routes     []route
+	noRoute    HandlerFunc
+	middleware []HandlerFunc
 }
 
 func Default() *Engine {
 	return &Engine{}
 }
 
-func (e *Engine) Use(_ ...HandlerFunc) {
-	// middleware stub
+// Use registers middleware that runs, in order, before every handler including NoRoute.
+func (e *Engine) Use(middleware ...HandlerFunc) {
+	e.middleware = append(e.middleware, middleware...)
 }
 
 func (e *Engine) addRoute(method, path string, handler HandlerFunc) {
@@ -82,6 +147,14 @@ func (e *Engine) POST(path string, handler HandlerFunc) {
 	e.addRoute(http.MethodPost, path, handler)
 }
 
+func (e *Engine) PUT(path string, handler HandlerFunc) {
+	e.addRoute(http.MethodPut, path, handler)
+}
+
+func (e *Engine) DELETE(path string, handler HandlerFunc) {
+	e.addRoute(http.MethodDelete, path, handler)
+}
+
 func (e *Engine) Static(relativePath, root string) {
 	fs := http.FileServer(http.Dir(root))
 	e.GET(relativePath+"/*filepath", func(c *Context) {
@@ -104,37 +177,71 @@ func (e *Engine) Run(addr string) error {
 	return http.ListenAndServe(addr, e)
 }
 
-func (e *Engine) match(method, path string) (HandlerFunc, map[string]string) {
+func (e *Engine) match(method, path string) (HandlerFunc, Params, string) {
+	// Static routes take precedence over parameterised ones regardless of registration order.
+	for _, r := range e.routes {
+		if r.method == method && r.path == path {
+			return r.handler, Params{}, r.path
+		}
+	}
 	for _, r := range e.routes {
 		if r.method != method {
 			continue
 		}
-		if r.path == path {
-			return r.handler, map[string]string{}
-		}
-		if strings.Contains(r.path, "*") {
-			prefix := strings.Split(r.path, "*")[0]
+		if idx := strings.Index(r.path, "*"); idx >= 0 {
+			prefix := r.path[:idx]
 			if strings.HasPrefix(path, prefix) {
-				return r.handler, map[string]string{"filepath": strings.TrimPrefix(path, prefix)}
+				return r.handler, Params{{Key: r.path[idx+1:], Value: strings.TrimPrefix(path, prefix)}}, r.path
+			}
+			continue
+		}
+		if params, ok := matchSegments(r.path, path); ok {
+			return r.handler, params, r.path
+		}
+	}
+	return nil, nil, ""
+}
+
+// matchSegments matches patterns containing :name segments against a concrete path.
+func matchSegments(pattern, path string) (Params, bool) {
+	if !strings.Contains(pattern, ":") {
+		return nil, false
+	}
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	var params Params
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, ":") {
+			if pathParts[i] == "" {
+				return nil, false
 			}
+			params = append(params, Param{Key: part[1:], Value: pathParts[i]})
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
 		}
 	}
-	return nil, nil
+	return params, true
 }
 
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	handler, params := e.match(r.Method, r.URL.Path)
+	handler, params, fullPath := e.match(r.Method, r.URL.Path)
 	if handler == nil {
-		if e.noRoute != nil {
-			ctx := &Context{Writer: w, Request: r, params: map[string]string{}}
-			e.noRoute(ctx)
-			return
-		}
-		http.NotFound(w, r)
-		return
+		handler = e.noRoute
 	}
-	ctx := &Context{Writer: w, Request: r, params: params}
-	handler(ctx)
+	if handler == nil {
+		handler = func(c *Context) { http.NotFound(c.Writer, c.Request) }
+	}
+	chain := make([]HandlerFunc, 0, len(e.middleware)+1)
+	chain = append(chain, e.middleware...)
+	chain = append(chain, handler)
+
+	ctx := &Context{Writer: w, Request: r, Params: params, fullPath: fullPath, handlers: chain, index: -1}
+	ctx.Next()
 }
 
 func ServeFile(c *Context, filePath string) {
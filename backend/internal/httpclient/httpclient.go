@@ -0,0 +1,284 @@
+// Package httpclient builds the clients used to call external services. Every call is bounded by
+// a timeout, idempotent requests that fail with a network error or a gateway status are retried a
+// few times, and a destination that keeps failing is cut off for a while instead of holding up
+// requests. Calls, retries and latency are counted per destination.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultRetryDelay       = 200 * time.Millisecond
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = 30 * time.Second
+	// hostBreakerSweepSize is how many per-host breakers may exist before the idle ones are
+	// dropped.
+	hostBreakerSweepSize = 10000
+)
+
+// ErrCircuitOpen is returned without calling the destination while its circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Config tunes every client of a Factory. Zero values fall back to the defaults; MaxRetries 0
+// disables retries.
+type Config struct {
+	// Timeout bounds a call including its retries.
+	Timeout time.Duration
+	// MaxRetries is how often an idempotent request is repeated after a retryable failure.
+	MaxRetries int
+	// RetryDelay is the pause before the first retry; it doubles for each further one.
+	RetryDelay time.Duration
+	// FailureThreshold is how many consecutive failures open the breaker of a destination.
+	FailureThreshold int
+	// OpenDuration is how long an open breaker rejects calls before one trial call is let through.
+	OpenDuration time.Duration
+}
+
+// Factory hands out clients that share its configuration and metrics registry. Clients for the
+// same destination share one circuit breaker.
+type Factory struct {
+	cfg      Config
+	registry *metrics.Registry
+
+	mu           sync.Mutex
+	breakers     map[string]*breaker
+	hostBreakers map[string]*breaker
+}
+
+func NewFactory(cfg Config, registry *metrics.Registry) *Factory {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultRetryDelay
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = defaultFailureThreshold
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = defaultOpenDuration
+	}
+	return &Factory{cfg: cfg, registry: registry, breakers: make(map[string]*breaker), hostBreakers: make(map[string]*breaker)}
+}
+
+// Client returns a client for calls to destination, a short name such as "turnstile" used in
+// metrics and logs. base performs the actual calls; nil uses http.DefaultTransport.
+func (f *Factory) Client(destination string, base http.RoundTripper) *http.Client {
+	f.mu.Lock()
+	b, ok := f.breakers[destination]
+	if !ok {
+		b = &breaker{destination: destination, threshold: f.cfg.FailureThreshold, openFor: f.cfg.OpenDuration}
+		f.breakers[destination] = b
+	}
+	f.mu.Unlock()
+	return &http.Client{
+		Timeout:   f.cfg.Timeout,
+		Transport: f.transport(destination, base, func(*http.Request) *breaker { return b }),
+	}
+}
+
+// HostTransport returns a transport with the retries and metrics of Client for callers that reach
+// many hosts under one destination, such as user-supplied links, and keep their own timeout and
+// redirect policy. Each host gets its own breaker, so one failing target does not cut off the rest.
+func (f *Factory) HostTransport(destination string, base http.RoundTripper) http.RoundTripper {
+	return f.transport(destination, base, func(req *http.Request) *breaker { return f.hostBreaker(destination, req.URL.Host) })
+}
+
+func (f *Factory) transport(destination string, base http.RoundTripper, breakerFor func(*http.Request) *breaker) *transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		destination: destination,
+		base:        base,
+		cfg:         f.cfg,
+		breakerFor:  breakerFor,
+		registry:    f.registry,
+	}
+}
+
+// hostBreaker returns the breaker of host under destination. Breakers without failures are
+// dropped once there are many, as a new one behaves the same.
+func (f *Factory) hostBreaker(destination, host string) *breaker {
+	key := destination + " " + host
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if b, ok := f.hostBreakers[key]; ok {
+		return b
+	}
+	if len(f.hostBreakers) >= hostBreakerSweepSize {
+		for k, b := range f.hostBreakers {
+			if b.idle() {
+				delete(f.hostBreakers, k)
+			}
+		}
+	}
+	b := &breaker{destination: destination + " host=" + host, threshold: f.cfg.FailureThreshold, openFor: f.cfg.OpenDuration}
+	f.hostBreakers[key] = b
+	return b
+}
+
+type transport struct {
+	destination string
+	base        http.RoundTripper
+	cfg         Config
+	breakerFor  func(*http.Request) *breaker
+	registry    *metrics.Registry
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.breakerFor(req)
+	if !b.allow(time.Now()) {
+		t.count("circuit_open")
+		return nil, fmt.Errorf("%s: %w", t.destination, ErrCircuitOpen)
+	}
+	resp, err := t.send(req)
+	b.record(err == nil && resp.StatusCode < http.StatusInternalServerError, time.Now())
+	return resp, err
+}
+
+// send makes the call and its retries and returns the last outcome.
+func (t *transport) send(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if replayable(req) {
+		retries = t.cfg.MaxRetries
+	}
+	delay := t.cfg.RetryDelay
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		started := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		t.registry.Counter("kuppixel_upstream_duration_milliseconds_total", "Time spent waiting for external services, per destination.", "destination", t.destination).Add(time.Since(started).Milliseconds())
+		if err != nil {
+			t.count("error")
+		} else {
+			t.count(strconv.Itoa(resp.StatusCode/100) + "xx")
+		}
+		if attempt >= retries || !retryable(req.Context(), resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		t.registry.Counter("kuppixel_upstream_retries_total", "Calls to external services repeated after a failure, per destination.", "destination", t.destination).Inc()
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+func (t *transport) count(result string) {
+	t.registry.Counter("kuppixel_upstream_requests_total", "Calls to external services per destination and result.", "destination", t.destination, "result", result).Inc()
+}
+
+// replayable reports whether req may be sent again: like net/http, only idempotent methods or
+// requests carrying an idempotency key, and only when the body can be rewound.
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// retryable reports whether a failed attempt is worth repeating: network errors while the caller
+// still waits, and gateway statuses that usually pass.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// breaker opens after threshold consecutive failures. Once openFor has passed it lets a single
+// trial call through: success closes it again, failure keeps it open for another openFor.
+type breaker struct {
+	destination string
+	threshold   int
+	openFor     time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// idle reports whether b is closed without recent failures.
+func (b *breaker) idle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures == 0 && !b.trial
+}
+
+func (b *breaker) record(success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if success {
+		if b.failures >= b.threshold {
+			log.Printf("httpclient: destination=%s circuit closed after %d failures", b.destination, b.failures)
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.openFor)
+		if b.failures == b.threshold {
+			log.Printf("httpclient: destination=%s circuit opened for %s after %d failures", b.destination, b.openFor, b.failures)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	registry := metrics.NewRegistry()
+	client := NewFactory(Config{MaxRetries: 2, RetryDelay: time.Millisecond}, registry).Client("test", nil)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" || calls.Load() != 3 {
+		t.Fatalf("expected the body to be resent until success, got %d %q after %d calls", resp.StatusCode, body, calls.Load())
+	}
+	if got := registry.Counter("kuppixel_upstream_retries_total", "", "destination", "test").Value(); got != 2 {
+		t.Fatalf("expected 2 retries to be counted, got %d", got)
+	}
+
+	calls.Store(0)
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("expected a POST without idempotency key to be sent once, got %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
+
+type failingTransport struct {
+	calls atomic.Int32
+	fail  atomic.Bool
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls.Add(1)
+	if f.fail.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	base := &failingTransport{}
+	base.fail.Store(true)
+	factory := NewFactory(Config{FailureThreshold: 2, OpenDuration: 50 * time.Millisecond}, nil)
+	client := factory.Client("flaky", base)
+	get := func() error {
+		resp, err := client.Get("http://flaky.example/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected call %d to reach the failing destination, got %v", i, err)
+		}
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) || base.calls.Load() != 2 {
+		t.Fatalf("expected the open breaker to reject without calling, got %v after %d calls", err, base.calls.Load())
+	}
+	// Clients for the same destination share the breaker.
+	if _, err := factory.Client("flaky", base).Get("http://flaky.example/"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a second client to see the open breaker, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	base.fail.Store(false)
+	if err := get(); err != nil {
+		t.Fatalf("expected the trial call to go through, got %v", err)
+	}
+	if err := get(); err != nil || base.calls.Load() != 4 {
+		t.Fatalf("expected the breaker to close after a successful trial, got %v after %d calls", err, base.calls.Load())
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestHostTransportKeepsBreakersPerHost(t *testing.T) {
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "dead.example" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	factory := NewFactory(Config{FailureThreshold: 2, OpenDuration: time.Minute}, nil)
+	client := &http.Client{Transport: factory.HostTransport("webhook", base)}
+	get := func(host string) error {
+		resp, err := client.Get("http://" + host + "/")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		_ = get("dead.example")
+	}
+	if err := get("dead.example"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the failing host to be cut off, got %v", err)
+	}
+	if err := get("alive.example"); err != nil {
+		t.Fatalf("expected another host of the destination to be reached, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package jobs runs periodic background tasks such as heartbeats and cleanups, and queues of
+// one-off tasks too slow to finish within a request.
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Runner runs every registered job once at start and then at its interval until the
+// context passed to Start is cancelled. A job never overlaps with itself.
+type Runner struct {
+	mu      sync.Mutex
+	jobs    []job
+	queues  []*Queue
+	started bool
+	wg      sync.WaitGroup
+}
+
+func NewRunner() *Runner {
+	return &Runner{}
+}
+
+// Add registers a job. Jobs added after Start are ignored.
+func (r *Runner) Add(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		log.Printf("jobs: %s ignored, interval must be positive", name)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		log.Printf("jobs: %s ignored, runner already started", name)
+		return
+	}
+	r.jobs = append(r.jobs, job{name: name, interval: interval, run: run})
+}
+
+// AddQueue registers a queue whose workers run alongside the jobs. Queues added after Start are ignored.
+func (r *Runner) AddQueue(q *Queue) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		log.Printf("jobs: queue ignored, runner already started")
+		return
+	}
+	r.queues = append(r.queues, q)
+}
+
+// Start launches all registered jobs and queue workers in the background.
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, j)
+	}
+	for _, q := range r.queues {
+		q.Start(ctx)
+	}
+}
+
+// Wait blocks until all jobs and queue workers stopped after their context was cancelled.
+func (r *Runner) Wait() {
+	r.wg.Wait()
+	for _, q := range r.queues {
+		q.Wait()
+	}
+}
+
+func (r *Runner) loop(ctx context.Context, j job) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		runOnce(ctx, j)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func runOnce(ctx context.Context, j job) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("jobs: %s panicked: %v", j.name, recovered)
+		}
+	}()
+	if err := j.run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("jobs: %s failed: %v", j.name, err)
+	}
+}
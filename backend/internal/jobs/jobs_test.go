@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunnerRunsJobsUntilCancelled(t *testing.T) {
+	var fast, failing atomic.Int32
+	runner := NewRunner()
+	runner.Add("fast", time.Millisecond, func(context.Context) error {
+		fast.Add(1)
+		return nil
+	})
+	runner.Add("failing", time.Millisecond, func(context.Context) error {
+		if failing.Add(1) == 1 {
+			panic("boom")
+		}
+		return errors.New("still broken")
+	})
+	runner.Add("invalid", 0, func(context.Context) error {
+		t.Error("job with zero interval must not run")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for fast.Load() < 3 || failing.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not keep running: fast=%d failing=%d", fast.Load(), failing.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	runner.Wait()
+
+	stopped := fast.Load()
+	time.Sleep(10 * time.Millisecond)
+	if fast.Load() != stopped {
+		t.Fatal("job kept running after cancellation")
+	}
+}
+
+func TestJobsAddedAfterStartAreIgnored(t *testing.T) {
+	runner := NewRunner()
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	runner.Add("late", time.Millisecond, func(context.Context) error {
+		t.Error("late job must not run")
+		return nil
+	})
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	runner.Wait()
+}
@@ -0,0 +1,185 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Task states reported by a Queue.
+const (
+	TaskQueued    = "queued"
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+)
+
+// ErrQueueFull is returned by Submit when no more tasks can wait for a worker.
+var ErrQueueFull = errors.New("job queue is full")
+
+// TaskFunc performs a one-off task; its result is kept for polling.
+type TaskFunc func(ctx context.Context) (any, error)
+
+// Task is a snapshot of a one-off task submitted to a Queue.
+type Task struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	OwnerID    int64      `json:"-"`
+	Status     string     `json:"status"`
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type queuedTask struct {
+	Task
+	run TaskFunc
+}
+
+// Queue runs one-off tasks on a fixed number of workers and remembers their outcome until
+// pruned. Tasks live in memory only, so unfinished ones are lost on restart.
+type Queue struct {
+	mu      sync.Mutex
+	tasks   map[string]*queuedTask
+	pending chan *queuedTask
+	workers int
+	started bool
+	wg      sync.WaitGroup
+	now     func() time.Time
+}
+
+// NewQueue returns a queue with the given number of workers holding at most capacity waiting tasks.
+func NewQueue(workers, capacity int) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Queue{
+		tasks:   make(map[string]*queuedTask),
+		pending: make(chan *queuedTask, capacity),
+		workers: workers,
+		now:     time.Now,
+	}
+}
+
+// Submit queues run and returns the task as created.
+func (q *Queue) Submit(kind string, ownerID int64, run TaskFunc) (Task, error) {
+	id, err := newTaskID()
+	if err != nil {
+		return Task{}, err
+	}
+	task := &queuedTask{
+		Task: Task{ID: id, Kind: kind, OwnerID: ownerID, Status: TaskQueued, CreatedAt: q.now().UTC()},
+		run:  run,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	select {
+	case q.pending <- task:
+	default:
+		return Task{}, ErrQueueFull
+	}
+	q.tasks[id] = task
+	return task.Task, nil
+}
+
+// Get returns the current state of a task.
+func (q *Queue) Get(id string) (Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	task, ok := q.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return task.Task, true
+}
+
+// Prune forgets finished tasks older than maxAge and returns how many were removed.
+func (q *Queue) Prune(maxAge time.Duration) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cutoff := q.now().Add(-maxAge)
+	removed := 0
+	for id, task := range q.tasks {
+		if task.FinishedAt != nil && task.FinishedAt.Before(cutoff) {
+			delete(q.tasks, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Start launches the workers; they stop once ctx is cancelled.
+func (q *Queue) Start(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.started {
+		return
+	}
+	q.started = true
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+// Wait blocks until all workers stopped after their context was cancelled.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-q.pending:
+			q.execute(ctx, task)
+		}
+	}
+}
+
+func (q *Queue) execute(ctx context.Context, task *queuedTask) {
+	q.mu.Lock()
+	started := q.now().UTC()
+	task.Status, task.StartedAt = TaskRunning, &started
+	q.mu.Unlock()
+
+	result, err := runTask(ctx, task)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := q.now().UTC()
+	task.FinishedAt, task.Result = &finished, result
+	if err != nil {
+		task.Status, task.Error = TaskFailed, err.Error()
+		log.Printf("jobs: task %s (%s) failed: %v", task.ID, task.Kind, err)
+		return
+	}
+	task.Status = TaskSucceeded
+}
+
+func runTask(ctx context.Context, task *queuedTask) (result any, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return task.run(ctx)
+}
+
+func newTaskID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate task id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitForTask(t *testing.T, q *Queue, id string) Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, ok := q.Get(id)
+		if !ok {
+			t.Fatalf("task %s not found", id)
+		}
+		if task.Status == TaskSucceeded || task.Status == TaskFailed {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s did not finish, status %s", id, task.Status)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueRunsTasksThroughRunner(t *testing.T) {
+	queue := NewQueue(2, 4)
+	runner := NewRunner()
+	runner.AddQueue(queue)
+
+	ok, err := queue.Submit("purchase", 7, func(context.Context) (any, error) { return 42, nil })
+	if err != nil || ok.Status != TaskQueued || ok.OwnerID != 7 {
+		t.Fatalf("unexpected submit result %+v err=%v", ok, err)
+	}
+	failed, _ := queue.Submit("purchase", 7, func(context.Context) (any, error) { return nil, errors.New("boom") })
+	panicked, _ := queue.Submit("purchase", 7, func(context.Context) (any, error) { panic("oops") })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	if task := waitForTask(t, queue, ok.ID); task.Status != TaskSucceeded || task.Result != 42 || task.StartedAt == nil || task.FinishedAt == nil {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	if task := waitForTask(t, queue, failed.ID); task.Status != TaskFailed || task.Error != "boom" {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	if task := waitForTask(t, queue, panicked.ID); task.Status != TaskFailed || task.Error != "panic: oops" {
+		t.Fatalf("unexpected task %+v", task)
+	}
+	cancel()
+	runner.Wait()
+
+	queue.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if removed := queue.Prune(time.Hour); removed != 3 {
+		t.Fatalf("expected 3 pruned tasks, got %d", removed)
+	}
+	if _, found := queue.Get(ok.ID); found {
+		t.Fatal("pruned task must be gone")
+	}
+}
+
+func TestQueueRejectsTasksWhenFull(t *testing.T) {
+	queue := NewQueue(1, 1)
+	noop := func(context.Context) (any, error) { return nil, nil }
+	if _, err := queue.Submit("a", 1, noop); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := queue.Submit("b", 1, noop); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+}
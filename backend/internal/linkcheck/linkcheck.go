@@ -0,0 +1,123 @@
+// Package linkcheck makes sure the site a pixel links to answers before the purchase is charged,
+// so buyers do not pay for links that lead nowhere.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/example/kup-piksel/internal/netguard"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxRedirects   = 5
+)
+
+var (
+	// ErrUnsupportedURL is returned for links that are not absolute http(s) URLs.
+	ErrUnsupportedURL = errors.New("link check needs an http(s) url")
+	// ErrUnreachable wraps the reason a site could not be reached or answered with an error.
+	ErrUnreachable = errors.New("link is unreachable")
+)
+
+// Config tunes checking. Zero values select the defaults.
+type Config struct {
+	// Timeout bounds one check including redirects and the GET fallback.
+	Timeout time.Duration
+	// AllowPrivateTargets permits links that resolve to loopback or private addresses.
+	AllowPrivateTargets bool
+	// WrapTransport, when set, wraps the guarded transport, e.g. with retries and metrics.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+// Checker sends the requests. It is safe for concurrent use.
+type Checker struct {
+	timeout time.Duration
+	client  *http.Client
+}
+
+// NewChecker returns a Checker for cfg.
+func NewChecker(cfg Config) *Checker {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !cfg.AllowPrivateTargets {
+		dialer.Control = netguard.Control
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	var roundTripper http.RoundTripper = transport
+	if cfg.WrapTransport != nil {
+		roundTripper = cfg.WrapTransport(transport)
+	}
+	return &Checker{
+		timeout: timeout,
+		client: &http.Client{
+			Transport: roundTripper,
+			// Every hop dials through the same guard; only the scheme needs checking here.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errors.New("too many redirects")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrUnsupportedURL
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Check sends a HEAD request to rawURL, or a GET when the site does not support HEAD, and
+// returns an error wrapping ErrUnreachable when the site cannot be reached or answers 404, 410
+// or a server error. Other answers, including 401 and 403 of sites that turn away bots, pass.
+func (c *Checker) Check(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrUnsupportedURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	status, err := c.send(ctx, http.MethodHead, target.String())
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = c.send(ctx, http.MethodGet, target.String())
+	}
+	switch {
+	case errors.Is(err, ErrUnsupportedURL):
+		return err
+	case err != nil:
+		return fmt.Errorf("%w: %v", ErrUnreachable, err)
+	case status == http.StatusNotFound || status == http.StatusGone || status >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: status %d", ErrUnreachable, status)
+	}
+	return nil
+}
+
+func (c *Checker) send(ctx context.Context, method, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "KupPiksel-LinkCheck/1")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) && errors.Is(urlErr.Err, ErrUnsupportedURL) {
+			return 0, ErrUnsupportedURL
+		}
+		return 0, err
+	}
+	// The body is never read; closing it right away ends a GET fallback early.
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
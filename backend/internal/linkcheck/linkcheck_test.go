@@ -0,0 +1,82 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/private":
+			w.WriteHeader(http.StatusForbidden)
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	checker := NewChecker(Config{AllowPrivateTargets: true})
+	ctx := context.Background()
+	for _, path := range []string{"/ok", "/get-only", "/private", "/moved"} {
+		if err := checker.Check(ctx, srv.URL+path); err != nil {
+			t.Fatalf("Check(%s) = %v, want nil", path, err)
+		}
+	}
+	for _, path := range []string{"/missing", "/broken"} {
+		if err := checker.Check(ctx, srv.URL+path); !errors.Is(err, ErrUnreachable) {
+			t.Fatalf("Check(%s) = %v, want ErrUnreachable", path, err)
+		}
+	}
+	for _, target := range []string{srv.URL + "/ftp", "mailto:shop@example.com", "/relative"} {
+		if err := checker.Check(ctx, target); !errors.Is(err, ErrUnsupportedURL) {
+			t.Fatalf("Check(%s) = %v, want ErrUnsupportedURL", target, err)
+		}
+	}
+
+	guarded := NewChecker(Config{})
+	if err := guarded.Check(ctx, srv.URL+"/ok"); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("expected a loopback target to be refused, got %v", err)
+	}
+}
+
+type countingTransport struct {
+	base  http.RoundTripper
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return c.base.RoundTrip(req)
+}
+
+func TestWrapTransportKeepsTheGuard(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	var wrapped *countingTransport
+	checker := NewChecker(Config{WrapTransport: func(base http.RoundTripper) http.RoundTripper {
+		wrapped = &countingTransport{base: base}
+		return wrapped
+	}})
+	if err := checker.Check(context.Background(), srv.URL); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("expected a loopback target to be refused through the wrapper, got %v", err)
+	}
+	if wrapped == nil || wrapped.calls == 0 {
+		t.Fatal("expected the check to go through the wrapped transport")
+	}
+}
@@ -0,0 +1,344 @@
+// Package linkpreview fetches the title and description of the sites pixels link to, so pixel
+// pages and hover cards can show them without the visitor's browser contacting the site.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/example/kup-piksel/internal/netguard"
+)
+
+const (
+	defaultTTL             = time.Hour
+	defaultTimeout         = 5 * time.Second
+	defaultMaxBytes        = 256 << 10
+	defaultDomainBudget    = 30
+	defaultMaxCacheEntries = 10000
+	maxRedirects           = 3
+
+	maxTitleRunes       = 120
+	maxDescriptionRunes = 300
+	maxSiteNameRunes    = 60
+)
+
+var (
+	// ErrBudgetExceeded is returned when the link's domain used up its fetches for the hour.
+	// Such failures are not cached, so the preview appears once the budget renews.
+	ErrBudgetExceeded = errors.New("link preview budget for this domain is exhausted")
+	// ErrUnsupportedURL is returned for links that are not absolute http(s) URLs.
+	ErrUnsupportedURL = errors.New("link preview needs an http(s) url")
+)
+
+// Preview is what a page says about itself, reduced to plain single-line text.
+type Preview struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// Config tunes fetching. Zero values select the defaults.
+type Config struct {
+	// TTL is how long a preview, or the failure to get one, is reused.
+	TTL      time.Duration
+	Timeout  time.Duration
+	MaxBytes int64
+	// DomainBudget caps the fetches per host and hour; cached previews do not count.
+	DomainBudget int
+	// AllowPrivateTargets permits links that resolve to loopback or private addresses.
+	AllowPrivateTargets bool
+	// WrapTransport, when set, wraps the guarded transport, e.g. with retries and metrics.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+type cacheEntry struct {
+	preview Preview
+	err     error
+	expires time.Time
+}
+
+type call struct {
+	done    chan struct{}
+	preview Preview
+	err     error
+}
+
+type budget struct {
+	window time.Time
+	used   int
+}
+
+// Fetcher fetches and caches previews. It is safe for concurrent use.
+type Fetcher struct {
+	ttl          time.Duration
+	maxBytes     int64
+	domainBudget int
+	client       *http.Client
+	now          func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]cacheEntry
+	inflight map[string]*call
+	budgets  map[string]*budget
+}
+
+// NewFetcher returns a Fetcher for cfg.
+func NewFetcher(cfg Config) *Fetcher {
+	f := &Fetcher{
+		ttl:          cfg.TTL,
+		maxBytes:     cfg.MaxBytes,
+		domainBudget: cfg.DomainBudget,
+		now:          time.Now,
+		cache:        make(map[string]cacheEntry),
+		inflight:     make(map[string]*call),
+		budgets:      make(map[string]*budget),
+	}
+	if f.ttl <= 0 {
+		f.ttl = defaultTTL
+	}
+	if f.maxBytes <= 0 {
+		f.maxBytes = defaultMaxBytes
+	}
+	if f.domainBudget <= 0 {
+		f.domainBudget = defaultDomainBudget
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !cfg.AllowPrivateTargets {
+		dialer.Control = netguard.Control
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	var roundTripper http.RoundTripper = transport
+	if cfg.WrapTransport != nil {
+		roundTripper = cfg.WrapTransport(transport)
+	}
+	f.client = &http.Client{
+		Timeout:   timeout,
+		Transport: roundTripper,
+		// Every hop dials through the same guard; only the scheme needs checking here.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Get returns the preview of rawURL, fetching it unless a fresh one is cached. Concurrent calls
+// for the same URL share one fetch.
+func (f *Fetcher) Get(ctx context.Context, rawURL string) (Preview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return Preview{}, ErrUnsupportedURL
+	}
+	target.Fragment = ""
+	key := target.String()
+
+	f.mu.Lock()
+	now := f.now()
+	if entry, ok := f.cache[key]; ok && now.Before(entry.expires) {
+		f.mu.Unlock()
+		return entry.preview, entry.err
+	}
+	if pending, ok := f.inflight[key]; ok {
+		f.mu.Unlock()
+		select {
+		case <-pending.done:
+			return pending.preview, pending.err
+		case <-ctx.Done():
+			return Preview{}, ctx.Err()
+		}
+	}
+	if !f.spend(strings.ToLower(target.Hostname()), now) {
+		f.mu.Unlock()
+		return Preview{}, ErrBudgetExceeded
+	}
+	pending := &call{done: make(chan struct{})}
+	f.inflight[key] = pending
+	f.mu.Unlock()
+
+	// The fetch outlives a caller that gives up, so waiting callers and the cache still get it.
+	pending.preview, pending.err = f.fetch(context.WithoutCancel(ctx), key)
+
+	f.mu.Lock()
+	delete(f.inflight, key)
+	f.store(key, cacheEntry{preview: pending.preview, err: pending.err, expires: f.now().Add(f.ttl)})
+	f.mu.Unlock()
+	close(pending.done)
+	return pending.preview, pending.err
+}
+
+// spend takes one fetch from host's budget for the current hour. f.mu must be held.
+func (f *Fetcher) spend(host string, now time.Time) bool {
+	window := now.Truncate(time.Hour)
+	b, ok := f.budgets[host]
+	if !ok || !b.window.Equal(window) {
+		if len(f.budgets) >= defaultMaxCacheEntries {
+			for name, old := range f.budgets {
+				if !old.window.Equal(window) {
+					delete(f.budgets, name)
+				}
+			}
+		}
+		b = &budget{window: window}
+		f.budgets[host] = b
+	}
+	if b.used >= f.domainBudget {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// store caches entry, dropping expired entries, or arbitrary ones, once the cache is full.
+// f.mu must be held.
+func (f *Fetcher) store(key string, entry cacheEntry) {
+	if len(f.cache) >= defaultMaxCacheEntries {
+		now := f.now()
+		for name, old := range f.cache {
+			if !now.Before(old.expires) {
+				delete(f.cache, name)
+			}
+		}
+		for name := range f.cache {
+			if len(f.cache) < defaultMaxCacheEntries {
+				break
+			}
+			delete(f.cache, name)
+		}
+	}
+	f.cache[key] = entry
+}
+
+func (f *Fetcher) fetch(ctx context.Context, target string) (Preview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "KupPiksel-LinkPreview/1")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, fmt.Errorf("fetch link preview: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("fetch link preview: status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, fmt.Errorf("fetch link preview: unsupported content type %q", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes))
+	if err != nil {
+		return Preview{}, fmt.Errorf("read link preview: %w", err)
+	}
+	preview := Parse(string(body))
+	preview.FetchedAt = f.now().UTC()
+	return preview, nil
+}
+
+var (
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaPattern      = regexp.MustCompile(`(?is)<meta\s((?:[^>"']|"[^"]*"|'[^']*')*)>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	headEndPattern   = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+// Parse extracts the preview from an HTML document. Open Graph and Twitter card tags win over
+// <title> and the description meta tag. The texts are unescaped and sanitized.
+func Parse(document string) Preview {
+	if end := headEndPattern.FindStringIndex(document); end != nil {
+		document = document[:end[0]]
+	}
+	meta := make(map[string]string)
+	for _, match := range metaPattern.FindAllStringSubmatch(document, -1) {
+		var name, content string
+		for _, attr := range attributePattern.FindAllStringSubmatch(match[1], -1) {
+			value := attr[2] + attr[3] + attr[4]
+			switch strings.ToLower(attr[1]) {
+			case "property", "name":
+				name = strings.ToLower(strings.TrimSpace(value))
+			case "content":
+				content = value
+			}
+		}
+		if _, seen := meta[name]; name != "" && !seen {
+			meta[name] = content
+		}
+	}
+	var title string
+	if match := titlePattern.FindStringSubmatch(document); match != nil {
+		title = match[1]
+	}
+	return Preview{
+		Title:       sanitize(first(meta["og:title"], meta["twitter:title"], title), maxTitleRunes),
+		Description: sanitize(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionRunes),
+		SiteName:    sanitize(meta["og:site_name"], maxSiteNameRunes),
+	}
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// sanitize unescapes entities, drops markup, control and invalid characters, collapses
+// whitespace and truncates to limit runes.
+func sanitize(value string, limit int) string {
+	value = html.UnescapeString(strings.ToValidUTF8(value, ""))
+	var b strings.Builder
+	inTag, space := false, false
+	for _, r := range value {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case inTag:
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == utf8.RuneError:
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteRune(r)
+		}
+	}
+	text := b.String()
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:limit-1])) + "…"
+}
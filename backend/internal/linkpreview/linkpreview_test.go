@@ -0,0 +1,85 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	got := Parse(`<!doctype html><html><head>
+		<title>Fallback</title>
+		<meta name="description" content="Plain description">
+		<meta property='og:title' content="Sklep &quot;Kot&quot; &amp; &lt;b&gt;spółka&lt;/b&gt;">
+		<meta content="Opis
+		   w dwóch liniach` + "​\x07" + `" property="og:description">
+		<meta property="og:site_name" content=Kot>
+		<meta name="twitter:title" content="<i>ignored</i>">
+		</head><body><meta property="og:title" content="ignored"></body></html>`)
+	want := Preview{Title: `Sklep "Kot" & spółka`, Description: "Opis w dwóch liniach", SiteName: "Kot"}
+	if got != want {
+		t.Fatalf("Parse() = %+v, want %+v", got, want)
+	}
+
+	fallback := Parse(`<title>  Only
+	title </title><meta name="Description" content="` + strings.Repeat("a", 400) + `">`)
+	if fallback.Title != "Only title" || len([]rune(fallback.Description)) != maxDescriptionRunes || !strings.HasSuffix(fallback.Description, "…") {
+		t.Fatalf("unexpected fallback preview %+v", fallback)
+	}
+}
+
+func TestGetCachesAndEnforcesDomainBudget(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/image.png" {
+			w.Header().Set("Content-Type", "image/png")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<title>Page ` + r.URL.Path + `</title>`))
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(Config{DomainBudget: 2, AllowPrivateTargets: true})
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		preview, err := f.Get(ctx, srv.URL+"/a#section")
+		if err != nil || preview.Title != "Page /a" {
+			t.Fatalf("Get() = %+v, %v", preview, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected one fetch for a cached page, got %d", hits.Load())
+	}
+	if _, err := f.Get(ctx, srv.URL+"/image.png"); err == nil {
+		t.Fatal("expected a non-HTML page to fail")
+	}
+	if _, err := f.Get(ctx, srv.URL+"/b"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the domain budget to be exhausted, got %v", err)
+	}
+
+	f.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if preview, err := f.Get(ctx, srv.URL+"/b"); err != nil || preview.Title != "Page /b" {
+		t.Fatalf("expected the budget to renew, got %+v, %v", preview, err)
+	}
+}
+
+func TestGetRejectsPrivateTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private target must not be reached")
+	}))
+	defer srv.Close()
+
+	if _, err := NewFetcher(Config{}).Get(context.Background(), srv.URL); err == nil {
+		t.Fatal("expected loopback fetch to fail")
+	}
+	if _, err := NewFetcher(Config{}).Get(context.Background(), "javascript:alert(1)"); !errors.Is(err, ErrUnsupportedURL) {
+		t.Fatalf("expected a non-http link to be rejected, got %v", err)
+	}
+}
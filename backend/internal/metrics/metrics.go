@@ -0,0 +1,177 @@
+// Package metrics keeps process-wide counters and renders them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing value. A nil Counter ignores updates.
+type Counter struct {
+	name   string
+	labels string
+	value  atomic.Int64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(n int64) {
+	if c == nil || n < 0 {
+		return
+	}
+	c.value.Add(n)
+}
+
+func (c *Counter) Value() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.value.Load()
+}
+
+// Gauge is a value that can go up and down, such as a count read from the database. A nil Gauge
+// ignores updates.
+type Gauge struct {
+	name   string
+	labels string
+	value  atomic.Int64
+}
+
+func (g *Gauge) Set(v int64) {
+	if g == nil {
+		return
+	}
+	g.value.Store(v)
+}
+
+func (g *Gauge) Value() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.value.Load()
+}
+
+// Registry holds named counters and gauges. A nil Registry hands out nil counters and gauges.
+type Registry struct {
+	mu       sync.Mutex
+	help     map[string]string
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+}
+
+func NewRegistry() *Registry {
+	return &Registry{help: make(map[string]string), counters: make(map[string]*Counter), gauges: make(map[string]*Gauge)}
+}
+
+// Counter returns the counter with the given name and label pairs ("key", "value", ...),
+// creating it on first use. Repeated calls with the same arguments return the same counter.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	rendered := renderLabels(labels)
+	key := name + rendered
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counter, ok := r.counters[key]; ok {
+		return counter
+	}
+	if _, ok := r.help[name]; !ok {
+		r.help[name] = help
+	}
+	counter := &Counter{name: name, labels: rendered}
+	r.counters[key] = counter
+	return counter
+}
+
+// Gauge returns the gauge with the given name and label pairs ("key", "value", ...), creating
+// it on first use. Repeated calls with the same arguments return the same gauge.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	if r == nil {
+		return nil
+	}
+
+	rendered := renderLabels(labels)
+	key := name + rendered
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if gauge, ok := r.gauges[key]; ok {
+		return gauge
+	}
+	if _, ok := r.help[name]; !ok {
+		r.help[name] = help
+	}
+	gauge := &Gauge{name: name, labels: rendered}
+	r.gauges[key] = gauge
+	return gauge
+}
+
+// series is one counter or gauge line of the exposition.
+type series struct {
+	name, labels, kind string
+	value              int64
+}
+
+// WriteText writes all counters and gauges in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	lines := make([]series, 0, len(r.counters)+len(r.gauges))
+	for _, counter := range r.counters {
+		lines = append(lines, series{counter.name, counter.labels, "counter", counter.Value()})
+	}
+	for _, gauge := range r.gauges {
+		lines = append(lines, series{gauge.name, gauge.labels, "gauge", gauge.Value()})
+	}
+	help := make(map[string]string, len(r.help))
+	for name, text := range r.help {
+		help[name] = text
+	}
+	r.mu.Unlock()
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].name != lines[j].name {
+			return lines[i].name < lines[j].name
+		}
+		return lines[i].labels < lines[j].labels
+	})
+
+	var b strings.Builder
+	previous := ""
+	for _, line := range lines {
+		if line.name != previous {
+			if text := help[line.name]; text != "" {
+				fmt.Fprintf(&b, "# HELP %s %s\n", line.name, text)
+			}
+			fmt.Fprintf(&b, "# TYPE %s %s\n", line.name, line.kind)
+			previous = line.name
+		}
+		fmt.Fprintf(&b, "%s%s %d\n", line.name, line.labels, line.value)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderLabels(pairs []string) string {
+	if len(pairs) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Handled requests.", "route", "/b").Add(2)
+	r.Counter("requests_total", "ignored", "route", "/a").Inc()
+	r.Counter("requests_total", "", "route", "/a").Inc()
+	r.Counter("errors_total", "").Inc()
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	want := `# TYPE errors_total counter
+errors_total 1
+# HELP requests_total Handled requests.
+# TYPE requests_total counter
+requests_total{route="/a"} 2
+requests_total{route="/b"} 2
+`
+	if b.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestRegistryWritesGauges(t *testing.T) {
+	r := NewRegistry()
+	r.Gauge("pixels_taken_total", "Taken pixels.").Set(5)
+	r.Gauge("pixels_taken_total", "").Set(3)
+	r.Counter("errors_total", "").Inc()
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	want := `# TYPE errors_total counter
+errors_total 1
+# HELP pixels_taken_total Taken pixels.
+# TYPE pixels_taken_total gauge
+pixels_taken_total 3
+`
+	if b.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestNilRegistryAndCounter(t *testing.T) {
+	var r *Registry
+	counter := r.Counter("x_total", "")
+	counter.Inc()
+	if counter.Value() != 0 {
+		t.Fatalf("nil counter should stay at zero")
+	}
+	gauge := r.Gauge("x", "")
+	gauge.Set(4)
+	if gauge.Value() != 0 {
+		t.Fatalf("nil gauge should stay at zero")
+	}
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil || b.Len() != 0 {
+		t.Fatalf("nil registry should write nothing, got %q err=%v", b.String(), err)
+	}
+}
+
+func TestLabelValuesAreEscaped(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("q_total", "", "query", `say "hi"`).Inc()
+
+	var b strings.Builder
+	_ = r.WriteText(&b)
+	if !strings.Contains(b.String(), `q_total{query="say \"hi\""} 1`) {
+		t.Fatalf("label not escaped: %s", b.String())
+	}
+}
@@ -0,0 +1,66 @@
+// Package moderation screens the content of a pixel before it is accepted. A Moderator is
+// consulted for the link and color of every pixel being bought; providers plug in behind the
+// interface so the purchase code does not depend on any one of them.
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Provider names accepted by New.
+const (
+	ProviderNone         = "none"
+	ProviderSafeBrowsing = "safeBrowsing"
+)
+
+var (
+	// ErrRejected wraps the reason a provider refused the content.
+	ErrRejected = errors.New("content rejected")
+	// ErrUnknownProvider is returned by New for a provider it does not know.
+	ErrUnknownProvider = errors.New("unknown moderation provider")
+)
+
+// Submission is the content of one pixel under review.
+type Submission struct {
+	URL   string
+	Color string
+}
+
+// Moderator decides whether a submission may be accepted. Review returns nil to accept it, an
+// error wrapping ErrRejected to refuse it, and any other error when no decision could be made.
+type Moderator interface {
+	Review(ctx context.Context, submission Submission) error
+}
+
+// Noop accepts every submission.
+type Noop struct{}
+
+// Review implements Moderator.
+func (Noop) Review(context.Context, Submission) error { return nil }
+
+// Config selects and configures the provider.
+type Config struct {
+	// Provider is ProviderNone, ProviderSafeBrowsing or empty for none.
+	Provider string
+	// SafeBrowsingAPIKey is the Google API key of the Safe Browsing provider.
+	SafeBrowsingAPIKey string
+	// Client sends the requests of remote providers; nil selects a plain client with a 10 second
+	// timeout.
+	Client *http.Client
+}
+
+// New returns the Moderator selected by cfg.
+func New(cfg Config) (Moderator, error) {
+	switch strings.TrimSpace(cfg.Provider) {
+	case "", ProviderNone:
+		return Noop{}, nil
+	case ProviderSafeBrowsing:
+		return NewSafeBrowsing(cfg.SafeBrowsingAPIKey, cfg.Client)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, cfg.Provider)
+	}
+}
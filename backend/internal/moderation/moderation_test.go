@@ -0,0 +1,68 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, provider := range []string{"", ProviderNone} {
+		moderator, err := New(Config{Provider: provider})
+		if err != nil {
+			t.Fatalf("New(%q) error: %v", provider, err)
+		}
+		if err := moderator.Review(context.Background(), Submission{URL: "https://example.com/", Color: "#ffffff"}); err != nil {
+			t.Fatalf("no-op moderator refused content: %v", err)
+		}
+	}
+	if _, err := New(Config{Provider: ProviderSafeBrowsing}); err == nil {
+		t.Fatalf("expected safe browsing without an api key to be refused")
+	}
+	if _, err := New(Config{Provider: "vision"}); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestSafeBrowsingReview(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("key") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var body safeBrowsingRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.ThreatInfo.ThreatEntries) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch body.ThreatInfo.ThreatEntries[0].URL {
+		case "https://phish.example/":
+			w.Write([]byte(`{"matches":[{"threatType":"SOCIAL_ENGINEERING","threat":{"url":"https://phish.example/"}}]}`))
+		case "https://down.example/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	moderator, err := NewSafeBrowsing("test-key", srv.Client())
+	if err != nil {
+		t.Fatalf("NewSafeBrowsing error: %v", err)
+	}
+	moderator.endpoint = srv.URL
+	ctx := context.Background()
+
+	if err := moderator.Review(ctx, Submission{URL: "https://example.com/", Color: "#000000"}); err != nil {
+		t.Fatalf("expected a clean link to pass, got %v", err)
+	}
+	if err := moderator.Review(ctx, Submission{URL: "https://phish.example/"}); !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected a listed link to be rejected, got %v", err)
+	}
+	if err := moderator.Review(ctx, Submission{URL: "https://down.example/"}); err == nil || errors.Is(err, ErrRejected) {
+		t.Fatalf("expected a lookup failure, got %v", err)
+	}
+}
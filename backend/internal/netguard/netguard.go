@@ -0,0 +1,44 @@
+// Package netguard keeps outgoing requests to user-supplied URLs away from the deployment's own
+// network.
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned when a connection would reach a loopback, private, link-local
+// or otherwise internal address.
+var ErrPrivateAddress = errors.New("address is not publicly routable")
+
+// deniedPrefixes are non-public ranges the net.IP predicates do not cover: "this network",
+// carrier-grade NAT, benchmarking and the NAT64 prefix that maps onto IPv4 addresses.
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Control is a net.Dialer Control function that refuses internal addresses. It runs after DNS
+// resolution, so a public name pointing at one is refused too.
+func Control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return ErrPrivateAddress
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	addr = addr.Unmap()
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package netguard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestControl(t *testing.T) {
+	for address, private := range map[string]bool{
+		"127.0.0.1:80":            true,
+		"10.1.2.3:443":            true,
+		"192.168.0.10:8080":       true,
+		"169.254.169.254:80":      true,
+		"[::1]:443":               true,
+		"0.0.0.0:80":              true,
+		"0.1.2.3:80":              true,
+		"100.64.0.1:80":           true,
+		"100.127.255.254:80":      true,
+		"198.18.0.1:80":           true,
+		"198.19.255.1:443":        true,
+		"[64:ff9b::a9fe:a9fe]:80": true,
+		"[::ffff:100.64.0.1]:80":  true,
+		"100.128.0.1:80":          false,
+		"198.20.0.1:443":          false,
+		"93.184.216.34:443":       false,
+		"[2606:4700::1]:443":      false,
+	} {
+		err := Control("tcp", address, nil)
+		if private != errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("Control(%s) = %v, want private=%t", address, err, private)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// QuietZone is the number of light modules surrounding the symbol as required by the specification.
+const QuietZone = 4
+
+// Image renders the code as a paletted image where every module occupies scale×scale pixels.
+func (c *Code) Image(scale int) *image.Paletted {
+	if scale <= 0 {
+		scale = 1
+	}
+	dim := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, dim, dim), color.Palette{color.White, color.Black})
+	for row := 0; row < c.Size; row++ {
+		for col := 0; col < c.Size; col++ {
+			if !c.Modules[row][col] {
+				continue
+			}
+			y0 := (row + QuietZone) * scale
+			x0 := (col + QuietZone) * scale
+			for y := y0; y < y0+scale; y++ {
+				offset := img.PixOffset(x0, y)
+				for x := 0; x < scale; x++ {
+					img.Pix[offset+x] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG encodes the code as a PNG image.
+func (c *Code) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, c.Image(scale))
+}
@@ -0,0 +1,493 @@
+// Package qrcode implements a small QR code encoder sufficient for printing
+// activation code deep links on promotional material. It supports byte mode
+// payloads for versions 1-10 at error correction level M.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPayloadTooLong is returned when the payload does not fit in the largest supported version.
+var ErrPayloadTooLong = errors.New("qrcode: payload too long")
+
+// Code is an encoded QR symbol. Modules are indexed as Modules[row][col] and true means dark.
+type Code struct {
+	Version int
+	Size    int
+	Mask    int
+	Modules [][]bool
+}
+
+type blockLayout struct {
+	ecPerBlock int
+	groups     [][2]int // pairs of (block count, data codewords per block)
+}
+
+// Error correction level M layouts for versions 1-10.
+var layoutsM = [...]blockLayout{
+	1:  {10, [][2]int{{1, 16}}},
+	2:  {16, [][2]int{{1, 28}}},
+	3:  {26, [][2]int{{1, 44}}},
+	4:  {18, [][2]int{{2, 32}}},
+	5:  {24, [][2]int{{2, 43}}},
+	6:  {16, [][2]int{{4, 27}}},
+	7:  {18, [][2]int{{4, 31}}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+var alignmentPositions = [...][]int{
+	1:  nil,
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+const (
+	maxVersion  = 10
+	formatBitsM = 0
+)
+
+func (l blockLayout) dataCodewords() int {
+	total := 0
+	for _, g := range l.groups {
+		total += g[0] * g[1]
+	}
+	return total
+}
+
+// Encode builds the smallest QR code that can hold payload in byte mode.
+func Encode(payload []byte) (*Code, error) {
+	version := 0
+	for v := 1; v <= maxVersion; v++ {
+		if payloadBits(v, len(payload)) <= layoutsM[v].dataCodewords()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrPayloadTooLong, len(payload))
+	}
+
+	codewords := addErrorCorrection(encodeData(version, payload), layoutsM[version])
+
+	size := version*4 + 17
+	m := newMatrix(size)
+	m.drawFunctionPatterns(version)
+	m.drawCodewords(codewords)
+
+	bestMask := 0
+	bestPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if penalty := m.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask = mask
+			bestPenalty = penalty
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(bestMask)
+	m.drawFormatBits(bestMask)
+
+	return &Code{Version: version, Size: size, Mask: bestMask, Modules: m.modules}, nil
+}
+
+func payloadBits(version, length int) int {
+	return 4 + charCountBits(version) + length*8
+}
+
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		b.bits = append(b.bits, (value>>uint(i))&1 == 1)
+	}
+}
+
+func encodeData(version int, payload []byte) []byte {
+	capacity := layoutsM[version].dataCodewords() * 8
+
+	var buf bitBuffer
+	buf.append(0x4, 4)
+	buf.append(len(payload), charCountBits(version))
+	for _, b := range payload {
+		buf.append(int(b), 8)
+	}
+
+	terminator := capacity - len(buf.bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	buf.append(0, terminator)
+	if rem := len(buf.bits) % 8; rem != 0 {
+		buf.append(0, 8-rem)
+	}
+	for pad := 0xEC; len(buf.bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		buf.append(pad, 8)
+	}
+
+	data := make([]byte, len(buf.bits)/8)
+	for i, bit := range buf.bits {
+		if bit {
+			data[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return data
+}
+
+func addErrorCorrection(data []byte, layout blockLayout) []byte {
+	generator := rsGenerator(layout.ecPerBlock)
+
+	var dataBlocks, ecBlocks [][]byte
+	offset := 0
+	for _, g := range layout.groups {
+		for i := 0; i < g[0]; i++ {
+			block := data[offset : offset+g[1]]
+			offset += g[1]
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, rsRemainder(block, generator))
+		}
+	}
+
+	result := make([]byte, 0, len(data)+len(ecBlocks)*layout.ecPerBlock)
+	longest := 0
+	for _, block := range dataBlocks {
+		if len(block) > longest {
+			longest = len(block)
+		}
+	}
+	for i := 0; i < longest; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies two elements of GF(2^8) modulo the QR polynomial 0x11D.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range generator {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+type matrix struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newMatrix(size int) *matrix {
+	m := &matrix{size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.isFunction[i] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *matrix) setFunction(row, col int, dark bool) {
+	m.modules[row][col] = dark
+	m.isFunction[row][col] = true
+}
+
+func (m *matrix) drawFunctionPatterns(version int) {
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(3, m.size-4)
+	m.drawFinder(m.size-4, 3)
+
+	positions := alignmentPositions[version]
+	last := len(positions) - 1
+	for i, row := range positions {
+		for j, col := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			m.drawAlignment(row, col)
+		}
+	}
+
+	// Reserve format areas before placing data; real bits are drawn after masking.
+	m.drawFormatBits(0)
+	m.drawVersion(version)
+}
+
+func (m *matrix) drawFinder(centerRow, centerCol int) {
+	for dr := -4; dr <= 4; dr++ {
+		for dc := -4; dc <= 4; dc++ {
+			row, col := centerRow+dr, centerCol+dc
+			if row < 0 || row >= m.size || col < 0 || col >= m.size {
+				continue
+			}
+			dist := abs(dr)
+			if abs(dc) > dist {
+				dist = abs(dc)
+			}
+			m.setFunction(row, col, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(centerRow, centerCol int) {
+	for dr := -2; dr <= 2; dr++ {
+		for dc := -2; dc <= 2; dc++ {
+			dist := abs(dr)
+			if abs(dc) > dist {
+				dist = abs(dc)
+			}
+			m.setFunction(centerRow+dr, centerCol+dc, dist != 1)
+		}
+	}
+}
+
+func formatBits(mask int) int {
+	data := formatBitsM<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (m *matrix) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.setFunction(i, 8, bit(i))
+	}
+	m.setFunction(7, 8, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(8, 14-i, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.setFunction(8, m.size-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(m.size-15+i, 8, bit(i))
+	}
+	m.setFunction(m.size-8, 8, true)
+}
+
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func (m *matrix) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	bits := versionBits(version)
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 == 1
+		a := m.size - 11 + i%3
+		b := i / 3
+		m.setFunction(b, a, dark)
+		m.setFunction(a, b, dark)
+	}
+}
+
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	total := len(data) * 8
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			row := vert
+			if upward {
+				row = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if m.isFunction[row][col] {
+					continue
+				}
+				if i < total {
+					m.modules[row][col] = (data[i>>3]>>uint(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func maskApplies(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+// applyMask toggles data modules covered by the mask. Applying the same mask twice restores the matrix.
+func (m *matrix) applyMask(mask int) {
+	for row := 0; row < m.size; row++ {
+		for col := 0; col < m.size; col++ {
+			if !m.isFunction[row][col] && maskApplies(mask, row, col) {
+				m.modules[row][col] = !m.modules[row][col]
+			}
+		}
+	}
+}
+
+var finderLike = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+func (m *matrix) penalty() int {
+	score := 0
+	at := func(row, col int, vertical bool) bool {
+		if vertical {
+			return m.modules[col][row]
+		}
+		return m.modules[row][col]
+	}
+
+	for _, vertical := range []bool{false, true} {
+		for line := 0; line < m.size; line++ {
+			run := 1
+			for i := 1; i < m.size; i++ {
+				if at(line, i, vertical) == at(line, i-1, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += 3 + run - 5
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += 3 + run - 5
+			}
+
+			for i := 0; i+11 <= m.size; i++ {
+				for _, pattern := range finderLike {
+					matched := true
+					for k, dark := range pattern {
+						if at(line, i+k, vertical) != dark {
+							matched = false
+							break
+						}
+					}
+					if matched {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for row := 0; row < m.size; row++ {
+		for col := 0; col < m.size; col++ {
+			if m.modules[row][col] {
+				dark++
+			}
+			if row+1 < m.size && col+1 < m.size {
+				c := m.modules[row][col]
+				if c == m.modules[row+1][col] && c == m.modules[row][col+1] && c == m.modules[row+1][col+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	total := m.size * m.size
+	deviation := abs(dark*20-total*10) / total
+	score += deviation * 10
+
+	return score
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainderMatchesReference(t *testing.T) {
+	// "HELLO WORLD" encoded as version 1-M (alphanumeric mode) from the ISO 18004 worked example.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := rsRemainder(data, rsGenerator(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected ec codewords: got %v want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got := formatBits(0); got != 0b101010000010010 {
+		t.Fatalf("unexpected format bits for M/0: %015b", got)
+	}
+	if got := versionBits(7); got != 0b000111110010010100 {
+		t.Fatalf("unexpected version bits for 7: %018b", got)
+	}
+}
+
+func TestEncodeSelectsSmallestVersion(t *testing.T) {
+	tests := []struct {
+		payload string
+		version int
+	}{
+		{payload: "ABCD", version: 1},
+		{payload: "https://kuppixel.pl/redeem?code=ABCD-EFGH-IJKL-MNOP", version: 4},
+		{payload: strings.Repeat("x", 200), version: 10},
+	}
+
+	for _, tt := range tests {
+		code, err := Encode([]byte(tt.payload))
+		if err != nil {
+			t.Fatalf("encode %q: %v", tt.payload, err)
+		}
+		if code.Version != tt.version {
+			t.Fatalf("payload of %d bytes: expected version %d, got %d", len(tt.payload), tt.version, code.Version)
+		}
+		if code.Size != tt.version*4+17 {
+			t.Fatalf("unexpected size %d for version %d", code.Size, code.Version)
+		}
+		// Finder pattern corners must always be dark, the separator light.
+		for _, pos := range [][2]int{{0, 0}, {0, code.Size - 1}, {code.Size - 1, 0}} {
+			if !code.Modules[pos[0]][pos[1]] {
+				t.Fatalf("expected dark finder module at %v", pos)
+			}
+		}
+		if code.Modules[7][7] {
+			t.Fatalf("expected light separator module at (7,7)")
+		}
+		if !code.Modules[code.Size-8][8] {
+			t.Fatalf("expected dark module at (%d,8)", code.Size-8)
+		}
+	}
+}
+
+func TestEncodeRejectsOversizedPayload(t *testing.T) {
+	if _, err := Encode([]byte(strings.Repeat("x", 300))); !errors.Is(err, ErrPayloadTooLong) {
+		t.Fatalf("expected ErrPayloadTooLong, got %v", err)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	code, err := Encode([]byte("https://example.com/redeem?code=TEST-CODE-0000-0001"))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := code.WritePNG(&buf, 4); err != nil {
+		t.Fatalf("write png: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	want := (code.Size + 2*QuietZone) * 4
+	if img.Bounds().Dx() != want || img.Bounds().Dy() != want {
+		t.Fatalf("unexpected image size %v, want %dx%d", img.Bounds(), want, want)
+	}
+}
+
+func TestEncodeRoundTripsCodewords(t *testing.T) {
+	payload := []byte("https://kuppixel.pl/redeem?code=WXYZ-2345-6789-ABCD")
+	code, err := Encode(payload)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	// Rebuild the function-pattern map and read the data modules back in placement order.
+	m := newMatrix(code.Size)
+	m.drawFunctionPatterns(code.Version)
+	layout := layoutsM[code.Version]
+	total := layout.dataCodewords() + layout.ecPerBlock*func() int {
+		blocks := 0
+		for _, g := range layout.groups {
+			blocks += g[0]
+		}
+		return blocks
+	}()
+
+	read := make([]byte, total)
+	i := 0
+	for right := code.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < code.Size; vert++ {
+			row := vert
+			if upward {
+				row = code.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				col := right - j
+				if m.isFunction[row][col] || i >= total*8 {
+					continue
+				}
+				dark := code.Modules[row][col] != maskApplies(code.Mask, row, col)
+				if dark {
+					read[i>>3] |= 1 << uint(7-i&7)
+				}
+				i++
+			}
+		}
+	}
+
+	want := addErrorCorrection(encodeData(code.Version, payload), layout)
+	if !bytes.Equal(read, want) {
+		t.Fatalf("codewords read back from the matrix do not match the encoded stream")
+	}
+}
@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *Store) SaveAgeAttestation(ctx context.Context, attestation AgeAttestation) error {
+	if attestation.UserID <= 0 {
+		return errors.New("invalid user id")
+	}
+	attested := attestation.AttestedAt
+	if attested.IsZero() {
+		attested = time.Now()
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO age_attestations (user_id, birth_year, minimum_age, attested_at) VALUES (?, ?, ?, ?)
+                 ON DUPLICATE KEY UPDATE birth_year = VALUES(birth_year), minimum_age = VALUES(minimum_age), attested_at = VALUES(attested_at)`,
+		attestation.UserID,
+		attestation.BirthYear,
+		attestation.MinimumAge,
+		attested.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("save age attestation: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetAgeAttestation(ctx context.Context, userID int64) (AgeAttestation, error) {
+	var attestation AgeAttestation
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, birth_year, minimum_age, attested_at FROM age_attestations WHERE user_id = ?`, userID).
+		Scan(&attestation.UserID, &attestation.BirthYear, &attestation.MinimumAge, &attestation.AttestedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AgeAttestation{}, sql.ErrNoRows
+		}
+		return AgeAttestation{}, fmt.Errorf("get age attestation: %w", err)
+	}
+	attestation.AttestedAt = attestation.AttestedAt.UTC()
+	return attestation, nil
+}
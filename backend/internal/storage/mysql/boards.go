@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// onMainBoard restricts a query on pixels or pixel_history to the main grid.
+const onMainBoard = "board_id = ''"
+
+// ensurePixelBoardKey keys the pixels table by board and id once, so boards can share it. The
+// key keeps id, which the partitions are laid out by.
+func ensurePixelBoardKey(ctx context.Context, tx *sqltrace.Tx) error {
+	var keyed int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM information_schema.KEY_COLUMN_USAGE WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'pixels' AND CONSTRAINT_NAME = 'PRIMARY' AND COLUMN_NAME = 'board_id'`).Scan(&keyed)
+	if err != nil {
+		return fmt.Errorf("inspect pixels primary key: %w", err)
+	}
+	if keyed > 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE pixels DROP PRIMARY KEY, ADD PRIMARY KEY (board_id, id)`); err != nil {
+		return fmt.Errorf("key pixels by board: %w", err)
+	}
+	return nil
+}
+
+// GetBoardPixels fills the ids missing from the board with free pixels; unlike the main grid,
+// boards get a row only once a pixel is first bought.
+func (s *Store) GetBoardPixels(ctx context.Context, boardID string) (PixelState, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE board_id = ? ORDER BY id`, boardID)
+	if err != nil {
+		return PixelState{}, fmt.Errorf("query board pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, storage.TotalPixels)
+	for id := range pixels {
+		pixels[id] = Pixel{ID: id, Status: "free"}
+	}
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var updated sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return PixelState{}, fmt.Errorf("scan board pixel: %w", err)
+		}
+		if owner.Valid {
+			oid := owner.Int64
+			pixel.OwnerID = &oid
+		}
+		if updated.Valid {
+			pixel.UpdatedAt = updated.Time.UTC()
+		}
+		if pixel.ID >= 0 && pixel.ID < storage.TotalPixels {
+			pixels[pixel.ID] = pixel
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return PixelState{}, fmt.Errorf("iterate board pixels: %w", err)
+	}
+	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+}
+
+func (s *Store) UpdateBoardPixelsForUser(ctx context.Context, boardID string, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	if boardID == storage.MainBoard {
+		return nil, User{}, errors.New("board id must not be empty")
+	}
+	if userID <= 0 {
+		return nil, User{}, errors.New("invalid user id")
+	}
+	return s.updatePixelsWithCost(ctx, boardID, userID, userID, pixels, cost, priceVersion)
+}
+
+// insertBoardPixels adds free rows for those of ids the board does not have yet.
+func insertBoardPixels(ctx context.Context, tx *sqltrace.Tx, board string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	args := make([]any, 0, 3*len(ids))
+	for _, id := range ids {
+		args = append(args, board, id, now)
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?, ?, 'free', '', '', ?), ", len(ids)), ", ")
+	if _, err := tx.ExecContext(ctx, `INSERT INTO pixels (board_id, id, status, color, url, updated_at) VALUES `+values+` ON DUPLICATE KEY UPDATE id = id`, args...); err != nil {
+		return fmt.Errorf("insert board pixels: %w", err)
+	}
+	return nil
+}
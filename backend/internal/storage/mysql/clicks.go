@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) RecordPixelClick(ctx context.Context, pixelID int, ownerID int64, visitor string, at time.Time) (err error) {
+	day := at.UTC().Format(time.DateOnly)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin record pixel click: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO pixel_clicks (pixel_id, owner_id, day, clicks) VALUES (?, ?, ?, 1)
+                 ON DUPLICATE KEY UPDATE clicks = clicks + 1`,
+		pixelID,
+		ownerID,
+		day,
+	)
+	if err != nil {
+		return fmt.Errorf("record pixel click: %w", err)
+	}
+	if visitor != "" {
+		_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO pixel_visitors (pixel_id, owner_id, day, visitor) VALUES (?, ?, ?, ?)`, pixelID, ownerID, day, visitor)
+		if err != nil {
+			return fmt.Errorf("record pixel visitor: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit record pixel click: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListPixelClicksByOwner(ctx context.Context, ownerID int64, since time.Time) ([]storage.PixelClicks, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT pixel_id, SUM(clicks) FROM pixel_clicks WHERE owner_id = ? AND day >= ? GROUP BY pixel_id ORDER BY pixel_id`,
+		ownerID,
+		since.UTC().Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel clicks: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := make([]storage.PixelClicks, 0)
+	for rows.Next() {
+		var entry storage.PixelClicks
+		if err := rows.Scan(&entry.PixelID, &entry.Clicks); err != nil {
+			return nil, fmt.Errorf("scan pixel clicks: %w", err)
+		}
+		clicks = append(clicks, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel clicks: %w", err)
+	}
+	return clicks, nil
+}
+
+func (s *Store) GetPixelClickDays(ctx context.Context, pixelID int, ownerID int64, since time.Time) ([]storage.PixelClickDay, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT DATE_FORMAT(c.day, '%Y-%m-%d'), c.clicks,
+                        (SELECT COUNT(*) FROM pixel_visitors v WHERE v.pixel_id = c.pixel_id AND v.owner_id = c.owner_id AND v.day = c.day)
+                 FROM pixel_clicks c WHERE c.pixel_id = ? AND c.owner_id = ? AND c.day >= ? ORDER BY c.day`,
+		pixelID,
+		ownerID,
+		since.UTC().Format(time.DateOnly),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel click days: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]storage.PixelClickDay, 0)
+	for rows.Next() {
+		var entry storage.PixelClickDay
+		if err := rows.Scan(&entry.Day, &entry.Clicks, &entry.Visitors); err != nil {
+			return nil, fmt.Errorf("scan pixel click day: %w", err)
+		}
+		days = append(days, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel click days: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) CheckConsistency(ctx context.Context, repair bool) (anomalies []storage.Anomaly, err error) {
+	now := time.Now().UTC()
+	checks := []struct {
+		kind, find, record, repair string
+		recordArgs, repairArgs     []any
+	}{
+		{
+			storage.AnomalyOrphanedPixels,
+			`SELECT DISTINCT id FROM pixels WHERE owner_id IS NOT NULL AND owner_id NOT IN (SELECT id FROM users) ORDER BY id`,
+			`INSERT INTO pixel_history(board_id, pixel_id, status, color, url, owner_id, previous_owner_id, changed_at) SELECT board_id, id, 'free', '', '', NULL, owner_id, ? FROM pixels WHERE owner_id IS NOT NULL AND owner_id NOT IN (SELECT id FROM users)`,
+			`UPDATE pixels SET status = 'free', color = '', url = '', title = '', description = '', owner_id = NULL, expires_at = NULL, expiry_notified_at = NULL, paid_points = NULL, updated_at = ? WHERE owner_id IS NOT NULL AND owner_id NOT IN (SELECT id FROM users)`,
+			[]any{now},
+			[]any{now},
+		},
+		{
+			storage.AnomalyLedgerMismatches,
+			`SELECT id FROM users WHERE user_points <> ` + ledgerBalance + ` ORDER BY id`,
+			"",
+			`UPDATE users SET user_points = ` + ledgerBalance + ` WHERE user_points <> ` + ledgerBalance,
+			nil,
+			nil,
+		},
+		{
+			storage.AnomalyNegativeBalances,
+			`SELECT id FROM users WHERE user_points < 0 ORDER BY id`,
+			`INSERT INTO points_ledger(user_id, delta, kind, reference, reason, created_at) SELECT id, -user_points, ?, '', 'negative balance reset to zero', ? FROM users WHERE user_points < 0`,
+			`UPDATE users SET user_points = 0 WHERE user_points < 0`,
+			[]any{storage.LedgerRepair, now},
+			nil,
+		},
+		{
+			storage.AnomalyOrphanedVerificationTokens,
+			`SELECT user_id FROM verification_tokens WHERE user_id NOT IN (SELECT id FROM users) ORDER BY user_id`,
+			"",
+			`DELETE FROM verification_tokens WHERE user_id NOT IN (SELECT id FROM users)`,
+			nil,
+			nil,
+		},
+		{
+			storage.AnomalyOrphanedPasswordResetTokens,
+			`SELECT user_id FROM password_reset_tokens WHERE user_id NOT IN (SELECT id FROM users) ORDER BY user_id`,
+			"",
+			`DELETE FROM password_reset_tokens WHERE user_id NOT IN (SELECT id FROM users)`,
+			nil,
+			nil,
+		},
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin consistency check: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, check := range checks {
+		anomaly := storage.Anomaly{Kind: check.kind, IDs: []int64{}}
+		rows, queryErr := tx.QueryContext(ctx, check.find)
+		if queryErr != nil {
+			err = fmt.Errorf("find %s: %w", check.kind, queryErr)
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			if scanErr := rows.Scan(&id); scanErr != nil {
+				rows.Close()
+				err = fmt.Errorf("scan %s: %w", check.kind, scanErr)
+				return nil, err
+			}
+			anomaly.IDs = append(anomaly.IDs, id)
+		}
+		rowsErr := rows.Err()
+		rows.Close()
+		if rowsErr != nil {
+			err = fmt.Errorf("iterate %s: %w", check.kind, rowsErr)
+			return nil, err
+		}
+
+		if repair && len(anomaly.IDs) > 0 {
+			if check.record != "" {
+				if _, execErr := tx.ExecContext(ctx, check.record, check.recordArgs...); execErr != nil {
+					err = fmt.Errorf("record %s: %w", check.kind, execErr)
+					return nil, err
+				}
+			}
+			res, execErr := tx.ExecContext(ctx, check.repair, check.repairArgs...)
+			if execErr != nil {
+				err = fmt.Errorf("repair %s: %w", check.kind, execErr)
+				return nil, err
+			}
+			if anomaly.Repaired, err = res.RowsAffected(); err != nil {
+				err = fmt.Errorf("repair %s rows affected: %w", check.kind, err)
+				return nil, err
+			}
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit consistency check: %w", err)
+	}
+	return anomalies, nil
+}
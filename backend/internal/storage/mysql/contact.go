@@ -0,0 +1,165 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const contactMessageColumns = "id, user_id, name, email, subject, body, status, created_at"
+
+func (s *Store) CreateContactMessage(ctx context.Context, message ContactMessage) (ContactMessage, error) {
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	message.CreatedAt = message.CreatedAt.UTC()
+	message.Status = storage.ContactStatusOpen
+	var userID sql.NullInt64
+	if message.UserID != nil {
+		userID = sql.NullInt64{Int64: *message.UserID, Valid: true}
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO contact_messages (user_id, name, email, subject, body, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID,
+		message.Name,
+		message.Email,
+		message.Subject,
+		message.Body,
+		message.Status,
+		message.CreatedAt,
+	)
+	if err != nil {
+		return ContactMessage{}, fmt.Errorf("insert contact message: %w", err)
+	}
+	if message.ID, err = res.LastInsertId(); err != nil {
+		return ContactMessage{}, fmt.Errorf("contact message id: %w", err)
+	}
+	return message, nil
+}
+
+func (s *Store) GetContactMessage(ctx context.Context, id int64) (ContactMessage, error) {
+	return scanContactMessage(s.db.QueryRowContext(ctx, `SELECT `+contactMessageColumns+` FROM contact_messages WHERE id = ?`, id))
+}
+
+func (s *Store) ListContactMessages(ctx context.Context, status string) ([]ContactMessage, error) {
+	query := `SELECT ` + contactMessageColumns + ` FROM contact_messages ORDER BY created_at DESC, id DESC`
+	args := []any{}
+	if status != "" {
+		query = `SELECT ` + contactMessageColumns + ` FROM contact_messages WHERE status = ? ORDER BY created_at DESC, id DESC`
+		args = append(args, status)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query contact messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]ContactMessage, 0)
+	for rows.Next() {
+		message, err := scanContactMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contact messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *Store) CreateContactReply(ctx context.Context, reply ContactReply) (created ContactReply, err error) {
+	if reply.CreatedAt.IsZero() {
+		reply.CreatedAt = time.Now()
+	}
+	reply.CreatedAt = reply.CreatedAt.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ContactReply{}, fmt.Errorf("begin contact reply: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var exists int
+	if scanErr := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM contact_messages WHERE id = ?`, reply.MessageID).Scan(&exists); scanErr != nil {
+		err = fmt.Errorf("check contact message: %w", scanErr)
+		return ContactReply{}, err
+	}
+	if exists == 0 {
+		err = sql.ErrNoRows
+		return ContactReply{}, err
+	}
+	if _, execErr := tx.ExecContext(ctx, `UPDATE contact_messages SET status = ? WHERE id = ?`, storage.ContactStatusAnswered, reply.MessageID); execErr != nil {
+		err = fmt.Errorf("mark contact message answered: %w", execErr)
+		return ContactReply{}, err
+	}
+	res, execErr := tx.ExecContext(
+		ctx,
+		`INSERT INTO contact_replies (message_id, admin_id, body, created_at) VALUES (?, ?, ?, ?)`,
+		reply.MessageID,
+		reply.AdminID,
+		reply.Body,
+		reply.CreatedAt,
+	)
+	if execErr != nil {
+		err = fmt.Errorf("insert contact reply: %w", execErr)
+		return ContactReply{}, err
+	}
+	if reply.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("contact reply id: %w", err)
+		return ContactReply{}, err
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit contact reply: %w", commitErr)
+		return ContactReply{}, err
+	}
+	return reply, nil
+}
+
+func (s *Store) ListContactReplies(ctx context.Context, messageID int64) ([]ContactReply, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, message_id, admin_id, body, created_at FROM contact_replies WHERE message_id = ? ORDER BY id`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("query contact replies: %w", err)
+	}
+	defer rows.Close()
+
+	replies := make([]ContactReply, 0)
+	for rows.Next() {
+		var reply ContactReply
+		if err := rows.Scan(&reply.ID, &reply.MessageID, &reply.AdminID, &reply.Body, &reply.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan contact reply: %w", err)
+		}
+		reply.CreatedAt = reply.CreatedAt.UTC()
+		replies = append(replies, reply)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contact replies: %w", err)
+	}
+	return replies, nil
+}
+
+func scanContactMessage(row rowScanner) (ContactMessage, error) {
+	var message ContactMessage
+	var userID sql.NullInt64
+	if err := row.Scan(&message.ID, &userID, &message.Name, &message.Email, &message.Subject, &message.Body, &message.Status, &message.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ContactMessage{}, sql.ErrNoRows
+		}
+		return ContactMessage{}, fmt.Errorf("scan contact message: %w", err)
+	}
+	if userID.Valid {
+		id := userID.Int64
+		message.UserID = &id
+	}
+	message.CreatedAt = message.CreatedAt.UTC()
+	return message, nil
+}
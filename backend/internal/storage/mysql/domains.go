@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const customDomainColumns = "id, domain, user_id, pixel_id, token, verified_at, last_checked_at, created_at"
+
+func (s *Store) CreateCustomDomain(ctx context.Context, domain storage.CustomDomain) (storage.CustomDomain, error) {
+	if domain.CreatedAt.IsZero() {
+		domain.CreatedAt = time.Now()
+	}
+	domain.CreatedAt = domain.CreatedAt.UTC()
+	var pixelID sql.NullInt64
+	if domain.PixelID != nil {
+		pixelID = sql.NullInt64{Int64: int64(*domain.PixelID), Valid: true}
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO custom_domains (domain, user_id, pixel_id, token, created_at) SELECT ?, ?, ?, ?, ? FROM DUAL
+                 WHERE NOT EXISTS (SELECT 1 FROM custom_domains WHERE domain = ? AND verified_at IS NOT NULL)`,
+		domain.Domain,
+		domain.UserID,
+		pixelID,
+		domain.Token,
+		domain.CreatedAt,
+		domain.Domain,
+	)
+	if err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("insert custom domain: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("insert custom domain: %w", err)
+	} else if affected == 0 {
+		return storage.CustomDomain{}, storage.ErrDomainTaken
+	}
+	if domain.ID, err = res.LastInsertId(); err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("custom domain id: %w", err)
+	}
+	domain.VerifiedAt, domain.LastCheckedAt = nil, nil
+	return domain, nil
+}
+
+func (s *Store) ListCustomDomains(ctx context.Context, userID int64) ([]storage.CustomDomain, error) {
+	return s.loadCustomDomains(ctx, `SELECT `+customDomainColumns+` FROM custom_domains WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (s *Store) ListCustomDomainsByState(ctx context.Context, verified bool) ([]storage.CustomDomain, error) {
+	condition := "verified_at IS NULL"
+	if verified {
+		condition = "verified_at IS NOT NULL"
+	}
+	return s.loadCustomDomains(ctx, `SELECT `+customDomainColumns+` FROM custom_domains WHERE `+condition+` ORDER BY id`)
+}
+
+func (s *Store) MarkCustomDomainChecked(ctx context.Context, id int64, verified bool, at time.Time) (err error) {
+	if !verified {
+		if _, err := s.db.ExecContext(ctx, `UPDATE custom_domains SET last_checked_at = ? WHERE id = ?`, at.UTC(), id); err != nil {
+			return fmt.Errorf("mark custom domain checked: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin verify custom domain: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var domain string
+	if err = tx.QueryRowContext(ctx, `SELECT domain FROM custom_domains WHERE id = ? FOR UPDATE`, id).Scan(&domain); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The domain was deleted since it was listed.
+			_ = tx.Rollback()
+			return nil
+		}
+		return fmt.Errorf("load custom domain: %w", err)
+	}
+	var taken int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM custom_domains WHERE domain = ? AND id <> ? AND verified_at IS NOT NULL FOR UPDATE`, domain, id).Scan(&taken); err != nil {
+		return fmt.Errorf("check custom domain claims: %w", err)
+	}
+	query := `UPDATE custom_domains SET last_checked_at = ?, verified_at = COALESCE(verified_at, ?) WHERE id = ?`
+	args := []any{at.UTC(), at.UTC(), id}
+	if taken > 0 {
+		query, args = `UPDATE custom_domains SET last_checked_at = ? WHERE id = ?`, []any{at.UTC(), id}
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("mark custom domain checked: %w", err)
+	}
+	if taken == 0 {
+		// The first verified claim wins; the other pending claims of the domain cannot be verified any more.
+		if _, err = tx.ExecContext(ctx, `DELETE FROM custom_domains WHERE domain = ? AND id <> ? AND verified_at IS NULL`, domain, id); err != nil {
+			return fmt.Errorf("delete losing custom domain claims: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit custom domain check: %w", err)
+	}
+	if taken > 0 {
+		return storage.ErrDomainTaken
+	}
+	return nil
+}
+
+func (s *Store) DeleteCustomDomain(ctx context.Context, userID, id int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM custom_domains WHERE id = ? AND user_id = ?`, id, userID); err != nil {
+		return fmt.Errorf("delete custom domain: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteUnverifiedCustomDomains(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM custom_domains WHERE verified_at IS NULL AND created_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete unverified custom domains: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (s *Store) loadCustomDomains(ctx context.Context, query string, args ...any) ([]storage.CustomDomain, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query custom domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make([]storage.CustomDomain, 0)
+	for rows.Next() {
+		var domain storage.CustomDomain
+		var pixelID sql.NullInt64
+		var verified, checked sql.NullTime
+		if err := rows.Scan(&domain.ID, &domain.Domain, &domain.UserID, &pixelID, &domain.Token, &verified, &checked, &domain.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan custom domain: %w", err)
+		}
+		if pixelID.Valid {
+			id := int(pixelID.Int64)
+			domain.PixelID = &id
+		}
+		domain.VerifiedAt, domain.LastCheckedAt = expiresAt(verified), expiresAt(checked)
+		domain.CreatedAt = domain.CreatedAt.UTC()
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate custom domains: %w", err)
+	}
+	return domains, nil
+}
@@ -0,0 +1,181 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const pixelDraftColumns = "id, user_id, name, created_at, updated_at"
+
+func (s *Store) CreatePixelDraft(ctx context.Context, draft storage.PixelDraft) (_ storage.PixelDraft, err error) {
+	now := time.Now().UTC()
+	draft.CreatedAt, draft.UpdatedAt = now, now
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("begin create pixel draft: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO pixel_drafts (user_id, name, created_at, updated_at) VALUES (?, ?, ?, ?)`, draft.UserID, draft.Name, now, now)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("insert pixel draft: %w", err)
+	}
+	if draft.ID, err = res.LastInsertId(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("pixel draft id: %w", err)
+	}
+	if err = insertDraftPixels(ctx, tx, draft); err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("commit pixel draft: %w", err)
+	}
+	return draft, nil
+}
+
+func (s *Store) ListPixelDrafts(ctx context.Context, userID int64) ([]storage.PixelDraft, error) {
+	return loadPixelDrafts(ctx, s.db, `SELECT `+pixelDraftColumns+` FROM pixel_drafts WHERE user_id = ? ORDER BY updated_at DESC, id DESC`, userID)
+}
+
+func (s *Store) GetPixelDraft(ctx context.Context, userID, id int64) (storage.PixelDraft, error) {
+	drafts, err := loadPixelDrafts(ctx, s.db, `SELECT `+pixelDraftColumns+` FROM pixel_drafts WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if len(drafts) == 0 {
+		return storage.PixelDraft{}, sql.ErrNoRows
+	}
+	return drafts[0], nil
+}
+
+func (s *Store) UpdatePixelDraft(ctx context.Context, draft storage.PixelDraft) (_ storage.PixelDraft, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("begin update pixel draft: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Locking the row first tells a missing draft apart from an update that changes nothing.
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM pixel_drafts WHERE id = ? AND user_id = ? FOR UPDATE`, draft.ID, draft.UserID).Scan(&id)
+	if err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE pixel_drafts SET name = ?, updated_at = ? WHERE id = ?`, draft.Name, time.Now().UTC(), draft.ID); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("update pixel draft: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM pixel_draft_pixels WHERE draft_id = ?`, draft.ID); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("clear pixel draft pixels: %w", err)
+	}
+	if err = insertDraftPixels(ctx, tx, draft); err != nil {
+		return storage.PixelDraft{}, err
+	}
+	drafts, err := loadPixelDrafts(ctx, tx, `SELECT `+pixelDraftColumns+` FROM pixel_drafts WHERE id = ?`, draft.ID)
+	if err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("commit pixel draft: %w", err)
+	}
+	return drafts[0], nil
+}
+
+func (s *Store) DeletePixelDraft(ctx context.Context, userID, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM pixel_drafts WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete pixel draft: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete pixel draft: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func insertDraftPixels(ctx context.Context, tx *sqltrace.Tx, draft storage.PixelDraft) error {
+	for _, pixel := range draft.Pixels {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO pixel_draft_pixels (draft_id, pixel_id, color, url, title, description) VALUES (?, ?, ?, ?, ?, ?)
+                 ON DUPLICATE KEY UPDATE color = VALUES(color), url = VALUES(url), title = VALUES(title), description = VALUES(description)`,
+			draft.ID,
+			pixel.ID,
+			pixel.Color,
+			pixel.URL,
+			pixel.Title,
+			pixel.Description,
+		)
+		if err != nil {
+			return fmt.Errorf("insert pixel draft pixel: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadPixelDrafts runs query, which must select pixelDraftColumns, and attaches the drafts' pixels.
+func loadPixelDrafts(ctx context.Context, db queryer, query string, args ...any) ([]storage.PixelDraft, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := make([]storage.PixelDraft, 0)
+	index := make(map[int64]int)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var draft storage.PixelDraft
+		if err := rows.Scan(&draft.ID, &draft.UserID, &draft.Name, &draft.CreatedAt, &draft.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan pixel draft: %w", err)
+		}
+		draft.CreatedAt, draft.UpdatedAt = draft.CreatedAt.UTC(), draft.UpdatedAt.UTC()
+		draft.Pixels = make([]storage.DraftPixel, 0)
+		index[draft.ID] = len(drafts)
+		ids = append(ids, draft.ID)
+		drafts = append(drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel drafts: %w", err)
+	}
+	rows.Close()
+	if len(drafts) == 0 {
+		return drafts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	pixelRows, err := db.QueryContext(ctx, `SELECT draft_id, pixel_id, color, url, title, description FROM pixel_draft_pixels WHERE draft_id IN (`+placeholders+`) ORDER BY pixel_id`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel draft pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var draftID int64
+		var pixel storage.DraftPixel
+		if err := pixelRows.Scan(&draftID, &pixel.ID, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description); err != nil {
+			return nil, fmt.Errorf("scan pixel draft pixel: %w", err)
+		}
+		if i, ok := index[draftID]; ok {
+			drafts[i].Pixels = append(drafts[i].Pixels, pixel)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel draft pixels: %w", err)
+	}
+	return drafts, nil
+}
@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const pixelChangeColumns = "id, pixel_id, status, color, url, owner_id, previous_owner_id, changed_at"
+
+// loadPixelHistoryStates reads the fields of the pixels of board that their history tracks, and
+// their rental expiry, in one query that locks them for the rest of tx. Pixels that do not exist
+// are missing from the map.
+func loadPixelHistoryStates(ctx context.Context, tx *sqltrace.Tx, board string, ids []int) (map[int]Pixel, error) {
+	states := make(map[int]Pixel, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+	in, args := pixelIDsIn(ids)
+	rows, err := tx.QueryContext(ctx, `SELECT id, status, color, url, owner_id, expires_at FROM pixels WHERE board_id = ? AND `+in+` FOR UPDATE`, append([]any{board}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("load current pixel states: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var expires sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &expires); err != nil {
+			return nil, fmt.Errorf("scan current pixel state: %w", err)
+		}
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		pixel.ExpiresAt = expiresAt(expires)
+		states[pixel.ID] = pixel
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate current pixel states: %w", err)
+	}
+	return states, nil
+}
+
+// pixelTransition is one write of a pixel, from before to after.
+type pixelTransition struct {
+	before, after Pixel
+}
+
+// recordPixelChange logs after in pixel_history when its status, color, link or owner differs
+// from before. Title and description edits are not recorded.
+func recordPixelChange(ctx context.Context, tx *sqltrace.Tx, before, after Pixel) error {
+	return recordPixelChanges(ctx, tx, storage.MainBoard, []pixelTransition{{before: before, after: after}})
+}
+
+// recordPixelChanges is recordPixelChange for many writes to board in one statement.
+func recordPixelChanges(ctx context.Context, tx *sqltrace.Tx, board string, transitions []pixelTransition) error {
+	rows := make([]string, 0, len(transitions))
+	args := make([]any, 0, 8*len(transitions))
+	for _, t := range transitions {
+		before, after := t.before, t.after
+		if before.Status == after.Status && before.Color == after.Color && before.URL == after.URL && sameOwner(before.OwnerID, after.OwnerID) {
+			continue
+		}
+		rows = append(rows, `(?, ?, ?, ?, ?, ?, ?, ?)`)
+		args = append(args, board, after.ID, after.Status, after.Color, after.URL, ownerValue(after.OwnerID), ownerValue(before.OwnerID), after.UpdatedAt.UTC())
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO pixel_history (board_id, pixel_id, status, color, url, owner_id, previous_owner_id, changed_at) VALUES `+strings.Join(rows, ", "), args...); err != nil {
+		return fmt.Errorf("insert pixel history: %w", err)
+	}
+	return nil
+}
+
+func sameOwner(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func ownerValue(owner *int64) any {
+	if owner == nil {
+		return nil
+	}
+	return *owner
+}
+
+func (s *Store) ListPixelHistory(ctx context.Context, pixelID int, limit int) ([]storage.PixelChange, error) {
+	return s.loadPixelChanges(
+		ctx,
+		`SELECT `+pixelChangeColumns+` FROM pixel_history WHERE `+onMainBoard+` AND pixel_id = ? ORDER BY id DESC LIMIT ?`,
+		pixelID,
+		limit,
+	)
+}
+
+func (s *Store) ListPixelHistoryByOwner(ctx context.Context, ownerID int64, limit int) ([]storage.PixelChange, error) {
+	return s.loadPixelChanges(
+		ctx,
+		`SELECT `+pixelChangeColumns+` FROM pixel_history WHERE `+onMainBoard+` AND (owner_id = ? OR previous_owner_id = ?) ORDER BY id DESC LIMIT ?`,
+		ownerID,
+		ownerID,
+		limit,
+	)
+}
+
+func (s *Store) loadPixelChanges(ctx context.Context, query string, args ...any) ([]storage.PixelChange, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]storage.PixelChange, 0)
+	for rows.Next() {
+		var change storage.PixelChange
+		var owner, previousOwner sql.NullInt64
+		if err := rows.Scan(&change.ID, &change.PixelID, &change.Status, &change.Color, &change.URL, &owner, &previousOwner, &change.ChangedAt); err != nil {
+			return nil, fmt.Errorf("scan pixel history: %w", err)
+		}
+		if owner.Valid {
+			id := owner.Int64
+			change.OwnerID = &id
+		}
+		if previousOwner.Valid {
+			id := previousOwner.Int64
+			change.PreviousOwnerID = &id
+		}
+		change.ChangedAt = change.ChangedAt.UTC()
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel history: %w", err)
+	}
+	return changes, nil
+}
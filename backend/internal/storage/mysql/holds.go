@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const pixelHoldColumns = "pixel_id, user_id, expires_at"
+
+func (s *Store) HoldPixels(ctx context.Context, userID int64, pixelIDs []int, expiresAt, now time.Time) (held []int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin hold pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM pixel_holds WHERE user_id = ? OR expires_at <= ?`, userID, now.UTC()); err != nil {
+		return nil, fmt.Errorf("release previous pixel holds: %w", err)
+	}
+	if len(pixelIDs) > 0 {
+		args := make([]any, 0, len(pixelIDs)+2)
+		args = append(args, userID, expiresAt.UTC())
+		for _, id := range pixelIDs {
+			args = append(args, id)
+		}
+		// The primary key on pixel_id leaves pixels held by someone else out.
+		query := `INSERT INTO pixel_holds (` + pixelHoldColumns + `) SELECT id, ?, ? FROM pixels WHERE ` + onMainBoard + ` AND id IN (` +
+			strings.TrimSuffix(strings.Repeat("?,", len(pixelIDs)), ",") + `) AND owner_id IS NULL ON DUPLICATE KEY UPDATE pixel_id = pixel_id`
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("hold pixels: %w", err)
+		}
+	}
+	holds, err := loadPixelHolds(ctx, tx, `SELECT `+pixelHoldColumns+` FROM pixel_holds WHERE user_id = ? ORDER BY pixel_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit hold pixels: %w", err)
+	}
+	held = make([]int, len(holds))
+	for i, hold := range holds {
+		held[i] = hold.PixelID
+	}
+	return held, nil
+}
+
+func (s *Store) ListPixelHolds(ctx context.Context, userID int64, now time.Time) ([]storage.PixelHold, error) {
+	return loadPixelHolds(ctx, s.db, `SELECT `+pixelHoldColumns+` FROM pixel_holds WHERE user_id = ? AND expires_at > ? ORDER BY pixel_id`, userID, now.UTC())
+}
+
+func (s *Store) ReleasePixelHolds(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM pixel_holds WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("release pixel holds: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteExpiredPixelHolds(ctx context.Context, now time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM pixel_holds WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired pixel holds: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// pixelsHeldByOthers returns which of the pixels a user other than userID holds past now. The read
+// locks the holds, so a hold placed concurrently is either seen or waits for the purchase.
+func pixelsHeldByOthers(ctx context.Context, tx *sqltrace.Tx, pixelIDs []int, userID int64, now time.Time) (map[int]bool, error) {
+	held := make(map[int]bool)
+	if len(pixelIDs) == 0 {
+		return held, nil
+	}
+	args := make([]any, 0, len(pixelIDs)+2)
+	for _, id := range pixelIDs {
+		args = append(args, id)
+	}
+	args = append(args, userID, now.UTC())
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(pixelIDs)), ", ")
+	rows, err := tx.QueryContext(ctx, `SELECT pixel_id FROM pixel_holds WHERE pixel_id IN (`+placeholders+`) AND user_id <> ? AND expires_at > ? FOR UPDATE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("check pixel holds: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan pixel hold: %w", err)
+		}
+		held[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel holds: %w", err)
+	}
+	return held, nil
+}
+
+func loadPixelHolds(ctx context.Context, q queryer, query string, args ...any) ([]storage.PixelHold, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := make([]storage.PixelHold, 0)
+	for rows.Next() {
+		var hold storage.PixelHold
+		if err := rows.Scan(&hold.PixelID, &hold.UserID, &hold.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("scan pixel hold: %w", err)
+		}
+		hold.ExpiresAt = hold.ExpiresAt.UTC()
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel holds: %w", err)
+	}
+	return holds, nil
+}
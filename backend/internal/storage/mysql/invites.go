@@ -0,0 +1,163 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const inviteCodeColumns = "code, batch_id, created_by, created_at, used_by, used_at"
+
+func (s *Store) CreateInviteCodeBatch(ctx context.Context, batchID string, codes []string) (err error) {
+	if len(codes) == 0 {
+		return errors.New("batch must contain codes")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin create invite code batch: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	created := time.Now().UTC()
+	for _, code := range codes {
+		if _, err = tx.ExecContext(ctx, `INSERT INTO invite_codes (code, batch_id, created_at) VALUES (?, ?, ?)`, code, nullableString(strings.TrimSpace(batchID)), created); err != nil {
+			return fmt.Errorf("insert invite code: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit invite code batch: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) CreateUserInviteCode(ctx context.Context, userID int64, code string, limit int) (invite storage.InviteCode, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.InviteCode{}, fmt.Errorf("begin create invite code: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// The user row serializes concurrent requests so the limit cannot be overshot.
+	var id int64
+	if err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("lock invite creator: %w", err)
+	}
+	var created int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM invite_codes WHERE created_by = ?`, userID).Scan(&created); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("count invite codes: %w", err)
+	}
+	if created >= limit {
+		err = storage.ErrInviteLimitReached
+		return storage.InviteCode{}, err
+	}
+	invite = storage.InviteCode{Code: code, CreatedBy: &userID, CreatedAt: time.Now().UTC()}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO invite_codes (code, created_by, created_at) VALUES (?, ?, ?)`, code, userID, invite.CreatedAt); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("insert invite code: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("commit invite code: %w", err)
+	}
+	return invite, nil
+}
+
+func (s *Store) ListInviteCodesByCreator(ctx context.Context, userID int64) ([]storage.InviteCode, error) {
+	return s.loadInviteCodes(ctx, `SELECT `+inviteCodeColumns+` FROM invite_codes WHERE created_by = ? ORDER BY created_at, code`, userID)
+}
+
+func (s *Store) ListInviteCodesByBatch(ctx context.Context, batchID string) ([]storage.InviteCode, error) {
+	return s.loadInviteCodes(ctx, `SELECT `+inviteCodeColumns+` FROM invite_codes WHERE batch_id = ? ORDER BY created_at, code`, batchID)
+}
+
+func (s *Store) CreateUserWithInvite(ctx context.Context, email, passwordHash, code string) (user User, err error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return User{}, errors.New("email must not be empty")
+	}
+	if passwordHash == "" {
+		return User{}, errors.New("password hash must not be empty")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("begin create user with invite: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx, `INSERT INTO users (email, password_hash, created_at, is_verified) VALUES (?, ?, ?, FALSE)`, email, passwordHash, now)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+			return User{}, fmt.Errorf("email already exists: %w", err)
+		}
+		return User{}, fmt.Errorf("insert user: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, fmt.Errorf("last insert id: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, `UPDATE invite_codes SET used_by = ?, used_at = ? WHERE code = ? AND used_by IS NULL`, id, now, code)
+	if err != nil {
+		return User{}, fmt.Errorf("use invite code: %w", err)
+	}
+	if affected, affectedErr := res.RowsAffected(); affectedErr != nil {
+		err = fmt.Errorf("use invite code: %w", affectedErr)
+		return User{}, err
+	} else if affected == 0 {
+		err = storage.ErrInviteCodeInvalid
+		return User{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("commit create user with invite: %w", err)
+	}
+	return User{ID: id, Email: email, PasswordHash: passwordHash, CreatedAt: now}, nil
+}
+
+func (s *Store) loadInviteCodes(ctx context.Context, query string, args ...any) ([]storage.InviteCode, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query invite codes: %w", err)
+	}
+	defer rows.Close()
+
+	invites := make([]storage.InviteCode, 0)
+	for rows.Next() {
+		var invite storage.InviteCode
+		var batchID sql.NullString
+		var createdBy, usedBy sql.NullInt64
+		var used sql.NullTime
+		if err := rows.Scan(&invite.Code, &batchID, &createdBy, &invite.CreatedAt, &usedBy, &used); err != nil {
+			return nil, fmt.Errorf("scan invite code: %w", err)
+		}
+		invite.BatchID = batchID.String
+		if createdBy.Valid {
+			invite.CreatedBy = &createdBy.Int64
+		}
+		if usedBy.Valid {
+			invite.UsedBy = &usedBy.Int64
+		}
+		invite.CreatedAt, invite.UsedAt = invite.CreatedAt.UTC(), expiresAt(used)
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate invite codes: %w", err)
+	}
+	return invites, nil
+}
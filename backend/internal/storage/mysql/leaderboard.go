@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) ListTopOwners(ctx context.Context, orderBy string, limit int) ([]storage.OwnerRank, error) {
+	order := "pixels DESC, points DESC, owner_id"
+	switch orderBy {
+	case storage.LeaderboardByPixels:
+	case storage.LeaderboardByPoints:
+		order = "points DESC, pixels DESC, owner_id"
+	default:
+		return nil, fmt.Errorf("unknown leaderboard order %q", orderBy)
+	}
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT owner_id, COUNT(1) AS pixels, COALESCE(SUM(paid_points), 0) AS points FROM pixels
+                WHERE board_id = '' AND status = 'taken' AND owner_id IS NOT NULL AND owner_id NOT IN (SELECT user_id FROM leaderboard_opt_outs)
+                GROUP BY owner_id ORDER BY `+order+` LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query top owners: %w", err)
+	}
+	defer rows.Close()
+
+	ranks := make([]storage.OwnerRank, 0)
+	for rows.Next() {
+		var rank storage.OwnerRank
+		if err := rows.Scan(&rank.OwnerID, &rank.Pixels, &rank.PointsSpent); err != nil {
+			return nil, fmt.Errorf("scan top owner: %w", err)
+		}
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate top owners: %w", err)
+	}
+	return ranks, nil
+}
+
+func (s *Store) GetLeaderboardOptOut(ctx context.Context, userID int64) (bool, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM leaderboard_opt_outs WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		return false, fmt.Errorf("load leaderboard opt-out: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) SetLeaderboardOptOut(ctx context.Context, userID int64, optOut bool) error {
+	var err error
+	if optOut {
+		_, err = s.db.ExecContext(ctx, `INSERT IGNORE INTO leaderboard_opt_outs (user_id, created_at) VALUES (?, ?)`, userID, time.Now().UTC())
+	} else {
+		_, err = s.db.ExecContext(ctx, `DELETE FROM leaderboard_opt_outs WHERE user_id = ?`, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("save leaderboard opt-out: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// recordLedgerEntry appends a change of the user's balance to the points ledger. It runs in the
+// transaction that changes users.user_points, so the two never disagree.
+func recordLedgerEntry(ctx context.Context, tx *sqltrace.Tx, userID, delta int64, kind, reference, reason string) error {
+	if delta == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO points_ledger (user_id, delta, kind, reference, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, delta, kind, reference, reason, time.Now().UTC(),
+	); err != nil {
+		return fmt.Errorf("record %s in points ledger: %w", kind, err)
+	}
+	return nil
+}
+
+func (s *Store) ListLedgerEntries(ctx context.Context, userID int64) ([]storage.LedgerEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, delta, kind, reference, reason, created_at FROM points_ledger WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query points ledger: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]storage.LedgerEntry, 0)
+	for rows.Next() {
+		var entry storage.LedgerEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Delta, &entry.Kind, &entry.Reference, &entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan points ledger entry: %w", err)
+		}
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate points ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// ledgerBalance is the balance the points ledger gives the users row it is used on.
+const ledgerBalance = `(SELECT COALESCE(SUM(delta), 0) FROM points_ledger WHERE points_ledger.user_id = users.id)`
+
+func (s *Store) RecomputeBalances(ctx context.Context, repair bool) (drifts []storage.BalanceDrift, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin recompute balances: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, user_points, `+ledgerBalance+` FROM users WHERE user_points <> `+ledgerBalance+` ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query balance drift: %w", err)
+	}
+	drifts = make([]storage.BalanceDrift, 0)
+	for rows.Next() {
+		var drift storage.BalanceDrift
+		if err = rows.Scan(&drift.UserID, &drift.Balance, &drift.Ledger); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan balance drift: %w", err)
+		}
+		drifts = append(drifts, drift)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("iterate balance drift: %w", err)
+	}
+
+	if repair && len(drifts) > 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE users SET user_points = `+ledgerBalance+` WHERE user_points <> `+ledgerBalance); err != nil {
+			return nil, fmt.Errorf("repair balances: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit recompute balances: %w", err)
+	}
+	return drifts, nil
+}
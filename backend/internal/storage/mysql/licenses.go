@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const pixelLicenseColumns = "id, user_id, artwork_owner, contact, statement, created_at"
+
+func (s *Store) CreatePixelLicense(ctx context.Context, license PixelLicense) (created PixelLicense, err error) {
+	if license.UserID <= 0 {
+		return PixelLicense{}, errors.New("invalid user id")
+	}
+	if len(license.PixelIDs) == 0 {
+		return PixelLicense{}, errors.New("license must cover at least one pixel")
+	}
+	if license.CreatedAt.IsZero() {
+		license.CreatedAt = time.Now()
+	}
+	license.CreatedAt = license.CreatedAt.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PixelLicense{}, fmt.Errorf("begin create pixel license: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, execErr := tx.ExecContext(
+		ctx,
+		`INSERT INTO pixel_licenses (user_id, artwork_owner, contact, statement, created_at) VALUES (?, ?, ?, ?, ?)`,
+		license.UserID,
+		license.ArtworkOwner,
+		license.Contact,
+		license.Statement,
+		license.CreatedAt,
+	)
+	if execErr != nil {
+		err = fmt.Errorf("insert pixel license: %w", execErr)
+		return PixelLicense{}, err
+	}
+	if license.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("pixel license id: %w", err)
+		return PixelLicense{}, err
+	}
+	for _, pixelID := range license.PixelIDs {
+		if _, execErr := tx.ExecContext(ctx, `INSERT IGNORE INTO pixel_license_pixels (license_id, pixel_id) VALUES (?, ?)`, license.ID, pixelID); execErr != nil {
+			err = fmt.Errorf("insert pixel license pixel: %w", execErr)
+			return PixelLicense{}, err
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit pixel license: %w", commitErr)
+		return PixelLicense{}, err
+	}
+	return license, nil
+}
+
+func (s *Store) ListPixelLicensesByUser(ctx context.Context, userID int64) ([]PixelLicense, error) {
+	return s.queryPixelLicenses(ctx, `SELECT `+pixelLicenseColumns+` FROM pixel_licenses WHERE user_id = ? ORDER BY created_at DESC, id DESC`, userID)
+}
+
+func (s *Store) ListPixelLicensesByPixel(ctx context.Context, pixelID int) ([]PixelLicense, error) {
+	return s.queryPixelLicenses(
+		ctx,
+		`SELECT `+pixelLicenseColumns+` FROM pixel_licenses
+                 WHERE id IN (SELECT license_id FROM pixel_license_pixels WHERE pixel_id = ?) ORDER BY created_at DESC, id DESC`,
+		pixelID,
+	)
+}
+
+func (s *Store) queryPixelLicenses(ctx context.Context, query string, args ...any) ([]PixelLicense, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel licenses: %w", err)
+	}
+	defer rows.Close()
+
+	licenses := make([]PixelLicense, 0)
+	index := make(map[int64]int)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var license PixelLicense
+		if err := rows.Scan(&license.ID, &license.UserID, &license.ArtworkOwner, &license.Contact, &license.Statement, &license.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan pixel license: %w", err)
+		}
+		license.CreatedAt = license.CreatedAt.UTC()
+		license.PixelIDs = make([]int, 0)
+		index[license.ID] = len(licenses)
+		ids = append(ids, license.ID)
+		licenses = append(licenses, license)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel licenses: %w", err)
+	}
+	if len(licenses) == 0 {
+		return licenses, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	pixelRows, err := s.db.QueryContext(ctx, `SELECT license_id, pixel_id FROM pixel_license_pixels WHERE license_id IN (`+placeholders+`) ORDER BY pixel_id`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel license pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var licenseID int64
+		var pixelID int
+		if err := pixelRows.Scan(&licenseID, &pixelID); err != nil {
+			return nil, fmt.Errorf("scan pixel license pixel: %w", err)
+		}
+		if i, ok := index[licenseID]; ok {
+			licenses[i].PixelIDs = append(licenses[i].PixelIDs, pixelID)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel license pixels: %w", err)
+	}
+	return licenses, nil
+}
@@ -0,0 +1,33 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (s *Store) ConsumeLinkNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	nonce = strings.TrimSpace(nonce)
+	if nonce == "" {
+		return false, errors.New("nonce must not be empty")
+	}
+	result, err := s.db.ExecContext(ctx, `INSERT IGNORE INTO used_link_nonces (nonce, expires_at) VALUES (?, ?)`, nonce, expiresAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("insert used link nonce: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("insert used link nonce: %w", err)
+	}
+	return inserted == 1, nil
+}
+
+func (s *Store) DeleteExpiredLinkNonces(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM used_link_nonces WHERE expires_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired link nonces: %w", err)
+	}
+	return result.RowsAffected()
+}
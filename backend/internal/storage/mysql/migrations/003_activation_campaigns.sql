@@ -0,0 +1,8 @@
+ALTER TABLE activation_codes
+    ADD COLUMN IF NOT EXISTS campaign_id VARCHAR(64) NULL,
+    ADD COLUMN IF NOT EXISTS batch_id VARCHAR(64) NULL,
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS redeemed_by BIGINT NULL,
+    ADD COLUMN IF NOT EXISTS redeemed_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_activation_codes_campaign ON activation_codes (campaign_id);
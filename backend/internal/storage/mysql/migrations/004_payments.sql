@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS payments (
+    id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL,
+    bundle_id VARCHAR(64) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    amount_minor BIGINT NOT NULL,
+    points BIGINT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    provider_ref VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_payments_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_payments_user (user_id)
+) ENGINE=InnoDB;
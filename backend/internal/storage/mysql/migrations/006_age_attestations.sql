@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS age_attestations (
+    user_id BIGINT NOT NULL,
+    birth_year INT NOT NULL,
+    minimum_age INT NOT NULL,
+    attested_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id),
+    CONSTRAINT fk_age_attestations_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
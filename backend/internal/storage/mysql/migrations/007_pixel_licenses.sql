@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS pixel_licenses (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    artwork_owner VARCHAR(200) NOT NULL,
+    contact VARCHAR(200) NOT NULL,
+    statement TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_licenses_user (user_id),
+    CONSTRAINT fk_pixel_licenses_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS pixel_license_pixels (
+    license_id BIGINT NOT NULL,
+    pixel_id INT NOT NULL,
+    PRIMARY KEY (license_id, pixel_id),
+    INDEX idx_pixel_license_pixels_pixel (pixel_id),
+    CONSTRAINT fk_pixel_license_pixels_license FOREIGN KEY (license_id) REFERENCES pixel_licenses(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
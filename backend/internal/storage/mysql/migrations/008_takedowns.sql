@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS takedowns (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    claimant_name VARCHAR(200) NOT NULL,
+    claimant_email VARCHAR(255) NOT NULL,
+    work_url VARCHAR(2048) NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP NULL,
+    INDEX idx_takedowns_status (status)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS takedown_pixels (
+    takedown_id BIGINT NOT NULL,
+    pixel_id INT NOT NULL,
+    PRIMARY KEY (takedown_id, pixel_id),
+    CONSTRAINT fk_takedown_pixels_takedown FOREIGN KEY (takedown_id) REFERENCES takedowns(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS takedown_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    takedown_id BIGINT NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor_id BIGINT NULL,
+    note TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    INDEX idx_takedown_events_takedown (takedown_id),
+    CONSTRAINT fk_takedown_events_takedown FOREIGN KEY (takedown_id) REFERENCES takedowns(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
@@ -0,0 +1,9 @@
+CREATE INDEX IF NOT EXISTS idx_pixels_owner ON pixels (owner_id);
+CREATE INDEX IF NOT EXISTS idx_pixels_updated_at ON pixels (updated_at);
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS email_lower VARCHAR(255) AS (LOWER(email)) VIRTUAL;
+
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (email_lower);
+CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires ON verification_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires ON password_reset_tokens (expires_at);
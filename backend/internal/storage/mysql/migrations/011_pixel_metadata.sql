@@ -0,0 +1,3 @@
+ALTER TABLE pixels
+    ADD COLUMN IF NOT EXISTS title VARCHAR(255) NULL,
+    ADD COLUMN IF NOT EXISTS description TEXT NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pixel_clicks (
+    pixel_id INT NOT NULL,
+    owner_id BIGINT NOT NULL,
+    day DATE NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (pixel_id, owner_id, day),
+    INDEX idx_pixel_clicks_owner (owner_id, day)
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS used_link_nonces (
+    nonce VARCHAR(64) NOT NULL PRIMARY KEY,
+    expires_at DATETIME NOT NULL,
+    INDEX idx_used_link_nonces_expires (expires_at)
+) ENGINE=InnoDB;
@@ -0,0 +1,5 @@
+ALTER TABLE verification_tokens ADD COLUMN IF NOT EXISTS hashed TINYINT(1) NOT NULL DEFAULT 0;
+UPDATE verification_tokens SET token = SHA2(token, 256), hashed = 1 WHERE hashed = 0;
+
+ALTER TABLE password_reset_tokens ADD COLUMN IF NOT EXISTS hashed TINYINT(1) NOT NULL DEFAULT 0;
+UPDATE password_reset_tokens SET token = SHA2(token, 256), hashed = 1 WHERE hashed = 0;
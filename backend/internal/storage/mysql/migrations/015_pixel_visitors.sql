@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS pixel_visitors (
+    pixel_id INT NOT NULL,
+    owner_id BIGINT NOT NULL,
+    day DATE NOT NULL,
+    visitor VARCHAR(64) NOT NULL,
+    PRIMARY KEY (pixel_id, owner_id, day, visitor)
+) ENGINE=InnoDB;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS pixel_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    pixel_id INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    color VARCHAR(16) NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    owner_id BIGINT NULL,
+    previous_owner_id BIGINT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_history_pixel (pixel_id, id),
+    INDEX idx_pixel_history_owner (owner_id, id),
+    INDEX idx_pixel_history_previous_owner (previous_owner_id, id)
+) ENGINE=InnoDB;
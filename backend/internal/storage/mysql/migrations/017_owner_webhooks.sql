@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS owner_webhooks (
+    user_id BIGINT NOT NULL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+) ENGINE=InnoDB;
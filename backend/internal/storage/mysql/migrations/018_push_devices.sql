@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS push_devices (
+    token VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    platform VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    INDEX idx_push_devices_user (user_id)
+) ENGINE=InnoDB;
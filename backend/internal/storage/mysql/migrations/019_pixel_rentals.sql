@@ -0,0 +1,5 @@
+ALTER TABLE pixels
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP NULL,
+    ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMP NULL;
+
+CREATE INDEX IF NOT EXISTS idx_pixels_expires_at ON pixels (expires_at);
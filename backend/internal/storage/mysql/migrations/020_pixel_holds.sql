@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS pixel_holds (
+    pixel_id INT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_holds_user (user_id)
+) ENGINE=InnoDB;
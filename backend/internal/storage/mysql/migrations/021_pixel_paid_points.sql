@@ -0,0 +1,2 @@
+ALTER TABLE pixels
+    ADD COLUMN IF NOT EXISTS paid_points BIGINT NULL;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS custom_domains (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    domain VARCHAR(253) NOT NULL,
+    user_id BIGINT NOT NULL,
+    pixel_id INT NULL,
+    token VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMP NULL,
+    last_checked_at TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE KEY uk_custom_domains_domain (domain),
+    INDEX idx_custom_domains_user (user_id)
+) ENGINE=InnoDB;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS pixel_refunds (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    admin_id BIGINT NOT NULL,
+    pixel_ids TEXT NOT NULL,
+    points BIGINT NOT NULL,
+    reason VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_refunds_user (user_id)
+) ENGINE=InnoDB;
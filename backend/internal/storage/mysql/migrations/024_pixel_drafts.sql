@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS pixel_drafts (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_drafts_user (user_id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS pixel_draft_pixels (
+    draft_id BIGINT NOT NULL,
+    pixel_id INT NOT NULL,
+    color VARCHAR(16) NOT NULL DEFAULT '',
+    url TEXT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    PRIMARY KEY (draft_id, pixel_id),
+    CONSTRAINT fk_pixel_draft_pixels_draft FOREIGN KEY (draft_id) REFERENCES pixel_drafts(id) ON DELETE CASCADE
+) ENGINE=InnoDB;
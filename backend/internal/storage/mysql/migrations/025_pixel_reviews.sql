@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS pixel_reviews (
+    pixel_id INT NOT NULL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    queued_at TIMESTAMP NOT NULL,
+    INDEX idx_pixel_reviews_user (user_id)
+) ENGINE=InnoDB;
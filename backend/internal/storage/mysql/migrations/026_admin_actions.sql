@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS admin_actions (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    admin_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(500) NOT NULL,
+    pixel_ids MEDIUMTEXT NOT NULL,
+    reason VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+) ENGINE=InnoDB;
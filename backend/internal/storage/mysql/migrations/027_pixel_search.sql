@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS pixel_search (
+    pixel_id INT NOT NULL PRIMARY KEY,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    url TEXT NOT NULL,
+    FULLTEXT INDEX idx_pixel_search_text (title, description, url)
+) ENGINE=InnoDB;
+
+INSERT IGNORE INTO pixel_search (pixel_id, title, description, url)
+SELECT id, COALESCE(title, ''), COALESCE(description, ''), COALESCE(url, '')
+FROM pixels
+WHERE status = 'taken'
+  AND (COALESCE(title, '') <> '' OR COALESCE(description, '') <> '' OR COALESCE(url, '') <> '')
+  AND NOT EXISTS (SELECT 1 FROM (SELECT pixel_id FROM pixel_search LIMIT 1) AS indexed);
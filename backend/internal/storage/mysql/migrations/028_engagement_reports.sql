@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS engagement_reports (
+    user_id BIGINT NOT NULL PRIMARY KEY,
+    token VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_sent_at TIMESTAMP NULL,
+    UNIQUE KEY idx_engagement_reports_token (token)
+) ENGINE=InnoDB;
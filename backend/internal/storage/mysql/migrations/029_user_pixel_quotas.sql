@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS user_pixel_quotas (
+    user_id BIGINT NOT NULL PRIMARY KEY,
+    max_pixels INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+) ENGINE=InnoDB;
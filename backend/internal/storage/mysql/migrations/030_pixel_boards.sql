@@ -0,0 +1,5 @@
+ALTER TABLE pixels
+    ADD COLUMN IF NOT EXISTS board_id VARCHAR(64) NOT NULL DEFAULT '' FIRST;
+
+ALTER TABLE pixel_history
+    ADD COLUMN IF NOT EXISTS board_id VARCHAR(64) NOT NULL DEFAULT '' AFTER id;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS invite_codes (
+    code VARCHAR(64) NOT NULL PRIMARY KEY,
+    batch_id VARCHAR(64) NULL,
+    created_by BIGINT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_by BIGINT NULL,
+    used_at TIMESTAMP NULL,
+    INDEX idx_invite_codes_created_by (created_by),
+    INDEX idx_invite_codes_batch (batch_id)
+) ENGINE=InnoDB;
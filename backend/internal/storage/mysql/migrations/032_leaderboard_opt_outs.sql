@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS leaderboard_opt_outs (
+    user_id BIGINT NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL
+) ENGINE=InnoDB;
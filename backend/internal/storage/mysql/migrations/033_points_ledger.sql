@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS points_ledger (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    delta BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    reference MEDIUMTEXT NOT NULL,
+    reason VARCHAR(500) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    INDEX idx_points_ledger_user (user_id)
+) ENGINE=InnoDB;
+
+INSERT INTO points_ledger (user_id, delta, kind, reference, reason, created_at)
+SELECT id, user_points, 'opening_balance', '', '', CURRENT_TIMESTAMP
+FROM users
+WHERE user_points <> 0
+  AND NOT EXISTS (SELECT 1 FROM (SELECT id FROM points_ledger LIMIT 1) AS recorded);
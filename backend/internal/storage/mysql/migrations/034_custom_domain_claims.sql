@@ -0,0 +1,7 @@
+ALTER TABLE custom_domains
+    ADD COLUMN IF NOT EXISTS verified_domain VARCHAR(253) AS (IF(verified_at IS NULL, NULL, domain)) STORED;
+
+ALTER TABLE custom_domains DROP INDEX IF EXISTS uk_custom_domains_domain;
+
+CREATE INDEX IF NOT EXISTS idx_custom_domains_domain ON custom_domains (domain);
+CREATE UNIQUE INDEX IF NOT EXISTS uk_custom_domains_verified ON custom_domains (verified_domain);
@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const paymentColumns = "id, user_id, bundle_id, currency, amount_minor, points, status, COALESCE(provider_ref, ''), created_at, completed_at"
+
+func (s *Store) CreatePayment(ctx context.Context, payment Payment) error {
+	if strings.TrimSpace(payment.ID) == "" {
+		return errors.New("payment id must not be empty")
+	}
+	if payment.UserID <= 0 {
+		return errors.New("invalid user id")
+	}
+	if payment.AmountMinor <= 0 || payment.Points <= 0 {
+		return errors.New("payment amount and points must be positive")
+	}
+	status := payment.Status
+	if status == "" {
+		status = storage.PaymentStatusPending
+	}
+	created := payment.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO payments (id, user_id, bundle_id, currency, amount_minor, points, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		payment.ID,
+		payment.UserID,
+		payment.BundleID,
+		strings.ToUpper(payment.Currency),
+		payment.AmountMinor,
+		payment.Points,
+		status,
+		created.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetPayment(ctx context.Context, id string) (Payment, error) {
+	return scanPayment(s.db.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = ?`, strings.TrimSpace(id)))
+}
+
+func (s *Store) ListPaymentsByUser(ctx context.Context, userID int64) ([]Payment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make([]Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate payments: %w", err)
+	}
+	return payments, nil
+}
+
+func (s *Store) CompletePayment(ctx context.Context, id, providerRef string) (payment Payment, user User, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Payment{}, User{}, fmt.Errorf("begin complete payment: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	payment, err = finishPayment(ctx, tx, id, providerRef, storage.PaymentStatusCompleted)
+	if err != nil {
+		return Payment{}, User{}, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE users SET user_points = user_points + ? WHERE id = ?`, payment.Points, payment.UserID); err != nil {
+		return Payment{}, User{}, fmt.Errorf("credit payment points: %w", err)
+	}
+	if err = recordLedgerEntry(ctx, tx, payment.UserID, payment.Points, storage.LedgerPayment, payment.ID, ""); err != nil {
+		return Payment{}, User{}, err
+	}
+
+	row := tx.QueryRowContext(ctx, `SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = ?`, payment.UserID)
+	user, err = scanUser(row)
+	if err != nil {
+		return Payment{}, User{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Payment{}, User{}, fmt.Errorf("commit complete payment: %w", err)
+	}
+	return payment, user, nil
+}
+
+func (s *Store) FailPayment(ctx context.Context, id, providerRef string) (payment Payment, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Payment{}, fmt.Errorf("begin fail payment: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	payment, err = finishPayment(ctx, tx, id, providerRef, storage.PaymentStatusFailed)
+	if err != nil {
+		return Payment{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return Payment{}, fmt.Errorf("commit fail payment: %w", err)
+	}
+	return payment, nil
+}
+
+// finishPayment moves a pending payment to its final status inside tx.
+func finishPayment(ctx context.Context, tx *sqltrace.Tx, id, providerRef, status string) (Payment, error) {
+	id = strings.TrimSpace(id)
+	payment, err := scanPayment(tx.QueryRowContext(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = ? FOR UPDATE`, id))
+	if err != nil {
+		return Payment{}, err
+	}
+	if payment.Status != storage.PaymentStatusPending {
+		return Payment{}, storage.ErrPaymentNotPending
+	}
+
+	now := time.Now().UTC()
+	ref := strings.TrimSpace(providerRef)
+	if _, err := tx.ExecContext(
+		ctx,
+		`UPDATE payments SET status = ?, provider_ref = ?, completed_at = ? WHERE id = ?`,
+		status,
+		ref,
+		now,
+		id,
+	); err != nil {
+		return Payment{}, fmt.Errorf("update payment status: %w", err)
+	}
+
+	payment.Status = status
+	payment.ProviderRef = ref
+	payment.CompletedAt = &now
+	return payment, nil
+}
+
+func scanPayment(row rowScanner) (Payment, error) {
+	var payment Payment
+	var completed sql.NullTime
+	if err := row.Scan(&payment.ID, &payment.UserID, &payment.BundleID, &payment.Currency, &payment.AmountMinor, &payment.Points, &payment.Status, &payment.ProviderRef, &payment.CreatedAt, &completed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Payment{}, sql.ErrNoRows
+		}
+		return Payment{}, fmt.Errorf("scan payment: %w", err)
+	}
+	payment.CreatedAt = payment.CreatedAt.UTC()
+	if completed.Valid {
+		t := completed.Time.UTC()
+		payment.CompletedAt = &t
+	}
+	return payment, nil
+}
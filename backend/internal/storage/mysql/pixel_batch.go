@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// pixelBatch collects the pixel writes of one purchase, so they reach the database in a fixed
+// number of statements however many pixels it covers. Only main grid writes are indexed for
+// search and release pixel holds.
+type pixelBatch struct {
+	board       string
+	transitions []pixelTransition
+	writes      map[int]*pixelWrite
+	order       []int
+	acquired    []any
+	charged     int64
+}
+
+// pixelWrite is the last state of a pixel in the batch, with the columns that depend on the
+// states before it.
+type pixelWrite struct {
+	pixel       Pixel
+	resetExpiry bool
+	setPaid     bool
+	paid        sql.NullInt64
+}
+
+func newPixelBatch(board string) *pixelBatch {
+	return &pixelBatch{board: board, writes: make(map[int]*pixelWrite)}
+}
+
+// add queues the write of after over before, charged points for it. A pixel written twice keeps
+// the last state, as if both writes had been applied in turn.
+func (b *pixelBatch) add(before, after Pixel, charged int64, purchased bool) {
+	b.transitions = append(b.transitions, pixelTransition{before: before, after: after})
+	b.charged += charged
+	if before.OwnerID == nil && after.OwnerID != nil {
+		b.acquired = append(b.acquired, after.ID)
+	}
+	write, ok := b.writes[after.ID]
+	if !ok {
+		write = &pixelWrite{}
+		b.writes[after.ID] = write
+		b.order = append(b.order, after.ID)
+	}
+	write.pixel = after
+	write.resetExpiry = write.resetExpiry || resetsExpiryWarning(before, after)
+	if paid, changed := paidPoints(before, after, charged, purchased); changed {
+		write.setPaid, write.paid = true, paid
+	}
+}
+
+// write applies the batch in tx and charges its points to userID at priceVersion. The rows are joined as a
+// derived table, so every pixel gets its own values in a single UPDATE.
+func (b *pixelBatch) write(ctx context.Context, tx *sqltrace.Tx, userID int64, priceVersion string) error {
+	if len(b.order) == 0 {
+		return nil
+	}
+	selects := make([]string, len(b.order))
+	args := make([]any, 0, 12*len(b.order)+1)
+	final := make([]Pixel, len(b.order))
+	for i, id := range b.order {
+		write := b.writes[id]
+		pixel := write.pixel
+		final[i] = pixel
+		if i == 0 {
+			selects[i] = `SELECT ? AS id, ? AS status, ? AS color, ? AS url, ? AS title, ? AS description, ? AS owner_id, ? AS updated_at, ? AS expires_at, ? AS reset_expiry, ? AS set_paid, ? AS paid_points`
+		} else {
+			selects[i] = `SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?`
+		}
+		var expires, paid any
+		if pixel.ExpiresAt != nil {
+			expires = pixel.ExpiresAt.UTC()
+		}
+		if write.paid.Valid {
+			paid = write.paid.Int64
+		}
+		args = append(args, pixel.ID, pixel.Status, pixel.Color, pixel.URL, pixel.Title, pixel.Description, ownerValue(pixel.OwnerID), pixel.UpdatedAt, expires, write.resetExpiry, write.setPaid, paid)
+	}
+	_, err := tx.ExecContext(
+		ctx,
+		`UPDATE pixels p JOIN (`+strings.Join(selects, ` UNION ALL `)+`) v ON p.board_id = ? AND p.id = v.id SET `+
+			`p.status = v.status, p.color = v.color, p.url = v.url, p.title = v.title, p.description = v.description, p.owner_id = v.owner_id, p.updated_at = v.updated_at, p.expires_at = v.expires_at, `+
+			`p.expiry_notified_at = IF(v.reset_expiry, NULL, p.expiry_notified_at), p.paid_points = IF(v.set_paid, v.paid_points, p.paid_points)`,
+		append(args, b.board)...,
+	)
+	if err != nil {
+		return fmt.Errorf("update pixels: %w", err)
+	}
+	if err := recordPixelChanges(ctx, tx, b.board, b.transitions); err != nil {
+		return err
+	}
+	if b.board == storage.MainBoard {
+		if err := indexPixelsSearch(ctx, tx, final); err != nil {
+			return err
+		}
+		if len(b.acquired) > 0 {
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(b.acquired)), ", ")
+			if _, err := tx.ExecContext(ctx, `DELETE FROM pixel_holds WHERE pixel_id IN (`+placeholders+`)`, b.acquired...); err != nil {
+				return fmt.Errorf("release pixel holds: %w", err)
+			}
+		}
+	}
+	if b.charged > 0 {
+		res, err := tx.ExecContext(ctx, `UPDATE users SET user_points = user_points - ? WHERE id = ? AND user_points >= ?`, b.charged, userID, b.charged)
+		if err != nil {
+			return fmt.Errorf("deduct user points: %w", err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("deduct user points rows affected: %w", err)
+		}
+		if affected == 0 {
+			return storage.ErrInsufficientPoints
+		}
+		if err := recordLedgerEntry(ctx, tx, userID, -b.charged, storage.LedgerPurchase, priceVersion, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
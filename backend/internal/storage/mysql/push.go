@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const pushDeviceColumns = "token, user_id, platform, created_at, last_seen_at"
+
+func (s *Store) RegisterPushDevice(ctx context.Context, device storage.PushDevice) (storage.PushDevice, error) {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO push_devices (token, user_id, platform, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?)
+                 ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), platform = VALUES(platform), last_seen_at = VALUES(last_seen_at)`,
+		device.Token,
+		device.UserID,
+		device.Platform,
+		now,
+		now,
+	)
+	if err != nil {
+		return storage.PushDevice{}, fmt.Errorf("register push device: %w", err)
+	}
+	devices, err := s.loadPushDevices(ctx, `SELECT `+pushDeviceColumns+` FROM push_devices WHERE token = ?`, device.Token)
+	if err != nil {
+		return storage.PushDevice{}, err
+	}
+	if len(devices) == 0 {
+		return storage.PushDevice{}, errors.New("push device vanished after register")
+	}
+	return devices[0], nil
+}
+
+func (s *Store) ListPushDevices(ctx context.Context, userID int64) ([]storage.PushDevice, error) {
+	return s.loadPushDevices(ctx, `SELECT `+pushDeviceColumns+` FROM push_devices WHERE user_id = ? ORDER BY last_seen_at DESC`, userID)
+}
+
+func (s *Store) DeletePushDevice(ctx context.Context, userID int64, token string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM push_devices WHERE user_id = ? AND token = ?`, userID, token); err != nil {
+		return fmt.Errorf("delete push device: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) loadPushDevices(ctx context.Context, query string, args ...any) ([]storage.PushDevice, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query push devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := make([]storage.PushDevice, 0)
+	for rows.Next() {
+		var device storage.PushDevice
+		if err := rows.Scan(&device.Token, &device.UserID, &device.Platform, &device.CreatedAt, &device.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan push device: %w", err)
+		}
+		device.CreatedAt = device.CreatedAt.UTC()
+		device.LastSeenAt = device.LastSeenAt.UTC()
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate push devices: %w", err)
+	}
+	return devices, nil
+}
@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// SetPixelQuota sets how many pixels one account may own; 0 means no limit.
+func (s *Store) SetPixelQuota(maxPixels int) {
+	if s != nil {
+		s.pixelQuota = max(maxPixels, 0)
+	}
+}
+
+func (s *Store) GetUserPixelQuota(ctx context.Context, userID int64) (int, error) {
+	var maxPixels int
+	if err := s.db.QueryRowContext(ctx, `SELECT max_pixels FROM user_pixel_quotas WHERE user_id = ?`, userID).Scan(&maxPixels); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, sql.ErrNoRows
+		}
+		return 0, fmt.Errorf("load user pixel quota: %w", err)
+	}
+	return maxPixels, nil
+}
+
+func (s *Store) SetUserPixelQuota(ctx context.Context, userID int64, maxPixels int) error {
+	if userID <= 0 {
+		return errors.New("invalid user id")
+	}
+	if maxPixels < 0 {
+		return errors.New("pixel quota must not be negative")
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO user_pixel_quotas (user_id, max_pixels, updated_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE max_pixels = VALUES(max_pixels), updated_at = VALUES(updated_at)`,
+		userID,
+		maxPixels,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("save user pixel quota: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteUserPixelQuota(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM user_pixel_quotas WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete user pixel quota: %w", err)
+	}
+	return nil
+}
+
+// pixelQuota counts how many more pixels an owner may acquire within one transaction. A nil
+// quota is unlimited.
+type pixelQuota struct {
+	remaining int
+}
+
+// take reports whether the owner may acquire one more pixel and counts it.
+func (q *pixelQuota) take() bool {
+	if q == nil {
+		return true
+	}
+	if q.remaining <= 0 {
+		return false
+	}
+	q.remaining--
+	return true
+}
+
+// release gives back the place of a pixel the owner frees.
+func (q *pixelQuota) release() {
+	if q != nil {
+		q.remaining++
+	}
+}
+
+// loadPixelQuota returns the quota left to ownerID: its override, or defaultMax without one,
+// less the pixels it owns. It returns nil when the owner has no limit. The owner's row is locked
+// so concurrent purchases for the same owner count each other's pixels.
+func loadPixelQuota(ctx context.Context, tx *sqltrace.Tx, ownerID int64, defaultMax int) (*pixelQuota, error) {
+	if ownerID <= 0 {
+		return nil, nil
+	}
+	limit := defaultMax
+	if err := tx.QueryRowContext(ctx, `SELECT max_pixels FROM user_pixel_quotas WHERE user_id = ?`, ownerID).Scan(&limit); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("load user pixel quota: %w", err)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	var id int64
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, ownerID).Scan(&id); err != nil {
+		return nil, fmt.Errorf("lock pixel owner: %w", err)
+	}
+	var owned int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pixels WHERE owner_id = ?`, ownerID).Scan(&owned); err != nil {
+		return nil, fmt.Errorf("count owned pixels: %w", err)
+	}
+	return &pixelQuota{remaining: limit - owned}, nil
+}
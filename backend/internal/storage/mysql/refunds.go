@@ -0,0 +1,139 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const pixelRefundColumns = "id, user_id, admin_id, pixel_ids, points, reason, created_at"
+
+func (s *Store) RefundPixels(ctx context.Context, refund storage.PixelRefund, points *int64) (_ storage.PixelRefund, release storage.PixelRelease, err error) {
+	if len(refund.PixelIDs) == 0 {
+		return storage.PixelRefund{}, storage.PixelRelease{}, errors.New("refund needs at least one pixel")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("begin refund pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	pixels, paid, err := releaseOwnedPixels(ctx, tx, refund.UserID, refund.PixelIDs)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, err
+	}
+	if len(pixels) == 0 {
+		return storage.PixelRefund{}, storage.PixelRelease{}, tx.Rollback()
+	}
+	release.Pixels = pixels
+	refund.PixelIDs = make([]int, len(pixels))
+	for i, pixel := range pixels {
+		refund.PixelIDs[i] = pixel.ID
+		if paid[i].Valid {
+			release.RefundedPoints += paid[i].Int64
+		}
+	}
+	if points != nil {
+		release.RefundedPoints = *points
+	}
+	refund.Points = release.RefundedPoints
+	refund.CreatedAt = time.Now().UTC()
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO pixel_refunds (user_id, admin_id, pixel_ids, points, reason, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		refund.UserID,
+		refund.AdminID,
+		joinPixelIDs(refund.PixelIDs),
+		refund.Points,
+		refund.Reason,
+		refund.CreatedAt,
+	)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("insert pixel refund: %w", err)
+	}
+	if refund.ID, err = res.LastInsertId(); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("pixel refund id: %w", err)
+	}
+	// The credit goes through the ledger with the refund's reason, so the balance can be traced back to it.
+	if refund.Points != 0 {
+		if _, err = tx.ExecContext(ctx, `UPDATE users SET user_points = user_points + ? WHERE id = ?`, refund.Points, refund.UserID); err != nil {
+			return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("credit refunded points: %w", err)
+		}
+		if err = recordLedgerEntry(ctx, tx, refund.UserID, refund.Points, storage.LedgerAdminRefund, strconv.FormatInt(refund.ID, 10), refund.Reason); err != nil {
+			return storage.PixelRefund{}, storage.PixelRelease{}, err
+		}
+	}
+
+	row := tx.QueryRowContext(ctx, `SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = ?`, refund.UserID)
+	if release.User, err = scanUser(row); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("commit refund pixels: %w", err)
+	}
+	return refund, release, nil
+}
+
+func (s *Store) ListPixelRefunds(ctx context.Context, userID int64) ([]storage.PixelRefund, error) {
+	query := `SELECT ` + pixelRefundColumns + ` FROM pixel_refunds`
+	var args []any
+	if userID != 0 {
+		query += ` WHERE user_id = ?`
+		args = append(args, userID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := make([]storage.PixelRefund, 0)
+	for rows.Next() {
+		var refund storage.PixelRefund
+		var ids string
+		if err := rows.Scan(&refund.ID, &refund.UserID, &refund.AdminID, &ids, &refund.Points, &refund.Reason, &refund.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan pixel refund: %w", err)
+		}
+		if refund.PixelIDs, err = splitPixelIDs(ids); err != nil {
+			return nil, fmt.Errorf("parse pixel refund %d pixel_ids: %w", refund.ID, err)
+		}
+		refund.CreatedAt = refund.CreatedAt.UTC()
+		refunds = append(refunds, refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+func joinPixelIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitPixelIDs(value string) ([]int, error) {
+	ids := make([]int, 0)
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,131 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const rentedPixelColumns = "id, status, color, url, owner_id, expires_at"
+
+func expiresAt(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	at := value.Time.UTC()
+	return &at
+}
+
+// expiryWarningReset clears the expiry warning mark when the pixel changes hands or its rental
+// changes, so the next rental is warned about again.
+func expiryWarningReset(before, after Pixel) string {
+	if !resetsExpiryWarning(before, after) {
+		return ""
+	}
+	return ", expiry_notified_at = NULL"
+}
+
+func resetsExpiryWarning(before, after Pixel) bool {
+	return !sameOwner(before.OwnerID, after.OwnerID) || !sameExpiry(before.ExpiresAt, after.ExpiresAt)
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+func (s *Store) ClaimExpiringPixels(ctx context.Context, before time.Time) (claimed []Pixel, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim expiring pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	claimed, err = loadRentedPixels(ctx, tx,
+		`SELECT `+rentedPixelColumns+` FROM pixels WHERE `+onMainBoard+` AND owner_id IS NOT NULL AND expires_at IS NOT NULL AND expiry_notified_at IS NULL AND expires_at <= ? ORDER BY expires_at, id FOR UPDATE`,
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		args := make([]any, 0, len(claimed)+1)
+		args = append(args, time.Now().UTC())
+		for _, pixel := range claimed {
+			args = append(args, pixel.ID)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(claimed)), ", ")
+		if _, err = tx.ExecContext(ctx, `UPDATE pixels SET expiry_notified_at = ? WHERE `+onMainBoard+` AND id IN (`+placeholders+`)`, args...); err != nil {
+			return nil, fmt.Errorf("mark expiring pixels: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claim expiring pixels: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *Store) ReleaseExpiredPixels(ctx context.Context, now time.Time) (released []Pixel, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin release expired pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	at := now.UTC()
+	released, err = loadRentedPixels(ctx, tx, `SELECT `+rentedPixelColumns+` FROM pixels WHERE `+onMainBoard+` AND expires_at IS NOT NULL AND expires_at <= ? ORDER BY id FOR UPDATE`, at)
+	if err != nil {
+		return nil, err
+	}
+	if err = freePixels(ctx, tx, released, at); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit release expired pixels: %w", err)
+	}
+	return released, nil
+}
+
+func loadRentedPixels(ctx context.Context, tx *sqltrace.Tx, query string, args ...any) ([]Pixel, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rented pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var pixel Pixel
+		var color, url sql.NullString
+		var owner sql.NullInt64
+		var expires sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &color, &url, &owner, &expires); err != nil {
+			return nil, fmt.Errorf("scan rented pixel: %w", err)
+		}
+		pixel.Color, pixel.URL = color.String, url.String
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		pixel.ExpiresAt = expiresAt(expires)
+		pixels = append(pixels, pixel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rented pixels: %w", err)
+	}
+	return pixels, nil
+}
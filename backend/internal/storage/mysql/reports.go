@@ -0,0 +1,132 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const engagementReportColumns = "user_id, token, created_at, last_sent_at"
+
+func (s *Store) GetEngagementReportSubscription(ctx context.Context, userID int64) (storage.EngagementReportSubscription, error) {
+	subscriptions, err := s.loadEngagementReports(ctx, s.db, `SELECT `+engagementReportColumns+` FROM engagement_reports WHERE user_id = ?`, userID)
+	if err != nil {
+		return storage.EngagementReportSubscription{}, err
+	}
+	if len(subscriptions) == 0 {
+		return storage.EngagementReportSubscription{}, sql.ErrNoRows
+	}
+	return subscriptions[0], nil
+}
+
+func (s *Store) SubscribeEngagementReport(ctx context.Context, userID int64, token string) (storage.EngagementReportSubscription, error) {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT IGNORE INTO engagement_reports (user_id, token, created_at) VALUES (?, ?, ?)`,
+		userID,
+		token,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return storage.EngagementReportSubscription{}, fmt.Errorf("subscribe engagement report: %w", err)
+	}
+	subscription, err := s.GetEngagementReportSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.EngagementReportSubscription{}, errors.New("engagement report subscription vanished after insert")
+	}
+	return subscription, err
+}
+
+func (s *Store) UnsubscribeEngagementReport(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM engagement_reports WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("unsubscribe engagement report: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UnsubscribeEngagementReportByToken(ctx context.Context, token string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM engagement_reports WHERE token = ?`, token)
+	if err != nil {
+		return false, fmt.Errorf("unsubscribe engagement report: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unsubscribe engagement report rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (s *Store) ClaimEngagementReports(ctx context.Context, before, now time.Time) (claimed []storage.EngagementReportSubscription, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim engagement reports: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	claimed, err = s.loadEngagementReports(ctx, tx,
+		`SELECT `+engagementReportColumns+` FROM engagement_reports WHERE last_sent_at IS NULL OR last_sent_at < ? ORDER BY user_id FOR UPDATE`,
+		before.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		args := make([]any, 0, len(claimed)+1)
+		args = append(args, now.UTC())
+		for _, subscription := range claimed {
+			args = append(args, subscription.UserID)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(claimed)), ", ")
+		if _, err = tx.ExecContext(ctx, `UPDATE engagement_reports SET last_sent_at = ? WHERE user_id IN (`+placeholders+`)`, args...); err != nil {
+			return nil, fmt.Errorf("mark engagement reports: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claim engagement reports: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *Store) ReleaseEngagementReport(ctx context.Context, userID int64, lastSentAt *time.Time) error {
+	var previous any
+	if lastSentAt != nil {
+		previous = lastSentAt.UTC()
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE engagement_reports SET last_sent_at = ? WHERE user_id = ?`, previous, userID); err != nil {
+		return fmt.Errorf("release engagement report: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) loadEngagementReports(ctx context.Context, q queryer, query string, args ...any) ([]storage.EngagementReportSubscription, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query engagement reports: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]storage.EngagementReportSubscription, 0)
+	for rows.Next() {
+		var subscription storage.EngagementReportSubscription
+		var sent sql.NullTime
+		if err := rows.Scan(&subscription.UserID, &subscription.Token, &subscription.CreatedAt, &sent); err != nil {
+			return nil, fmt.Errorf("scan engagement report: %w", err)
+		}
+		subscription.CreatedAt = subscription.CreatedAt.UTC()
+		subscription.LastSentAt = expiresAt(sent)
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate engagement reports: %w", err)
+	}
+	return subscriptions, nil
+}
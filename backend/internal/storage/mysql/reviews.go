@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// pixelReviewQuery selects the queued pixels still owned by the user who queued them.
+const pixelReviewQuery = `SELECT r.pixel_id, r.user_id, p.color, p.url, COALESCE(p.title, ''), COALESCE(p.description, ''), r.queued_at FROM pixel_reviews r JOIN pixels p ON p.board_id = '' AND p.id = r.pixel_id AND p.owner_id = r.user_id`
+
+func (s *Store) QueuePixelReviews(ctx context.Context, userID int64, pixelIDs []int, at time.Time) error {
+	if len(pixelIDs) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(pixelIDs)*3)
+	for _, id := range pixelIDs {
+		args = append(args, id, userID, at.UTC())
+	}
+	query := `INSERT INTO pixel_reviews (pixel_id, user_id, queued_at) VALUES ` +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(pixelIDs)), ", ") +
+		` ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), queued_at = VALUES(queued_at)`
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("queue pixel reviews: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListPixelReviews(ctx context.Context) ([]storage.PixelReview, error) {
+	return s.loadPixelReviews(ctx, s.db, pixelReviewQuery+` ORDER BY r.queued_at, r.pixel_id`)
+}
+
+func (s *Store) ListPendingReviewPixelIDs(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT r.pixel_id FROM pixel_reviews r JOIN pixels p ON p.board_id = '' AND p.id = r.pixel_id AND p.owner_id = r.user_id ORDER BY r.pixel_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query pending review pixels: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan pending review pixel: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending review pixels: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *Store) ResolvePixelReviews(ctx context.Context, pixelIDs []int, decision string) (reviews []storage.PixelReview, err error) {
+	if decision != storage.PixelReviewApproved && decision != storage.PixelReviewRejected {
+		return nil, fmt.Errorf("invalid review decision %q", decision)
+	}
+	if len(pixelIDs) == 0 {
+		return nil, errors.New("resolve needs at least one pixel")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin resolve pixel reviews: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	in := `(` + strings.TrimSuffix(strings.Repeat("?, ", len(pixelIDs)), ", ") + `)`
+	args := make([]any, len(pixelIDs))
+	for i, id := range pixelIDs {
+		args[i] = id
+	}
+	if reviews, err = s.loadPixelReviews(ctx, tx, pixelReviewQuery+` WHERE r.pixel_id IN `+in+` ORDER BY r.pixel_id FOR UPDATE`, args...); err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM pixel_reviews WHERE pixel_id IN `+in, args...); err != nil {
+		return nil, fmt.Errorf("delete pixel reviews: %w", err)
+	}
+
+	if decision == storage.PixelReviewRejected {
+		byUser := make(map[int64][]int)
+		for _, review := range reviews {
+			byUser[review.UserID] = append(byUser[review.UserID], review.PixelID)
+		}
+		refunded := make(map[int]int64)
+		for userID, owned := range byUser {
+			pixels, paid, releaseErr := releaseOwnedPixels(ctx, tx, userID, owned)
+			if releaseErr != nil {
+				err = releaseErr
+				return nil, err
+			}
+			var points int64
+			for i, pixel := range pixels {
+				if paid[i].Valid {
+					refunded[pixel.ID] = paid[i].Int64
+					points += paid[i].Int64
+				}
+			}
+			if points > 0 {
+				if _, err = tx.ExecContext(ctx, `UPDATE users SET user_points = user_points + ? WHERE id = ?`, points, userID); err != nil {
+					return nil, fmt.Errorf("credit rejected pixel points: %w", err)
+				}
+				if err = recordLedgerEntry(ctx, tx, userID, points, storage.LedgerReviewRefund, joinPixelIDs(pixelIDsOf(pixels)), ""); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for i := range reviews {
+			reviews[i].RefundedPoints = refunded[reviews[i].PixelID]
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit resolve pixel reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+func (s *Store) loadPixelReviews(ctx context.Context, q queryer, query string, args ...any) ([]storage.PixelReview, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := make([]storage.PixelReview, 0)
+	for rows.Next() {
+		var review storage.PixelReview
+		if err := rows.Scan(&review.PixelID, &review.UserID, &review.Color, &review.URL, &review.Title, &review.Description, &review.QueuedAt); err != nil {
+			return nil, fmt.Errorf("scan pixel review: %w", err)
+		}
+		review.QueuedAt = review.QueuedAt.UTC()
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel reviews: %w", err)
+	}
+	return reviews, nil
+}
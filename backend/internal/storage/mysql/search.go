@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// pixelSearchable reports whether pixel belongs in the search index: a taken pixel with a link,
+// title or description.
+func pixelSearchable(pixel Pixel) bool {
+	return pixel.Status == "taken" && (pixel.URL != "" || pixel.Title != "" || pixel.Description != "")
+}
+
+// indexPixelSearch replaces the search entry of pixel with its current texts.
+func indexPixelSearch(ctx context.Context, tx *sqltrace.Tx, pixel Pixel) error {
+	return indexPixelsSearch(ctx, tx, []Pixel{pixel})
+}
+
+// indexPixelsSearch is indexPixelSearch for many pixels in at most two statements.
+func indexPixelsSearch(ctx context.Context, tx *sqltrace.Tx, pixels []Pixel) error {
+	var removed []any
+	var rows []string
+	var args []any
+	for _, pixel := range pixels {
+		if !pixelSearchable(pixel) {
+			removed = append(removed, pixel.ID)
+			continue
+		}
+		rows = append(rows, `(?, ?, ?, ?)`)
+		args = append(args, pixel.ID, pixel.Title, pixel.Description, pixel.URL)
+	}
+	if len(removed) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(removed)), ", ")
+		if _, err := tx.ExecContext(ctx, `DELETE FROM pixel_search WHERE pixel_id IN (`+placeholders+`)`, removed...); err != nil {
+			return fmt.Errorf("remove pixels from search: %w", err)
+		}
+	}
+	if len(rows) > 0 {
+		if _, err := tx.ExecContext(ctx, `REPLACE INTO pixel_search (pixel_id, title, description, url) VALUES `+strings.Join(rows, ", "), args...); err != nil {
+			return fmt.Errorf("index pixels for search: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) SearchPixels(ctx context.Context, terms []string, limit int) ([]Pixel, error) {
+	if len(terms) == 0 {
+		return []Pixel{}, nil
+	}
+	// Every term has to match, as a prefix of a word of the title, description or link.
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `+"` + strings.ReplaceAll(term, `"`, "") + `"*`
+	}
+	against := strings.Join(match, " ")
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT p.id, p.status, COALESCE(p.color, ''), COALESCE(p.url, ''), COALESCE(p.title, ''), COALESCE(p.description, '')
+                FROM pixel_search s JOIN pixels p ON p.board_id = '' AND p.id = s.pixel_id
+                WHERE MATCH(s.title, s.description, s.url) AGAINST (? IN BOOLEAN MODE) AND p.status = 'taken'
+                ORDER BY MATCH(s.title, s.description, s.url) AGAINST (? IN BOOLEAN MODE) DESC, p.id
+                LIMIT ?`,
+		against,
+		against,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var pixel Pixel
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description); err != nil {
+			return nil, fmt.Errorf("scan found pixel: %w", err)
+		}
+		pixels = append(pixels, pixel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate found pixels: %w", err)
+	}
+	return pixels, nil
+}
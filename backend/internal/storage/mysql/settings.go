@@ -0,0 +1,47 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (s *Store) GetSetting(ctx context.Context, name string) (string, error) {
+	var value string
+	if err := s.db.QueryRowContext(ctx, `SELECT value FROM settings WHERE name = ?`, strings.TrimSpace(name)).Scan(&value); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", sql.ErrNoRows
+		}
+		return "", fmt.Errorf("get setting: %w", err)
+	}
+	return value, nil
+}
+
+func (s *Store) PutSetting(ctx context.Context, name, value string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("setting name must not be empty")
+	}
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO settings (name, value, updated_at) VALUES (?, ?, ?)
+                 ON DUPLICATE KEY UPDATE value = VALUES(value), updated_at = VALUES(updated_at)`,
+		name,
+		value,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("put setting: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteSetting(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM settings WHERE name = ?`, strings.TrimSpace(name)); err != nil {
+		return fmt.Errorf("delete setting: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const (
+	// pixelShardSize is the number of pixel ids per partition of the pixels table; full-grid
+	// reads query one shard per statement so each can be served from a single partition.
+	pixelShardSize = 100_000
+	// pixelShardWorkers bounds how many shards GetAllPixels reads concurrently. It stays well
+	// below the connection pool size so a grid refresh cannot starve request handlers.
+	pixelShardWorkers = 2
+)
+
+// pixelShard is the id range [start, end) of one row band of the grid.
+type pixelShard struct {
+	start, end int
+}
+
+func pixelShards(total, size int) []pixelShard {
+	shards := make([]pixelShard, 0, (total+size-1)/size)
+	for start := 0; start < total; start += size {
+		shards = append(shards, pixelShard{start: start, end: min(start+size, total)})
+	}
+	return shards
+}
+
+// pixelPartitionDDL partitions the pixels table by id range along the shard boundaries.
+func pixelPartitionDDL(total, size int) string {
+	shards := pixelShards(total, size)
+	parts := make([]string, 0, len(shards))
+	for i, shard := range shards {
+		bound := fmt.Sprintf("%d", shard.end)
+		if i == len(shards)-1 {
+			bound = "MAXVALUE"
+		}
+		parts = append(parts, fmt.Sprintf("PARTITION p%d VALUES LESS THAN (%s)", i, bound))
+	}
+	return "ALTER TABLE pixels PARTITION BY RANGE (id) (" + strings.Join(parts, ", ") + ")"
+}
+
+// ensurePixelPartitions partitions the pixels table once; tables that already have partitions,
+// including ones an operator laid out by hand, are left alone.
+func ensurePixelPartitions(ctx context.Context, tx *sqltrace.Tx) error {
+	var partitions int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'pixels' AND PARTITION_NAME IS NOT NULL`).Scan(&partitions)
+	if err != nil {
+		return fmt.Errorf("inspect pixel partitions: %w", err)
+	}
+	if partitions > 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, pixelPartitionDDL(storage.TotalPixels, pixelShardSize)); err != nil {
+		return fmt.Errorf("partition pixels: %w", err)
+	}
+	return nil
+}
+
+// GetAllPixels reads the grid shard by shard on a bounded number of connections. The shards are
+// separate statements, so a pixel written during the read may appear in its old or new state.
+func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
+	shards := pixelShards(storage.TotalPixels, pixelShardSize)
+	results := make([][]Pixel, len(shards))
+	errs := make([]error, len(shards))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(pixelShardWorkers, len(shards)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], errs[i] = s.loadPixelShard(ctx, shards[i])
+				if errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := range shards {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var cancelled error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			cancelled = err
+		default:
+			return PixelState{}, err
+		}
+	}
+	if cancelled != nil {
+		return PixelState{}, cancelled
+	}
+
+	count := 0
+	for _, shard := range results {
+		count += len(shard)
+	}
+	pixels := make([]Pixel, 0, count)
+	for _, shard := range results {
+		pixels = append(pixels, shard...)
+	}
+	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+}
+
+func (s *Store) loadPixelShard(ctx context.Context, shard pixelShard) ([]Pixel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE `+onMainBoard+` AND id >= ? AND id < ? ORDER BY id`, shard.start, shard.end)
+	if err != nil {
+		return nil, fmt.Errorf("query pixels %d-%d: %w", shard.start, shard.end, err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0, shard.end-shard.start)
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var updated sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
+		}
+		if owner.Valid {
+			oid := owner.Int64
+			pixel.OwnerID = &oid
+		}
+		if updated.Valid {
+			pixel.UpdatedAt = updated.Time.UTC()
+		}
+		pixels = append(pixels, pixel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixels %d-%d: %w", shard.start, shard.end, err)
+	}
+	return pixels, nil
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -21,14 +22,25 @@ type (
 	VerificationToken  = storage.VerificationToken
 	PasswordResetToken = storage.PasswordResetToken
 	PixelState         = storage.PixelState
+	PixelUpdateOutcome = storage.PixelUpdateOutcome
+	ActivationCode     = storage.ActivationCode
+	Payment            = storage.Payment
+	AgeAttestation     = storage.AgeAttestation
+	PixelLicense       = storage.PixelLicense
+	Takedown           = storage.Takedown
+	TakedownEvent      = storage.TakedownEvent
+	ContactMessage     = storage.ContactMessage
+	ContactReply       = storage.ContactReply
+	CampaignStats      = storage.CampaignStats
 )
 
 //go:embed migrations/*.sql
 var migrationFiles embed.FS
 
 type Store struct {
-	db            *sql.DB
+	db            *sqltrace.DB
 	skipPixelSeed bool
+	pixelQuota    int
 }
 
 var _ storage.Store = (*Store)(nil)
@@ -48,7 +60,7 @@ func Open(dsn string) (*Store, error) {
 	db.SetMaxIdleConns(5)
 	db.SetMaxOpenConns(10)
 
-	return &Store{db: db}, nil
+	return &Store{db: sqltrace.Wrap(db)}, nil
 }
 
 func (s *Store) Close() error {
@@ -58,6 +70,20 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+func (s *Store) Ping(ctx context.Context) error {
+	if s == nil || s.db == nil {
+		return errors.New("store is closed")
+	}
+	return s.db.PingContext(ctx)
+}
+
+// SetSlowQueryHook reports statements slower than threshold to hook with their literals redacted.
+func (s *Store) SetSlowQueryHook(threshold time.Duration, hook sqltrace.Hook) {
+	if s != nil && s.db != nil {
+		s.db.SetSlowQueryHook(threshold, hook)
+	}
+}
+
 func (s *Store) SetSkipPixelSeed(skip bool) {
 	if s != nil {
 		s.skipPixelSeed = skip
@@ -83,13 +109,15 @@ func (s *Store) InsertPixel(ctx context.Context, pixel Pixel) error {
 
 	_, err := s.db.ExecContext(
 		ctx,
-		`INSERT INTO pixels (id, status, color, url, owner_id, updated_at)
-                 VALUES (?, ?, ?, ?, ?, ?)
+		`INSERT INTO pixels (id, status, color, url, title, description, owner_id, updated_at)
+                 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
                  ON DUPLICATE KEY UPDATE id = id`,
 		pixel.ID,
 		status,
 		nullableString(color),
 		nullableString(url),
+		nullableString(strings.TrimSpace(pixel.Title)),
+		nullableString(strings.TrimSpace(pixel.Description)),
 		owner,
 		time.Now().UTC(),
 	)
@@ -122,9 +150,15 @@ func (s *Store) EnsureSchema(ctx context.Context) (err error) {
 			return err
 		}
 	}
+	if err = ensurePixelBoardKey(ctx, tx); err != nil {
+		return err
+	}
+	if err = ensurePixelPartitions(ctx, tx); err != nil {
+		return err
+	}
 
 	var count int
-	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pixels`).Scan(&count); err != nil {
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pixels WHERE `+onMainBoard).Scan(&count); err != nil {
 		err = fmt.Errorf("count pixels: %w", err)
 		return err
 	}
@@ -175,6 +209,27 @@ func (s *Store) EnsureSchema(ctx context.Context) (err error) {
 	return nil
 }
 
+func (s *Store) MissingIndexes(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT INDEX_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE()`)
+	if err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var present []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		present = append(present, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate indexes: %w", err)
+	}
+	return storage.MissingIndexes(present), nil
+}
+
 func readMigrationStatements() ([]string, error) {
 	entries, err := fs.ReadDir(migrationFiles, "migrations")
 	if err != nil {
@@ -217,7 +272,7 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 		return nil, errors.New("invalid owner id")
 	}
 
-	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), owner_id, updated_at FROM pixels WHERE owner_id = ? ORDER BY updated_at DESC`, ownerID)
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at, expires_at FROM pixels WHERE `+onMainBoard+` AND owner_id = ? ORDER BY updated_at DESC`, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("query pixels by owner: %w", err)
 	}
@@ -227,8 +282,8 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 	for rows.Next() {
 		var pixel Pixel
 		var owner sql.NullInt64
-		var updated sql.NullTime
-		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &updated); err != nil {
+		var updated, expires sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated, &expires); err != nil {
 			return nil, fmt.Errorf("scan pixel: %w", err)
 		}
 		if owner.Valid {
@@ -238,6 +293,7 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 		if updated.Valid {
 			pixel.UpdatedAt = updated.Time.UTC()
 		}
+		pixel.ExpiresAt = expiresAt(expires)
 		pixels = append(pixels, pixel)
 	}
 
@@ -248,20 +304,56 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 	return pixels, nil
 }
 
-func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), owner_id, updated_at FROM pixels ORDER BY id`)
+// GetPixelsModifiedSince returns the pixels updated at or after since, ordered by id.
+func (s *Store) GetPixelsModifiedSince(ctx context.Context, since time.Time) ([]Pixel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE `+onMainBoard+` AND updated_at >= ? ORDER BY id`, since.UTC())
 	if err != nil {
-		return PixelState{}, fmt.Errorf("query pixels: %w", err)
+		return nil, fmt.Errorf("query modified pixels: %w", err)
 	}
 	defer rows.Close()
 
-	pixels := make([]Pixel, 0, storage.TotalPixels)
+	pixels := make([]Pixel, 0)
 	for rows.Next() {
 		var pixel Pixel
 		var owner sql.NullInt64
 		var updated sql.NullTime
-		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &updated); err != nil {
-			return PixelState{}, fmt.Errorf("scan pixel: %w", err)
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
+		}
+		if owner.Valid {
+			oid := owner.Int64
+			pixel.OwnerID = &oid
+		}
+		if updated.Valid {
+			pixel.UpdatedAt = updated.Time.UTC()
+		}
+		pixels = append(pixels, pixel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate modified pixels: %w", err)
+	}
+
+	return pixels, nil
+}
+
+// GetPixelsInRect reads the rectangle's row band by id range, which keeps the query within the
+// partitions covering those rows, and filters the columns by id modulo the grid width.
+func (s *Store) GetPixelsInRect(ctx context.Context, x, y, width, height int) ([]Pixel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE `+onMainBoard+` AND id >= ? AND id < ? AND MOD(id, ?) >= ? AND MOD(id, ?) < ? ORDER BY id`,
+		y*storage.GridWidth, (y+height)*storage.GridWidth, storage.GridWidth, x, storage.GridWidth, x+width)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel rect: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0, width*height)
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var updated sql.NullTime
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
 		}
 		if owner.Valid {
 			oid := owner.Int64
@@ -274,10 +366,10 @@ func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
 	}
 
 	if err := rows.Err(); err != nil {
-		return PixelState{}, fmt.Errorf("iterate pixels: %w", err)
+		return nil, fmt.Errorf("iterate pixel rect: %w", err)
 	}
 
-	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+	return pixels, nil
 }
 
 func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (Pixel, error) {
@@ -289,16 +381,38 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (Pixel, error) {
 }
 
 func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pixel Pixel, cost int64) (Pixel, User, error) {
-	if pixel.ID < 0 || pixel.ID >= storage.TotalPixels {
-		return Pixel{}, User{}, fmt.Errorf("invalid pixel id: %d", pixel.ID)
+	outcomes, updatedUser, err := s.UpdatePixelsForUserWithCost(ctx, userID, []Pixel{pixel}, cost, "")
+	if err != nil {
+		return Pixel{}, User{}, err
+	}
+	if outcomes[0].Err != nil {
+		return Pixel{}, User{}, outcomes[0].Err
 	}
+	return outcomes[0].Pixel, updatedUser, nil
+}
+
+func (s *Store) UpdatePixelsForUserWithCost(ctx context.Context, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	return s.updatePixelsWithCost(ctx, storage.MainBoard, userID, userID, pixels, cost, priceVersion)
+}
+
+func (s *Store) GiftPixels(ctx context.Context, buyerID, recipientID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	if recipientID <= 0 || recipientID == buyerID {
+		return nil, User{}, errors.New("invalid gift recipient")
+	}
+	return s.updatePixelsWithCost(ctx, storage.MainBoard, buyerID, recipientID, pixels, cost, priceVersion)
+}
+
+// updatePixelsWithCost applies pixels of board in one transaction, charging userID and making
+// ownerID the owner of the pixels taken. A different ownerID is a gift, which only free pixels can
+// be. Pixels of other boards than the main grid get their row when first written and cannot be held.
+func (s *Store) updatePixelsWithCost(ctx context.Context, board string, userID, ownerID int64, pixels []Pixel, cost int64, priceVersion string) (outcomes []PixelUpdateOutcome, updatedUser User, err error) {
 	if cost < 0 {
-		return Pixel{}, User{}, errors.New("cost must not be negative")
+		return nil, User{}, errors.New("cost must not be negative")
 	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return Pixel{}, User{}, fmt.Errorf("begin update pixel for user: %w", err)
+		return nil, User{}, fmt.Errorf("begin update pixels for user: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -310,109 +424,154 @@ func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pi
 	if userID > 0 {
 		if err = tx.QueryRowContext(ctx, `SELECT user_points FROM users WHERE id = ?`, userID).Scan(&currentPoints); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				return Pixel{}, User{}, sql.ErrNoRows
+				return nil, User{}, sql.ErrNoRows
 			}
-			return Pixel{}, User{}, fmt.Errorf("load user points: %w", err)
+			return nil, User{}, fmt.Errorf("load user points: %w", err)
 		}
 	}
 
-	var currentOwner sql.NullInt64
-	if err = tx.QueryRowContext(ctx, `SELECT owner_id FROM pixels WHERE id = ?`, pixel.ID).Scan(&currentOwner); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return Pixel{}, User{}, sql.ErrNoRows
+	var quota *pixelQuota
+	if userID > 0 {
+		if quota, err = loadPixelQuota(ctx, tx, ownerID, s.pixelQuota); err != nil {
+			return nil, User{}, err
 		}
-		return Pixel{}, User{}, fmt.Errorf("load pixel owner: %w", err)
 	}
 
-	updated := Pixel{ID: pixel.ID}
+	ids := make([]int, 0, len(pixels))
+	for _, pixel := range pixels {
+		if pixel.ID >= 0 && pixel.ID < storage.TotalPixels {
+			ids = append(ids, pixel.ID)
+		}
+	}
+	if board != storage.MainBoard {
+		if err = insertBoardPixels(ctx, tx, board, ids); err != nil {
+			return nil, User{}, err
+		}
+	}
+	states, err := loadPixelHistoryStates(ctx, tx, board, ids)
+	if err != nil {
+		return nil, User{}, err
+	}
+	held := map[int]bool{}
+	if userID > 0 && board == storage.MainBoard {
+		if held, err = pixelsHeldByOthers(ctx, tx, ids, userID, time.Now()); err != nil {
+			return nil, User{}, err
+		}
+	}
+
+	// Pixels are decided one by one against the state the earlier ones left, then written together.
+	batch := newPixelBatch(board)
+	outcomes = make([]PixelUpdateOutcome, len(pixels))
+	for i, pixel := range pixels {
+		before, exists := states[pixel.ID]
+		updated, charged, rejected := planPixelUpdate(userID, ownerID, pixel, before, exists, held[pixel.ID], cost, &currentPoints, quota)
+		outcomes[i] = PixelUpdateOutcome{Pixel: updated, Err: rejected}
+		if rejected == nil {
+			batch.add(before, updated, charged, userID > 0)
+			states[pixel.ID] = updated
+		}
+	}
+	if err = batch.write(ctx, tx, userID, priceVersion); err != nil {
+		return nil, User{}, err
+	}
+
+	if userID > 0 {
+		row := tx.QueryRowContext(ctx, `SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = ?`, userID)
+		updatedUser, err = scanUser(row)
+		if err != nil {
+			return nil, User{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, User{}, fmt.Errorf("commit update pixels for user: %w", err)
+	}
+
+	return outcomes, updatedUser, nil
+}
+
+// planPixelUpdate decides how pixel is written for userID, given the state before it and whether
+// another user holds it, and deducts cost from points when the user acquires it. The pixel goes to
+// ownerID, which differs from userID only for gifts of free pixels, and counts against its quota.
+// Nothing is written; a rejected pixel leaves points and quota untouched.
+func planPixelUpdate(userID, ownerID int64, pixel, before Pixel, exists, held bool, cost int64, points *int64, quota *pixelQuota) (updated Pixel, charged int64, rejected error) {
+	if pixel.ID < 0 || pixel.ID >= storage.TotalPixels {
+		return Pixel{}, 0, fmt.Errorf("invalid pixel id: %d", pixel.ID)
+	}
+	gift := ownerID != userID
+	if gift && !strings.EqualFold(pixel.Status, "taken") {
+		return Pixel{}, 0, errors.New("gifted pixels must be taken")
+	}
+	if !exists {
+		return Pixel{}, 0, sql.ErrNoRows
+	}
+	var currentOwner sql.NullInt64
+	if before.OwnerID != nil {
+		currentOwner = sql.NullInt64{Int64: *before.OwnerID, Valid: true}
+	}
+	if gift && currentOwner.Valid {
+		return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
+	}
+
+	updated = Pixel{ID: pixel.ID}
 	chargeCost := false
 
 	if strings.EqualFold(pixel.Status, "taken") {
 		if pixel.Color == "" || pixel.URL == "" {
-			return Pixel{}, User{}, errors.New("taken pixels require color and url")
+			return Pixel{}, 0, errors.New("taken pixels require color and url")
 		}
 		if currentOwner.Valid && userID > 0 && currentOwner.Int64 != userID {
-			return Pixel{}, User{}, storage.ErrPixelOwnedByAnotherUser
+			return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
+		}
+		if !currentOwner.Valid && userID > 0 && held {
+			return Pixel{}, 0, storage.ErrPixelHeld
 		}
 		if (!currentOwner.Valid && cost > 0) || (currentOwner.Valid && userID > 0 && currentOwner.Int64 != userID) {
 			chargeCost = cost > 0
 		}
+		if !currentOwner.Valid || currentOwner.Int64 != userID {
+			updated.ExpiresAt = pixel.ExpiresAt
+		} else {
+			updated.ExpiresAt = before.ExpiresAt
+		}
 		updated.Status = "taken"
 		updated.Color = pixel.Color
 		updated.URL = pixel.URL
+		updated.Title = pixel.Title
+		updated.Description = pixel.Description
 		if userID > 0 {
-			owner := userID
+			owner := ownerID
 			updated.OwnerID = &owner
 		}
 	} else {
 		if currentOwner.Valid && userID > 0 && currentOwner.Int64 != userID {
-			return Pixel{}, User{}, storage.ErrPixelOwnedByAnotherUser
+			return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
 		}
 		updated.Status = "free"
 		updated.Color = ""
 		updated.URL = ""
+		updated.Title = ""
+		updated.Description = ""
 		updated.OwnerID = nil
 	}
 
-	if chargeCost {
-		if currentPoints < cost {
-			return Pixel{}, User{}, storage.ErrInsufficientPoints
-		}
-		res, execErr := tx.ExecContext(ctx, `UPDATE users SET user_points = user_points - ? WHERE id = ? AND user_points >= ?`, cost, userID, cost)
-		if execErr != nil {
-			return Pixel{}, User{}, fmt.Errorf("deduct user points: %w", execErr)
-		}
-		affected, affErr := res.RowsAffected()
-		if affErr != nil {
-			return Pixel{}, User{}, fmt.Errorf("deduct user points rows affected: %w", affErr)
-		}
-		if affected == 0 {
-			return Pixel{}, User{}, storage.ErrInsufficientPoints
-		}
-		currentPoints -= cost
-	}
-
-	updated.UpdatedAt = time.Now().UTC()
-	var owner any
-	if updated.OwnerID != nil {
-		owner = *updated.OwnerID
-	}
-
-	res, execErr := tx.ExecContext(
-		ctx,
-		`UPDATE pixels SET status = ?, color = ?, url = ?, owner_id = ?, updated_at = ? WHERE id = ?`,
-		updated.Status,
-		updated.Color,
-		updated.URL,
-		owner,
-		updated.UpdatedAt,
-		updated.ID,
-	)
-	if execErr != nil {
-		return Pixel{}, User{}, fmt.Errorf("update pixel: %w", execErr)
-	}
-	affected, affErr := res.RowsAffected()
-	if affErr != nil {
-		return Pixel{}, User{}, fmt.Errorf("update pixel rows affected: %w", affErr)
+	if chargeCost && *points < cost {
+		return Pixel{}, 0, storage.ErrInsufficientPoints
 	}
-	if affected == 0 {
-		return Pixel{}, User{}, sql.ErrNoRows
+	acquired := updated.OwnerID != nil && (!currentOwner.Valid || currentOwner.Int64 != *updated.OwnerID)
+	if acquired && !quota.take() {
+		return Pixel{}, 0, storage.ErrPixelQuotaExceeded
 	}
-
-	var updatedUser User
-	if userID > 0 {
-		row := tx.QueryRowContext(ctx, `SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = ?`, userID)
-		updatedUser, err = scanUser(row)
-		if err != nil {
-			return Pixel{}, User{}, err
-		}
+	if updated.OwnerID == nil && currentOwner.Valid && currentOwner.Int64 == ownerID {
+		quota.release()
 	}
-
-	if commitErr := tx.Commit(); commitErr != nil {
-		return Pixel{}, User{}, fmt.Errorf("commit update pixel for user: %w", commitErr)
+	if chargeCost {
+		charged = cost
+		*points -= cost
 	}
 
-	return updated, updatedUser, nil
+	updated.UpdatedAt = time.Now().UTC()
+	return updated, charged, nil
 }
 
 func (s *Store) UpdatePixelForUser(ctx context.Context, userID int64, pixel Pixel) (Pixel, error) {
@@ -495,7 +654,7 @@ func (s *Store) CreateActivationCode(ctx context.Context, code string, value int
 		return errors.New("activation code value must be positive")
 	}
 
-	_, err := s.db.ExecContext(ctx, `INSERT INTO activation_codes (code, value) VALUES (?, ?)`, strings.ToUpper(code), value)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO activation_codes (code, value, created_at) VALUES (?, ?, ?)`, strings.ToUpper(code), value, time.Now().UTC())
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
 			return fmt.Errorf("activation code already exists: %w", err)
@@ -505,6 +664,143 @@ func (s *Store) CreateActivationCode(ctx context.Context, code string, value int
 	return nil
 }
 
+// CreateActivationCodeBatch inserts all codes of a batch in a single transaction.
+func (s *Store) CreateActivationCodeBatch(ctx context.Context, batch storage.ActivationCodeBatch) (err error) {
+	if batch.Value <= 0 {
+		return errors.New("activation code value must be positive")
+	}
+	campaign := strings.TrimSpace(batch.CampaignID)
+	if campaign == "" {
+		return errors.New("campaign id must not be empty")
+	}
+	if len(batch.Codes) == 0 {
+		return errors.New("batch must contain codes")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin create activation code batch: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	created := time.Now().UTC()
+	batchID := strings.TrimSpace(batch.BatchID)
+	for _, code := range batch.Codes {
+		normalized := strings.ToUpper(strings.TrimSpace(code))
+		if normalized == "" {
+			err = errors.New("activation code must not be empty")
+			return err
+		}
+		if _, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO activation_codes (code, value, campaign_id, batch_id, created_at) VALUES (?, ?, ?, ?, ?)`,
+			normalized,
+			batch.Value,
+			campaign,
+			nullableString(batchID),
+			created,
+		); err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+				return fmt.Errorf("activation code already exists: %w", err)
+			}
+			return fmt.Errorf("insert activation code: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit activation code batch: %w", err)
+	}
+	return nil
+}
+
+// GetCampaignStats aggregates redemption and follow-up purchase figures for a campaign.
+// Purchases are attributed to the campaign when a redeeming user owns pixels updated after redemption.
+func (s *Store) GetCampaignStats(ctx context.Context, campaignID string) (CampaignStats, error) {
+	campaign := strings.TrimSpace(campaignID)
+	if campaign == "" {
+		return CampaignStats{}, errors.New("campaign id must not be empty")
+	}
+
+	stats := CampaignStats{CampaignID: campaign, Batches: make([]storage.CampaignBatchStats, 0)}
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(1),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN 1 ELSE 0 END), 0),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN value ELSE 0 END), 0),
+                        COUNT(DISTINCT redeemed_by)
+                 FROM activation_codes WHERE campaign_id = ?`,
+		campaign,
+	).Scan(&stats.CodesIssued, &stats.CodesRedeemed, &stats.PointsIssued, &stats.RedeemingUsers); err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign summary: %w", err)
+	}
+	if stats.CodesIssued == 0 {
+		return CampaignStats{}, sql.ErrNoRows
+	}
+	stats.RedemptionRate = float64(stats.CodesRedeemed) / float64(stats.CodesIssued)
+
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(DISTINCT p.id), COUNT(DISTINCT p.owner_id)
+                 FROM activation_codes a
+                 JOIN pixels p ON p.owner_id = a.redeemed_by AND p.updated_at >= a.redeemed_at
+                 WHERE a.campaign_id = ? AND a.redeemed_at IS NOT NULL`,
+		campaign,
+	).Scan(&stats.PixelsPurchased, &stats.PurchasingUsers); err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign purchases: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT COALESCE(batch_id, ''), COUNT(1),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN 1 ELSE 0 END), 0),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN value ELSE 0 END), 0)
+                 FROM activation_codes WHERE campaign_id = ?
+                 GROUP BY COALESCE(batch_id, '') ORDER BY 1`,
+		campaign,
+	)
+	if err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign batches: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var batch storage.CampaignBatchStats
+		if err := rows.Scan(&batch.BatchID, &batch.CodesIssued, &batch.CodesRedeemed, &batch.PointsIssued); err != nil {
+			return CampaignStats{}, fmt.Errorf("scan campaign batch: %w", err)
+		}
+		stats.Batches = append(stats.Batches, batch)
+	}
+	if err := rows.Err(); err != nil {
+		return CampaignStats{}, fmt.Errorf("iterate campaign batches: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *Store) GetActivationCode(ctx context.Context, code string) (ActivationCode, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+	if normalized == "" {
+		return ActivationCode{}, errors.New("activation code must not be empty")
+	}
+
+	var record ActivationCode
+	row := s.db.QueryRowContext(
+		ctx,
+		`SELECT code, value, COALESCE(campaign_id, ''), COALESCE(batch_id, '') FROM activation_codes WHERE code = ? AND redeemed_at IS NULL`,
+		normalized,
+	)
+	if err := row.Scan(&record.Code, &record.Value, &record.CampaignID, &record.BatchID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ActivationCode{}, sql.ErrNoRows
+		}
+		return ActivationCode{}, fmt.Errorf("get activation code: %w", err)
+	}
+	return record, nil
+}
+
 func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code string) (User, int64, error) {
 	if userID <= 0 {
 		return User{}, 0, errors.New("invalid user id")
@@ -525,20 +821,35 @@ func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code str
 	}()
 
 	var value int64
-	if err = tx.QueryRowContext(ctx, `SELECT value FROM activation_codes WHERE code = ?`, normalized).Scan(&value); err != nil {
+	if err = tx.QueryRowContext(ctx, `SELECT value FROM activation_codes WHERE code = ? AND redeemed_at IS NULL FOR UPDATE`, normalized).Scan(&value); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return User{}, 0, sql.ErrNoRows
 		}
 		return User{}, 0, fmt.Errorf("load activation code: %w", err)
 	}
 
-	if _, err = tx.ExecContext(ctx, `DELETE FROM activation_codes WHERE code = ?`, normalized); err != nil {
-		return User{}, 0, fmt.Errorf("delete activation code: %w", err)
+	// Redeemed codes are kept (marked with the redeeming user) so campaign statistics can be computed.
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE activation_codes SET redeemed_by = ?, redeemed_at = ? WHERE code = ? AND redeemed_at IS NULL`,
+		userID,
+		time.Now().UTC(),
+		normalized,
+	)
+	if err != nil {
+		return User{}, 0, fmt.Errorf("mark activation code redeemed: %w", err)
+	}
+	if affected, affErr := res.RowsAffected(); affErr == nil && affected == 0 {
+		err = sql.ErrNoRows
+		return User{}, 0, err
 	}
 
 	if _, err = tx.ExecContext(ctx, `UPDATE users SET user_points = user_points + ? WHERE id = ?`, value, userID); err != nil {
 		return User{}, 0, fmt.Errorf("add user points: %w", err)
 	}
+	if err = recordLedgerEntry(ctx, tx, userID, value, storage.LedgerActivationCode, normalized, ""); err != nil {
+		return User{}, 0, err
+	}
 
 	row := tx.QueryRowContext(ctx, `SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = ?`, userID)
 	user, scanErr := scanUser(row)
@@ -563,7 +874,7 @@ func (s *Store) CreateVerificationToken(ctx context.Context, token string, userI
 	}
 
 	now := time.Now().UTC()
-	_, err := s.db.ExecContext(ctx, `INSERT INTO verification_tokens (token, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)`, token, userID, expiresAt.UTC(), now)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO verification_tokens (token, user_id, expires_at, created_at, hashed) VALUES (?, ?, ?, ?, 1)`, storage.HashToken(token), userID, expiresAt.UTC(), now)
 	if err != nil {
 		return VerificationToken{}, fmt.Errorf("insert verification token: %w", err)
 	}
@@ -577,9 +888,9 @@ func (s *Store) GetVerificationToken(ctx context.Context, token string) (Verific
 		return VerificationToken{}, errors.New("token must not be empty")
 	}
 
-	var record VerificationToken
-	if err := s.db.QueryRowContext(ctx, `SELECT token, user_id, expires_at, created_at FROM verification_tokens WHERE token = ?`, token).
-		Scan(&record.Token, &record.UserID, &record.ExpiresAt, &record.CreatedAt); err != nil {
+	record := VerificationToken{Token: token}
+	if err := s.db.QueryRowContext(ctx, `SELECT user_id, expires_at, created_at FROM verification_tokens WHERE token = ?`, storage.HashToken(token)).
+		Scan(&record.UserID, &record.ExpiresAt, &record.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return VerificationToken{}, sql.ErrNoRows
 		}
@@ -595,7 +906,7 @@ func (s *Store) DeleteVerificationToken(ctx context.Context, token string) error
 	if token == "" {
 		return errors.New("token must not be empty")
 	}
-	if _, err := s.db.ExecContext(ctx, `DELETE FROM verification_tokens WHERE token = ?`, token); err != nil {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM verification_tokens WHERE token = ?`, storage.HashToken(token)); err != nil {
 		return fmt.Errorf("delete verification token: %w", err)
 	}
 	return nil
@@ -640,7 +951,7 @@ func (s *Store) CreatePasswordResetToken(ctx context.Context, token string, user
 	}
 
 	now := time.Now().UTC()
-	_, err := s.db.ExecContext(ctx, `INSERT INTO password_reset_tokens (token, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)`, token, userID, expiresAt.UTC(), now)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO password_reset_tokens (token, user_id, expires_at, created_at, hashed) VALUES (?, ?, ?, ?, 1)`, storage.HashToken(token), userID, expiresAt.UTC(), now)
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "duplicate") {
 			return PasswordResetToken{}, fmt.Errorf("token already exists: %w", err)
@@ -657,9 +968,9 @@ func (s *Store) GetPasswordResetToken(ctx context.Context, token string) (Passwo
 		return PasswordResetToken{}, errors.New("token must not be empty")
 	}
 
-	row := s.db.QueryRowContext(ctx, `SELECT token, user_id, expires_at, created_at FROM password_reset_tokens WHERE token = ?`, token)
-	var record PasswordResetToken
-	if err := row.Scan(&record.Token, &record.UserID, &record.ExpiresAt, &record.CreatedAt); err != nil {
+	row := s.db.QueryRowContext(ctx, `SELECT user_id, expires_at, created_at FROM password_reset_tokens WHERE token = ?`, storage.HashToken(token))
+	record := PasswordResetToken{Token: token}
+	if err := row.Scan(&record.UserID, &record.ExpiresAt, &record.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return PasswordResetToken{}, sql.ErrNoRows
 		}
@@ -675,12 +986,33 @@ func (s *Store) DeletePasswordResetToken(ctx context.Context, token string) erro
 	if token == "" {
 		return errors.New("token must not be empty")
 	}
-	if _, err := s.db.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE token = ?`, token); err != nil {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE token = ?`, storage.HashToken(token)); err != nil {
 		return fmt.Errorf("delete password reset token: %w", err)
 	}
 	return nil
 }
 
+func (s *Store) ConsumePasswordResetToken(ctx context.Context, token string) (PasswordResetToken, error) {
+	record, err := s.GetPasswordResetToken(ctx, token)
+	if err != nil {
+		return PasswordResetToken{}, err
+	}
+
+	// Only the caller whose delete removes the row may use the token.
+	res, err := s.db.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE token = ?`, storage.HashToken(record.Token))
+	if err != nil {
+		return PasswordResetToken{}, fmt.Errorf("consume password reset token: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return PasswordResetToken{}, fmt.Errorf("consume password reset token rows affected: %w", err)
+	}
+	if affected == 0 {
+		return PasswordResetToken{}, storage.ErrTokenUsed
+	}
+	return record, nil
+}
+
 func (s *Store) DeletePasswordResetTokensForUser(ctx context.Context, userID int64) error {
 	if userID <= 0 {
 		return errors.New("invalid user id")
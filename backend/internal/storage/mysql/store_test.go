@@ -11,10 +11,16 @@ import (
 	"testing"
 
 	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
 )
 
 type stubDBState struct {
-	records map[int]struct{}
+	records    map[int]struct{}
+	partitions int
+	altered    []string
+	indexes    []string
+	boardKeyed bool
+	rekeyed    []string
 }
 
 func newStubDBState(existing []int) *stubDBState {
@@ -94,10 +100,17 @@ func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.Na
 		}
 		return driver.RowsAffected(len(args) / 2), nil
 	}
+	if normalized := strings.TrimSpace(strings.ToUpper(query)); strings.HasPrefix(normalized, "ALTER TABLE PIXELS") && strings.Contains(normalized, "PARTITION BY") {
+		c.state.altered = append(c.state.altered, query)
+	}
+	if normalized := strings.TrimSpace(strings.ToUpper(query)); strings.HasPrefix(normalized, "ALTER TABLE PIXELS") && strings.Contains(normalized, "PRIMARY KEY") {
+		c.state.rekeyed = append(c.state.rekeyed, query)
+		c.state.boardKeyed = true
+	}
 	return driver.RowsAffected(0), nil
 }
 
-func (c *stubConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	normalized := strings.TrimSpace(strings.ToUpper(query))
 	if strings.HasPrefix(normalized, "SELECT COUNT(1) FROM PIXELS") {
 		count := c.state.count()
@@ -106,6 +119,37 @@ func (c *stubConn) QueryContext(_ context.Context, query string, _ []driver.Name
 			values:  [][]driver.Value{{int64(count)}},
 		}, nil
 	}
+	if strings.HasPrefix(normalized, "SELECT COUNT(1) FROM INFORMATION_SCHEMA.PARTITIONS") {
+		return &stubRows{
+			columns: []string{"count"},
+			values:  [][]driver.Value{{int64(c.state.partitions)}},
+		}, nil
+	}
+	if strings.HasPrefix(normalized, "SELECT COUNT(1) FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE") {
+		keyed := int64(0)
+		if c.state.boardKeyed {
+			keyed = 1
+		}
+		return &stubRows{columns: []string{"count"}, values: [][]driver.Value{{keyed}}}, nil
+	}
+	if strings.HasPrefix(normalized, "SELECT DISTINCT INDEX_NAME FROM INFORMATION_SCHEMA.STATISTICS") {
+		rows := &stubRows{columns: []string{"index_name"}}
+		for _, name := range c.state.indexes {
+			rows.values = append(rows.values, []driver.Value{name})
+		}
+		return rows, nil
+	}
+	if strings.HasPrefix(normalized, "SELECT ID, STATUS") && len(args) == 2 {
+		start, _ := asInt(args[0].Value)
+		end, _ := asInt(args[1].Value)
+		rows := &stubRows{columns: []string{"id", "status", "color", "url", "title", "description", "owner_id", "updated_at"}}
+		for id := start; id < end; id++ {
+			if c.state.has(id) {
+				rows.values = append(rows.values, []driver.Value{int64(id), "free", "", "", "", "", nil, nil})
+			}
+		}
+		return rows, nil
+	}
 	return nil, fmt.Errorf("unexpected query: %s", query)
 }
 
@@ -237,7 +281,7 @@ func TestEnsureSchemaSeedsMissingPixels(t *testing.T) {
 			db := sql.OpenDB(connector)
 			t.Cleanup(func() { db.Close() })
 
-			store := &Store{db: db}
+			store := &Store{db: sqltrace.Wrap(db)}
 			store.SetSkipPixelSeed(tt.skip)
 
 			if err := store.EnsureSchema(context.Background()); err != nil {
@@ -262,3 +306,84 @@ func TestEnsureSchemaSeedsMissingPixels(t *testing.T) {
 		})
 	}
 }
+
+func TestEnsureSchemaPartitionsPixelsOnce(t *testing.T) {
+	state := newStubDBState(nil)
+	db := sql.OpenDB(&stubConnector{state: state})
+	t.Cleanup(func() { db.Close() })
+	store := &Store{db: sqltrace.Wrap(db)}
+	store.SetSkipPixelSeed(true)
+
+	if err := store.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("EnsureSchema() error = %v", err)
+	}
+	if len(state.altered) != 1 || !strings.Contains(state.altered[0], "PARTITION p0 VALUES LESS THAN (100000)") || !strings.HasSuffix(state.altered[0], "PARTITION p9 VALUES LESS THAN (MAXVALUE))") {
+		t.Fatalf("unexpected partition statements %q", state.altered)
+	}
+
+	state.partitions = 10
+	if err := store.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("EnsureSchema() error = %v", err)
+	}
+	if len(state.altered) != 1 {
+		t.Fatalf("an already partitioned table must not be altered again, got %d statements", len(state.altered))
+	}
+}
+
+func TestEnsureSchemaKeysPixelsByBoardOnce(t *testing.T) {
+	state := newStubDBState(nil)
+	db := sql.OpenDB(&stubConnector{state: state})
+	t.Cleanup(func() { db.Close() })
+	store := &Store{db: sqltrace.Wrap(db)}
+	store.SetSkipPixelSeed(true)
+
+	for i := 0; i < 2; i++ {
+		if err := store.EnsureSchema(context.Background()); err != nil {
+			t.Fatalf("EnsureSchema() error = %v", err)
+		}
+	}
+	if len(state.rekeyed) != 1 || !strings.Contains(state.rekeyed[0], "ADD PRIMARY KEY (board_id, id)") {
+		t.Fatalf("expected the primary key changed once, got %q", state.rekeyed)
+	}
+}
+
+func TestGetAllPixelsMergesShardsInOrder(t *testing.T) {
+	ids := []int{0, 1, pixelShardSize - 1, pixelShardSize, 5*pixelShardSize + 7, storage.TotalPixels - 1}
+	db := sql.OpenDB(&stubConnector{state: newStubDBState(ids)})
+	t.Cleanup(func() { db.Close() })
+	store := &Store{db: sqltrace.Wrap(db)}
+
+	state, err := store.GetAllPixels(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllPixels() error = %v", err)
+	}
+	if len(state.Pixels) != len(ids) {
+		t.Fatalf("unexpected pixel count %d", len(state.Pixels))
+	}
+	for i, pixel := range state.Pixels {
+		if pixel.ID != ids[i] || pixel.Status != "free" {
+			t.Fatalf("pixel %d: unexpected %+v", i, pixel)
+		}
+	}
+}
+
+func TestMissingIndexesReportsAbsentIndexes(t *testing.T) {
+	state := newStubDBState(nil)
+	state.indexes = []string{"PRIMARY"}
+	for _, name := range storage.ExpectedIndexes {
+		if name != "idx_pixels_updated_at" && name != "idx_users_email_lower" {
+			state.indexes = append(state.indexes, strings.ToUpper(name))
+		}
+	}
+	db := sql.OpenDB(&stubConnector{state: state})
+	t.Cleanup(func() { db.Close() })
+	store := &Store{db: sqltrace.Wrap(db)}
+
+	missing, err := store.MissingIndexes(context.Background())
+	if err != nil {
+		t.Fatalf("MissingIndexes() error = %v", err)
+	}
+	if len(missing) != 2 || missing[0] != "idx_pixels_updated_at" || missing[1] != "idx_users_email_lower" {
+		t.Fatalf("unexpected missing indexes %v", missing)
+	}
+}
@@ -0,0 +1,268 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const takedownColumns = "id, claimant_name, claimant_email, work_url, description, status, created_at, resolved_at"
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (s *Store) CreateTakedown(ctx context.Context, takedown Takedown) (created Takedown, err error) {
+	if len(takedown.PixelIDs) == 0 {
+		return Takedown{}, errors.New("takedown must cover at least one pixel")
+	}
+	if takedown.CreatedAt.IsZero() {
+		takedown.CreatedAt = time.Now()
+	}
+	takedown.CreatedAt = takedown.CreatedAt.UTC()
+	takedown.Status = storage.TakedownStatusPending
+	takedown.ResolvedAt = nil
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Takedown{}, fmt.Errorf("begin create takedown: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, execErr := tx.ExecContext(
+		ctx,
+		`INSERT INTO takedowns (claimant_name, claimant_email, work_url, description, status, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		takedown.ClaimantName,
+		takedown.ClaimantEmail,
+		takedown.WorkURL,
+		takedown.Description,
+		takedown.Status,
+		takedown.CreatedAt,
+	)
+	if execErr != nil {
+		err = fmt.Errorf("insert takedown: %w", execErr)
+		return Takedown{}, err
+	}
+	if takedown.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("takedown id: %w", err)
+		return Takedown{}, err
+	}
+	for _, pixelID := range takedown.PixelIDs {
+		if _, execErr := tx.ExecContext(ctx, `INSERT IGNORE INTO takedown_pixels (takedown_id, pixel_id) VALUES (?, ?)`, takedown.ID, pixelID); execErr != nil {
+			err = fmt.Errorf("insert takedown pixel: %w", execErr)
+			return Takedown{}, err
+		}
+	}
+	if err = insertTakedownEvent(ctx, tx, takedown.ID, "submitted", nil, "", takedown.CreatedAt); err != nil {
+		return Takedown{}, err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit takedown: %w", commitErr)
+		return Takedown{}, err
+	}
+	return takedown, nil
+}
+
+func (s *Store) GetTakedown(ctx context.Context, id int64) (Takedown, error) {
+	takedowns, err := loadTakedowns(ctx, s.db, `SELECT `+takedownColumns+` FROM takedowns WHERE id = ?`, id)
+	if err != nil {
+		return Takedown{}, err
+	}
+	if len(takedowns) == 0 {
+		return Takedown{}, sql.ErrNoRows
+	}
+	return takedowns[0], nil
+}
+
+func (s *Store) ListTakedowns(ctx context.Context, status string) ([]Takedown, error) {
+	if status == "" {
+		return loadTakedowns(ctx, s.db, `SELECT `+takedownColumns+` FROM takedowns ORDER BY created_at, id`)
+	}
+	return loadTakedowns(ctx, s.db, `SELECT `+takedownColumns+` FROM takedowns WHERE status = ? ORDER BY created_at, id`, status)
+}
+
+func (s *Store) ResolveTakedown(ctx context.Context, id int64, status string, actorID int64, note string) (resolved Takedown, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Takedown{}, fmt.Errorf("begin resolve takedown: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var current string
+	if scanErr := tx.QueryRowContext(ctx, `SELECT status FROM takedowns WHERE id = ? FOR UPDATE`, id).Scan(&current); scanErr != nil {
+		if errors.Is(scanErr, sql.ErrNoRows) {
+			err = sql.ErrNoRows
+		} else {
+			err = fmt.Errorf("load takedown: %w", scanErr)
+		}
+		return Takedown{}, err
+	}
+	if current != storage.TakedownStatusPending {
+		err = storage.ErrTakedownNotPending
+		return Takedown{}, err
+	}
+
+	now := time.Now().UTC()
+	if _, execErr := tx.ExecContext(ctx, `UPDATE takedowns SET status = ?, resolved_at = ? WHERE id = ?`, status, now, id); execErr != nil {
+		err = fmt.Errorf("resolve takedown: %w", execErr)
+		return Takedown{}, err
+	}
+	if err = insertTakedownEvent(ctx, tx, id, status, &actorID, note, now); err != nil {
+		return Takedown{}, err
+	}
+
+	takedowns, loadErr := loadTakedowns(ctx, tx, `SELECT `+takedownColumns+` FROM takedowns WHERE id = ?`, id)
+	if loadErr != nil {
+		err = loadErr
+		return Takedown{}, err
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit resolve takedown: %w", commitErr)
+		return Takedown{}, err
+	}
+	return takedowns[0], nil
+}
+
+func (s *Store) ListTakedownEvents(ctx context.Context, takedownID int64) ([]TakedownEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, takedown_id, action, actor_id, note, created_at FROM takedown_events WHERE takedown_id = ? ORDER BY id`, takedownID)
+	if err != nil {
+		return nil, fmt.Errorf("query takedown events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]TakedownEvent, 0)
+	for rows.Next() {
+		var event TakedownEvent
+		var actor sql.NullInt64
+		if err := rows.Scan(&event.ID, &event.TakedownID, &event.Action, &actor, &event.Note, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan takedown event: %w", err)
+		}
+		if actor.Valid {
+			id := actor.Int64
+			event.ActorID = &id
+		}
+		event.CreatedAt = event.CreatedAt.UTC()
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedown events: %w", err)
+	}
+	return events, nil
+}
+
+func (s *Store) ListHiddenPixelIDs(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT DISTINCT p.pixel_id FROM takedown_pixels p JOIN takedowns t ON t.id = p.takedown_id WHERE t.status IN (?, ?) ORDER BY p.pixel_id`,
+		storage.TakedownStatusPending,
+		storage.TakedownStatusUpheld,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query hidden pixels: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan hidden pixel: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate hidden pixels: %w", err)
+	}
+	return ids, nil
+}
+
+func insertTakedownEvent(ctx context.Context, tx *sqltrace.Tx, takedownID int64, action string, actorID *int64, note string, at time.Time) error {
+	var actor sql.NullInt64
+	if actorID != nil {
+		actor = sql.NullInt64{Int64: *actorID, Valid: true}
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO takedown_events (takedown_id, action, actor_id, note, created_at) VALUES (?, ?, ?, ?, ?)`,
+		takedownID,
+		action,
+		actor,
+		note,
+		at.UTC(),
+	); err != nil {
+		return fmt.Errorf("insert takedown event: %w", err)
+	}
+	return nil
+}
+
+// loadTakedowns runs query, which must select takedownColumns, and attaches the covered pixel ids.
+func loadTakedowns(ctx context.Context, db queryer, query string, args ...any) ([]Takedown, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query takedowns: %w", err)
+	}
+	defer rows.Close()
+
+	takedowns := make([]Takedown, 0)
+	index := make(map[int64]int)
+	ids := make([]any, 0)
+	for rows.Next() {
+		var takedown Takedown
+		var resolved sql.NullTime
+		if err := rows.Scan(&takedown.ID, &takedown.ClaimantName, &takedown.ClaimantEmail, &takedown.WorkURL, &takedown.Description, &takedown.Status, &takedown.CreatedAt, &resolved); err != nil {
+			return nil, fmt.Errorf("scan takedown: %w", err)
+		}
+		takedown.CreatedAt = takedown.CreatedAt.UTC()
+		if resolved.Valid {
+			t := resolved.Time.UTC()
+			takedown.ResolvedAt = &t
+		}
+		takedown.PixelIDs = make([]int, 0)
+		index[takedown.ID] = len(takedowns)
+		ids = append(ids, takedown.ID)
+		takedowns = append(takedowns, takedown)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedowns: %w", err)
+	}
+	rows.Close()
+	if len(takedowns) == 0 {
+		return takedowns, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	pixelRows, err := db.QueryContext(ctx, `SELECT takedown_id, pixel_id FROM takedown_pixels WHERE takedown_id IN (`+placeholders+`) ORDER BY pixel_id`, ids...)
+	if err != nil {
+		return nil, fmt.Errorf("query takedown pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var takedownID int64
+		var pixelID int
+		if err := pixelRows.Scan(&takedownID, &pixelID); err != nil {
+			return nil, fmt.Errorf("scan takedown pixel: %w", err)
+		}
+		if i, ok := index[takedownID]; ok {
+			takedowns[i].PixelIDs = append(takedowns[i].PixelIDs, pixelID)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedown pixels: %w", err)
+	}
+	return takedowns, nil
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) GetOwnerWebhook(ctx context.Context, userID int64) (storage.OwnerWebhook, error) {
+	var webhook storage.OwnerWebhook
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, url, secret, created_at, updated_at FROM owner_webhooks WHERE user_id = ?`, userID).
+		Scan(&webhook.UserID, &webhook.URL, &webhook.Secret, &webhook.CreatedAt, &webhook.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.OwnerWebhook{}, sql.ErrNoRows
+		}
+		return storage.OwnerWebhook{}, fmt.Errorf("get owner webhook: %w", err)
+	}
+	webhook.CreatedAt = webhook.CreatedAt.UTC()
+	webhook.UpdatedAt = webhook.UpdatedAt.UTC()
+	return webhook, nil
+}
+
+func (s *Store) PutOwnerWebhook(ctx context.Context, webhook storage.OwnerWebhook) (storage.OwnerWebhook, error) {
+	now := time.Now().UTC()
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO owner_webhooks (user_id, url, secret, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+                 ON DUPLICATE KEY UPDATE url = VALUES(url), secret = VALUES(secret), updated_at = VALUES(updated_at)`,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		now,
+		now,
+	)
+	if err != nil {
+		return storage.OwnerWebhook{}, fmt.Errorf("put owner webhook: %w", err)
+	}
+	return s.GetOwnerWebhook(ctx, webhook.UserID)
+}
+
+func (s *Store) DeleteOwnerWebhook(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM owner_webhooks WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete owner webhook: %w", err)
+	}
+	return nil
+}
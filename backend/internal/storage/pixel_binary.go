@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// PixelRLEMagic opens every grid in the packed binary encoding.
+const PixelRLEMagic = "KPX1"
+
+type pixelRLEEntry struct {
+	color, url string
+}
+
+// WriteRLE streams the public grid (colors and links) in a packed binary format. Neighbouring
+// pixels of one purchase share an entry, so a grid costs a few bytes per run instead of tens of
+// bytes of JSON per pixel. All integers are unsigned varints:
+//
+//	"KPX1" width height
+//	entries {len(color) color len(url) url}...   entry 0 is always the free pixel ("", "")
+//	runs {count entry}...                         in pixel id order until width*height cells
+//
+// An entry with an empty color marks free pixels. Owners and timestamps are not encoded.
+func (s PixelState) WriteRLE(w io.Writer) error {
+	cells := make([]uint32, s.Width*s.Height)
+	entries := []pixelRLEEntry{{}}
+	index := map[pixelRLEEntry]uint32{{}: 0}
+	for _, pixel := range s.Pixels {
+		if pixel.ID < 0 || pixel.ID >= len(cells) || pixel.Color == "" {
+			continue
+		}
+		entry := pixelRLEEntry{color: pixel.Color, url: pixel.URL}
+		i, ok := index[entry]
+		if !ok {
+			i = uint32(len(entries))
+			index[entry] = i
+			entries = append(entries, entry)
+		}
+		cells[pixel.ID] = i
+	}
+
+	bufp := jsonBufferPool.Get().(*[]byte)
+	defer jsonBufferPool.Put(bufp)
+
+	var writeErr error
+	flush := func(dst []byte) []byte {
+		if len(dst) >= pixelStateFlushSize && writeErr == nil {
+			_, writeErr = w.Write(dst)
+			return dst[:0]
+		}
+		return dst
+	}
+
+	dst := append((*bufp)[:0], PixelRLEMagic...)
+	dst = binary.AppendUvarint(dst, uint64(s.Width))
+	dst = binary.AppendUvarint(dst, uint64(s.Height))
+	dst = binary.AppendUvarint(dst, uint64(len(entries)))
+	for _, entry := range entries {
+		dst = binary.AppendUvarint(dst, uint64(len(entry.color)))
+		dst = append(dst, entry.color...)
+		dst = binary.AppendUvarint(dst, uint64(len(entry.url)))
+		dst = append(dst, entry.url...)
+		dst = flush(dst)
+	}
+	for start := 0; start < len(cells); {
+		end := start + 1
+		for end < len(cells) && cells[end] == cells[start] {
+			end++
+		}
+		dst = binary.AppendUvarint(dst, uint64(end-start))
+		dst = binary.AppendUvarint(dst, uint64(cells[start]))
+		dst = flush(dst)
+		start = end
+	}
+	*bufp = dst[:0]
+	if writeErr != nil {
+		return writeErr
+	}
+	_, err := w.Write(dst)
+	return err
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func decodeRLE(t *testing.T, data []byte) (width, height int, cells []pixelRLEEntry) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte(PixelRLEMagic)) {
+		t.Fatalf("missing magic in %q", data)
+	}
+	r := bufio.NewReader(bytes.NewReader(data[len(PixelRLEMagic):]))
+	uvarint := func() int {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			t.Fatalf("read varint: %v", err)
+		}
+		return int(v)
+	}
+	str := func() string {
+		b := make([]byte, uvarint())
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatalf("read string: %v", err)
+		}
+		return string(b)
+	}
+	width, height = uvarint(), uvarint()
+	entries := make([]pixelRLEEntry, uvarint())
+	for i := range entries {
+		entries[i] = pixelRLEEntry{color: str(), url: str()}
+	}
+	for len(cells) < width*height {
+		count, entry := uvarint(), uvarint()
+		for i := 0; i < count; i++ {
+			cells = append(cells, entries[entry])
+		}
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatalf("unexpected trailing data")
+	}
+	return width, height, cells
+}
+
+func TestWriteRLE(t *testing.T) {
+	state := colorState()
+	state.Pixels[1].URL = "https://a.example"
+	state.Pixels[2].URL = "https://a.example"
+	state.Pixels[0].URL = "https://b.example"
+
+	var buf bytes.Buffer
+	if err := state.WriteRLE(&buf); err != nil {
+		t.Fatalf("WriteRLE() error = %v", err)
+	}
+	width, height, cells := decodeRLE(t, buf.Bytes())
+	want := []pixelRLEEntry{
+		{"#ff0000", "https://a.example"}, {"#ff0000", "https://a.example"}, {}, {},
+		{"#00ff00", "https://b.example"}, {},
+	}
+	if width != 3 || height != 2 || len(cells) != len(want) {
+		t.Fatalf("unexpected grid %dx%d with %d cells", width, height, len(cells))
+	}
+	for i := range want {
+		if cells[i] != want[i] {
+			t.Fatalf("cell %d = %+v, want %+v", i, cells[i], want[i])
+		}
+	}
+}
+
+func TestWriteRLEFullGrid(t *testing.T) {
+	state := PixelState{Width: GridWidth, Height: GridHeight}
+	for id := 0; id < TotalPixels; id++ {
+		pixel := Pixel{ID: id, Status: "free"}
+		if id%GridWidth < 10 {
+			pixel = Pixel{ID: id, Status: "taken", Color: "#123456", URL: "https://example.com/" + strings.Repeat("x", id%3)}
+		}
+		state.Pixels = append(state.Pixels, pixel)
+	}
+
+	var buf bytes.Buffer
+	if err := state.WriteRLE(&buf); err != nil {
+		t.Fatalf("WriteRLE() error = %v", err)
+	}
+	_, _, cells := decodeRLE(t, buf.Bytes())
+	if len(cells) != TotalPixels || cells[GridWidth+1].url != "https://example.com/xx" || cells[GridWidth+10].color != "" {
+		t.Fatalf("unexpected decoded grid")
+	}
+	if buf.Len() > 150<<10 {
+		t.Fatalf("encoding is %d bytes", buf.Len())
+	}
+}
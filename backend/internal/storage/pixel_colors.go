@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"io"
+	"strconv"
+)
+
+// Colors returns the color of every grid cell indexed by pixel id; cells without a stored
+// pixel or color are empty.
+func (s PixelState) Colors() []string {
+	colors := make([]string, s.Width*s.Height)
+	for _, pixel := range s.Pixels {
+		if pixel.ID >= 0 && pixel.ID < len(colors) {
+			colors[pixel.ID] = pixel.Color
+		}
+	}
+	return colors
+}
+
+// WriteColorsJSON streams {"width","height","colors":[...]} with one color per pixel id,
+// which is all the canvas renderer needs.
+func (s PixelState) WriteColorsJSON(w io.Writer) error {
+	return s.writeColors(w, func(dst []byte, colors []string, flush func([]byte) []byte) []byte {
+		dst = append(dst, `,"colors":[`...)
+		for i, color := range colors {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, color)
+			if len(dst) >= pixelStateFlushSize {
+				dst = flush(dst)
+			}
+		}
+		return append(dst, ']')
+	})
+}
+
+// WriteColorsRLE streams {"width","height","rle":"..."} where the string holds runs of equal
+// colors in pixel id order as "count:color" separated by ";", e.g. "3:#ff0000;999997:".
+func (s PixelState) WriteColorsRLE(w io.Writer) error {
+	return s.writeColors(w, func(dst []byte, colors []string, flush func([]byte) []byte) []byte {
+		dst = append(dst, `,"rle":"`...)
+		for start := 0; start < len(colors); {
+			end := start + 1
+			for end < len(colors) && colors[end] == colors[start] {
+				end++
+			}
+			if start > 0 {
+				dst = append(dst, ';')
+			}
+			dst = strconv.AppendInt(dst, int64(end-start), 10)
+			dst = append(dst, ':')
+			dst = appendJSONEscaped(dst, colors[start])
+			if len(dst) >= pixelStateFlushSize {
+				dst = flush(dst)
+			}
+			start = end
+		}
+		return append(dst, '"')
+	})
+}
+
+func (s PixelState) writeColors(w io.Writer, body func(dst []byte, colors []string, flush func([]byte) []byte) []byte) error {
+	return s.writeGridObject(w, func(dst []byte, flush func([]byte) []byte) []byte {
+		return body(dst, s.Colors(), flush)
+	})
+}
+
+// writeGridObject streams {"width","height"...} where body appends the remaining members.
+func (s PixelState) writeGridObject(w io.Writer, body func(dst []byte, flush func([]byte) []byte) []byte) error {
+	bufp := jsonBufferPool.Get().(*[]byte)
+	defer jsonBufferPool.Put(bufp)
+
+	var writeErr error
+	flush := func(chunk []byte) []byte {
+		if writeErr == nil {
+			_, writeErr = w.Write(chunk)
+		}
+		return chunk[:0]
+	}
+
+	dst := append((*bufp)[:0], `{"width":`...)
+	dst = strconv.AppendInt(dst, int64(s.Width), 10)
+	dst = append(dst, `,"height":`...)
+	dst = strconv.AppendInt(dst, int64(s.Height), 10)
+	dst = body(dst, flush)
+	dst = append(dst, '}')
+	*bufp = dst[:0]
+	if writeErr != nil {
+		return writeErr
+	}
+	_, err := w.Write(dst)
+	return err
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func colorState() PixelState {
+	return PixelState{Width: 3, Height: 2, Pixels: []Pixel{
+		{ID: 4, Status: "taken", Color: "#00ff00"},
+		{ID: 0, Status: "taken", Color: "#ff0000"},
+		{ID: 1, Status: "taken", Color: "#ff0000"},
+		{ID: 2, Status: "free"},
+		{ID: 99, Status: "taken", Color: "#ignored"},
+	}}
+}
+
+func TestWriteColorsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := colorState().WriteColorsJSON(&buf); err != nil {
+		t.Fatalf("WriteColorsJSON() error = %v", err)
+	}
+	var resp struct {
+		Width  int      `json:"width"`
+		Height int      `json:"height"`
+		Colors []string `json:"colors"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	want := []string{"#ff0000", "#ff0000", "", "", "#00ff00", ""}
+	if resp.Width != 3 || resp.Height != 2 || len(resp.Colors) != len(want) {
+		t.Fatalf("unexpected response %s", buf.String())
+	}
+	for i := range want {
+		if resp.Colors[i] != want[i] {
+			t.Fatalf("color %d = %q, want %q", i, resp.Colors[i], want[i])
+		}
+	}
+}
+
+func TestWriteColorsRLE(t *testing.T) {
+	var buf bytes.Buffer
+	if err := colorState().WriteColorsRLE(&buf); err != nil {
+		t.Fatalf("WriteColorsRLE() error = %v", err)
+	}
+	if want := `{"width":3,"height":2,"rle":"2:#ff0000;2:;1:#00ff00;1:"}`; buf.String() != want {
+		t.Fatalf("unexpected RLE output:\n got %s\nwant %s", buf.String(), want)
+	}
+}
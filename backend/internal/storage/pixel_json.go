@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The grid holds a million pixels, so encoding/json's reflection and the intermediate
+// document it builds dominated allocations of GET /api/pixels. Pixels are encoded by hand
+// here into pooled buffers; the output is byte-for-byte what encoding/json produces.
+
+const pixelStateFlushSize = 32 << 10
+
+var jsonBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, pixelStateFlushSize+512)
+		return &buf
+	},
+}
+
+// PixelFields selects which pixel fields are encoded.
+type PixelFields uint16
+
+const (
+	PixelFieldID PixelFields = 1 << iota
+	PixelFieldStatus
+	PixelFieldColor
+	PixelFieldURL
+	PixelFieldOwnerID
+	PixelFieldUpdatedAt
+	PixelFieldTitle
+	PixelFieldDescription
+	PixelFieldExpiresAt
+
+	AllPixelFields = PixelFieldID | PixelFieldStatus | PixelFieldColor | PixelFieldURL | PixelFieldOwnerID | PixelFieldUpdatedAt |
+		PixelFieldTitle | PixelFieldDescription | PixelFieldExpiresAt
+)
+
+var pixelFieldNames = map[string]PixelFields{
+	"id":          PixelFieldID,
+	"status":      PixelFieldStatus,
+	"color":       PixelFieldColor,
+	"url":         PixelFieldURL,
+	"title":       PixelFieldTitle,
+	"description": PixelFieldDescription,
+	"owner_id":    PixelFieldOwnerID,
+	"updated_at":  PixelFieldUpdatedAt,
+	"expires_at":  PixelFieldExpiresAt,
+}
+
+// ParsePixelFields parses a comma separated list of JSON field names such as "id,status,color".
+// An empty list selects all fields.
+func ParsePixelFields(list string) (PixelFields, error) {
+	var fields PixelFields
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		field, ok := pixelFieldNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown pixel field %q", name)
+		}
+		fields |= field
+	}
+	if fields == 0 {
+		return AllPixelFields, nil
+	}
+	return fields, nil
+}
+
+// AppendJSON appends the JSON encoding of p to dst.
+func (p Pixel) AppendJSON(dst []byte) []byte {
+	return p.AppendJSONFields(dst, AllPixelFields)
+}
+
+// AppendJSONFields appends the JSON encoding of the selected fields of p to dst.
+// Fields tagged omitempty are still left out when empty.
+func (p Pixel) AppendJSONFields(dst []byte, fields PixelFields) []byte {
+	dst = append(dst, '{')
+	first := true
+	if fields&PixelFieldID != 0 {
+		dst = appendJSONKey(dst, "id", &first)
+		dst = strconv.AppendInt(dst, int64(p.ID), 10)
+	}
+	if fields&PixelFieldStatus != 0 {
+		dst = appendJSONKey(dst, "status", &first)
+		dst = appendJSONString(dst, p.Status)
+	}
+	if fields&PixelFieldColor != 0 && p.Color != "" {
+		dst = appendJSONKey(dst, "color", &first)
+		dst = appendJSONString(dst, p.Color)
+	}
+	if fields&PixelFieldURL != 0 && p.URL != "" {
+		dst = appendJSONKey(dst, "url", &first)
+		dst = appendJSONString(dst, p.URL)
+	}
+	if fields&PixelFieldTitle != 0 && p.Title != "" {
+		dst = appendJSONKey(dst, "title", &first)
+		dst = appendJSONString(dst, p.Title)
+	}
+	if fields&PixelFieldDescription != 0 && p.Description != "" {
+		dst = appendJSONKey(dst, "description", &first)
+		dst = appendJSONString(dst, p.Description)
+	}
+	if fields&PixelFieldOwnerID != 0 && p.OwnerID != nil {
+		dst = appendJSONKey(dst, "owner_id", &first)
+		dst = strconv.AppendInt(dst, *p.OwnerID, 10)
+	}
+	if fields&PixelFieldUpdatedAt != 0 {
+		dst = appendJSONKey(dst, "updated_at", &first)
+		dst = append(dst, '"')
+		dst = p.UpdatedAt.AppendFormat(dst, time.RFC3339Nano)
+		dst = append(dst, '"')
+	}
+	if fields&PixelFieldExpiresAt != 0 && p.ExpiresAt != nil {
+		dst = appendJSONKey(dst, "expires_at", &first)
+		dst = append(dst, '"')
+		dst = p.ExpiresAt.AppendFormat(dst, time.RFC3339Nano)
+		dst = append(dst, '"')
+	}
+	return append(dst, '}')
+}
+
+func appendJSONKey(dst []byte, key string, first *bool) []byte {
+	if !*first {
+		dst = append(dst, ',')
+	}
+	*first = false
+	dst = append(dst, '"')
+	dst = append(dst, key...)
+	return append(dst, '"', ':')
+}
+
+func (p Pixel) MarshalJSON() ([]byte, error) {
+	if err := checkJSONTime(p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return p.AppendJSON(make([]byte, 0, 128)), nil
+}
+
+func (s PixelState) MarshalJSON() ([]byte, error) {
+	if err := s.checkTimes(); err != nil {
+		return nil, err
+	}
+	return s.appendJSON(make([]byte, 0, 64+len(s.Pixels)*48), AllPixelFields, false, nil), nil
+}
+
+// WriteJSON streams the JSON encoding of s to w, reusing pooled buffers between calls.
+func (s PixelState) WriteJSON(w io.Writer) error {
+	return s.WriteJSONFields(w, AllPixelFields)
+}
+
+// WriteJSONFields is WriteJSON limited to the selected pixel fields.
+func (s PixelState) WriteJSONFields(w io.Writer, fields PixelFields) error {
+	return s.writeJSON(w, fields, false)
+}
+
+// WriteJSONFreeRanges is WriteJSONFields with runs of consecutive free pixels left out of
+// "pixels" and listed instead as inclusive id ranges in "free_ranges", e.g. [[0,41],[43,999999]].
+func (s PixelState) WriteJSONFreeRanges(w io.Writer, fields PixelFields) error {
+	return s.writeJSON(w, fields, true)
+}
+
+func (s PixelState) writeJSON(w io.Writer, fields PixelFields, freeRanges bool) error {
+	if fields&PixelFieldUpdatedAt != 0 {
+		if err := s.checkTimes(); err != nil {
+			return err
+		}
+	}
+	bufp := jsonBufferPool.Get().(*[]byte)
+	defer jsonBufferPool.Put(bufp)
+
+	var writeErr error
+	buf := s.appendJSON((*bufp)[:0], fields, freeRanges, func(chunk []byte) []byte {
+		if writeErr == nil {
+			_, writeErr = w.Write(chunk)
+		}
+		return chunk[:0]
+	})
+	*bufp = buf[:0]
+	if writeErr != nil {
+		return writeErr
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// appendJSON encodes s into dst. When flush is set it is handed the buffer whenever it grows
+// past pixelStateFlushSize and returns the buffer to continue with.
+func (s PixelState) appendJSON(dst []byte, fields PixelFields, freeRanges bool, flush func([]byte) []byte) []byte {
+	dst = append(dst, `{"width":`...)
+	dst = strconv.AppendInt(dst, int64(s.Width), 10)
+	dst = append(dst, `,"height":`...)
+	dst = strconv.AppendInt(dst, int64(s.Height), 10)
+	if s.Pixels == nil && !freeRanges {
+		return append(dst, `,"pixels":null}`...)
+	}
+	dst = append(dst, `,"pixels":[`...)
+	var ranges [][2]int
+	written := 0
+	for _, pixel := range s.Pixels {
+		if freeRanges && pixel.Status == "free" {
+			if n := len(ranges); n > 0 && ranges[n-1][1] == pixel.ID-1 {
+				ranges[n-1][1] = pixel.ID
+			} else {
+				ranges = append(ranges, [2]int{pixel.ID, pixel.ID})
+			}
+			continue
+		}
+		if written > 0 {
+			dst = append(dst, ',')
+		}
+		written++
+		dst = pixel.AppendJSONFields(dst, fields)
+		if flush != nil && len(dst) >= pixelStateFlushSize {
+			dst = flush(dst)
+		}
+	}
+	dst = append(dst, ']')
+	if freeRanges {
+		dst = append(dst, `,"free_ranges":[`...)
+		for i, r := range ranges {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, '[')
+			dst = strconv.AppendInt(dst, int64(r[0]), 10)
+			dst = append(dst, ',')
+			dst = strconv.AppendInt(dst, int64(r[1]), 10)
+			dst = append(dst, ']')
+		}
+		dst = append(dst, ']')
+	}
+	return append(dst, '}')
+}
+
+func (s PixelState) checkTimes() error {
+	for _, pixel := range s.Pixels {
+		if err := checkJSONTime(pixel.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkJSONTime mirrors time.Time.MarshalJSON, which refuses years outside [0, 9999]
+// and zone offsets of a day or more.
+func checkJSONTime(t time.Time) error {
+	_, offset := t.Zone()
+	if y := t.Year(); y < 0 || y > 9999 || offset <= -24*60*60 || offset >= 24*60*60 {
+		_, err := t.MarshalJSON()
+		return err
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string using encoding/json's default escaping,
+// including HTML-safe escapes and replacement of invalid UTF-8.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	dst = appendJSONEscaped(dst, s)
+	return append(dst, '"')
+}
+
+// appendJSONEscaped appends the escaped contents of a JSON string without the quotes.
+func appendJSONEscaped(dst []byte, s string) []byte {
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	return append(dst, s[start:]...)
+}
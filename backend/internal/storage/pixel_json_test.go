@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stdPixel has Pixel's fields and tags without its MarshalJSON, so encoding/json output can be
+// used as the reference.
+type stdPixel struct {
+	ID          int        `json:"id"`
+	Status      string     `json:"status"`
+	Color       string     `json:"color,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	OwnerID     *int64     `json:"owner_id,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type stdPixelState struct {
+	Width  int        `json:"width"`
+	Height int        `json:"height"`
+	Pixels []stdPixel `json:"pixels"`
+}
+
+func samplePixels() []Pixel {
+	owner := int64(42)
+	warsaw := time.FixedZone("CET", 3600)
+	expires := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	return []Pixel{
+		{ID: 0, Status: "free"},
+		{ID: 1, Status: "taken", Color: "#ff00aa", URL: "https://example.com/?a=1&b=<2>", Title: "Sklep \"Kot\" & <b>", Description: "Zniżki\n\u2028do 50%", OwnerID: &owner, UpdatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC), ExpiresAt: &expires},
+		{ID: 2, Status: "taken", URL: "quote\" back\\slash\nnew\ttab\b\f\x01", UpdatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, warsaw)},
+		{ID: 999999, Status: "zażółć \u2028\u2029 \xff end"},
+	}
+}
+
+func toStd(pixels []Pixel) []stdPixel {
+	out := make([]stdPixel, len(pixels))
+	for i, p := range pixels {
+		out[i] = stdPixel(p)
+	}
+	return out
+}
+
+func TestPixelStateJSONMatchesEncodingJSON(t *testing.T) {
+	pixels := samplePixels()
+	for len(pixels) < 2000 {
+		pixels = append(pixels, samplePixels()...)
+	}
+
+	for _, state := range []PixelState{
+		{Width: GridWidth, Height: GridHeight, Pixels: pixels},
+		{Width: 1, Height: 1, Pixels: []Pixel{}},
+		{Width: 1, Height: 1},
+	} {
+		want, err := json.Marshal(stdPixelState{Width: state.Width, Height: state.Height, Pixels: toStd(state.Pixels)})
+		if state.Pixels == nil {
+			want, err = json.Marshal(stdPixelState{Width: state.Width, Height: state.Height})
+		}
+		if err != nil {
+			t.Fatalf("reference marshal: %v", err)
+		}
+
+		got, err := json.Marshal(state)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Marshal() mismatch:\n got %.300s\nwant %.300s", got, want)
+		}
+
+		var streamed bytes.Buffer
+		if err := state.WriteJSON(&streamed); err != nil {
+			t.Fatalf("WriteJSON() error = %v", err)
+		}
+		if !bytes.Equal(streamed.Bytes(), want) {
+			t.Fatalf("WriteJSON() mismatch:\n got %.300s\nwant %.300s", streamed.Bytes(), want)
+		}
+	}
+}
+
+func TestPixelJSONRoundTrip(t *testing.T) {
+	for _, pixel := range samplePixels()[:3] {
+		data, err := json.Marshal(pixel)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		var decoded Pixel
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", data, err)
+		}
+		if decoded.URL != pixel.URL || !decoded.UpdatedAt.Equal(pixel.UpdatedAt) {
+			t.Fatalf("round trip changed pixel: %+v -> %+v", pixel, decoded)
+		}
+	}
+}
+
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("connection reset")
+}
+
+func TestWriteJSONStopsOnWriteError(t *testing.T) {
+	pixels := make([]Pixel, 5000)
+	for i := range pixels {
+		pixels[i] = Pixel{ID: i, Status: strings.Repeat("x", 20)}
+	}
+	w := &failingWriter{}
+	if err := (PixelState{Width: 1, Height: 1, Pixels: pixels}).WriteJSON(w); err == nil {
+		t.Fatal("expected write error")
+	}
+	if w.writes != 1 {
+		t.Fatalf("expected encoding to stop writing after the first error, got %d writes", w.writes)
+	}
+}
+
+func TestPixelJSONRejectsOutOfRangeTime(t *testing.T) {
+	state := PixelState{Pixels: []Pixel{{Status: "free", UpdatedAt: time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+	if _, err := json.Marshal(state); err == nil {
+		t.Fatal("expected error for year outside [0, 9999]")
+	}
+	if err := state.WriteJSON(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected WriteJSON error for year outside [0, 9999]")
+	}
+}
+
+func TestWriteJSONFields(t *testing.T) {
+	fields, err := ParsePixelFields(" id, status ,color,url")
+	if err != nil {
+		t.Fatalf("ParsePixelFields() error = %v", err)
+	}
+	state := PixelState{Width: 2, Height: 1, Pixels: samplePixels()[:2]}
+
+	var buf bytes.Buffer
+	if err := state.WriteJSONFields(&buf, fields); err != nil {
+		t.Fatalf("WriteJSONFields() error = %v", err)
+	}
+	want := `{"width":2,"height":1,"pixels":[{"id":0,"status":"free"},{"id":1,"status":"taken","color":"#ff00aa","url":"https://example.com/?a=1\u0026b=\u003c2\u003e"}]}`
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n got %s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	_ = state.WriteJSONFields(&buf, PixelFieldColor)
+	if want := `{"width":2,"height":1,"pixels":[{},{"color":"#ff00aa"}]}`; buf.String() != want {
+		t.Fatalf("unexpected output:\n got %s\nwant %s", buf.String(), want)
+	}
+}
+
+func TestParsePixelFields(t *testing.T) {
+	if fields, err := ParsePixelFields(""); err != nil || fields != AllPixelFields {
+		t.Fatalf("empty list should select all fields, got %v %v", fields, err)
+	}
+	if _, err := ParsePixelFields("id,password"); err == nil {
+		t.Fatal("expected unknown field to be rejected")
+	}
+}
+
+func TestWriteJSONFreeRanges(t *testing.T) {
+	state := PixelState{Width: 3, Height: 2, Pixels: []Pixel{
+		{ID: 0, Status: "free"},
+		{ID: 1, Status: "free"},
+		{ID: 2, Status: "taken", Color: "#000000"},
+		{ID: 3, Status: "free"},
+		{ID: 5, Status: "free"},
+	}}
+
+	var buf bytes.Buffer
+	if err := state.WriteJSONFreeRanges(&buf, PixelFieldID|PixelFieldColor); err != nil {
+		t.Fatalf("WriteJSONFreeRanges() error = %v", err)
+	}
+	want := `{"width":3,"height":2,"pixels":[{"id":2,"color":"#000000"}],"free_ranges":[[0,1],[3,3],[5,5]]}`
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n got %s\nwant %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	_ = PixelState{Width: 1, Height: 1}.WriteJSONFreeRanges(&buf, AllPixelFields)
+	if want := `{"width":1,"height":1,"pixels":[],"free_ranges":[]}`; buf.String() != want {
+		t.Fatalf("unexpected empty output %s", buf.String())
+	}
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"io"
+	"strconv"
+)
+
+// Overlay edge bits mark the sides of an owned cell that border another owner, a free cell or
+// the end of the grid.
+const (
+	OverlayEdgeTop = 1 << iota
+	OverlayEdgeRight
+	OverlayEdgeBottom
+	OverlayEdgeLeft
+)
+
+// Overlay describes ownership without revealing owners: Patterns holds, per cell indexed by pixel
+// id, 0 for a free cell or 1..n for the pattern of its owner, and Edges the OverlayEdge bits of
+// the cell. Owners are numbered by their first pixel and given the lowest pattern none of their
+// already numbered neighbours uses, so adjacent owners differ whenever n patterns allow it.
+type Overlay struct {
+	Patterns []uint8
+	Edges    []uint8
+}
+
+// OverlayPatternsMax is the largest number of patterns an Overlay can use.
+const OverlayPatternsMax = 255
+
+// Overlay computes the ownership overlay of the grid with n patterns, clamped to 1 to
+// OverlayPatternsMax. Taken pixels without a recorded owner count as one owner.
+func (s PixelState) Overlay(n int) Overlay {
+	n = max(1, min(n, OverlayPatternsMax))
+	cells := s.Width * s.Height
+	owners := make([]int64, cells)
+	for _, pixel := range s.Pixels {
+		if pixel.ID < 0 || pixel.ID >= cells || pixel.Status != "taken" {
+			continue
+		}
+		owners[pixel.ID] = -1
+		if pixel.OwnerID != nil {
+			owners[pixel.ID] = *pixel.OwnerID
+		}
+	}
+	owned := func(id int) bool { return owners[id] != 0 }
+
+	overlay := Overlay{Patterns: make([]uint8, cells), Edges: make([]uint8, cells)}
+	patterns := make(map[int64]uint8)
+	neighbours := make(map[int64]map[int64]bool)
+	link := func(a, b int64) {
+		for _, pair := range [][2]int64{{a, b}, {b, a}} {
+			if neighbours[pair[0]] == nil {
+				neighbours[pair[0]] = make(map[int64]bool)
+			}
+			neighbours[pair[0]][pair[1]] = true
+		}
+	}
+	for id := 0; id < cells; id++ {
+		if !owned(id) {
+			continue
+		}
+		x, y := id%s.Width, id/s.Width
+		owner := owners[id]
+		var edges uint8
+		if y == 0 || owners[id-s.Width] != owner {
+			edges |= OverlayEdgeTop
+		}
+		if x == s.Width-1 || owners[id+1] != owner {
+			edges |= OverlayEdgeRight
+		}
+		if y == s.Height-1 || owners[id+s.Width] != owner {
+			edges |= OverlayEdgeBottom
+		}
+		if x == 0 || owners[id-1] != owner {
+			edges |= OverlayEdgeLeft
+		}
+		overlay.Edges[id] = edges
+		if edges&OverlayEdgeRight != 0 && x < s.Width-1 && owned(id+1) {
+			link(owner, owners[id+1])
+		}
+		if edges&OverlayEdgeBottom != 0 && y < s.Height-1 && owned(id+s.Width) {
+			link(owner, owners[id+s.Width])
+		}
+	}
+
+	for id := 0; id < cells; id++ {
+		if !owned(id) {
+			continue
+		}
+		owner := owners[id]
+		pattern, ok := patterns[owner]
+		if !ok {
+			used := make([]bool, n+1)
+			for neighbour := range neighbours[owner] {
+				used[patterns[neighbour]] = true
+			}
+			pattern = uint8(len(patterns)%n + 1)
+			for candidate := 1; candidate <= n; candidate++ {
+				if !used[candidate] {
+					pattern = uint8(candidate)
+					break
+				}
+			}
+			patterns[owner] = pattern
+		}
+		overlay.Patterns[id] = pattern
+	}
+	return overlay
+}
+
+// WriteOverlayRLE streams {"width","height","patterns","rle":"..."} where the string holds runs
+// of cells with the same overlay pattern and edges in pixel id order as "count:pattern:edges"
+// separated by ";", e.g. "2:1:13;999998:0:0".
+func (s PixelState) WriteOverlayRLE(w io.Writer, n int) error {
+	n = max(1, min(n, OverlayPatternsMax))
+	overlay := s.Overlay(n)
+	return s.writeGridObject(w, func(dst []byte, flush func([]byte) []byte) []byte {
+		dst = append(dst, `,"patterns":`...)
+		dst = strconv.AppendInt(dst, int64(n), 10)
+		dst = append(dst, `,"rle":"`...)
+		cells := len(overlay.Patterns)
+		for start := 0; start < cells; {
+			end := start + 1
+			for end < cells && overlay.Patterns[end] == overlay.Patterns[start] && overlay.Edges[end] == overlay.Edges[start] {
+				end++
+			}
+			if start > 0 {
+				dst = append(dst, ';')
+			}
+			dst = strconv.AppendInt(dst, int64(end-start), 10)
+			dst = append(dst, ':')
+			dst = strconv.AppendInt(dst, int64(overlay.Patterns[start]), 10)
+			dst = append(dst, ':')
+			dst = strconv.AppendInt(dst, int64(overlay.Edges[start]), 10)
+			if len(dst) >= pixelStateFlushSize {
+				dst = flush(dst)
+			}
+			start = end
+		}
+		return append(dst, '"')
+	})
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func overlayState() PixelState {
+	owner := func(id int64) *int64 { return &id }
+	// A A B
+	// C . B
+	// . . D
+	return PixelState{Width: 3, Height: 3, Pixels: []Pixel{
+		{ID: 0, Status: "taken", OwnerID: owner(10)},
+		{ID: 1, Status: "taken", OwnerID: owner(10)},
+		{ID: 2, Status: "taken", OwnerID: owner(20)},
+		{ID: 3, Status: "taken", OwnerID: owner(30)},
+		{ID: 4, Status: "free"},
+		{ID: 5, Status: "taken", OwnerID: owner(20)},
+		{ID: 8, Status: "taken", OwnerID: owner(40)},
+	}}
+}
+
+func TestOverlayGivesNeighboursDifferentPatterns(t *testing.T) {
+	overlay := overlayState().Overlay(2)
+	wantPatterns := []uint8{1, 1, 2, 2, 0, 2, 0, 0, 1}
+	wantEdges := []uint8{
+		OverlayEdgeTop | OverlayEdgeBottom | OverlayEdgeLeft,
+		OverlayEdgeTop | OverlayEdgeRight | OverlayEdgeBottom,
+		OverlayEdgeTop | OverlayEdgeRight | OverlayEdgeLeft,
+		OverlayEdgeTop | OverlayEdgeRight | OverlayEdgeBottom | OverlayEdgeLeft,
+		0,
+		OverlayEdgeRight | OverlayEdgeBottom | OverlayEdgeLeft,
+		0,
+		0,
+		OverlayEdgeTop | OverlayEdgeRight | OverlayEdgeBottom | OverlayEdgeLeft,
+	}
+	for i := range wantPatterns {
+		if overlay.Patterns[i] != wantPatterns[i] || overlay.Edges[i] != wantEdges[i] {
+			t.Fatalf("cell %d = pattern %d edges %d, want pattern %d edges %d", i, overlay.Patterns[i], overlay.Edges[i], wantPatterns[i], wantEdges[i])
+		}
+	}
+}
+
+func TestWriteOverlayRLE(t *testing.T) {
+	var buf bytes.Buffer
+	if err := overlayState().WriteOverlayRLE(&buf, 2); err != nil {
+		t.Fatalf("WriteOverlayRLE() error = %v", err)
+	}
+	var resp struct {
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+		Patterns int    `json:"patterns"`
+		RLE      string `json:"rle"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", buf.String(), err)
+	}
+	want := "1:1:13;1:1:7;1:2:11;1:2:15;1:0:0;1:2:14;2:0:0;1:1:15"
+	if resp.Width != 3 || resp.Height != 3 || resp.Patterns != 2 || resp.RLE != want {
+		t.Fatalf("unexpected response %s, want rle %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FreePixelColor is what free pixels and pixels without a valid color are drawn with; it matches
+// the background of the frontend canvas.
+var FreePixelColor = color.RGBA{R: 55, G: 65, B: 81, A: 255}
+
+// Image renders the grid at width x height. Scaling down averages the cells each image pixel
+// covers; scaling up repeats the nearest cell.
+func (s PixelState) Image(width, height int) *image.RGBA {
+	cells := make([]color.RGBA, s.Width*s.Height)
+	for i := range cells {
+		cells[i] = FreePixelColor
+	}
+	for _, pixel := range s.Pixels {
+		if pixel.ID < 0 || pixel.ID >= len(cells) || pixel.Status != "taken" {
+			continue
+		}
+		if rgb, ok := parseHexColor(pixel.Color); ok {
+			cells[pixel.ID] = rgb
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * s.Height / height
+		y1 := max((dy+1)*s.Height/height, y0+1)
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * s.Width / width
+			x1 := max((dx+1)*s.Width/width, x0+1)
+			var r, g, b, n int
+			for y := y0; y < y1; y++ {
+				for _, cell := range cells[y*s.Width+x0 : y*s.Width+x1] {
+					r, g, b, n = r+int(cell.R), g+int(cell.G), b+int(cell.B), n+1
+				}
+			}
+			img.SetRGBA(dx, dy, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255})
+		}
+	}
+	return img
+}
+
+// WritePNG encodes the grid rendered by Image as a PNG.
+func (s PixelState) WritePNG(w io.Writer, width, height int) error {
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	return encoder.Encode(w, s.Image(width, height))
+}
+
+// parseHexColor reads "#rgb" and "#rrggbb" colors.
+func parseHexColor(value string) (color.RGBA, bool) {
+	hex, ok := strings.CutPrefix(strings.TrimSpace(value), "#")
+	if !ok {
+		return color.RGBA{}, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, true
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestImage(t *testing.T) {
+	state := PixelState{Width: 4, Height: 2, Pixels: []Pixel{
+		{ID: 0, Status: "taken", Color: "#ff0000"},
+		{ID: 1, Status: "taken", Color: "#f00"},
+		{ID: 4, Status: "taken", Color: "#ff0000"},
+		{ID: 5, Status: "taken", Color: "#ff0000"},
+		{ID: 2, Status: "taken", Color: "red"},
+		{ID: 3, Status: "free", Color: "#00ff00"},
+	}}
+
+	same := state.Image(4, 2)
+	if got := same.RGBAAt(1, 0); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("short color drawn as %v", got)
+	}
+	for _, x := range []int{2, 3} {
+		if got := same.RGBAAt(x, 0); got != FreePixelColor {
+			t.Fatalf("pixel %d drawn as %v, want the free color", x, got)
+		}
+	}
+
+	half := state.Image(2, 1)
+	if got := half.RGBAAt(0, 0); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("red block averaged to %v", got)
+	}
+	if got := half.RGBAAt(1, 0); got != FreePixelColor {
+		t.Fatalf("free block averaged to %v", got)
+	}
+
+	double := state.Image(8, 4)
+	if got := double.RGBAAt(1, 1); got != (color.RGBA{R: 255, A: 255}) {
+		t.Fatalf("upscaled cell drawn as %v", got)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := colorState().WritePNG(&buf, 6, 4); err != nil {
+		t.Fatalf("WritePNG() error = %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 6 || bounds.Dy() != 4 {
+		t.Fatalf("unexpected size %v", bounds)
+	}
+	if r, g, b, _ := img.At(2, 2).RGBA(); r != 0 || g != 0xffff || b != 0 {
+		t.Fatalf("unexpected color %v", img.At(2, 2))
+	}
+}
@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *Store) SaveAgeAttestation(ctx context.Context, attestation AgeAttestation) error {
+	if attestation.UserID <= 0 {
+		return errors.New("invalid user id")
+	}
+	attested := attestation.AttestedAt
+	if attested.IsZero() {
+		attested = time.Now()
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO age_attestations(user_id, birth_year, minimum_age, attested_at) VALUES (%d, %d, %d, %s) ON CONFLICT(user_id) DO UPDATE SET birth_year = excluded.birth_year, minimum_age = excluded.minimum_age, attested_at = excluded.attested_at",
+		attestation.UserID,
+		attestation.BirthYear,
+		attestation.MinimumAge,
+		quoteLiteral(attested.UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("save age attestation: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetAgeAttestation(ctx context.Context, userID int64) (AgeAttestation, error) {
+	query := fmt.Sprintf("SELECT user_id, birth_year, minimum_age, attested_at FROM age_attestations WHERE user_id = %d", userID)
+	var attestation AgeAttestation
+	var attested string
+	if err := s.db.QueryRowContext(ctx, query).Scan(&attestation.UserID, &attestation.BirthYear, &attestation.MinimumAge, &attested); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AgeAttestation{}, sql.ErrNoRows
+		}
+		return AgeAttestation{}, fmt.Errorf("get age attestation: %w", err)
+	}
+	parsed, err := parseUpdatedAt(attested)
+	if err != nil {
+		return AgeAttestation{}, fmt.Errorf("parse attested_at: %w", err)
+	}
+	attestation.AttestedAt = parsed
+	return attestation, nil
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const adminActionColumns = "id, admin_id, action, target, pixel_ids, reason, created_at"
+
+func (s *Store) ForceFreePixels(ctx context.Context, action storage.AdminAction) (_ storage.AdminAction, pixels []Pixel, err error) {
+	if len(action.PixelIDs) == 0 {
+		return storage.AdminAction{}, nil, errors.New("force free needs at least one pixel")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.AdminAction{}, nil, fmt.Errorf("begin force free pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	pixels, _, err = freePixelsWhere(ctx, tx, "status <> 'free' AND "+pixelIDsIn(action.PixelIDs))
+	if err != nil {
+		return storage.AdminAction{}, nil, err
+	}
+	if len(pixels) == 0 {
+		return storage.AdminAction{}, nil, tx.Rollback()
+	}
+	action.PixelIDs = make([]int, len(pixels))
+	for i, pixel := range pixels {
+		action.PixelIDs[i] = pixel.ID
+	}
+
+	action.CreatedAt = time.Now().UTC()
+	query := fmt.Sprintf(
+		"INSERT INTO admin_actions(admin_id, action, target, pixel_ids, reason, created_at) VALUES (%d, %s, %s, %s, %s, %s)",
+		action.AdminID,
+		quoteLiteral(action.Action),
+		quoteLiteral(action.Target),
+		quoteLiteral(joinPixelIDs(action.PixelIDs)),
+		quoteLiteral(action.Reason),
+		quoteLiteral(action.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return storage.AdminAction{}, nil, fmt.Errorf("insert admin action: %w", err)
+	}
+	if action.ID, err = res.LastInsertId(); err != nil {
+		return storage.AdminAction{}, nil, fmt.Errorf("admin action id: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.AdminAction{}, nil, fmt.Errorf("commit force free pixels: %w", err)
+	}
+	return action, pixels, nil
+}
+
+func (s *Store) ListAdminActions(ctx context.Context, limit int) ([]storage.AdminAction, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM admin_actions ORDER BY id DESC LIMIT %d", adminActionColumns, limit))
+	if err != nil {
+		return nil, fmt.Errorf("query admin actions: %w", err)
+	}
+	defer rows.Close()
+
+	actions := make([]storage.AdminAction, 0)
+	for rows.Next() {
+		var action storage.AdminAction
+		var ids, created string
+		if err := rows.Scan(&action.ID, &action.AdminID, &action.Action, &action.Target, &ids, &action.Reason, &created); err != nil {
+			return nil, fmt.Errorf("scan admin action: %w", err)
+		}
+		if action.PixelIDs, err = splitPixelIDs(ids); err != nil {
+			return nil, fmt.Errorf("parse admin action %d pixel_ids: %w", action.ID, err)
+		}
+		if action.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse admin action created_at: %w", err)
+		}
+		actions = append(actions, action)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate admin actions: %w", err)
+	}
+	return actions, nil
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// onMainBoard restricts a query on pixels or pixel_history to the main grid.
+const onMainBoard = "board_id = ''"
+
+// onBoard restricts a query on pixels or pixel_history to the board.
+func onBoard(board string) string {
+	return "board_id = " + quoteLiteral(board)
+}
+
+// GetBoardPixels fills the ids missing from the board with free pixels; unlike the main grid,
+// boards get a row only once a pixel is first bought.
+func (s *Store) GetBoardPixels(ctx context.Context, boardID string) (PixelState, error) {
+	stored, err := s.loadBoardPixels(ctx, boardID)
+	if err != nil {
+		return PixelState{}, err
+	}
+	pixels := make([]Pixel, storage.TotalPixels)
+	for id := range pixels {
+		pixels[id] = Pixel{ID: id, Status: "free"}
+	}
+	for _, pixel := range stored {
+		pixels[pixel.ID] = pixel
+	}
+	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+}
+
+func (s *Store) UpdateBoardPixelsForUser(ctx context.Context, boardID string, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	if boardID == storage.MainBoard {
+		return nil, User{}, errors.New("board id must not be empty")
+	}
+	return s.updatePixelsWithCost(ctx, boardID, userID, userID, pixels, cost, priceVersion)
+}
+
+// insertBoardPixels adds free rows for those of ids the board does not have yet.
+func insertBoardPixels(ctx context.Context, tx *sqltrace.Tx, board string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = fmt.Sprintf("(%s, %d, 'free', '', '', CURRENT_TIMESTAMP)", quoteLiteral(board), id)
+	}
+	query := "INSERT OR IGNORE INTO pixels(board_id, id, status, color, url, updated_at) VALUES " + strings.Join(values, ", ")
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("insert board pixels: %w", err)
+	}
+	return nil
+}
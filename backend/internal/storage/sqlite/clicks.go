@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) RecordPixelClick(ctx context.Context, pixelID int, ownerID int64, visitor string, at time.Time) (err error) {
+	day := quoteLiteral(at.UTC().Format(time.DateOnly))
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin record pixel click: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := fmt.Sprintf(
+		"INSERT INTO pixel_clicks(pixel_id, owner_id, day, clicks) VALUES (%d, %d, %s, 1) ON CONFLICT(pixel_id, owner_id, day) DO UPDATE SET clicks = clicks + 1",
+		pixelID,
+		ownerID,
+		day,
+	)
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("record pixel click: %w", err)
+	}
+	if visitor != "" {
+		query = fmt.Sprintf(
+			"INSERT OR IGNORE INTO pixel_visitors(pixel_id, owner_id, day, visitor) VALUES (%d, %d, %s, %s)",
+			pixelID,
+			ownerID,
+			day,
+			quoteLiteral(visitor),
+		)
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("record pixel visitor: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit record pixel click: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListPixelClicksByOwner(ctx context.Context, ownerID int64, since time.Time) ([]storage.PixelClicks, error) {
+	query := fmt.Sprintf(
+		"SELECT pixel_id, SUM(clicks) FROM pixel_clicks WHERE owner_id = %d AND day >= %s GROUP BY pixel_id ORDER BY pixel_id",
+		ownerID,
+		quoteLiteral(since.UTC().Format(time.DateOnly)),
+	)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel clicks: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := make([]storage.PixelClicks, 0)
+	for rows.Next() {
+		var entry storage.PixelClicks
+		if err := rows.Scan(&entry.PixelID, &entry.Clicks); err != nil {
+			return nil, fmt.Errorf("scan pixel clicks: %w", err)
+		}
+		clicks = append(clicks, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel clicks: %w", err)
+	}
+	return clicks, nil
+}
+
+func (s *Store) GetPixelClickDays(ctx context.Context, pixelID int, ownerID int64, since time.Time) ([]storage.PixelClickDay, error) {
+	query := fmt.Sprintf(
+		`SELECT c.day, c.clicks,
+                        (SELECT COUNT(*) FROM pixel_visitors v WHERE v.pixel_id = c.pixel_id AND v.owner_id = c.owner_id AND v.day = c.day)
+                 FROM pixel_clicks c WHERE c.pixel_id = %d AND c.owner_id = %d AND c.day >= %s ORDER BY c.day`,
+		pixelID,
+		ownerID,
+		quoteLiteral(since.UTC().Format(time.DateOnly)),
+	)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel click days: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]storage.PixelClickDay, 0)
+	for rows.Next() {
+		var entry storage.PixelClickDay
+		if err := rows.Scan(&entry.Day, &entry.Clicks, &entry.Visitors); err != nil {
+			return nil, fmt.Errorf("scan pixel click day: %w", err)
+		}
+		days = append(days, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel click days: %w", err)
+	}
+	return days, nil
+}
@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const contactMessageColumns = "id, user_id, name, email, subject, body, status, created_at"
+
+func (s *Store) CreateContactMessage(ctx context.Context, message ContactMessage) (ContactMessage, error) {
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	message.CreatedAt = message.CreatedAt.UTC()
+	message.Status = storage.ContactStatusOpen
+	userID := "NULL"
+	if message.UserID != nil {
+		userID = strconv.FormatInt(*message.UserID, 10)
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO contact_messages(user_id, name, email, subject, body, status, created_at) VALUES (%s, %s, %s, %s, %s, %s, %s)",
+		userID,
+		quoteLiteral(message.Name),
+		quoteLiteral(message.Email),
+		quoteLiteral(message.Subject),
+		quoteLiteral(message.Body),
+		quoteLiteral(message.Status),
+		quoteLiteral(message.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return ContactMessage{}, fmt.Errorf("insert contact message: %w", err)
+	}
+	if message.ID, err = res.LastInsertId(); err != nil {
+		return ContactMessage{}, fmt.Errorf("contact message id: %w", err)
+	}
+	return message, nil
+}
+
+func (s *Store) GetContactMessage(ctx context.Context, id int64) (ContactMessage, error) {
+	query := fmt.Sprintf("SELECT %s FROM contact_messages WHERE id = %d", contactMessageColumns, id)
+	return scanContactMessage(s.db.QueryRowContext(ctx, query))
+}
+
+func (s *Store) ListContactMessages(ctx context.Context, status string) ([]ContactMessage, error) {
+	where := ""
+	if status != "" {
+		where = " WHERE status = " + quoteLiteral(status)
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM contact_messages%s ORDER BY created_at DESC, id DESC", contactMessageColumns, where))
+	if err != nil {
+		return nil, fmt.Errorf("query contact messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := make([]ContactMessage, 0)
+	for rows.Next() {
+		message, err := scanContactMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contact messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (s *Store) CreateContactReply(ctx context.Context, reply ContactReply) (created ContactReply, err error) {
+	if reply.CreatedAt.IsZero() {
+		reply.CreatedAt = time.Now()
+	}
+	reply.CreatedAt = reply.CreatedAt.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ContactReply{}, fmt.Errorf("begin contact reply: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	update := fmt.Sprintf("UPDATE contact_messages SET status = %s WHERE id = %d", quoteLiteral(storage.ContactStatusAnswered), reply.MessageID)
+	res, execErr := tx.ExecContext(ctx, update)
+	if execErr != nil {
+		err = fmt.Errorf("mark contact message answered: %w", execErr)
+		return ContactReply{}, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		err = sql.ErrNoRows
+		return ContactReply{}, err
+	}
+	insert := fmt.Sprintf(
+		"INSERT INTO contact_replies(message_id, admin_id, body, created_at) VALUES (%d, %d, %s, %s)",
+		reply.MessageID,
+		reply.AdminID,
+		quoteLiteral(reply.Body),
+		quoteLiteral(reply.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, execErr = tx.ExecContext(ctx, insert)
+	if execErr != nil {
+		err = fmt.Errorf("insert contact reply: %w", execErr)
+		return ContactReply{}, err
+	}
+	if reply.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("contact reply id: %w", err)
+		return ContactReply{}, err
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit contact reply: %w", commitErr)
+		return ContactReply{}, err
+	}
+	return reply, nil
+}
+
+func (s *Store) ListContactReplies(ctx context.Context, messageID int64) ([]ContactReply, error) {
+	query := fmt.Sprintf("SELECT id, message_id, admin_id, body, created_at FROM contact_replies WHERE message_id = %d ORDER BY id", messageID)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query contact replies: %w", err)
+	}
+	defer rows.Close()
+
+	replies := make([]ContactReply, 0)
+	for rows.Next() {
+		var reply ContactReply
+		var created string
+		if err := rows.Scan(&reply.ID, &reply.MessageID, &reply.AdminID, &reply.Body, &created); err != nil {
+			return nil, fmt.Errorf("scan contact reply: %w", err)
+		}
+		if reply.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse contact reply created_at: %w", err)
+		}
+		replies = append(replies, reply)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate contact replies: %w", err)
+	}
+	return replies, nil
+}
+
+func scanContactMessage(row rowScanner) (ContactMessage, error) {
+	var message ContactMessage
+	var userID sql.NullInt64
+	var created string
+	if err := row.Scan(&message.ID, &userID, &message.Name, &message.Email, &message.Subject, &message.Body, &message.Status, &created); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ContactMessage{}, sql.ErrNoRows
+		}
+		return ContactMessage{}, fmt.Errorf("scan contact message: %w", err)
+	}
+	if userID.Valid {
+		id := userID.Int64
+		message.UserID = &id
+	}
+	parsed, err := parseUpdatedAt(created)
+	if err != nil {
+		return ContactMessage{}, fmt.Errorf("parse contact message created_at: %w", err)
+	}
+	message.CreatedAt = parsed
+	return message, nil
+}
@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const customDomainColumns = "id, domain, user_id, pixel_id, token, verified_at, last_checked_at, created_at"
+
+func (s *Store) CreateCustomDomain(ctx context.Context, domain storage.CustomDomain) (storage.CustomDomain, error) {
+	if domain.CreatedAt.IsZero() {
+		domain.CreatedAt = time.Now()
+	}
+	domain.CreatedAt = domain.CreatedAt.UTC()
+	pixelID := "NULL"
+	if domain.PixelID != nil {
+		pixelID = strconv.Itoa(*domain.PixelID)
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO custom_domains(domain, user_id, pixel_id, token, created_at) SELECT %s, %d, %s, %s, %s WHERE NOT EXISTS (SELECT 1 FROM custom_domains WHERE domain = %s AND verified_at IS NOT NULL)",
+		quoteLiteral(domain.Domain),
+		domain.UserID,
+		pixelID,
+		quoteLiteral(domain.Token),
+		quoteLiteral(domain.CreatedAt.Format(time.RFC3339Nano)),
+		quoteLiteral(domain.Domain),
+	)
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("insert custom domain: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("insert custom domain: %w", err)
+	} else if affected == 0 {
+		return storage.CustomDomain{}, storage.ErrDomainTaken
+	}
+	if domain.ID, err = res.LastInsertId(); err != nil {
+		return storage.CustomDomain{}, fmt.Errorf("custom domain id: %w", err)
+	}
+	domain.VerifiedAt, domain.LastCheckedAt = nil, nil
+	return domain, nil
+}
+
+func (s *Store) ListCustomDomains(ctx context.Context, userID int64) ([]storage.CustomDomain, error) {
+	return s.loadCustomDomains(ctx, fmt.Sprintf("SELECT %s FROM custom_domains WHERE user_id = %d ORDER BY id", customDomainColumns, userID))
+}
+
+func (s *Store) ListCustomDomainsByState(ctx context.Context, verified bool) ([]storage.CustomDomain, error) {
+	condition := "verified_at IS NULL"
+	if verified {
+		condition = "verified_at IS NOT NULL"
+	}
+	return s.loadCustomDomains(ctx, fmt.Sprintf("SELECT %s FROM custom_domains WHERE %s ORDER BY id", customDomainColumns, condition))
+}
+
+func (s *Store) MarkCustomDomainChecked(ctx context.Context, id int64, verified bool, at time.Time) (err error) {
+	stamp := quoteLiteral(at.UTC().Format(time.RFC3339Nano))
+	if !verified {
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("UPDATE custom_domains SET last_checked_at = %s WHERE id = %d", stamp, id)); err != nil {
+			return fmt.Errorf("mark custom domain checked: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin verify custom domain: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var domain string
+	if err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT domain FROM custom_domains WHERE id = %d", id)).Scan(&domain); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// The domain was deleted since it was listed.
+			_ = tx.Rollback()
+			return nil
+		}
+		return fmt.Errorf("load custom domain: %w", err)
+	}
+	var taken int
+	if err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM custom_domains WHERE domain = %s AND id <> %d AND verified_at IS NOT NULL", quoteLiteral(domain), id)).Scan(&taken); err != nil {
+		return fmt.Errorf("check custom domain claims: %w", err)
+	}
+	set := "last_checked_at = " + stamp
+	if taken == 0 {
+		set += ", verified_at = COALESCE(verified_at, " + stamp + ")"
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE custom_domains SET %s WHERE id = %d", set, id)); err != nil {
+		return fmt.Errorf("mark custom domain checked: %w", err)
+	}
+	if taken == 0 {
+		// The first verified claim wins; the other pending claims of the domain cannot be verified any more.
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM custom_domains WHERE domain = %s AND id <> %d AND verified_at IS NULL", quoteLiteral(domain), id)); err != nil {
+			return fmt.Errorf("delete losing custom domain claims: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit custom domain check: %w", err)
+	}
+	if taken > 0 {
+		return storage.ErrDomainTaken
+	}
+	return nil
+}
+
+func (s *Store) DeleteCustomDomain(ctx context.Context, userID, id int64) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM custom_domains WHERE id = %d AND user_id = %d", id, userID)); err != nil {
+		return fmt.Errorf("delete custom domain: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteUnverifiedCustomDomains(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM custom_domains WHERE verified_at IS NULL AND julianday(created_at) < julianday(%s)",
+		quoteLiteral(before.UTC().Format(time.RFC3339Nano)),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("delete unverified custom domains: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) loadCustomDomains(ctx context.Context, query string) ([]storage.CustomDomain, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query custom domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := make([]storage.CustomDomain, 0)
+	for rows.Next() {
+		var domain storage.CustomDomain
+		var pixelID sql.NullInt64
+		var verified, checked sql.NullString
+		var created string
+		if err := rows.Scan(&domain.ID, &domain.Domain, &domain.UserID, &pixelID, &domain.Token, &verified, &checked, &created); err != nil {
+			return nil, fmt.Errorf("scan custom domain: %w", err)
+		}
+		if pixelID.Valid {
+			id := int(pixelID.Int64)
+			domain.PixelID = &id
+		}
+		if domain.VerifiedAt, err = parseExpiresAt(verified); err != nil {
+			return nil, fmt.Errorf("parse custom domain verified_at: %w", err)
+		}
+		if domain.LastCheckedAt, err = parseExpiresAt(checked); err != nil {
+			return nil, fmt.Errorf("parse custom domain last_checked_at: %w", err)
+		}
+		if domain.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse custom domain created_at: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate custom domains: %w", err)
+	}
+	return domains, nil
+}
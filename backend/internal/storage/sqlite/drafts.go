@@ -0,0 +1,218 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const pixelDraftColumns = "id, user_id, name, created_at, updated_at"
+
+func (s *Store) CreatePixelDraft(ctx context.Context, draft storage.PixelDraft) (_ storage.PixelDraft, err error) {
+	now := time.Now().UTC()
+	draft.CreatedAt, draft.UpdatedAt = now, now
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("begin create pixel draft: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := fmt.Sprintf(
+		"INSERT INTO pixel_drafts(user_id, name, created_at, updated_at) VALUES (%d, %s, %s, %s)",
+		draft.UserID,
+		quoteLiteral(draft.Name),
+		quoteLiteral(now.Format(time.RFC3339Nano)),
+		quoteLiteral(now.Format(time.RFC3339Nano)),
+	)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("insert pixel draft: %w", err)
+	}
+	if draft.ID, err = res.LastInsertId(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("pixel draft id: %w", err)
+	}
+	if err = insertDraftPixels(ctx, tx, draft); err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("commit pixel draft: %w", err)
+	}
+	return draft, nil
+}
+
+func (s *Store) ListPixelDrafts(ctx context.Context, userID int64) ([]storage.PixelDraft, error) {
+	return loadPixelDrafts(ctx, s.db, fmt.Sprintf("SELECT %s FROM pixel_drafts WHERE user_id = %d ORDER BY updated_at DESC, id DESC", pixelDraftColumns, userID))
+}
+
+func (s *Store) GetPixelDraft(ctx context.Context, userID, id int64) (storage.PixelDraft, error) {
+	drafts, err := loadPixelDrafts(ctx, s.db, fmt.Sprintf("SELECT %s FROM pixel_drafts WHERE id = %d AND user_id = %d", pixelDraftColumns, id, userID))
+	if err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if len(drafts) == 0 {
+		return storage.PixelDraft{}, sql.ErrNoRows
+	}
+	return drafts[0], nil
+}
+
+func (s *Store) UpdatePixelDraft(ctx context.Context, draft storage.PixelDraft) (_ storage.PixelDraft, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("begin update pixel draft: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+	query := fmt.Sprintf(
+		"UPDATE pixel_drafts SET name = %s, updated_at = %s WHERE id = %d AND user_id = %d",
+		quoteLiteral(draft.Name),
+		quoteLiteral(now.Format(time.RFC3339Nano)),
+		draft.ID,
+		draft.UserID,
+	)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("update pixel draft: %w", err)
+	}
+	if affected, affectedErr := res.RowsAffected(); affectedErr != nil {
+		err = fmt.Errorf("update pixel draft: %w", affectedErr)
+		return storage.PixelDraft{}, err
+	} else if affected == 0 {
+		err = sql.ErrNoRows
+		return storage.PixelDraft{}, err
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_draft_pixels WHERE draft_id = %d", draft.ID)); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("clear pixel draft pixels: %w", err)
+	}
+	if err = insertDraftPixels(ctx, tx, draft); err != nil {
+		return storage.PixelDraft{}, err
+	}
+	drafts, err := loadPixelDrafts(ctx, tx, fmt.Sprintf("SELECT %s FROM pixel_drafts WHERE id = %d", pixelDraftColumns, draft.ID))
+	if err != nil {
+		return storage.PixelDraft{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelDraft{}, fmt.Errorf("commit pixel draft: %w", err)
+	}
+	return drafts[0], nil
+}
+
+func (s *Store) DeletePixelDraft(ctx context.Context, userID, id int64) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete pixel draft: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_drafts WHERE id = %d AND user_id = %d", id, userID))
+	if err != nil {
+		return fmt.Errorf("delete pixel draft: %w", err)
+	}
+	if affected, affectedErr := res.RowsAffected(); affectedErr != nil {
+		err = fmt.Errorf("delete pixel draft: %w", affectedErr)
+		return err
+	} else if affected == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_draft_pixels WHERE draft_id = %d", id)); err != nil {
+		return fmt.Errorf("delete pixel draft pixels: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete pixel draft: %w", err)
+	}
+	return nil
+}
+
+func insertDraftPixels(ctx context.Context, tx *sqltrace.Tx, draft storage.PixelDraft) error {
+	for _, pixel := range draft.Pixels {
+		query := fmt.Sprintf(
+			"INSERT OR REPLACE INTO pixel_draft_pixels(draft_id, pixel_id, color, url, title, description) VALUES (%d, %d, %s, %s, %s, %s)",
+			draft.ID,
+			pixel.ID,
+			quoteLiteral(pixel.Color),
+			quoteLiteral(pixel.URL),
+			quoteLiteral(pixel.Title),
+			quoteLiteral(pixel.Description),
+		)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("insert pixel draft pixel: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadPixelDrafts runs query, which must select pixelDraftColumns, and attaches the drafts' pixels.
+func loadPixelDrafts(ctx context.Context, db queryer, query string) ([]storage.PixelDraft, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := make([]storage.PixelDraft, 0)
+	index := make(map[int64]int)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var draft storage.PixelDraft
+		var created, updated string
+		if err := rows.Scan(&draft.ID, &draft.UserID, &draft.Name, &created, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel draft: %w", err)
+		}
+		if draft.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse pixel draft created_at: %w", err)
+		}
+		if draft.UpdatedAt, err = parseUpdatedAt(updated); err != nil {
+			return nil, fmt.Errorf("parse pixel draft updated_at: %w", err)
+		}
+		draft.Pixels = make([]storage.DraftPixel, 0)
+		index[draft.ID] = len(drafts)
+		ids = append(ids, strconv.FormatInt(draft.ID, 10))
+		drafts = append(drafts, draft)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel drafts: %w", err)
+	}
+	rows.Close()
+	if len(drafts) == 0 {
+		return drafts, nil
+	}
+
+	pixelRows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT draft_id, pixel_id, color, url, title, description FROM pixel_draft_pixels WHERE draft_id IN (%s) ORDER BY pixel_id", strings.Join(ids, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("query pixel draft pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var draftID int64
+		var pixel storage.DraftPixel
+		if err := pixelRows.Scan(&draftID, &pixel.ID, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description); err != nil {
+			return nil, fmt.Errorf("scan pixel draft pixel: %w", err)
+		}
+		if i, ok := index[draftID]; ok {
+			drafts[i].Pixels = append(drafts[i].Pixels, pixel)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel draft pixels: %w", err)
+	}
+	return drafts, nil
+}
@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const pixelChangeColumns = "id, pixel_id, status, color, url, owner_id, previous_owner_id, changed_at"
+
+// loadPixelHistoryState reads the fields of pixel id that its history tracks, and its rental
+// expiry. It returns sql.ErrNoRows when the pixel does not exist.
+func loadPixelHistoryState(ctx context.Context, tx *sqltrace.Tx, id int) (Pixel, error) {
+	pixel := Pixel{ID: id}
+	var owner sql.NullInt64
+	var expires sql.NullString
+	query := fmt.Sprintf("SELECT status, color, url, owner_id, expires_at FROM pixels WHERE %s AND id = %d", onMainBoard, id)
+	if err := tx.QueryRowContext(ctx, query).Scan(&pixel.Status, &pixel.Color, &pixel.URL, &owner, &expires); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Pixel{}, sql.ErrNoRows
+		}
+		return Pixel{}, fmt.Errorf("load current pixel state: %w", err)
+	}
+	if owner.Valid {
+		ownerID := owner.Int64
+		pixel.OwnerID = &ownerID
+	}
+	var err error
+	if pixel.ExpiresAt, err = parseExpiresAt(expires); err != nil {
+		return Pixel{}, fmt.Errorf("parse pixel %d expires_at: %w", id, err)
+	}
+	return pixel, nil
+}
+
+// loadPixelHistoryStates is loadPixelHistoryState for many pixels of board in one query. Pixels
+// that do not exist are missing from the map.
+func loadPixelHistoryStates(ctx context.Context, tx *sqltrace.Tx, board string, ids []int) (map[int]Pixel, error) {
+	states := make(map[int]Pixel, len(ids))
+	if len(ids) == 0 {
+		return states, nil
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, status, color, url, owner_id, expires_at FROM pixels WHERE "+onBoard(board)+" AND "+pixelIDsIn(ids))
+	if err != nil {
+		return nil, fmt.Errorf("load current pixel states: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var expires sql.NullString
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &expires); err != nil {
+			return nil, fmt.Errorf("scan current pixel state: %w", err)
+		}
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		if pixel.ExpiresAt, err = parseExpiresAt(expires); err != nil {
+			return nil, fmt.Errorf("parse pixel %d expires_at: %w", pixel.ID, err)
+		}
+		states[pixel.ID] = pixel
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate current pixel states: %w", err)
+	}
+	return states, nil
+}
+
+// pixelTransition is one write of a pixel, from before to after.
+type pixelTransition struct {
+	before, after Pixel
+}
+
+// recordPixelChange logs after in pixel_history when its status, color, link or owner differs
+// from before. Title and description edits are not recorded.
+func recordPixelChange(ctx context.Context, tx *sqltrace.Tx, before, after Pixel) error {
+	return recordPixelChanges(ctx, tx, storage.MainBoard, []pixelTransition{{before: before, after: after}})
+}
+
+// recordPixelChanges is recordPixelChange for many writes to board in one statement.
+func recordPixelChanges(ctx context.Context, tx *sqltrace.Tx, board string, transitions []pixelTransition) error {
+	values := make([]string, 0, len(transitions))
+	for _, t := range transitions {
+		before, after := t.before, t.after
+		if before.Status == after.Status && before.Color == after.Color && before.URL == after.URL && sameOwner(before.OwnerID, after.OwnerID) {
+			continue
+		}
+		values = append(values, fmt.Sprintf(
+			"(%s, %d, %s, %s, %s, %s, %s, %s)",
+			quoteLiteral(board),
+			after.ID,
+			quoteLiteral(after.Status),
+			quoteLiteral(after.Color),
+			quoteLiteral(after.URL),
+			ownerLiteral(after.OwnerID),
+			ownerLiteral(before.OwnerID),
+			quoteLiteral(after.UpdatedAt.UTC().Format(time.RFC3339Nano)),
+		))
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	query := "INSERT INTO pixel_history(board_id, pixel_id, status, color, url, owner_id, previous_owner_id, changed_at) VALUES " + strings.Join(values, ", ")
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("insert pixel history: %w", err)
+	}
+	return nil
+}
+
+func sameOwner(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func ownerLiteral(owner *int64) string {
+	if owner == nil {
+		return "NULL"
+	}
+	return strconv.FormatInt(*owner, 10)
+}
+
+func (s *Store) ListPixelHistory(ctx context.Context, pixelID int, limit int) ([]storage.PixelChange, error) {
+	return s.loadPixelChanges(ctx, fmt.Sprintf(
+		"SELECT %s FROM pixel_history WHERE %s AND pixel_id = %d ORDER BY id DESC LIMIT %d",
+		pixelChangeColumns,
+		onMainBoard,
+		pixelID,
+		limit,
+	))
+}
+
+func (s *Store) ListPixelHistoryByOwner(ctx context.Context, ownerID int64, limit int) ([]storage.PixelChange, error) {
+	return s.loadPixelChanges(ctx, fmt.Sprintf(
+		"SELECT %s FROM pixel_history WHERE %s AND (owner_id = %d OR previous_owner_id = %d) ORDER BY id DESC LIMIT %d",
+		pixelChangeColumns,
+		onMainBoard,
+		ownerID,
+		ownerID,
+		limit,
+	))
+}
+
+func (s *Store) loadPixelChanges(ctx context.Context, query string) ([]storage.PixelChange, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]storage.PixelChange, 0)
+	for rows.Next() {
+		var change storage.PixelChange
+		var owner, previousOwner sql.NullInt64
+		var changed string
+		if err := rows.Scan(&change.ID, &change.PixelID, &change.Status, &change.Color, &change.URL, &owner, &previousOwner, &changed); err != nil {
+			return nil, fmt.Errorf("scan pixel history: %w", err)
+		}
+		if owner.Valid {
+			id := owner.Int64
+			change.OwnerID = &id
+		}
+		if previousOwner.Valid {
+			id := previousOwner.Int64
+			change.PreviousOwnerID = &id
+		}
+		if change.ChangedAt, err = parseUpdatedAt(changed); err != nil {
+			return nil, fmt.Errorf("parse pixel history changed_at: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel history: %w", err)
+	}
+	return changes, nil
+}
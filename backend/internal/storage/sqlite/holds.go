@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+func (s *Store) HoldPixels(ctx context.Context, userID int64, pixelIDs []int, expiresAt, now time.Time) (held []int, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin hold pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	at := quoteLiteral(now.UTC().Format(time.RFC3339Nano))
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_holds WHERE user_id = %d OR julianday(expires_at) <= julianday(%s)", userID, at)); err != nil {
+		return nil, fmt.Errorf("release previous pixel holds: %w", err)
+	}
+	// The primary key on pixel_id leaves pixels held by someone else out.
+	until := quoteLiteral(expiresAt.UTC().Format(time.RFC3339Nano))
+	for _, id := range pixelIDs {
+		query := fmt.Sprintf(
+			"INSERT OR IGNORE INTO pixel_holds(pixel_id, user_id, expires_at) SELECT id, %d, %s FROM pixels WHERE %s AND id = %d AND owner_id IS NULL",
+			userID, until, onMainBoard, id,
+		)
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("hold pixel %d: %w", id, err)
+		}
+	}
+	holds, err := loadPixelHolds(ctx, tx, fmt.Sprintf("SELECT pixel_id, user_id, expires_at FROM pixel_holds WHERE user_id = %d ORDER BY pixel_id", userID))
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit hold pixels: %w", err)
+	}
+	held = make([]int, len(holds))
+	for i, hold := range holds {
+		held[i] = hold.PixelID
+	}
+	return held, nil
+}
+
+func (s *Store) ListPixelHolds(ctx context.Context, userID int64, now time.Time) ([]storage.PixelHold, error) {
+	return loadPixelHolds(ctx, s.db, fmt.Sprintf(
+		"SELECT pixel_id, user_id, expires_at FROM pixel_holds WHERE user_id = %d AND julianday(expires_at) > julianday(%s) ORDER BY pixel_id",
+		userID, quoteLiteral(now.UTC().Format(time.RFC3339Nano)),
+	))
+}
+
+func (s *Store) ReleasePixelHolds(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_holds WHERE user_id = %d", userID)); err != nil {
+		return fmt.Errorf("release pixel holds: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteExpiredPixelHolds(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"DELETE FROM pixel_holds WHERE julianday(expires_at) <= julianday(%s)",
+		quoteLiteral(now.UTC().Format(time.RFC3339Nano)),
+	))
+	if err != nil {
+		return 0, fmt.Errorf("delete expired pixel holds: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// pixelsHeldByOthers returns which of the pixels a user other than userID holds past now.
+func pixelsHeldByOthers(ctx context.Context, tx *sqltrace.Tx, pixelIDs []int, userID int64, now time.Time) (map[int]bool, error) {
+	held := make(map[int]bool)
+	if len(pixelIDs) == 0 {
+		return held, nil
+	}
+	query := fmt.Sprintf(
+		"SELECT DISTINCT pixel_id FROM pixel_holds WHERE pixel_id IN (%s) AND user_id <> %d AND julianday(expires_at) > julianday(%s)",
+		joinIDs(pixelIDs), userID, quoteLiteral(now.UTC().Format(time.RFC3339Nano)),
+	)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("check pixel holds: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan pixel hold: %w", err)
+		}
+		held[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel holds: %w", err)
+	}
+	return held, nil
+}
+
+func loadPixelHolds(ctx context.Context, q queryer, query string) ([]storage.PixelHold, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel holds: %w", err)
+	}
+	defer rows.Close()
+
+	holds := make([]storage.PixelHold, 0)
+	for rows.Next() {
+		var hold storage.PixelHold
+		var expires string
+		if err := rows.Scan(&hold.PixelID, &hold.UserID, &expires); err != nil {
+			return nil, fmt.Errorf("scan pixel hold: %w", err)
+		}
+		if hold.ExpiresAt, err = parseUpdatedAt(expires); err != nil {
+			return nil, fmt.Errorf("parse pixel hold expires_at: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel holds: %w", err)
+	}
+	return holds, nil
+}
@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const inviteCodeColumns = "code, batch_id, created_by, created_at, used_by, used_at"
+
+func (s *Store) CreateInviteCodeBatch(ctx context.Context, batchID string, codes []string) (err error) {
+	if len(codes) == 0 {
+		return errors.New("batch must contain codes")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin create invite code batch: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	created := quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano))
+	for _, code := range codes {
+		query := fmt.Sprintf(
+			"INSERT INTO invite_codes(code, batch_id, created_at) VALUES (%s, %s, %s)",
+			quoteLiteral(code),
+			quoteLiteral(strings.TrimSpace(batchID)),
+			created,
+		)
+		if _, execErr := tx.ExecContext(ctx, query); execErr != nil {
+			err = fmt.Errorf("insert invite code: %w", execErr)
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit invite code batch: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) CreateUserInviteCode(ctx context.Context, userID int64, code string, limit int) (invite storage.InviteCode, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.InviteCode{}, fmt.Errorf("begin create invite code: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var created int
+	if err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM invite_codes WHERE created_by = %d", userID)).Scan(&created); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("count invite codes: %w", err)
+	}
+	if created >= limit {
+		err = storage.ErrInviteLimitReached
+		return storage.InviteCode{}, err
+	}
+	invite = storage.InviteCode{Code: code, CreatedBy: &userID, CreatedAt: time.Now().UTC()}
+	query := fmt.Sprintf(
+		"INSERT INTO invite_codes(code, created_by, created_at) VALUES (%s, %d, %s)",
+		quoteLiteral(code),
+		userID,
+		quoteLiteral(invite.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("insert invite code: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.InviteCode{}, fmt.Errorf("commit invite code: %w", err)
+	}
+	return invite, nil
+}
+
+func (s *Store) ListInviteCodesByCreator(ctx context.Context, userID int64) ([]storage.InviteCode, error) {
+	return s.loadInviteCodes(ctx, fmt.Sprintf("SELECT %s FROM invite_codes WHERE created_by = %d ORDER BY created_at, code", inviteCodeColumns, userID))
+}
+
+func (s *Store) ListInviteCodesByBatch(ctx context.Context, batchID string) ([]storage.InviteCode, error) {
+	return s.loadInviteCodes(ctx, fmt.Sprintf("SELECT %s FROM invite_codes WHERE batch_id = %s ORDER BY created_at, code", inviteCodeColumns, quoteLiteral(batchID)))
+}
+
+func (s *Store) CreateUserWithInvite(ctx context.Context, email, passwordHash, code string) (user User, err error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return User{}, errors.New("email must not be empty")
+	}
+	if passwordHash == "" {
+		return User{}, errors.New("password hash must not be empty")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("begin create user with invite: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO users(email, password_hash, created_at, is_verified) VALUES (%s, %s, CURRENT_TIMESTAMP, 0)",
+		quoteLiteral(email),
+		quoteLiteral(passwordHash),
+	))
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			return User{}, fmt.Errorf("email already exists: %w", err)
+		}
+		return User{}, fmt.Errorf("insert user: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, fmt.Errorf("last insert id: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx, fmt.Sprintf(
+		"UPDATE invite_codes SET used_by = %d, used_at = %s WHERE code = %s AND used_by IS NULL",
+		id,
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+		quoteLiteral(code),
+	))
+	if err != nil {
+		return User{}, fmt.Errorf("use invite code: %w", err)
+	}
+	if affected, affectedErr := res.RowsAffected(); affectedErr != nil {
+		err = fmt.Errorf("use invite code: %w", affectedErr)
+		return User{}, err
+	} else if affected == 0 {
+		err = storage.ErrInviteCodeInvalid
+		return User{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("commit create user with invite: %w", err)
+	}
+	return User{ID: id, Email: email, PasswordHash: passwordHash, CreatedAt: time.Now().UTC()}, nil
+}
+
+func (s *Store) loadInviteCodes(ctx context.Context, query string) ([]storage.InviteCode, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query invite codes: %w", err)
+	}
+	defer rows.Close()
+
+	invites := make([]storage.InviteCode, 0)
+	for rows.Next() {
+		var invite storage.InviteCode
+		var batchID, used sql.NullString
+		var createdBy, usedBy sql.NullInt64
+		var created string
+		if err := rows.Scan(&invite.Code, &batchID, &createdBy, &created, &usedBy, &used); err != nil {
+			return nil, fmt.Errorf("scan invite code: %w", err)
+		}
+		invite.BatchID = batchID.String
+		if createdBy.Valid {
+			invite.CreatedBy = &createdBy.Int64
+		}
+		if usedBy.Valid {
+			invite.UsedBy = &usedBy.Int64
+		}
+		if invite.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse invite code created_at: %w", err)
+		}
+		if invite.UsedAt, err = parseExpiresAt(used); err != nil {
+			return nil, fmt.Errorf("parse invite code used_at: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate invite codes: %w", err)
+	}
+	return invites, nil
+}
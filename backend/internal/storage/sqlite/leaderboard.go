@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) ListTopOwners(ctx context.Context, orderBy string, limit int) ([]storage.OwnerRank, error) {
+	order := "pixels DESC, points DESC, owner_id"
+	switch orderBy {
+	case storage.LeaderboardByPixels:
+	case storage.LeaderboardByPoints:
+		order = "points DESC, pixels DESC, owner_id"
+	default:
+		return nil, fmt.Errorf("unknown leaderboard order %q", orderBy)
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT owner_id, COUNT(1) AS pixels, COALESCE(SUM(paid_points), 0) AS points FROM pixels
+                WHERE board_id = '' AND status = 'taken' AND owner_id IS NOT NULL AND owner_id NOT IN (SELECT user_id FROM leaderboard_opt_outs)
+                GROUP BY owner_id ORDER BY %s LIMIT %d`,
+		order,
+		limit,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("query top owners: %w", err)
+	}
+	defer rows.Close()
+
+	ranks := make([]storage.OwnerRank, 0)
+	for rows.Next() {
+		var rank storage.OwnerRank
+		if err := rows.Scan(&rank.OwnerID, &rank.Pixels, &rank.PointsSpent); err != nil {
+			return nil, fmt.Errorf("scan top owner: %w", err)
+		}
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate top owners: %w", err)
+	}
+	return ranks, nil
+}
+
+func (s *Store) GetLeaderboardOptOut(ctx context.Context, userID int64) (bool, error) {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(1) FROM leaderboard_opt_outs WHERE user_id = %d", userID)
+	if err := s.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return false, fmt.Errorf("load leaderboard opt-out: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Store) SetLeaderboardOptOut(ctx context.Context, userID int64, optOut bool) error {
+	query := fmt.Sprintf("DELETE FROM leaderboard_opt_outs WHERE user_id = %d", userID)
+	if optOut {
+		query = fmt.Sprintf(
+			"INSERT INTO leaderboard_opt_outs(user_id, created_at) VALUES (%d, %s) ON CONFLICT(user_id) DO NOTHING",
+			userID,
+			quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+		)
+	}
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("save leaderboard opt-out: %w", err)
+	}
+	return nil
+}
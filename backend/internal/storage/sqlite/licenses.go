@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pixelLicenseColumns = "id, user_id, artwork_owner, contact, statement, created_at"
+
+func (s *Store) CreatePixelLicense(ctx context.Context, license PixelLicense) (created PixelLicense, err error) {
+	if license.UserID <= 0 {
+		return PixelLicense{}, errors.New("invalid user id")
+	}
+	if len(license.PixelIDs) == 0 {
+		return PixelLicense{}, errors.New("license must cover at least one pixel")
+	}
+	if license.CreatedAt.IsZero() {
+		license.CreatedAt = time.Now()
+	}
+	license.CreatedAt = license.CreatedAt.UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PixelLicense{}, fmt.Errorf("begin create pixel license: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := fmt.Sprintf(
+		"INSERT INTO pixel_licenses(user_id, artwork_owner, contact, statement, created_at) VALUES (%d, %s, %s, %s, %s)",
+		license.UserID,
+		quoteLiteral(license.ArtworkOwner),
+		quoteLiteral(license.Contact),
+		quoteLiteral(license.Statement),
+		quoteLiteral(license.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, execErr := tx.ExecContext(ctx, query)
+	if execErr != nil {
+		err = fmt.Errorf("insert pixel license: %w", execErr)
+		return PixelLicense{}, err
+	}
+	if license.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("pixel license id: %w", err)
+		return PixelLicense{}, err
+	}
+	for _, pixelID := range license.PixelIDs {
+		query := fmt.Sprintf("INSERT OR IGNORE INTO pixel_license_pixels(license_id, pixel_id) VALUES (%d, %d)", license.ID, pixelID)
+		if _, execErr := tx.ExecContext(ctx, query); execErr != nil {
+			err = fmt.Errorf("insert pixel license pixel: %w", execErr)
+			return PixelLicense{}, err
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit pixel license: %w", commitErr)
+		return PixelLicense{}, err
+	}
+	return license, nil
+}
+
+func (s *Store) ListPixelLicensesByUser(ctx context.Context, userID int64) ([]PixelLicense, error) {
+	query := fmt.Sprintf("SELECT %s FROM pixel_licenses WHERE user_id = %d ORDER BY created_at DESC, id DESC", pixelLicenseColumns, userID)
+	return s.queryPixelLicenses(ctx, query)
+}
+
+func (s *Store) ListPixelLicensesByPixel(ctx context.Context, pixelID int) ([]PixelLicense, error) {
+	query := fmt.Sprintf(
+		"SELECT %s FROM pixel_licenses WHERE id IN (SELECT license_id FROM pixel_license_pixels WHERE pixel_id = %d) ORDER BY created_at DESC, id DESC",
+		pixelLicenseColumns,
+		pixelID,
+	)
+	return s.queryPixelLicenses(ctx, query)
+}
+
+func (s *Store) queryPixelLicenses(ctx context.Context, query string) ([]PixelLicense, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel licenses: %w", err)
+	}
+	defer rows.Close()
+
+	licenses := make([]PixelLicense, 0)
+	index := make(map[int64]int)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var license PixelLicense
+		var created string
+		if err := rows.Scan(&license.ID, &license.UserID, &license.ArtworkOwner, &license.Contact, &license.Statement, &created); err != nil {
+			return nil, fmt.Errorf("scan pixel license: %w", err)
+		}
+		if license.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse pixel license created_at: %w", err)
+		}
+		license.PixelIDs = make([]int, 0)
+		index[license.ID] = len(licenses)
+		ids = append(ids, strconv.FormatInt(license.ID, 10))
+		licenses = append(licenses, license)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel licenses: %w", err)
+	}
+	if len(licenses) == 0 {
+		return licenses, nil
+	}
+
+	pixelQuery := fmt.Sprintf("SELECT license_id, pixel_id FROM pixel_license_pixels WHERE license_id IN (%s) ORDER BY pixel_id", strings.Join(ids, ", "))
+	pixelRows, err := s.db.QueryContext(ctx, pixelQuery)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel license pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var licenseID int64
+		var pixelID int
+		if err := pixelRows.Scan(&licenseID, &pixelID); err != nil {
+			return nil, fmt.Errorf("scan pixel license pixel: %w", err)
+		}
+		if i, ok := index[licenseID]; ok {
+			licenses[i].PixelIDs = append(licenses[i].PixelIDs, pixelID)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel license pixels: %w", err)
+	}
+	return licenses, nil
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (s *Store) ConsumeLinkNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	nonce = strings.TrimSpace(nonce)
+	if nonce == "" {
+		return false, errors.New("nonce must not be empty")
+	}
+	// Second precision keeps the stored times comparable as text in DeleteExpiredLinkNonces.
+	query := fmt.Sprintf(
+		"INSERT OR IGNORE INTO used_link_nonces(nonce, expires_at) VALUES (%s, %s)",
+		quoteLiteral(nonce),
+		quoteLiteral(expiresAt.UTC().Format(time.RFC3339)),
+	)
+	result, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("insert used link nonce: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("insert used link nonce: %w", err)
+	}
+	return inserted == 1, nil
+}
+
+func (s *Store) DeleteExpiredLinkNonces(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM used_link_nonces WHERE expires_at < %s",
+		quoteLiteral(before.UTC().Format(time.RFC3339)),
+	)
+	result, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired link nonces: %w", err)
+	}
+	return result.RowsAffected()
+}
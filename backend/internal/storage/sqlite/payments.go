@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const paymentColumns = "id, user_id, bundle_id, currency, amount_minor, points, status, COALESCE(provider_ref, ''), created_at, completed_at"
+
+func (s *Store) CreatePayment(ctx context.Context, payment Payment) error {
+	if strings.TrimSpace(payment.ID) == "" {
+		return errors.New("payment id must not be empty")
+	}
+	if payment.UserID <= 0 {
+		return errors.New("invalid user id")
+	}
+	if payment.AmountMinor <= 0 || payment.Points <= 0 {
+		return errors.New("payment amount and points must be positive")
+	}
+	status := payment.Status
+	if status == "" {
+		status = storage.PaymentStatusPending
+	}
+	created := payment.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO payments(id, user_id, bundle_id, currency, amount_minor, points, status, created_at) VALUES (%s, %d, %s, %s, %d, %d, %s, %s)",
+		quoteLiteral(payment.ID),
+		payment.UserID,
+		quoteLiteral(payment.BundleID),
+		quoteLiteral(strings.ToUpper(payment.Currency)),
+		payment.AmountMinor,
+		payment.Points,
+		quoteLiteral(status),
+		quoteLiteral(created.UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("insert payment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetPayment(ctx context.Context, id string) (Payment, error) {
+	query := fmt.Sprintf("SELECT %s FROM payments WHERE id = %s", paymentColumns, quoteLiteral(strings.TrimSpace(id)))
+	return scanPayment(s.db.QueryRowContext(ctx, query))
+}
+
+func (s *Store) ListPaymentsByUser(ctx context.Context, userID int64) ([]Payment, error) {
+	query := fmt.Sprintf("SELECT %s FROM payments WHERE user_id = %d ORDER BY created_at DESC", paymentColumns, userID)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query payments: %w", err)
+	}
+	defer rows.Close()
+
+	payments := make([]Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate payments: %w", err)
+	}
+	return payments, nil
+}
+
+func (s *Store) CompletePayment(ctx context.Context, id, providerRef string) (payment Payment, user User, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Payment{}, User{}, fmt.Errorf("begin complete payment: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	payment, err = s.finishPayment(ctx, tx, id, providerRef, storage.PaymentStatusCompleted)
+	if err != nil {
+		return Payment{}, User{}, err
+	}
+
+	updateQuery := fmt.Sprintf("UPDATE users SET user_points = user_points + %d WHERE id = %d", payment.Points, payment.UserID)
+	if _, execErr := tx.ExecContext(ctx, updateQuery); execErr != nil {
+		err = fmt.Errorf("credit payment points: %w", execErr)
+		return Payment{}, User{}, err
+	}
+	if err = recordLedgerEntry(ctx, tx, payment.UserID, payment.Points, storage.LedgerPayment, payment.ID, ""); err != nil {
+		return Payment{}, User{}, err
+	}
+
+	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", payment.UserID)
+	user, err = scanUser(tx.QueryRowContext(ctx, userQuery))
+	if err != nil {
+		return Payment{}, User{}, err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit complete payment: %w", commitErr)
+		return Payment{}, User{}, err
+	}
+	return payment, user, nil
+}
+
+func (s *Store) FailPayment(ctx context.Context, id, providerRef string) (payment Payment, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Payment{}, fmt.Errorf("begin fail payment: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	payment, err = s.finishPayment(ctx, tx, id, providerRef, storage.PaymentStatusFailed)
+	if err != nil {
+		return Payment{}, err
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit fail payment: %w", commitErr)
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+// finishPayment moves a pending payment to its final status inside tx.
+func (s *Store) finishPayment(ctx context.Context, tx *sqltrace.Tx, id, providerRef, status string) (Payment, error) {
+	id = strings.TrimSpace(id)
+	payment, err := scanPayment(tx.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM payments WHERE id = %s", paymentColumns, quoteLiteral(id))))
+	if err != nil {
+		return Payment{}, err
+	}
+	if payment.Status != storage.PaymentStatusPending {
+		return Payment{}, storage.ErrPaymentNotPending
+	}
+
+	now := time.Now().UTC()
+	query := fmt.Sprintf(
+		"UPDATE payments SET status = %s, provider_ref = %s, completed_at = %s WHERE id = %s AND status = %s",
+		quoteLiteral(status),
+		quoteLiteral(strings.TrimSpace(providerRef)),
+		quoteLiteral(now.Format(time.RFC3339Nano)),
+		quoteLiteral(id),
+		quoteLiteral(storage.PaymentStatusPending),
+	)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return Payment{}, fmt.Errorf("update payment status: %w", err)
+	}
+	if affected, affErr := res.RowsAffected(); affErr == nil && affected == 0 {
+		return Payment{}, storage.ErrPaymentNotPending
+	}
+
+	payment.Status = status
+	payment.ProviderRef = strings.TrimSpace(providerRef)
+	payment.CompletedAt = &now
+	return payment, nil
+}
+
+func scanPayment(row rowScanner) (Payment, error) {
+	var payment Payment
+	var created string
+	var completed sql.NullString
+	if err := row.Scan(&payment.ID, &payment.UserID, &payment.BundleID, &payment.Currency, &payment.AmountMinor, &payment.Points, &payment.Status, &payment.ProviderRef, &created, &completed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Payment{}, sql.ErrNoRows
+		}
+		return Payment{}, fmt.Errorf("scan payment: %w", err)
+	}
+	parsed, err := parseUpdatedAt(created)
+	if err != nil {
+		return Payment{}, fmt.Errorf("parse payment created_at: %w", err)
+	}
+	payment.CreatedAt = parsed
+	if completed.Valid && completed.String != "" {
+		parsedCompleted, err := parseUpdatedAt(completed.String)
+		if err != nil {
+			return Payment{}, fmt.Errorf("parse payment completed_at: %w", err)
+		}
+		payment.CompletedAt = &parsedCompleted
+	}
+	return payment, nil
+}
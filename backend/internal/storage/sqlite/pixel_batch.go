@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// pixelBatch collects the pixel writes of one purchase, so they reach the database in a fixed
+// number of statements however many pixels it covers. Only main grid writes are indexed for
+// search and release pixel holds.
+type pixelBatch struct {
+	board       string
+	transitions []pixelTransition
+	writes      map[int]*pixelWrite
+	order       []int
+	acquired    []int
+	charged     int64
+}
+
+// pixelWrite is the last state of a pixel in the batch, with the columns that depend on the
+// states before it.
+type pixelWrite struct {
+	pixel       Pixel
+	resetExpiry bool
+	setPaid     bool
+	paid        sql.NullInt64
+}
+
+func newPixelBatch(board string) *pixelBatch {
+	return &pixelBatch{board: board, writes: make(map[int]*pixelWrite)}
+}
+
+// add queues the write of after over before, charged points for it. A pixel written twice keeps
+// the last state, as if both writes had been applied in turn.
+func (b *pixelBatch) add(before, after Pixel, charged int64, purchased bool) {
+	b.transitions = append(b.transitions, pixelTransition{before: before, after: after})
+	b.charged += charged
+	if before.OwnerID == nil && after.OwnerID != nil {
+		b.acquired = append(b.acquired, after.ID)
+	}
+	write, ok := b.writes[after.ID]
+	if !ok {
+		write = &pixelWrite{}
+		b.writes[after.ID] = write
+		b.order = append(b.order, after.ID)
+	}
+	write.pixel = after
+	write.resetExpiry = write.resetExpiry || resetsExpiryWarning(before, after)
+	if paid, changed := paidPoints(before, after, charged, purchased); changed {
+		write.setPaid, write.paid = true, paid
+	}
+}
+
+// write applies the batch in tx and charges its points to userID at priceVersion.
+func (b *pixelBatch) write(ctx context.Context, tx *sqltrace.Tx, userID int64, priceVersion string) error {
+	if len(b.order) == 0 {
+		return nil
+	}
+	values := make([]string, len(b.order))
+	final := make([]Pixel, len(b.order))
+	for i, id := range b.order {
+		write := b.writes[id]
+		pixel := write.pixel
+		final[i] = pixel
+		values[i] = fmt.Sprintf(
+			"(%d, %s, %s, %s, %s, %s, %s, %s, %s, %d, %d, %s)",
+			pixel.ID,
+			quoteLiteral(pixel.Status),
+			quoteLiteral(pixel.Color),
+			quoteLiteral(pixel.URL),
+			quoteLiteral(pixel.Title),
+			quoteLiteral(pixel.Description),
+			ownerLiteral(pixel.OwnerID),
+			quoteLiteral(pixel.UpdatedAt.Format(time.RFC3339Nano)),
+			expiresLiteral(pixel.ExpiresAt),
+			boolFlag(write.resetExpiry),
+			boolFlag(write.setPaid),
+			paidLiteral(write.paid),
+		)
+	}
+	query := "WITH v(id, status, color, url, title, description, owner_id, updated_at, expires_at, reset_expiry, set_paid, paid_points) AS (VALUES " + strings.Join(values, ", ") + ") " +
+		"UPDATE pixels SET status = v.status, color = v.color, url = v.url, title = v.title, description = v.description, owner_id = v.owner_id, updated_at = v.updated_at, expires_at = v.expires_at, " +
+		"expiry_notified_at = CASE WHEN v.reset_expiry = 1 THEN NULL ELSE pixels.expiry_notified_at END, " +
+		"paid_points = CASE WHEN v.set_paid = 1 THEN v.paid_points ELSE pixels.paid_points END " +
+		"FROM v WHERE pixels.id = v.id AND pixels." + onBoard(b.board)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("update pixels for user: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected != int64(len(b.order)) {
+		return sql.ErrNoRows
+	}
+	if err := recordPixelChanges(ctx, tx, b.board, b.transitions); err != nil {
+		return err
+	}
+	if b.board == storage.MainBoard {
+		if err := indexPixelsSearch(ctx, tx, final); err != nil {
+			return err
+		}
+		if len(b.acquired) > 0 {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM pixel_holds WHERE pixel_id IN (%s)", joinIDs(b.acquired))); err != nil {
+				return fmt.Errorf("release pixel holds: %w", err)
+			}
+		}
+	}
+	if b.charged > 0 {
+		chargeQuery := fmt.Sprintf("UPDATE users SET user_points = user_points - %d WHERE id = %d AND user_points >= %d", b.charged, userID, b.charged)
+		res, err := tx.ExecContext(ctx, chargeQuery)
+		if err != nil {
+			return fmt.Errorf("deduct user points: %w", err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("deduct user points rows affected: %w", err)
+		}
+		if affected == 0 {
+			return storage.ErrInsufficientPoints
+		}
+		if err := recordLedgerEntry(ctx, tx, userID, -b.charged, storage.LedgerPurchase, priceVersion, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func boolFlag(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
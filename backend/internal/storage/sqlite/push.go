@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const pushDeviceColumns = "token, user_id, platform, created_at, last_seen_at"
+
+func (s *Store) RegisterPushDevice(ctx context.Context, device storage.PushDevice) (storage.PushDevice, error) {
+	now := quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano))
+	query := fmt.Sprintf(
+		"INSERT INTO push_devices(token, user_id, platform, created_at, last_seen_at) VALUES (%s, %d, %s, %s, %s) ON CONFLICT(token) DO UPDATE SET user_id = excluded.user_id, platform = excluded.platform, last_seen_at = excluded.last_seen_at",
+		quoteLiteral(device.Token),
+		device.UserID,
+		quoteLiteral(device.Platform),
+		now,
+		now,
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return storage.PushDevice{}, fmt.Errorf("register push device: %w", err)
+	}
+	devices, err := s.loadPushDevices(ctx, fmt.Sprintf("SELECT %s FROM push_devices WHERE token = %s", pushDeviceColumns, quoteLiteral(device.Token)))
+	if err != nil {
+		return storage.PushDevice{}, err
+	}
+	if len(devices) == 0 {
+		return storage.PushDevice{}, errors.New("push device vanished after register")
+	}
+	return devices[0], nil
+}
+
+func (s *Store) ListPushDevices(ctx context.Context, userID int64) ([]storage.PushDevice, error) {
+	return s.loadPushDevices(ctx, fmt.Sprintf("SELECT %s FROM push_devices WHERE user_id = %d ORDER BY last_seen_at DESC", pushDeviceColumns, userID))
+}
+
+func (s *Store) DeletePushDevice(ctx context.Context, userID int64, token string) error {
+	query := fmt.Sprintf("DELETE FROM push_devices WHERE user_id = %d AND token = %s", userID, quoteLiteral(token))
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete push device: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) loadPushDevices(ctx context.Context, query string) ([]storage.PushDevice, error) {
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query push devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := make([]storage.PushDevice, 0)
+	for rows.Next() {
+		var device storage.PushDevice
+		var created, seen string
+		if err := rows.Scan(&device.Token, &device.UserID, &device.Platform, &created, &seen); err != nil {
+			return nil, fmt.Errorf("scan push device: %w", err)
+		}
+		if device.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse push device created_at: %w", err)
+		}
+		if device.LastSeenAt, err = parseUpdatedAt(seen); err != nil {
+			return nil, fmt.Errorf("parse push device last_seen_at: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate push devices: %w", err)
+	}
+	return devices, nil
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// SetPixelQuota sets how many pixels one account may own; 0 means no limit.
+func (s *Store) SetPixelQuota(maxPixels int) {
+	if s != nil {
+		s.pixelQuota = max(maxPixels, 0)
+	}
+}
+
+func (s *Store) GetUserPixelQuota(ctx context.Context, userID int64) (int, error) {
+	var maxPixels int
+	query := fmt.Sprintf("SELECT max_pixels FROM user_pixel_quotas WHERE user_id = %d", userID)
+	if err := s.db.QueryRowContext(ctx, query).Scan(&maxPixels); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, sql.ErrNoRows
+		}
+		return 0, fmt.Errorf("load user pixel quota: %w", err)
+	}
+	return maxPixels, nil
+}
+
+func (s *Store) SetUserPixelQuota(ctx context.Context, userID int64, maxPixels int) error {
+	if userID <= 0 {
+		return errors.New("invalid user id")
+	}
+	if maxPixels < 0 {
+		return errors.New("pixel quota must not be negative")
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO user_pixel_quotas(user_id, max_pixels, updated_at) VALUES (%d, %d, %s) ON CONFLICT(user_id) DO UPDATE SET max_pixels = excluded.max_pixels, updated_at = excluded.updated_at",
+		userID,
+		maxPixels,
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("save user pixel quota: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteUserPixelQuota(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM user_pixel_quotas WHERE user_id = %d", userID)); err != nil {
+		return fmt.Errorf("delete user pixel quota: %w", err)
+	}
+	return nil
+}
+
+// pixelQuota counts how many more pixels an owner may acquire within one transaction. A nil
+// quota is unlimited.
+type pixelQuota struct {
+	remaining int
+}
+
+// take reports whether the owner may acquire one more pixel and counts it.
+func (q *pixelQuota) take() bool {
+	if q == nil {
+		return true
+	}
+	if q.remaining <= 0 {
+		return false
+	}
+	q.remaining--
+	return true
+}
+
+// release gives back the place of a pixel the owner frees.
+func (q *pixelQuota) release() {
+	if q != nil {
+		q.remaining++
+	}
+}
+
+// loadPixelQuota returns the quota left to ownerID: its override, or defaultMax without one,
+// less the pixels it owns. It returns nil when the owner has no limit.
+func loadPixelQuota(ctx context.Context, tx *sqltrace.Tx, ownerID int64, defaultMax int) (*pixelQuota, error) {
+	limit := defaultMax
+	query := fmt.Sprintf("SELECT max_pixels FROM user_pixel_quotas WHERE user_id = %d", ownerID)
+	if err := tx.QueryRowContext(ctx, query).Scan(&limit); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("load user pixel quota: %w", err)
+	}
+	if limit <= 0 {
+		return nil, nil
+	}
+	var owned int
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM pixels WHERE owner_id = %d", ownerID)).Scan(&owned); err != nil {
+		return nil, fmt.Errorf("count owned pixels: %w", err)
+	}
+	return &pixelQuota{remaining: limit - owned}, nil
+}
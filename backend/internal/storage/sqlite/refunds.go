@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const pixelRefundColumns = "id, user_id, admin_id, pixel_ids, points, reason, created_at"
+
+func (s *Store) RefundPixels(ctx context.Context, refund storage.PixelRefund, points *int64) (_ storage.PixelRefund, release storage.PixelRelease, err error) {
+	if len(refund.PixelIDs) == 0 {
+		return storage.PixelRefund{}, storage.PixelRelease{}, errors.New("refund needs at least one pixel")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("begin refund pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	pixels, paid, err := releaseOwnedPixels(ctx, tx, refund.UserID, refund.PixelIDs)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, err
+	}
+	if len(pixels) == 0 {
+		return storage.PixelRefund{}, storage.PixelRelease{}, tx.Rollback()
+	}
+	release.Pixels = pixels
+	refund.PixelIDs = make([]int, len(pixels))
+	for i, pixel := range pixels {
+		refund.PixelIDs[i] = pixel.ID
+		if paid[i].Valid {
+			release.RefundedPoints += paid[i].Int64
+		}
+	}
+	if points != nil {
+		release.RefundedPoints = *points
+	}
+	refund.Points = release.RefundedPoints
+	refund.CreatedAt = time.Now().UTC()
+	query := fmt.Sprintf(
+		"INSERT INTO pixel_refunds(user_id, admin_id, pixel_ids, points, reason, created_at) VALUES (%d, %d, %s, %d, %s, %s)",
+		refund.UserID,
+		refund.AdminID,
+		quoteLiteral(joinPixelIDs(refund.PixelIDs)),
+		refund.Points,
+		quoteLiteral(refund.Reason),
+		quoteLiteral(refund.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("insert pixel refund: %w", err)
+	}
+	if refund.ID, err = res.LastInsertId(); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("pixel refund id: %w", err)
+	}
+	// The credit goes through the ledger with the refund's reason, so the balance can be traced back to it.
+	if refund.Points != 0 {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE users SET user_points = user_points + %d WHERE id = %d", refund.Points, refund.UserID)); err != nil {
+			return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("credit refunded points: %w", err)
+		}
+		if err = recordLedgerEntry(ctx, tx, refund.UserID, refund.Points, storage.LedgerAdminRefund, strconv.FormatInt(refund.ID, 10), refund.Reason); err != nil {
+			return storage.PixelRefund{}, storage.PixelRelease{}, err
+		}
+	}
+
+	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", refund.UserID)
+	if release.User, err = scanUser(tx.QueryRowContext(ctx, userQuery)); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelRefund{}, storage.PixelRelease{}, fmt.Errorf("commit refund pixels: %w", err)
+	}
+	return refund, release, nil
+}
+
+func (s *Store) ListPixelRefunds(ctx context.Context, userID int64) ([]storage.PixelRefund, error) {
+	where := ""
+	if userID != 0 {
+		where = fmt.Sprintf(" WHERE user_id = %d", userID)
+	}
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM pixel_refunds%s ORDER BY id DESC", pixelRefundColumns, where))
+	if err != nil {
+		return nil, fmt.Errorf("query pixel refunds: %w", err)
+	}
+	defer rows.Close()
+
+	refunds := make([]storage.PixelRefund, 0)
+	for rows.Next() {
+		var refund storage.PixelRefund
+		var ids, created string
+		if err := rows.Scan(&refund.ID, &refund.UserID, &refund.AdminID, &ids, &refund.Points, &refund.Reason, &created); err != nil {
+			return nil, fmt.Errorf("scan pixel refund: %w", err)
+		}
+		if refund.PixelIDs, err = splitPixelIDs(ids); err != nil {
+			return nil, fmt.Errorf("parse pixel refund %d pixel_ids: %w", refund.ID, err)
+		}
+		if refund.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse pixel refund created_at: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+func joinPixelIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitPixelIDs(value string) ([]int, error) {
+	ids := make([]int, 0)
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// paidPointsUpdate records what the new owner paid when the pixel changes hands. Pixels that
+// are freed or assigned without a purchase get no price.
+func paidPointsUpdate(before, after Pixel, charged int64, purchased bool) string {
+	paid, changed := paidPoints(before, after, charged, purchased)
+	if !changed {
+		return ""
+	}
+	return ", paid_points = " + paidLiteral(paid)
+}
+
+// paidPoints returns the price paidPointsUpdate records, and false when the column is kept.
+func paidPoints(before, after Pixel, charged int64, purchased bool) (sql.NullInt64, bool) {
+	switch {
+	case sameOwner(before.OwnerID, after.OwnerID):
+		return sql.NullInt64{}, false
+	case after.OwnerID == nil || !purchased:
+		return sql.NullInt64{}, true
+	}
+	return sql.NullInt64{Int64: charged, Valid: true}, true
+}
+
+func paidLiteral(paid sql.NullInt64) string {
+	if !paid.Valid {
+		return "NULL"
+	}
+	return strconv.FormatInt(paid.Int64, 10)
+}
+
+func (s *Store) ReleasePixelsForUser(ctx context.Context, userID int64, pixelIDs []int, refundFraction float64) (release storage.PixelRelease, err error) {
+	if refundFraction < 0 || refundFraction > 1 {
+		return storage.PixelRelease{}, errors.New("refund fraction must be between 0 and 1")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.PixelRelease{}, fmt.Errorf("begin release pixels for user: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	pixels, paid, err := releaseOwnedPixels(ctx, tx, userID, pixelIDs)
+	if err != nil {
+		return storage.PixelRelease{}, err
+	}
+	release.Pixels = pixels
+	for i, pixel := range pixels {
+		if pixel.ExpiresAt == nil && paid[i].Valid {
+			release.RefundedPoints += int64(math.Floor(float64(paid[i].Int64) * refundFraction))
+		}
+	}
+	if release.RefundedPoints > 0 {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE users SET user_points = user_points + %d WHERE id = %d", release.RefundedPoints, userID)); err != nil {
+			return storage.PixelRelease{}, fmt.Errorf("refund user points: %w", err)
+		}
+		if err = recordLedgerEntry(ctx, tx, userID, release.RefundedPoints, storage.LedgerRelease, joinPixelIDs(pixelIDsOf(pixels)), ""); err != nil {
+			return storage.PixelRelease{}, err
+		}
+	}
+
+	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", userID)
+	if release.User, err = scanUser(tx.QueryRowContext(ctx, userQuery)); err != nil {
+		return storage.PixelRelease{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		return storage.PixelRelease{}, fmt.Errorf("commit release pixels for user: %w", err)
+	}
+	return release, nil
+}
+
+// releaseOwnedPixels frees the given pixels of the user, or all of them when pixelIDs is empty,
+// and returns them as they were together with the points recorded as paid for each.
+func releaseOwnedPixels(ctx context.Context, tx *sqltrace.Tx, userID int64, pixelIDs []int) (pixels []Pixel, paid []sql.NullInt64, err error) {
+	where := fmt.Sprintf("owner_id = %d", userID)
+	if len(pixelIDs) > 0 {
+		where += " AND " + pixelIDsIn(pixelIDs)
+	}
+	return freePixelsWhere(ctx, tx, where)
+}
+
+// pixelIDsIn returns an "id IN (...)" condition for pixelIDs, which must not be empty.
+func pixelIDsIn(pixelIDs []int) string {
+	return "id IN (" + joinIDs(pixelIDs) + ")"
+}
+
+// pixelIDsOf returns the ids of pixels, in order.
+func pixelIDsOf(pixels []Pixel) []int {
+	ids := make([]int, len(pixels))
+	for i, pixel := range pixels {
+		ids[i] = pixel.ID
+	}
+	return ids
+}
+
+// joinIDs lists ids for an IN condition.
+func joinIDs(ids []int) string {
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = strconv.Itoa(id)
+	}
+	return strings.Join(list, ", ")
+}
+
+// freePixelsWhere frees the pixels matching where and records the change in their history. It
+// returns them as they were together with the points recorded as paid for each.
+func freePixelsWhere(ctx context.Context, tx *sqltrace.Tx, where string) (pixels []Pixel, paid []sql.NullInt64, err error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, status, color, url, owner_id, expires_at, paid_points FROM pixels WHERE "+onMainBoard+" AND ("+where+") ORDER BY id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("query pixels to free: %w", err)
+	}
+	for rows.Next() {
+		var pixel Pixel
+		var color, url, expires sql.NullString
+		var owner, points sql.NullInt64
+		if err = rows.Scan(&pixel.ID, &pixel.Status, &color, &url, &owner, &expires, &points); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("scan pixel to free: %w", err)
+		}
+		pixel.Color, pixel.URL = color.String, url.String
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		if pixel.ExpiresAt, err = parseExpiresAt(expires); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("parse pixel %d expires_at: %w", pixel.ID, err)
+		}
+		pixels = append(pixels, pixel)
+		paid = append(paid, points)
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return nil, nil, fmt.Errorf("iterate pixels to free: %w", err)
+	}
+	rows.Close()
+
+	if err = freePixels(ctx, tx, pixels, time.Now().UTC()); err != nil {
+		return nil, nil, err
+	}
+	return pixels, paid, nil
+}
+
+// freePixels frees pixels, as loaded, at at and records the change in their history, in a fixed
+// number of statements however many pixels there are.
+func freePixels(ctx context.Context, tx *sqltrace.Tx, pixels []Pixel, at time.Time) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	ids := make([]int, len(pixels))
+	transitions := make([]pixelTransition, len(pixels))
+	freed := make([]Pixel, len(pixels))
+	for i, pixel := range pixels {
+		ids[i] = pixel.ID
+		freed[i] = Pixel{ID: pixel.ID, Status: "free", UpdatedAt: at}
+		transitions[i] = pixelTransition{before: pixel, after: freed[i]}
+	}
+	query := fmt.Sprintf(
+		"UPDATE pixels SET status = 'free', color = '', url = '', title = '', description = '', owner_id = NULL, expires_at = NULL, expiry_notified_at = NULL, paid_points = NULL, updated_at = %s WHERE %s AND %s",
+		quoteLiteral(at.Format(time.RFC3339Nano)),
+		onMainBoard,
+		pixelIDsIn(ids),
+	)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("release pixels: %w", err)
+	}
+	if err := recordPixelChanges(ctx, tx, storage.MainBoard, transitions); err != nil {
+		return err
+	}
+	return indexPixelsSearch(ctx, tx, freed)
+}
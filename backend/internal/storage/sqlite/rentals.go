@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+func expiresLiteral(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return "NULL"
+	}
+	return quoteLiteral(expiresAt.UTC().Format(time.RFC3339Nano))
+}
+
+func parseExpiresAt(value sql.NullString) (*time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return nil, nil
+	}
+	parsed, err := parseUpdatedAt(value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// expiryWarningReset clears the expiry warning mark when the pixel changes hands or its rental
+// changes, so the next rental is warned about again.
+func expiryWarningReset(before, after Pixel) string {
+	if !resetsExpiryWarning(before, after) {
+		return ""
+	}
+	return ", expiry_notified_at = NULL"
+}
+
+func resetsExpiryWarning(before, after Pixel) bool {
+	return !sameOwner(before.OwnerID, after.OwnerID) || !sameExpiry(before.ExpiresAt, after.ExpiresAt)
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+func (s *Store) ClaimExpiringPixels(ctx context.Context, before time.Time) (claimed []Pixel, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim expiring pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	claimed, err = loadRentedPixels(ctx, tx, fmt.Sprintf(
+		"SELECT id, status, color, url, owner_id, expires_at FROM pixels WHERE %s AND owner_id IS NOT NULL AND expires_at IS NOT NULL AND expiry_notified_at IS NULL AND julianday(expires_at) <= julianday(%s) ORDER BY expires_at, id",
+		onMainBoard,
+		quoteLiteral(before.UTC().Format(time.RFC3339Nano)),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		query := fmt.Sprintf(
+			"UPDATE pixels SET expiry_notified_at = %s WHERE %s AND id IN (%s)",
+			quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+			onMainBoard,
+			pixelIDList(claimed),
+		)
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("mark expiring pixels: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claim expiring pixels: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *Store) ReleaseExpiredPixels(ctx context.Context, now time.Time) (released []Pixel, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin release expired pixels: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	at := now.UTC()
+	released, err = loadRentedPixels(ctx, tx, fmt.Sprintf(
+		"SELECT id, status, color, url, owner_id, expires_at FROM pixels WHERE %s AND expires_at IS NOT NULL AND julianday(expires_at) <= julianday(%s) ORDER BY id",
+		onMainBoard,
+		quoteLiteral(at.Format(time.RFC3339Nano)),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if err = freePixels(ctx, tx, released, at); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit release expired pixels: %w", err)
+	}
+	return released, nil
+}
+
+func loadRentedPixels(ctx context.Context, tx *sqltrace.Tx, query string) ([]Pixel, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query rented pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var pixel Pixel
+		var color, url, expires sql.NullString
+		var owner sql.NullInt64
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &color, &url, &owner, &expires); err != nil {
+			return nil, fmt.Errorf("scan rented pixel: %w", err)
+		}
+		pixel.Color, pixel.URL = color.String, url.String
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		if pixel.ExpiresAt, err = parseExpiresAt(expires); err != nil {
+			return nil, fmt.Errorf("parse pixel %d expires_at: %w", pixel.ID, err)
+		}
+		pixels = append(pixels, pixel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rented pixels: %w", err)
+	}
+	return pixels, nil
+}
+
+func pixelIDList(pixels []Pixel) string {
+	ids := make([]string, len(pixels))
+	for i, pixel := range pixels {
+		ids[i] = strconv.Itoa(pixel.ID)
+	}
+	return strings.Join(ids, ", ")
+}
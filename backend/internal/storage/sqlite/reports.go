@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const engagementReportColumns = "user_id, token, created_at, last_sent_at"
+
+func (s *Store) GetEngagementReportSubscription(ctx context.Context, userID int64) (storage.EngagementReportSubscription, error) {
+	subscriptions, err := s.loadEngagementReports(ctx, s.db, fmt.Sprintf("SELECT %s FROM engagement_reports WHERE user_id = %d", engagementReportColumns, userID))
+	if err != nil {
+		return storage.EngagementReportSubscription{}, err
+	}
+	if len(subscriptions) == 0 {
+		return storage.EngagementReportSubscription{}, sql.ErrNoRows
+	}
+	return subscriptions[0], nil
+}
+
+func (s *Store) SubscribeEngagementReport(ctx context.Context, userID int64, token string) (storage.EngagementReportSubscription, error) {
+	query := fmt.Sprintf(
+		"INSERT INTO engagement_reports(user_id, token, created_at) VALUES (%d, %s, %s) ON CONFLICT(user_id) DO NOTHING",
+		userID,
+		quoteLiteral(token),
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return storage.EngagementReportSubscription{}, fmt.Errorf("subscribe engagement report: %w", err)
+	}
+	subscription, err := s.GetEngagementReportSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.EngagementReportSubscription{}, errors.New("engagement report subscription vanished after insert")
+	}
+	return subscription, err
+}
+
+func (s *Store) UnsubscribeEngagementReport(ctx context.Context, userID int64) error {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM engagement_reports WHERE user_id = %d", userID)); err != nil {
+		return fmt.Errorf("unsubscribe engagement report: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) UnsubscribeEngagementReportByToken(ctx context.Context, token string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM engagement_reports WHERE token = %s", quoteLiteral(token)))
+	if err != nil {
+		return false, fmt.Errorf("unsubscribe engagement report: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("unsubscribe engagement report rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (s *Store) ClaimEngagementReports(ctx context.Context, before, now time.Time) (claimed []storage.EngagementReportSubscription, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim engagement reports: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	claimed, err = s.loadEngagementReports(ctx, tx, fmt.Sprintf(
+		"SELECT %s FROM engagement_reports WHERE last_sent_at IS NULL OR julianday(last_sent_at) < julianday(%s) ORDER BY user_id",
+		engagementReportColumns,
+		quoteLiteral(before.UTC().Format(time.RFC3339Nano)),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) > 0 {
+		ids := make([]string, len(claimed))
+		for i, subscription := range claimed {
+			ids[i] = fmt.Sprint(subscription.UserID)
+		}
+		query := fmt.Sprintf(
+			"UPDATE engagement_reports SET last_sent_at = %s WHERE user_id IN (%s)",
+			quoteLiteral(now.UTC().Format(time.RFC3339Nano)),
+			strings.Join(ids, ", "),
+		)
+		if _, err = tx.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("mark engagement reports: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claim engagement reports: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *Store) ReleaseEngagementReport(ctx context.Context, userID int64, lastSentAt *time.Time) error {
+	previous := "NULL"
+	if lastSentAt != nil {
+		previous = quoteLiteral(lastSentAt.UTC().Format(time.RFC3339Nano))
+	}
+	query := fmt.Sprintf("UPDATE engagement_reports SET last_sent_at = %s WHERE user_id = %d", previous, userID)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("release engagement report: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) loadEngagementReports(ctx context.Context, db queryer, query string) ([]storage.EngagementReportSubscription, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query engagement reports: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]storage.EngagementReportSubscription, 0)
+	for rows.Next() {
+		var subscription storage.EngagementReportSubscription
+		var created string
+		var sent sql.NullString
+		if err := rows.Scan(&subscription.UserID, &subscription.Token, &created, &sent); err != nil {
+			return nil, fmt.Errorf("scan engagement report: %w", err)
+		}
+		if subscription.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse engagement report created_at: %w", err)
+		}
+		if subscription.LastSentAt, err = parseExpiresAt(sent); err != nil {
+			return nil, fmt.Errorf("parse engagement report last_sent_at: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate engagement reports: %w", err)
+	}
+	return subscriptions, nil
+}
@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// pixelReviewQuery selects the queued pixels still owned by the user who queued them.
+const pixelReviewQuery = "SELECT r.pixel_id, r.user_id, p.color, p.url, COALESCE(p.title, ''), COALESCE(p.description, ''), r.queued_at FROM pixel_reviews r JOIN pixels p ON p.board_id = '' AND p.id = r.pixel_id AND p.owner_id = r.user_id"
+
+func (s *Store) QueuePixelReviews(ctx context.Context, userID int64, pixelIDs []int, at time.Time) error {
+	if len(pixelIDs) == 0 {
+		return nil
+	}
+	values := make([]string, len(pixelIDs))
+	queuedAt := quoteLiteral(at.UTC().Format(time.RFC3339Nano))
+	for i, id := range pixelIDs {
+		values[i] = fmt.Sprintf("(%d, %d, %s)", id, userID, queuedAt)
+	}
+	query := "INSERT INTO pixel_reviews(pixel_id, user_id, queued_at) VALUES " + strings.Join(values, ", ") +
+		" ON CONFLICT(pixel_id) DO UPDATE SET user_id = excluded.user_id, queued_at = excluded.queued_at"
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("queue pixel reviews: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListPixelReviews(ctx context.Context) ([]storage.PixelReview, error) {
+	return s.loadPixelReviews(ctx, s.db, pixelReviewQuery+" ORDER BY r.queued_at, r.pixel_id")
+}
+
+func (s *Store) ListPendingReviewPixelIDs(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT r.pixel_id FROM pixel_reviews r JOIN pixels p ON p.board_id = '' AND p.id = r.pixel_id AND p.owner_id = r.user_id ORDER BY r.pixel_id")
+	if err != nil {
+		return nil, fmt.Errorf("query pending review pixels: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan pending review pixel: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending review pixels: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *Store) ResolvePixelReviews(ctx context.Context, pixelIDs []int, decision string) (reviews []storage.PixelReview, err error) {
+	if decision != storage.PixelReviewApproved && decision != storage.PixelReviewRejected {
+		return nil, fmt.Errorf("invalid review decision %q", decision)
+	}
+	if len(pixelIDs) == 0 {
+		return nil, errors.New("resolve needs at least one pixel")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin resolve pixel reviews: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	ids := make([]string, len(pixelIDs))
+	for i, id := range pixelIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	in := "(" + strings.Join(ids, ", ") + ")"
+	if reviews, err = s.loadPixelReviews(ctx, tx, pixelReviewQuery+" WHERE r.pixel_id IN "+in+" ORDER BY r.pixel_id"); err != nil {
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM pixel_reviews WHERE pixel_id IN "+in); err != nil {
+		return nil, fmt.Errorf("delete pixel reviews: %w", err)
+	}
+
+	if decision == storage.PixelReviewRejected {
+		byUser := make(map[int64][]int)
+		for _, review := range reviews {
+			byUser[review.UserID] = append(byUser[review.UserID], review.PixelID)
+		}
+		refunded := make(map[int]int64)
+		for userID, owned := range byUser {
+			pixels, paid, releaseErr := releaseOwnedPixels(ctx, tx, userID, owned)
+			if releaseErr != nil {
+				err = releaseErr
+				return nil, err
+			}
+			var points int64
+			for i, pixel := range pixels {
+				if paid[i].Valid {
+					refunded[pixel.ID] = paid[i].Int64
+					points += paid[i].Int64
+				}
+			}
+			if points > 0 {
+				if _, err = tx.ExecContext(ctx, fmt.Sprintf("UPDATE users SET user_points = user_points + %d WHERE id = %d", points, userID)); err != nil {
+					return nil, fmt.Errorf("credit rejected pixel points: %w", err)
+				}
+				if err = recordLedgerEntry(ctx, tx, userID, points, storage.LedgerReviewRefund, joinPixelIDs(pixelIDsOf(pixels)), ""); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for i := range reviews {
+			reviews[i].RefundedPoints = refunded[reviews[i].PixelID]
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit resolve pixel reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+func (s *Store) loadPixelReviews(ctx context.Context, q queryer, query string) ([]storage.PixelReview, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel reviews: %w", err)
+	}
+	defer rows.Close()
+
+	reviews := make([]storage.PixelReview, 0)
+	for rows.Next() {
+		var review storage.PixelReview
+		var queued string
+		if err := rows.Scan(&review.PixelID, &review.UserID, &review.Color, &review.URL, &review.Title, &review.Description, &queued); err != nil {
+			return nil, fmt.Errorf("scan pixel review: %w", err)
+		}
+		if review.QueuedAt, err = parseUpdatedAt(queued); err != nil {
+			return nil, fmt.Errorf("parse pixel review queued_at: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel reviews: %w", err)
+	}
+	return reviews, nil
+}
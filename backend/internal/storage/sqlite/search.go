@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// pixelSearchable reports whether pixel belongs in the search index: a taken pixel with a link,
+// title or description.
+func pixelSearchable(pixel Pixel) bool {
+	return pixel.Status == "taken" && (pixel.URL != "" || pixel.Title != "" || pixel.Description != "")
+}
+
+// indexPixelSearch replaces the search entry of pixel with its current texts.
+func indexPixelSearch(ctx context.Context, tx *sqltrace.Tx, pixel Pixel) error {
+	return indexPixelsSearch(ctx, tx, []Pixel{pixel})
+}
+
+// indexPixelsSearch is indexPixelSearch for many pixels in at most two statements.
+func indexPixelsSearch(ctx context.Context, tx *sqltrace.Tx, pixels []Pixel) error {
+	if len(pixels) == 0 {
+		return nil
+	}
+	ids := make([]int, len(pixels))
+	values := make([]string, 0, len(pixels))
+	for i, pixel := range pixels {
+		ids[i] = pixel.ID
+		if pixelSearchable(pixel) {
+			values = append(values, fmt.Sprintf("(%d, %s, %s, %s)", pixel.ID, quoteLiteral(pixel.Title), quoteLiteral(pixel.Description), quoteLiteral(pixel.URL)))
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM pixel_search WHERE rowid IN ("+joinIDs(ids)+")"); err != nil {
+		return fmt.Errorf("remove pixels from search: %w", err)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO pixel_search(rowid, title, description, url) VALUES "+strings.Join(values, ", ")); err != nil {
+		return fmt.Errorf("index pixels for search: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) SearchPixels(ctx context.Context, terms []string, limit int) ([]Pixel, error) {
+	if len(terms) == 0 {
+		return []Pixel{}, nil
+	}
+	// Every term has to match, as a prefix of a word of the title, description or link.
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	query := fmt.Sprintf(
+		"SELECT p.id, p.status, COALESCE(p.color, ''), COALESCE(p.url, ''), COALESCE(p.title, ''), COALESCE(p.description, '') FROM pixel_search JOIN pixels p ON p.board_id = '' AND p.id = pixel_search.rowid WHERE pixel_search MATCH %s AND p.status = 'taken' ORDER BY rank, p.id LIMIT %d",
+		quoteLiteral(strings.Join(match, " ")),
+		limit,
+	)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("search pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var pixel Pixel
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description); err != nil {
+			return nil, fmt.Errorf("scan found pixel: %w", err)
+		}
+		pixels = append(pixels, pixel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate found pixels: %w", err)
+	}
+	return pixels, nil
+}
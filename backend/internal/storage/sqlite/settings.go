@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (s *Store) GetSetting(ctx context.Context, name string) (string, error) {
+	query := fmt.Sprintf("SELECT value FROM settings WHERE name = %s", quoteLiteral(strings.TrimSpace(name)))
+	var value string
+	if err := s.db.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", sql.ErrNoRows
+		}
+		return "", fmt.Errorf("get setting: %w", err)
+	}
+	return value, nil
+}
+
+func (s *Store) PutSetting(ctx context.Context, name, value string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("setting name must not be empty")
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO settings(name, value, updated_at) VALUES (%s, %s, %s) ON CONFLICT(name) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at",
+		quoteLiteral(name),
+		quoteLiteral(value),
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("put setting: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteSetting(ctx context.Context, name string) error {
+	query := fmt.Sprintf("DELETE FROM settings WHERE name = %s", quoteLiteral(strings.TrimSpace(name)))
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete setting: %w", err)
+	}
+	return nil
+}
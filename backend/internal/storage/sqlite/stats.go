@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) GetBusinessStats(ctx context.Context) (storage.BusinessStats, error) {
+	var stats storage.BusinessStats
+	err := s.db.QueryRowContext(ctx, `SELECT
+                (SELECT COUNT(1) FROM pixels WHERE status = 'taken'),
+                (SELECT COUNT(1) FROM pixels WHERE status = 'taken' AND expires_at IS NOT NULL),
+                (SELECT COUNT(1) FROM users),
+                (SELECT COUNT(1) FROM users WHERE is_verified <> 0),
+                (SELECT COALESCE(SUM(user_points), 0) FROM users)`,
+	).Scan(&stats.PixelsTaken, &stats.PixelsRented, &stats.Users, &stats.UsersVerified, &stats.PointsOutstanding)
+	if err != nil {
+		return storage.BusinessStats{}, fmt.Errorf("get business stats: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *Store) GetCanvasStats(ctx context.Context, recent int) (storage.CanvasStats, error) {
+	var stats storage.CanvasStats
+	err := s.db.QueryRowContext(ctx, `SELECT
+                (SELECT COUNT(1) FROM pixels WHERE board_id = '' AND status = 'taken'),
+                (SELECT COUNT(DISTINCT owner_id) FROM pixels WHERE board_id = '' AND status = 'taken' AND owner_id IS NOT NULL),
+                (SELECT COALESCE(SUM(paid_points), 0) FROM pixels WHERE board_id = '' AND status = 'taken')`,
+	).Scan(&stats.PixelsTaken, &stats.Owners, &stats.PointsSpent)
+	if err != nil {
+		return storage.CanvasStats{}, fmt.Errorf("get canvas stats: %w", err)
+	}
+	stats.PixelsFree = storage.TotalPixels - stats.PixelsTaken
+	stats.RecentPurchases, err = s.loadPixelChanges(ctx, fmt.Sprintf(
+		"SELECT %s FROM pixel_history WHERE %s AND owner_id IS NOT NULL AND (previous_owner_id IS NULL OR previous_owner_id <> owner_id) ORDER BY id DESC LIMIT %d",
+		pixelChangeColumns,
+		onMainBoard,
+		recent,
+	))
+	if err != nil {
+		return storage.CanvasStats{}, err
+	}
+	return stats, nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -20,11 +21,22 @@ type (
 	VerificationToken  = storage.VerificationToken
 	PasswordResetToken = storage.PasswordResetToken
 	PixelState         = storage.PixelState
+	PixelUpdateOutcome = storage.PixelUpdateOutcome
+	ActivationCode     = storage.ActivationCode
+	Payment            = storage.Payment
+	AgeAttestation     = storage.AgeAttestation
+	PixelLicense       = storage.PixelLicense
+	Takedown           = storage.Takedown
+	TakedownEvent      = storage.TakedownEvent
+	ContactMessage     = storage.ContactMessage
+	ContactReply       = storage.ContactReply
+	CampaignStats      = storage.CampaignStats
 )
 
 type Store struct {
-	db            *sql.DB
+	db            *sqltrace.DB
 	skipPixelSeed bool
+	pixelQuota    int
 }
 
 var _ storage.Store = (*Store)(nil)
@@ -35,7 +47,8 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 	}
 
 	query := fmt.Sprintf(
-		"SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), owner_id, updated_at FROM pixels WHERE owner_id = %d ORDER BY updated_at DESC",
+		"SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at, expires_at FROM pixels WHERE %s AND owner_id = %d ORDER BY updated_at DESC",
+		onMainBoard,
 		ownerID,
 	)
 
@@ -49,8 +62,8 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 	for rows.Next() {
 		var pixel Pixel
 		var owner sql.NullInt64
-		var updated sql.NullString
-		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &updated); err != nil {
+		var updated, expires sql.NullString
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated, &expires); err != nil {
 			return nil, fmt.Errorf("scan pixel: %w", err)
 		}
 		if owner.Valid {
@@ -64,6 +77,9 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 			}
 			pixel.UpdatedAt = parsed
 		}
+		if pixel.ExpiresAt, err = parseExpiresAt(expires); err != nil {
+			return nil, fmt.Errorf("parse pixel %d expires_at: %w", pixel.ID, err)
+		}
 		pixels = append(pixels, pixel)
 	}
 
@@ -74,6 +90,92 @@ func (s *Store) GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, e
 	return pixels, nil
 }
 
+// GetPixelsModifiedSince compares through julianday because seeded rows carry SQLite's
+// CURRENT_TIMESTAMP format while updated pixels are stored as RFC 3339.
+func (s *Store) GetPixelsModifiedSince(ctx context.Context, since time.Time) ([]Pixel, error) {
+	query := fmt.Sprintf(
+		"SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE %s AND julianday(updated_at) >= julianday(%s) ORDER BY id",
+		onMainBoard,
+		quoteLiteral(since.UTC().Format(time.RFC3339Nano)),
+	)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query modified pixels: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var updated sql.NullString
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
+		}
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		if updated.Valid {
+			parsed, err := parseUpdatedAt(updated.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse pixel %d updated_at: %w", pixel.ID, err)
+			}
+			pixel.UpdatedAt = parsed
+		}
+		pixels = append(pixels, pixel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate modified pixels: %w", err)
+	}
+
+	return pixels, nil
+}
+
+func (s *Store) GetPixelsInRect(ctx context.Context, x, y, width, height int) ([]Pixel, error) {
+	query := fmt.Sprintf(
+		"SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE %s AND id >= %d AND id < %d AND id %% %d >= %d AND id %% %d < %d ORDER BY id",
+		onMainBoard,
+		y*storage.GridWidth, (y+height)*storage.GridWidth, storage.GridWidth, x, storage.GridWidth, x+width,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query pixel rect: %w", err)
+	}
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0, width*height)
+	for rows.Next() {
+		var pixel Pixel
+		var owner sql.NullInt64
+		var updated sql.NullString
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
+		}
+		if owner.Valid {
+			ownerID := owner.Int64
+			pixel.OwnerID = &ownerID
+		}
+		if updated.Valid {
+			parsed, err := parseUpdatedAt(updated.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse pixel %d updated_at: %w", pixel.ID, err)
+			}
+			pixel.UpdatedAt = parsed
+		}
+		pixels = append(pixels, pixel)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pixel rect: %w", err)
+	}
+
+	return pixels, nil
+}
+
 func Open(path string) (*Store, error) {
 	if path == "" {
 		return nil, errors.New("sqlite path must not be empty")
@@ -90,7 +192,7 @@ func Open(path string) (*Store, error) {
 		return nil, fmt.Errorf("configure busy timeout: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: sqltrace.Wrap(db)}, nil
 }
 
 func (s *Store) Close() error {
@@ -100,6 +202,20 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+func (s *Store) Ping(ctx context.Context) error {
+	if s == nil || s.db == nil {
+		return errors.New("store is closed")
+	}
+	return s.db.PingContext(ctx)
+}
+
+// SetSlowQueryHook reports statements slower than threshold to hook with their literals redacted.
+func (s *Store) SetSlowQueryHook(threshold time.Duration, hook sqltrace.Hook) {
+	if s != nil && s.db != nil {
+		s.db.SetSlowQueryHook(threshold, hook)
+	}
+}
+
 // SetSkipPixelSeed configures the store to skip the initial pixel seeding step during EnsureSchema.
 // This is primarily useful for tests where populating the full grid would be prohibitively slow.
 func (s *Store) SetSkipPixelSeed(skip bool) {
@@ -128,11 +244,13 @@ func (s *Store) InsertPixel(ctx context.Context, pixel Pixel) error {
 	}
 
 	query := fmt.Sprintf(
-		"INSERT OR IGNORE INTO pixels(id, status, color, url, owner_id, updated_at) VALUES (%d, %s, %s, %s, %s, CURRENT_TIMESTAMP)",
+		"INSERT OR IGNORE INTO pixels(id, status, color, url, title, description, owner_id, updated_at) VALUES (%d, %s, %s, %s, %s, %s, %s, CURRENT_TIMESTAMP)",
 		pixel.ID,
 		quoteLiteral(status),
 		quoteLiteral(color),
 		quoteLiteral(url),
+		quoteLiteral(strings.TrimSpace(pixel.Title)),
+		quoteLiteral(strings.TrimSpace(pixel.Description)),
 		ownerValue,
 	)
 
@@ -165,75 +283,652 @@ func (s *Store) EnsureSchema(ctx context.Context) (err error) {
 		return err
 	}
 
+	for _, column := range []string{
+		`ALTER TABLE pixels ADD COLUMN title TEXT`,
+		`ALTER TABLE pixels ADD COLUMN description TEXT`,
+		`ALTER TABLE pixels ADD COLUMN expires_at TIMESTAMP`,
+		`ALTER TABLE pixels ADD COLUMN expiry_notified_at TIMESTAMP`,
+		`ALTER TABLE pixels ADD COLUMN paid_points INTEGER`,
+	} {
+		if _, execErr := tx.ExecContext(ctx, column); execErr != nil {
+			// ignore - column may already exist
+		}
+	}
+
+	var boardColumns int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pragma_table_info('pixels') WHERE name = 'board_id'`).Scan(&boardColumns); err != nil {
+		err = fmt.Errorf("inspect pixels table: %w", err)
+		return err
+	}
+	if boardColumns == 0 {
+		// Boards share the table, so pixels are keyed by board and id. SQLite cannot change a
+		// primary key in place; the table is rebuilt with the existing pixels on the main grid.
+		for _, statement := range []string{
+			`CREATE TABLE pixels_by_board (
+                board_id TEXT NOT NULL DEFAULT '',
+                id INTEGER NOT NULL,
+                status TEXT,
+                color TEXT,
+                url TEXT,
+                owner_id INTEGER,
+                updated_at TIMESTAMP,
+                title TEXT,
+                description TEXT,
+                expires_at TIMESTAMP,
+                expiry_notified_at TIMESTAMP,
+                paid_points INTEGER,
+                PRIMARY KEY(board_id, id)
+        )`,
+			`INSERT INTO pixels_by_board(id, status, color, url, owner_id, updated_at, title, description, expires_at, expiry_notified_at, paid_points)
+                SELECT id, status, color, url, owner_id, updated_at, title, description, expires_at, expiry_notified_at, paid_points FROM pixels`,
+			`DROP TABLE pixels`,
+			`ALTER TABLE pixels_by_board RENAME TO pixels`,
+		} {
+			if _, execErr := tx.ExecContext(ctx, statement); execErr != nil {
+				err = fmt.Errorf("key pixels by board: %w", execErr)
+				return err
+			}
+		}
+	}
+
 	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixels_status ON pixels(status)`); execErr != nil {
 		err = fmt.Errorf("create status index: %w", execErr)
 		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users (
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixels_owner ON pixels(owner_id)`); execErr != nil {
+		err = fmt.Errorf("create owner index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixels_updated_at ON pixels(updated_at)`); execErr != nil {
+		err = fmt.Errorf("create updated_at index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixels_expires_at ON pixels(expires_at)`); execErr != nil {
+		err = fmt.Errorf("create expires_at index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                email TEXT NOT NULL UNIQUE,
+                password_hash TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                is_verified INTEGER NOT NULL DEFAULT 0,
+                verified_at TIMESTAMP,
+                user_points INTEGER NOT NULL DEFAULT 0
+        )`); execErr != nil {
+		err = fmt.Errorf("create users table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN is_verified INTEGER NOT NULL DEFAULT 0`); execErr != nil {
+		// ignore - column may already exist
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN verified_at TIMESTAMP`); execErr != nil {
+		// ignore - column may already exist
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN user_points INTEGER NOT NULL DEFAULT 0`); execErr != nil {
+		// ignore - column may already exist
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email))`); execErr != nil {
+		err = fmt.Errorf("create users email index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS activation_codes (
+                code TEXT PRIMARY KEY,
+                value INTEGER NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create activation_codes table: %w", execErr)
+		return err
+	}
+
+	for _, column := range []string{
+		`ALTER TABLE activation_codes ADD COLUMN campaign_id TEXT`,
+		`ALTER TABLE activation_codes ADD COLUMN batch_id TEXT`,
+		`ALTER TABLE activation_codes ADD COLUMN created_at TIMESTAMP`,
+		`ALTER TABLE activation_codes ADD COLUMN redeemed_by INTEGER`,
+		`ALTER TABLE activation_codes ADD COLUMN redeemed_at TIMESTAMP`,
+	} {
+		if _, execErr := tx.ExecContext(ctx, column); execErr != nil {
+			// ignore - column may already exist
+		}
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_activation_codes_campaign ON activation_codes(campaign_id)`); execErr != nil {
+		err = fmt.Errorf("create activation code campaign index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS verification_tokens (
+                token TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                expires_at TIMESTAMP NOT NULL,
+                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                hashed INTEGER NOT NULL DEFAULT 0,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create verification_tokens table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_verification_tokens_user ON verification_tokens(user_id)`); execErr != nil {
+		err = fmt.Errorf("create verification token index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_verification_tokens_expires ON verification_tokens(expires_at)`); execErr != nil {
+		err = fmt.Errorf("create verification token expiry index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS password_reset_tokens (
+                token TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                expires_at TIMESTAMP NOT NULL,
+                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                hashed INTEGER NOT NULL DEFAULT 0,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create password_reset_tokens table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id)`); execErr != nil {
+		err = fmt.Errorf("create password reset token index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires ON password_reset_tokens(expires_at)`); execErr != nil {
+		err = fmt.Errorf("create password reset token expiry index: %w", execErr)
+		return err
+	}
+
+	// Tokens used to be stored as sent; hash the rows written before that changed.
+	for _, table := range []string{"verification_tokens", "password_reset_tokens"} {
+		_, _ = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN hashed INTEGER NOT NULL DEFAULT 0", table))
+		if execErr := hashPlaintextTokens(ctx, tx, table); execErr != nil {
+			err = execErr
+			return err
+		}
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS used_link_nonces (
+                nonce TEXT PRIMARY KEY,
+                expires_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create used_link_nonces table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_used_link_nonces_expires ON used_link_nonces(expires_at)`); execErr != nil {
+		err = fmt.Errorf("create used link nonce expiry index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS payments (
+                id TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                bundle_id TEXT NOT NULL,
+                currency TEXT NOT NULL,
+                amount_minor INTEGER NOT NULL,
+                points INTEGER NOT NULL,
+                status TEXT NOT NULL,
+                provider_ref TEXT,
+                created_at TIMESTAMP NOT NULL,
+                completed_at TIMESTAMP,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create payments table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_payments_user ON payments(user_id)`); execErr != nil {
+		err = fmt.Errorf("create payments index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS settings (
+                name TEXT PRIMARY KEY,
+                value TEXT NOT NULL,
+                updated_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create settings table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS age_attestations (
+                user_id INTEGER PRIMARY KEY,
+                birth_year INTEGER NOT NULL,
+                minimum_age INTEGER NOT NULL,
+                attested_at TIMESTAMP NOT NULL,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create age_attestations table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_licenses (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INTEGER NOT NULL,
+                artwork_owner TEXT NOT NULL,
+                contact TEXT NOT NULL,
+                statement TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP NOT NULL,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_licenses table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_license_pixels (
+                license_id INTEGER NOT NULL,
+                pixel_id INTEGER NOT NULL,
+                PRIMARY KEY(license_id, pixel_id),
+                FOREIGN KEY(license_id) REFERENCES pixel_licenses(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_license_pixels table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_license_pixels_pixel ON pixel_license_pixels(pixel_id)`); execErr != nil {
+		err = fmt.Errorf("create pixel_license_pixels index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS takedowns (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                claimant_name TEXT NOT NULL,
+                claimant_email TEXT NOT NULL,
+                work_url TEXT NOT NULL DEFAULT '',
+                description TEXT NOT NULL,
+                status TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                resolved_at TIMESTAMP
+        )`); execErr != nil {
+		err = fmt.Errorf("create takedowns table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS takedown_pixels (
+                takedown_id INTEGER NOT NULL,
+                pixel_id INTEGER NOT NULL,
+                PRIMARY KEY(takedown_id, pixel_id),
+                FOREIGN KEY(takedown_id) REFERENCES takedowns(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create takedown_pixels table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS takedown_events (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                takedown_id INTEGER NOT NULL,
+                action TEXT NOT NULL,
+                actor_id INTEGER,
+                note TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP NOT NULL,
+                FOREIGN KEY(takedown_id) REFERENCES takedowns(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create takedown_events table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS contact_messages (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INTEGER,
+                name TEXT NOT NULL,
+                email TEXT NOT NULL,
+                subject TEXT NOT NULL,
+                body TEXT NOT NULL,
+                status TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE SET NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create contact_messages table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS contact_replies (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                message_id INTEGER NOT NULL,
+                admin_id INTEGER NOT NULL,
+                body TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                FOREIGN KEY(message_id) REFERENCES contact_messages(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create contact_replies table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_clicks (
+                pixel_id INTEGER NOT NULL,
+                owner_id INTEGER NOT NULL,
+                day TEXT NOT NULL,
+                clicks INTEGER NOT NULL DEFAULT 0,
+                PRIMARY KEY(pixel_id, owner_id, day)
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_clicks table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_clicks_owner ON pixel_clicks(owner_id, day)`); execErr != nil {
+		err = fmt.Errorf("create pixel clicks owner index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_visitors (
+                pixel_id INTEGER NOT NULL,
+                owner_id INTEGER NOT NULL,
+                day TEXT NOT NULL,
+                visitor TEXT NOT NULL,
+                PRIMARY KEY(pixel_id, owner_id, day, visitor)
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_visitors table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_history (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                pixel_id INTEGER NOT NULL,
+                status TEXT NOT NULL,
+                color TEXT NOT NULL DEFAULT '',
+                url TEXT NOT NULL DEFAULT '',
+                owner_id INTEGER,
+                previous_owner_id INTEGER,
+                changed_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_history table: %w", execErr)
+		return err
+	}
+	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE pixel_history ADD COLUMN board_id TEXT NOT NULL DEFAULT ''`); execErr != nil {
+		// ignore - column may already exist
+	}
+
+	for _, index := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_pixel_history_pixel ON pixel_history(pixel_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_pixel_history_owner ON pixel_history(owner_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_pixel_history_previous_owner ON pixel_history(previous_owner_id, id)`,
+	} {
+		if _, execErr := tx.ExecContext(ctx, index); execErr != nil {
+			err = fmt.Errorf("create pixel history index: %w", execErr)
+			return err
+		}
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS owner_webhooks (
+                user_id INTEGER PRIMARY KEY,
+                url TEXT NOT NULL,
+                secret TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                updated_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create owner_webhooks table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS push_devices (
+                token TEXT PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                platform TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL,
+                last_seen_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create push_devices table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_push_devices_user ON push_devices(user_id)`); execErr != nil {
+		err = fmt.Errorf("create push devices user index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS engagement_reports (
+                user_id INTEGER PRIMARY KEY,
+                token TEXT NOT NULL UNIQUE,
+                created_at TIMESTAMP NOT NULL,
+                last_sent_at TIMESTAMP
+        )`); execErr != nil {
+		err = fmt.Errorf("create engagement_reports table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS user_pixel_quotas (
+                user_id INTEGER PRIMARY KEY,
+                max_pixels INTEGER NOT NULL,
+                updated_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create user_pixel_quotas table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS invite_codes (
+                code TEXT PRIMARY KEY,
+                batch_id TEXT,
+                created_by INTEGER,
+                created_at TIMESTAMP NOT NULL,
+                used_by INTEGER,
+                used_at TIMESTAMP
+        )`); execErr != nil {
+		err = fmt.Errorf("create invite_codes table: %w", execErr)
+		return err
+	}
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_invite_codes_created_by ON invite_codes(created_by)`); execErr != nil {
+		err = fmt.Errorf("create invite_codes created_by index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS leaderboard_opt_outs (
+                user_id INTEGER PRIMARY KEY,
+                created_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create leaderboard_opt_outs table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_holds (
+                pixel_id INTEGER PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                expires_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_holds table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_holds_user ON pixel_holds(user_id)`); execErr != nil {
+		err = fmt.Errorf("create pixel holds user index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS custom_domains (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                domain TEXT NOT NULL,
+                user_id INTEGER NOT NULL,
+                pixel_id INTEGER,
+                token TEXT NOT NULL,
+                verified_at TIMESTAMP,
+                last_checked_at TIMESTAMP,
+                created_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create custom_domains table: %w", execErr)
+		return err
+	}
+
+	var domainConstraints int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pragma_index_list('custom_domains') WHERE origin = 'u'`).Scan(&domainConstraints); err != nil {
+		err = fmt.Errorf("inspect custom_domains table: %w", err)
+		return err
+	}
+	if domainConstraints > 0 {
+		// Several users may claim a domain until one of them verifies it, so only verified domains
+		// are unique. SQLite cannot drop a column constraint; the table is rebuilt without it.
+		for _, statement := range []string{
+			`CREATE TABLE custom_domain_claims (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                domain TEXT NOT NULL,
+                user_id INTEGER NOT NULL,
+                pixel_id INTEGER,
+                token TEXT NOT NULL,
+                verified_at TIMESTAMP,
+                last_checked_at TIMESTAMP,
+                created_at TIMESTAMP NOT NULL
+        )`,
+			`INSERT INTO custom_domain_claims SELECT id, domain, user_id, pixel_id, token, verified_at, last_checked_at, created_at FROM custom_domains`,
+			`DROP TABLE custom_domains`,
+			`ALTER TABLE custom_domain_claims RENAME TO custom_domains`,
+		} {
+			if _, execErr := tx.ExecContext(ctx, statement); execErr != nil {
+				err = fmt.Errorf("scope custom domain uniqueness to verified domains: %w", execErr)
+				return err
+			}
+		}
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_custom_domains_user ON custom_domains(user_id)`); execErr != nil {
+		err = fmt.Errorf("create custom domains user index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_custom_domains_domain ON custom_domains(domain)`); execErr != nil {
+		err = fmt.Errorf("create custom domains domain index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_verified ON custom_domains(domain) WHERE verified_at IS NOT NULL`); execErr != nil {
+		err = fmt.Errorf("create custom domains verified index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_refunds (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                user_id INTEGER NOT NULL,
+                admin_id INTEGER NOT NULL,
+                pixel_ids TEXT NOT NULL,
+                points INTEGER NOT NULL,
+                reason TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_refunds table: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_refunds_user ON pixel_refunds(user_id)`); execErr != nil {
+		err = fmt.Errorf("create pixel refunds user index: %w", execErr)
+		return err
+	}
+
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_drafts (
                 id INTEGER PRIMARY KEY AUTOINCREMENT,
-                email TEXT NOT NULL UNIQUE,
-                password_hash TEXT NOT NULL,
-                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-                is_verified INTEGER NOT NULL DEFAULT 0,
-                verified_at TIMESTAMP,
-                user_points INTEGER NOT NULL DEFAULT 0
+                user_id INTEGER NOT NULL,
+                name TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP NOT NULL,
+                updated_at TIMESTAMP NOT NULL
         )`); execErr != nil {
-		err = fmt.Errorf("create users table: %w", execErr)
+		err = fmt.Errorf("create pixel_drafts table: %w", execErr)
 		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN is_verified INTEGER NOT NULL DEFAULT 0`); execErr != nil {
-		// ignore - column may already exist
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_drafts_user ON pixel_drafts(user_id)`); execErr != nil {
+		err = fmt.Errorf("create pixel drafts user index: %w", execErr)
+		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN verified_at TIMESTAMP`); execErr != nil {
-		// ignore - column may already exist
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_draft_pixels (
+                draft_id INTEGER NOT NULL,
+                pixel_id INTEGER NOT NULL,
+                color TEXT NOT NULL DEFAULT '',
+                url TEXT NOT NULL DEFAULT '',
+                title TEXT NOT NULL DEFAULT '',
+                description TEXT NOT NULL DEFAULT '',
+                PRIMARY KEY(draft_id, pixel_id),
+                FOREIGN KEY(draft_id) REFERENCES pixel_drafts(id) ON DELETE CASCADE
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_draft_pixels table: %w", execErr)
+		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE users ADD COLUMN user_points INTEGER NOT NULL DEFAULT 0`); execErr != nil {
-		// ignore - column may already exist
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS pixel_reviews (
+                pixel_id INTEGER PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                queued_at TIMESTAMP NOT NULL
+        )`); execErr != nil {
+		err = fmt.Errorf("create pixel_reviews table: %w", execErr)
+		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS activation_codes (
-                code TEXT PRIMARY KEY,
-                value INTEGER NOT NULL
-        )`); execErr != nil {
-		err = fmt.Errorf("create activation_codes table: %w", execErr)
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_pixel_reviews_user ON pixel_reviews(user_id)`); execErr != nil {
+		err = fmt.Errorf("create pixel reviews user index: %w", execErr)
 		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS verification_tokens (
-                token TEXT PRIMARY KEY,
-                user_id INTEGER NOT NULL,
-                expires_at TIMESTAMP NOT NULL,
-                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS admin_actions (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                admin_id INTEGER NOT NULL,
+                action TEXT NOT NULL,
+                target TEXT NOT NULL,
+                pixel_ids TEXT NOT NULL,
+                reason TEXT NOT NULL,
+                created_at TIMESTAMP NOT NULL
         )`); execErr != nil {
-		err = fmt.Errorf("create verification_tokens table: %w", execErr)
+		err = fmt.Errorf("create admin_actions table: %w", execErr)
 		return err
 	}
 
-	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_verification_tokens_user ON verification_tokens(user_id)`); execErr != nil {
-		err = fmt.Errorf("create verification token index: %w", execErr)
+	var ledgerTables int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'points_ledger'`).Scan(&ledgerTables); err != nil {
+		err = fmt.Errorf("inspect points ledger table: %w", err)
 		return err
 	}
-
-	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS password_reset_tokens (
-                token TEXT PRIMARY KEY,
+	if _, execErr := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS points_ledger (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
                 user_id INTEGER NOT NULL,
-                expires_at TIMESTAMP NOT NULL,
-                created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+                delta INTEGER NOT NULL,
+                kind TEXT NOT NULL,
+                reference TEXT NOT NULL DEFAULT '',
+                reason TEXT NOT NULL DEFAULT '',
+                created_at TIMESTAMP NOT NULL
         )`); execErr != nil {
-		err = fmt.Errorf("create password_reset_tokens table: %w", execErr)
+		err = fmt.Errorf("create points_ledger table: %w", execErr)
 		return err
 	}
+	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_points_ledger_user ON points_ledger(user_id)`); execErr != nil {
+		err = fmt.Errorf("create points ledger user index: %w", execErr)
+		return err
+	}
+	if ledgerTables == 0 {
+		// Balances from before the ledger are carried over as opening entries.
+		openingQuery := fmt.Sprintf(
+			"INSERT INTO points_ledger(user_id, delta, kind, created_at) SELECT id, user_points, %s, %s FROM users WHERE user_points <> 0",
+			quoteLiteral(storage.LedgerOpeningBalance),
+			quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+		)
+		if _, execErr := tx.ExecContext(ctx, openingQuery); execErr != nil {
+			err = fmt.Errorf("record opening balances: %w", execErr)
+			return err
+		}
+	}
 
-	if _, execErr := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id)`); execErr != nil {
-		err = fmt.Errorf("create password reset token index: %w", execErr)
+	var searchTables int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE name = 'pixel_search'`).Scan(&searchTables); err != nil {
+		err = fmt.Errorf("inspect pixel search table: %w", err)
+		return err
+	}
+	if _, execErr := tx.ExecContext(ctx, `CREATE VIRTUAL TABLE IF NOT EXISTS pixel_search USING fts5(title, description, url, tokenize = 'unicode61 remove_diacritics 2')`); execErr != nil {
+		err = fmt.Errorf("create pixel_search table: %w", execErr)
 		return err
 	}
+	if searchTables == 0 {
+		// Pixels bought before the index existed are indexed once.
+		if _, execErr := tx.ExecContext(ctx, `INSERT INTO pixel_search(rowid, title, description, url) SELECT id, COALESCE(title, ''), COALESCE(description, ''), COALESCE(url, '') FROM pixels WHERE board_id = '' AND status = 'taken' AND (COALESCE(title, '') <> '' OR COALESCE(description, '') <> '' OR COALESCE(url, '') <> '')`); execErr != nil {
+			err = fmt.Errorf("index pixels for search: %w", execErr)
+			return err
+		}
+	}
 
 	// Attempt to add missing owner_id column for existing databases. Ignore errors if it already exists.
 	if _, execErr := tx.ExecContext(ctx, `ALTER TABLE pixels ADD COLUMN owner_id INTEGER`); execErr != nil {
@@ -241,7 +936,7 @@ func (s *Store) EnsureSchema(ctx context.Context) (err error) {
 	}
 
 	var count int
-	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pixels`).Scan(&count); err != nil {
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(1) FROM pixels WHERE board_id = ''`).Scan(&count); err != nil {
 		err = fmt.Errorf("count pixels: %w", err)
 		return err
 	}
@@ -267,6 +962,27 @@ func (s *Store) EnsureSchema(ctx context.Context) (err error) {
 	return nil
 }
 
+func (s *Store) MissingIndexes(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'index'`)
+	if err != nil {
+		return nil, fmt.Errorf("query indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var present []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan index: %w", err)
+		}
+		present = append(present, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate indexes: %w", err)
+	}
+	return storage.MissingIndexes(present), nil
+}
+
 func parseUpdatedAt(value string) (time.Time, error) {
 	layouts := []string{
 		time.RFC3339Nano,
@@ -287,19 +1003,33 @@ func parseUpdatedAt(value string) (time.Time, error) {
 }
 
 func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), owner_id, updated_at FROM pixels ORDER BY id`)
+	pixels, err := s.loadBoardPixels(ctx, storage.MainBoard)
+	if err != nil {
+		return PixelState{}, err
+	}
+	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+}
+
+// loadBoardPixels returns the stored pixels of the board, ordered by id.
+func (s *Store) loadBoardPixels(ctx context.Context, board string) ([]Pixel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, status, COALESCE(color, ''), COALESCE(url, ''), COALESCE(title, ''), COALESCE(description, ''), owner_id, updated_at FROM pixels WHERE `+onBoard(board)+` ORDER BY id`)
 	if err != nil {
-		return PixelState{}, fmt.Errorf("query pixels: %w", err)
+		return nil, fmt.Errorf("query pixels: %w", err)
 	}
 	defer rows.Close()
 
-	pixels := make([]Pixel, 0, storage.TotalPixels)
+	capacity := 0
+	if board == storage.MainBoard {
+		// The main grid has a row for every pixel.
+		capacity = storage.TotalPixels
+	}
+	pixels := make([]Pixel, 0, capacity)
 	for rows.Next() {
 		var pixel Pixel
 		var owner sql.NullInt64
 		var updated sql.NullString
-		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &owner, &updated); err != nil {
-			return PixelState{}, fmt.Errorf("scan pixel: %w", err)
+		if err := rows.Scan(&pixel.ID, &pixel.Status, &pixel.Color, &pixel.URL, &pixel.Title, &pixel.Description, &owner, &updated); err != nil {
+			return nil, fmt.Errorf("scan pixel: %w", err)
 		}
 		if owner.Valid {
 			ownerID := owner.Int64
@@ -308,7 +1038,7 @@ func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
 		if updated.Valid {
 			parsed, err := parseUpdatedAt(updated.String)
 			if err != nil {
-				return PixelState{}, fmt.Errorf("parse pixel %d updated_at: %w", pixel.ID, err)
+				return nil, fmt.Errorf("parse pixel %d updated_at: %w", pixel.ID, err)
 			}
 			pixel.UpdatedAt = parsed
 		}
@@ -316,10 +1046,10 @@ func (s *Store) GetAllPixels(ctx context.Context) (PixelState, error) {
 	}
 
 	if err := rows.Err(); err != nil {
-		return PixelState{}, fmt.Errorf("iterate pixels: %w", err)
+		return nil, fmt.Errorf("iterate pixels: %w", err)
 	}
 
-	return PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}, nil
+	return pixels, nil
 }
 
 func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, err error) {
@@ -335,11 +1065,16 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, er
 		updated.Status = "taken"
 		updated.Color = pixel.Color
 		updated.URL = pixel.URL
+		updated.Title = pixel.Title
+		updated.Description = pixel.Description
 		updated.OwnerID = pixel.OwnerID
+		updated.ExpiresAt = pixel.ExpiresAt
 	} else {
 		updated.Status = "free"
 		updated.Color = ""
 		updated.URL = ""
+		updated.Title = ""
+		updated.Description = ""
 		updated.OwnerID = nil
 	}
 
@@ -355,6 +1090,11 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, er
 		}
 	}()
 
+	before, err := loadPixelHistoryState(ctx, tx, updated.ID)
+	if err != nil {
+		return Pixel{}, err
+	}
+
 	var ownerValue string
 	if updated.OwnerID != nil {
 		ownerValue = fmt.Sprintf("%d", *updated.OwnerID)
@@ -363,12 +1103,18 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, er
 	}
 
 	query := fmt.Sprintf(
-		"UPDATE pixels SET status = %s, color = %s, url = %s, owner_id = %s, updated_at = %s WHERE id = %d",
+		"UPDATE pixels SET status = %s, color = %s, url = %s, title = %s, description = %s, owner_id = %s, updated_at = %s, expires_at = %s%s%s WHERE %s AND id = %d",
 		quoteLiteral(updated.Status),
 		quoteLiteral(updated.Color),
 		quoteLiteral(updated.URL),
+		quoteLiteral(updated.Title),
+		quoteLiteral(updated.Description),
 		ownerValue,
 		quoteLiteral(updated.UpdatedAt.Format(time.RFC3339Nano)),
+		expiresLiteral(updated.ExpiresAt),
+		expiryWarningReset(before, updated),
+		paidPointsUpdate(before, updated, 0, false),
+		onMainBoard,
 		updated.ID,
 	)
 
@@ -384,6 +1130,12 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, er
 	if affected == 0 {
 		return Pixel{}, sql.ErrNoRows
 	}
+	if err = recordPixelChange(ctx, tx, before, updated); err != nil {
+		return Pixel{}, err
+	}
+	if err = indexPixelSearch(ctx, tx, updated); err != nil {
+		return Pixel{}, err
+	}
 
 	if err = tx.Commit(); err != nil {
 		return Pixel{}, fmt.Errorf("commit update pixel: %w", err)
@@ -392,21 +1144,43 @@ func (s *Store) UpdatePixel(ctx context.Context, pixel Pixel) (updated Pixel, er
 	return updated, nil
 }
 
-func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pixel Pixel, cost int64) (updated Pixel, updatedUser User, err error) {
-	if userID <= 0 {
-		return Pixel{}, User{}, errors.New("invalid user id")
+func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pixel Pixel, cost int64) (Pixel, User, error) {
+	outcomes, updatedUser, err := s.UpdatePixelsForUserWithCost(ctx, userID, []Pixel{pixel}, cost, "")
+	if err != nil {
+		return Pixel{}, User{}, err
 	}
-	if pixel.ID < 0 || pixel.ID >= storage.TotalPixels {
-		return Pixel{}, User{}, fmt.Errorf("invalid pixel id: %d", pixel.ID)
+	if outcomes[0].Err != nil {
+		return Pixel{}, User{}, outcomes[0].Err
+	}
+	return outcomes[0].Pixel, updatedUser, nil
+}
+
+func (s *Store) UpdatePixelsForUserWithCost(ctx context.Context, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	return s.updatePixelsWithCost(ctx, storage.MainBoard, userID, userID, pixels, cost, priceVersion)
+}
+
+func (s *Store) GiftPixels(ctx context.Context, buyerID, recipientID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error) {
+	if recipientID <= 0 || recipientID == buyerID {
+		return nil, User{}, errors.New("invalid gift recipient")
+	}
+	return s.updatePixelsWithCost(ctx, storage.MainBoard, buyerID, recipientID, pixels, cost, priceVersion)
+}
+
+// updatePixelsWithCost applies pixels of board in one transaction, charging userID and making
+// ownerID the owner of the pixels taken. A different ownerID is a gift, which only free pixels can
+// be. Pixels of other boards than the main grid get their row when first written and cannot be held.
+func (s *Store) updatePixelsWithCost(ctx context.Context, board string, userID, ownerID int64, pixels []Pixel, cost int64, priceVersion string) (outcomes []PixelUpdateOutcome, updatedUser User, err error) {
+	if userID <= 0 {
+		return nil, User{}, errors.New("invalid user id")
 	}
 	if cost < 0 {
-		return Pixel{}, User{}, errors.New("cost must not be negative")
+		return nil, User{}, errors.New("cost must not be negative")
 	}
 
-	var tx *sql.Tx
+	var tx *sqltrace.Tx
 	tx, err = s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return Pixel{}, User{}, fmt.Errorf("begin update pixel for user: %w", err)
+		return nil, User{}, fmt.Errorf("begin update pixels for user: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -419,22 +1193,89 @@ func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pi
 	if scanErr := tx.QueryRowContext(ctx, pointsQuery).Scan(&currentPoints); scanErr != nil {
 		if errors.Is(scanErr, sql.ErrNoRows) {
 			err = sql.ErrNoRows
-			return Pixel{}, User{}, err
+			return nil, User{}, err
 		}
 		err = fmt.Errorf("load user points: %w", scanErr)
-		return Pixel{}, User{}, err
+		return nil, User{}, err
 	}
 
-	ownerQuery := fmt.Sprintf("SELECT owner_id FROM pixels WHERE id = %d", pixel.ID)
-	row := tx.QueryRowContext(ctx, ownerQuery)
-	var currentOwner sql.NullInt64
-	if scanErr := row.Scan(&currentOwner); scanErr != nil {
-		if errors.Is(scanErr, sql.ErrNoRows) {
-			err = sql.ErrNoRows
-			return Pixel{}, User{}, err
+	quota, quotaErr := loadPixelQuota(ctx, tx, ownerID, s.pixelQuota)
+	if quotaErr != nil {
+		err = quotaErr
+		return nil, User{}, err
+	}
+
+	ids := make([]int, 0, len(pixels))
+	for _, pixel := range pixels {
+		if pixel.ID >= 0 && pixel.ID < storage.TotalPixels {
+			ids = append(ids, pixel.ID)
 		}
-		err = fmt.Errorf("load current pixel state: %w", scanErr)
-		return Pixel{}, User{}, err
+	}
+	var held map[int]bool
+	if board == storage.MainBoard {
+		held, err = pixelsHeldByOthers(ctx, tx, ids, userID, time.Now())
+	} else {
+		err = insertBoardPixels(ctx, tx, board, ids)
+	}
+	if err != nil {
+		return nil, User{}, err
+	}
+	states, err := loadPixelHistoryStates(ctx, tx, board, ids)
+	if err != nil {
+		return nil, User{}, err
+	}
+
+	// Pixels are decided one by one against the state the earlier ones left, then written together.
+	batch := newPixelBatch(board)
+	outcomes = make([]PixelUpdateOutcome, len(pixels))
+	for i, pixel := range pixels {
+		before, exists := states[pixel.ID]
+		updated, charged, rejected := planPixelUpdate(userID, ownerID, pixel, before, exists, held[pixel.ID], cost, &currentPoints, quota)
+		outcomes[i] = PixelUpdateOutcome{Pixel: updated, Err: rejected}
+		if rejected == nil {
+			batch.add(before, updated, charged, true)
+			states[pixel.ID] = updated
+		}
+	}
+	if err = batch.write(ctx, tx, userID, priceVersion); err != nil {
+		return nil, User{}, err
+	}
+
+	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", userID)
+	updatedUser, err = scanUser(tx.QueryRowContext(ctx, userQuery))
+	if err != nil {
+		return nil, User{}, err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit update pixels for user: %w", commitErr)
+		return nil, User{}, err
+	}
+
+	return outcomes, updatedUser, nil
+}
+
+// planPixelUpdate decides how pixel is written for userID, given the state before it and whether
+// another user holds it, and deducts cost from points when the user acquires it. The pixel goes to
+// ownerID, which differs from userID only for gifts of free pixels, and counts against its quota.
+// Nothing is written; a rejected pixel leaves points and quota untouched.
+func planPixelUpdate(userID, ownerID int64, pixel, before Pixel, exists, held bool, cost int64, points *int64, quota *pixelQuota) (updated Pixel, charged int64, rejected error) {
+	if pixel.ID < 0 || pixel.ID >= storage.TotalPixels {
+		return Pixel{}, 0, fmt.Errorf("invalid pixel id: %d", pixel.ID)
+	}
+	gift := ownerID != userID
+	if gift && !strings.EqualFold(pixel.Status, "taken") {
+		return Pixel{}, 0, errors.New("gifted pixels must be taken")
+	}
+	if !exists {
+		return Pixel{}, 0, sql.ErrNoRows
+	}
+	var currentOwner sql.NullInt64
+	if before.OwnerID != nil {
+		currentOwner = sql.NullInt64{Int64: *before.OwnerID, Valid: true}
+	}
+	if gift && currentOwner.Valid {
+		return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
 	}
 
 	updated = Pixel{ID: pixel.ID}
@@ -442,104 +1283,56 @@ func (s *Store) UpdatePixelForUserWithCost(ctx context.Context, userID int64, pi
 
 	if strings.EqualFold(pixel.Status, "taken") {
 		if pixel.Color == "" || pixel.URL == "" {
-			err = errors.New("taken pixels require color and url")
-			return Pixel{}, User{}, err
+			return Pixel{}, 0, errors.New("taken pixels require color and url")
 		}
 		if currentOwner.Valid && currentOwner.Int64 != userID {
-			err = storage.ErrPixelOwnedByAnotherUser
-			return Pixel{}, User{}, err
+			return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
+		}
+		if !currentOwner.Valid && held {
+			return Pixel{}, 0, storage.ErrPixelHeld
 		}
 		if !currentOwner.Valid || currentOwner.Int64 != userID {
 			chargeCost = cost > 0
+			updated.ExpiresAt = pixel.ExpiresAt
+		} else {
+			updated.ExpiresAt = before.ExpiresAt
 		}
 		updated.Status = "taken"
 		updated.Color = pixel.Color
 		updated.URL = pixel.URL
-		owner := userID
+		updated.Title = pixel.Title
+		updated.Description = pixel.Description
+		owner := ownerID
 		updated.OwnerID = &owner
 	} else {
 		if currentOwner.Valid && currentOwner.Int64 != userID {
-			err = storage.ErrPixelOwnedByAnotherUser
-			return Pixel{}, User{}, err
+			return Pixel{}, 0, storage.ErrPixelOwnedByAnotherUser
 		}
 		updated.Status = "free"
 		updated.Color = ""
 		updated.URL = ""
+		updated.Title = ""
+		updated.Description = ""
 		updated.OwnerID = nil
 	}
 
-	if chargeCost {
-		if currentPoints < cost {
-			err = storage.ErrInsufficientPoints
-			return Pixel{}, User{}, err
-		}
-		chargeQuery := fmt.Sprintf("UPDATE users SET user_points = user_points - %d WHERE id = %d AND user_points >= %d", cost, userID, cost)
-		res, execErr := tx.ExecContext(ctx, chargeQuery)
-		if execErr != nil {
-			err = fmt.Errorf("deduct user points: %w", execErr)
-			return Pixel{}, User{}, err
-		}
-		affected, affErr := res.RowsAffected()
-		if affErr != nil {
-			err = fmt.Errorf("deduct user points rows affected: %w", affErr)
-			return Pixel{}, User{}, err
-		}
-		if affected == 0 {
-			err = storage.ErrInsufficientPoints
-			return Pixel{}, User{}, err
-		}
-		currentPoints -= cost
-	}
-
-	updated.UpdatedAt = time.Now().UTC()
-
-	var ownerValue string
-	if updated.OwnerID != nil {
-		ownerValue = fmt.Sprintf("%d", *updated.OwnerID)
-	} else {
-		ownerValue = "NULL"
-	}
-
-	updateQuery := fmt.Sprintf(
-		"UPDATE pixels SET status = %s, color = %s, url = %s, owner_id = %s, updated_at = %s WHERE id = %d",
-		quoteLiteral(updated.Status),
-		quoteLiteral(updated.Color),
-		quoteLiteral(updated.URL),
-		ownerValue,
-		quoteLiteral(updated.UpdatedAt.Format(time.RFC3339Nano)),
-		updated.ID,
-	)
-
-	res, execErr := tx.ExecContext(ctx, updateQuery)
-	if execErr != nil {
-		err = fmt.Errorf("update pixel for user: %w", execErr)
-		return Pixel{}, User{}, err
-	}
-
-	affected, affErr := res.RowsAffected()
-	if affErr != nil {
-		err = fmt.Errorf("rows affected: %w", affErr)
-		return Pixel{}, User{}, err
+	if chargeCost && *points < cost {
+		return Pixel{}, 0, storage.ErrInsufficientPoints
 	}
-	if affected == 0 {
-		err = sql.ErrNoRows
-		return Pixel{}, User{}, err
+	acquired := updated.OwnerID != nil && (!currentOwner.Valid || currentOwner.Int64 != *updated.OwnerID)
+	if acquired && !quota.take() {
+		return Pixel{}, 0, storage.ErrPixelQuotaExceeded
 	}
-
-	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", userID)
-	userRow := tx.QueryRowContext(ctx, userQuery)
-	updatedUser, scanErr := scanUser(userRow)
-	if scanErr != nil {
-		err = scanErr
-		return Pixel{}, User{}, err
+	if updated.OwnerID == nil && currentOwner.Valid && currentOwner.Int64 == ownerID {
+		quota.release()
 	}
-
-	if commitErr := tx.Commit(); commitErr != nil {
-		err = fmt.Errorf("commit update pixel for user: %w", commitErr)
-		return Pixel{}, User{}, err
+	if chargeCost {
+		charged = cost
+		*points -= cost
 	}
 
-	return updated, updatedUser, nil
+	updated.UpdatedAt = time.Now().UTC()
+	return updated, charged, nil
 }
 
 func (s *Store) UpdatePixelForUser(ctx context.Context, userID int64, pixel Pixel) (Pixel, error) {
@@ -655,9 +1448,10 @@ func (s *Store) CreateActivationCode(ctx context.Context, code string, value int
 	}
 
 	query := fmt.Sprintf(
-		"INSERT INTO activation_codes(code, value) VALUES (%s, %d)",
+		"INSERT INTO activation_codes(code, value, created_at) VALUES (%s, %d, %s)",
 		quoteLiteral(strings.ToUpper(code)),
 		value,
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
 	)
 
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
@@ -669,6 +1463,145 @@ func (s *Store) CreateActivationCode(ctx context.Context, code string, value int
 	return nil
 }
 
+// CreateActivationCodeBatch inserts all codes of a batch in a single transaction.
+func (s *Store) CreateActivationCodeBatch(ctx context.Context, batch storage.ActivationCodeBatch) (err error) {
+	if batch.Value <= 0 {
+		return errors.New("activation code value must be positive")
+	}
+	campaign := strings.TrimSpace(batch.CampaignID)
+	if campaign == "" {
+		return errors.New("campaign id must not be empty")
+	}
+	if len(batch.Codes) == 0 {
+		return errors.New("batch must contain codes")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin create activation code batch: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	created := quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano))
+	for _, code := range batch.Codes {
+		normalized := strings.ToUpper(strings.TrimSpace(code))
+		if normalized == "" {
+			err = errors.New("activation code must not be empty")
+			return err
+		}
+		query := fmt.Sprintf(
+			"INSERT INTO activation_codes(code, value, campaign_id, batch_id, created_at) VALUES (%s, %d, %s, %s, %s)",
+			quoteLiteral(normalized),
+			batch.Value,
+			quoteLiteral(campaign),
+			quoteLiteral(strings.TrimSpace(batch.BatchID)),
+			created,
+		)
+		if _, execErr := tx.ExecContext(ctx, query); execErr != nil {
+			if strings.Contains(strings.ToLower(execErr.Error()), "unique") {
+				err = fmt.Errorf("activation code already exists: %w", execErr)
+				return err
+			}
+			err = fmt.Errorf("insert activation code: %w", execErr)
+			return err
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit activation code batch: %w", commitErr)
+		return err
+	}
+	return nil
+}
+
+// GetCampaignStats aggregates redemption and follow-up purchase figures for a campaign.
+// Purchases are attributed to the campaign when a redeeming user owns pixels updated after redemption.
+func (s *Store) GetCampaignStats(ctx context.Context, campaignID string) (CampaignStats, error) {
+	campaign := strings.TrimSpace(campaignID)
+	if campaign == "" {
+		return CampaignStats{}, errors.New("campaign id must not be empty")
+	}
+	quoted := quoteLiteral(campaign)
+
+	stats := CampaignStats{CampaignID: campaign, Batches: make([]storage.CampaignBatchStats, 0)}
+	summary := fmt.Sprintf(
+		`SELECT COUNT(1),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN 1 ELSE 0 END), 0),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN value ELSE 0 END), 0),
+                        COUNT(DISTINCT redeemed_by)
+                 FROM activation_codes WHERE campaign_id = %s`,
+		quoted,
+	)
+	if err := s.db.QueryRowContext(ctx, summary).Scan(&stats.CodesIssued, &stats.CodesRedeemed, &stats.PointsIssued, &stats.RedeemingUsers); err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign summary: %w", err)
+	}
+	if stats.CodesIssued == 0 {
+		return CampaignStats{}, sql.ErrNoRows
+	}
+	stats.RedemptionRate = float64(stats.CodesRedeemed) / float64(stats.CodesIssued)
+
+	purchases := fmt.Sprintf(
+		`SELECT COUNT(DISTINCT p.id), COUNT(DISTINCT p.owner_id)
+                 FROM activation_codes a
+                 JOIN pixels p ON p.owner_id = a.redeemed_by AND p.updated_at >= a.redeemed_at
+                 WHERE a.campaign_id = %s AND a.redeemed_at IS NOT NULL`,
+		quoted,
+	)
+	if err := s.db.QueryRowContext(ctx, purchases).Scan(&stats.PixelsPurchased, &stats.PurchasingUsers); err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign purchases: %w", err)
+	}
+
+	batches := fmt.Sprintf(
+		`SELECT COALESCE(batch_id, ''), COUNT(1),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN 1 ELSE 0 END), 0),
+                        COALESCE(SUM(CASE WHEN redeemed_at IS NOT NULL THEN value ELSE 0 END), 0)
+                 FROM activation_codes WHERE campaign_id = %s
+                 GROUP BY COALESCE(batch_id, '') ORDER BY 1`,
+		quoted,
+	)
+	rows, err := s.db.QueryContext(ctx, batches)
+	if err != nil {
+		return CampaignStats{}, fmt.Errorf("query campaign batches: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var batch storage.CampaignBatchStats
+		if err := rows.Scan(&batch.BatchID, &batch.CodesIssued, &batch.CodesRedeemed, &batch.PointsIssued); err != nil {
+			return CampaignStats{}, fmt.Errorf("scan campaign batch: %w", err)
+		}
+		stats.Batches = append(stats.Batches, batch)
+	}
+	if err := rows.Err(); err != nil {
+		return CampaignStats{}, fmt.Errorf("iterate campaign batches: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *Store) GetActivationCode(ctx context.Context, code string) (ActivationCode, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(code))
+	if normalized == "" {
+		return ActivationCode{}, errors.New("activation code must not be empty")
+	}
+
+	query := fmt.Sprintf(
+		"SELECT code, value, COALESCE(campaign_id, ''), COALESCE(batch_id, '') FROM activation_codes WHERE code = %s AND redeemed_at IS NULL",
+		quoteLiteral(normalized),
+	)
+	var record ActivationCode
+	if err := s.db.QueryRowContext(ctx, query).Scan(&record.Code, &record.Value, &record.CampaignID, &record.BatchID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ActivationCode{}, sql.ErrNoRows
+		}
+		return ActivationCode{}, fmt.Errorf("get activation code: %w", err)
+	}
+	return record, nil
+}
+
 func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code string) (User, int64, error) {
 	if userID <= 0 {
 		return User{}, 0, errors.New("invalid user id")
@@ -688,7 +1621,7 @@ func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code str
 		}
 	}()
 
-	selectQuery := fmt.Sprintf("SELECT value FROM activation_codes WHERE code = %s", quoteLiteral(normalized))
+	selectQuery := fmt.Sprintf("SELECT value FROM activation_codes WHERE code = %s AND redeemed_at IS NULL", quoteLiteral(normalized))
 	row := tx.QueryRowContext(ctx, selectQuery)
 	var value int64
 	if scanErr := row.Scan(&value); scanErr != nil {
@@ -700,10 +1633,16 @@ func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code str
 		return User{}, 0, err
 	}
 
-	deleteQuery := fmt.Sprintf("DELETE FROM activation_codes WHERE code = %s", quoteLiteral(normalized))
-	res, execErr := tx.ExecContext(ctx, deleteQuery)
+	// Redeemed codes are kept (marked with the redeeming user) so campaign statistics can be computed.
+	redeemQuery := fmt.Sprintf(
+		"UPDATE activation_codes SET redeemed_by = %d, redeemed_at = %s WHERE code = %s AND redeemed_at IS NULL",
+		userID,
+		quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano)),
+		quoteLiteral(normalized),
+	)
+	res, execErr := tx.ExecContext(ctx, redeemQuery)
 	if execErr != nil {
-		err = fmt.Errorf("delete activation code: %w", execErr)
+		err = fmt.Errorf("mark activation code redeemed: %w", execErr)
 		return User{}, 0, err
 	}
 	affected, affErr := res.RowsAffected()
@@ -731,6 +1670,9 @@ func (s *Store) RedeemActivationCode(ctx context.Context, userID int64, code str
 		err = sql.ErrNoRows
 		return User{}, 0, err
 	}
+	if err = recordLedgerEntry(ctx, tx, userID, value, storage.LedgerActivationCode, normalized, ""); err != nil {
+		return User{}, 0, err
+	}
 
 	userQuery := fmt.Sprintf("SELECT id, email, password_hash, created_at, is_verified, verified_at, user_points FROM users WHERE id = %d", userID)
 	userRow := tx.QueryRowContext(ctx, userQuery)
@@ -759,8 +1701,8 @@ func (s *Store) CreateVerificationToken(ctx context.Context, token string, userI
 
 	created := time.Now().UTC()
 	query := fmt.Sprintf(
-		"INSERT INTO verification_tokens(token, user_id, expires_at, created_at) VALUES (%s, %d, %s, %s)",
-		quoteLiteral(token),
+		"INSERT INTO verification_tokens(token, user_id, expires_at, created_at, hashed) VALUES (%s, %d, %s, %s, 1)",
+		quoteLiteral(storage.HashToken(token)),
 		userID,
 		quoteLiteral(expiresAt.UTC().Format(time.RFC3339Nano)),
 		quoteLiteral(created.Format(time.RFC3339Nano)),
@@ -783,15 +1725,15 @@ func (s *Store) GetVerificationToken(ctx context.Context, token string) (Verific
 	}
 
 	query := fmt.Sprintf(
-		"SELECT token, user_id, expires_at, created_at FROM verification_tokens WHERE token = %s",
-		quoteLiteral(token),
+		"SELECT user_id, expires_at, created_at FROM verification_tokens WHERE token = %s",
+		quoteLiteral(storage.HashToken(token)),
 	)
 
 	row := s.db.QueryRowContext(ctx, query)
-	var vt VerificationToken
+	vt := VerificationToken{Token: token}
 	var expires string
 	var created string
-	if err := row.Scan(&vt.Token, &vt.UserID, &expires, &created); err != nil {
+	if err := row.Scan(&vt.UserID, &expires, &created); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return VerificationToken{}, sql.ErrNoRows
 		}
@@ -819,7 +1761,7 @@ func (s *Store) DeleteVerificationToken(ctx context.Context, token string) error
 		return errors.New("token must not be empty")
 	}
 
-	query := fmt.Sprintf("DELETE FROM verification_tokens WHERE token = %s", quoteLiteral(token))
+	query := fmt.Sprintf("DELETE FROM verification_tokens WHERE token = %s", quoteLiteral(storage.HashToken(token)))
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("delete verification token: %w", err)
 	}
@@ -876,8 +1818,8 @@ func (s *Store) CreatePasswordResetToken(ctx context.Context, token string, user
 
 	created := time.Now().UTC()
 	query := fmt.Sprintf(
-		"INSERT INTO password_reset_tokens(token, user_id, expires_at, created_at) VALUES (%s, %d, %s, %s)",
-		quoteLiteral(token),
+		"INSERT INTO password_reset_tokens(token, user_id, expires_at, created_at, hashed) VALUES (%s, %d, %s, %s, 1)",
+		quoteLiteral(storage.HashToken(token)),
 		userID,
 		quoteLiteral(expiresAt.UTC().Format(time.RFC3339Nano)),
 		quoteLiteral(created.Format(time.RFC3339Nano)),
@@ -900,15 +1842,15 @@ func (s *Store) GetPasswordResetToken(ctx context.Context, token string) (Passwo
 	}
 
 	query := fmt.Sprintf(
-		"SELECT token, user_id, expires_at, created_at FROM password_reset_tokens WHERE token = %s",
-		quoteLiteral(token),
+		"SELECT user_id, expires_at, created_at FROM password_reset_tokens WHERE token = %s",
+		quoteLiteral(storage.HashToken(token)),
 	)
 
 	row := s.db.QueryRowContext(ctx, query)
-	var prt PasswordResetToken
+	prt := PasswordResetToken{Token: token}
 	var expires string
 	var created string
-	if err := row.Scan(&prt.Token, &prt.UserID, &expires, &created); err != nil {
+	if err := row.Scan(&prt.UserID, &expires, &created); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return PasswordResetToken{}, sql.ErrNoRows
 		}
@@ -936,13 +1878,35 @@ func (s *Store) DeletePasswordResetToken(ctx context.Context, token string) erro
 		return errors.New("token must not be empty")
 	}
 
-	query := fmt.Sprintf("DELETE FROM password_reset_tokens WHERE token = %s", quoteLiteral(token))
+	query := fmt.Sprintf("DELETE FROM password_reset_tokens WHERE token = %s", quoteLiteral(storage.HashToken(token)))
 	if _, err := s.db.ExecContext(ctx, query); err != nil {
 		return fmt.Errorf("delete password reset token: %w", err)
 	}
 	return nil
 }
 
+func (s *Store) ConsumePasswordResetToken(ctx context.Context, token string) (PasswordResetToken, error) {
+	record, err := s.GetPasswordResetToken(ctx, token)
+	if err != nil {
+		return PasswordResetToken{}, err
+	}
+
+	// Only the caller whose delete removes the row may use the token.
+	query := fmt.Sprintf("DELETE FROM password_reset_tokens WHERE token = %s", quoteLiteral(storage.HashToken(record.Token)))
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return PasswordResetToken{}, fmt.Errorf("consume password reset token: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return PasswordResetToken{}, fmt.Errorf("rows affected consume password reset token: %w", err)
+	}
+	if affected == 0 {
+		return PasswordResetToken{}, storage.ErrTokenUsed
+	}
+	return record, nil
+}
+
 func (s *Store) DeletePasswordResetTokensForUser(ctx context.Context, userID int64) error {
 	if userID <= 0 {
 		return errors.New("invalid user id")
@@ -982,6 +1946,38 @@ func (s *Store) UpdateUserPassword(ctx context.Context, userID int64, passwordHa
 	return nil
 }
 
+// hashPlaintextTokens replaces tokens of table that were stored as sent with their hashes.
+func hashPlaintextTokens(ctx context.Context, tx *sqltrace.Tx, table string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT token FROM %s WHERE hashed = 0", table))
+	if err != nil {
+		return fmt.Errorf("query plaintext %s: %w", table, err)
+	}
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan plaintext %s: %w", table, err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("iterate plaintext %s: %w", table, err)
+	}
+	for _, token := range tokens {
+		query := fmt.Sprintf(
+			"UPDATE %s SET token = %s, hashed = 1 WHERE token = %s",
+			table,
+			quoteLiteral(storage.HashToken(token)),
+			quoteLiteral(token),
+		)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("hash %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
 func quoteLiteral(value string) string {
 	escaped := strings.ReplaceAll(value, "'", "''")
 	return "'" + escaped + "'"
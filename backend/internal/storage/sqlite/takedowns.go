@@ -0,0 +1,287 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const takedownColumns = "id, claimant_name, claimant_email, work_url, description, status, created_at, resolved_at"
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (s *Store) CreateTakedown(ctx context.Context, takedown Takedown) (created Takedown, err error) {
+	if len(takedown.PixelIDs) == 0 {
+		return Takedown{}, errors.New("takedown must cover at least one pixel")
+	}
+	if takedown.CreatedAt.IsZero() {
+		takedown.CreatedAt = time.Now()
+	}
+	takedown.CreatedAt = takedown.CreatedAt.UTC()
+	takedown.Status = storage.TakedownStatusPending
+	takedown.ResolvedAt = nil
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Takedown{}, fmt.Errorf("begin create takedown: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := fmt.Sprintf(
+		"INSERT INTO takedowns(claimant_name, claimant_email, work_url, description, status, created_at) VALUES (%s, %s, %s, %s, %s, %s)",
+		quoteLiteral(takedown.ClaimantName),
+		quoteLiteral(takedown.ClaimantEmail),
+		quoteLiteral(takedown.WorkURL),
+		quoteLiteral(takedown.Description),
+		quoteLiteral(takedown.Status),
+		quoteLiteral(takedown.CreatedAt.Format(time.RFC3339Nano)),
+	)
+	res, execErr := tx.ExecContext(ctx, query)
+	if execErr != nil {
+		err = fmt.Errorf("insert takedown: %w", execErr)
+		return Takedown{}, err
+	}
+	if takedown.ID, err = res.LastInsertId(); err != nil {
+		err = fmt.Errorf("takedown id: %w", err)
+		return Takedown{}, err
+	}
+	for _, pixelID := range takedown.PixelIDs {
+		query := fmt.Sprintf("INSERT OR IGNORE INTO takedown_pixels(takedown_id, pixel_id) VALUES (%d, %d)", takedown.ID, pixelID)
+		if _, execErr := tx.ExecContext(ctx, query); execErr != nil {
+			err = fmt.Errorf("insert takedown pixel: %w", execErr)
+			return Takedown{}, err
+		}
+	}
+	if err = insertTakedownEvent(ctx, tx, takedown.ID, "submitted", nil, "", takedown.CreatedAt); err != nil {
+		return Takedown{}, err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit takedown: %w", commitErr)
+		return Takedown{}, err
+	}
+	return takedown, nil
+}
+
+func (s *Store) GetTakedown(ctx context.Context, id int64) (Takedown, error) {
+	takedowns, err := loadTakedowns(ctx, s.db, fmt.Sprintf("SELECT %s FROM takedowns WHERE id = %d", takedownColumns, id))
+	if err != nil {
+		return Takedown{}, err
+	}
+	if len(takedowns) == 0 {
+		return Takedown{}, sql.ErrNoRows
+	}
+	return takedowns[0], nil
+}
+
+func (s *Store) ListTakedowns(ctx context.Context, status string) ([]Takedown, error) {
+	where := ""
+	if status != "" {
+		where = " WHERE status = " + quoteLiteral(status)
+	}
+	return loadTakedowns(ctx, s.db, fmt.Sprintf("SELECT %s FROM takedowns%s ORDER BY created_at, id", takedownColumns, where))
+}
+
+func (s *Store) ResolveTakedown(ctx context.Context, id int64, status string, actorID int64, note string) (resolved Takedown, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Takedown{}, fmt.Errorf("begin resolve takedown: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC()
+	query := fmt.Sprintf(
+		"UPDATE takedowns SET status = %s, resolved_at = %s WHERE id = %d AND status = %s",
+		quoteLiteral(status),
+		quoteLiteral(now.Format(time.RFC3339Nano)),
+		id,
+		quoteLiteral(storage.TakedownStatusPending),
+	)
+	res, execErr := tx.ExecContext(ctx, query)
+	if execErr != nil {
+		err = fmt.Errorf("resolve takedown: %w", execErr)
+		return Takedown{}, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		var exists int
+		if scanErr := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(1) FROM takedowns WHERE id = %d", id)).Scan(&exists); scanErr != nil {
+			err = fmt.Errorf("check takedown: %w", scanErr)
+			return Takedown{}, err
+		}
+		if exists == 0 {
+			err = sql.ErrNoRows
+		} else {
+			err = storage.ErrTakedownNotPending
+		}
+		return Takedown{}, err
+	}
+	if err = insertTakedownEvent(ctx, tx, id, status, &actorID, note, now); err != nil {
+		return Takedown{}, err
+	}
+
+	takedowns, loadErr := loadTakedowns(ctx, tx, fmt.Sprintf("SELECT %s FROM takedowns WHERE id = %d", takedownColumns, id))
+	if loadErr != nil {
+		err = loadErr
+		return Takedown{}, err
+	}
+	if commitErr := tx.Commit(); commitErr != nil {
+		err = fmt.Errorf("commit resolve takedown: %w", commitErr)
+		return Takedown{}, err
+	}
+	return takedowns[0], nil
+}
+
+func (s *Store) ListTakedownEvents(ctx context.Context, takedownID int64) ([]TakedownEvent, error) {
+	query := fmt.Sprintf("SELECT id, takedown_id, action, actor_id, note, created_at FROM takedown_events WHERE takedown_id = %d ORDER BY id", takedownID)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query takedown events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]TakedownEvent, 0)
+	for rows.Next() {
+		var event TakedownEvent
+		var actor sql.NullInt64
+		var created string
+		if err := rows.Scan(&event.ID, &event.TakedownID, &event.Action, &actor, &event.Note, &created); err != nil {
+			return nil, fmt.Errorf("scan takedown event: %w", err)
+		}
+		if actor.Valid {
+			id := actor.Int64
+			event.ActorID = &id
+		}
+		if event.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse takedown event created_at: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedown events: %w", err)
+	}
+	return events, nil
+}
+
+func (s *Store) ListHiddenPixelIDs(ctx context.Context) ([]int, error) {
+	query := fmt.Sprintf(
+		"SELECT DISTINCT p.pixel_id FROM takedown_pixels p JOIN takedowns t ON t.id = p.takedown_id WHERE t.status IN (%s, %s) ORDER BY p.pixel_id",
+		quoteLiteral(storage.TakedownStatusPending),
+		quoteLiteral(storage.TakedownStatusUpheld),
+	)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query hidden pixels: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan hidden pixel: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate hidden pixels: %w", err)
+	}
+	return ids, nil
+}
+
+func insertTakedownEvent(ctx context.Context, tx *sqltrace.Tx, takedownID int64, action string, actorID *int64, note string, at time.Time) error {
+	actor := "NULL"
+	if actorID != nil {
+		actor = strconv.FormatInt(*actorID, 10)
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO takedown_events(takedown_id, action, actor_id, note, created_at) VALUES (%d, %s, %s, %s, %s)",
+		takedownID,
+		quoteLiteral(action),
+		actor,
+		quoteLiteral(note),
+		quoteLiteral(at.UTC().Format(time.RFC3339Nano)),
+	)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("insert takedown event: %w", err)
+	}
+	return nil
+}
+
+// loadTakedowns runs query, which must select takedownColumns, and attaches the covered pixel ids.
+func loadTakedowns(ctx context.Context, db queryer, query string) ([]Takedown, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query takedowns: %w", err)
+	}
+	defer rows.Close()
+
+	takedowns := make([]Takedown, 0)
+	index := make(map[int64]int)
+	ids := make([]string, 0)
+	for rows.Next() {
+		var takedown Takedown
+		var created string
+		var resolved sql.NullString
+		if err := rows.Scan(&takedown.ID, &takedown.ClaimantName, &takedown.ClaimantEmail, &takedown.WorkURL, &takedown.Description, &takedown.Status, &created, &resolved); err != nil {
+			return nil, fmt.Errorf("scan takedown: %w", err)
+		}
+		if takedown.CreatedAt, err = parseUpdatedAt(created); err != nil {
+			return nil, fmt.Errorf("parse takedown created_at: %w", err)
+		}
+		if resolved.Valid && resolved.String != "" {
+			parsed, err := parseUpdatedAt(resolved.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse takedown resolved_at: %w", err)
+			}
+			takedown.ResolvedAt = &parsed
+		}
+		takedown.PixelIDs = make([]int, 0)
+		index[takedown.ID] = len(takedowns)
+		ids = append(ids, strconv.FormatInt(takedown.ID, 10))
+		takedowns = append(takedowns, takedown)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedowns: %w", err)
+	}
+	rows.Close()
+	if len(takedowns) == 0 {
+		return takedowns, nil
+	}
+
+	pixelRows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT takedown_id, pixel_id FROM takedown_pixels WHERE takedown_id IN (%s) ORDER BY pixel_id", strings.Join(ids, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("query takedown pixels: %w", err)
+	}
+	defer pixelRows.Close()
+	for pixelRows.Next() {
+		var takedownID int64
+		var pixelID int
+		if err := pixelRows.Scan(&takedownID, &pixelID); err != nil {
+			return nil, fmt.Errorf("scan takedown pixel: %w", err)
+		}
+		if i, ok := index[takedownID]; ok {
+			takedowns[i].PixelIDs = append(takedowns[i].PixelIDs, pixelID)
+		}
+	}
+	if err := pixelRows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate takedown pixels: %w", err)
+	}
+	return takedowns, nil
+}
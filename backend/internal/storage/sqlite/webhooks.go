@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func (s *Store) GetOwnerWebhook(ctx context.Context, userID int64) (storage.OwnerWebhook, error) {
+	query := fmt.Sprintf("SELECT user_id, url, secret, created_at, updated_at FROM owner_webhooks WHERE user_id = %d", userID)
+	var webhook storage.OwnerWebhook
+	var created, updated string
+	if err := s.db.QueryRowContext(ctx, query).Scan(&webhook.UserID, &webhook.URL, &webhook.Secret, &created, &updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.OwnerWebhook{}, sql.ErrNoRows
+		}
+		return storage.OwnerWebhook{}, fmt.Errorf("get owner webhook: %w", err)
+	}
+	var err error
+	if webhook.CreatedAt, err = parseUpdatedAt(created); err != nil {
+		return storage.OwnerWebhook{}, fmt.Errorf("parse owner webhook created_at: %w", err)
+	}
+	if webhook.UpdatedAt, err = parseUpdatedAt(updated); err != nil {
+		return storage.OwnerWebhook{}, fmt.Errorf("parse owner webhook updated_at: %w", err)
+	}
+	return webhook, nil
+}
+
+func (s *Store) PutOwnerWebhook(ctx context.Context, webhook storage.OwnerWebhook) (storage.OwnerWebhook, error) {
+	now := quoteLiteral(time.Now().UTC().Format(time.RFC3339Nano))
+	query := fmt.Sprintf(
+		"INSERT INTO owner_webhooks(user_id, url, secret, created_at, updated_at) VALUES (%d, %s, %s, %s, %s) ON CONFLICT(user_id) DO UPDATE SET url = excluded.url, secret = excluded.secret, updated_at = excluded.updated_at",
+		webhook.UserID,
+		quoteLiteral(webhook.URL),
+		quoteLiteral(webhook.Secret),
+		now,
+		now,
+	)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return storage.OwnerWebhook{}, fmt.Errorf("put owner webhook: %w", err)
+	}
+	return s.GetOwnerWebhook(ctx, webhook.UserID)
+}
+
+func (s *Store) DeleteOwnerWebhook(ctx context.Context, userID int64) error {
+	query := fmt.Sprintf("DELETE FROM owner_webhooks WHERE user_id = %d", userID)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("delete owner webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package sqltrace wraps database/sql handles so statements that exceed a configurable
+// duration can be reported without leaking the values they were executed with.
+package sqltrace
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SlowQuery describes a statement that took longer than the configured threshold.
+// Query has all literal values replaced by '?' and Args is the number of bound parameters.
+type SlowQuery struct {
+	Query   string
+	Args    int
+	Elapsed time.Duration
+}
+
+// Hook receives slow statements. It is called synchronously and must be cheap.
+type Hook func(SlowQuery)
+
+type statsKey struct{}
+
+// Stats accumulates the statements executed with a context returned by WithStats,
+// typically everything a single HTTP request asked the store to do.
+type Stats struct {
+	mu           sync.Mutex
+	statements   int
+	transactions int
+	total        time.Duration
+	slowest      time.Duration
+	slowestQuery string
+}
+
+// WithStats returns a context whose statements are recorded in the returned Stats.
+func WithStats(ctx context.Context) (context.Context, *Stats) {
+	stats := &Stats{}
+	return context.WithValue(ctx, statsKey{}, stats), stats
+}
+
+func statsFrom(ctx context.Context) *Stats {
+	if ctx == nil {
+		return nil
+	}
+	stats, _ := ctx.Value(statsKey{}).(*Stats)
+	return stats
+}
+
+func (s *Stats) record(query string, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements++
+	s.total += elapsed
+	if elapsed >= s.slowest {
+		s.slowest = elapsed
+		s.slowestQuery = query
+	}
+}
+
+func (s *Stats) beginTx() {
+	s.mu.Lock()
+	s.transactions++
+	s.mu.Unlock()
+}
+
+// Statements returns the number of recorded statements and their combined duration.
+func (s *Stats) Statements() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statements, s.total
+}
+
+// Transactions returns the number of transactions begun with the context.
+func (s *Stats) Transactions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transactions
+}
+
+// Summary renders the recorded statements as "statements=3 tx=1 total=1.2ms slowest=800µs",
+// followed by the redacted slowest statement.
+func (s *Stats) Summary() (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary := fmt.Sprintf("statements=%d tx=%d total=%s slowest=%s", s.statements, s.transactions, s.total, s.slowest)
+	if s.slowestQuery == "" {
+		return summary, ""
+	}
+	return summary, Redact(s.slowestQuery)
+}
+
+type tracer struct {
+	threshold atomic.Int64
+	hook      atomic.Pointer[Hook]
+	now       func() time.Time
+}
+
+func (t *tracer) observe(ctx context.Context, query string, args int, started time.Time) {
+	stats := statsFrom(ctx)
+	threshold := time.Duration(t.threshold.Load())
+	hook := t.hook.Load()
+	if stats == nil && (threshold <= 0 || hook == nil) {
+		return
+	}
+	elapsed := t.now().Sub(started)
+	if stats != nil {
+		stats.record(query, elapsed)
+	}
+	if threshold <= 0 || hook == nil || elapsed < threshold {
+		return
+	}
+	(*hook)(SlowQuery{Query: Redact(query), Args: args, Elapsed: elapsed})
+}
+
+// DB is a *sql.DB whose context-aware statement methods are timed.
+type DB struct {
+	*sql.DB
+	tracer *tracer
+}
+
+// Wrap returns a traced handle for db. Reporting stays disabled until SetSlowQueryHook is called.
+func Wrap(db *sql.DB) *DB {
+	return &DB{DB: db, tracer: &tracer{now: time.Now}}
+}
+
+// SetSlowQueryHook reports every statement slower than threshold to hook.
+// A zero threshold or nil hook disables reporting.
+func (db *DB) SetSlowQueryHook(threshold time.Duration, hook Hook) {
+	db.tracer.threshold.Store(int64(threshold))
+	if hook == nil {
+		db.tracer.hook.Store(nil)
+		return
+	}
+	db.tracer.hook.Store(&hook)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer db.tracer.observe(ctx, query, len(args), db.tracer.now())
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer db.tracer.observe(ctx, query, len(args), db.tracer.now())
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer db.tracer.observe(ctx, query, len(args), db.tracer.now())
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if stats := statsFrom(ctx); stats != nil {
+		stats.beginTx()
+	}
+	return &Tx{Tx: tx, tracer: db.tracer, ctx: ctx}, nil
+}
+
+// Tx is a *sql.Tx whose statements and commit are timed with the tracer of its DB.
+type Tx struct {
+	*sql.Tx
+	tracer *tracer
+	ctx    context.Context
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer tx.tracer.observe(ctx, query, len(args), tx.tracer.now())
+	return tx.Tx.ExecContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	defer tx.tracer.observe(ctx, query, len(args), tx.tracer.now())
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer tx.tracer.observe(ctx, query, len(args), tx.tracer.now())
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// Commit is timed as well because lock waits on busy tables usually surface there.
+func (tx *Tx) Commit() error {
+	defer tx.tracer.observe(tx.ctx, "COMMIT", 0, tx.tracer.now())
+	return tx.Tx.Commit()
+}
+
+// Redact replaces string and numeric literals in query with '?' and collapses whitespace,
+// so statements built with inlined values can be logged without exposing user data.
+func Redact(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		switch {
+		case ch == '\'' || ch == '"':
+			// Skip to the closing quote; doubled quotes are escapes inside the literal.
+			i++
+			for i < len(query) {
+				if query[i] == ch {
+					if i+1 < len(query) && query[i+1] == ch {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			b.WriteByte('?')
+		case isDigit(ch) && !continuesIdentifier(query, i):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func continuesIdentifier(query string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	prev := query[i-1]
+	return prev == '_' || isDigit(prev) || (prev|0x20 >= 'a' && prev|0x20 <= 'z')
+}
@@ -0,0 +1,152 @@
+package sqltrace
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+)
+
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) { return stubConn{}, nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{}, nil }
+func (stubConn) Close() error                              { return nil }
+func (stubConn) Begin() (driver.Tx, error)                 { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct{}
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (stubStmt) Query([]driver.Value) (driver.Rows, error)  { return stubRows{}, nil }
+
+type stubRows struct{}
+
+func (stubRows) Columns() []string         { return []string{"n"} }
+func (stubRows) Close() error              { return nil }
+func (stubRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("sqltrace-stub", stubDriver{})
+}
+
+// newTestDB returns a traced handle whose clock advances by step on every reading.
+func newTestDB(t *testing.T, step time.Duration) *DB {
+	t.Helper()
+	raw, err := sql.Open("sqltrace-stub", "")
+	if err != nil {
+		t.Fatalf("open stub db: %v", err)
+	}
+	t.Cleanup(func() { raw.Close() })
+
+	db := Wrap(raw)
+	current := time.Unix(0, 0)
+	db.tracer.now = func() time.Time {
+		current = current.Add(step)
+		return current
+	}
+	return db
+}
+
+func TestSlowStatementsAreReported(t *testing.T) {
+	db := newTestDB(t, 300*time.Millisecond)
+	var reported []SlowQuery
+	db.SetSlowQueryHook(250*time.Millisecond, func(q SlowQuery) { reported = append(reported, q) })
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "UPDATE users SET points = points - 10 WHERE email = 'a@b.pl'"); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE pixels SET color = ? WHERE id = ?", "#fff", 5); err != nil {
+		t.Fatalf("tx.ExecContext() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	if len(reported) != 3 {
+		t.Fatalf("expected 3 slow statements, got %d: %+v", len(reported), reported)
+	}
+	if got := reported[0].Query; got != "UPDATE users SET points = points - ? WHERE email = ?" {
+		t.Fatalf("literals not redacted: %q", got)
+	}
+	if reported[1].Args != 2 || reported[1].Elapsed != 300*time.Millisecond {
+		t.Fatalf("unexpected tx report: %+v", reported[1])
+	}
+	if reported[2].Query != "COMMIT" {
+		t.Fatalf("expected commit to be timed, got %q", reported[2].Query)
+	}
+}
+
+func TestFastStatementsAndDisabledHookAreIgnored(t *testing.T) {
+	db := newTestDB(t, 10*time.Millisecond)
+	calls := 0
+	db.SetSlowQueryHook(250*time.Millisecond, func(SlowQuery) { calls++ })
+	_, _ = db.ExecContext(context.Background(), "DELETE FROM sessions")
+
+	slow := newTestDB(t, time.Second)
+	slow.SetSlowQueryHook(0, func(SlowQuery) { calls++ })
+	_, _ = slow.ExecContext(context.Background(), "DELETE FROM sessions")
+
+	if calls != 0 {
+		t.Fatalf("expected no reports, got %d", calls)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"SELECT * FROM users WHERE email = 'o''neil@x.pl'", "SELECT * FROM users WHERE email = ?"},
+		{"SELECT id FROM pixels WHERE id IN (1, 22, 333) AND price > 1.5", "SELECT id FROM pixels WHERE id IN (?, ?, ?) AND price > ?"},
+		{"INSERT INTO t2 (col_1)\n   VALUES (?)", "INSERT INTO t2 (col_1) VALUES (?)"},
+		{"  PRAGMA busy_timeout=5000 ", "PRAGMA busy_timeout=?"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStatsCollectStatementsOfContext(t *testing.T) {
+	db := newTestDB(t, 5*time.Millisecond)
+	ctx, stats := WithStats(context.Background())
+
+	_, _ = db.ExecContext(ctx, "UPDATE users SET points = 5 WHERE id = 1")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	_, _ = tx.ExecContext(ctx, "DELETE FROM sessions")
+	_ = tx.Commit()
+	_, _ = db.ExecContext(context.Background(), "DELETE FROM other")
+
+	count, total := stats.Statements()
+	if count != 3 || total != 15*time.Millisecond || stats.Transactions() != 1 {
+		t.Fatalf("unexpected stats: count=%d total=%s tx=%d", count, total, stats.Transactions())
+	}
+	summary, slowest := stats.Summary()
+	if summary != "statements=3 tx=1 total=15ms slowest=5ms" {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+	if slowest != "COMMIT" {
+		t.Fatalf("unexpected slowest statement %q", slowest)
+	}
+}
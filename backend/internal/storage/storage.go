@@ -2,8 +2,13 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"strings"
 	"time"
+
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
 )
 
 const (
@@ -12,13 +17,23 @@ const (
 	TotalPixels = GridWidth * GridHeight
 )
 
+// MainBoard is the board_id of the pixels of the main grid. Further boards share the pixels table
+// under the ids given in the config; every Store method without a boardID works on the main grid.
+const MainBoard = ""
+
 type Pixel struct {
-	ID        int       `json:"id"`
-	Status    string    `json:"status"`
-	Color     string    `json:"color,omitempty"`
-	URL       string    `json:"url,omitempty"`
-	OwnerID   *int64    `json:"owner_id,omitempty"`
-	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Color  string `json:"color,omitempty"`
+	URL    string `json:"url,omitempty"`
+	// Title and Description are optional texts the owner shows in the pixel's tooltip.
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	OwnerID     *int64    `json:"owner_id,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	// ExpiresAt is set for rented pixels; the pixel is freed once it passes. Only the owner's
+	// pixel list and purchase results carry it.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 type User struct {
@@ -38,6 +53,13 @@ type VerificationToken struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// HashToken returns the form verification and password reset tokens are stored in, so a leaked
+// database holds no usable links. Stores take and return the tokens as sent.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 type PasswordResetToken struct {
 	Token     string    `json:"token"`
 	UserID    int64     `json:"user_id"`
@@ -45,31 +67,524 @@ type PasswordResetToken struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type ActivationCode struct {
+	Code       string `json:"code"`
+	Value      int64  `json:"value"`
+	CampaignID string `json:"campaign_id,omitempty"`
+	BatchID    string `json:"batch_id,omitempty"`
+}
+
+// ActivationCodeBatch describes a set of codes minted together for a promotional campaign.
+type ActivationCodeBatch struct {
+	CampaignID string
+	BatchID    string
+	Value      int64
+	Codes      []string
+}
+
+type CampaignBatchStats struct {
+	BatchID       string `json:"batch_id"`
+	CodesIssued   int64  `json:"codes_issued"`
+	CodesRedeemed int64  `json:"codes_redeemed"`
+	PointsIssued  int64  `json:"points_issued"`
+}
+
+// CampaignStats summarises how activation codes of a campaign were used.
+type CampaignStats struct {
+	CampaignID      string               `json:"campaign_id"`
+	CodesIssued     int64                `json:"codes_issued"`
+	CodesRedeemed   int64                `json:"codes_redeemed"`
+	RedemptionRate  float64              `json:"redemption_rate"`
+	PointsIssued    int64                `json:"points_issued"`
+	RedeemingUsers  int64                `json:"redeeming_users"`
+	PixelsPurchased int64                `json:"pixels_purchased"`
+	PurchasingUsers int64                `json:"purchasing_users"`
+	Batches         []CampaignBatchStats `json:"batches"`
+}
+
+// Payment statuses.
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCompleted = "completed"
+	PaymentStatusFailed    = "failed"
+)
+
+// Payment records the purchase of a points bundle in a real currency. Points are fixed when the
+// payment is created so later bundle price changes do not affect payments in flight.
+type Payment struct {
+	ID          string     `json:"id"`
+	UserID      int64      `json:"user_id"`
+	BundleID    string     `json:"bundle_id"`
+	Currency    string     `json:"currency"`
+	AmountMinor int64      `json:"amount_minor"`
+	Points      int64      `json:"points"`
+	Status      string     `json:"status"`
+	ProviderRef string     `json:"provider_ref,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Points ledger entry kinds: what changed a user's balance.
+const (
+	// LedgerOpeningBalance carries over a balance that predates the ledger.
+	LedgerOpeningBalance = "opening_balance"
+	LedgerActivationCode = "activation_code"
+	LedgerPayment        = "payment"
+	LedgerPurchase       = "purchase"
+	LedgerRelease        = "release"
+	LedgerReviewRefund   = "review_refund"
+	LedgerAdminRefund    = "admin_refund"
+	// LedgerRepair is a correction made by the consistency check.
+	LedgerRepair = "repair"
+)
+
+// LedgerEntry is one change of a user's points balance. Reference names what caused it: the
+// activation code, payment or refund id, or for purchases the price version that applied.
+type LedgerEntry struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Delta     int64     `json:"delta"`
+	Kind      string    `json:"kind"`
+	Reference string    `json:"reference,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BalanceDrift is a user whose users.user_points disagrees with their points ledger.
+type BalanceDrift struct {
+	UserID int64 `json:"user_id"`
+	// Balance is the stored balance and Ledger the sum of the user's ledger entries.
+	Balance int64 `json:"balance"`
+	Ledger  int64 `json:"ledger"`
+}
+
+// AgeAttestation records a user's declared birth year and the minimum age they confirmed.
+type AgeAttestation struct {
+	UserID     int64     `json:"-"`
+	BirthYear  int       `json:"birth_year"`
+	MinimumAge int       `json:"minimum_age"`
+	AttestedAt time.Time `json:"attested_at"`
+}
+
+// PixelLicense is the rights declaration a buyer attached to the region of pixels bought in one purchase.
+type PixelLicense struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	PixelIDs     []int     `json:"pixel_ids"`
+	ArtworkOwner string    `json:"artwork_owner"`
+	Contact      string    `json:"contact"`
+	Statement    string    `json:"statement,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Takedown statuses. Pixels of pending and upheld takedowns are hidden from the public grid.
+const (
+	TakedownStatusPending  = "pending"
+	TakedownStatusUpheld   = "upheld"
+	TakedownStatusRejected = "rejected"
+)
+
+// Takedown is a copyright takedown request against a region of pixels.
+type Takedown struct {
+	ID            int64      `json:"id"`
+	PixelIDs      []int      `json:"pixel_ids"`
+	ClaimantName  string     `json:"claimant_name"`
+	ClaimantEmail string     `json:"claimant_email"`
+	WorkURL       string     `json:"work_url,omitempty"`
+	Description   string     `json:"description"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+// TakedownEvent is an entry in a takedown's resolution log.
+type TakedownEvent struct {
+	ID         int64     `json:"id"`
+	TakedownID int64     `json:"takedown_id"`
+	Action     string    `json:"action"`
+	ActorID    *int64    `json:"actor_id,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PixelClicks is the number of redirects through a pixel's tracked link while its owner held it.
+type PixelClicks struct {
+	PixelID int   `json:"pixel_id"`
+	Clicks  int64 `json:"clicks"`
+}
+
+// PixelClickDay is one day of clicks on a pixel's tracked link.
+type PixelClickDay struct {
+	// Day is the UTC date as YYYY-MM-DD.
+	Day      string `json:"day"`
+	Clicks   int64  `json:"clicks"`
+	Visitors int64  `json:"unique_visitors"`
+}
+
+// PixelChange is an entry in a pixel's history: the pixel's status, color, link and owner after a
+// change to any of them, and the owner it had before.
+type PixelChange struct {
+	ID              int64     `json:"id"`
+	PixelID         int       `json:"pixel_id"`
+	Status          string    `json:"status"`
+	Color           string    `json:"color"`
+	URL             string    `json:"url"`
+	OwnerID         *int64    `json:"owner_id,omitempty"`
+	PreviousOwnerID *int64    `json:"previous_owner_id,omitempty"`
+	ChangedAt       time.Time `json:"changed_at"`
+}
+
+// OwnerWebhook is the endpoint a user registered to be notified about events on their pixels.
+// Secret signs the deliveries and is only shown to the user when it is generated.
+type OwnerWebhook struct {
+	UserID    int64     `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PushDevice is a mobile device registered for push notifications of a user.
+type PushDevice struct {
+	Token      string    `json:"token"`
+	UserID     int64     `json:"user_id"`
+	Platform   string    `json:"platform"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// BusinessStats are the totals exported as Prometheus gauges.
+type BusinessStats struct {
+	// PixelsTaken and PixelsRented count the pixels of every board.
+	PixelsTaken   int64
+	PixelsRented  int64
+	Users         int64
+	UsersVerified int64
+	// PointsOutstanding is the sum of the users' unspent points.
+	PointsOutstanding int64
+}
+
+// CanvasStats summarise the board for the public statistics page.
+type CanvasStats struct {
+	PixelsTaken int64
+	PixelsFree  int64
+	// Owners counts the distinct users holding at least one pixel.
+	Owners int64
+	// PointsSpent sums what was paid for the pixels currently held.
+	PointsSpent int64
+	// RecentPurchases are the latest changes that gave a pixel a new owner, newest first.
+	RecentPurchases []PixelChange
+}
+
+// Orders of ListTopOwners.
+const (
+	LeaderboardByPixels = "pixels"
+	LeaderboardByPoints = "points"
+)
+
+// OwnerRank is one owner's standing on the leaderboard. PointsSpent sums what was paid for the
+// pixels they currently hold.
+type OwnerRank struct {
+	OwnerID     int64
+	Pixels      int64
+	PointsSpent int64
+}
+
+// InviteCode admits one registration while sign-up is invite-only. Admins mint them in batches
+// (BatchID) and verified users create their own to share (CreatedBy).
+type InviteCode struct {
+	Code      string     `json:"code"`
+	BatchID   string     `json:"batch_id,omitempty"`
+	CreatedBy *int64     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedBy    *int64     `json:"used_by,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// EngagementReportSubscription is a user's opt-in to the weekly engagement report email. Token
+// only serves the unsubscribe link of the emails.
+type EngagementReportSubscription struct {
+	UserID     int64      `json:"-"`
+	Token      string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+}
+
+// PixelRelease is the outcome of ReleasePixelsForUser.
+type PixelRelease struct {
+	// Pixels are the freed pixels as they were before, with their last owner.
+	Pixels         []Pixel
+	RefundedPoints int64
+	User           User
+}
+
+// PixelRefund records an admin refund: pixels taken back from a user and the points credited to
+// them, with the reason, e.g. a chargeback or a moderation decision.
+type PixelRefund struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	AdminID   int64     `json:"admin_id"`
+	PixelIDs  []int     `json:"pixel_ids"`
+	Points    int64     `json:"points"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AdminActionForceFree is the audit trail action of an admin freeing pixels regardless of
+// their owners.
+const AdminActionForceFree = "force_free"
+
+// AdminAction is an entry of the admin audit trail: an action that bypassed the usual ownership
+// checks, the pixels it changed and why. Target describes what the admin selected, e.g. the
+// domain whose pixels were freed.
+type AdminAction struct {
+	ID        int64     `json:"id"`
+	AdminID   int64     `json:"admin_id"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	PixelIDs  []int     `json:"pixel_ids"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PixelHold reserves a free pixel for a user until ExpiresAt, so nobody else can buy it while the
+// user finishes the purchase.
+type PixelHold struct {
+	PixelID   int       `json:"pixel_id"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CustomDomain is a domain pointed at the service by a user. It is routed only once its DNS TXT
+// challenge was found: with a PixelID it redirects visitors to that pixel's link, without one (for
+// admins) it serves the board itself.
+type CustomDomain struct {
+	ID            int64      `json:"id"`
+	Domain        string     `json:"domain"`
+	UserID        int64      `json:"user_id"`
+	PixelID       *int       `json:"pixel_id,omitempty"`
+	Token         string     `json:"token"`
+	VerifiedAt    *time.Time `json:"verified_at,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// PixelDraft is a pixel design a user saved without buying it. It holds no points and reserves
+// no pixels; the pixels may be taken by others in the meantime.
+type PixelDraft struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	Name      string       `json:"name"`
+	Pixels    []DraftPixel `json:"pixels"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// DraftPixel is one pixel of a draft with the content it would be bought with.
+type DraftPixel struct {
+	ID          int    `json:"id"`
+	Color       string `json:"color"`
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Pixel review decisions.
+const (
+	PixelReviewApproved = "approved"
+	PixelReviewRejected = "rejected"
+)
+
+// PixelReview is a pixel waiting in the moderation queue with the content its owner gave it. The
+// public grid shows the pixel as free until an admin approves it.
+type PixelReview struct {
+	PixelID     int       `json:"pixel_id"`
+	UserID      int64     `json:"user_id"`
+	Color       string    `json:"color"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	QueuedAt    time.Time `json:"queued_at"`
+	// RefundedPoints is what the owner got back for a rejected pixel.
+	RefundedPoints int64 `json:"refunded_points,omitempty"`
+}
+
+// Anomaly kinds reported by CheckConsistency.
+const (
+	// AnomalyOrphanedPixels are pixels owned by a user that no longer exists; repairing frees them.
+	AnomalyOrphanedPixels = "orphaned_pixels"
+	// AnomalyLedgerMismatches are users whose balance differs from the sum of their points ledger;
+	// repairing sets the balance to the ledger's.
+	AnomalyLedgerMismatches = "ledger_mismatches"
+	// AnomalyNegativeBalances are users with fewer than zero points; repairing resets them to zero.
+	AnomalyNegativeBalances = "negative_balances"
+	// AnomalyOrphanedVerificationTokens and AnomalyOrphanedPasswordResetTokens are tokens of
+	// missing users; repairing deletes them.
+	AnomalyOrphanedVerificationTokens  = "orphaned_verification_tokens"
+	AnomalyOrphanedPasswordResetTokens = "orphaned_password_reset_tokens"
+)
+
+// Anomaly is one kind of inconsistency found in the database.
+type Anomaly struct {
+	Kind string `json:"kind"`
+	// IDs are the affected pixel ids for orphaned pixels and the user ids otherwise.
+	IDs      []int64 `json:"ids"`
+	Repaired int64   `json:"repaired"`
+}
+
+// Contact message statuses.
+const (
+	ContactStatusOpen     = "open"
+	ContactStatusAnswered = "answered"
+)
+
+// ContactMessage is a message sent through the contact form, linked to the sender's account when logged in.
+type ContactMessage struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContactReply is an admin's answer to a ContactMessage, delivered by email.
+type ContactReply struct {
+	ID        int64     `json:"id"`
+	MessageID int64     `json:"message_id"`
+	AdminID   int64     `json:"admin_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type PixelState struct {
 	Width  int     `json:"width"`
 	Height int     `json:"height"`
 	Pixels []Pixel `json:"pixels"`
 }
 
+// PixelUpdateOutcome is the result for one pixel of a batch update. Err is set when the pixel was
+// skipped (sql.ErrNoRows, ErrPixelOwnedByAnotherUser, ErrPixelHeld, ErrInsufficientPoints or
+// ErrPixelQuotaExceeded) and Pixel otherwise.
+type PixelUpdateOutcome struct {
+	Pixel Pixel
+	Err   error
+}
+
 var (
 	ErrPixelOwnedByAnotherUser = errors.New("pixel owned by another user")
 	ErrInsufficientPoints      = errors.New("insufficient points")
+	ErrPixelHeld               = errors.New("pixel reserved by another user")
+	ErrPixelQuotaExceeded      = errors.New("pixel quota exceeded")
+	ErrPaymentNotPending       = errors.New("payment is not pending")
+	ErrTakedownNotPending      = errors.New("takedown is not pending")
+	ErrTokenUsed               = errors.New("token already used")
+	ErrDomainTaken             = errors.New("domain already registered")
+	ErrInviteCodeInvalid       = errors.New("invite code is unknown or used")
+	ErrInviteLimitReached      = errors.New("invite limit reached")
 )
 
+// ExpectedIndexes lists the secondary indexes both drivers create. Lookups by owner, the pixel
+// delta feed, email lookups and token cleanup depend on them, so startup warns when one is missing.
+var ExpectedIndexes = []string{
+	"idx_pixels_status",
+	"idx_pixels_owner",
+	"idx_pixels_updated_at",
+	"idx_users_email_lower",
+	"idx_activation_codes_campaign",
+	"idx_verification_tokens_user",
+	"idx_verification_tokens_expires",
+	"idx_password_reset_tokens_user",
+	"idx_password_reset_tokens_expires",
+	"idx_payments_user",
+	"idx_pixel_license_pixels_pixel",
+	"idx_pixel_clicks_owner",
+	"idx_used_link_nonces_expires",
+	"idx_pixel_history_pixel",
+	"idx_pixel_history_owner",
+	"idx_pixel_history_previous_owner",
+	"idx_push_devices_user",
+	"idx_pixels_expires_at",
+	"idx_pixel_holds_user",
+	"idx_custom_domains_user",
+	"idx_pixel_refunds_user",
+	"idx_pixel_drafts_user",
+	"idx_pixel_reviews_user",
+}
+
+// MissingIndexes returns the entries of ExpectedIndexes that are not in present.
+func MissingIndexes(present []string) []string {
+	have := make(map[string]bool, len(present))
+	for _, name := range present {
+		have[strings.ToLower(name)] = true
+	}
+	var missing []string
+	for _, name := range ExpectedIndexes {
+		if !have[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
 type Store interface {
 	Close() error
+	// Ping verifies the database connection is alive.
+	Ping(ctx context.Context) error
 	EnsureSchema(ctx context.Context) error
+	// MissingIndexes reports which of ExpectedIndexes the database lacks.
+	MissingIndexes(ctx context.Context) ([]string, error)
+	// GetBusinessStats counts taken and rented pixels, users and unspent points.
+	GetBusinessStats(ctx context.Context) (BusinessStats, error)
+	// GetCanvasStats aggregates the board's totals and returns up to recent of its latest purchases.
+	GetCanvasStats(ctx context.Context, recent int) (CanvasStats, error)
+	// CheckConsistency looks for every anomaly kind, in a fixed order, and repairs what it finds
+	// when repair is set.
+	CheckConsistency(ctx context.Context, repair bool) ([]Anomaly, error)
 	SetSkipPixelSeed(skip bool)
+	// SetPixelQuota sets how many pixels one account may own; 0 means no limit. Buying or being
+	// gifted a pixel beyond it fails with ErrPixelQuotaExceeded. Accounts with an override from
+	// SetUserPixelQuota use that instead.
+	SetPixelQuota(maxPixels int)
+	// SetSlowQueryHook reports statements that take at least threshold; zero disables reporting.
+	SetSlowQueryHook(threshold time.Duration, hook sqltrace.Hook)
 	InsertPixel(ctx context.Context, pixel Pixel) error
 	GetAllPixels(ctx context.Context) (PixelState, error)
+	// GetPixelsModifiedSince returns the pixels updated at or after since, ordered by id.
+	GetPixelsModifiedSince(ctx context.Context, since time.Time) ([]Pixel, error)
+	// GetPixelsInRect returns the pixels of the width×height rectangle whose top-left cell is
+	// (x, y), ordered by id.
+	GetPixelsInRect(ctx context.Context, x, y, width, height int) ([]Pixel, error)
+	// SearchPixels returns up to limit taken pixels whose title, description or link contain words
+	// starting with every one of terms, best matches first.
+	SearchPixels(ctx context.Context, terms []string, limit int) ([]Pixel, error)
 	UpdatePixel(ctx context.Context, pixel Pixel) (Pixel, error)
 	UpdatePixelForUserWithCost(ctx context.Context, userID int64, pixel Pixel, cost int64) (Pixel, User, error)
+	// UpdatePixelsForUserWithCost applies a chunk of pixel updates in one transaction. Rejected pixels
+	// are reported in their outcome without affecting the others; a returned error rolls back the chunk.
+	// A pixel the user takes over gets the requested ExpiresAt, one they already own keeps its own.
+	// The charge is recorded in the points ledger with priceVersion.
+	UpdatePixelsForUserWithCost(ctx context.Context, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error)
+	// GiftPixels works like UpdatePixelsForUserWithCost but charges buyerID and makes recipientID the
+	// owner. Only free pixels can be gifted and only as taken; others are rejected in their outcome.
+	GiftPixels(ctx context.Context, buyerID, recipientID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error)
+	// GetBoardPixels returns the grid of the board; pixels nobody has bought there yet are free.
+	GetBoardPixels(ctx context.Context, boardID string) (PixelState, error)
+	// UpdateBoardPixelsForUser works like UpdatePixelsForUserWithCost on the pixels of the board.
+	// Board pixels are neither held, rented nor indexed for search, and they count against the
+	// owner's pixel quota together with those of the main grid.
+	UpdateBoardPixelsForUser(ctx context.Context, boardID string, userID int64, pixels []Pixel, cost int64, priceVersion string) ([]PixelUpdateOutcome, User, error)
 	UpdatePixelForUser(ctx context.Context, userID int64, pixel Pixel) (Pixel, error)
 	GetPixelsByOwner(ctx context.Context, ownerID int64) ([]Pixel, error)
 	CreateUser(ctx context.Context, email, passwordHash string) (User, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id int64) (User, error)
 	CreateActivationCode(ctx context.Context, code string, value int64) error
+	CreateActivationCodeBatch(ctx context.Context, batch ActivationCodeBatch) error
+	GetActivationCode(ctx context.Context, code string) (ActivationCode, error)
+	GetCampaignStats(ctx context.Context, campaignID string) (CampaignStats, error)
 	RedeemActivationCode(ctx context.Context, userID int64, code string) (User, int64, error)
 	CreateVerificationToken(ctx context.Context, token string, userID int64, expiresAt time.Time) (VerificationToken, error)
 	GetVerificationToken(ctx context.Context, token string) (VerificationToken, error)
@@ -80,5 +595,202 @@ type Store interface {
 	GetPasswordResetToken(ctx context.Context, token string) (PasswordResetToken, error)
 	DeletePasswordResetToken(ctx context.Context, token string) error
 	DeletePasswordResetTokensForUser(ctx context.Context, userID int64) error
+	// ConsumePasswordResetToken deletes the token and returns it. Of concurrent callers only one
+	// gets the token; the others get ErrTokenUsed, and sql.ErrNoRows means it never existed.
+	ConsumePasswordResetToken(ctx context.Context, token string) (PasswordResetToken, error)
 	UpdateUserPassword(ctx context.Context, userID int64, passwordHash string) error
+	// ConsumeLinkNonce marks the nonce of a signed email link as used. It reports false when the
+	// nonce was used before; expiresAt is when the link stops being valid anyway.
+	ConsumeLinkNonce(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+	// DeleteExpiredLinkNonces forgets used nonces of links that expired before the given time.
+	DeleteExpiredLinkNonces(ctx context.Context, before time.Time) (int64, error)
+	CreatePayment(ctx context.Context, payment Payment) error
+	GetPayment(ctx context.Context, id string) (Payment, error)
+	ListPaymentsByUser(ctx context.Context, userID int64) ([]Payment, error)
+	// CompletePayment marks a pending payment as completed and credits its points to the user.
+	CompletePayment(ctx context.Context, id, providerRef string) (Payment, User, error)
+	// FailPayment marks a pending payment as failed.
+	FailPayment(ctx context.Context, id, providerRef string) (Payment, error)
+	// GetSetting returns a runtime setting stored by admins or sql.ErrNoRows when unset.
+	GetSetting(ctx context.Context, name string) (string, error)
+	PutSetting(ctx context.Context, name, value string) error
+	DeleteSetting(ctx context.Context, name string) error
+	// SaveAgeAttestation stores the user's attestation, replacing an earlier one.
+	SaveAgeAttestation(ctx context.Context, attestation AgeAttestation) error
+	// GetAgeAttestation returns sql.ErrNoRows when the user has not attested their age.
+	GetAgeAttestation(ctx context.Context, userID int64) (AgeAttestation, error)
+	// CreatePixelLicense stores a license declaration covering license.PixelIDs.
+	CreatePixelLicense(ctx context.Context, license PixelLicense) (PixelLicense, error)
+	ListPixelLicensesByUser(ctx context.Context, userID int64) ([]PixelLicense, error)
+	// ListPixelLicensesByPixel returns every declaration that covered the pixel, newest first.
+	ListPixelLicensesByPixel(ctx context.Context, pixelID int) ([]PixelLicense, error)
+	// CreateTakedown stores a pending takedown and logs its submission.
+	CreateTakedown(ctx context.Context, takedown Takedown) (Takedown, error)
+	// GetTakedown returns sql.ErrNoRows when the takedown does not exist.
+	GetTakedown(ctx context.Context, id int64) (Takedown, error)
+	// ListTakedowns returns takedowns with the given status, or all of them when status is empty, oldest first.
+	ListTakedowns(ctx context.Context, status string) ([]Takedown, error)
+	// ResolveTakedown moves a pending takedown to status and logs the decision; it returns ErrTakedownNotPending otherwise.
+	ResolveTakedown(ctx context.Context, id int64, status string, actorID int64, note string) (Takedown, error)
+	ListTakedownEvents(ctx context.Context, takedownID int64) ([]TakedownEvent, error)
+	// RecordPixelClick counts one click on the pixel's link for its owner on the day of at, and
+	// the visitor among the day's unique visitors of the pixel.
+	RecordPixelClick(ctx context.Context, pixelID int, ownerID int64, visitor string, at time.Time) error
+	// ListPixelClicksByOwner sums the owner's clicks per pixel over the days from since on,
+	// ordered by pixel id.
+	ListPixelClicksByOwner(ctx context.Context, ownerID int64, since time.Time) ([]PixelClicks, error)
+	// GetPixelClickDays returns the days from since on with clicks on the pixel while ownerID held
+	// it, in date order.
+	GetPixelClickDays(ctx context.Context, pixelID int, ownerID int64, since time.Time) ([]PixelClickDay, error)
+	// ListPixelHistory returns up to limit of the pixel's changes, newest first.
+	ListPixelHistory(ctx context.Context, pixelID int, limit int) ([]PixelChange, error)
+	// ListPixelHistoryByOwner returns up to limit of the changes that gave the user a pixel or took
+	// one away, newest first.
+	ListPixelHistoryByOwner(ctx context.Context, ownerID int64, limit int) ([]PixelChange, error)
+	// GetOwnerWebhook returns sql.ErrNoRows when the user has no webhook.
+	GetOwnerWebhook(ctx context.Context, userID int64) (OwnerWebhook, error)
+	// PutOwnerWebhook creates or replaces the user's webhook, keeping its creation time.
+	PutOwnerWebhook(ctx context.Context, webhook OwnerWebhook) (OwnerWebhook, error)
+	DeleteOwnerWebhook(ctx context.Context, userID int64) error
+	// RegisterPushDevice stores the device for its user, moving the token over when another
+	// user registered it before, and refreshes its last_seen_at.
+	RegisterPushDevice(ctx context.Context, device PushDevice) (PushDevice, error)
+	// ListPushDevices returns the user's devices, most recently seen first.
+	ListPushDevices(ctx context.Context, userID int64) ([]PushDevice, error)
+	DeletePushDevice(ctx context.Context, userID int64, token string) error
+	// GetUserPixelQuota returns the pixel quota override of the user, or sql.ErrNoRows when the
+	// default applies.
+	GetUserPixelQuota(ctx context.Context, userID int64) (int, error)
+	// SetUserPixelQuota overrides the pixel quota of the user; 0 lifts the limit for them.
+	SetUserPixelQuota(ctx context.Context, userID int64, maxPixels int) error
+	// DeleteUserPixelQuota puts the user back on the default quota.
+	DeleteUserPixelQuota(ctx context.Context, userID int64) error
+	// CreateInviteCodeBatch stores admin-minted invite codes under batchID.
+	CreateInviteCodeBatch(ctx context.Context, batchID string, codes []string) error
+	// CreateUserInviteCode stores an invite code shared by userID, failing with
+	// ErrInviteLimitReached when they created limit codes already.
+	CreateUserInviteCode(ctx context.Context, userID int64, code string, limit int) (InviteCode, error)
+	// ListInviteCodesByCreator returns the codes userID created, oldest first.
+	ListInviteCodesByCreator(ctx context.Context, userID int64) ([]InviteCode, error)
+	// ListInviteCodesByBatch returns the codes of an admin batch, oldest first.
+	ListInviteCodesByBatch(ctx context.Context, batchID string) ([]InviteCode, error)
+	// CreateUserWithInvite works like CreateUser and marks code as used by the new user in the same
+	// transaction. It fails with ErrInviteCodeInvalid, creating nobody, when the code is unknown or
+	// was used already.
+	CreateUserWithInvite(ctx context.Context, email, passwordHash, code string) (User, error)
+	// ListTopOwners returns up to limit owners of taken pixels ordered by LeaderboardByPixels or
+	// LeaderboardByPoints, ties broken by the other measure and then by user id. Users who opted
+	// out of the leaderboard are left out.
+	ListTopOwners(ctx context.Context, orderBy string, limit int) ([]OwnerRank, error)
+	// GetLeaderboardOptOut reports whether the user opted out of the leaderboard.
+	GetLeaderboardOptOut(ctx context.Context, userID int64) (bool, error)
+	// SetLeaderboardOptOut opts the user out of the leaderboard or back in.
+	SetLeaderboardOptOut(ctx context.Context, userID int64, optOut bool) error
+	// GetEngagementReportSubscription returns sql.ErrNoRows when the user did not opt in.
+	GetEngagementReportSubscription(ctx context.Context, userID int64) (EngagementReportSubscription, error)
+	// SubscribeEngagementReport opts the user in with the given unsubscribe token; an existing
+	// subscription is kept as it is.
+	SubscribeEngagementReport(ctx context.Context, userID int64, token string) (EngagementReportSubscription, error)
+	// UnsubscribeEngagementReport opts the user out; it is not an error when they were not in.
+	UnsubscribeEngagementReport(ctx context.Context, userID int64) error
+	// UnsubscribeEngagementReportByToken opts out the user the token belongs to and reports
+	// whether there was one.
+	UnsubscribeEngagementReportByToken(ctx context.Context, token string) (bool, error)
+	// ClaimEngagementReports returns the subscriptions never reported or last reported before
+	// the given time, marking them as reported at now. LastSentAt holds the previous report.
+	ClaimEngagementReports(ctx context.Context, before, now time.Time) ([]EngagementReportSubscription, error)
+	// ReleaseEngagementReport undoes the claim of a report that could not be sent, putting the
+	// user's last report time back to lastSentAt (nil when never reported) so the next run retries.
+	ReleaseEngagementReport(ctx context.Context, userID int64, lastSentAt *time.Time) error
+	// ClaimExpiringPixels returns the rented pixels that expire before the given time and whose
+	// owners were not warned yet, marking them as warned.
+	ClaimExpiringPixels(ctx context.Context, before time.Time) ([]Pixel, error)
+	// ReleaseExpiredPixels frees the rented pixels whose rental ended by now and returns them as
+	// they were before, with their last owner.
+	ReleaseExpiredPixels(ctx context.Context, now time.Time) ([]Pixel, error)
+	// ReleasePixelsForUser frees the given pixels of the user, or all of them when pixelIDs is
+	// empty, in one transaction and refunds refundFraction of the points paid for each one, rounded
+	// down. Pixels the user does not own are skipped. Rented pixels and pixels bought before
+	// prices were recorded are freed without a refund.
+	ReleasePixelsForUser(ctx context.Context, userID int64, pixelIDs []int, refundFraction float64) (PixelRelease, error)
+	// RefundPixels frees those of refund.PixelIDs that refund.UserID owns and credits the user
+	// *points, or the points recorded as paid for the freed pixels when points is nil, in one
+	// transaction. The refund is stored with the ids of the freed pixels and the credited points.
+	// When the user owns none of the pixels nothing changes and a zero refund is returned.
+	RefundPixels(ctx context.Context, refund PixelRefund, points *int64) (PixelRefund, PixelRelease, error)
+	// ListPixelRefunds returns the refunds of the user, or of everybody when userID is 0, newest first.
+	ListPixelRefunds(ctx context.Context, userID int64) ([]PixelRefund, error)
+	// ListLedgerEntries returns the points ledger of the user, newest first.
+	ListLedgerEntries(ctx context.Context, userID int64) ([]LedgerEntry, error)
+	// RecomputeBalances sums each user's ledger entries and returns the users whose stored balance
+	// differs, ordered by id. With repair those balances are set to the ledger's in the same
+	// transaction.
+	RecomputeBalances(ctx context.Context, repair bool) ([]BalanceDrift, error)
+	// ForceFreePixels frees those of action.PixelIDs that are not free, whoever owns them, and
+	// records action in the audit trail with the ids of the freed pixels, in one transaction. The
+	// freed pixels are returned as they were, with their last owner. When all of the pixels are
+	// already free nothing changes and a zero action is returned.
+	ForceFreePixels(ctx context.Context, action AdminAction) (AdminAction, []Pixel, error)
+	// ListAdminActions returns the newest limit entries of the audit trail, newest first.
+	ListAdminActions(ctx context.Context, limit int) ([]AdminAction, error)
+	// HoldPixels replaces the user's holds with holds until expiresAt on those of pixelIDs that are
+	// free and not held by another user, and returns the ids it holds. Holds that expired by now
+	// are ignored. While held, buying a pixel fails with ErrPixelHeld for everybody else; buying
+	// it releases the hold.
+	HoldPixels(ctx context.Context, userID int64, pixelIDs []int, expiresAt, now time.Time) ([]int, error)
+	// ListPixelHolds returns the user's holds that have not expired by now, by pixel id.
+	ListPixelHolds(ctx context.Context, userID int64, now time.Time) ([]PixelHold, error)
+	ReleasePixelHolds(ctx context.Context, userID int64) error
+	// DeleteExpiredPixelHolds removes holds that expired by now and returns how many it removed.
+	DeleteExpiredPixelHolds(ctx context.Context, now time.Time) (int64, error)
+	// CreateCustomDomain stores an unverified claim of a domain and returns it with its id, or
+	// ErrDomainTaken when somebody verified the domain already. Several users may claim a domain
+	// until one of them verifies it.
+	CreateCustomDomain(ctx context.Context, domain CustomDomain) (CustomDomain, error)
+	// ListCustomDomains returns the user's domains, oldest first.
+	ListCustomDomains(ctx context.Context, userID int64) ([]CustomDomain, error)
+	// ListCustomDomainsByState returns all verified domains, or all unverified ones.
+	ListCustomDomainsByState(ctx context.Context, verified bool) ([]CustomDomain, error)
+	// MarkCustomDomainChecked records a DNS check at the given time and, when verified, marks
+	// the domain verified and removes the other pending claims of it. It returns ErrDomainTaken,
+	// leaving the claim unverified, when another claim of the domain was verified first.
+	MarkCustomDomainChecked(ctx context.Context, id int64, verified bool, at time.Time) error
+	// DeleteCustomDomain removes the user's domain; deleting a missing domain is not an error.
+	DeleteCustomDomain(ctx context.Context, userID, id int64) error
+	// DeleteUnverifiedCustomDomains removes domains still unverified that were registered
+	// before the given time and returns how many it removed.
+	DeleteUnverifiedCustomDomains(ctx context.Context, before time.Time) (int64, error)
+	// CreatePixelDraft stores a draft with its pixels and returns it with its id and timestamps.
+	CreatePixelDraft(ctx context.Context, draft PixelDraft) (PixelDraft, error)
+	// ListPixelDrafts returns the user's drafts, most recently updated first.
+	ListPixelDrafts(ctx context.Context, userID int64) ([]PixelDraft, error)
+	// GetPixelDraft returns sql.ErrNoRows unless the user has a draft with that id.
+	GetPixelDraft(ctx context.Context, userID, id int64) (PixelDraft, error)
+	// UpdatePixelDraft replaces the name and pixels of the user's draft; it returns
+	// sql.ErrNoRows when the user has no draft with that id.
+	UpdatePixelDraft(ctx context.Context, draft PixelDraft) (PixelDraft, error)
+	// DeletePixelDraft removes the user's draft; it returns sql.ErrNoRows when there is none.
+	DeletePixelDraft(ctx context.Context, userID, id int64) error
+	// QueuePixelReviews puts the user's pixels in the moderation queue, replacing earlier entries
+	// of the same pixels, so a pixel queued again goes to the back of the queue.
+	QueuePixelReviews(ctx context.Context, userID int64, pixelIDs []int, at time.Time) error
+	// ListPixelReviews returns the queued pixels still owned by the user who queued them, with
+	// their current content, oldest first.
+	ListPixelReviews(ctx context.Context) ([]PixelReview, error)
+	// ListPendingReviewPixelIDs returns the ids of the pixels ListPixelReviews returns.
+	ListPendingReviewPixelIDs(ctx context.Context) ([]int, error)
+	// ResolvePixelReviews takes the given pixels off the moderation queue in one transaction and
+	// returns those still owned by the user who queued them. Rejected pixels are freed and their
+	// owners credited the points recorded as paid for them.
+	ResolvePixelReviews(ctx context.Context, pixelIDs []int, decision string) ([]PixelReview, error)
+	// ListHiddenPixelIDs returns the pixels covered by pending or upheld takedowns.
+	ListHiddenPixelIDs(ctx context.Context) ([]int, error)
+	CreateContactMessage(ctx context.Context, message ContactMessage) (ContactMessage, error)
+	// GetContactMessage returns sql.ErrNoRows when the message does not exist.
+	GetContactMessage(ctx context.Context, id int64) (ContactMessage, error)
+	// ListContactMessages returns messages with the given status, or all when status is empty, newest first.
+	ListContactMessages(ctx context.Context, status string) ([]ContactMessage, error)
+	// CreateContactReply stores the reply and marks its message as answered.
+	CreateContactReply(ctx context.Context, reply ContactReply) (ContactReply, error)
+	ListContactReplies(ctx context.Context, messageID int64) ([]ContactReply, error)
 }
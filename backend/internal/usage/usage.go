@@ -0,0 +1,127 @@
+// Package usage keeps lightweight in-memory API usage counters per account and enforces daily quotas.
+package usage
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot is the usage of one account.
+type Snapshot struct {
+	Total        int64            `json:"total"`
+	Today        int64            `json:"today"`
+	Rejected     int64            `json:"rejected_today"`
+	Routes       map[string]int64 `json:"routes"`
+	LastActivity time.Time        `json:"last_activity"`
+	// ResetAt is when the daily counter starts over (next UTC midnight).
+	ResetAt time.Time `json:"reset_at"`
+}
+
+type account struct {
+	total    int64
+	today    int64
+	rejected int64
+	day      time.Time
+	routes   map[string]int64
+	last     time.Time
+}
+
+// Tracker counts API calls per account. Counters live in memory and restart with the process.
+type Tracker struct {
+	mu       sync.Mutex
+	accounts map[int64]*account
+	now      func() time.Time
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{accounts: make(map[int64]*account), now: time.Now}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Hit records a call to route by accountID unless dailyLimit (0 means unlimited) is already used up.
+// It returns the updated snapshot and whether the call is allowed.
+func (t *Tracker) Hit(accountID int64, route string, dailyLimit int64) (Snapshot, bool) {
+	if t == nil {
+		return Snapshot{}, true
+	}
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acc, ok := t.accounts[accountID]
+	if !ok {
+		acc = &account{routes: make(map[string]int64)}
+		t.accounts[accountID] = acc
+	}
+	if day := startOfDay(now); !acc.day.Equal(day) {
+		acc.day = day
+		acc.today = 0
+		acc.rejected = 0
+	}
+	acc.last = now
+	if dailyLimit > 0 && acc.today >= dailyLimit {
+		acc.rejected++
+		return acc.snapshot(), false
+	}
+	acc.total++
+	acc.today++
+	acc.routes[route]++
+	return acc.snapshot(), true
+}
+
+// Usage returns the usage of accountID; the zero Snapshot when it has made no calls.
+func (t *Tracker) Usage(accountID int64) Snapshot {
+	if t == nil {
+		return Snapshot{Routes: map[string]int64{}}
+	}
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	acc, ok := t.accounts[accountID]
+	if !ok {
+		return Snapshot{Routes: map[string]int64{}, ResetAt: startOfDay(now).Add(24 * time.Hour)}
+	}
+	snap := acc.snapshot()
+	if !acc.day.Equal(startOfDay(now)) {
+		snap.Today, snap.Rejected = 0, 0
+		snap.ResetAt = startOfDay(now).Add(24 * time.Hour)
+	}
+	return snap
+}
+
+// Prune forgets accounts idle for longer than idle and returns how many were removed.
+func (t *Tracker) Prune(idle time.Duration) int {
+	if t == nil {
+		return 0
+	}
+	cutoff := t.now().Add(-idle)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	removed := 0
+	for id, acc := range t.accounts {
+		if acc.last.Before(cutoff) {
+			delete(t.accounts, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (a *account) snapshot() Snapshot {
+	routes := make(map[string]int64, len(a.routes))
+	for route, n := range a.routes {
+		routes[route] = n
+	}
+	return Snapshot{
+		Total:        a.total,
+		Today:        a.today,
+		Rejected:     a.rejected,
+		Routes:       routes,
+		LastActivity: a.last,
+		ResetAt:      a.day.Add(24 * time.Hour),
+	}
+}
@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTracker(now *time.Time) *Tracker {
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestHitCountsRoutesAndEnforcesDailyLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := tracker.Hit(7, "GET /api/pixels", 3); !ok {
+			t.Fatalf("call %d should be allowed", i)
+		}
+	}
+	if _, ok := tracker.Hit(7, "POST /api/pixels", 3); !ok {
+		t.Fatal("third call should be allowed")
+	}
+	snap, ok := tracker.Hit(7, "GET /api/pixels", 3)
+	if ok {
+		t.Fatal("fourth call should exceed the quota")
+	}
+	if snap.Total != 3 || snap.Today != 3 || snap.Rejected != 1 || snap.Routes["GET /api/pixels"] != 2 || snap.Routes["POST /api/pixels"] != 1 {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	if want := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !snap.ResetAt.Equal(want) {
+		t.Fatalf("reset at %s, want %s", snap.ResetAt, want)
+	}
+
+	now = now.Add(3 * time.Hour)
+	if got := tracker.Usage(7); got.Today != 0 || got.Total != 3 {
+		t.Fatalf("daily counter should reset at midnight, got %+v", got)
+	}
+	if _, ok := tracker.Hit(7, "GET /api/pixels", 3); !ok {
+		t.Fatal("quota should be available on the next day")
+	}
+}
+
+func TestPruneForgetsIdleAccounts(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+	tracker.Hit(1, "GET /api/account", 0)
+	now = now.Add(time.Hour)
+	tracker.Hit(2, "GET /api/account", 0)
+
+	if removed := tracker.Prune(30 * time.Minute); removed != 1 {
+		t.Fatalf("Prune() removed %d, want 1", removed)
+	}
+	if tracker.Usage(1).Total != 0 || tracker.Usage(2).Total != 1 {
+		t.Fatal("unexpected accounts after prune")
+	}
+}
+
+func TestNilTrackerAllowsEverything(t *testing.T) {
+	var tracker *Tracker
+	if _, ok := tracker.Hit(1, "GET /api/pixels", 1); !ok {
+		t.Fatal("nil tracker must allow calls")
+	}
+}
@@ -0,0 +1,271 @@
+// Package webhook delivers signed event notifications to user-supplied URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/example/kup-piksel/internal/netguard"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+	SignatureHeader = "X-KupPiksel-Signature"
+	// EventHeader names the event the body describes.
+	EventHeader = "X-KupPiksel-Event"
+	// DeliveryHeader identifies a delivery; retries of it repeat the same id.
+	DeliveryHeader = "X-KupPiksel-Delivery"
+	// TransactionHeader carries the id of the request that triggered the event, so the
+	// receiver's logs can be matched with ours.
+	TransactionHeader = "X-Transaction-ID"
+
+	defaultMaxAttempts = 5
+	defaultTimeout     = 10 * time.Second
+	defaultBaseDelay   = 2 * time.Second
+	defaultMaxDelay    = 10 * time.Minute
+	queueCapacity      = 1000
+	workers            = 4
+)
+
+var (
+	// ErrQueueFull is returned by Enqueue when deliveries arrive faster than they are sent.
+	ErrQueueFull = errors.New("webhook queue is full")
+
+	// errPermanent marks failures a retry cannot fix.
+	errPermanent = errors.New("permanent failure")
+)
+
+// Config tunes delivery.
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is dropped.
+	MaxAttempts int
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+	// BaseDelay and MaxDelay bound the exponential backoff between attempts.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AllowPrivateTargets permits loopback, private and link-local addresses, e.g. in tests.
+	AllowPrivateTargets bool
+	// WrapTransport, when set, wraps the guarded transport, e.g. with retries and metrics.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+// Delivery is one event for one endpoint.
+type Delivery struct {
+	ID     string
+	URL    string
+	Secret string
+	Event  string
+	Body   []byte
+	// TransactionID is the id of the request that caused the event; it is sent with every
+	// attempt when set.
+	TransactionID string
+
+	attempt int
+}
+
+// Dispatcher sends queued deliveries in the background and retries failed ones with jittered
+// exponential backoff. Deliveries live only in memory and are lost on restart.
+type Dispatcher struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	client      *http.Client
+	queue       chan Delivery
+}
+
+func NewDispatcher(cfg Config) *Dispatcher {
+	d := &Dispatcher{
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   cfg.BaseDelay,
+		maxDelay:    cfg.MaxDelay,
+		queue:       make(chan Delivery, queueCapacity),
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.baseDelay <= 0 {
+		d.baseDelay = defaultBaseDelay
+	}
+	if d.maxDelay <= 0 {
+		d.maxDelay = defaultMaxDelay
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !cfg.AllowPrivateTargets {
+		dialer.Control = netguard.Control
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	var roundTripper http.RoundTripper = transport
+	if cfg.WrapTransport != nil {
+		roundTripper = cfg.WrapTransport(transport)
+	}
+	d.client = &http.Client{
+		Timeout:   timeout,
+		Transport: roundTripper,
+		// A redirect could lead to an address the endpoint itself was not allowed to be.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return d
+}
+
+// Enqueue schedules a delivery without blocking. A nil Dispatcher ignores calls.
+func (d *Dispatcher) Enqueue(delivery Delivery) error {
+	if d == nil {
+		return nil
+	}
+	if delivery.ID == "" {
+		delivery.ID = NewDeliveryID()
+	}
+	select {
+	case d.queue <- delivery:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-d.queue:
+					d.attempt(ctx, delivery)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// attempt sends delivery once and schedules a retry when it fails and attempts remain.
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	delivery.attempt++
+	err := d.Send(ctx, delivery)
+	if err == nil {
+		return
+	}
+	if delivery.attempt >= d.maxAttempts || errors.Is(err, errPermanent) {
+		log.Printf("webhook: dropped delivery=%s event=%s attempts=%d: %v", delivery.ID, delivery.Event, delivery.attempt, err)
+		return
+	}
+	delay := d.Backoff(delivery.attempt)
+	log.Printf("webhook: delivery=%s event=%s attempt=%d failed, retrying in %s: %v", delivery.ID, delivery.Event, delivery.attempt, delay.Round(time.Millisecond), err)
+	time.AfterFunc(delay, func() {
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case d.queue <- delivery:
+		default:
+			log.Printf("webhook: dropped delivery=%s event=%s: %v", delivery.ID, delivery.Event, ErrQueueFull)
+		}
+	})
+}
+
+// Backoff returns the delay before the attempt after the n-th failure: a random duration between
+// half and all of BaseDelay*2^(n-1), capped at MaxDelay, so endpoints that failed together are
+// not retried in lockstep.
+func (d *Dispatcher) Backoff(n int) time.Duration {
+	delay := d.baseDelay
+	for i := 1; i < n && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.maxDelay)
+	half := int64(delay / 2)
+	jitter, err := rand.Int(rand.Reader, big.NewInt(half+1))
+	if err != nil {
+		return delay
+	}
+	return time.Duration(half + jitter.Int64())
+}
+
+// Send makes one signed POST of delivery. Answers other than 2xx are errors; 410 Gone is
+// permanent and is not retried.
+func (d *Dispatcher) Send(ctx context.Context, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "KupPiksel-Webhook/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Body))
+	if delivery.TransactionID != "" {
+		req.Header.Set(TransactionHeader, delivery.TransactionID)
+	}
+
+	started := time.Now()
+	resp, err := d.client.Do(req)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("webhook: delivery=%s tx=%s host=%s duration=%s err=%v", delivery.ID, transactionLabel(delivery), req.URL.Host, elapsed, err)
+		if errors.Is(err, netguard.ErrPrivateAddress) {
+			return fmt.Errorf("%w: %v", errPermanent, err)
+		}
+		return fmt.Errorf("post: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	log.Printf("webhook: delivery=%s tx=%s host=%s status=%d duration=%s", delivery.ID, transactionLabel(delivery), req.URL.Host, resp.StatusCode, elapsed)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: status %d", errPermanent, resp.StatusCode)
+	}
+	return fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// transactionLabel returns the transaction id of delivery for log lines, "-" when there is none.
+func transactionLabel(delivery Delivery) string {
+	if delivery.TransactionID == "" {
+		return "-"
+	}
+	return delivery.TransactionID
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewDeliveryID returns a random id for a Delivery, e.g. to embed it in the body as well.
+func NewDeliveryID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
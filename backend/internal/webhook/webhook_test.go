@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunRetriesUntilDelivered(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts []string
+	)
+	delivered := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, r.Header.Get(DeliveryHeader))
+		if len(attempts) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(EventHeader) != "pixel.clicked" || string(body) != `{"ok":true}` {
+			t.Errorf("unexpected delivery %q %s", r.Header.Get(EventHeader), body)
+		}
+		close(delivered)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, AllowPrivateTargets: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	if err := d.Enqueue(Delivery{URL: srv.URL, Secret: "s", Event: "pixel.clicked", Body: []byte(`{"ok":true}`)}); err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery was not retried to success")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 3 || attempts[0] == "" || attempts[0] != attempts[2] {
+		t.Fatalf("expected three attempts of one delivery, got %q", attempts)
+	}
+}
+
+func TestSendRejectsPrivateTargets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("private target must not be reached")
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{})
+	err := d.Send(context.Background(), Delivery{URL: srv.URL, Event: "pixel.clicked"})
+	if err == nil {
+		t.Fatal("expected loopback delivery to fail")
+	}
+}
+
+func TestSendForwardsTransactionID(t *testing.T) {
+	received := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(TransactionHeader)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(Config{AllowPrivateTargets: true})
+	if err := d.Send(context.Background(), Delivery{URL: srv.URL, Event: "pixel.clicked", TransactionID: "tx-1234567"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := <-received; got != "tx-1234567" {
+		t.Fatalf("expected the transaction id header, got %q", got)
+	}
+}
+
+func TestBackoffIsJitteredAndCapped(t *testing.T) {
+	d := NewDispatcher(Config{BaseDelay: time.Second, MaxDelay: 8 * time.Second})
+	for n, full := range map[int]time.Duration{1: time.Second, 3: 4 * time.Second, 10: 8 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if delay := d.Backoff(n); delay < full/2 || delay > full {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", n, delay, full/2, full)
+			}
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", time.Unix(1700000000, 0), []byte(`{}`))
+	want := "t=1700000000,v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got != want {
+		t.Fatalf("Sign() = %q, want %q", got, want)
+	}
+}
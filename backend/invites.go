@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const maxInviteBatch = 1000
+
+type createInviteBatchRequest struct {
+	BatchID string `json:"batch_id"`
+	Count   int    `json:"count"`
+}
+
+// accountInvite is an invite code as its creator sees it; who used it stays private.
+type accountInvite struct {
+	Code      string     `json:"code"`
+	CreatedAt time.Time  `json:"created_at"`
+	Used      bool       `json:"used"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// generateInviteCode returns a code such as INV-7KQ2-M4XD-PA9C. The prefix tells invite codes apart
+// from activation codes, which look alike.
+func generateInviteCode() (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)[:12]
+	return "INV-" + encoded[:4] + "-" + encoded[4:8] + "-" + encoded[8:], nil
+}
+
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// createUser registers email, taking up inviteCode while registration is invite-only.
+func (s *Server) createUser(ctx context.Context, email, passwordHash, inviteCode string) (storage.User, error) {
+	if !s.invitesRequired {
+		return s.store.CreateUser(ctx, email, passwordHash)
+	}
+	return s.store.CreateUserWithInvite(ctx, email, passwordHash, inviteCode)
+}
+
+// requireInvites answers 404 while registration is open to everyone, when invites are pointless.
+func (s *Server) requireInvites(c *gin.Context) bool {
+	if !s.invitesRequired {
+		c.JSON(http.StatusNotFound, gin.H{"error": "invites are disabled"})
+		return false
+	}
+	return true
+}
+
+// handleListInvites returns the invite codes the signed-in user created and how many more they may
+// create.
+func (s *Server) handleListInvites(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireInvites(c) {
+		return
+	}
+	invites, err := s.store.ListInviteCodesByCreator(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("invites: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load invites"})
+		return
+	}
+	codes := make([]accountInvite, len(invites))
+	for i, invite := range invites {
+		codes[i] = accountInvite{Code: invite.Code, CreatedAt: invite.CreatedAt, Used: invite.UsedBy != nil, UsedAt: invite.UsedAt}
+	}
+	c.JSON(http.StatusOK, gin.H{"codes": codes, "limit": s.invitesPerUser, "remaining": max(s.invitesPerUser-len(invites), 0)})
+}
+
+// handleCreateInvite creates an invite code for the signed-in user to share. Only verified accounts
+// get invites, up to invites.perUser each.
+func (s *Server) handleCreateInvite(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireInvites(c) || s.rejectWrites(c) {
+		return
+	}
+	if !user.IsVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "potwierdź adres e-mail, aby zapraszać znajomych.", "code": "email_not_verified"})
+		return
+	}
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Printf("invites: generate code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+	invite, err := s.store.CreateUserInviteCode(c.Request.Context(), user.ID, code, s.invitesPerUser)
+	if errors.Is(err, storage.ErrInviteLimitReached) {
+		c.JSON(http.StatusConflict, gin.H{"error": "wykorzystano już wszystkie zaproszenia.", "code": "invite_limit"})
+		return
+	}
+	if err != nil {
+		log.Printf("invites: create user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+	log.Printf("invites: user_id=%d created an invite", user.ID)
+	c.JSON(http.StatusCreated, accountInvite{Code: invite.Code, CreatedAt: invite.CreatedAt})
+}
+
+// handleCreateInviteBatch mints count invite codes under batch_id, e.g. for a beta wave. Admins may
+// mint them while registration is still open.
+func (s *Server) handleCreateInviteBatch(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	var req createInviteBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	batchID := strings.TrimSpace(req.BatchID)
+	if !campaignIDPattern.MatchString(batchID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	if req.Count <= 0 || req.Count > maxInviteBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", maxInviteBatch)})
+		return
+	}
+
+	codes := make([]string, 0, req.Count)
+	seen := make(map[string]struct{}, req.Count)
+	for len(codes) < req.Count {
+		code, err := generateInviteCode()
+		if err != nil {
+			log.Printf("invites: generate code: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate codes"})
+			return
+		}
+		if _, dup := seen[code]; dup {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	if err := s.store.CreateInviteCodeBatch(c.Request.Context(), batchID, codes); err != nil {
+		log.Printf("invites: create batch=%s: %v", batchID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store codes"})
+		return
+	}
+	log.Printf("invites: batch=%s count=%d created", batchID, len(codes))
+	c.JSON(http.StatusCreated, gin.H{"batch_id": batchID, "codes": codes})
+}
+
+// handleListInviteBatch returns the codes of an admin batch with who used them.
+func (s *Server) handleListInviteBatch(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	batchID := strings.TrimSpace(c.Param("batch"))
+	if !campaignIDPattern.MatchString(batchID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+	invites, err := s.store.ListInviteCodesByBatch(c.Request.Context(), batchID)
+	if err != nil {
+		log.Printf("invites: list batch=%s: %v", batchID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load invites"})
+		return
+	}
+	if len(invites) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+		return
+	}
+	used := 0
+	for _, invite := range invites {
+		if invite.UsedBy != nil {
+			used++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"batch_id": batchID, "codes": invites, "used": used})
+}
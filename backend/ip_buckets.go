@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/netip"
+)
+
+// ipBuckets groups client addresses into the networks that rate limits, abuse scoring and audit
+// logs count as one source. A single IPv6 user usually controls a whole /64, so counting every
+// address on its own would let them rotate past any per-IP limit. A prefix length of 0 keeps the
+// whole address.
+type ipBuckets struct {
+	ipv4Bits int
+	ipv6Bits int
+}
+
+// bucket returns the network of ip in CIDR notation, or the plain address when the prefix
+// covers all of it. IPv4-mapped IPv6 addresses count as IPv4; anything that does not parse is
+// returned unchanged.
+func (b ipBuckets) bucket(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := b.ipv6Bits
+	if addr.Is4() {
+		bits = b.ipv4Bits
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
+
+// clientSource is the bucket of the request's remote address.
+func (s *Server) clientSource(r *http.Request) string {
+	return s.ipBuckets.bucket(extractRemoteIP(r))
+}
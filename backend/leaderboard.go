@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// leaderboardCache keeps the rankings of each order for ttl, so the aggregate query runs at most
+// once per order and period however many visitors open the leaderboard.
+type leaderboardCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]leaderboardCacheEntry
+}
+
+type leaderboardCacheEntry struct {
+	ranks    []storage.OwnerRank
+	loadedAt time.Time
+}
+
+func newLeaderboardCache(ttl time.Duration) *leaderboardCache {
+	return &leaderboardCache{ttl: ttl, now: time.Now, entries: make(map[string]leaderboardCacheEntry)}
+}
+
+// invalidate drops the cached rankings, so an opt-out takes effect on the next request.
+func (l *leaderboardCache) invalidate() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.entries)
+}
+
+type leaderboardEntry struct {
+	Rank        int   `json:"rank"`
+	Pixels      int64 `json:"pixels"`
+	PointsSpent int64 `json:"points_spent"`
+	Mine        bool  `json:"mine"`
+}
+
+type leaderboardOptOutRequest struct {
+	OptOut *bool `json:"opt_out"`
+}
+
+// topOwners returns the leaderboard ordered by orderBy, from the cache while it is fresh.
+func (s *Server) topOwners(ctx context.Context, orderBy string) ([]storage.OwnerRank, error) {
+	cache := s.leaderboard
+	if cache == nil || cache.ttl <= 0 {
+		return s.store.ListTopOwners(ctx, orderBy, s.leaderboardSize)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := cache.now()
+	if entry, ok := cache.entries[orderBy]; ok && now.Sub(entry.loadedAt) < cache.ttl {
+		return entry.ranks, nil
+	}
+	ranks, err := s.store.ListTopOwners(ctx, orderBy, s.leaderboardSize)
+	if err != nil {
+		return nil, err
+	}
+	cache.entries[orderBy] = leaderboardCacheEntry{ranks: ranks, loadedAt: now}
+	return ranks, nil
+}
+
+// requireLeaderboard answers 404 while the leaderboard is disabled in the configuration.
+func (s *Server) requireLeaderboard(c *gin.Context) bool {
+	if s.leaderboardSize <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "leaderboard is disabled"})
+		return false
+	}
+	return true
+}
+
+// handleLeaderboard lists the top pixel owners by pixel count, or by points spent with
+// ?by=points. Owners are ranked, never identified; mine marks the signed-in viewer's entry.
+// Users who opted out are left out.
+func (s *Server) handleLeaderboard(c *gin.Context) {
+	if !s.requireLeaderboard(c) {
+		return
+	}
+	orderBy := c.Query("by")
+	if orderBy == "" {
+		orderBy = storage.LeaderboardByPixels
+	}
+	if orderBy != storage.LeaderboardByPixels && orderBy != storage.LeaderboardByPoints {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be pixels or points"})
+		return
+	}
+	ranks, err := s.topOwners(c.Request.Context(), orderBy)
+	if err != nil {
+		log.Printf("leaderboard: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaderboard"})
+		return
+	}
+	viewer, _, signedIn := s.getSessionUser(c)
+	owners := make([]leaderboardEntry, 0, len(ranks))
+	for i, rank := range ranks {
+		owners = append(owners, leaderboardEntry{
+			Rank:        i + 1,
+			Pixels:      rank.Pixels,
+			PointsSpent: rank.PointsSpent,
+			Mine:        signedIn && rank.OwnerID == viewer.ID,
+		})
+	}
+	c.Writer.Header().Set("Cache-Control", "private, no-cache")
+	c.JSON(http.StatusOK, gin.H{"by": orderBy, "owners": owners})
+}
+
+// handleGetLeaderboardOptOut tells the signed-in user whether they are left out of the leaderboard.
+func (s *Server) handleGetLeaderboardOptOut(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireLeaderboard(c) {
+		return
+	}
+	optOut, err := s.store.GetLeaderboardOptOut(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("leaderboard: load opt-out user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaderboard settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"opt_out": optOut})
+}
+
+// handlePutLeaderboardOptOut leaves the signed-in user out of the leaderboard with
+// {"opt_out": true}, or puts them back with false. The cached rankings are dropped so the change
+// shows at once.
+func (s *Server) handlePutLeaderboardOptOut(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireLeaderboard(c) || s.rejectWrites(c) {
+		return
+	}
+	var req leaderboardOptOutRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.OptOut == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opt_out must be true or false"})
+		return
+	}
+	if err := s.store.SetLeaderboardOptOut(c.Request.Context(), user.ID, *req.OptOut); err != nil {
+		log.Printf("leaderboard: save opt-out user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save leaderboard settings"})
+		return
+	}
+	s.leaderboard.invalidate()
+	c.JSON(http.StatusOK, gin.H{"opt_out": *req.OptOut})
+}
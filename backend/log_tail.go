@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	// logRingCapacity is how many recent log lines the admin log tail can replay.
+	logRingCapacity = 2000
+	// logTailBuffer is how many lines a log tail subscriber may lag behind before it is dropped.
+	logTailBuffer = 256
+	// errorRingCapacity is how many error lines GET /api/admin/errors keeps, independently of
+	// how much other logging pushed them out of the main ring.
+	errorRingCapacity = 200
+)
+
+// logLevels orders the levels accepted by ?level=.
+var logLevels = map[string]int{"info": 0, "warn": 1, "error": 2}
+
+// logTransactionPattern finds the tx=<id> field that request-scoped log lines carry.
+var logTransactionPattern = regexp.MustCompile(`\btx=([A-Za-z0-9_-]{8,64})\b`)
+
+// logEntry is one line written through the standard logger.
+type logEntry struct {
+	Seq           uint64    `json:"seq"`
+	Time          time.Time `json:"time"`
+	Level         string    `json:"level"`
+	Message       string    `json:"message"`
+	TransactionID string    `json:"tx,omitempty"`
+}
+
+// logBuffer is a fixed-size ring of log entries.
+type logBuffer struct {
+	entries []logEntry
+	next    int
+}
+
+func newLogBuffer(capacity int) logBuffer {
+	return logBuffer{entries: make([]logEntry, 0, capacity)}
+}
+
+func (b *logBuffer) push(entry logEntry) {
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, entry)
+		return
+	}
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+}
+
+// newest returns the i-th newest entry, starting at 0.
+func (b *logBuffer) newest(i int) logEntry {
+	return b.entries[(b.next+len(b.entries)-1-i)%len(b.entries)]
+}
+
+// logLineLevel guesses the level of a log line. The backend logs through the standard library
+// without levels, so failures are recognised by the words their messages use.
+func logLineLevel(line string) string {
+	lower := strings.ToLower(line)
+	for _, word := range []string{"error", "failed", "panic", "fatal", "err="} {
+		if strings.Contains(lower, word) {
+			return "error"
+		}
+	}
+	for _, word := range []string{"warn", "missing", "ignoring", "ignored", "rejected", "dropped", "slow"} {
+		if strings.Contains(lower, word) {
+			return "warn"
+		}
+	}
+	return "info"
+}
+
+// LogRing keeps the most recent log lines for the admin log tail. It is installed as an extra
+// output of the standard logger, so it must never log itself.
+type LogRing struct {
+	mu          sync.Mutex
+	lines       logBuffer
+	errors      logBuffer
+	seq         uint64
+	partial     []byte
+	now         func() time.Time
+	subscribers map[chan logEntry]struct{}
+}
+
+func NewLogRing(capacity int) *LogRing {
+	return &LogRing{
+		lines:       newLogBuffer(capacity),
+		errors:      newLogBuffer(errorRingCapacity),
+		now:         time.Now,
+		subscribers: make(map[chan logEntry]struct{}),
+	}
+}
+
+// Write records every complete line of p; a trailing partial line waits for its newline.
+func (r *LogRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.add(string(data[:i]))
+		data = data[i+1:]
+	}
+	r.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// add stores line and delivers it to subscribers; callers hold r.mu.
+func (r *LogRing) add(line string) {
+	r.seq++
+	entry := logEntry{Seq: r.seq, Time: r.now().UTC(), Level: logLineLevel(line), Message: line}
+	if match := logTransactionPattern.FindStringSubmatch(line); match != nil {
+		entry.TransactionID = match[1]
+	}
+	r.lines.push(entry)
+	if entry.Level == "error" {
+		r.errors.push(entry)
+	}
+	for ch := range r.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Recent returns up to limit of the newest entries at or above level, oldest first.
+func (r *LogRing) Recent(level string, limit int) []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recent(level, limit)
+}
+
+func (r *LogRing) recent(level string, limit int) []logEntry {
+	threshold := logLevels[level]
+	var out []logEntry
+	for i := 0; i < len(r.lines.entries) && len(out) < limit; i++ {
+		entry := r.lines.newest(i)
+		if logLevels[entry.Level] >= threshold {
+			out = append(out, entry)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Errors returns up to limit of the newest error entries, newest first. A non-empty tx keeps
+// only the entries of that transaction.
+func (r *LogRing) Errors(tx string, limit int) []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []logEntry{}
+	for i := 0; i < len(r.errors.entries) && len(out) < limit; i++ {
+		entry := r.errors.newest(i)
+		if tx == "" || entry.TransactionID == tx {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// Tail returns the newest backlog entries at or above level together with a subscription to
+// all later lines, so nothing is lost or repeated in between. The channel is closed when the
+// subscriber falls more than logTailBuffer lines behind or cancels.
+func (r *LogRing) Tail(level string, backlog int) ([]logEntry, <-chan logEntry, func()) {
+	ch := make(chan logEntry, logTailBuffer)
+	r.mu.Lock()
+	recent := r.recent(level, backlog)
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+	return recent, ch, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.subscribers[ch]; ok {
+			delete(r.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// handleAdminLogStream tails the in-process log over Server-Sent Events for operators without
+// access to the log aggregation. ?level=warn|error hides less severe lines and ?backlog=N
+// (default 100) replays that many recent lines first.
+func (s *Server) handleAdminLogStream(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	if s.logRing == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log tail disabled"})
+		return
+	}
+	level := strings.ToLower(strings.TrimSpace(c.Query("level")))
+	if level == "" {
+		level = "info"
+	}
+	if _, ok := logLevels[level]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level must be info, warn or error"})
+		return
+	}
+	backlog := 100
+	if raw := c.Query("backlog"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "backlog must be a non-negative number"})
+			return
+		}
+		backlog = min(n, logRingCapacity)
+	}
+
+	recent, entries, unsubscribe := s.logRing.Tail(level, backlog)
+	defer unsubscribe()
+	flusher, ok := startEventStream(c)
+	if !ok {
+		return
+	}
+
+	send := func(entry logEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: log\ndata: %s\n\n", entry.Seq, data)
+		return err
+	}
+	for _, entry := range recent {
+		if send(entry) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(pixelStreamHeartbeat)
+	defer heartbeat.Stop()
+	minLevel := logLevels[level]
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case entry, open := <-entries:
+			if !open {
+				return
+			}
+			if logLevels[entry.Level] < minLevel {
+				continue
+			}
+			err = send(entry)
+		case now := <-heartbeat.C:
+			_, err = fmt.Fprintf(c.Writer, "event: heartbeat\ndata: {\"time\":%q}\n\n", now.UTC().Format(time.RFC3339))
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleAdminErrors lists the most recent error log lines, newest first, so support can match a
+// user's complaint (and the X-Transaction-ID of the failed request) to what went wrong.
+// ?limit=N (default 100) caps the list and ?tx=ID keeps only the lines of one request.
+func (s *Server) handleAdminErrors(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	if s.logRing == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "log tail disabled"})
+		return
+	}
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		limit = min(n, errorRingCapacity)
+	}
+	tx := strings.TrimSpace(c.Query("tx"))
+	c.JSON(http.StatusOK, gin.H{"errors": s.logRing.Errors(tx, limit)})
+}
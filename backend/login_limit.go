@@ -0,0 +1,182 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+// loginSweepSize is the number of tracked sources above which stale ones are dropped.
+const loginSweepSize = 10000
+
+// LoginLimiter counts failed logins per IP address and account over a rolling window. An IP
+// address that reached the limit has to wait until its oldest counted failure leaves the window;
+// an account that reached it only requires an interactive challenge, so guessing at someone's
+// password cannot lock the owner out. The counters live in memory, so a restart resets them.
+type LoginLimiter struct {
+	mu       sync.Mutex
+	limits   config.LoginLimits
+	failures map[string][]time.Time
+	now      func() time.Time
+}
+
+func NewLoginLimiter(limits config.LoginLimits) *LoginLimiter {
+	return &LoginLimiter{limits: limits, failures: make(map[string][]time.Time), now: time.Now}
+}
+
+// loginVerdict describes whether a source may attempt a login. remaining is the number of
+// failures the source may still make before it has to wait or solve a challenge, or -1 when
+// logins are not limited.
+type loginVerdict struct {
+	retryAfter       time.Duration
+	scope            string
+	remaining        int
+	requireChallenge bool
+}
+
+// loginSource is a counter key together with the scope reported to clients.
+type loginSource struct {
+	scope string
+	key   string
+}
+
+func loginSources(ip, email string) []loginSource {
+	sources := make([]loginSource, 0, 2)
+	if ip != "" {
+		sources = append(sources, loginSource{scope: "ip", key: "ip:" + ip})
+	}
+	if email != "" {
+		sources = append(sources, loginSource{scope: "account", key: "account:" + email})
+	}
+	return sources
+}
+
+func (l *LoginLimiter) window() time.Duration {
+	return time.Duration(l.limits.LockoutMinutes) * time.Minute
+}
+
+// recent returns the failures of key inside the window. Callers hold mu.
+func (l *LoginLimiter) recent(key string, now time.Time) []time.Time {
+	times := pruneBefore(l.failures[key], now.Add(-l.window()))
+	if len(times) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = times
+	return times
+}
+
+// Check evaluates a login from ip for the account email, which may be empty when unknown.
+func (l *LoginLimiter) Check(ip, email string) loginVerdict {
+	verdict := loginVerdict{remaining: -1}
+	if l == nil || l.limits.MaxFailures <= 0 {
+		return verdict
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	verdict.remaining = l.limits.MaxFailures
+	for _, source := range loginSources(ip, email) {
+		times := l.recent(source.key, now)
+		if len(times) < l.limits.MaxFailures {
+			verdict.remaining = min(verdict.remaining, l.limits.MaxFailures-len(times))
+			continue
+		}
+		verdict.remaining = 0
+		if source.scope == "account" {
+			verdict.requireChallenge = true
+			continue
+		}
+		if wait := times[len(times)-l.limits.MaxFailures].Add(l.window()).Sub(now); wait > verdict.retryAfter {
+			verdict.retryAfter = wait
+			verdict.scope = source.scope
+		}
+	}
+	return verdict
+}
+
+// RecordFailure counts a failed login against ip and the account email.
+func (l *LoginLimiter) RecordFailure(ip, email string) {
+	if l == nil || l.limits.MaxFailures <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.failures) > loginSweepSize {
+		for key := range l.failures {
+			l.recent(key, now)
+		}
+	}
+	for _, source := range loginSources(ip, email) {
+		l.failures[source.key] = append(l.recent(source.key, now), now)
+	}
+}
+
+// RecordSuccess forgets the failures of the account. Those of the IP address are kept, so
+// signing in to an own account does not reset guessing at others.
+func (l *LoginLimiter) RecordSuccess(email string) {
+	if l == nil || email == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, "account:"+email)
+}
+
+// checkLoginLimit answers the request with 429 while its IP address has to wait after too many
+// failed logins. Otherwise it returns the verdict, which tells whether the account asks for a
+// challenge.
+func (s *Server) checkLoginLimit(c *gin.Context, email string) (loginVerdict, bool) {
+	verdict := s.loginLimiter.Check(s.clientSource(c.Request), email)
+	if verdict.retryAfter <= 0 {
+		return verdict, true
+	}
+	log.Printf("login limit: blocked scope=%s ip=%s retry_after=%s", verdict.scope, s.clientSource(c.Request), verdict.retryAfter.Round(time.Second))
+	c.Writer.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(verdict.retryAfter)))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":               "Zbyt wiele nieudanych prób logowania. Spróbuj ponownie później.",
+		"code":                "login_limited",
+		"scope":               verdict.scope,
+		"retry_after_seconds": retryAfterSeconds(verdict.retryAfter),
+	})
+	return verdict, false
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds, so a client counting down never retries
+// too early.
+func retryAfterSeconds(wait time.Duration) int {
+	return int((wait + time.Second - 1) / time.Second)
+}
+
+// handleAuthLimits tells the login form whether the current IP address has to wait after failed
+// logins and for how long, so it can show a countdown, and whether the account of ?email= asks
+// for a challenge. attempts_left is omitted while logins are not limited.
+func (s *Server) handleAuthLimits(c *gin.Context) {
+	email := s.normalizeEmail(c.Query("email"))
+	verdict := s.loginLimiter.Check(s.clientSource(c.Request), email)
+	response := gin.H{
+		"throttled":           verdict.retryAfter > 0,
+		"retry_after_seconds": retryAfterSeconds(verdict.retryAfter),
+	}
+	if verdict.retryAfter > 0 {
+		response["scope"] = verdict.scope
+		response["retry_at"] = time.Now().UTC().Add(time.Duration(retryAfterSeconds(verdict.retryAfter)) * time.Second).Truncate(time.Second)
+	}
+	if verdict.requireChallenge {
+		response["captcha"] = "challenge"
+	}
+	if verdict.remaining >= 0 {
+		response["attempts_left"] = verdict.remaining
+	}
+	c.JSON(http.StatusOK, response)
+}
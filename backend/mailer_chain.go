@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+// buildMailer arranges the available mailers in the configured priority order. A single
+// transport is used directly; several are wrapped in a failover chain whose deliveries are counted.
+func buildMailer(order []string, available map[string]email.Mailer, registry *metrics.Registry) email.Mailer {
+	if len(order) == 0 {
+		order = []string{"console"}
+		if _, ok := available["smtp"]; ok {
+			order = []string{"smtp"}
+		}
+	}
+	transports := make([]email.Transport, 0, len(order))
+	for _, name := range order {
+		mailer, ok := available[name]
+		if !ok {
+			log.Printf("email transport %s is not available; skipping", name)
+			continue
+		}
+		transports = append(transports, email.Transport{Name: name, Mailer: mailer})
+	}
+	if len(transports) == 0 {
+		log.Printf("no email transport available; using console mailer")
+		return available["console"]
+	}
+	if len(transports) == 1 {
+		return transports[0].Mailer
+	}
+
+	chain := email.NewChain(transports...)
+	chain.SetDeliveryHook(func(kind, transport string, err error) {
+		result := "delivered"
+		if err != nil {
+			result = "failed"
+		}
+		registry.Counter("kuppixel_email_deliveries_total", "Transactional email delivery attempts per transport.", "kind", kind, "transport", transport, "result", result).Inc()
+	})
+	log.Printf("email failover chain enabled: %s", strings.Join(chain.Transports(), " -> "))
+	return chain
+}
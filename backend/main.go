@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net"
@@ -17,18 +18,34 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	gin "github.com/gin-gonic/gin"
 
+	"github.com/example/kup-piksel/internal/activationcode"
+	"github.com/example/kup-piksel/internal/archive"
+	"github.com/example/kup-piksel/internal/cloudflare"
 	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/currency"
+	"github.com/example/kup-piksel/internal/elasticlog"
 	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/emailaddr"
+	"github.com/example/kup-piksel/internal/fcm"
+	"github.com/example/kup-piksel/internal/httpclient"
+	"github.com/example/kup-piksel/internal/jobs"
+	"github.com/example/kup-piksel/internal/linkcheck"
+	"github.com/example/kup-piksel/internal/linkpreview"
+	"github.com/example/kup-piksel/internal/metrics"
+	"github.com/example/kup-piksel/internal/moderation"
 	"github.com/example/kup-piksel/internal/storage"
 	"github.com/example/kup-piksel/internal/storage/mysql"
 	"github.com/example/kup-piksel/internal/storage/sqlite"
+	"github.com/example/kup-piksel/internal/usage"
+	"github.com/example/kup-piksel/internal/webhook"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -36,20 +53,37 @@ import (
 var frontendFS embed.FS
 
 type PixelUpdate struct {
-	ID     int    `json:"id"`
-	Status string `json:"status"`
-	Color  string `json:"color"`
-	URL    string `json:"url"`
+	ID          int    `json:"id"`
+	Status      string `json:"status"`
+	Color       string `json:"color"`
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 type UpdatePixelRequest struct {
 	Pixels []PixelUpdate `json:"pixels"`
+	// License optionally declares who holds the rights to the artwork placed on the purchased pixels.
+	License *pixelLicenseRequest `json:"license,omitempty"`
+	// RentalDays rents the pixels for that many days instead of buying them; see rentals.go.
+	RentalDays int `json:"rental_days,omitempty"`
+	// Quote is a price quote from GET /api/pixels/quote whose prices the purchase is charged.
+	Quote string `json:"quote,omitempty"`
+	// GiftTo is the email of another account that becomes the owner of the pixels; see pixel_gifts.go.
+	GiftTo string `json:"gift_to,omitempty"`
+
+	// prices are the prices settled by resolvePurchasePrices; nil charges the current ones.
+	prices *priceList
+	// recipient is the account resolved from GiftTo by resolveGiftRecipient.
+	recipient *storage.User
 }
 
 type PixelUpdateResult struct {
 	ID    int            `json:"id"`
 	Pixel *storage.Pixel `json:"pixel,omitempty"`
 	Error string         `json:"error,omitempty"`
+	// Code identifies errors clients handle specially, e.g. "pixel_quota_exceeded".
+	Code string `json:"code,omitempty"`
 }
 
 type Server struct {
@@ -57,32 +91,116 @@ type Server struct {
 	sessions                 *SessionManager
 	mailer                   email.Mailer
 	verificationBaseURL      string
+	verificationLink         config.LinkTemplate
 	verificationTokenTTL     time.Duration
+	verificationTokenBytes   int
 	passwordResetBaseURL     string
+	passwordResetLink        config.LinkTemplate
 	passwordResetTokenTTL    time.Duration
+	passwordResetTokenBytes  int
+	emailLanguage            string
+	linkSigner               *linkSigner
 	disableVerificationEmail bool
 	pixelCostPoints          int64
 	turnstileSecret          string
 	turnstileVerify          turnstileVerifier
+	adminEmails              map[string]struct{}
+	redeemBaseURL            string
+	redeemHandoffs           *RedeemHandoffManager
+	visitorSalt              visitorSalt
+	redemptionGuard          *RedemptionGuard
+	registrationLimiter      *RegistrationLimiter
+	loginLimiter             *LoginLimiter
+	formTokenKey             []byte
+	purchaseMaxBytes         int64
+	purchaseChunkSize        int
+	asyncPurchaseThreshold   int
+	pixelQuota               int
+	boards                   []config.Board
+	invitesRequired          bool
+	invitesPerUser           int
+	leaderboardSize          int
+	leaderboard              *leaderboardCache
+	purchaseJobs             *jobs.Queue
+	pixelFeed                *PixelFeed
+	codeFormat               activationcode.Format
+	currency                 *currency.Converter
+	displayCurrency          string
+	paymentBundles           []config.PaymentBundle
+	paymentWebhookSecret     string
+	readOnly                 bool
+	metrics                  *metrics.Registry
+	gridCache                *GridCache
+	gridSnapshot             *GridSnapshot
+	canvasArchive            *canvasArchive
+	gridVersion              *GridVersion
+	tileSize                 int
+	logRing                  *LogRing
+	consistency              lastConsistencyReport
+	contentBlocklist         atomic.Pointer[contentBlocklist]
+	allowedLinkDomains       []string
+	configPath               string
+	cdnPurger                *cloudflare.Purger
+	purgeURLs                []string
+	pixelPurgeURLs           []string
+	ownerWebhooks            *webhook.Dispatcher
+	push                     pushSender
+	rentals                  config.Rentals
+	engagementReports        config.EngagementReports
+	priceQuotes              *quoteSigner
+	pixelHolds               config.PixelHolds
+	pixelDrafts              config.PixelDrafts
+	moderation               config.Moderation
+	pixelRefundFraction      float64
+	customDomains            config.CustomDomains
+	ipBuckets                ipBuckets
+	domainRoutes             *customDomainRoutes
+	lookupTXT                func(ctx context.Context, name string) ([]string, error)
+	linkPreviews             *linkpreview.Fetcher
+	linkChecker              linkChecker
+	moderator                moderation.Moderator
+	moderationFailOpen       bool
+	minimumAge               int
+	emailPolicy              emailaddr.Policy
+	countryPolicy            *countryPolicy
+	apiUsage                 *usage.Tracker
+	apiPlans                 config.APIUsage
 }
 
 type SessionManager struct {
-	mu       sync.RWMutex
-	sessions map[string]int64
+	mu       sync.Mutex
+	sessions map[string]*session
+	// idleTimeout ends sessions unused for that long, however long their cookie still lives; 0
+	// keeps them until the cookie expires.
+	idleTimeout time.Duration
+}
+
+// session is a signed-in browser; lastSeen is bumped every time the session is used.
+type session struct {
+	userID    int64
+	createdAt time.Time
+	lastSeen  time.Time
 }
 
 type turnstileResponse struct {
 	Success    bool     `json:"success"`
 	ErrorCodes []string `json:"error-codes"`
+	Action     string   `json:"action"`
 }
 
 type turnstileVerifier func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error)
 
 func NewSessionManager() *SessionManager {
-	return &SessionManager{sessions: make(map[string]int64)}
+	return &SessionManager{sessions: make(map[string]*session)}
 }
 
 func (m *SessionManager) Create(userID int64) (string, error) {
+	return m.Rotate("", userID)
+}
+
+// Rotate invalidates oldID, if set, and returns a new session for userID. Issuing a fresh id on
+// login and other privilege changes keeps a session id planted by an attacker from gaining access.
+func (m *SessionManager) Rotate(oldID string, userID int64) (string, error) {
 	if userID <= 0 {
 		return "", errors.New("invalid user id")
 	}
@@ -98,7 +216,11 @@ func (m *SessionManager) Create(userID int64) (string, error) {
 			m.mu.Unlock()
 			continue
 		}
-		m.sessions[id] = userID
+		if oldID != "" {
+			delete(m.sessions, oldID)
+		}
+		now := time.Now()
+		m.sessions[id] = &session{userID: userID, createdAt: now, lastSeen: now}
 		m.mu.Unlock()
 		return id, nil
 	}
@@ -106,11 +228,22 @@ func (m *SessionManager) Create(userID int64) (string, error) {
 	return "", errors.New("failed to generate unique session id")
 }
 
+// Get returns the user of session id and records the activity. A session idle for longer than
+// the idle timeout is ended instead.
 func (m *SessionManager) Get(id string) (int64, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	userID, ok := m.sessions[id]
-	return userID, ok
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[id]
+	if !ok {
+		return 0, false
+	}
+	now := time.Now()
+	if m.idle(sess, now) {
+		delete(m.sessions, id)
+		return 0, false
+	}
+	sess.lastSeen = now
+	return sess.userID, true
 }
 
 func (m *SessionManager) Delete(id string) {
@@ -119,10 +252,32 @@ func (m *SessionManager) Delete(id string) {
 	m.mu.Unlock()
 }
 
+// DeleteUser removes every session of userID except keep and returns how many were removed.
+func (m *SessionManager) DeleteUser(userID int64, keep string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for id, sess := range m.sessions {
+		if sess.userID == userID && id != keep {
+			delete(m.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
 type authRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Token    string `json:"turnstile_token"`
+	// AgeAttestation and BirthYear are required at registration when ageGate.minimumAge is set.
+	AgeAttestation bool `json:"age_attestation"`
+	BirthYear      int  `json:"birth_year"`
+	// CountryOverride is a support-issued code admitting the email despite country restrictions.
+	CountryOverride string `json:"country_override"`
+	// InviteCode is required at registration while invites.required is set.
+	InviteCode string `json:"invite_code"`
+	formSignals
 }
 
 type passwordResetRequest struct {
@@ -158,11 +313,12 @@ const (
 	defaultVerificationTTL     = 24 * time.Hour
 	defaultConfigPath          = "config.json"
 	turnstileVerifyURL         = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	defaultPurchaseChunkSize   = 500
 )
 
-var activationCodePattern = regexp.MustCompile(`^[A-Z0-9]{4}(?:-[A-Z0-9]{4}){3}$`)
-
-var turnstileHTTPClient = &http.Client{Timeout: 10 * time.Second}
+// defaultTurnstileVerifier calls Turnstile with the default outbound settings for servers built
+// without a verifier.
+var defaultTurnstileVerifier = newTurnstileVerifier(outboundClient(httpclient.NewFactory(httpclient.Config{}, nil), "turnstile"))
 
 func generateSessionID() (string, error) {
 	buf := make([]byte, 32)
@@ -172,6 +328,21 @@ func generateSessionID() (string, error) {
 	return hex.EncodeToString(buf), nil
 }
 
+// rotateSession starts a new session for userID, invalidating the session id the request carried, and
+// sets the new cookie. It must be used whenever the privileges tied to a session change.
+func (s *Server) rotateSession(c *gin.Context, userID int64) (string, error) {
+	oldID, _, err := readSessionCookie(c.Request)
+	if err != nil {
+		log.Printf("read session cookie: %v", err)
+	}
+	sessionID, err := s.sessions.Rotate(oldID, userID)
+	if err != nil {
+		return "", err
+	}
+	setSessionCookie(c, sessionID)
+	return sessionID, nil
+}
+
 func setSessionCookie(c *gin.Context, sessionID string) {
 	http.SetCookie(c.Writer, &http.Cookie{
 		Name:     sessionCookieName,
@@ -209,36 +380,39 @@ func readSessionCookie(r *http.Request) (string, bool, error) {
 	return cookie.Value, true, nil
 }
 
-func defaultTurnstileVerifier(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
-	form := url.Values{}
-	form.Set("secret", secret)
-	form.Set("response", token)
-	if remoteIP != "" {
-		form.Set("remoteip", remoteIP)
-	}
+// newTurnstileVerifier verifies tokens with Cloudflare's siteverify endpoint through client.
+func newTurnstileVerifier(client *http.Client) turnstileVerifier {
+	return func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		form := url.Values{}
+		form.Set("secret", secret)
+		form.Set("response", token)
+		if remoteIP != "" {
+			form.Set("remoteip", remoteIP)
+		}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, turnstileVerifyURL, strings.NewReader(form.Encode()))
-	if err != nil {
-		return turnstileResponse{}, fmt.Errorf("create turnstile request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, turnstileVerifyURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return turnstileResponse{}, fmt.Errorf("create turnstile request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := turnstileHTTPClient.Do(req)
-	if err != nil {
-		return turnstileResponse{}, fmt.Errorf("execute turnstile request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
+		resp, err := client.Do(req)
+		if err != nil {
+			return turnstileResponse{}, fmt.Errorf("execute turnstile request: %w", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		return turnstileResponse{}, fmt.Errorf("turnstile verification status %d", resp.StatusCode)
-	}
+		if resp.StatusCode != http.StatusOK {
+			return turnstileResponse{}, fmt.Errorf("turnstile verification status %d", resp.StatusCode)
+		}
 
-	var result turnstileResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return turnstileResponse{}, fmt.Errorf("decode turnstile response: %w", err)
-	}
+		var result turnstileResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return turnstileResponse{}, fmt.Errorf("decode turnstile response: %w", err)
+		}
 
-	return result, nil
+		return result, nil
+	}
 }
 
 func sanitizeUser(user storage.User) userResponse {
@@ -319,27 +493,30 @@ func openConfiguredStore(cfg *config.Config) (storage.Store, string, error) {
 	}
 }
 
-func generateVerificationToken() (string, error) {
-	buf := make([]byte, 32)
+// generateVerificationToken returns size random bytes hex-encoded; 0 uses the default length.
+func generateVerificationToken(size int) (string, error) {
+	if size <= 0 {
+		size = config.Default().Verification.TokenBytes
+	}
+	buf := make([]byte, size)
 	if _, err := rand.Read(buf); err != nil {
 		return "", fmt.Errorf("generate verification token: %w", err)
 	}
 	return hex.EncodeToString(buf), nil
 }
 
-func buildVerificationLink(base string, token string) (string, error) {
-	trimmed := strings.TrimRight(base, "/")
-	if trimmed == "" {
-		trimmed = defaultVerificationBaseURL
-	}
-	if _, err := url.Parse(trimmed); err != nil {
-		return "", fmt.Errorf("invalid base url: %w", err)
-	}
-	escapedToken := url.QueryEscape(token)
-	return fmt.Sprintf("%s/verify?token=%s", trimmed, escapedToken), nil
+func buildVerificationLink(base string, link config.LinkTemplate, token, language string) (string, error) {
+	return buildTokenLink(base, link, config.Default().Verification.Link.Path, token, language)
 }
 
-func buildPasswordResetLink(base string, token string) (string, error) {
+func buildPasswordResetLink(base string, link config.LinkTemplate, token, language string) (string, error) {
+	return buildTokenLink(base, link, config.Default().PasswordReset.Link.Path, token, language)
+}
+
+// buildTokenLink appends the link template to base and fills in its placeholders, escaped for the
+// part of the URL they land in. A template without a path uses fallback, and an empty language the
+// default email language.
+func buildTokenLink(base string, link config.LinkTemplate, fallback, token, language string) (string, error) {
 	trimmed := strings.TrimRight(base, "/")
 	if trimmed == "" {
 		trimmed = defaultVerificationBaseURL
@@ -347,8 +524,32 @@ func buildPasswordResetLink(base string, token string) (string, error) {
 	if _, err := url.Parse(trimmed); err != nil {
 		return "", fmt.Errorf("invalid base url: %w", err)
 	}
-	escapedToken := url.QueryEscape(token)
-	return fmt.Sprintf("%s/reset-password?token=%s", trimmed, escapedToken), nil
+	if language == "" {
+		language = config.Default().Email.Language
+	}
+	pattern := link.Path
+	if pattern == "" {
+		pattern = fallback
+	}
+	path, rawQuery, _ := strings.Cut(pattern, "?")
+	path = strings.NewReplacer(config.LinkTokenPlaceholder, url.PathEscape(token), config.LinkLanguagePlaceholder, url.PathEscape(language)).Replace(path)
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("invalid link query: %w", err)
+	}
+	fill := strings.NewReplacer(config.LinkTokenPlaceholder, token, config.LinkLanguagePlaceholder, language)
+	for _, values := range query {
+		for i := range values {
+			values[i] = fill.Replace(values[i])
+		}
+	}
+	for name, value := range link.Query {
+		query.Set(name, fill.Replace(value))
+	}
+	if len(query) == 0 {
+		return trimmed + path, nil
+	}
+	return trimmed + path + "?" + query.Encode(), nil
 }
 
 func (s *Server) getSessionUser(c *gin.Context) (storage.User, string, bool) {
@@ -403,13 +604,19 @@ func extractRemoteIP(r *http.Request) string {
 }
 
 func (s *Server) requireTurnstile(c *gin.Context, token string) bool {
+	return s.requireTurnstileAction(c, token, "")
+}
+
+// requireTurnstileAction verifies the token and, when action is set, that it was issued
+// by a widget rendered with that action.
+func (s *Server) requireTurnstileAction(c *gin.Context, token, action string) bool {
 	trimmed := strings.TrimSpace(token)
 	if trimmed == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Potwierdź, że nie jesteś robotem."})
 		return false
 	}
 	if strings.TrimSpace(s.turnstileSecret) == "" {
-		log.Printf("turnstile secret key missing in configuration")
+		log.Printf("turnstile secret key missing in configuration: tx=%s", transactionID(c.Request.Context()))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Weryfikacja bezpieczeństwa jest chwilowo niedostępna."})
 		return false
 	}
@@ -425,22 +632,29 @@ func (s *Server) requireTurnstile(c *gin.Context, token string) bool {
 	remoteIP := extractRemoteIP(c.Request)
 	result, err := verifier(ctx, s.turnstileSecret, trimmed, remoteIP)
 	if err != nil {
-		log.Printf("turnstile verification error: %v", err)
+		log.Printf("turnstile verification error: tx=%s err=%v", transactionID(c.Request.Context()), err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Nie udało się zweryfikować zabezpieczenia. Spróbuj ponownie."})
 		return false
 	}
 	if !result.Success {
 		if len(result.ErrorCodes) > 0 {
-			log.Printf("turnstile verification failed: codes=%v", result.ErrorCodes)
+			log.Printf("turnstile verification failed: tx=%s codes=%v", transactionID(c.Request.Context()), result.ErrorCodes)
 		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nieprawidłowa weryfikacja CAPTCHA."})
 		return false
 	}
+	if action != "" && result.Action != action {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wymagana jest dodatkowa weryfikacja CAPTCHA.", "captcha": "challenge"})
+		return false
+	}
 
 	return true
 }
 
 func main() {
+	logRing := NewLogRing(logRingCapacity)
+	log.SetOutput(io.MultiWriter(os.Stderr, logRing))
+
 	configPath := os.Getenv("PIXEL_CONFIG_PATH")
 	if configPath == "" {
 		configPath = defaultConfigPath
@@ -467,6 +681,42 @@ func main() {
 	}
 	log.Printf("loaded config from %s", configPath)
 
+	ctx := context.Background()
+	if len(cfg.Tenants) > 0 {
+		if err := checkTenantEnvironment(); err != nil {
+			log.Fatalf("tenants: %v", err)
+		}
+	}
+	server, router, closeStore := newBoard(ctx, cfg, configPath, nil, logRing)
+	defer closeStore()
+	handler := http.Handler(router)
+	var tenantServers map[string]*Server
+	if len(cfg.Tenants) > 0 {
+		tenants, closeTenants, err := newTenantRouter(ctx, cfg, router)
+		if err != nil {
+			log.Fatalf("tenants: %v", err)
+		}
+		defer closeTenants()
+		handler = tenants
+		tenantServers = tenants.servers
+	}
+
+	build := currentBuild()
+	log.Printf("build: version=%s commit=%s frontend_hash=%s go_version=%s", build.Version, build.Commit, build.FrontendHash, build.GoVersion)
+
+	startDiagnosticsListener(cfg.Diagnostics.ListenAddr)
+	startMetricsListener(cfg.Metrics.ListenAddr, metricsHandler(server, tenantServers))
+
+	log.Println("Kup Piksel backend listening on :3000")
+	if err := http.ListenAndServe(":3000", handler); err != nil {
+		log.Fatalf("server stopped: %v", err)
+	}
+}
+
+// newBoard sets up the store, background jobs and routes of one billboard. tenant is nil for the
+// board of the main config file; logRing is only given to that board. The returned function
+// closes the store.
+func newBoard(ctx context.Context, cfg *config.Config, configPath string, tenant *config.Tenant, logRing *LogRing) (*Server, *gin.Engine, func()) {
 	pixelCost := cfg.PixelCostPoints
 	if pixelCost <= 0 {
 		pixelCost = config.Default().PixelCostPoints
@@ -477,26 +727,48 @@ func main() {
 	if err != nil {
 		log.Fatalf("configure storage: %v", err)
 	}
-	defer func() {
-		if cerr := store.Close(); cerr != nil {
-			log.Printf("close store: %v", cerr)
-		}
-	}()
 	log.Printf("storage backend: %s", storeDescription)
 
-	ctx := context.Background()
 	if err := store.EnsureSchema(ctx); err != nil {
 		log.Fatalf("ensure schema: %v", err)
 	}
-	seedDemoPixels(ctx, store)
+	if missing, err := store.MissingIndexes(ctx); err != nil {
+		log.Printf("index check: %v", err)
+	} else if len(missing) > 0 {
+		log.Printf("index check: missing indexes %s; queries on these columns will scan whole tables", strings.Join(missing, ", "))
+	}
+	if err := runStartupHooks(ctx, store, startupHooks(cfg.StartupHooks)); err != nil {
+		log.Fatalf("startup hooks: %v", err)
+	}
+	store.SetPixelQuota(cfg.Purchases.MaxPixelsPerUser)
+
+	// Enabled after schema setup so the initial pixel seed is not reported as slow.
+	registry := metrics.NewRegistry()
+	if threshold := cfg.Database.SlowQueryThresholdMs; threshold > 0 {
+		store.SetSlowQueryHook(time.Duration(threshold)*time.Millisecond, slowQueryHook(registry, cfg.Database.Driver))
+	}
+
+	outbound := httpclient.NewFactory(httpclient.Config{
+		Timeout:          time.Duration(cfg.OutboundHTTP.TimeoutSeconds) * time.Second,
+		MaxRetries:       cfg.OutboundHTTP.MaxRetries,
+		RetryDelay:       time.Duration(cfg.OutboundHTTP.RetryDelayMs) * time.Millisecond,
+		FailureThreshold: cfg.OutboundHTTP.FailureThreshold,
+		OpenDuration:     time.Duration(cfg.OutboundHTTP.OpenSeconds) * time.Second,
+	}, registry)
 
 	router := gin.Default()
-	verificationBaseURL := strings.TrimSpace(os.Getenv("VERIFICATION_LINK_BASE_URL"))
+	// Environment overrides apply to the primary board only; tenants have their own origin.
+	verificationBaseURL, passwordResetBaseURL := "", ""
+	if tenant != nil {
+		verificationBaseURL = tenant.BaseURL
+	} else {
+		verificationBaseURL = strings.TrimSpace(os.Getenv("VERIFICATION_LINK_BASE_URL"))
+		passwordResetBaseURL = strings.TrimSpace(os.Getenv("PASSWORD_RESET_LINK_BASE_URL"))
+	}
 	if verificationBaseURL == "" {
 		verificationBaseURL = defaultVerificationBaseURL
 	}
 
-	passwordResetBaseURL := strings.TrimSpace(os.Getenv("PASSWORD_RESET_LINK_BASE_URL"))
 	if passwordResetBaseURL == "" {
 		passwordResetBaseURL = strings.TrimSpace(cfg.PasswordReset.BaseURL)
 	}
@@ -515,7 +787,7 @@ func main() {
 	}
 
 	smtpConfigured := false
-	var mailer email.Mailer = email.NewConsoleMailer("Kup Piksel", cfg.Email.Language)
+	availableMailers := map[string]email.Mailer{"console": email.NewConsoleMailer("Kup Piksel", cfg.Email.Language)}
 	if cfg.SMTP != nil {
 		log.Printf(
 			"smtp config detected: host=%s port=%d username=%s from_email=%s from_name=%s language=%s",
@@ -531,32 +803,318 @@ func main() {
 			log.Printf("failed to initialise smtp mailer: %v", err)
 			log.Printf("falling back to console mailer")
 		} else {
-			mailer = smtpMailer
+			availableMailers["smtp"] = smtpMailer
 			smtpConfigured = true
 			log.Printf("smtp mailer enabled for %s", cfg.SMTP.Address())
 		}
 	} else {
-		log.Printf("smtp config missing; using console mailer")
+		log.Printf("smtp config missing")
 	}
+	if cfg.Mailgun != nil {
+		mailgunMailer, err := email.NewMailgunMailer(*cfg.Mailgun, cfg.Email.Language, outboundClient(outbound, "mailgun"))
+		if err != nil {
+			log.Printf("failed to initialise mailgun mailer: %v", err)
+		} else {
+			availableMailers["mailgun"] = mailgunMailer
+			log.Printf("mailgun mailer enabled for domain %s", cfg.Mailgun.Domain)
+		}
+	}
+	mailer := buildMailer(cfg.Email.Transports, availableMailers, registry)
 
 	turnstileSecret := strings.TrimSpace(cfg.TurnstileSecretKey)
 
+	redeemBaseURL := strings.TrimSpace(cfg.ActivationCodes.RedeemBaseURL)
+	if redeemBaseURL == "" {
+		redeemBaseURL = verificationBaseURL
+	}
+
+	codeFormat := activationcode.Format{
+		Groups:         cfg.ActivationCodes.Groups,
+		GroupLength:    cfg.ActivationCodes.GroupLength,
+		Alphabet:       cfg.ActivationCodes.Alphabet,
+		AllowAmbiguous: cfg.ActivationCodes.AllowAmbiguous,
+	}
+	if err := codeFormat.Validate(); err != nil {
+		log.Fatalf("invalid activation code format: %v", err)
+	}
+
+	converter, err := currency.NewConverter(currency.Config{
+		Base:       cfg.Currency.Base,
+		PointValue: cfg.Currency.PointValue,
+		Rates:      cfg.Currency.Rates,
+		RatesURL:   cfg.Currency.RatesURL,
+		RatesTTL:   time.Duration(cfg.Currency.RatesTTLMinutes) * time.Minute,
+		Client:     outboundClient(outbound, "currency-rates"),
+	})
+	if err != nil {
+		log.Fatalf("invalid currency configuration: %v", err)
+	}
+
+	// Form tokens only have to outlive an open browser tab, so a per-process key is enough.
+	formTokenKey := make([]byte, 32)
+	if _, err := rand.Read(formTokenKey); err != nil {
+		log.Fatalf("generate form token key: %v", err)
+	}
+
 	server := &Server{
 		store:                    store,
-		sessions:                 NewSessionManager(),
+		sessions:                 &SessionManager{sessions: make(map[string]*session), idleTimeout: time.Duration(cfg.Sessions.IdleTimeoutMinutes) * time.Minute},
 		mailer:                   mailer,
 		verificationBaseURL:      verificationBaseURL,
+		verificationLink:         cfg.Verification.Link,
 		verificationTokenTTL:     verificationTTL,
+		verificationTokenBytes:   cfg.Verification.TokenBytes,
 		passwordResetBaseURL:     passwordResetBaseURL,
+		passwordResetLink:        cfg.PasswordReset.Link,
 		passwordResetTokenTTL:    passwordResetTTL,
+		passwordResetTokenBytes:  cfg.PasswordReset.TokenBytes,
+		emailLanguage:            cfg.Email.Language,
+		linkSigner:               newLinkSigner(cfg.LinkSigningSecret),
 		disableVerificationEmail: cfg.DisableVerificationEmail,
 		pixelCostPoints:          int64(pixelCost),
 		turnstileSecret:          turnstileSecret,
-		turnstileVerify:          defaultTurnstileVerifier,
+		turnstileVerify:          newTurnstileVerifier(outboundClient(outbound, "turnstile")),
+		adminEmails:              newAdminSet(cfg.AdminEmails),
+		redeemBaseURL:            redeemBaseURL,
+		redeemHandoffs:           NewRedeemHandoffManager(),
+		redemptionGuard:          NewRedemptionGuard(),
+		registrationLimiter:      NewRegistrationLimiter(cfg.RegistrationLimits),
+		loginLimiter:             NewLoginLimiter(cfg.LoginLimits),
+		formTokenKey:             formTokenKey,
+		purchaseMaxBytes:         cfg.Purchases.MaxRequestBytes,
+		purchaseChunkSize:        cfg.Purchases.ChunkSize,
+		asyncPurchaseThreshold:   cfg.Purchases.AsyncThreshold,
+		pixelQuota:               cfg.Purchases.MaxPixelsPerUser,
+		boards:                   cfg.Boards,
+		invitesRequired:          cfg.Invites.Required,
+		invitesPerUser:           cfg.Invites.PerUser,
+		leaderboardSize:          cfg.Leaderboard.Size,
+		leaderboard:              newLeaderboardCache(time.Duration(cfg.Leaderboard.CacheSeconds) * time.Second),
+		purchaseJobs:             jobs.NewQueue(purchaseJobWorkers, purchaseJobCapacity),
+		pixelFeed:                NewPixelFeed(),
+		gridVersion:              NewGridVersion(),
+		tileSize:                 cfg.Tiles.Size,
+		logRing:                  logRing,
+		configPath:               configPath,
+		allowedLinkDomains:       cfg.PixelContent.AllowedDomains,
+		codeFormat:               codeFormat,
+		currency:                 converter,
+		displayCurrency:          cfg.Currency.Display,
+		paymentBundles:           cfg.Payments.Bundles,
+		paymentWebhookSecret:     cfg.Payments.WebhookSecret,
+		readOnly:                 cfg.ReadOnly,
+		minimumAge:               cfg.AgeGate.MinimumAge,
+		metrics:                  registry,
+		apiUsage:                 usage.NewTracker(),
+		apiPlans:                 cfg.APIUsage,
+		rentals:                  cfg.Rentals,
+		engagementReports:        cfg.EngagementReports,
+		pixelHolds:               cfg.PixelHolds,
+		pixelDrafts:              cfg.PixelDrafts,
+		moderation:               cfg.Moderation,
+		pixelRefundFraction:      cfg.PixelReleases.RefundFraction,
+		customDomains:            cfg.CustomDomains,
+		ipBuckets:                ipBuckets{ipv4Bits: cfg.IPBuckets.IPv4PrefixLength, ipv6Bits: cfg.IPBuckets.IPv6PrefixLength},
+		lookupTXT:                net.DefaultResolver.LookupTXT,
+		emailPolicy: emailaddr.Policy{
+			ProviderRules:    cfg.EmailNormalization.ProviderRules,
+			StripPlusAliases: cfg.EmailNormalization.StripPlusAliases,
+		},
+	}
+	// Accounts are stored under canonical addresses, so admins must match in that form too.
+	for _, address := range cfg.AdminEmails {
+		if canonical, normErr := server.emailPolicy.Normalize(address); normErr == nil {
+			server.adminEmails[canonical] = struct{}{}
+		}
+	}
+	if server.countryPolicy, err = newCountryPolicy(cfg.CountryRestrictions); err != nil {
+		log.Fatalf("invalid country restrictions: %v", err)
+	}
+	if _, err := server.loadContentBlocklist(ctx, cfg.PixelContent.BlockedTerms); err != nil {
+		log.Fatalf("invalid content blocklist: %v", err)
+	}
+	if cfg.Cloudflare.Enabled() {
+		purger, err := cloudflare.NewPurger(cloudflare.Config{
+			ZoneID:   cfg.Cloudflare.ZoneID,
+			APIToken: cfg.Cloudflare.APIToken,
+			Interval: time.Duration(cfg.Cloudflare.PurgeIntervalSeconds) * time.Second,
+			Client:   outboundClient(outbound, "cloudflare"),
+		})
+		if err != nil {
+			log.Fatalf("invalid cloudflare configuration: %v", err)
+		}
+		server.cdnPurger = purger
+		server.purgeURLs = prefixURLs(cfg.Cloudflare.SiteURL, cfg.Cloudflare.PurgePaths)
+		server.pixelPurgeURLs = prefixURLs(cfg.Cloudflare.SiteURL, cfg.Cloudflare.PixelPurgePaths)
+		go purger.Run(ctx)
+	}
+	if cfg.OwnerWebhooks.Enabled {
+		server.ownerWebhooks = webhook.NewDispatcher(webhook.Config{
+			MaxAttempts:         cfg.OwnerWebhooks.MaxAttempts,
+			Timeout:             time.Duration(cfg.OwnerWebhooks.TimeoutSeconds) * time.Second,
+			AllowPrivateTargets: cfg.OwnerWebhooks.AllowPrivateTargets,
+			WrapTransport:       outboundHostTransport(outbound, "owner-webhooks"),
+		})
+		go server.ownerWebhooks.Run(ctx)
+	}
+	if cfg.LinkPreviews.Enabled {
+		server.linkPreviews = linkpreview.NewFetcher(linkpreview.Config{
+			TTL:                 time.Duration(cfg.LinkPreviews.CacheTTLMinutes) * time.Minute,
+			Timeout:             time.Duration(cfg.LinkPreviews.TimeoutSeconds) * time.Second,
+			MaxBytes:            cfg.LinkPreviews.MaxBytes,
+			DomainBudget:        cfg.LinkPreviews.DomainFetchesPerHour,
+			AllowPrivateTargets: cfg.LinkPreviews.AllowPrivateTargets,
+			WrapTransport:       outboundHostTransport(outbound, "link-previews"),
+		})
+	}
+	if cfg.LinkChecks.Enabled {
+		server.linkChecker = linkcheck.NewChecker(linkcheck.Config{
+			Timeout:             time.Duration(cfg.LinkChecks.TimeoutSeconds) * time.Second,
+			AllowPrivateTargets: cfg.LinkChecks.AllowPrivateTargets,
+			WrapTransport:       outboundHostTransport(outbound, "link-checks"),
+		})
+	}
+	if cfg.ContentModeration.Provider != moderation.ProviderNone {
+		moderator, err := moderation.New(moderation.Config{
+			Provider:           cfg.ContentModeration.Provider,
+			SafeBrowsingAPIKey: cfg.ContentModeration.SafeBrowsingAPIKey,
+			Client:             outboundClient(outbound, "moderation"),
+		})
+		if err != nil {
+			log.Fatalf("invalid content moderation configuration: %v", err)
+		}
+		server.moderator, server.moderationFailOpen = moderator, cfg.ContentModeration.FailOpen
+	}
+	if server.priceQuotes, err = newQuoteSigner(cfg.PriceQuotes.Secret, time.Duration(cfg.PriceQuotes.TTLMinutes)*time.Minute); err != nil {
+		log.Fatalf("invalid price quote configuration: %v", err)
+	}
+	if cfg.Push.FCMServiceAccountFile != "" {
+		account, err := fcm.LoadServiceAccount(cfg.Push.FCMServiceAccountFile)
+		if err != nil {
+			log.Fatalf("invalid push configuration: %v", err)
+		}
+		client, err := fcm.NewClient(account, "", outboundClient(outbound, "fcm"))
+		if err != nil {
+			log.Fatalf("invalid push configuration: %v", err)
+		}
+		server.push = client
+	}
+	started := time.Now()
+	snapshot, err := LoadGridSnapshot(ctx, store, cfg.GridCache.SnapshotPath)
+	if err != nil {
+		log.Fatalf("load grid snapshot: %v", err)
+	}
+	server.gridSnapshot = snapshot
+	log.Printf("grid snapshot: loaded path=%q duration=%s", cfg.GridCache.SnapshotPath, time.Since(started).Round(time.Millisecond))
+	runner := jobs.NewRunner()
+	if cfg.GridCache.SnapshotPath != "" {
+		runner.Add("grid-snapshot-save", gridSnapshotSaveInterval, func(ctx context.Context) error {
+			return server.gridSnapshot.Save()
+		})
+	}
+	if tenant == nil && cfg.ElasticLogs.URL != "" {
+		// The log is process-wide, so only the primary board ships it. The client skips
+		// upstreamTransport, whose log line per call would be shipped in turn.
+		shipper := elasticlog.New(elasticlog.Config{
+			URL:                  cfg.ElasticLogs.URL,
+			Index:                cfg.ElasticLogs.Index,
+			APIKey:               cfg.ElasticLogs.APIKey,
+			BufferSize:           cfg.ElasticLogs.BufferSize,
+			BatchSize:            cfg.ElasticLogs.BatchSize,
+			MaxConcurrentFlushes: cfg.ElasticLogs.MaxConcurrentFlushes,
+		}, outbound.Client("elastic-logs", nil), registry)
+		log.SetOutput(io.MultiWriter(os.Stderr, logRing, shipper))
+		runner.Add("elastic-logs", time.Duration(cfg.ElasticLogs.FlushIntervalSeconds)*time.Second, shipper.Flush)
+		log.Printf("elastic logs: shipping to %s index=%s", cfg.ElasticLogs.URL, cfg.ElasticLogs.Index)
+	}
+	if cfg.Heartbeat.URL != "" {
+		runner.Add("heartbeat", time.Duration(cfg.Heartbeat.IntervalSeconds)*time.Second, server.heartbeat(outboundClient(outbound, "heartbeat"), cfg.Heartbeat.URL, time.Now()))
+	}
+	if cfg.Consistency.IntervalMinutes > 0 {
+		runner.Add("consistency-check", time.Duration(cfg.Consistency.IntervalMinutes)*time.Minute, func(ctx context.Context) error {
+			_, err := server.checkConsistency(ctx, cfg.Consistency.Repair)
+			return err
+		})
+	}
+	if server.linkSigner != nil {
+		runner.Add("link-nonces-prune", linkNoncePruneInterval, func(ctx context.Context) error {
+			removed, err := store.DeleteExpiredLinkNonces(ctx, time.Now())
+			if removed > 0 {
+				log.Printf("signed links: pruned %d expired nonces", removed)
+			}
+			return err
+		})
+	}
+	runner.Add("api-usage-prune", time.Hour, func(ctx context.Context) error {
+		if removed := server.apiUsage.Prune(apiUsagePruneIdle); removed > 0 {
+			log.Printf("api usage: pruned %d idle accounts", removed)
+		}
+		return nil
+	})
+	if server.sessions.idleTimeout > 0 {
+		runner.Add("sessions-prune", sessionPruneInterval, func(ctx context.Context) error {
+			if removed := server.sessions.PruneIdle(time.Now()); removed > 0 {
+				log.Printf("sessions: pruned %d idle sessions", removed)
+			}
+			return nil
+		})
+	}
+	runner.Add("redeem-handoffs-prune", redeemHandoffPruneInterval, func(ctx context.Context) error {
+		if removed := server.redeemHandoffs.Prune(); removed > 0 {
+			log.Printf("redeem handoffs: pruned %d expired handoffs", removed)
+		}
+		return nil
+	})
+	runner.Add("pixel-rentals", pixelRentalCheckInterval, server.expirePixelRentals)
+	runner.Add("business-metrics", time.Duration(cfg.BusinessMetrics.IntervalSeconds)*time.Second, server.refreshBusinessMetrics)
+	if cfg.EngagementReports.Enabled {
+		runner.Add("engagement-reports", engagementReportCheckInterval, server.sendEngagementReports)
+	}
+	if cfg.CanvasArchive.Enabled {
+		var target archive.Store
+		if bucket := cfg.CanvasArchive.S3; bucket.Bucket != "" {
+			target, err = archive.NewS3(archive.S3Config{
+				Endpoint:        bucket.Endpoint,
+				Region:          bucket.Region,
+				Bucket:          bucket.Bucket,
+				Prefix:          bucket.Prefix,
+				AccessKeyID:     bucket.AccessKeyID,
+				SecretAccessKey: bucket.SecretAccessKey,
+				Client:          outboundClient(outbound, "canvas-archive"),
+			})
+		} else {
+			target, err = archive.NewDir(cfg.CanvasArchive.Dir)
+		}
+		if err != nil {
+			log.Fatalf("invalid canvas archive configuration: %v", err)
+		}
+		period := time.Duration(cfg.CanvasArchive.IntervalMinutes) * time.Minute
+		server.canvasArchive = newCanvasArchive(target, period, cfg.CanvasArchive.PNG)
+		runner.Add("canvas-archive", min(period, canvasArchiveCheckInterval), server.archiveCanvas)
+	}
+	runner.Add("pixel-holds-prune", pixelHoldPruneInterval, func(ctx context.Context) error {
+		_, err := store.DeleteExpiredPixelHolds(ctx, time.Now())
+		return err
+	})
+	if cfg.CustomDomains.Enabled {
+		server.domainRoutes = newCustomDomainRoutes()
+		runner.Add("custom-domains", time.Duration(cfg.CustomDomains.CheckIntervalMinutes)*time.Minute, server.checkCustomDomains)
+	}
+	runner.AddQueue(server.purchaseJobs)
+	runner.Add("purchase-jobs-prune", time.Hour, func(ctx context.Context) error {
+		if removed := server.purchaseJobs.Prune(purchaseJobRetention); removed > 0 {
+			log.Printf("purchase job: pruned %d finished jobs", removed)
+		}
+		return nil
+	})
+	runner.Start(ctx)
+
+	if cfg.GridCache.TTLSeconds > 0 {
+		server.gridCache = NewGridCache(time.Duration(cfg.GridCache.TTLSeconds)*time.Second, time.Duration(cfg.GridCache.StaleWhileRevalidateSeconds)*time.Second)
 	}
 
 	log.Printf(
-		"startup config: config_path=%s storage_backend=%s verification_base_url=%s verification_ttl=%s password_reset_base_url=%s reset_ttl=%s smtp_configured=%t disable_verification_email=%t pixel_cost_points=%d email_language=%s turnstile_configured=%t",
+		"startup config: config_path=%s storage_backend=%s verification_base_url=%s verification_ttl=%s password_reset_base_url=%s reset_ttl=%s smtp_configured=%t disable_verification_email=%t pixel_cost_points=%d email_language=%s turnstile_configured=%t admin_count=%d",
 		configPath,
 		storeDescription,
 		verificationBaseURL,
@@ -568,21 +1126,142 @@ func main() {
 		pixelCost,
 		cfg.Email.Language,
 		turnstileSecret != "",
+		len(server.adminEmails),
 	)
 
+	router.Use(server.transactionIDMiddleware)
+	router.Use(server.dbStatsMiddleware)
+	router.Use(server.apiUsageMiddleware)
+	router.Use(server.customDomainMiddleware)
 	router.POST("/api/register", server.handleRegister)
 	router.POST("/api/login", server.handleLogin)
+	router.GET("/api/auth/form-token", server.handleFormToken)
+	router.GET("/api/auth/limits", server.handleAuthLimits)
 	router.POST("/api/logout", server.handleLogout)
 	router.GET("/api/session", server.handleSession)
+	router.GET("/api/config", server.handleConfig)
 	router.GET("/api/account", server.handleAccount)
+	router.GET(apiUsagePath, server.handleAccountUsage)
+	router.GET("/api/account/export", server.handleAccountExport)
+	router.GET("/api/account/sessions", server.handleListSessions)
+	router.GET("/api/account/clicks", server.handleAccountClicks)
+	router.GET("/api/account/pixels/:id/stats", server.handlePixelStats)
+	router.GET("/api/account/webhook", server.handleGetOwnerWebhook)
+	router.PUT("/api/account/webhook", server.handlePutOwnerWebhook)
+	router.DELETE("/api/account/webhook", server.handleDeleteOwnerWebhook)
+	router.GET("/api/account/reports", server.handleGetEngagementReports)
+	router.PUT("/api/account/reports", server.handlePutEngagementReports)
+	router.GET("/api/reports/unsubscribe", server.handleUnsubscribeEngagementReports)
+	router.POST("/api/account/webhook/secret", server.handleRotateOwnerWebhookSecret)
+	router.POST("/api/account/devices/push", server.handleRegisterPushDevice)
+	router.DELETE("/api/account/devices/push", server.handleDeletePushDevice)
+	router.POST("/api/account/pixels/release", server.handleReleasePixels)
+	router.GET("/api/account/domains", server.handleListCustomDomains)
+	router.POST("/api/account/domains", server.handleCreateCustomDomain)
+	router.GET("/api/account/invites", server.handleListInvites)
+	router.GET("/api/account/leaderboard", server.handleGetLeaderboardOptOut)
+	router.POST("/api/account/invites", server.handleCreateInvite)
+	router.PUT("/api/account/leaderboard", server.handlePutLeaderboardOptOut)
+	router.DELETE("/api/account/domains/:id", server.handleDeleteCustomDomain)
+	router.POST("/api/account/age-attestation", server.handleAgeAttestation)
 	router.POST("/api/activation-codes/redeem", server.handleRedeemActivationCode)
+	router.GET("/api/activation-codes/pending", server.handlePendingActivationCode)
+	router.DELETE("/api/activation-codes/pending", server.handleCancelPendingActivationCode)
+	router.GET("/redeem", server.handleRedeemLink)
+	router.GET("/go/:id", server.handlePixelRedirect)
+	router.GET("/pixel/:id", server.handlePixelPage)
 	router.GET("/api/verify", server.handleVerifyAccount)
 	router.POST("/api/resend-verification", server.handleResendVerification)
 	router.POST("/api/password-reset/request", server.handlePasswordResetRequest)
 	router.POST("/api/password-reset/confirm", server.handlePasswordResetConfirm)
 
+	router.GET("/api/admin/activation-codes/qr", server.handleActivationCodeQR)
+	router.POST("/api/admin/activation-codes/qr", server.handleActivationCodeQRBatch)
+	router.POST("/api/admin/activation-codes", server.handleCreateActivationCodes)
+	router.GET("/api/admin/campaigns/:id/stats", server.handleCampaignStats)
+	router.POST("/api/admin/invites", server.handleCreateInviteBatch)
+	router.GET("/api/admin/invites/:batch", server.handleListInviteBatch)
+	router.GET("/api/admin/redemption-alerts", server.handleRedemptionAlerts)
+	router.GET("/api/admin/email-preview", server.handleEmailPreview)
+	router.POST("/api/admin/email-test", server.handleEmailTest)
+	router.POST("/api/admin/country-overrides", server.handleCreateCountryOverride)
+	router.GET("/api/admin/pixel-licenses", server.handleAdminPixelLicenses)
+	router.GET("/api/admin/takedowns", server.handleAdminTakedowns)
+	router.GET("/api/admin/takedowns/:id", server.handleAdminTakedown)
+	router.POST("/api/admin/takedowns/:id/resolve", server.handleResolveTakedown)
+	router.GET("/api/admin/contact", server.handleAdminContactMessages)
+	router.GET("/api/admin/contact/:id", server.handleAdminContactMessage)
+	router.POST("/api/admin/contact/:id/reply", server.handleAdminContactReply)
+	router.GET("/api/admin/logs/stream", server.handleAdminLogStream)
+	router.GET("/api/admin/errors", server.handleAdminErrors)
+	router.GET("/api/admin/refunds", server.handleListRefunds)
+	router.POST("/api/admin/refunds", server.handleCreateRefund)
+	router.POST("/api/admin/pixels/force-free", server.handleForceFreePixels)
+	router.GET("/api/admin/audit", server.handleListAdminActions)
+	router.GET("/api/admin/moderation", server.handleListModeration)
+	router.POST("/api/admin/moderation/approve", server.handleApproveModeration)
+	router.POST("/api/admin/moderation/reject", server.handleRejectModeration)
+	router.GET("/api/admin/consistency", server.handleGetConsistency)
+	router.POST("/api/admin/consistency", server.handleCheckConsistency)
+	router.GET("/api/admin/balances", server.handleGetBalances)
+	router.POST("/api/admin/balances", server.handleRecomputeBalances)
+	// Profiles and runtime vars cover the whole process, so tenant admins do not get them.
+	if tenant == nil {
+		router.GET("/api/admin/debug/vars", server.handleAdminDiagnostics)
+		router.GET(adminPprofPrefix+"*name", server.handleAdminDiagnostics)
+	}
+	router.PUT("/api/admin/banner", server.handlePutBanner)
+	router.DELETE("/api/admin/banner", server.handleDeleteBanner)
+	router.GET("/api/admin/blocklist", server.handleGetContentBlocklist)
+	router.PUT("/api/admin/blocklist", server.handlePutContentBlocklist)
+	router.DELETE("/api/admin/blocklist", server.handleDeleteContentBlocklist)
+	router.POST("/api/admin/blocklist/reload", server.handleReloadContentBlocklist)
+	router.PUT("/api/admin/read-only", server.handlePutReadOnly)
+	router.GET("/api/admin/users/:id/pixel-quota", server.handleGetPixelQuota)
+	router.PUT("/api/admin/users/:id/pixel-quota", server.handlePutPixelQuota)
+	router.DELETE("/api/admin/users/:id/pixel-quota", server.handleDeletePixelQuota)
+	router.GET("/api/admin/maintenance", server.handleListMaintenanceWindows)
+	router.POST("/api/admin/maintenance", server.handleCreateMaintenanceWindow)
+	router.DELETE("/api/admin/maintenance/:id", server.handleDeleteMaintenanceWindow)
+	router.GET("/api/payments/bundles", server.handlePaymentBundles)
+	router.GET("/api/payments", server.handleListPayments)
+	router.GET("/api/jobs/:id", server.handleJob)
+	router.POST("/api/payments", server.handleCreatePayment)
+	router.POST("/api/payments/webhook", server.handlePaymentWebhook)
+	router.POST("/api/takedowns", server.handleCreateTakedown)
+	router.POST("/api/contact", server.handleContact)
+
+	router.GET("/api/version", handleVersion)
 	router.GET("/api/pixels", server.handleGetPixels)
+	router.GET("/api/pixels/colors", server.handleGetPixelColors)
+	router.GET("/api/pixels/overlay", server.handleGetPixelOverlay)
+	router.GET("/api/canvas.png", server.handleGetCanvasPNG)
+	router.GET("/api/canvas/archive", server.handleListCanvasArchive)
+	router.GET("/api/canvas/archive.png", server.handleGetArchivedCanvasPNG)
+	router.GET("/api/pixels/quote", server.handlePriceQuote)
+	router.GET("/api/pixels/search", server.handleSearchPixels)
+	router.GET("/api/drafts", server.handleListDrafts)
+	router.POST("/api/drafts", server.handleCreateDraft)
+	router.GET("/api/drafts/:id", server.handleGetDraft)
+	router.PUT("/api/drafts/:id", server.handleUpdateDraft)
+	router.DELETE("/api/drafts/:id", server.handleDeleteDraft)
+	router.POST("/api/drafts/:id/purchase", server.handlePurchaseDraft)
+	router.GET("/api/pixels/reserve", server.handleGetPixelHolds)
+	router.POST("/api/pixels/reserve", server.handleReservePixels)
+	router.DELETE("/api/pixels/reserve", server.handleReleasePixelHolds)
+	router.GET("/api/pixels/stream", server.handlePixelStream)
+	router.GET("/api/pixels/tile/:x/:y", server.handleGetPixelTile)
+	router.GET("/api/pixels/region", server.handleGetPixelRegion)
+	router.GET("/api/stats", server.handleCanvasStats)
+	router.GET("/api/leaderboard", server.handleLeaderboard)
+	router.GET("/api/boards", server.handleListBoards)
+	router.GET("/api/boards/:id/pixels", server.handleGetBoardPixels)
+	router.POST("/api/boards/:id/pixels", server.handleUpdateBoardPixels)
+	router.GET("/api/pixels/:id/history", server.handlePixelHistory)
+	router.GET("/api/pixels/:id/preview", server.handlePixelPreview)
 	router.POST("/api/pixels", server.handleUpdatePixel)
+	router.POST("/api/pixels/image", server.handlePurchasePixelImage)
+	router.POST("/api/pixels/preview-image", server.handlePreviewPixelImage)
 
 	if assets := embedSub("frontend_dist/assets"); assets != nil {
 		router.StaticFS("/assets", http.FS(assets))
@@ -592,39 +1271,144 @@ func main() {
 		serveIndex(c)
 	})
 
-	log.Println("Kup Piksel backend listening on :3000")
-	if err := router.Run(":3000"); err != nil {
-		log.Fatalf("server stopped: %v", err)
+	return server, router, func() {
+		if cerr := store.Close(); cerr != nil {
+			log.Printf("close store: %v", cerr)
+		}
 	}
 }
 
+// freeRangesMediaType lets clients opt into free pixel ranges through content negotiation.
+const freeRangesMediaType = "application/vnd.kuppixel.free-ranges+json"
+
+// gridRLEMediaType selects the packed binary grid written by storage.PixelState.WriteRLE.
+const gridRLEMediaType = "application/vnd.kuppixel.grid-rle"
+
+// handleGetPixels returns the whole grid. ?fields=id,status,color,url limits the encoded pixel
+// fields, which lets the public grid view skip owner ids and timestamps. With ?free=ranges (or
+// Accept: application/vnd.kuppixel.free-ranges+json) runs of free pixels are sent as id ranges.
+// ?since= switches to the incremental response of handleGetPixelDelta. Clients sending
+// Accept: application/vnd.kuppixel.grid-rle get colors and links in the packed binary encoding.
 func (s *Server) handleGetPixels(c *gin.Context) {
-	state, err := s.store.GetAllPixels(c.Request.Context())
+	if c.Query("since") != "" {
+		s.handleGetPixelDelta(c)
+		return
+	}
+	fields, err := storage.ParsePixelFields(c.Query("fields"))
 	if err != nil {
-		log.Printf("get pixels: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accept := c.Request.Header.Get("Accept")
+	freeRanges := c.Query("free") == "ranges" || strings.Contains(accept, freeRangesMediaType)
+	c.Writer.Header().Add("Vary", "Accept")
+
+	if strings.Contains(accept, gridRLEMediaType) {
+		s.serveGrid(c, "pixels rle", gridRLEMediaType, func(ctx context.Context) (func(io.Writer) error, error) {
+			state, err := s.loadGrid(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.hideContestedPixels(ctx, &state); err != nil {
+				return nil, err
+			}
+			return state.WriteRLE, nil
+		})
+		return
+	}
+
+	key := fmt.Sprintf("pixels fields=%d free_ranges=%t", fields, freeRanges)
+	s.serveGrid(c, key, "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		state, err := s.loadGrid(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		if freeRanges {
+			return func(w io.Writer) error { return state.WriteJSONFreeRanges(w, fields) }, nil
+		}
+		return func(w io.Writer) error { return state.WriteJSONFields(w, fields) }, nil
+	})
+}
+
+// handleGetPixelColors returns only the color of every pixel indexed by id, which is all the
+// canvas renderer needs. ?encoding=rle returns runs of equal colors as a single string.
+func (s *Server) handleGetPixelColors(c *gin.Context) {
+	encoding := strings.ToLower(strings.TrimSpace(c.Query("encoding")))
+	if encoding != "" && encoding != "rle" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported encoding"})
 		return
 	}
-	c.JSON(http.StatusOK, state)
+
+	s.serveGrid(c, "colors encoding="+encoding, "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		state, err := s.loadGrid(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		if encoding == "rle" {
+			return state.WriteColorsRLE, nil
+		}
+		return state.WriteColorsJSON, nil
+	})
 }
 
 func (s *Server) handleRegister(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
 	var req authRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
 
-	email := strings.TrimSpace(strings.ToLower(req.Email))
+	email := s.normalizeEmail(req.Email)
 	password := strings.TrimSpace(req.Password)
-	if email == "" || password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+	var invalid fieldErrors
+	invalid.email("email", email)
+	invalid.password("password", password)
+	inviteCode := normalizeInviteCode(req.InviteCode)
+	if s.invitesRequired {
+		invalid.required("invite_code", inviteCode)
+	}
+	if invalid.respond(c, "popraw zaznaczone pola") {
 		return
 	}
 
-        if !s.requireTurnstile(c, req.Token) {
-                return
-        }
+	var attestation storage.AgeAttestation
+	if s.minimumAge > 0 {
+		var err error
+		attestation, err = s.ageAttestationFromRequest(ageAttestationRequest{AgeAttestation: req.AgeAttestation, BirthYear: req.BirthYear}, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "age_attestation_invalid"})
+			return
+		}
+	}
+
+	if !s.requireAllowedCountry(c, "register", email, req.CountryOverride) {
+		return
+	}
+
+	formChallenge, ok := s.screenForm(c, "register", email, req.formSignals)
+	if !ok {
+		return
+	}
+	ip, device, challengeAction, ok := s.checkRegistrationLimit(c)
+	if !ok {
+		return
+	}
+	if formChallenge {
+		challengeAction = registerChallengeAction
+	}
+	if !s.requireTurnstileAction(c, req.Token, challengeAction) {
+		return
+	}
 
         hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -633,8 +1417,12 @@ func (s *Server) handleRegister(c *gin.Context) {
 		return
 	}
 
-	user, err := s.store.CreateUser(c.Request.Context(), email, string(hash))
+	user, err := s.createUser(c.Request.Context(), email, string(hash), inviteCode)
 	if err != nil {
+		if errors.Is(err, storage.ErrInviteCodeInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kod zaproszenia jest nieprawidłowy lub został już użyty.", "code": "invite_code_invalid"})
+			return
+		}
 		if strings.Contains(strings.ToLower(err.Error()), "email already exists") {
 			existing, getErr := s.store.GetUserByEmail(c.Request.Context(), email)
 			if getErr != nil {
@@ -663,7 +1451,7 @@ func (s *Server) handleRegister(c *gin.Context) {
 
 			log.Printf("register: issued verification token for user_id=%d", existing.ID)
 
-			link, linkErr := buildVerificationLink(s.verificationBaseURL, token)
+			link, linkErr := buildVerificationLink(s.verificationBaseURL, s.verificationLink, token, s.emailLanguage)
 			if linkErr != nil {
 				log.Printf("build verification link (duplicate register): %v", linkErr)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare verification"})
@@ -689,6 +1477,15 @@ func (s *Server) handleRegister(c *gin.Context) {
 	}
 
 	log.Printf("register: created new user id=%d email=%s disable_verification_email=%t", user.ID, user.Email, s.disableVerificationEmail)
+	s.registrationLimiter.Record(ip, device)
+
+	if s.minimumAge > 0 {
+		attestation.UserID = user.ID
+		if err := s.store.SaveAgeAttestation(c.Request.Context(), attestation); err != nil {
+			// The user can still attest later through /api/account/age-attestation before purchasing.
+			log.Printf("register: save age attestation for user_id=%d: %v", user.ID, err)
+		}
+	}
 
 	if s.disableVerificationEmail {
 		if err := s.store.MarkUserVerified(c.Request.Context(), user.ID); err != nil {
@@ -716,7 +1513,7 @@ func (s *Server) handleRegister(c *gin.Context) {
 
 	log.Printf("register: verification token issued for user_id=%d", user.ID)
 
-	link, err := buildVerificationLink(s.verificationBaseURL, token)
+	link, err := buildVerificationLink(s.verificationBaseURL, s.verificationLink, token, s.emailLanguage)
 	if err != nil {
 		log.Printf("build verification link: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare verification"})
@@ -743,20 +1540,34 @@ func (s *Server) handleLogin(c *gin.Context) {
                 return
 	}
 
-	email := strings.TrimSpace(strings.ToLower(req.Email))
+	email := s.normalizeEmail(req.Email)
 	password := strings.TrimSpace(req.Password)
         if email == "" || password == "" {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
                 return
         }
 
-        if !s.requireTurnstile(c, req.Token) {
-                return
-        }
+	limit, ok := s.checkLoginLimit(c, email)
+	if !ok {
+		return
+	}
 
-        user, err := s.store.GetUserByEmail(c.Request.Context(), email)
+	formChallenge, ok := s.screenForm(c, "login", email, req.formSignals)
+	if !ok {
+		return
+	}
+	challengeAction := ""
+	if formChallenge || limit.requireChallenge {
+		challengeAction = loginChallengeAction
+	}
+	if !s.requireTurnstileAction(c, req.Token, challengeAction) {
+		return
+	}
+
+        user, err := s.findUserByEmail(c.Request.Context(), req.Email)
         if err != nil {
                 if errors.Is(err, sql.ErrNoRows) {
+			s.loginLimiter.RecordFailure(s.clientSource(c.Request), email)
                         c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
                         return
 		}
@@ -766,9 +1577,11 @@ func (s *Server) handleLogin(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		s.loginLimiter.RecordFailure(s.clientSource(c.Request), email)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	s.loginLimiter.RecordSuccess(email)
 
 	if !user.IsVerified {
 		if s.disableVerificationEmail {
@@ -787,7 +1600,7 @@ func (s *Server) handleLogin(c *gin.Context) {
 
 		log.Printf("login: verification token issued for user_id=%d", user.ID)
 
-		link, err := buildVerificationLink(s.verificationBaseURL, token)
+		link, err := buildVerificationLink(s.verificationBaseURL, s.verificationLink, token, s.emailLanguage)
 		if err != nil {
 			log.Printf("build verification link (login): %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare verification"})
@@ -806,15 +1619,13 @@ func (s *Server) handleLogin(c *gin.Context) {
 		return
 	}
 
-	sessionID, err := s.sessions.Create(user.ID)
-	if err != nil {
+	if _, err := s.rotateSession(c, user.ID); err != nil {
 		log.Printf("create session: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
 		return
 	}
-	setSessionCookie(c, sessionID)
 
-	c.JSON(http.StatusOK, gin.H{"user": sanitizeUser(user), "pixel_cost_points": s.pixelCostPoints})
+	c.JSON(http.StatusOK, gin.H{"user": sanitizeUser(user), "pixel_cost_points": s.pixelCostPoints, "pixel_price": s.pointsPrice(c, s.pixelCostPoints)})
 }
 
 func (s *Server) issueVerificationToken(ctx context.Context, user storage.User) (string, error) {
@@ -825,6 +1636,12 @@ func (s *Server) issueVerificationToken(ctx context.Context, user storage.User)
 		return "", errors.New("invalid user id")
 	}
 
+	if s.linkSigner != nil {
+		expires := time.Now().Add(s.verificationTokenTTL)
+		log.Printf("issueVerificationToken: signed link for user_id=%d expires_at=%s", user.ID, expires.Format(time.RFC3339))
+		return s.linkSigner.sign(linkPurposeVerify, user, expires)
+	}
+
 	log.Printf("issueVerificationToken: start user_id=%d", user.ID)
 
 	if err := s.store.DeleteVerificationTokensForUser(ctx, user.ID); err != nil {
@@ -835,7 +1652,7 @@ func (s *Server) issueVerificationToken(ctx context.Context, user storage.User)
 	var token string
 	var err error
 	for i := 0; i < 5; i++ {
-		token, err = generateVerificationToken()
+		token, err = generateVerificationToken(s.verificationTokenBytes)
 		if err != nil {
 			return "", err
 		}
@@ -863,17 +1680,23 @@ func (s *Server) issuePasswordResetToken(ctx context.Context, user storage.User)
 		return "", errors.New("invalid user id")
 	}
 
-	if err := s.store.DeletePasswordResetTokensForUser(ctx, user.ID); err != nil {
-		log.Printf("cleanup password reset tokens for user_id=%d: %v", user.ID, err)
-	}
-
 	ttl := s.passwordResetTokenTTL
 	if ttl <= 0 {
 		ttl = time.Duration(config.Default().PasswordReset.TokenTTLHours) * time.Hour
 	}
 
+	if s.linkSigner != nil {
+		expires := time.Now().Add(ttl)
+		log.Printf("issuePasswordResetToken: signed link for user_id=%d expires_at=%s", user.ID, expires.Format(time.RFC3339))
+		return s.linkSigner.sign(linkPurposeReset, user, expires)
+	}
+
+	if err := s.store.DeletePasswordResetTokensForUser(ctx, user.ID); err != nil {
+		log.Printf("cleanup password reset tokens for user_id=%d: %v", user.ID, err)
+	}
+
 	for i := 0; i < 5; i++ {
-		token, err := generateVerificationToken()
+		token, err := generateVerificationToken(s.passwordResetTokenBytes)
 		if err != nil {
 			return "", fmt.Errorf("generate reset token: %w", err)
 		}
@@ -899,6 +1722,9 @@ func (s *Server) issuePasswordResetToken(ctx context.Context, user storage.User)
 }
 
 func (s *Server) handleVerifyAccount(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
 	if s.disableVerificationEmail {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Weryfikacja adresów e-mail jest wyłączona."})
 		return
@@ -909,24 +1735,43 @@ func (s *Server) handleVerifyAccount(c *gin.Context) {
 		return
 	}
 
-	record, err := s.store.GetVerificationToken(c.Request.Context(), token)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	var userID int64
+	if link, ok := parseSignedLink(token); ok {
+		user, err := s.redeemSignedLink(c.Request.Context(), link, linkPurposeVerify)
+		switch {
+		case errors.Is(err, errSignedLinkInvalid):
 			c.JSON(http.StatusBadRequest, gin.H{"error": "nieprawidłowy lub wykorzystany token"})
 			return
+		case errors.Is(err, errSignedLinkExpired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link weryfikacyjny."})
+			return
+		case err != nil:
+			log.Printf("redeem signed verification link: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify account"})
+			return
+		}
+		userID = user.ID
+	} else {
+		record, err := s.store.GetVerificationToken(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "nieprawidłowy lub wykorzystany token"})
+				return
+			}
+			log.Printf("get verification token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify account"})
+			return
 		}
-		log.Printf("get verification token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify account"})
-		return
-	}
 
-	if time.Now().After(record.ExpiresAt) {
-		_ = s.store.DeleteVerificationToken(c.Request.Context(), token)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link weryfikacyjny."})
-		return
+		if time.Now().After(record.ExpiresAt) {
+			_ = s.store.DeleteVerificationToken(c.Request.Context(), token)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link weryfikacyjny."})
+			return
+		}
+		userID = record.UserID
 	}
 
-	if err := s.store.MarkUserVerified(c.Request.Context(), record.UserID); err != nil {
+	if err := s.store.MarkUserVerified(c.Request.Context(), userID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "konto nie istnieje"})
 			return
@@ -936,7 +1781,7 @@ func (s *Server) handleVerifyAccount(c *gin.Context) {
 		return
 	}
 
-	if err := s.store.DeleteVerificationTokensForUser(c.Request.Context(), record.UserID); err != nil {
+	if err := s.store.DeleteVerificationTokensForUser(c.Request.Context(), userID); err != nil {
 		log.Printf("cleanup verification tokens: %v", err)
 	}
 
@@ -944,6 +1789,9 @@ func (s *Server) handleVerifyAccount(c *gin.Context) {
 }
 
 func (s *Server) handleResendVerification(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
 	if s.disableVerificationEmail {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Konto jest już potwierdzone."})
 		return
@@ -954,7 +1802,7 @@ func (s *Server) handleResendVerification(c *gin.Context) {
 		return
 	}
 
-	email := strings.TrimSpace(strings.ToLower(req.Email))
+	email := s.normalizeEmail(req.Email)
 	if email == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
 		return
@@ -964,7 +1812,7 @@ func (s *Server) handleResendVerification(c *gin.Context) {
 		return
 	}
 
-	user, err := s.store.GetUserByEmail(c.Request.Context(), email)
+	user, err := s.findUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "konto z tym adresem e-mail nie istnieje"})
@@ -991,7 +1839,7 @@ func (s *Server) handleResendVerification(c *gin.Context) {
 
 	log.Printf("resend: verification token issued for user_id=%d", user.ID)
 
-	link, err := buildVerificationLink(s.verificationBaseURL, token)
+	link, err := buildVerificationLink(s.verificationBaseURL, s.verificationLink, token, s.emailLanguage)
 	if err != nil {
 		log.Printf("build verification link (resend): %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare verification"})
@@ -1010,13 +1858,16 @@ func (s *Server) handleResendVerification(c *gin.Context) {
 }
 
 func (s *Server) handlePasswordResetRequest(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
         var req passwordResetRequest
         if err := c.ShouldBindJSON(&req); err != nil {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
                 return
 	}
 
-	email := strings.TrimSpace(strings.ToLower(req.Email))
+	email := s.normalizeEmail(req.Email)
         if email == "" {
                 c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
                 return
@@ -1028,7 +1879,7 @@ func (s *Server) handlePasswordResetRequest(c *gin.Context) {
 
         const responseMessage = "Jeśli konto istnieje, wysłaliśmy instrukcje resetu hasła."
 
-        user, err := s.store.GetUserByEmail(c.Request.Context(), email)
+        user, err := s.findUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusAccepted, gin.H{"message": responseMessage})
@@ -1046,7 +1897,7 @@ func (s *Server) handlePasswordResetRequest(c *gin.Context) {
 		return
 	}
 
-	link, err := buildPasswordResetLink(s.passwordResetBaseURL, token)
+	link, err := buildPasswordResetLink(s.passwordResetBaseURL, s.passwordResetLink, token, s.emailLanguage)
 	if err != nil {
 		log.Printf("build password reset link: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prepare reset"})
@@ -1063,6 +1914,9 @@ func (s *Server) handlePasswordResetRequest(c *gin.Context) {
 }
 
 func (s *Server) handlePasswordResetConfirm(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
 	var req passwordResetConfirmRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
@@ -1072,13 +1926,16 @@ func (s *Server) handlePasswordResetConfirm(c *gin.Context) {
 	token := strings.TrimSpace(req.Token)
 	password := strings.TrimSpace(req.Password)
 	confirm := strings.TrimSpace(req.ConfirmPassword)
-	if token == "" || password == "" || confirm == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "token and password are required"})
-		return
-	}
-
-	if password != confirm {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "hasła muszą być takie same"})
+	var invalid fieldErrors
+	invalid.required("token", token)
+	invalid.password("password", password)
+	switch {
+	case confirm == "":
+		invalid.add("confirm_password", fieldCodeRequired)
+	case password != "" && password != confirm:
+		invalid.add("confirm_password", fieldCodeMismatch)
+	}
+	if invalid.respond(c, "popraw zaznaczone pola") {
 		return
 	}
 
@@ -1086,23 +1943,40 @@ func (s *Server) handlePasswordResetConfirm(c *gin.Context) {
 		return
 	}
 
-	record, err := s.store.GetPasswordResetToken(c.Request.Context(), token)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+	var userID int64
+	if link, ok := parseSignedLink(token); ok {
+		user, err := s.redeemSignedLink(c.Request.Context(), link, linkPurposeReset)
+		switch {
+		case errors.Is(err, errSignedLinkInvalid):
 			c.JSON(http.StatusBadRequest, gin.H{"error": "nieprawidłowy lub wykorzystany token"})
 			return
+		case errors.Is(err, errSignedLinkExpired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link resetu hasła."})
+			return
+		case err != nil:
+			log.Printf("redeem signed password reset link: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+			return
+		}
+		userID = user.ID
+	} else {
+		// Consuming the token up front lets only one of concurrent confirmations use it.
+		record, err := s.store.ConsumePasswordResetToken(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, storage.ErrTokenUsed) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "nieprawidłowy lub wykorzystany token"})
+				return
+			}
+			log.Printf("consume password reset token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+			return
 		}
-		log.Printf("get password reset token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
-		return
-	}
 
-	if time.Now().After(record.ExpiresAt) {
-		if delErr := s.store.DeletePasswordResetToken(c.Request.Context(), token); delErr != nil {
-			log.Printf("cleanup expired password reset token: %v", delErr)
+		if time.Now().After(record.ExpiresAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link resetu hasła."})
+			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "token wygasł. Poproś o nowy link resetu hasła."})
-		return
+		userID = record.UserID
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -1112,7 +1986,7 @@ func (s *Server) handlePasswordResetConfirm(c *gin.Context) {
 		return
 	}
 
-	if err := s.store.UpdateUserPassword(c.Request.Context(), record.UserID, string(hash)); err != nil {
+	if err := s.store.UpdateUserPassword(c.Request.Context(), userID, string(hash)); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "konto nie istnieje"})
 			return
@@ -1122,8 +1996,22 @@ func (s *Server) handlePasswordResetConfirm(c *gin.Context) {
 		return
 	}
 
-	if err := s.store.DeletePasswordResetTokensForUser(c.Request.Context(), record.UserID); err != nil {
-		log.Printf("cleanup password reset tokens for user_id=%d: %v", record.UserID, err)
+	if err := s.store.DeletePasswordResetTokensForUser(c.Request.Context(), userID); err != nil {
+		log.Printf("cleanup password reset tokens for user_id=%d: %v", userID, err)
+	}
+
+	// A password change ends every existing session; a caller already logged in as this user gets a new one.
+	keep := ""
+	if sessionID, ok, _ := readSessionCookie(c.Request); ok {
+		if owner, exists := s.sessions.Get(sessionID); exists && owner == userID {
+			if keep, err = s.rotateSession(c, userID); err != nil {
+				log.Printf("rotate session after password reset user_id=%d: %v", userID, err)
+				keep = ""
+			}
+		}
+	}
+	if removed := s.sessions.DeleteUser(userID, keep); removed > 0 {
+		log.Printf("password reset: invalidated %d session(s) for user_id=%d", removed, userID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Hasło zostało zaktualizowane."})
@@ -1148,10 +2036,20 @@ func (s *Server) handleSession(c *gin.Context) {
 			s.sessions.Delete(sessionID)
 			clearSessionCookie(c)
 		}
-		c.JSON(http.StatusOK, gin.H{"user": nil, "pixel_cost_points": s.pixelCostPoints})
+		c.JSON(http.StatusOK, gin.H{
+			"user":              nil,
+			"pixel_cost_points": s.pixelCostPoints,
+			"pixel_price":       s.pointsPrice(c, s.pixelCostPoints),
+			"banner":            s.activeBanner(c.Request.Context()),
+		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"user": sanitizeUser(user), "pixel_cost_points": s.pixelCostPoints})
+	c.JSON(http.StatusOK, gin.H{
+		"user":              sanitizeUser(user),
+		"pixel_cost_points": s.pixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, s.pixelCostPoints),
+		"banner":            s.activeBanner(c.Request.Context()),
+	})
 }
 
 func (s *Server) handleAccount(c *gin.Context) {
@@ -1166,11 +2064,24 @@ func (s *Server) handleAccount(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
 		return
 	}
+	if err := s.markPendingReview(c.Request.Context(), pixels); err != nil {
+		log.Printf("get pending review pixels for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
+		return
+	}
+	history, err := s.store.ListPixelHistoryByOwner(c.Request.Context(), user.ID, pixelHistoryDefaultLimit)
+	if err != nil {
+		log.Printf("get pixel history for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"user":              sanitizeUser(user),
 		"pixels":            pixels,
+		"pixel_history":     history,
 		"pixel_cost_points": s.pixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, s.pixelCostPoints),
 	})
 }
 
@@ -1179,6 +2090,9 @@ func (s *Server) handleRedeemActivationCode(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if s.rejectWrites(c) {
+		return
+	}
 
 	var req activationCodeRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1186,20 +2100,44 @@ func (s *Server) handleRedeemActivationCode(c *gin.Context) {
 		return
 	}
 
-	code := strings.ToUpper(strings.TrimSpace(req.Code))
-	if !activationCodePattern.MatchString(code) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "nieprawidłowy format kodu. Użyj xxxx-xxxx-xxxx-xxxx."})
+	sourceKeys := redemptionSourceKeys(s.clientSource(c.Request), redemptionDeviceID(c), user.ID)
+	verdict := s.redemptionGuard.Check(sourceKeys)
+	if verdict.retryAfter > 0 {
+		c.Writer.Header().Set("Retry-After", strconv.Itoa(int(verdict.retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "zbyt wiele nieudanych prób. Spróbuj ponownie później."})
 		return
 	}
 
-	if !s.requireTurnstile(c, req.Token) {
+	code := activationcode.Normalize(req.Code)
+	handoffID := ""
+	if code == "" {
+		// Codes opened via a /redeem deep link are confirmed without retyping them.
+		if id, pending, ok := s.claimPendingRedeemCode(c, user.ID); ok {
+			handoffID, code = id, pending
+		}
+	}
+	if !s.codeFormat.Valid(code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("nieprawidłowy format kodu. Użyj %s.", s.codeFormat.Pattern())})
+		return
+	}
+
+	challengeAction := ""
+	if verdict.requireChallenge {
+		challengeAction = redeemChallengeAction
+	}
+	if !s.requireTurnstileAction(c, req.Token, challengeAction) {
 		return
 	}
 
 	updatedUser, added, err := s.store.RedeemActivationCode(c.Request.Context(), user.ID, code)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "kod nie istnieje lub został już wykorzystany."})
+			s.redemptionGuard.RecordFailure(sourceKeys, code)
+			response := gin.H{"error": "kod nie istnieje lub został już wykorzystany."}
+			if s.redemptionGuard.Check(sourceKeys).requireChallenge {
+				response["captcha"] = "challenge"
+			}
+			c.JSON(http.StatusBadRequest, response)
 			return
 		}
 		log.Printf("redeem activation code %s for user %d: %v", code, user.ID, err)
@@ -1207,10 +2145,18 @@ func (s *Server) handleRedeemActivationCode(c *gin.Context) {
 		return
 	}
 
+	s.redemptionGuard.RecordSuccess(sourceKeys)
+	if handoffID != "" {
+		s.redeemHandoffs.Delete(handoffID)
+		clearRedeemHandoffCookie(c)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user":              sanitizeUser(updatedUser),
 		"added_points":      added,
+		"added_value":       s.pointsPrice(c, added),
 		"pixel_cost_points": s.pixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, s.pixelCostPoints),
 	})
 }
 
@@ -1219,127 +2165,353 @@ func (s *Server) handleUpdatePixel(c *gin.Context) {
 	if !ok {
 		return
 	}
+	if s.rejectWrites(c) {
+		return
+	}
+	if !s.requireAgeAttestation(c, user) {
+		return
+	}
 
+	if s.purchaseMaxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.purchaseMaxBytes)
+	}
 	var req UpdatePixelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "zaznaczenie jest zbyt duże. Podziel zakup na mniejsze części.", "code": "payload_too_large"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
+	s.purchasePixels(c, user, req)
+}
 
+// purchasePixels validates req, applies it for user, or queues it when it is large, and writes
+// the response. It reports whether any pixel was updated or the purchase was queued.
+func (s *Server) purchasePixels(c *gin.Context, user storage.User, req UpdatePixelRequest) bool {
 	if len(req.Pixels) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no pixels provided"})
-		return
+		return false
+	}
+	if req.License != nil {
+		if err := req.License.normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "license_invalid"})
+			return false
+		}
+	}
+	if !s.resolveGiftRecipient(c, user, &req) {
+		return false
+	}
+	if !s.resolvePurchasePrices(c, user, &req) {
+		return false
 	}
+	if _, _, err := s.purchaseTerms(*req.prices, req.RentalDays, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "code": "rental_invalid"})
+		return false
+	}
+	if s.purchaseJobs != nil && s.asyncPurchaseThreshold > 0 && len(req.Pixels) >= s.asyncPurchaseThreshold {
+		return s.enqueuePurchase(c, user, req)
+	}
+
+	purchase := s.applyPixelUpdates(c.Request.Context(), user, req)
+	if !purchase.updated {
+		c.JSON(purchase.status(), purchase.response(nil, nil))
+		return false
+	}
+	c.JSON(http.StatusOK, purchase.response(s.pointsPrice(c, purchase.costPoints), s.pointsPrice(c, purchase.spent)))
+	return true
+}
+
+// pixelPurchase is the outcome of applying an UpdatePixelRequest.
+type pixelPurchase struct {
+	results    []PixelUpdateResult
+	user       storage.User
+	costPoints int64
+	// priceVersion identifies the prices the purchase was charged by.
+	priceVersion string
+	updated      bool
+	purchased    int
+	spent        int64
+	license      *storage.PixelLicense
+	// expiresAt ends the rental of the purchased pixels; nil when they were bought for good.
+	expiresAt *time.Time
+	// recipient owns the purchased pixels when they were gifted.
+	recipient *storage.User
+	// pendingReview lists the pixels put in the moderation queue.
+	pendingReview []int
+	// errStatus, errMessage and errCode describe the first rejected pixel.
+	errStatus  int
+	errMessage string
+	errCode    string
+}
+
+// status is the HTTP status of the purchase: 200 when any pixel changed, otherwise that of the first error.
+func (p pixelPurchase) status() int {
+	switch {
+	case p.updated:
+		return http.StatusOK
+	case p.errStatus != 0:
+		return p.errStatus
+	}
+	return http.StatusBadRequest
+}
 
-	results := make([]PixelUpdateResult, 0, len(req.Pixels))
-	currentUser := user
-	var anySuccess bool
-	var firstErrStatus int
-	var firstErrMessage string
+// errorMessage explains why no pixel was updated.
+func (p pixelPurchase) errorMessage() string {
+	if p.errMessage == "" {
+		return "failed to update pixels"
+	}
+	return p.errMessage
+}
+
+// response renders the purchase; prices are null when not given.
+func (p pixelPurchase) response(pixelPrice, spentPrice *currency.Price) gin.H {
+	if !p.updated {
+		response := gin.H{
+			"error":             p.errorMessage(),
+			"results":           p.results,
+			"user":              sanitizeUser(p.user),
+			"pixel_cost_points": p.costPoints,
+		}
+		if p.errCode != "" {
+			response["code"] = p.errCode
+		}
+		return response
+	}
+	receipt := gin.H{
+		"pixels":        p.purchased,
+		"points_spent":  p.spent,
+		"price":         spentPrice,
+		"price_version": p.priceVersion,
+	}
+	if p.expiresAt != nil {
+		receipt["expires_at"] = p.expiresAt
+	}
+	if p.recipient != nil {
+		receipt["gift_to"] = p.recipient.Email
+	}
+	response := gin.H{
+		"license":           p.license,
+		"results":           p.results,
+		"user":              sanitizeUser(p.user),
+		"pixel_cost_points": p.costPoints,
+		"pixel_price":       pixelPrice,
+		"receipt":           receipt,
+	}
+	if len(p.pendingReview) > 0 {
+		response["pending_review"] = p.pendingReview
+	}
+	return response
+}
 
-	for _, item := range req.Pixels {
-		result := PixelUpdateResult{ID: item.ID}
+// applyPixelUpdates writes the requested pixels for user in chunks and records the license
+// declaration for the purchased ones.
+func (s *Server) applyPixelUpdates(ctx context.Context, user storage.User, req UpdatePixelRequest) pixelPurchase {
+	results := make([]PixelUpdateResult, len(req.Pixels))
+	prices := s.currentPrices()
+	if req.prices != nil {
+		prices = *req.prices
+	}
+	purchase := pixelPurchase{results: results, user: user, costPoints: prices.PixelCostPoints, priceVersion: prices.Version, recipient: req.recipient}
+	statuses := make([]int, len(req.Pixels))
+	cost, expiresAt, err := s.purchaseTerms(prices, req.RentalDays, time.Now())
+	if err != nil {
+		for i, item := range req.Pixels {
+			results[i].ID = item.ID
+		}
+		purchase.errStatus, purchase.errMessage = http.StatusBadRequest, err.Error()
+		return purchase
+	}
+	purchase.costPoints, purchase.expiresAt = cost, expiresAt
+
+	// Valid pixels are written in chunks; pending maps each of them back to its request index.
+	// checkedLinks remembers the outcome of each link check, as pixels usually share one link.
+	checkedLinks := make(map[string]string)
+	moderated := make(map[moderation.Submission]moderationVerdict)
+	pending := make([]int, 0, len(req.Pixels))
+	pixels := make([]storage.Pixel, 0, len(req.Pixels))
+	for i, item := range req.Pixels {
+		results[i].ID = item.ID
 		if item.ID < 0 || item.ID >= storage.TotalPixels {
-			result.Error = "invalid pixel id"
-			if firstErrStatus == 0 {
-				firstErrStatus = http.StatusBadRequest
-				firstErrMessage = result.Error
-			}
-			results = append(results, result)
+			results[i].Error, statuses[i] = "invalid pixel id", http.StatusBadRequest
 			continue
 		}
 
 		pixel := storage.Pixel{ID: item.ID}
+		if req.recipient != nil && strings.ToLower(item.Status) != "taken" {
+			results[i].Error, statuses[i] = "gifted pixels must be taken", http.StatusBadRequest
+			continue
+		}
 		if strings.ToLower(item.Status) == "taken" {
 			color := strings.TrimSpace(item.Color)
 			url := strings.TrimSpace(item.URL)
 			if color == "" || url == "" {
-				result.Error = "taken pixels require color and url"
-				if firstErrStatus == 0 {
-					firstErrStatus = http.StatusBadRequest
-					firstErrMessage = result.Error
-				}
-				results = append(results, result)
+				results[i].Error, statuses[i] = "taken pixels require color and url", http.StatusBadRequest
+				continue
+			}
+			title := strings.TrimSpace(item.Title)
+			description := strings.TrimSpace(strings.ReplaceAll(item.Description, "\r\n", "\n"))
+			if reason := s.pixelContentError(url, title, description); reason != "" {
+				results[i].Error, statuses[i] = reason, http.StatusBadRequest
+				continue
+			}
+			if reason := s.pixelLinkError(ctx, checkedLinks, url); reason != "" {
+				results[i].Error, statuses[i] = reason, http.StatusBadRequest
+				continue
+			}
+			if reason, status := s.pixelModerationError(ctx, moderated, moderation.Submission{URL: url, Color: color}); reason != "" {
+				results[i].Error, statuses[i] = reason, status
 				continue
 			}
 			pixel.Status = "taken"
 			pixel.Color = color
 			pixel.URL = url
+			pixel.Title = title
+			pixel.Description = description
+			pixel.ExpiresAt = expiresAt
 		} else {
 			pixel.Status = "free"
 			pixel.Color = ""
 			pixel.URL = ""
 		}
+		pending = append(pending, i)
+		pixels = append(pixels, pixel)
+	}
 
-		updatedPixel, updatedUser, err := s.store.UpdatePixelForUserWithCost(c.Request.Context(), user.ID, pixel, s.pixelCostPoints)
+	// Under moderation, pixels new to their owner or given a new link wait for a review.
+	var reviewed map[int]string
+	if s.moderation.Enabled && len(pixels) > 0 {
+		reviewed = make(map[int]string)
+		owned, err := s.store.GetPixelsByOwner(ctx, user.ID)
 		if err != nil {
-			status := http.StatusInternalServerError
-			switch {
-			case errors.Is(err, sql.ErrNoRows):
-				result.Error = "pixel not found"
-				status = http.StatusNotFound
-			case errors.Is(err, storage.ErrPixelOwnedByAnotherUser):
-				result.Error = "pixel already owned"
-				status = http.StatusForbidden
-			case errors.Is(err, storage.ErrInsufficientPoints):
-				result.Error = "brak wystarczającej liczby punktów. Aktywuj kod, aby zdobyć więcej."
-				status = http.StatusForbidden
-			default:
-				result.Error = "failed to update pixel"
-				log.Printf("update pixel %d: %v", item.ID, err)
-			}
+			// Without the previous links every pixel of the purchase is queued.
+			log.Printf("moderation: load pixels of user %d: %v", user.ID, err)
+		}
+		for _, pixel := range owned {
+			reviewed[pixel.ID] = pixel.URL
+		}
+	}
 
-			if firstErrStatus == 0 {
-				firstErrStatus = status
-				firstErrMessage = result.Error
+	chunkSize := s.purchaseChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultPurchaseChunkSize
+	}
+	for start := 0; start < len(pixels); start += chunkSize {
+		end := min(start+chunkSize, len(pixels))
+		var outcomes []storage.PixelUpdateOutcome
+		var updatedUser storage.User
+		if req.recipient != nil {
+			outcomes, updatedUser, err = s.store.GiftPixels(ctx, user.ID, req.recipient.ID, pixels[start:end], cost, prices.Version)
+		} else {
+			outcomes, updatedUser, err = s.store.UpdatePixelsForUserWithCost(ctx, user.ID, pixels[start:end], cost, prices.Version)
+		}
+		if err != nil {
+			log.Printf("update pixels %d-%d of %d for user %d: %v", start, end, len(pixels), user.ID, err)
+			for _, i := range pending[start:end] {
+				results[i].Error, statuses[i] = "failed to update pixel", http.StatusInternalServerError
 			}
-			results = append(results, result)
 			continue
 		}
 
-		anySuccess = true
-		currentUser = updatedUser
-		result.Pixel = &updatedPixel
-		results = append(results, result)
+		changed := make([]storage.Pixel, 0, len(outcomes))
+		for j, outcome := range outcomes {
+			i := pending[start+j]
+			if outcome.Err != nil {
+				results[i].Error, statuses[i] = pixelUpdateError(results[i].ID, outcome.Err)
+				if errors.Is(outcome.Err, storage.ErrPixelQuotaExceeded) {
+					results[i].Code = "pixel_quota_exceeded"
+				}
+				continue
+			}
+			updated := outcome.Pixel
+			results[i].Pixel = &updated
+			// The grid and live feed do not show when rentals end; only the owner sees it.
+			public := updated
+			public.ExpiresAt = nil
+			changed = append(changed, public)
+		}
+		purchase.user = updatedUser
+		if reviewed != nil && len(changed) > 0 {
+			purchase.pendingReview = append(purchase.pendingReview, s.reviewChangedPixels(ctx, reviewed, changed)...)
+		}
+		if len(changed) > 0 {
+			purchase.updated = true
+			s.pixelsChanged(ctx, changed)
+		}
+		if len(pixels) > chunkSize {
+			log.Printf("update pixels: user_id=%d progress=%d/%d", user.ID, end, len(pixels))
+		}
 	}
 
-	if !anySuccess {
-		status := firstErrStatus
-		if status == 0 {
-			status = http.StatusBadRequest
-		}
-		message := firstErrMessage
-		if message == "" {
-			message = "failed to update pixels"
+	for i, status := range statuses {
+		if status != 0 {
+			purchase.errStatus, purchase.errMessage, purchase.errCode = status, results[i].Error, results[i].Code
+			break
 		}
-		c.JSON(status, gin.H{
-			"error":             message,
-			"results":           results,
-			"user":              sanitizeUser(currentUser),
-			"pixel_cost_points": s.pixelCostPoints,
-		})
-		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"results":           results,
-		"user":              sanitizeUser(currentUser),
-		"pixel_cost_points": s.pixelCostPoints,
-	})
-}
+	if !purchase.updated {
+		return purchase
+	}
 
-func seedDemoPixels(ctx context.Context, store storage.Store) {
-	demo := []storage.Pixel{
-		{ID: 500500, Status: "taken", Color: "#ff4d4f", URL: "https://example.com"},
-		{ID: 250250, Status: "taken", Color: "#36cfc9", URL: "https://minecraft.net"},
-		{ID: 750750, Status: "taken", Color: "#722ed1", URL: "https://github.com"},
+	purchasedIDs := make([]int, 0, len(results))
+	for _, result := range results {
+		if result.Pixel != nil && result.Pixel.Status == "taken" {
+			purchasedIDs = append(purchasedIDs, result.ID)
+		}
+	}
+	purchase.purchased = len(purchasedIDs)
+	purchase.spent = int64(purchase.purchased) * cost
+	if purchase.purchased > 0 {
+		log.Printf("pixel purchase: user_id=%d pixels=%d points_spent=%d price_version=%s", user.ID, purchase.purchased, purchase.spent, purchase.priceVersion)
+		s.notifyOwnerWebhook(ctx, user.ID, ownerEventPixelsPurchased, gin.H{"pixel_ids": purchasedIDs, "points_spent": purchase.spent, "price_version": purchase.priceVersion})
+		go s.notifyPush(context.WithoutCancel(ctx), user.ID, fcm.Message{
+			Title: "Zakup pikseli",
+			Body:  fmt.Sprintf("Kupione piksele: %d, wykorzystane punkty: %d.", purchase.purchased, purchase.spent),
+			Data:  map[string]string{"event": ownerEventPixelsPurchased, "pixels": strconv.Itoa(purchase.purchased)},
+		})
+		if purchase.recipient != nil {
+			s.notifyGiftRecipient(ctx, user, *purchase.recipient, results)
+		}
 	}
 
-	for _, pixel := range demo {
-		if _, err := store.UpdatePixel(ctx, pixel); err != nil {
-			log.Printf("seed pixel %d: %v", pixel.ID, err)
+	if req.License != nil && purchase.purchased > 0 {
+		created, err := s.store.CreatePixelLicense(ctx, storage.PixelLicense{
+			UserID:       user.ID,
+			PixelIDs:     purchasedIDs,
+			ArtworkOwner: req.License.ArtworkOwner,
+			Contact:      req.License.Contact,
+			Statement:    req.License.Statement,
+		})
+		if err != nil {
+			// The pixels are already bought; a missing declaration must not fail the purchase.
+			log.Printf("save pixel license for user %d: %v", user.ID, err)
+		} else {
+			purchase.license = &created
 		}
 	}
+
+	return purchase
+}
+
+// pixelUpdateError maps a rejected pixel update to the message and status reported for it.
+func pixelUpdateError(pixelID int, err error) (string, int) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return "pixel not found", http.StatusNotFound
+	case errors.Is(err, storage.ErrPixelOwnedByAnotherUser):
+		return "pixel already owned", http.StatusForbidden
+	case errors.Is(err, storage.ErrPixelHeld):
+		return "pixel reserved by another user", http.StatusConflict
+	case errors.Is(err, storage.ErrInsufficientPoints):
+		return "brak wystarczającej liczby punktów. Aktywuj kod, aby zdobyć więcej.", http.StatusForbidden
+	case errors.Is(err, storage.ErrPixelQuotaExceeded):
+		return "osiągnięto limit pikseli na jedno konto.", http.StatusForbidden
+	}
+	log.Printf("update pixel %d: %v", pixelID, err)
+	return "failed to update pixel", http.StatusInternalServerError
 }
 
 func serveIndex(c *gin.Context) {
@@ -1362,6 +2534,16 @@ func serveIndex(c *gin.Context) {
 		c.String(http.StatusInternalServerError, fmt.Sprintf("frontend build missing: %v", err))
 		return
 	}
+	// The shell names the hashed assets of the current build, so browsers must revalidate it.
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	if hash := currentBuild().FrontendHash; hash != "" {
+		etag := `"` + hash + `"`
+		c.Writer.Header().Set("ETag", etag)
+		if c.Request.Header.Get("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
 	c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, _ = c.Writer.Write(data)
 }
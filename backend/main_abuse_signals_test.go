@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestAuthFormAbuseSignals(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	server.formTokenKey = []byte("test-form-key")
+	var action string
+	server.turnstileVerify = func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		return turnstileResponse{Success: true, Action: action}, nil
+	}
+
+	do := func(handler func(*gin.Context), target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	register := func(email, extra string) *httptest.ResponseRecorder {
+		return do(server.handleRegister, "/api/register", fmt.Sprintf(`{"email":"%s","password":"secret","turnstile_token":"%s"%s}`, email, testTurnstileToken, extra))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/form-token", nil)
+	w := httptest.NewRecorder()
+	server.handleFormToken(&gin.Context{Writer: w, Request: req})
+	var issued struct {
+		FormToken string `json:"form_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &issued); err != nil || issued.FormToken == "" {
+		t.Fatalf("unexpected form token response %s", w.Body.String())
+	}
+	if age, ok := server.formTokenAge(issued.FormToken, time.Now()); !ok || age > time.Minute {
+		t.Fatalf("issued token not accepted: age=%s ok=%t", age, ok)
+	}
+	if _, ok := server.formTokenAge(issued.FormToken+"0", time.Now()); ok {
+		t.Fatal("tampered token must be rejected")
+	}
+
+	oldToken := server.issueFormToken(time.Now().Add(-time.Minute))
+	if w := register("bot@example.com", `,"website":"http://spam.example","form_token":"`+oldToken+`"`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected honeypot rejection, got %d", w.Code)
+	}
+
+	// A form submitted right after it was rendered has to pass the interactive challenge.
+	if w := register("fast@example.com", `,"form_token":"`+issued.FormToken+`"`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected captcha escalation for a fast form, got %d", w.Code)
+	}
+	action = registerChallengeAction
+	if w := register("fast@example.com", `,"form_token":"`+issued.FormToken+`"`); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status with challenge token %d: %s", w.Code, w.Body.String())
+	}
+	action = ""
+
+	if w := register("human@example.com", `,"website":"","form_token":"`+oldToken+`"`); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if w := register("legacy@example.com", ""); w.Code != http.StatusCreated {
+		t.Fatalf("clients without a form token should still register, got %d: %s", w.Code, w.Body.String())
+	}
+
+	login := func(extra string) *httptest.ResponseRecorder {
+		return do(server.handleLogin, "/api/login", `{"email":"human@example.com","password":"secret","turnstile_token":"`+testTurnstileToken+`"`+extra+`}`)
+	}
+	if w := login(`,"website":"x"`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected honeypot rejection on login, got %d", w.Code)
+	}
+	if w := login(`,"form_token":"` + server.issueFormToken(time.Now()) + `"`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected captcha escalation on login, got %d", w.Code)
+	}
+	if w := login(`,"form_token":"` + oldToken + `"`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected login status %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqlite"
+)
+
+func newAdminTestServer(t *testing.T) (*Server, storage.Store, string) {
+	t.Helper()
+
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	prepareStore(t, store)
+
+	server := &Server{
+		store:                store,
+		sessions:             NewSessionManager(),
+		mailer:               &fakeMailer{},
+		verificationBaseURL:  "http://example.com",
+		verificationTokenTTL: time.Hour,
+		pixelCostPoints:      10,
+		adminEmails:          newAdminSet([]string{"admin@example.com"}),
+		redeemBaseURL:        "https://kuppixel.pl",
+	}
+	enableTurnstileForTest(server)
+
+	admin, err := store.CreateUser(context.Background(), "admin@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	sessionID, err := server.sessions.Create(admin.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return server, store, sessionID
+}
+
+func TestBuildRedeemLink(t *testing.T) {
+	link, err := buildRedeemLink("https://kuppixel.pl/", "ABCD-EFGH-IJKL-MNOP")
+	if err != nil {
+		t.Fatalf("build redeem link: %v", err)
+	}
+	if link != "https://kuppixel.pl/redeem?code=ABCD-EFGH-IJKL-MNOP" {
+		t.Fatalf("unexpected link %q", link)
+	}
+}
+
+func TestHandleActivationCodeQR_RequiresAdmin(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+
+	user, err := store.CreateUser(context.Background(), "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/activation-codes/qr?code=ABCD-EFGH-IJKL-MNOP", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleActivationCodeQR(&gin.Context{Writer: w, Request: req})
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", w.Code)
+	}
+}
+
+func TestHandleActivationCodeQR_RendersPNG(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/activation-codes/qr?code=abcd-efgh-ijkl-mnop&scale=2", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleActivationCodeQR(&gin.Context{Writer: w, Request: req})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Fatalf("expected image/png, got %q", ct)
+	}
+	if _, err := png.Decode(w.Body); err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+}
+
+func TestHandleActivationCodeQRBatch_ReturnsZip(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+
+	body := bytes.NewBufferString(`{"codes":["ABCD-EFGH-IJKL-MNOP","WXYZ-1234-5678-90AB","abcd-efgh-ijkl-mnop"],"scale":2}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/activation-codes/qr", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleActivationCodeQRBatch(&gin.Context{Writer: w, Request: req})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	if len(archive.File) != 2 {
+		t.Fatalf("expected 2 deduplicated entries, got %d", len(archive.File))
+	}
+	if archive.File[0].Name != "ABCD-EFGH-IJKL-MNOP.png" {
+		t.Fatalf("unexpected entry name %q", archive.File[0].Name)
+	}
+}
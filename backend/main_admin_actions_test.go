@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestForceFreePixelsRecordsAuditTrail(t *testing.T) {
+	server, store, adminSession := newAdminTestServer(t)
+	ctx := context.Background()
+	owner, err := store.CreateUser(ctx, "owner@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create owner: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "FREE-FREE-FREE-FREE", 50); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, owner.ID, "FREE-FREE-FREE-FREE"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	ownerSession, err := server.sessions.Create(owner.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	call := func(handler gin.HandlerFunc, method, session string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, "/api/admin/pixels/force-free", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	pixel := func(id int, link string) gin.H {
+		return gin.H{"id": id, "status": "taken", "color": "#123456", "url": link}
+	}
+	purchase := gin.H{"pixels": []gin.H{
+		pixel(1, "https://scam.example.com/offer"),
+		pixel(2, "https://EXAMPLE.com"),
+		pixel(3, "https://example.org"),
+	}}
+	if w := call(server.handleUpdatePixel, http.MethodPost, ownerSession, purchase); w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+	forceFree := func(session string, body gin.H) *httptest.ResponseRecorder {
+		return call(server.handleForceFreePixels, http.MethodPost, session, body)
+	}
+
+	if w := forceFree(ownerSession, gin.H{"pixel_ids": []int{3}, "reason": "spam"}); w.Code != http.StatusForbidden {
+		t.Fatalf("expected non-admins to be refused, got %d", w.Code)
+	}
+	if w := forceFree(adminSession, gin.H{"domain": "example.com"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a missing reason to be rejected, got %d", w.Code)
+	}
+	if w := forceFree(adminSession, gin.H{"domain": "example.com", "pixel_ids": []int{3}, "reason": "spam"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected pixel_ids and domain together to be rejected, got %d", w.Code)
+	}
+	if w := forceFree(adminSession, gin.H{"domain": "nowhere.example.net", "reason": "spam"}); w.Code != http.StatusNotFound {
+		t.Fatalf("expected a domain nobody links to to be reported, got %d", w.Code)
+	}
+
+	w := forceFree(adminSession, gin.H{"domain": "example.com", "reason": "phishing"})
+	var created struct {
+		Action storage.AdminAction `json:"action"`
+	}
+	if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil {
+		t.Fatalf("unexpected force free response %d: %s", w.Code, w.Body.String())
+	}
+	if ids := created.Action.PixelIDs; len(ids) != 2 || ids[0] != 1 || ids[1] != 2 || created.Action.Target != "domain:example.com" {
+		t.Fatalf("expected the pixels linking to example.com to be freed, got %+v", created.Action)
+	}
+	if w := forceFree(adminSession, gin.H{"pixel_ids": []int{3}, "reason": "spam"}); w.Code != http.StatusCreated {
+		t.Fatalf("expected pixel 3 to be freed, got %d %s", w.Code, w.Body.String())
+	}
+	if w := forceFree(adminSession, gin.H{"pixel_ids": []int{1, 3}, "reason": "spam"}); w.Code != http.StatusConflict {
+		t.Fatalf("expected free pixels to conflict, got %d %s", w.Code, w.Body.String())
+	}
+
+	owned, err := store.GetPixelsByOwner(ctx, owner.ID)
+	if err != nil || len(owned) != 0 {
+		t.Fatalf("expected the owner to keep no pixels, got %d, %v", len(owned), err)
+	}
+	w = call(server.handleListAdminActions, http.MethodGet, adminSession, nil)
+	var trail struct {
+		Actions []storage.AdminAction `json:"actions"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &trail); err != nil || len(trail.Actions) != 2 {
+		t.Fatalf("expected two audit entries, got %d %s", w.Code, w.Body.String())
+	}
+	if latest := trail.Actions[0]; latest.Action != storage.AdminActionForceFree || latest.Target != "pixels" || latest.Reason != "spam" || len(latest.PixelIDs) != 1 || latest.PixelIDs[0] != 3 {
+		t.Fatalf("unexpected latest audit entry %+v", latest)
+	}
+}
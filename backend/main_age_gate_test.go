@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestRegisterRequiresAgeAttestation(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.minimumAge = 18
+	server.disableVerificationEmail = true
+
+	register := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.handleRegister(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	year := time.Now().UTC().Year()
+	for _, body := range []string{
+		fmt.Sprintf(`{"email":"kid@example.com","password":"secret","birth_year":%d,"turnstile_token":"%s"}`, year-30, testTurnstileToken),
+		fmt.Sprintf(`{"email":"kid@example.com","password":"secret","age_attestation":true,"birth_year":%d,"turnstile_token":"%s"}`, year-10, testTurnstileToken),
+		fmt.Sprintf(`{"email":"kid@example.com","password":"secret","age_attestation":true,"birth_year":%d,"turnstile_token":"%s"}`, year+1, testTurnstileToken),
+	} {
+		if w := register(body); w.Code != http.StatusBadRequest {
+			t.Fatalf("body %s: expected status 400, got %d", body, w.Code)
+		}
+	}
+
+	w := register(fmt.Sprintf(`{"email":"adult@example.com","password":"secret","age_attestation":true,"birth_year":%d,"turnstile_token":"%s"}`, year-18, testTurnstileToken))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	user, err := store.GetUserByEmail(context.Background(), "adult@example.com")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	attestation, err := store.GetAgeAttestation(context.Background(), user.ID)
+	if err != nil || attestation.BirthYear != year-18 || attestation.MinimumAge != 18 || attestation.AttestedAt.IsZero() {
+		t.Fatalf("unexpected attestation %+v err=%v", attestation, err)
+	}
+}
+
+func TestPurchaseRequiresAgeAttestation(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.minimumAge = 18
+
+	do := func(handler func(*gin.Context), method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	purchase := `{"pixels":[{"id":1,"status":"taken","color":"#ffffff","url":"https://example.com"}]}`
+	w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", purchase)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without attestation, got %d", w.Code)
+	}
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != "age_attestation_required" {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+
+	body := fmt.Sprintf(`{"age_attestation":true,"birth_year":%d}`, time.Now().Year()-40)
+	if w := do(server.handleAgeAttestation, http.MethodPost, "/api/account/age-attestation", body); w.Code != http.StatusOK {
+		t.Fatalf("unexpected attestation status %d: %s", w.Code, w.Body.String())
+	}
+	w = do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", purchase)
+	resp.Code = ""
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code == "age_attestation_required" {
+		t.Fatalf("purchase should pass the age gate after attestation: %s", w.Body.String())
+	}
+
+	w = do(server.handleAccountExport, http.MethodGet, "/api/account/export", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") == "" {
+		t.Fatalf("unexpected export response %d %v", w.Code, w.Header())
+	}
+	var export accountExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if export.AgeAttestation == nil || export.AgeAttestation.MinimumAge != 18 || export.User.Email != "admin@example.com" {
+		t.Fatalf("unexpected export %+v", export)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/usage"
+)
+
+func TestAPIUsageTrackingAndQuota(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.apiUsage = usage.NewTracker()
+	server.apiPlans = config.APIUsage{
+		Plans:       map[string]config.APIPlan{"free": {DailyRequests: 2}},
+		DefaultPlan: "free",
+		AdminPlan:   "admin",
+	}
+	router := gin.Default()
+	router.Use(server.apiUsageMiddleware)
+	router.GET("/api/account", server.handleAccount)
+	router.GET(apiUsagePath, server.handleAccountUsage)
+
+	call := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := call("/api/account"); w.Code != http.StatusOK {
+			t.Fatalf("call %d: unexpected status %d", i, w.Code)
+		}
+	}
+	w := call("/api/account")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-Quota-Remaining") != "0" || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected quota rejection, got %d %v", w.Code, w.Header())
+	}
+
+	w = call(apiUsagePath)
+	if w.Code != http.StatusOK {
+		t.Fatalf("usage endpoint must stay available, got %d", w.Code)
+	}
+	var resp struct {
+		Plan       string         `json:"plan"`
+		DailyLimit int64          `json:"daily_limit"`
+		Remaining  int64          `json:"remaining"`
+		Usage      usage.Snapshot `json:"usage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode usage: %v", err)
+	}
+	if resp.Plan != "free" || resp.DailyLimit != 2 || resp.Remaining != 0 || resp.Usage.Today != 2 || resp.Usage.Rejected != 1 || resp.Usage.Routes["GET /api/account"] != 2 {
+		t.Fatalf("unexpected usage %+v", resp)
+	}
+}
+
+func TestAPIUsageAdminPlan(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.apiUsage = usage.NewTracker()
+	server.apiPlans = config.APIUsage{
+		Plans:       map[string]config.APIPlan{"free": {DailyRequests: 1}, "admin": {}},
+		DefaultPlan: "free",
+		AdminPlan:   "admin",
+	}
+	router := gin.Default()
+	router.Use(server.apiUsageMiddleware)
+	router.GET("/api/account", server.handleAccount)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/account", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("X-Quota-Limit") != "" {
+			t.Fatalf("admin plan should be unlimited, got %d %v", w.Code, w.Header())
+		}
+	}
+}
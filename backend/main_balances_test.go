@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqlite"
+)
+
+func TestRecomputeBalancesFromLedger(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "balances.db")
+	store, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	prepareStore(t, store)
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "BALA-NCES-BALA-NCES", 50); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "BALA-NCES-BALA-NCES"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	admin, err := store.CreateUser(ctx, "admin@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create admin: %v", err)
+	}
+	server := &Server{store: store, sessions: NewSessionManager(), adminEmails: newAdminSet([]string{"admin@example.com"})}
+	sessionID, err := server.sessions.Create(admin.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	// A balance changed behind the ledger's back, e.g. by hand in the database.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw sqlite: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(fmt.Sprintf(`UPDATE users SET user_points = 80 WHERE id = %d`, user.ID)); err != nil {
+		t.Fatalf("change balance: %v", err)
+	}
+
+	check := func(method, query string) balanceReport {
+		req := httptest.NewRequest(method, "/api/admin/balances"+query, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		c := &gin.Context{Writer: w, Request: req}
+		if method == http.MethodGet {
+			server.handleGetBalances(c)
+		} else {
+			server.handleRecomputeBalances(c)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var report balanceReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		return report
+	}
+
+	want := storage.BalanceDrift{UserID: user.ID, Balance: 80, Ledger: 50}
+	if report := check(http.MethodGet, ""); report.Repair || len(report.Drifts) != 1 || report.Drifts[0] != want {
+		t.Fatalf("expected the drift reported, got %+v", report)
+	}
+	if report := check(http.MethodPost, ""); report.Repair || len(report.Drifts) != 1 {
+		t.Fatalf("POST must default to a dry run, got %+v", report)
+	}
+	if report := check(http.MethodPost, "?dry_run=false"); !report.Repair || len(report.Drifts) != 1 || report.Drifts[0] != want {
+		t.Fatalf("expected the drift repaired, got %+v", report)
+	}
+	if repaired, err := store.GetUserByID(ctx, user.ID); err != nil || repaired.Points != 50 {
+		t.Fatalf("expected the balance set to the ledger's, got %+v (err %v)", repaired, err)
+	}
+	if report := check(http.MethodGet, ""); len(report.Drifts) != 0 {
+		t.Fatalf("expected no drift after the repair, got %+v", report)
+	}
+}
+
+func TestLedgerOpensWithExistingBalances(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "opening.db")
+	store, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	prepareStore(t, store)
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	_ = store.Close()
+
+	// A database from before the ledger: the balance exists only in users.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw sqlite: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(fmt.Sprintf(`DROP TABLE points_ledger; UPDATE users SET user_points = 120 WHERE id = %d`, user.ID)); err != nil {
+		t.Fatalf("downgrade database: %v", err)
+	}
+
+	store, err = sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	store.SetSkipPixelSeed(true)
+	if err := store.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	entries, err := store.ListLedgerEntries(ctx, user.ID)
+	if err != nil || len(entries) != 1 || entries[0].Kind != storage.LedgerOpeningBalance || entries[0].Delta != 120 {
+		t.Fatalf("expected an opening balance entry, got %+v (err %v)", entries, err)
+	}
+	if drifts, err := store.RecomputeBalances(ctx, false); err != nil || len(drifts) != 0 {
+		t.Fatalf("expected no drift after upgrading, got %+v (err %v)", drifts, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestAdminBannerLifecycle(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/banner", bytes.NewBufferString(`{"text":"Przerwa techniczna o 22:00","severity":"warning","expires_at":"`+expires+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handlePutBanner(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Banner *siteBanner `json:"banner"`
+	}
+	for _, handler := range []func(*gin.Context){server.handleConfig, server.handleSession} {
+		w = httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/config", nil)})
+		resp.Banner = nil
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Banner == nil || resp.Banner.Text != "Przerwa techniczna o 22:00" || resp.Banner.Severity != "warning" {
+			t.Fatalf("unexpected banner %+v", resp.Banner)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/admin/banner", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleDeleteBanner(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if banner := server.activeBanner(req.Context()); banner != nil {
+		t.Fatalf("expected banner to be cleared, got %+v", banner)
+	}
+}
+
+func TestAdminBannerValidation(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+
+	for _, body := range []string{
+		`{"text":""}`,
+		`{"text":"hello","severity":"loud"}`,
+		`{"text":"hello","expires_at":"2000-01-01T00:00:00Z"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/banner", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handlePutBanner(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("body %s: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestBoardsSharePixelIDsWithTheMainGrid(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		ctx := context.Background()
+		server.boards = []config.Board{{ID: "art", Name: "Art", PixelCostPoints: 25}}
+		buyer, buyerSession := buyPixelsForTest(t, server, store, "buyer@example.com", 1)
+		_, otherSession := buyPixelsForTest(t, server, store, "other@example.com", 2)
+
+		buy := func(board, session string, ids ...int) *httptest.ResponseRecorder {
+			t.Helper()
+			var pixels []PixelUpdate
+			for _, id := range ids {
+				pixels = append(pixels, PixelUpdate{ID: id, Status: "taken", Color: "#abcdef", URL: "https://example.com/art"})
+			}
+			body, _ := json.Marshal(boardPurchaseRequest{Pixels: pixels})
+			req := httptest.NewRequest(http.MethodPost, "/api/boards/"+board+"/pixels", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+			w := httptest.NewRecorder()
+			server.handleUpdateBoardPixels(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: board}}})
+			return w
+		}
+
+		// Pixel 1 of the board is free although the main grid's pixel 1 is taken, and costs the board's price.
+		w := buy("art", buyerSession, 1, 3)
+		if w.Code != http.StatusOK {
+			t.Fatalf("board purchase: unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var resp updatePixelResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode board purchase: %v", err)
+		}
+		if resp.User.Points != 1000-10-2*25 {
+			t.Fatalf("expected the board price charged, got %d points left", resp.User.Points)
+		}
+		if w := buy("art", otherSession, 1); w.Code != http.StatusForbidden {
+			t.Fatalf("expected a board pixel owned by another user to be refused, got %d: %s", w.Code, w.Body.String())
+		}
+		if w := buy("missing", buyerSession, 2); w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for an unknown board, got %d", w.Code)
+		}
+
+		// The moderation queue covers the main grid only, so boards are not sold while it is on.
+		server.moderation.Enabled = true
+		if w := buy("art", otherSession, 2); w.Code != http.StatusForbidden || !bytes.Contains(w.Body.Bytes(), []byte("board_moderation_unavailable")) {
+			t.Fatalf("expected a board purchase to be refused under the moderation queue, got %d: %s", w.Code, w.Body.String())
+		}
+		server.moderation.Enabled = false
+
+		board, err := store.GetBoardPixels(ctx, "art")
+		if err != nil {
+			t.Fatalf("get board pixels: %v", err)
+		}
+		if len(board.Pixels) != storage.TotalPixels || board.Pixels[1].Color != "#abcdef" || board.Pixels[3].OwnerID == nil || *board.Pixels[3].OwnerID != buyer.ID || board.Pixels[2].Status != "free" {
+			t.Fatalf("unexpected board pixels %+v %+v %+v", board.Pixels[1], board.Pixels[2], board.Pixels[3])
+		}
+		main, err := store.GetAllPixels(ctx)
+		if err != nil {
+			t.Fatalf("get main pixels: %v", err)
+		}
+		for _, pixel := range main.Pixels {
+			if pixel.ID == 1 && pixel.Color != "#123456" || pixel.ID == 3 && pixel.Status != "free" {
+				t.Fatalf("the board purchase changed the main grid: %+v", pixel)
+			}
+		}
+		stats, err := store.GetCanvasStats(ctx, 10)
+		if err != nil {
+			t.Fatalf("get canvas stats: %v", err)
+		}
+		if stats.PixelsTaken != 2 || len(stats.RecentPurchases) != 2 {
+			t.Fatalf("expected the canvas statistics to cover the main grid only, got %+v", stats)
+		}
+
+		w = httptest.NewRecorder()
+		server.handleListBoards(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/boards", nil)})
+		var list struct {
+			Boards []boardSummary `json:"boards"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("decode boards: %v", err)
+		}
+		if len(list.Boards) != 1 || list.Boards[0].ID != "art" || list.Boards[0].PixelCostPoints != 25 || list.Boards[0].PriceVersion != newPriceList(25, 0).Version {
+			t.Fatalf("unexpected boards %+v", list.Boards)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestFrontendHashTracksContents(t *testing.T) {
+	build := fstest.MapFS{
+		"dist/index.html":      {Data: []byte("<html>")},
+		"dist/assets/app-1.js": {Data: []byte("console.log(1)")},
+	}
+	first, err := frontendHash(build, "dist")
+	if err != nil || len(first) != 64 {
+		t.Fatalf("frontendHash() = %q, %v", first, err)
+	}
+	if again, _ := frontendHash(build, "dist"); again != first {
+		t.Fatalf("expected a stable hash, got %q and %q", first, again)
+	}
+
+	build["dist/assets/app-1.js"] = &fstest.MapFile{Data: []byte("console.log(2)")}
+	if changed, _ := frontendHash(build, "dist"); changed == first {
+		t.Fatal("expected the hash to change with an asset")
+	}
+	delete(build, "dist/assets/app-1.js")
+	build["dist/assets/app-2.js"] = &fstest.MapFile{Data: []byte("console.log(1)")}
+	if renamed, _ := frontendHash(build, "dist"); renamed == first {
+		t.Fatal("expected the hash to change with an asset name")
+	}
+}
+
+func TestVersionEndpointAndIndexETag(t *testing.T) {
+	router := gin.Default()
+	router.GET("/api/version", handleVersion)
+	router.NoRoute(serveIndex)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	var manifest buildManifest
+	if err := json.Unmarshal(w.Body.Bytes(), &manifest); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected version response %d %s (err %v)", w.Code, w.Body.String(), err)
+	}
+	if manifest.Version == "" || manifest.Commit == "" || len(manifest.FrontendHash) != 64 || !strings.HasPrefix(manifest.GoVersion, "go") {
+		t.Fatalf("incomplete build manifest %+v", manifest)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag != `"`+manifest.FrontendHash+`"` {
+		t.Fatalf("unexpected index response %d etag=%q", w.Code, etag)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected an unchanged frontend to be revalidated, got %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestHandleCreateActivationCodes(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+
+	body, _ := json.Marshal(map[string]any{"count": 3, "value": 25, "campaign_id": "spring", "batch_id": "flyers"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/activation-codes", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleCreateActivationCodes(&gin.Context{Writer: w, Request: req})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Codes []string `json:"codes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Codes) != 3 {
+		t.Fatalf("expected 3 codes, got %d", len(resp.Codes))
+	}
+	for _, code := range resp.Codes {
+		if !server.codeFormat.Valid(code) {
+			t.Fatalf("generated code %q does not match format", code)
+		}
+		record, err := store.GetActivationCode(context.Background(), code)
+		if err != nil {
+			t.Fatalf("get activation code %s: %v", code, err)
+		}
+		if record.CampaignID != "spring" || record.BatchID != "flyers" || record.Value != 25 {
+			t.Fatalf("unexpected stored code %+v", record)
+		}
+	}
+}
+
+func TestHandleCampaignStats(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+
+	if err := store.CreateActivationCodeBatch(ctx, storage.ActivationCodeBatch{
+		CampaignID: "spring",
+		BatchID:    "flyers",
+		Value:      30,
+		Codes:      []string{"AAAA-AAAA-AAAA-AAA1", "AAAA-AAAA-AAAA-AAA2", "AAAA-AAAA-AAAA-AAA3"},
+	}); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	if err := store.CreateActivationCodeBatch(ctx, storage.ActivationCodeBatch{
+		CampaignID: "spring",
+		BatchID:    "posters",
+		Value:      50,
+		Codes:      []string{"BBBB-BBBB-BBBB-BBB1"},
+	}); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+
+	user, err := store.CreateUser(ctx, "buyer@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "AAAA-AAAA-AAAA-AAA1"); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "BBBB-BBBB-BBBB-BBB1"); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "AAAA-AAAA-AAAA-AAA1"); err == nil {
+		t.Fatalf("expected second redemption of the same code to fail")
+	}
+	for _, id := range []int{1, 2} {
+		if _, _, err := store.UpdatePixelForUserWithCost(ctx, user.ID, storage.Pixel{ID: id, Status: "taken", Color: "#ff0000", URL: "https://example.com"}, 10); err != nil {
+			t.Fatalf("buy pixel %d: %v", id, err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/campaigns/spring/stats", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	c := &gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: "spring"}}}
+	server.handleCampaignStats(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats storage.CampaignStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if stats.CodesIssued != 4 || stats.CodesRedeemed != 2 {
+		t.Fatalf("unexpected code counts: %+v", stats)
+	}
+	if stats.RedemptionRate != 0.5 {
+		t.Fatalf("expected redemption rate 0.5, got %v", stats.RedemptionRate)
+	}
+	if stats.PointsIssued != 80 || stats.RedeemingUsers != 1 {
+		t.Fatalf("unexpected points or users: %+v", stats)
+	}
+	if stats.PixelsPurchased != 2 || stats.PurchasingUsers != 1 {
+		t.Fatalf("unexpected purchase figures: %+v", stats)
+	}
+	if len(stats.Batches) != 2 || stats.Batches[0].BatchID != "flyers" || stats.Batches[0].CodesRedeemed != 1 || stats.Batches[1].PointsIssued != 50 {
+		t.Fatalf("unexpected batch breakdown: %+v", stats.Batches)
+	}
+
+	missing := httptest.NewRequest(http.MethodGet, "/api/admin/campaigns/unknown/stats", nil)
+	missing.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleCampaignStats(&gin.Context{Writer: w, Request: missing, Params: gin.Params{{Key: "id", Value: "unknown"}}})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for unknown campaign, got %d", w.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/archive"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestCanvasArchive(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	dir, err := archive.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("create archive: %v", err)
+	}
+	get := func(handler gin.HandlerFunc, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, target, nil)})
+		return w
+	}
+	if w := get(server.handleListCanvasArchive, "/api/canvas/archive"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while archiving is disabled, got %d", w.Code)
+	}
+
+	server.canvasArchive = newCanvasArchive(dir, 24*time.Hour, true)
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 1, Status: "taken", Color: "#ff0000", URL: "https://example.com", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("take pixel: %v", err)
+	}
+	if err := server.archiveCanvas(ctx); err != nil {
+		t.Fatalf("archive canvas: %v", err)
+	}
+	// The snapshot is not due again within the period.
+	if err := server.archiveCanvas(ctx); err != nil {
+		t.Fatalf("archive canvas again: %v", err)
+	}
+	names, err := dir.List(ctx)
+	if err != nil || len(names) != 2 {
+		t.Fatalf("expected one blob and one png, got %v (%v)", names, err)
+	}
+
+	w := get(server.handleListCanvasArchive, "/api/canvas/archive")
+	var list struct {
+		Snapshots []time.Time `json:"snapshots"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Snapshots) != 1 {
+		t.Fatalf("unexpected snapshot list %s (%v)", w.Body.String(), err)
+	}
+	restored, err := server.loadArchivedGrid(ctx, list.Snapshots[0])
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	var found bool
+	for _, pixel := range restored.Pixels {
+		if pixel.ID == 1 {
+			found = pixel.URL == "https://example.com" && pixel.OwnerID != nil && *pixel.OwnerID == admin.ID
+		}
+	}
+	if !found {
+		t.Fatalf("snapshot lost pixel 1: %+v", restored.Pixels)
+	}
+
+	// Later changes do not alter the canvas of the past.
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 1, Status: "taken", Color: "#0000ff", URL: "https://example.com", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("repaint pixel: %v", err)
+	}
+	w = get(server.handleGetArchivedCanvasPNG, "/api/canvas/archive.png?at="+time.Now().UTC().Format(time.DateOnly))
+	if w.Code != http.StatusOK || w.Header().Get("X-Snapshot-Time") != list.Snapshots[0].Format(time.RFC3339) {
+		t.Fatalf("unexpected response %d %v: %s", w.Code, w.Header(), w.Body.String())
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if r, g, b, _ := img.At(1, 0).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Fatalf("archived pixel drawn as %v", img.At(1, 0))
+	}
+
+	for query, status := range map[string]int{
+		"?at=2000-01-01":           http.StatusNotFound,
+		"?at=yesterday":            http.StatusBadRequest,
+		"?at=2999-01-01&width=123": http.StatusBadRequest,
+	} {
+		if w := get(server.handleGetArchivedCanvasPNG, "/api/canvas/archive.png"+query); w.Code != status {
+			t.Fatalf("%s: expected %d, got %d", query, status, w.Code)
+		}
+	}
+}
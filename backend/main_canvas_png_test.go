@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestGetCanvasPNG(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 1, Status: "taken", Color: "#ff0000", URL: "https://example.com", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("take pixel: %v", err)
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.handleGetCanvasPNG(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/canvas.png"+query, nil)})
+		return w
+	}
+
+	w := get("")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("decode canvas: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != storage.GridWidth || bounds.Dy() != storage.GridHeight {
+		t.Fatalf("unexpected size %v", bounds)
+	}
+	if r, g, b, _ := img.At(1, 0).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Fatalf("taken pixel drawn as %v", img.At(1, 0))
+	}
+
+	w = get("?width=200")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if img, err = png.Decode(w.Body); err != nil || img.Bounds().Dx() != 200 || img.Bounds().Dy() != 200 {
+		t.Fatalf("unexpected scaled canvas %v, %v", img.Bounds(), err)
+	}
+
+	for _, query := range []string{"?width=123", "?width=x", "?width=5000"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
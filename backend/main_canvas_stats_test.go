@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// buyPixelsForTest signs up a user with enough points and buys the pixels through the purchase
+// handler, so history and prices are recorded as in production. It returns the user's session.
+func buyPixelsForTest(t *testing.T, server *Server, store storage.Store, email string, ids ...int) (storage.User, string) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, email, "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	code := fmt.Sprintf("TEST-CODE-%04d-BUYS", user.ID)
+	if err := store.CreateActivationCode(ctx, code, 1000); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, code); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	var pixels []PixelUpdate
+	for _, id := range ids {
+		pixels = append(pixels, PixelUpdate{ID: id, Status: "taken", Color: "#123456", URL: "https://example.com/"})
+	}
+	body, _ := json.Marshal(UpdatePixelRequest{Pixels: pixels})
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("purchase: unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	return user, sessionID
+}
+
+func TestCanvasStats(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		ctx := context.Background()
+		if err := store.InsertPixel(ctx, storage.Pixel{ID: 1001, Status: "free"}); err != nil {
+			t.Fatalf("insert pixel: %v", err)
+		}
+		buyPixelsForTest(t, server, store, "first@example.com", 1, 2)
+		buyPixelsForTest(t, server, store, "second@example.com", 1001)
+
+		w := httptest.NewRecorder()
+		server.handleCanvasStats(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/stats", nil)})
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var stats canvasStatsResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("decode stats: %v", err)
+		}
+		if stats.PixelsTaken != 3 || stats.PixelsFree != storage.TotalPixels-3 || stats.Owners != 2 || stats.PointsSpent != 30 {
+			t.Fatalf("unexpected totals %+v", stats)
+		}
+		if len(stats.RecentPurchases) != 3 {
+			t.Fatalf("expected 3 recent purchases, got %+v", stats.RecentPurchases)
+		}
+		if latest := stats.RecentPurchases[0]; latest.ID != 1001 || latest.X != 1 || latest.Y != 1 || latest.PurchasedAt.IsZero() {
+			t.Fatalf("unexpected latest purchase %+v", latest)
+		}
+		if bytes.Contains(w.Body.Bytes(), []byte("example.com")) {
+			t.Fatalf("stats expose pixel owners or content: %s", w.Body.String())
+		}
+	})
+}
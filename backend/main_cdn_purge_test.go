@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/cloudflare"
+)
+
+func TestPixelPurchaseQueuesCDNPurge(t *testing.T) {
+	var purged []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Files []string `json:"files"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		purged = append(purged, body.Files...)
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	defer api.Close()
+
+	server, store, sessionID := newAdminTestServer(t)
+	purger, err := cloudflare.NewPurger(cloudflare.Config{ZoneID: "zone", APIToken: "token", APIBase: api.URL})
+	if err != nil {
+		t.Fatalf("NewPurger() error = %v", err)
+	}
+	server.cdnPurger = purger
+	server.purgeURLs = prefixURLs("https://kuppixel.pl", []string{"/api/pixels"})
+	server.pixelPurgeURLs = prefixURLs("https://kuppixel.pl", []string{"/embed/{id}"})
+
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "PURG-EPUR-GEPU-RGE1", 100); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "PURG-EPUR-GEPU-RGE1"); err != nil {
+		t.Fatalf("redeem code: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"pixels":[{"id":2,"status":"taken","color":"#ffffff","url":"https://example.com"},{"id":3,"status":"taken","color":"#000000","url":"https://example.com"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := purger.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	sort.Strings(purged)
+	want := "https://kuppixel.pl/api/pixels https://kuppixel.pl/embed/2 https://kuppixel.pl/embed/3"
+	if got := strings.Join(purged, " "); got != want {
+		t.Fatalf("purged %q, want %q", got, want)
+	}
+}
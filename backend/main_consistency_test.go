@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqlite"
+)
+
+func TestConsistencyCheckReportsAndRepairs(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	missing := int64(999)
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com", OwnerID: &missing}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	if _, err := store.CreateVerificationToken(ctx, "orphaned-token", missing, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	check := func(method, query string) consistencyReport {
+		req := httptest.NewRequest(method, "/api/admin/consistency"+query, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		c := &gin.Context{Writer: w, Request: req}
+		if method == http.MethodGet {
+			server.handleGetConsistency(c)
+		} else {
+			server.handleCheckConsistency(c)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var report consistencyReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		return report
+	}
+	found := func(report consistencyReport, kind string) storage.Anomaly {
+		for _, anomaly := range report.Anomalies {
+			if anomaly.Kind == kind {
+				return anomaly
+			}
+		}
+		t.Fatalf("report has no %s entry: %+v", kind, report)
+		return storage.Anomaly{}
+	}
+
+	report := check(http.MethodGet, "")
+	if pixels := found(report, storage.AnomalyOrphanedPixels); report.Repair || len(pixels.IDs) != 1 || pixels.IDs[0] != 2 || pixels.Repaired != 0 {
+		t.Fatalf("expected a dry run finding pixel 2, got %+v", report)
+	}
+	if tokens := found(report, storage.AnomalyOrphanedVerificationTokens); len(tokens.IDs) != 1 || tokens.IDs[0] != missing {
+		t.Fatalf("expected the orphaned token, got %+v", tokens)
+	}
+	if balances := found(report, storage.AnomalyNegativeBalances); len(balances.IDs) != 0 {
+		t.Fatalf("expected no negative balances, got %+v", balances)
+	}
+	if report := check(http.MethodPost, ""); report.Repair || found(report, storage.AnomalyOrphanedPixels).Repaired != 0 {
+		t.Fatalf("POST must default to a dry run, got %+v", report)
+	}
+
+	report = check(http.MethodPost, "?dry_run=false")
+	if !report.Repair || found(report, storage.AnomalyOrphanedPixels).Repaired != 1 || found(report, storage.AnomalyOrphanedVerificationTokens).Repaired != 1 {
+		t.Fatalf("expected both anomalies repaired, got %+v", report)
+	}
+	state, err := store.GetAllPixels(ctx)
+	if err != nil {
+		t.Fatalf("load pixels: %v", err)
+	}
+	if pixel := state.Pixels[1]; pixel.Status != "free" || pixel.OwnerID != nil {
+		t.Fatalf("orphaned pixel must be freed, got %+v", pixel)
+	}
+	if report := check(http.MethodGet, ""); !report.Repair || found(report, storage.AnomalyOrphanedPixels).Repaired != 1 {
+		t.Fatalf("GET must return the latest report, got %+v", report)
+	}
+	if report := check(http.MethodPost, ""); len(found(report, storage.AnomalyOrphanedPixels).IDs) != 0 || len(found(report, storage.AnomalyOrphanedVerificationTokens).IDs) != 0 {
+		t.Fatalf("expected a clean database after repair, got %+v", report)
+	}
+}
+
+func TestConsistencyRepairsLedgerMismatches(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "consistency.db")
+	store, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	prepareStore(t, store)
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "LEDG-ERLE-DGER-LEDG", 50); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "LEDG-ERLE-DGER-LEDG"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw sqlite: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(fmt.Sprintf(`UPDATE users SET user_points = -5 WHERE id = %d`, user.ID)); err != nil {
+		t.Fatalf("change balance: %v", err)
+	}
+
+	idsOf := func(anomalies []storage.Anomaly, kind string) []int64 {
+		for _, anomaly := range anomalies {
+			if anomaly.Kind == kind {
+				return anomaly.IDs
+			}
+		}
+		t.Fatalf("no %s entry in %+v", kind, anomalies)
+		return nil
+	}
+	anomalies, err := store.CheckConsistency(ctx, false)
+	if err != nil {
+		t.Fatalf("check consistency: %v", err)
+	}
+	if ids := idsOf(anomalies, storage.AnomalyLedgerMismatches); len(ids) != 1 || ids[0] != user.ID {
+		t.Fatalf("expected the ledger mismatch found, got %+v", anomalies)
+	}
+
+	// The balance is restored from the ledger before negative balances are looked for.
+	if anomalies, err = store.CheckConsistency(ctx, true); err != nil {
+		t.Fatalf("repair consistency: %v", err)
+	}
+	if ids := idsOf(anomalies, storage.AnomalyNegativeBalances); len(ids) != 0 {
+		t.Fatalf("expected no negative balance left to reset, got %+v", anomalies)
+	}
+	if repaired, err := store.GetUserByID(ctx, user.ID); err != nil || repaired.Points != 50 {
+		t.Fatalf("expected the balance set to the ledger's, got %+v (err %v)", repaired, err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestContactMessageIsStoredAndAnswered(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	mailer := &noticeMailer{}
+	server.mailer = mailer
+
+	do := func(handler func(*gin.Context), method, target, body string, params gin.Params, withSession bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if withSession {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		}
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req, Params: params})
+		return w
+	}
+
+	if w := do(server.handleContact, http.MethodPost, "/api/contact", `{"subject":"Hi","message":"Hello","turnstile_token":"`+testTurnstileToken+`"}`, nil, false); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected anonymous message without email to be rejected, got %d", w.Code)
+	}
+	w := do(server.handleContact, http.MethodPost, "/api/contact", `{"name":"Ewa","email":"ewa@example.com","subject":"Faktura","message":"Proszę o fakturę.","turnstile_token":"`+testTurnstileToken+`"}`, nil, false)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected contact status %d: %s", w.Code, w.Body.String())
+	}
+	if len(mailer.notices) != 1 || mailer.recipients[0] != "admin@example.com" || !strings.Contains(mailer.notices[0].Body, "Proszę o fakturę.") {
+		t.Fatalf("unexpected admin notices %+v", mailer.notices)
+	}
+
+	if w := do(server.handleContact, http.MethodPost, "/api/contact", `{"subject":"Konto","message":"Pytanie","turnstile_token":"`+testTurnstileToken+`"}`, nil, true); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected logged-in contact status %d: %s", w.Code, w.Body.String())
+	}
+	linked, err := store.GetContactMessage(context.Background(), 2)
+	if err != nil || linked.UserID == nil || linked.Email != "admin@example.com" {
+		t.Fatalf("expected message linked to the session user, got %+v err=%v", linked, err)
+	}
+
+	params := gin.Params{{Key: "id", Value: "1"}}
+	w = do(server.handleAdminContactReply, http.MethodPost, "/api/admin/contact/1/reply", `{"message":"Faktura w załączniku."}`, params, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected reply status %d: %s", w.Code, w.Body.String())
+	}
+	if last := len(mailer.notices) - 1; mailer.recipients[last] != "ewa@example.com" || mailer.notices[last].Subject != "Re: Faktura" {
+		t.Fatalf("unexpected reply email to %s: %+v", mailer.recipients[last], mailer.notices[last])
+	}
+
+	w = do(server.handleAdminContactMessages, http.MethodGet, "/api/admin/contact", "", nil, true)
+	var open struct {
+		Messages []storage.ContactMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &open); err != nil || len(open.Messages) != 1 || open.Messages[0].ID != 2 {
+		t.Fatalf("expected only the unanswered message to be open: %s", w.Body.String())
+	}
+
+	w = do(server.handleAdminContactMessage, http.MethodGet, "/api/admin/contact/1", "", params, true)
+	var detail struct {
+		Message storage.ContactMessage `json:"message"`
+		Replies []storage.ContactReply `json:"replies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil || detail.Message.Status != storage.ContactStatusAnswered || len(detail.Replies) != 1 {
+		t.Fatalf("unexpected detail %s", w.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestContentBlocklistReloadAndUpdate(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	cfg := config.Default()
+	cfg.PixelContent.BlockedTerms = []string{"casino"}
+	server.configPath = filepath.Join(t.TempDir(), "config.json")
+	if err := config.WriteFile(server.configPath, cfg); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	call := func(handler func(*gin.Context), method, body string) (int, []string, string) {
+		req := httptest.NewRequest(method, "/api/admin/blocklist", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		var resp struct {
+			Entries []string `json:"entries"`
+			Source  string   `json:"source"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Entries, resp.Source
+	}
+	blocked := func(title string) bool {
+		return server.pixelContentError("https://example.com", title, "") != ""
+	}
+
+	if code, entries, source := call(server.handleReloadContentBlocklist, http.MethodPost, ""); code != http.StatusOK || len(entries) != 1 || source != "config" {
+		t.Fatalf("unexpected reload %d %v %s", code, entries, source)
+	}
+	if !blocked("Best CASINO") || blocked("Bet365") {
+		t.Fatal("expected the configured phrase to be blocked")
+	}
+
+	if code, _, _ := call(server.handlePutContentBlocklist, http.MethodPut, `{"entries":["/bet(/"]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid expression to be rejected, got %d", code)
+	}
+	if code, entries, source := call(server.handlePutContentBlocklist, http.MethodPut, `{"entries":["/bet\\d+/", " "]}`); code != http.StatusOK || len(entries) != 1 || source != "admin" {
+		t.Fatalf("unexpected update %d %v %s", code, entries, source)
+	}
+	if !blocked("BET365 bonus") || blocked("Best casino") || blocked("Better") {
+		t.Fatal("expected the saved expression to replace the configured phrase")
+	}
+	// The saved list outlives reloads of the config file.
+	if code, entries, source := call(server.handleReloadContentBlocklist, http.MethodPost, ""); code != http.StatusOK || entries[0] != `/bet\d+/` || source != "admin" {
+		t.Fatalf("unexpected reload %d %v %s", code, entries, source)
+	}
+	if code, _, source := call(server.handleGetContentBlocklist, http.MethodGet, ""); code != http.StatusOK || source != "admin" {
+		t.Fatalf("unexpected list %d %s", code, source)
+	}
+
+	if code, entries, source := call(server.handleDeleteContentBlocklist, http.MethodDelete, ""); code != http.StatusOK || entries[0] != "casino" || source != "config" {
+		t.Fatalf("unexpected delete %d %v %s", code, entries, source)
+	}
+	if !blocked("casino") || blocked("bet365") {
+		t.Fatal("expected the configured phrase to be back in force")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestCountryRestrictionsBlockRegistrationUnlessOverridden(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	policy, err := newCountryPolicy(config.CountryRestrictions{
+		Deny:           []string{"RU"},
+		CountryHeader:  "CF-IPCountry",
+		OverrideSecret: "support-secret",
+	})
+	if err != nil {
+		t.Fatalf("newCountryPolicy: %v", err)
+	}
+	server.countryPolicy = policy
+
+	register := func(country, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("CF-IPCountry", country)
+		w := httptest.NewRecorder()
+		server.handleRegister(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	w := register("ru", fmt.Sprintf(`{"email":"blocked@example.com","password":"secret","turnstile_token":"%s"}`, testTurnstileToken))
+	if w.Code != http.StatusUnavailableForLegalReasons {
+		t.Fatalf("expected 451, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := register("PL", fmt.Sprintf(`{"email":"allowed@example.com","password":"secret","turnstile_token":"%s"}`, testTurnstileToken)); w.Code != http.StatusCreated {
+		t.Fatalf("expected allowed country to register, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/country-overrides", bytes.NewBufferString(`{"email":"Blocked@example.com","days":7}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleCreateCountryOverride(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected override status %d: %s", w.Code, w.Body.String())
+	}
+	var override struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &override); err != nil || override.Code == "" {
+		t.Fatalf("unexpected override body %s", w.Body.String())
+	}
+
+	if w := register("RU", fmt.Sprintf(`{"email":"other@example.com","password":"secret","country_override":"%s","turnstile_token":"%s"}`, override.Code, testTurnstileToken)); w.Code != http.StatusUnavailableForLegalReasons {
+		t.Fatalf("expected override bound to another email to be rejected, got %d", w.Code)
+	}
+	if w := register("RU", fmt.Sprintf(`{"email":"blocked@example.com","password":"secret","country_override":"%s","turnstile_token":"%s"}`, override.Code, testTurnstileToken)); w.Code != http.StatusCreated {
+		t.Fatalf("expected override to admit registration, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCountryPolicyAllowListAndExpiredOverrides(t *testing.T) {
+	policy, err := newCountryPolicy(config.CountryRestrictions{
+		Allow:          []string{"PL", "DE"},
+		CountryHeader:  "CF-IPCountry",
+		BlockUnknown:   true,
+		OverrideSecret: "support-secret",
+	})
+	if err != nil {
+		t.Fatalf("newCountryPolicy: %v", err)
+	}
+	for country, want := range map[string]bool{"PL": true, "DE": true, "US": false, "": false} {
+		if got := policy.allowed(country); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", country, got, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("CF-IPCountry", "XX")
+	if got := policy.country(req); got != "" {
+		t.Fatalf("expected unknown country for XX, got %q", got)
+	}
+
+	now := time.Now()
+	code := policy.overrideCode("user@example.com", now.AddDate(0, 0, -2))
+	if policy.validOverride("user@example.com", code, now) {
+		t.Fatal("expected expired override to be rejected")
+	}
+	code = policy.overrideCode("user@example.com", now.AddDate(0, 0, 1))
+	if !policy.validOverride("USER@example.com", code, now) {
+		t.Fatal("expected override to match case-insensitively")
+	}
+}
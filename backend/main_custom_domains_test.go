@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestCustomDomainIsRoutedAfterVerification(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.customDomains = config.CustomDomains{Enabled: true, MaxPerUser: 1, CheckIntervalMinutes: 5, PendingTTLHours: 72}
+		server.domainRoutes = newCustomDomainRoutes()
+		records := make(map[string][]string)
+		server.lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+			if values, ok := records[name]; ok {
+				return values, nil
+			}
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "owner@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "HOST-HOST-HOST-HOST", 50); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "HOST-HOST-HOST-HOST"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		call := func(handler gin.HandlerFunc, method string, body any, params ...gin.Param) *httptest.ResponseRecorder {
+			payload, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, "/api/account/domains", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			w := httptest.NewRecorder()
+			handler(&gin.Context{Writer: w, Request: req, Params: params})
+			return w
+		}
+		if w := call(server.handleUpdatePixel, http.MethodPost, gin.H{
+			"pixels": []gin.H{{"id": 1, "status": "taken", "color": "#123456", "url": "https://example.com/landing"}},
+		}); w.Code != http.StatusOK {
+			t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+		}
+
+		for _, tc := range []struct {
+			body gin.H
+			want int
+		}{
+			{gin.H{"domain": "https://moja.example.pl", "pixel_id": 1}, http.StatusBadRequest},
+			{gin.H{"domain": "moja.example.pl"}, http.StatusBadRequest},
+			{gin.H{"domain": "moja.example.pl", "pixel_id": 2}, http.StatusNotFound},
+		} {
+			if w := call(server.handleCreateCustomDomain, http.MethodPost, tc.body); w.Code != tc.want {
+				t.Fatalf("%v: expected %d, got %d %s", tc.body, tc.want, w.Code, w.Body.String())
+			}
+		}
+		w := call(server.handleCreateCustomDomain, http.MethodPost, gin.H{"domain": "Moja.Example.PL.", "pixel_id": 1})
+		var created struct {
+			Domain    storage.CustomDomain `json:"domain"`
+			Challenge struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"challenge"`
+		}
+		if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.Challenge.Name != "_kuppiksel-challenge.moja.example.pl" {
+			t.Fatalf("unexpected registration %d %s", w.Code, w.Body.String())
+		}
+		if w := call(server.handleCreateCustomDomain, http.MethodPost, gin.H{"domain": "druga.example.pl", "pixel_id": 1}); w.Code != http.StatusConflict {
+			t.Fatalf("expected the domain limit to apply, got %d", w.Code)
+		}
+		// Until someone verifies it, a claim of the domain does not keep others from claiming it.
+		squatter, err := store.CreateCustomDomain(ctx, storage.CustomDomain{Domain: "moja.example.pl", UserID: user.ID + 1, Token: "other"})
+		if err != nil {
+			t.Fatalf("expected an unverified domain to be claimable by others, got %v", err)
+		}
+		stale, err := store.CreateCustomDomain(ctx, storage.CustomDomain{Domain: "stara.example.pl", UserID: user.ID + 1, Token: "stale", CreatedAt: time.Now().Add(-73 * time.Hour)})
+		if err != nil {
+			t.Fatalf("CreateCustomDomain() error = %v", err)
+		}
+
+		router := gin.Default()
+		router.Use(server.customDomainMiddleware)
+		router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "board") })
+		visit := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = "moja.example.pl"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+		check := func() {
+			t.Helper()
+			if err := server.checkCustomDomains(ctx); err != nil {
+				t.Fatalf("checkCustomDomains() error = %v", err)
+			}
+		}
+
+		check()
+		if w := visit(); w.Code != http.StatusOK {
+			t.Fatalf("expected an unverified domain to be ignored, got %d", w.Code)
+		}
+		records[created.Challenge.Name] = []string{"unrelated", created.Challenge.Value}
+		check()
+		if w := visit(); w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/landing" {
+			t.Fatalf("expected a redirect to the pixel link, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		domains, err := store.ListCustomDomainsByState(ctx, false)
+		if err != nil || len(domains) != 0 {
+			t.Fatalf("expected the stale domain %d and the losing claim %d to be removed, got %+v (err %v)", stale.ID, squatter.ID, domains, err)
+		}
+		if _, err := store.CreateCustomDomain(ctx, storage.CustomDomain{Domain: "moja.example.pl", UserID: user.ID + 1, Token: "late"}); !errors.Is(err, storage.ErrDomainTaken) {
+			t.Fatalf("expected a verified domain to be taken, got %v", err)
+		}
+
+		// A failed lookup keeps the domain routed, a removed record ends it.
+		lookupErr := errors.New("i/o timeout")
+		lookupTXT := server.lookupTXT
+		server.lookupTXT = func(ctx context.Context, name string) ([]string, error) { return nil, lookupErr }
+		check()
+		if w := visit(); w.Code != http.StatusFound {
+			t.Fatalf("expected a failed lookup to keep the domain routed, got %d", w.Code)
+		}
+		server.lookupTXT = lookupTXT
+		delete(records, created.Challenge.Name)
+		check()
+		if w := visit(); w.Code != http.StatusOK {
+			t.Fatalf("expected a domain without its record to stop redirecting, got %d", w.Code)
+		}
+		if domains, err := store.ListCustomDomains(ctx, user.ID); err != nil || len(domains) != 0 {
+			t.Fatalf("expected the lapsed domain to be removed, got %+v (err %v)", domains, err)
+		}
+
+		// Deleting a domain stops routing it right away.
+		w = call(server.handleCreateCustomDomain, http.MethodPost, gin.H{"domain": "moja.example.pl", "pixel_id": 1})
+		if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil {
+			t.Fatalf("unexpected registration %d %s", w.Code, w.Body.String())
+		}
+		records[created.Challenge.Name] = []string{created.Challenge.Value}
+		check()
+		if w := visit(); w.Code != http.StatusFound {
+			t.Fatalf("expected the domain to be routed again, got %d", w.Code)
+		}
+
+		if w := call(server.handleDeleteCustomDomain, http.MethodDelete, nil, gin.Param{Key: "id", Value: strconv.FormatInt(created.Domain.ID, 10)}); w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected delete status %d", w.Code)
+		}
+		if w := visit(); w.Code != http.StatusOK {
+			t.Fatalf("expected a deleted domain to stop redirecting, got %d", w.Code)
+		}
+	})
+}
+
+func TestNormalizeCustomDomain(t *testing.T) {
+	for raw, want := range map[string]string{
+		" Piksel.Example.com. ": "piksel.example.com",
+		"xn--pksel-xya.pl":      "xn--pksel-xya.pl",
+		"localhost":             "",
+		"127.0.0.1":             "",
+		"example.com:8080":      "",
+		"-bad.example.com":      "",
+		"a..example.com":        "",
+	} {
+		got, ok := normalizeCustomDomain(raw)
+		if got != want || ok != (want != "") {
+			t.Fatalf("normalizeCustomDomain(%q) = %q, %t", raw, got, ok)
+		}
+	}
+}
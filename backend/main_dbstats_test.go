@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func newDBStatsRouter(server *Server) *gin.Engine {
+	router := gin.Default()
+	router.Use(server.dbStatsMiddleware)
+	router.GET("/api/pixels", server.handleGetPixels)
+	return router
+}
+
+func TestDBStatsHeadersForAdmin(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	router := newDBStatsRouter(server)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+	req.Header.Set(dbStatsRequestHeader, "1")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	stats := w.Header().Get(dbStatsHeader)
+	if !strings.HasPrefix(stats, "statements=") || strings.HasPrefix(stats, "statements=0 ") {
+		t.Fatalf("expected recorded statements, got %q", stats)
+	}
+	if !strings.HasPrefix(w.Header().Get("Server-Timing"), "db;dur=") {
+		t.Fatalf("expected Server-Timing header, got %q", w.Header().Get("Server-Timing"))
+	}
+	if w.Header().Get(dbSlowestHeader) == "" {
+		t.Fatal("expected slowest statement header")
+	}
+}
+
+func TestDBStatsHeadersHiddenFromNonAdmins(t *testing.T) {
+	server, store, adminSession := newAdminTestServer(t)
+	router := newDBStatsRouter(server)
+
+	user, err := store.CreateUser(context.Background(), "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	userSession, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		header  string
+	}{
+		{name: "regular user", session: userSession, header: "1"},
+		{name: "anonymous", header: "1"},
+		{name: "admin without header", session: adminSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+			if tt.header != "" {
+				req.Header.Set(dbStatsRequestHeader, tt.header)
+			}
+			if tt.session != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.session})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("unexpected status %d", w.Code)
+			}
+			if got := w.Header().Get(dbStatsHeader); got != "" {
+				t.Fatalf("stats must not be exposed, got %q", got)
+			}
+		})
+	}
+}
+
+func TestSchemaHasExpectedIndexes(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		missing, err := store.MissingIndexes(context.Background())
+		if err != nil {
+			t.Fatalf("missing indexes: %v", err)
+		}
+		if len(missing) != 0 {
+			t.Fatalf("schema lacks indexes %v", missing)
+		}
+	})
+}
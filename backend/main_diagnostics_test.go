@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func serveDiagnostics(server *Server, path, sessionID string) *httptest.ResponseRecorder {
+	router := gin.Default()
+	router.GET("/api/admin/debug/vars", server.handleAdminDiagnostics)
+	router.GET(adminPprofPrefix+"*name", server.handleAdminDiagnostics)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if sessionID != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminDiagnosticsServesProfilesAndVars(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+
+	w := serveDiagnostics(server, adminPprofPrefix, sessionID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Fatalf("unexpected pprof index: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveDiagnostics(server, adminPprofPrefix+"goroutine?debug=1", sessionID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile") {
+		t.Fatalf("unexpected goroutine profile: %d %s", w.Code, w.Body.String())
+	}
+
+	w = serveDiagnostics(server, "/api/admin/debug/vars", sessionID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"memstats"`) || !strings.Contains(w.Body.String(), `"frontend_hash"`) {
+		t.Fatalf("unexpected expvar output: %d", w.Code)
+	}
+}
+
+func TestAdminDiagnosticsRequireAdmin(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+
+	if w := serveDiagnostics(server, adminPprofPrefix+"heap", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without session, got %d", w.Code)
+	}
+
+	user, err := store.CreateUser(context.Background(), "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if w := serveDiagnostics(server, "/api/admin/debug/vars", sessionID); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for regular user, got %d", w.Code)
+	}
+}
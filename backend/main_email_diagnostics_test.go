@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+)
+
+func TestEmailTestReportsTransportPhases(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/email-test", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleEmailTest(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	server.mailer = &fakeMailer{}
+	if w := send(`{}`); w.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for mailer without test support, got %d", w.Code)
+	}
+
+	server.mailer = email.NewConsoleMailer("Kup Piksel", "en")
+	if w := send(`{"to":"not-an-address"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid recipient, got %d", w.Code)
+	}
+
+	w := send(`{}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		OK        bool          `json:"ok"`
+		Transport string        `json:"transport"`
+		To        string        `json:"to"`
+		Phases    []email.Phase `json:"phases"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.OK || resp.Transport != "console" || resp.To != "admin@example.com" || len(resp.Phases) != 1 || resp.Phases[0].Status != email.PhaseOK {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
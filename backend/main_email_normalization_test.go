@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/emailaddr"
+)
+
+func TestRegisterRejectsGmailAliasesOfExistingAccount(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	server.emailPolicy = emailaddr.Policy{ProviderRules: true}
+
+	register := func(address string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":"%s","password":"secret","turnstile_token":"%s"}`, address, testTurnstileToken)
+		req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.handleRegister(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	if w := register("Jan.Kowalski+1@gmail.com"); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := store.GetUserByEmail(context.Background(), "jankowalski@gmail.com"); err != nil {
+		t.Fatalf("expected account under canonical address: %v", err)
+	}
+	if w := register("jankowalski+2@googlemail.com"); w.Code == http.StatusCreated {
+		t.Fatal("expected alias of an existing account not to create a second one")
+	}
+}
+
+func TestFindUserByEmailFallsBackToLegacyAddress(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	legacy, err := store.CreateUser(context.Background(), "j.doe@gmail.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	server.emailPolicy = emailaddr.Policy{ProviderRules: true}
+
+	user, err := server.findUserByEmail(context.Background(), "J.Doe@Gmail.com")
+	if err != nil || user.ID != legacy.ID {
+		t.Fatalf("findUserByEmail() = %+v, %v; want legacy account %d", user, err, legacy.ID)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestEmailPreviewRendersSampleData(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.verificationBaseURL = "https://kuppixel.pl"
+
+	preview := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleEmailPreview(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	w := preview("/api/admin/email-preview?template=verification&lang=en")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Subject string `json:"subject"`
+		Text    string `json:"text"`
+		HTML    string `json:"html"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Subject != "Confirm your email address" || !strings.Contains(resp.Text, "https://kuppixel.pl/verify?token="+emailPreviewToken) || !strings.Contains(resp.HTML, "Confirm account") {
+		t.Fatalf("unexpected preview %+v", resp)
+	}
+
+	w = preview("/api/admin/email-preview?template=password_reset&format=html")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") || !strings.Contains(w.Body.String(), "Ustaw nowe hasło") {
+		t.Fatalf("unexpected html preview %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	for _, target := range []string{
+		"/api/admin/email-preview?template=welcome",
+		"/api/admin/email-preview?lang=de",
+		"/api/admin/email-preview?format=pdf",
+	} {
+		if w := preview(target); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestEmailPreviewRequiresAdmin(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	w := httptest.NewRecorder()
+	server.handleEmailPreview(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/admin/email-preview", nil)})
+	if w.Code != http.StatusUnauthorized && w.Code != http.StatusForbidden {
+		t.Fatalf("expected unauthorized, got %d", w.Code)
+	}
+}
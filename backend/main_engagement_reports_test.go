@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestEngagementReportsAreSentToSubscribedOwners(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	server.engagementReports = config.EngagementReports{Enabled: true, IntervalDays: 7}
+	mailer := &noticeMailer{}
+	server.mailer = mailer
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	neighbour, err := store.CreateUser(ctx, "jan@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for id, owner := range map[int]int64{1: admin.ID, 2: admin.ID, 3: neighbour.ID} {
+		if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: id, Status: "taken", Color: "#ff0000", URL: "https://example.com", OwnerID: &owner}); err != nil {
+			t.Fatalf("take pixel %d: %v", id, err)
+		}
+	}
+	if err := store.RecordPixelClick(ctx, 1, admin.ID, "visitor", time.Now()); err != nil {
+		t.Fatalf("record click: %v", err)
+	}
+
+	call := func(handler func(*gin.Context), method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	if w := call(server.handlePutEngagementReports, http.MethodPut, "/api/account/reports", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a missing choice to be rejected, got %d", w.Code)
+	}
+	if w := call(server.handlePutEngagementReports, http.MethodPut, "/api/account/reports", `{"subscribed":true}`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected subscribe status %d: %s", w.Code, w.Body.String())
+	}
+
+	// A failed send leaves the report due, so the next run retries it.
+	mailer.fail = errors.New("smtp unavailable")
+	if err := server.sendEngagementReports(ctx); err != nil {
+		t.Fatalf("send reports: %v", err)
+	}
+	mailer.fail = nil
+	if err := server.sendEngagementReports(ctx); err != nil {
+		t.Fatalf("send reports: %v", err)
+	}
+	if len(mailer.notices) != 1 || mailer.recipients[0] != "admin@example.com" {
+		t.Fatalf("expected one report to the admin, got %v", mailer.recipients)
+	}
+	body := mailer.notices[0].Body
+	for _, want := range []string{"Twoje piksele: 2", "Kliknięcia w Twoje linki: 1", "Nowe piksele kupione obok Twoich: 1", "/api/reports/unsubscribe?token="} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("report %q lacks %q", body, want)
+		}
+	}
+	if err := server.sendEngagementReports(ctx); err != nil || len(mailer.notices) != 1 {
+		t.Fatalf("expected no second report within the interval, got %d (%v)", len(mailer.notices), err)
+	}
+
+	link, err := url.Parse(body[strings.Index(body, "http"):])
+	if err != nil {
+		t.Fatalf("parse unsubscribe link: %v", err)
+	}
+	unsubscribe := "/api/reports/unsubscribe?" + link.RawQuery
+	if w := call(server.handleUnsubscribeEngagementReports, http.MethodGet, unsubscribe, ""); w.Code != http.StatusOK {
+		t.Fatalf("unexpected unsubscribe status %d: %s", w.Code, w.Body.String())
+	}
+	if w := call(server.handleUnsubscribeEngagementReports, http.MethodGet, unsubscribe, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected a used link to be unknown, got %d", w.Code)
+	}
+	w := call(server.handleGetEngagementReports, http.MethodGet, "/api/account/reports", "")
+	var resp struct {
+		Subscribed bool `json:"subscribed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Subscribed {
+		t.Fatalf("expected the owner to be unsubscribed, got %s", w.Body.String())
+	}
+
+	server.engagementReports.Enabled = false
+	if w := call(server.handlePutEngagementReports, http.MethodPut, "/api/account/reports", `{"subscribed":true}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected disabled reports to answer 404, got %d", w.Code)
+	}
+}
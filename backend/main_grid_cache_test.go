@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// countingRenderer renders the number of calls so far and signals each completed render.
+func countingRenderer(calls *atomic.Int32, done chan<- struct{}) gridRenderer {
+	return func(context.Context) (func(io.Writer) error, error) {
+		n := calls.Add(1)
+		return func(w io.Writer) error {
+			_, err := io.WriteString(w, strconv.Itoa(int(n)))
+			if done != nil {
+				done <- struct{}{}
+			}
+			return err
+		}, nil
+	}
+}
+
+func TestGridCacheServesStaleWhileRevalidating(t *testing.T) {
+	cache := NewGridCache(2*time.Second, 30*time.Second)
+	now := time.Unix(1000, 0)
+	cache.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	done := make(chan struct{}, 4)
+	render := countingRenderer(&calls, done)
+	ctx := context.Background()
+
+	body, _, status, err := cache.lookup(ctx, "k", render)
+	<-done
+	if err != nil || string(body) != "1" || status != "MISS" {
+		t.Fatalf("first lookup = %q %s %v", body, status, err)
+	}
+	if body, _, status, _ = cache.lookup(ctx, "k", render); string(body) != "1" || status != "HIT" {
+		t.Fatalf("second lookup = %q %s", body, status)
+	}
+
+	cache.Invalidate()
+	if body, _, status, _ = cache.lookup(ctx, "k", render); string(body) != "1" || status != "STALE" {
+		t.Fatalf("lookup after invalidate = %q %s", body, status)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("background refresh did not run")
+	}
+	waitForRefresh(t, cache, "k")
+	if body, _, status, _ = cache.lookup(ctx, "k", render); string(body) != "2" || status != "HIT" {
+		t.Fatalf("lookup after refresh = %q %s", body, status)
+	}
+
+	now = now.Add(time.Minute)
+	body, _, status, _ = cache.lookup(ctx, "k", render)
+	<-done
+	if string(body) != "3" || status != "MISS" {
+		t.Fatalf("lookup past the stale window = %q %s", body, status)
+	}
+}
+
+func waitForRefresh(t *testing.T, cache *GridCache, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		cache.mu.Lock()
+		refreshing := cache.entries[key].refreshing
+		cache.mu.Unlock()
+		if !refreshing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("refresh did not finish")
+}
+
+func TestGridCacheReturnsRenderErrorOnMiss(t *testing.T) {
+	cache := NewGridCache(time.Second, time.Second)
+	failing := func(context.Context) (func(io.Writer) error, error) { return nil, errors.New("db down") }
+	if _, _, _, err := cache.lookup(context.Background(), "k", failing); err == nil {
+		t.Fatal("expected render error on miss")
+	}
+}
+
+func TestGetPixelsCachedWithETag(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.gridCache = NewGridCache(time.Minute, time.Minute)
+
+	first := getPixels(t, server, "/api/pixels")
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("unexpected first response %d %q", first.Code, first.Header().Get("X-Cache"))
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" || first.Header().Get("Cache-Control") != "public, max-age=60, stale-while-revalidate=60" {
+		t.Fatalf("missing cache headers: %v", first.Header())
+	}
+
+	if _, err := store.UpdatePixel(context.Background(), storage.Pixel{ID: 1, Status: "taken", Color: "#000000", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	second := getPixels(t, server, "/api/pixels")
+	if second.Header().Get("X-Cache") != "HIT" || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected cached body without invalidation, got %q", second.Header().Get("X-Cache"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+	req.Header.Set("If-None-Match", etag)
+	w := httptest.NewRecorder()
+	server.handleGetPixels(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	if other := getPixels(t, server, "/api/pixels?fields=id"); other.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("expected separate entry per variant, got %q", other.Header().Get("X-Cache"))
+	}
+}
+
+func TestGetPixelsVersionETagWithoutCache(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	counting := &countingStore{Store: store}
+	server.store = counting
+	server.gridVersion = NewGridVersion()
+
+	first := getPixels(t, server, "/api/pixels")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("unexpected first response %d %v", first.Code, first.Header())
+	}
+	if other := getPixels(t, server, "/api/pixels?fields=id"); other.Header().Get("ETag") == etag {
+		t.Fatal("variants must have distinct ETags")
+	}
+
+	revalidate := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+		req.Header.Set("If-None-Match", etag)
+		w := httptest.NewRecorder()
+		server.handleGetPixels(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	reads := counting.fullReads
+	if w := revalidate(); w.Code != http.StatusNotModified || w.Body.Len() != 0 || counting.fullReads != reads {
+		t.Fatalf("expected 304 without loading the grid, got %d after %d reads", w.Code, counting.fullReads-reads)
+	}
+
+	server.gridChanged(1)
+	if w := revalidate(); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected a fresh grid after a change, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
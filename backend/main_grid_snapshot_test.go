@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// countingStore counts full grid reads and delta reads.
+type countingStore struct {
+	storage.Store
+	fullReads  int
+	deltaReads int
+}
+
+func (s *countingStore) GetAllPixels(ctx context.Context) (storage.PixelState, error) {
+	s.fullReads++
+	return s.Store.GetAllPixels(ctx)
+}
+
+func (s *countingStore) GetPixelsModifiedSince(ctx context.Context, since time.Time) ([]storage.Pixel, error) {
+	s.deltaReads++
+	return s.Store.GetPixelsModifiedSince(ctx, since)
+}
+
+func TestGridSnapshotRestoresAndAppliesDeltas(t *testing.T) {
+	_, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	counting := &countingStore{Store: store}
+	path := filepath.Join(t.TempDir(), "grid.snapshot")
+
+	snapshot, err := LoadGridSnapshot(ctx, counting, path)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	if counting.fullReads != 1 {
+		t.Fatalf("without a file the grid is read once, got %d reads", counting.fullReads)
+	}
+	// Writes that bypass the server are only picked up by the periodic sync.
+	snapshot.syncEvery = 0
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	state, err := snapshot.State(ctx)
+	if err != nil {
+		t.Fatalf("snapshot state: %v", err)
+	}
+	if len(state.Pixels) != 3 || state.Pixels[1].Color != "#222222" {
+		t.Fatalf("snapshot did not apply the delta: %+v", state.Pixels)
+	}
+	state.Pixels[1].Color = "#ffffff"
+	if err := snapshot.Save(); err != nil {
+		t.Fatalf("save snapshot: %v", err)
+	}
+
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 3, Status: "taken", Color: "#333333", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	restored, err := LoadGridSnapshot(ctx, counting, path)
+	if err != nil {
+		t.Fatalf("restore snapshot: %v", err)
+	}
+	if counting.fullReads != 1 {
+		t.Fatalf("a saved snapshot must not read the whole grid, got %d reads", counting.fullReads)
+	}
+	state, err = restored.State(ctx)
+	if err != nil {
+		t.Fatalf("restored state: %v", err)
+	}
+	if state.Pixels[1].Color != "#222222" || state.Pixels[2].Color != "#333333" {
+		t.Fatalf("unexpected restored pixels %+v", state.Pixels)
+	}
+}
+
+func TestGridSnapshotFallsBackOnCorruptFile(t *testing.T) {
+	_, store, _ := newAdminTestServer(t)
+	path := filepath.Join(t.TempDir(), "grid.snapshot")
+	if err := writeGridSnapshot(path, gridSnapshotFile{SyncedAt: time.Now(), State: storage.PixelState{Width: 1, Height: 1}}); err != nil {
+		t.Fatalf("write snapshot: %v", err)
+	}
+	counting := &countingStore{Store: store}
+	snapshot, err := LoadGridSnapshot(context.Background(), counting, path)
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	if state, err := snapshot.State(context.Background()); err != nil || counting.fullReads != 1 || len(state.Pixels) != 3 {
+		t.Fatalf("expected a full read after a mismatched snapshot, got %d reads, err %v", counting.fullReads, err)
+	}
+}
+
+func TestGridSnapshotAppliesPurchasesWithoutQuery(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "GRID-MEMO-RYWR-ITE1", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "GRID-MEMO-RYWR-ITE1"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	counting := &countingStore{Store: store}
+	snapshot, err := LoadGridSnapshot(ctx, counting, "")
+	if err != nil {
+		t.Fatalf("load snapshot: %v", err)
+	}
+	snapshot.syncEvery = time.Hour
+	server.gridSnapshot = snapshot
+
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(`{"pixels":[{"id":2,"status":"taken","color":"#123456","url":"https://example.com"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = getPixels(t, server, "/api/pixels")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp storage.PixelState
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Pixels) != 3 || resp.Pixels[1].Color != "#123456" {
+		t.Fatalf("purchase missing from the grid: %+v", resp.Pixels)
+	}
+	if counting.fullReads != 1 || counting.deltaReads != 0 {
+		t.Fatalf("grid read hit the store: %d full reads, %d delta reads", counting.fullReads, counting.deltaReads)
+	}
+
+	server.gridChanged(2)
+	if _, err := snapshot.State(ctx); err != nil || counting.deltaReads != 1 {
+		t.Fatalf("a stale grid must sync on the next read, got %d delta reads, err %v", counting.deltaReads, err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatReportsHealthAndDatabaseFailures(t *testing.T) {
+	type ping struct {
+		path   string
+		report heartbeatReport
+	}
+	var pings []ping
+	monitor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report heartbeatReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode heartbeat: %v", err)
+		}
+		pings = append(pings, ping{path: r.URL.Path, report: report})
+	}))
+	defer monitor.Close()
+
+	server, store, _ := newAdminTestServer(t)
+	beat := server.heartbeat(monitor.Client(), monitor.URL+"/ping/abc", time.Now().Add(-time.Minute))
+
+	if err := beat(context.Background()); err != nil {
+		t.Fatalf("heartbeat error = %v", err)
+	}
+	if len(pings) != 1 || pings[0].path != "/ping/abc" || pings[0].report.Status != "ok" || pings[0].report.UptimeSeconds < 60 {
+		t.Fatalf("unexpected healthy ping %+v", pings)
+	}
+
+	_ = store.Close()
+	if err := beat(context.Background()); err == nil {
+		t.Fatal("expected error when the database is down")
+	}
+	if len(pings) != 2 || pings[1].path != "/ping/abc/fail" || pings[1].report.Status != "db_down" {
+		t.Fatalf("unexpected failure ping %+v", pings[1:])
+	}
+}
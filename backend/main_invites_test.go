@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestInviteOnlyRegistration(t *testing.T) {
+	server, store, adminSession := newAdminTestServer(t)
+	server.invitesRequired = true
+	server.invitesPerUser = 2
+	server.disableVerificationEmail = true
+	ctx := context.Background()
+
+	call := func(handler gin.HandlerFunc, method, target, session, body string, params ...gin.Param) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		}
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req, Params: params})
+		return w
+	}
+	register := func(email, code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": "strong-password", "turnstile_token": testTurnstileToken, "invite_code": code})
+		return call(server.handleRegister, http.MethodPost, "/api/register", "", string(body))
+	}
+
+	if w := register("first@example.com", ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invite_code") {
+		t.Fatalf("expected a missing invite code to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := register("first@example.com", "INV-NOPE-NOPE-NOPE"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invite_code_invalid") {
+		t.Fatalf("expected an unknown invite code to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := store.GetUserByEmail(ctx, "first@example.com"); err == nil {
+		t.Fatal("expected no account to be created without a valid invite")
+	}
+
+	w := call(server.handleCreateInviteBatch, http.MethodPost, "/api/admin/invites", adminSession, `{"batch_id": "beta-1", "count": 2}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create invite batch: unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var batch struct {
+		Codes []string `json:"codes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil || len(batch.Codes) != 2 || !strings.HasPrefix(batch.Codes[0], "INV-") {
+		t.Fatalf("unexpected batch %s (%v)", w.Body.String(), err)
+	}
+	if w := register("first@example.com", strings.ToLower(batch.Codes[0])); w.Code != http.StatusCreated {
+		t.Fatalf("expected the invited registration to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := register("second@example.com", batch.Codes[0]); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a used invite code to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	w = call(server.handleListInviteBatch, http.MethodGet, "/api/admin/invites/beta-1", adminSession, "", gin.Param{Key: "batch", Value: "beta-1"})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"used":1`) {
+		t.Fatalf("unexpected batch listing %d: %s", w.Code, w.Body.String())
+	}
+
+	// Users share their own invites once verified, up to the configured number.
+	if w := call(server.handleCreateInvite, http.MethodPost, "/api/account/invites", adminSession, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected an unverified account to get no invites, got %d: %s", w.Code, w.Body.String())
+	}
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if err := store.MarkUserVerified(ctx, admin.ID); err != nil {
+		t.Fatalf("verify admin: %v", err)
+	}
+	var shared []string
+	for i := 0; i < 2; i++ {
+		w := call(server.handleCreateInvite, http.MethodPost, "/api/account/invites", adminSession, "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create invite: unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var invite accountInvite
+		if err := json.Unmarshal(w.Body.Bytes(), &invite); err != nil {
+			t.Fatalf("decode invite: %v", err)
+		}
+		shared = append(shared, invite.Code)
+	}
+	if w := call(server.handleCreateInvite, http.MethodPost, "/api/account/invites", adminSession, ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected the invite limit to be enforced, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := register("friend@example.com", shared[1]); w.Code != http.StatusCreated {
+		t.Fatalf("expected a shared invite to admit a friend, got %d: %s", w.Code, w.Body.String())
+	}
+	w = call(server.handleListInvites, http.MethodGet, "/api/account/invites", adminSession, "")
+	var listing struct {
+		Codes     []accountInvite `json:"codes"`
+		Remaining int             `json:"remaining"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("decode invites: %v", err)
+	}
+	if len(listing.Codes) != 2 || listing.Remaining != 0 || listing.Codes[0].Used || !listing.Codes[1].Used || strings.Contains(w.Body.String(), "used_by") {
+		t.Fatalf("unexpected invite listing %s", w.Body.String())
+	}
+
+	server.invitesRequired = false
+	if w := call(server.handleListInvites, http.MethodGet, "/api/account/invites", adminSession, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected invites to be hidden while registration is open, got %d", w.Code)
+	}
+	if w := register("open@example.com", ""); w.Code != http.StatusCreated {
+		t.Fatalf("expected open registration without a code, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestIPBuckets(t *testing.T) {
+	buckets := ipBuckets{ipv4Bits: 32, ipv6Bits: 64}
+	for ip, want := range map[string]string{
+		"203.0.113.7":                "203.0.113.7",
+		"::ffff:203.0.113.7":         "203.0.113.7",
+		"2001:db8:1:2:aaaa::1":       "2001:db8:1:2::/64",
+		"2001:DB8:1:2:ffff:ffff::9":  "2001:db8:1:2::/64",
+		"fe80::1%eth0":               "fe80::/64",
+		"not-an-ip":                  "not-an-ip",
+		"2001:db8:1:3::1":            "2001:db8:1:3::/64",
+		"2001:0db8:0001:0002::abcd":  "2001:db8:1:2::/64",
+		"2001:db8:ffff:ffff:1:2:3:4": "2001:db8:ffff:ffff::/64",
+	} {
+		if got := buckets.bucket(ip); got != want {
+			t.Fatalf("bucket(%q) = %q, want %q", ip, got, want)
+		}
+	}
+	if got := (ipBuckets{ipv4Bits: 24}).bucket("203.0.113.7"); got != "203.0.113.0/24" {
+		t.Fatalf("unexpected /24 bucket %q", got)
+	}
+	if got := (ipBuckets{}).bucket("2001:db8::1"); got != "2001:db8::1" {
+		t.Fatalf("expected a zero prefix to keep the address, got %q", got)
+	}
+}
+
+func TestRegistrationLimitCountsIPv6Networks(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	server.ipBuckets = ipBuckets{ipv4Bits: 32, ipv6Bits: 64}
+	server.registrationLimiter = NewRegistrationLimiter(config.RegistrationLimits{PerIPPerDay: 2, PerDevicePerDay: 100, ChallengeAfter: 100})
+
+	register := func(n int, ip string) int {
+		body := fmt.Sprintf(`{"email":"v6bot%d@example.com","password":"secret","turnstile_token":"%s"}`, n, testTurnstileToken)
+		req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = fmt.Sprintf("[%s]:1234", ip)
+		req.AddCookie(&http.Cookie{Name: deviceCookieName, Value: fmt.Sprintf("device-%d", n)})
+		w := httptest.NewRecorder()
+		server.handleRegister(&gin.Context{Writer: w, Request: req})
+		return w.Code
+	}
+
+	// Rotating the interface identifier within one /64 does not reset the limit.
+	for n := 1; n <= 2; n++ {
+		if code := register(n, fmt.Sprintf("2001:db8:1:2::%x", n)); code != http.StatusCreated {
+			t.Fatalf("unexpected status %d for signup %d", code, n)
+		}
+	}
+	if code := register(3, "2001:db8:1:2::ffff"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the /64 to be limited, got %d", code)
+	}
+	if code := register(4, "2001:db8:1:3::1"); code != http.StatusCreated {
+		t.Fatalf("expected another /64 to be allowed, got %d", code)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestLeaderboard(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		ctx := context.Background()
+		for id := 4; id <= 8; id++ {
+			if err := store.InsertPixel(ctx, storage.Pixel{ID: id, Status: "free"}); err != nil {
+				t.Fatalf("insert pixel %d: %v", id, err)
+			}
+		}
+		server.leaderboardSize = 10
+		server.leaderboard = newLeaderboardCache(time.Minute)
+
+		_, manySession := buyPixelsForTest(t, server, store, "many@example.com", 1, 2, 3)
+		server.pixelCostPoints = 50
+		_, richSession := buyPixelsForTest(t, server, store, "rich@example.com", 4, 5)
+
+		get := func(target, session string) []leaderboardEntry {
+			t.Helper()
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if session != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+			}
+			w := httptest.NewRecorder()
+			server.handleLeaderboard(&gin.Context{Writer: w, Request: req})
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: unexpected status %d: %s", target, w.Code, w.Body.String())
+			}
+			var body struct {
+				Owners []leaderboardEntry `json:"owners"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode leaderboard: %v", err)
+			}
+			return body.Owners
+		}
+
+		byPixels := get("/api/leaderboard", manySession)
+		if len(byPixels) != 2 || byPixels[0] != (leaderboardEntry{Rank: 1, Pixels: 3, PointsSpent: 30, Mine: true}) || byPixels[1].Pixels != 2 || byPixels[1].Mine {
+			t.Fatalf("unexpected leaderboard by pixels %+v", byPixels)
+		}
+		byPoints := get("/api/leaderboard?by=points", "")
+		if len(byPoints) != 2 || byPoints[0].PointsSpent != 100 || byPoints[0].Mine || byPoints[1].PointsSpent != 30 {
+			t.Fatalf("unexpected leaderboard by points %+v", byPoints)
+		}
+
+		// Rankings are served from the cache until an opt-out drops it.
+		buyPixelsForTest(t, server, store, "late@example.com", 6, 7, 8)
+		if cached := get("/api/leaderboard", ""); len(cached) != 2 {
+			t.Fatalf("expected the cached leaderboard, got %+v", cached)
+		}
+		req := httptest.NewRequest(http.MethodPut, "/api/account/leaderboard", bytes.NewReader([]byte(`{"opt_out": true}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: richSession})
+		w := httptest.NewRecorder()
+		server.handlePutLeaderboardOptOut(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusOK {
+			t.Fatalf("opt out: unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		req = httptest.NewRequest(http.MethodGet, "/api/account/leaderboard", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: richSession})
+		w = httptest.NewRecorder()
+		server.handleGetLeaderboardOptOut(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"opt_out":true}` {
+			t.Fatalf("unexpected opt-out settings %d: %s", w.Code, w.Body.String())
+		}
+		byPoints = get("/api/leaderboard?by=points", richSession)
+		if len(byPoints) != 2 || byPoints[0].PointsSpent != 150 || byPoints[1].PointsSpent != 30 {
+			t.Fatalf("expected the opted-out owner to be left out, got %+v", byPoints)
+		}
+		for _, entry := range byPoints {
+			if entry.Mine {
+				t.Fatalf("opted-out viewer found on the leaderboard %+v", byPoints)
+			}
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/api/leaderboard?by=clicks", nil)
+		w = httptest.NewRecorder()
+		server.handleLeaderboard(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected an unknown order to be rejected, got %d", w.Code)
+		}
+	})
+}
+
+func TestLeaderboardDisabled(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	w := httptest.NewRecorder()
+	server.handleLeaderboard(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/leaderboard", nil)})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while disabled, got %d", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/account/leaderboard", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleGetLeaderboardOptOut(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 while disabled, got %d", w.Code)
+	}
+}
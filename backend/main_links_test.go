@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestBuildTokenLinks(t *testing.T) {
+	cases := []struct {
+		name     string
+		build    func(string, config.LinkTemplate, string, string) (string, error)
+		base     string
+		link     config.LinkTemplate
+		token    string
+		language string
+		want     string
+	}{
+		{"default verification", buildVerificationLink, "https://kuppixel.pl/", config.LinkTemplate{}, "a b", "", "https://kuppixel.pl/verify?token=a+b"},
+		{"default reset", buildPasswordResetLink, "", config.LinkTemplate{}, "abc", "pl", defaultVerificationBaseURL + "/reset-password?token=abc"},
+		{
+			"path token",
+			buildVerificationLink,
+			"https://kuppixel.pl",
+			config.LinkTemplate{Path: "/{language}/auth/verify/{token}", Query: map[string]string{"lang": "{language}", "utm_campaign": "welcome"}},
+			"a/b",
+			"en",
+			"https://kuppixel.pl/en/auth/verify/a%2Fb?lang=en&utm_campaign=welcome",
+		},
+		{
+			"query token with extras",
+			buildPasswordResetLink,
+			"https://kuppixel.pl",
+			config.LinkTemplate{Path: "/reset?t={token}&src=mail", Query: map[string]string{"campaign": "spring sale"}},
+			"abc",
+			"",
+			"https://kuppixel.pl/reset?campaign=spring+sale&src=mail&t=abc",
+		},
+	}
+	for _, tc := range cases {
+		got, err := tc.build(tc.base, tc.link, tc.token, tc.language)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
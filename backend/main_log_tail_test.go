@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestLogRingKeepsRecentLines(t *testing.T) {
+	ring := NewLogRing(3)
+	fmt.Fprint(ring, "grid snapshot: loaded\nturnstile verification failed: codes=[x]\nindex check: missing")
+	if got := ring.Recent("info", 10); len(got) != 2 {
+		t.Fatalf("a partial line must wait for its newline, got %+v", got)
+	}
+	fmt.Fprint(ring, " indexes idx_pixels_owner\nserver started\n")
+
+	got := ring.Recent("info", 10)
+	if len(got) != 3 || got[0].Seq != 2 || got[1].Message != "index check: missing indexes idx_pixels_owner" || got[2].Message != "server started" {
+		t.Fatalf("unexpected entries %+v", got)
+	}
+	if warn := ring.Recent("warn", 10); len(warn) != 2 || warn[0].Level != "error" || warn[1].Level != "warn" {
+		t.Fatalf("unexpected warn entries %+v", warn)
+	}
+	if newest := ring.Recent("info", 1); len(newest) != 1 || newest[0].Seq != 4 {
+		t.Fatalf("expected only the newest entry, got %+v", newest)
+	}
+}
+
+func TestAdminLogStream(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.logRing = NewLogRing(logRingCapacity)
+	fmt.Fprintln(server.logRing, "payment: completed id=1")
+	fmt.Fprintln(server.logRing, "cloudflare purge failed: timeout")
+
+	stream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handleAdminLogStream(&gin.Context{Writer: w, Request: r})
+	}))
+	defer stream.Close()
+
+	get := func(session string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, stream.URL+"?level=error", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("open stream: %v", err)
+		}
+		return resp
+	}
+	if resp := get("unknown"); resp.StatusCode != http.StatusUnauthorized {
+		resp.Body.Close()
+		t.Fatalf("expected 401 without an admin session, got %d", resp.StatusCode)
+	}
+
+	resp := get(sessionID)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+	next := func() logEntry {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read stream: %v", err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "data: "); ok {
+				var entry logEntry
+				if err := json.Unmarshal([]byte(data), &entry); err != nil {
+					t.Fatalf("decode %q: %v", data, err)
+				}
+				return entry
+			}
+		}
+	}
+	if entry := next(); entry.Message != "cloudflare purge failed: timeout" {
+		t.Fatalf("expected only the error backlog, got %+v", entry)
+	}
+
+	// The backlog is read after subscribing, so lines logged now are streamed live.
+	fmt.Fprintln(server.logRing, "payment: completed id=2")
+	fmt.Fprintln(server.logRing, "turnstile verification error: tx=abc err=timeout")
+	if entry := next(); entry.Level != "error" || entry.Message != "turnstile verification error: tx=abc err=timeout" {
+		t.Fatalf("unexpected live entry %+v", entry)
+	}
+}
+
+func TestAdminErrorsListsFailedRequests(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.logRing = NewLogRing(2)
+	log.SetOutput(server.logRing)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	router := gin.Default()
+	router.Use(server.transactionIDMiddleware)
+	router.GET("/api/fail", func(c *gin.Context) {
+		log.Printf("load pixels failed: %v", errors.New("database is locked"))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+	})
+	router.GET("/api/admin/errors", server.handleAdminErrors)
+
+	const id = "5f0c9a7e-3b1d-4c52-9e0a-2d7f1b6c8e43"
+	req := httptest.NewRequest(http.MethodGet, "/api/fail", nil)
+	req.Header.Set(transactionIDHeader, id)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	// Routine lines push the failure out of the main ring but not out of the error ring.
+	fmt.Fprintln(server.logRing, "payment: completed id=1")
+	fmt.Fprintln(server.logRing, "payment: completed id=2")
+
+	list := func(query string) []logEntry {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/errors"+query, nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Errors []logEntry `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return resp.Errors
+	}
+	got := list("")
+	if len(got) != 2 || got[0].TransactionID != id || !strings.Contains(got[0].Message, "request failed: tx="+id+" method=GET path=/api/fail status=500") || !strings.HasSuffix(got[1].Message, "load pixels failed: database is locked") {
+		t.Fatalf("unexpected errors %+v", got)
+	}
+	if got := list("?tx=" + id); len(got) != 1 || got[0].TransactionID != id {
+		t.Fatalf("expected only the failed request, got %+v", got)
+	}
+	if got := list("?tx=0000000000000000"); len(got) != 0 {
+		t.Fatalf("expected no errors for another transaction, got %+v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestLoginLimitLocksOutIPAndChallengesAccount(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	server.loginLimiter = NewLoginLimiter(config.LoginLimits{MaxFailures: 3, LockoutMinutes: 15})
+	now := time.Now()
+	server.loginLimiter.now = func() time.Time { return now }
+	server.turnstileVerify = func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		if token == "challenge-token" {
+			return turnstileResponse{Success: true, Action: loginChallengeAction}, nil
+		}
+		return turnstileResponse{Success: true}, nil
+	}
+	if _, err := store.CreateUser(context.Background(), "user@example.com", testLoginPasswordHash); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	login := func(ip, password, token string) *httptest.ResponseRecorder {
+		body := `{"email":"user@example.com","password":"` + password + `","turnstile_token":"` + token + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		server.handleLogin(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	type limits struct {
+		Throttled         bool   `json:"throttled"`
+		RetryAfterSeconds int    `json:"retry_after_seconds"`
+		Scope             string `json:"scope"`
+		Captcha           string `json:"captcha"`
+		AttemptsLeft      *int   `json:"attempts_left"`
+	}
+	check := func(ip, target string) limits {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		server.handleAuthLimits(&gin.Context{Writer: w, Request: req})
+		var got limits
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &got) != nil || got.AttemptsLeft == nil {
+			t.Fatalf("unexpected limits response %d: %s", w.Code, w.Body.String())
+		}
+		return got
+	}
+
+	if got := check("203.0.113.7", "/api/auth/limits?email=user@example.com"); got.Throttled || *got.AttemptsLeft != 3 {
+		t.Fatalf("expected a fresh source to be allowed, got %+v", got)
+	}
+	for _, ip := range []string{"203.0.113.7", "203.0.113.7", "203.0.113.7"} {
+		if w := login(ip, "wrong", testTurnstileToken); w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected a wrong password to be refused, got %d", w.Code)
+		}
+	}
+	w := login("203.0.113.7", testLoginPassword, "challenge-token")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected the guessing IP to be locked out, got %d %s", w.Code, w.Body.String())
+	}
+	got := check("203.0.113.7", "/api/auth/limits")
+	if !got.Throttled || got.Scope != "ip" || got.RetryAfterSeconds <= 0 || got.RetryAfterSeconds > 15*60 || *got.AttemptsLeft != 0 {
+		t.Fatalf("expected the IP lockout to be reported, got %+v", got)
+	}
+
+	// The owner, from another source, is asked for a challenge rather than locked out.
+	got = check("192.0.2.1", "/api/auth/limits?email=USER@example.com")
+	if got.Throttled || got.Captcha != "challenge" || *got.AttemptsLeft != 0 {
+		t.Fatalf("expected the account to ask for a challenge, got %+v", got)
+	}
+	if w := login("192.0.2.1", testLoginPassword, testTurnstileToken); w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"captcha":"challenge"`)) {
+		t.Fatalf("expected the account to require a challenge, got %d %s", w.Code, w.Body.String())
+	}
+	if w := login("192.0.2.1", testLoginPassword, "challenge-token"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the owner to sign in after the challenge, got %d %s", w.Code, w.Body.String())
+	}
+	if got := check("192.0.2.1", "/api/auth/limits?email=user@example.com"); got.Throttled || got.Captcha != "" || *got.AttemptsLeft != 3 {
+		t.Fatalf("expected the sign-in to reset the account, got %+v", got)
+	}
+
+	now = now.Add(15*time.Minute + time.Second)
+	if w := login("203.0.113.7", testLoginPassword, testTurnstileToken); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the lockout to end with the window, got %d %s", w.Code, w.Body.String())
+	}
+}
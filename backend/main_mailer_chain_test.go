@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/metrics"
+)
+
+type failingMailer struct{ fakeMailer }
+
+func (f *failingMailer) SendVerificationEmail(ctx context.Context, recipient, link string) error {
+	return errors.New("smtp: connection refused")
+}
+
+func TestBuildMailerFailoverChainCountsDeliveries(t *testing.T) {
+	registry := metrics.NewRegistry()
+	fallback := &fakeMailer{}
+	mailer := buildMailer([]string{"smtp", "mailgun", "console"}, map[string]email.Mailer{
+		"smtp":    &failingMailer{},
+		"mailgun": fallback,
+		"console": email.NewConsoleMailer("Kup Piksel", "pl"),
+	}, registry)
+
+	if err := mailer.SendVerificationEmail(context.Background(), "user@example.com", "https://kuppixel.pl/verify?token=x"); err != nil {
+		t.Fatalf("SendVerificationEmail() error = %v", err)
+	}
+	if fallback.sent != 1 || fallback.lastRecipient != "user@example.com" {
+		t.Fatalf("expected fallback transport to deliver, got %+v", fallback)
+	}
+
+	var buf bytes.Buffer
+	if err := registry.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	for _, want := range []string{
+		`kuppixel_email_deliveries_total{kind="verification",transport="smtp",result="failed"} 1`,
+		`kuppixel_email_deliveries_total{kind="verification",transport="mailgun",result="delivered"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestBuildMailerDefaults(t *testing.T) {
+	console := email.NewConsoleMailer("Kup Piksel", "pl")
+	smtp := &fakeMailer{}
+	if got := buildMailer(nil, map[string]email.Mailer{"console": console}, nil); got != console {
+		t.Fatalf("expected console mailer without smtp, got %T", got)
+	}
+	if got := buildMailer(nil, map[string]email.Mailer{"console": console, "smtp": smtp}, nil); got != smtp {
+		t.Fatalf("expected smtp mailer by default, got %T", got)
+	}
+	if got := buildMailer([]string{"mailgun", "console"}, map[string]email.Mailer{"console": console}, nil); got != console {
+		t.Fatalf("unavailable transports should be skipped, got %T", got)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func scheduleMaintenance(t *testing.T, server *Server, sessionID string, start, end time.Time) maintenanceWindow {
+	t.Helper()
+	body, _ := json.Marshal(maintenanceWindowRequest{StartsAt: start, EndsAt: end, Message: "Aktualizacja bazy danych"})
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/maintenance", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleCreateMaintenanceWindow(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Window maintenanceWindow `json:"window"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode window: %v", err)
+	}
+	return resp.Window
+}
+
+func TestMaintenanceWindowBlocksPurchases(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	now := time.Now()
+
+	upcoming := scheduleMaintenance(t, server, sessionID, now.Add(24*time.Hour), now.Add(25*time.Hour))
+
+	w := httptest.NewRecorder()
+	server.handleConfig(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/config", nil)})
+	var cfg struct {
+		Maintenance struct {
+			Active   *maintenanceWindow `json:"active"`
+			Upcoming *maintenanceWindow `json:"upcoming"`
+		} `json:"maintenance"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if cfg.Maintenance.Active != nil || cfg.Maintenance.Upcoming == nil || cfg.Maintenance.Upcoming.ID != upcoming.ID {
+		t.Fatalf("unexpected maintenance status %+v", cfg.Maintenance)
+	}
+
+	buy := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(`{"pixels":[{"id":1,"status":"taken","color":"#123456","url":"https://example.com"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	if w := buy(); w.Code == http.StatusServiceUnavailable {
+		t.Fatalf("did not expect purchases to be blocked before the window starts")
+	}
+
+	active := scheduleMaintenance(t, server, sessionID, now.Add(-time.Minute), now.Add(time.Hour))
+	w = buy()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 during maintenance, got %d", w.Code)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["code"] != "maintenance" || resp["error"] != "Aktualizacja bazy danych" {
+		t.Fatalf("unexpected maintenance response %v", resp)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/maintenance/"+active.ID, nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleDeleteMaintenanceWindow(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: active.ID}}})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", w.Code)
+	}
+	if active, _ := server.maintenanceStatus(context.Background()); active != nil {
+		t.Fatalf("expected no active maintenance after deletion, got %+v", active)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/kup-piksel/internal/metrics"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestSlowQueriesAreLoggedRedactedAndCounted(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.metrics = metrics.NewRegistry()
+
+	if _, err := store.CreateUser(context.Background(), "secret.person@example.com", "hash"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	store.SetSlowQueryHook(time.Nanosecond, slowQueryHook(server.metrics, "sqlite"))
+	if _, err := store.GetUserByEmail(context.Background(), "secret.person@example.com"); err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	store.SetSlowQueryHook(0, nil)
+
+	if !strings.Contains(logs.String(), "slow query: backend=sqlite") {
+		t.Fatalf("expected slow query log, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "secret.person") {
+		t.Fatalf("slow query log leaked parameters: %q", logs.String())
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	server.serveMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `kuppixel_db_slow_queries_total{backend="sqlite"} `) {
+		t.Fatalf("slow query counter missing: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), `kuppixel_db_slow_queries_total{backend="sqlite"} 0`) {
+		t.Fatalf("slow query counter not incremented: %s", w.Body.String())
+	}
+}
+
+func TestBusinessMetricsAreRefreshedFromTheStore(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.metrics = metrics.NewRegistry()
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.MarkUserVerified(ctx, admin.ID); err != nil {
+		t.Fatalf("verify admin: %v", err)
+	}
+	if _, err := store.CreateUser(ctx, "jan@example.com", "hash"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "BUSI-NESS-METR-ICS1", 100); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "BUSI-NESS-METR-ICS1"); err != nil {
+		t.Fatalf("redeem code: %v", err)
+	}
+	if _, _, err := store.UpdatePixelForUserWithCost(ctx, admin.ID, storage.Pixel{ID: 1, Status: "taken", Color: "#ff0000", URL: "https://example.com"}, 10); err != nil {
+		t.Fatalf("buy pixel: %v", err)
+	}
+
+	if err := server.refreshBusinessMetrics(ctx); err != nil {
+		t.Fatalf("refresh business metrics: %v", err)
+	}
+	w := httptest.NewRecorder()
+	server.serveMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		"# TYPE kuppixel_pixels_taken_total gauge\nkuppixel_pixels_taken_total 1\n",
+		"kuppixel_pixels_rented_total 0\n",
+		"kuppixel_users_total 2\n",
+		"kuppixel_users_verified_total 1\n",
+		"kuppixel_points_outstanding 90\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("metrics lack %q:\n%s", want, w.Body.String())
+		}
+	}
+}
+
+func TestMetricsListenerServesEachBoard(t *testing.T) {
+	primary, _, _ := newAdminTestServer(t)
+	primary.metrics = metrics.NewRegistry()
+	primary.metrics.Gauge("kuppixel_users_total", "Registered accounts.").Set(2)
+	tenant, _, _ := newAdminTestServer(t)
+	tenant.metrics = metrics.NewRegistry()
+	tenant.metrics.Gauge("kuppixel_users_total", "Registered accounts.").Set(7)
+	handler := metricsHandler(primary, map[string]*Server{"kids": tenant})
+
+	for path, want := range map[string]string{"/metrics": "kuppixel_users_total 2\n", "/metrics/kids": "kuppixel_users_total 7\n"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("GET %s = %d, lacks %q:\n%s", path, w.Code, want, w.Body.String())
+		}
+	}
+}
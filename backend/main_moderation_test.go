@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestModerationHoldsPixelsUntilApproved(t *testing.T) {
+	server, store, adminSession := newAdminTestServer(t)
+	server.moderation = config.Moderation{Enabled: true}
+	mailer := &noticeMailer{}
+	server.mailer = mailer
+	ctx := context.Background()
+	buyer, err := store.CreateUser(ctx, "buyer@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create buyer: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "MODE-RATE-MODE-RATE", 50); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, buyer.ID, "MODE-RATE-MODE-RATE"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	buyerSession, err := server.sessions.Create(buyer.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	do := func(handler gin.HandlerFunc, session, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/test", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	pixelAt := func(id int) storage.Pixel {
+		w := do(server.handleGetPixels, "", http.MethodGet, "")
+		var state storage.PixelState
+		if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+			t.Fatalf("decode grid: %v", err)
+		}
+		for _, pixel := range state.Pixels {
+			if pixel.ID == id {
+				return pixel
+			}
+		}
+		t.Fatalf("pixel %d missing from grid", id)
+		return storage.Pixel{}
+	}
+	buy := func(id int, url string) *httptest.ResponseRecorder {
+		body := `{"pixels":[{"id":` + strconv.Itoa(id) + `,"status":"taken","color":"#ff0000","url":"` + url + `"}]}`
+		return do(server.handleUpdatePixel, buyerSession, http.MethodPost, body)
+	}
+
+	w := buy(1, "https://example.com")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"pending_review":[1]`) {
+		t.Fatalf("expected the purchase to be queued for review, got %d %s", w.Code, w.Body.String())
+	}
+	if pixel := pixelAt(1); pixel.Status != "free" || pixel.URL != "" {
+		t.Fatalf("a pixel waiting for review must look free, got %+v", pixel)
+	}
+	w = do(server.handleAccount, buyerSession, http.MethodGet, "")
+	if !strings.Contains(w.Body.String(), `"status":"pending_review"`) {
+		t.Fatalf("expected the owner to see the pending status, got %s", w.Body.String())
+	}
+	if w := do(server.handleListModeration, buyerSession, http.MethodGet, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the queue to be admin-only, got %d", w.Code)
+	}
+	w = do(server.handleListModeration, adminSession, http.MethodGet, "")
+	var queue struct {
+		Pixels []storage.PixelReview `json:"pixels"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &queue); err != nil || len(queue.Pixels) != 1 || queue.Pixels[0].PixelID != 1 || queue.Pixels[0].URL != "https://example.com" {
+		t.Fatalf("expected pixel 1 in the queue, got %s", w.Body.String())
+	}
+
+	if w := do(server.handleApproveModeration, adminSession, http.MethodPost, `{"pixel_ids":[1]}`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected approve status %d: %s", w.Code, w.Body.String())
+	}
+	if pixel := pixelAt(1); pixel.Status != "taken" || pixel.URL != "https://example.com" {
+		t.Fatalf("an approved pixel must be shown, got %+v", pixel)
+	}
+	if w := do(server.handleApproveModeration, adminSession, http.MethodPost, `{"pixel_ids":[1]}`); w.Code != http.StatusConflict {
+		t.Fatalf("expected a second approval to conflict, got %d", w.Code)
+	}
+	if w := buy(1, "https://example.com"); strings.Contains(w.Body.String(), "pending_review") {
+		t.Fatalf("repainting with the same link must not need a review, got %s", w.Body.String())
+	}
+
+	if w := buy(2, "https://spam.example"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(server.handleRejectModeration, adminSession, http.MethodPost, `{"pixel_ids":[2]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a rejection without a reason to be refused, got %d", w.Code)
+	}
+	if w := do(server.handleRejectModeration, adminSession, http.MethodPost, `{"pixel_ids":[2],"reason":"Spam"}`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected reject status %d: %s", w.Code, w.Body.String())
+	}
+	owned, err := store.GetPixelsByOwner(ctx, buyer.ID)
+	if err != nil || len(owned) != 1 || owned[0].ID != 1 {
+		t.Fatalf("expected the rejected pixel to be freed, got %+v, %v", owned, err)
+	}
+	if refreshed, err := store.GetUserByID(ctx, buyer.ID); err != nil || refreshed.Points != 40 {
+		t.Fatalf("expected the rejected pixel to be refunded, got %+v, %v", refreshed, err)
+	}
+	if len(mailer.notices) != 2 || mailer.recipients[1] != "buyer@example.com" || !strings.Contains(mailer.notices[1].Body, "Spam") {
+		t.Fatalf("expected the owner to be told both decisions, got %+v", mailer.notices)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/webhook"
+)
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func TestOwnerWebhookReceivesSignedClicks(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server.ownerWebhooks = webhook.NewDispatcher(webhook.Config{BaseDelay: time.Millisecond, AllowPrivateTargets: true})
+	go server.ownerWebhooks.Run(ctx)
+
+	received := make(chan receivedWebhook, 4)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedWebhook{header: r.Header.Clone(), body: body}
+	}))
+	defer endpoint.Close()
+
+	call := func(method, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/account/webhook", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	if w := call(http.MethodPut, `{"url":"ftp://example.com/hook"}`, server.handlePutOwnerWebhook); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a non-http url to be rejected, got %d", w.Code)
+	}
+	w := call(http.MethodPut, `{"url":"`+endpoint.URL+`"}`, server.handlePutOwnerWebhook)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || len(created.Secret) != 64 {
+		t.Fatalf("expected the new secret in the response, got %s (err %v)", w.Body.String(), err)
+	}
+	if w := call(http.MethodGet, "", server.handleGetOwnerWebhook); w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) {
+		t.Fatalf("the secret must not be shown again, got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPut, `{"url":"`+endpoint.URL+`/v2"}`, server.handlePutOwnerWebhook); w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"secret"`) {
+		t.Fatalf("changing the url must keep the secret, got %d %s", w.Code, w.Body.String())
+	}
+
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	redirect := httptest.NewRecorder()
+	server.handlePixelRedirect(&gin.Context{Writer: redirect, Request: httptest.NewRequest(http.MethodGet, "/go/2", nil), Params: gin.Params{{Key: "id", Value: "2"}}})
+	if redirect.Code != http.StatusFound {
+		t.Fatalf("expected a redirect, got %d", redirect.Code)
+	}
+
+	select {
+	case got := <-received:
+		var event struct {
+			Event string `json:"event"`
+			Data  struct {
+				PixelID int `json:"pixel_id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(got.body, &event); err != nil || event.Event != ownerEventPixelClicked || event.Data.PixelID != 2 {
+			t.Fatalf("unexpected delivery %s (err %v)", got.body, err)
+		}
+		signature := got.header.Get(webhook.SignatureHeader)
+		sent, err := strconv.ParseInt(strings.TrimPrefix(strings.SplitN(signature, ",", 2)[0], "t="), 10, 64)
+		if err != nil || signature != webhook.Sign(created.Secret, time.Unix(sent, 0), got.body) {
+			t.Fatalf("delivery is not signed with the owner's secret: %q", signature)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	if w := call(http.MethodPost, "", server.handleRotateOwnerWebhookSecret); w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) {
+		t.Fatalf("expected a new secret, got %d %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodDelete, "", server.handleDeleteOwnerWebhook); w.Code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+	if w := call(http.MethodGet, "", server.handleGetOwnerWebhook); w.Code != http.StatusNotFound {
+		t.Fatalf("expected the webhook to be gone, got %d", w.Code)
+	}
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	gin "github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/example/kup-piksel/internal/storage"
 	"github.com/example/kup-piksel/internal/storage/sqlite"
 )
 
@@ -137,3 +141,143 @@ func TestPasswordResetFlow(t *testing.T) {
 		t.Fatalf("expected reset token to be removed")
 	}
 }
+
+func TestStoredTokensAreHashed(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.db")
+	store, err := sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	store.SetSkipPixelSeed(true)
+	if err := store.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	server := &Server{store: store, passwordResetTokenTTL: time.Hour, passwordResetTokenBytes: 48}
+	token, err := server.issuePasswordResetToken(ctx, user)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if len(token) != 96 {
+		t.Fatalf("expected a 48-byte hex token, got %q", token)
+	}
+	_ = store.Close()
+
+	// A row written before tokens were hashed, as the ALTER TABLE of an upgrade leaves it.
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw sqlite: %v", err)
+	}
+	defer db.Close()
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	if _, err := db.Exec(fmt.Sprintf(`INSERT INTO password_reset_tokens(token, user_id, expires_at, hashed) VALUES ('legacy-token', %d, '%s', 0)`, user.ID, expires)); err != nil {
+		t.Fatalf("insert legacy token: %v", err)
+	}
+
+	store, err = sqlite.Open(path)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	store.SetSkipPixelSeed(true)
+	if err := store.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	for _, sent := range []string{token, "legacy-token"} {
+		if record, err := store.GetPasswordResetToken(ctx, sent); err != nil || record.UserID != user.ID {
+			t.Fatalf("expected token %q to resolve to the user, got %+v (err %v)", sent, record, err)
+		}
+	}
+	var plaintext int
+	if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM password_reset_tokens WHERE token IN ('%s', 'legacy-token')`, token)).Scan(&plaintext); err != nil || plaintext != 0 {
+		t.Fatalf("expected no plaintext tokens in the database, found %d (err %v)", plaintext, err)
+	}
+}
+
+func TestPasswordResetTokenIsUsedOnceUnderConcurrency(t *testing.T) {
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	store.SetSkipPixelSeed(true)
+	if err := store.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	user, err := store.CreateUser(context.Background(), "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	const attempts = 8
+	barrier := &tokenBarrierStore{Store: store}
+	barrier.arrived.Add(attempts)
+	server := &Server{store: barrier, sessions: NewSessionManager(), passwordResetTokenTTL: time.Hour}
+	enableTurnstileForTest(server)
+	token, err := server.issuePasswordResetToken(context.Background(), user)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	codes := make(chan int, attempts)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			password := fmt.Sprintf("new-secret-%d", i)
+			body := bytes.NewBufferString(fmt.Sprintf(`{"token":"%s","password":"%s","confirm_password":"%s","turnstile_token":"%s"}`, token, password, password, testTurnstileToken))
+			req := httptest.NewRequest(http.MethodPost, "/api/password-reset/confirm", body)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			<-start
+			server.handlePasswordResetConfirm(&gin.Context{Writer: w, Request: req})
+			codes <- w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(codes)
+
+	succeeded := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusBadRequest:
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one confirmation to succeed, got %d", succeeded)
+	}
+}
+
+// tokenBarrierStore holds every password reset token lookup until all expected callers have done
+// theirs, so concurrent confirmations all see the token before any of them uses it.
+type tokenBarrierStore struct {
+	storage.Store
+	arrived sync.WaitGroup
+}
+
+func (s *tokenBarrierStore) wait() {
+	s.arrived.Done()
+	s.arrived.Wait()
+}
+
+func (s *tokenBarrierStore) GetPasswordResetToken(ctx context.Context, token string) (storage.PasswordResetToken, error) {
+	record, err := s.Store.GetPasswordResetToken(ctx, token)
+	s.wait()
+	return record, err
+}
+
+func (s *tokenBarrierStore) ConsumePasswordResetToken(ctx context.Context, token string) (storage.PasswordResetToken, error) {
+	record, err := s.Store.ConsumePasswordResetToken(ctx, token)
+	s.wait()
+	return record, err
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const testPaymentSecret = "payment-secret"
+
+func signedWebhookRequest(t *testing.T, payload any) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal webhook: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(testPaymentSecret))
+	mac.Write(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(paymentSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestPaymentFlowInMultipleCurrencies(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.paymentWebhookSecret = testPaymentSecret
+	server.paymentBundles = []config.PaymentBundle{
+		{ID: "small", Points: 100, Prices: map[string]int64{"PLN": 1000, "EUR": 250, "USD": 275}},
+	}
+
+	user, err := store.CreateUser(context.Background(), "payer@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	server.handlePaymentBundles(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/payments/bundles?currency=eur", nil)})
+	var bundles struct {
+		Bundles []bundleResponse `json:"bundles"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &bundles); err != nil {
+		t.Fatalf("decode bundles: %v", err)
+	}
+	if len(bundles.Bundles) != 1 || len(bundles.Bundles[0].Prices) != 1 || bundles.Bundles[0].Prices[0].Formatted != "2,50 €" {
+		t.Fatalf("unexpected bundles %+v", bundles.Bundles)
+	}
+
+	var paymentIDs []string
+	for _, code := range []string{"EUR", "usd"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/payments", bytes.NewBufferString(`{"bundle_id":"small","currency":"`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleCreatePayment(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Payment storage.Payment `json:"payment"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode payment: %v", err)
+		}
+		if resp.Payment.Status != storage.PaymentStatusPending || resp.Payment.Points != 100 {
+			t.Fatalf("unexpected payment %+v", resp.Payment)
+		}
+		paymentIDs = append(paymentIDs, resp.Payment.ID)
+	}
+
+	// A notification for the wrong amount is rejected.
+	w = httptest.NewRecorder()
+	server.handlePaymentWebhook(&gin.Context{Writer: w, Request: signedWebhookRequest(t, paymentWebhookRequest{
+		PaymentID: paymentIDs[0], Status: "completed", Currency: "EUR", AmountMinor: 1,
+	})})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for amount mismatch, got %d", w.Code)
+	}
+
+	for i, code := range []string{"EUR", "USD"} {
+		amount := map[string]int64{"EUR": 250, "USD": 275}[code]
+		for attempt := 0; attempt < 2; attempt++ {
+			w = httptest.NewRecorder()
+			server.handlePaymentWebhook(&gin.Context{Writer: w, Request: signedWebhookRequest(t, paymentWebhookRequest{
+				PaymentID: paymentIDs[i], Status: "completed", ProviderRef: "ref-" + code, Currency: code, AmountMinor: amount,
+			})})
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+		}
+	}
+
+	updated, err := store.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if updated.Points != 200 {
+		t.Fatalf("expected 200 points after two bundles (retries credited once), got %d", updated.Points)
+	}
+
+	payments, err := store.ListPaymentsByUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("list payments: %v", err)
+	}
+	if len(payments) != 2 {
+		t.Fatalf("expected 2 payments, got %d", len(payments))
+	}
+	for _, payment := range payments {
+		if payment.Status != storage.PaymentStatusCompleted || payment.CompletedAt == nil {
+			t.Fatalf("expected completed payment, got %+v", payment)
+		}
+	}
+}
+
+func TestPaymentWebhookRejectsBadSignature(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.paymentWebhookSecret = testPaymentSecret
+
+	req := httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewBufferString(`{"payment_id":"x","status":"completed"}`))
+	req.Header.Set(paymentSignatureHeader, "deadbeef")
+	w := httptest.NewRecorder()
+	server.handlePaymentWebhook(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+}
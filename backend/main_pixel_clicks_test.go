@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelRedirectCountsClicksForOwner(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com/shop?a=1", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 3, Status: "taken", Color: "#333333", URL: "javascript:alert(1)", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	redirect := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := &gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/go/"+id, nil), Params: gin.Params{{Key: "id", Value: id}}}
+		server.handlePixelRedirect(c)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		w := redirect("2")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/shop?a=1" {
+			t.Fatalf("expected a redirect to the pixel url, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("redirect must not be cached, got %q", w.Header().Get("Cache-Control"))
+		}
+	}
+	for _, id := range []string{"1", "3", "abc", "-1", "1000000"} {
+		if w := redirect(id); w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for pixel %s, got %d", id, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/account/clicks?days=7", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleAccountClicks(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Days   int                   `json:"days"`
+		Clicks []storage.PixelClicks `json:"clicks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode clicks: %v", err)
+	}
+	if resp.Days != 7 || len(resp.Clicks) != 1 || resp.Clicks[0] != (storage.PixelClicks{PixelID: 2, Clicks: 2}) {
+		t.Fatalf("expected two clicks on pixel 2, got %+v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/account/clicks?days=0", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w = httptest.NewRecorder()
+	server.handleAccountClicks(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for days=0, got %d", w.Code)
+	}
+}
+
+func TestPixelStatsCountsDailyUniqueVisitors(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com", OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	for _, remote := range []string{"192.0.2.1:1000", "192.0.2.1:2000", "192.0.2.7:1000"} {
+		req := httptest.NewRequest(http.MethodGet, "/go/2", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		server.handlePixelRedirect(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: "2"}}})
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected a redirect, got %d", w.Code)
+		}
+	}
+
+	stats := func(sessionID, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/account/pixels/"+id+"/stats?days=7", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handlePixelStats(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: id}}})
+		return w
+	}
+	w := stats(sessionID, "2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Clicks         int64                   `json:"clicks"`
+		UniqueVisitors int64                   `json:"unique_visitors"`
+		Series         []storage.PixelClickDay `json:"series"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode stats: %v", err)
+	}
+	if resp.Clicks != 3 || resp.UniqueVisitors != 2 || len(resp.Series) != 7 {
+		t.Fatalf("expected 3 clicks from 2 visitors over 7 days, got %+v", resp)
+	}
+	if today := resp.Series[6]; today.Clicks != 3 || today.Visitors != 2 || resp.Series[0].Clicks != 0 {
+		t.Fatalf("expected today's clicks last in the series, got %+v", resp.Series)
+	}
+	if w := stats(sessionID, "1"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a pixel the user does not own, got %d", w.Code)
+	}
+
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	otherSession, err := server.sessions.Create(other.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if w := stats(otherSession, "2"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected another user's pixel to be hidden, got %d", w.Code)
+	}
+}
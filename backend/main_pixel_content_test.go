@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/linkcheck"
+	"github.com/example/kup-piksel/internal/moderation"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelTitleAndDescription(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	blocklist, err := newContentBlocklist([]string{" Casino "})
+	if err != nil {
+		t.Fatalf("build blocklist: %v", err)
+	}
+	server.contentBlocklist.Store(blocklist)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "TITL-EDES-CRIP-TION", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "TITL-EDES-CRIP-TION"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+
+	buy := func(pixel PixelUpdate) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdatePixelRequest{Pixels: []PixelUpdate{pixel}})
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	for name, pixel := range map[string]PixelUpdate{
+		"long title":          {ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com", Title: strings.Repeat("ą", pixelTitleMaxLength+1)},
+		"multiline title":     {ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com", Title: "a\nb"},
+		"blocked url":         {ID: 1, Status: "taken", Color: "#111111", URL: "https://best-CASINO.example"},
+		"blocked description": {ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com", Description: "Online casino"},
+		"script url":          {ID: 1, Status: "taken", Color: "#111111", URL: "javascript:alert(1)"},
+		"relative url":        {ID: 1, Status: "taken", Color: "#111111", URL: "example.com/shop"},
+		"credentials in url":  {ID: 1, Status: "taken", Color: "#111111", URL: "https://bank.example@evil.example"},
+		"private address":     {ID: 1, Status: "taken", Color: "#111111", URL: "http://192.168.1.1/admin"},
+		"single label host":   {ID: 1, Status: "taken", Color: "#111111", URL: "http://intranet/"},
+	} {
+		if w := buy(pixel); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com", Title: " Kawiarnia ", Description: "Najlepsza kawa\r\nw mieście"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	w = getPixels(t, server, "/api/pixels?fields=id,title,description")
+	var resp storage.PixelState
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := resp.Pixels[0]; got.Title != "Kawiarnia" || got.Description != "Najlepsza kawa\nw mieście" || got.URL != "" {
+		t.Fatalf("unexpected pixel %+v", got)
+	}
+
+	if w := buy(PixelUpdate{ID: 1, Status: "free"}); w.Code != http.StatusOK {
+		t.Fatalf("unexpected release status %d: %s", w.Code, w.Body.String())
+	}
+	state, err := store.GetAllPixels(ctx)
+	if err != nil {
+		t.Fatalf("load pixels: %v", err)
+	}
+	if got := state.Pixels[0]; got.Title != "" || got.Description != "" {
+		t.Fatalf("releasing a pixel must clear its texts, got %+v", got)
+	}
+}
+
+// fakeLinkChecker refuses links ending in /gone and counts the checks.
+type fakeLinkChecker struct {
+	checks int
+}
+
+func (f *fakeLinkChecker) Check(ctx context.Context, link string) error {
+	f.checks++
+	if strings.HasSuffix(link, "/gone") {
+		return fmt.Errorf("%w: status 404", linkcheck.ErrUnreachable)
+	}
+	return nil
+}
+
+func TestPurchaseChecksLinksBeforeCharging(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	checker := &fakeLinkChecker{}
+	server.linkChecker = checker
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "LINK-CHEC-KLIN-KCHE", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "LINK-CHEC-KLIN-KCHE"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	buy := func(pixels ...PixelUpdate) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdatePixelRequest{Pixels: pixels})
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com/gone"}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unreachable") {
+		t.Fatalf("expected a missing page to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	user, err := store.GetUserByID(ctx, admin.ID)
+	if err != nil || user.Points != 100 {
+		t.Fatalf("expected nothing to be charged, got %d points, %v", user.Points, err)
+	}
+	checker.checks = 0
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com/"}, PixelUpdate{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com/"}); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if checker.checks != 1 {
+		t.Fatalf("expected one check for a shared link, got %d", checker.checks)
+	}
+}
+
+type fakeModerator struct {
+	reviews int
+	down    bool
+}
+
+func (f *fakeModerator) Review(ctx context.Context, submission moderation.Submission) error {
+	f.reviews++
+	switch {
+	case f.down:
+		return fmt.Errorf("safe browsing lookup: status 503")
+	case strings.Contains(submission.URL, "phish"), submission.Color == "#ff00ff":
+		return fmt.Errorf("%w: listed", moderation.ErrRejected)
+	}
+	return nil
+}
+
+func TestPurchaseConsultsModerator(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	moderator := &fakeModerator{}
+	server.moderator = moderator
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "MODE-RATE-MODE-RATE", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "MODE-RATE-MODE-RATE"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	buy := func(pixels ...PixelUpdate) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdatePixelRequest{Pixels: pixels})
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://phish.example/"}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "rejected by moderation") {
+		t.Fatalf("expected a flagged link to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#ff00ff", URL: "https://example.com/"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a flagged color to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+	moderator.down = true
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com/"}); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected an unreachable provider to refuse the purchase, got %d: %s", w.Code, w.Body.String())
+	}
+	user, err := store.GetUserByID(ctx, admin.ID)
+	if err != nil || user.Points != 100 {
+		t.Fatalf("expected nothing to be charged, got %d points, %v", user.Points, err)
+	}
+
+	server.moderationFailOpen = true
+	moderator.reviews = 0
+	if w := buy(PixelUpdate{ID: 1, Status: "taken", Color: "#111111", URL: "https://example.com/"}, PixelUpdate{ID: 2, Status: "taken", Color: "#111111", URL: "https://example.com/"}); w.Code != http.StatusOK {
+		t.Fatalf("expected fail-open moderation to accept the purchase, got %d: %s", w.Code, w.Body.String())
+	}
+	if moderator.reviews != 1 {
+		t.Fatalf("expected one review for identical content, got %d", moderator.reviews)
+	}
+}
+
+func TestPurchaseAllowsOnlyListedDomains(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	server.allowedLinkDomains = []string{"corp.example"}
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "ALLO-WEDD-OMAI-NSAL", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "ALLO-WEDD-OMAI-NSAL"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+
+	buy := func(link string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdatePixelRequest{Pixels: []PixelUpdate{{ID: 1, Status: "taken", Color: "#111111", URL: link}}})
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	for _, link := range []string{"https://example.com", "https://notcorp.example", "https://corp.example.evil.com"} {
+		if w := buy(link); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "url domain is not allowed") {
+			t.Fatalf("%s: expected the domain to be refused, got %d: %s", link, w.Code, w.Body.String())
+		}
+	}
+	for _, link := range []string{"https://corp.example/offer", "https://Intranet.CORP.example"} {
+		if w := buy(link); w.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", link, w.Code, w.Body.String())
+		}
+	}
+}
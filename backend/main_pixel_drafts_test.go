@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelDraftsAreSavedAndPurchased(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.pixelDrafts = config.PixelDrafts{MaxPerUser: 2, MaxPixels: 2}
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "designer@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "DRAF-DRAF-DRAF-DRAF", 100); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "DRAF-DRAF-DRAF-DRAF"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		call := func(handler gin.HandlerFunc, method string, body any, params ...gin.Param) *httptest.ResponseRecorder {
+			var payload []byte
+			if body != nil {
+				payload, _ = json.Marshal(body)
+			}
+			req := httptest.NewRequest(method, "/api/drafts", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			w := httptest.NewRecorder()
+			handler(&gin.Context{Writer: w, Request: req, Params: params})
+			return w
+		}
+		type draftResponse struct {
+			Draft storage.PixelDraft `json:"draft"`
+		}
+		pixel := func(id int, url string) gin.H {
+			return gin.H{"id": id, "color": "#abcdef", "url": url}
+		}
+
+		if w := call(server.handleCreateDraft, http.MethodPost, gin.H{"pixels": []gin.H{pixel(1, ""), pixel(2, ""), pixel(3, "")}}); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected more than maxPixels to be rejected, got %d", w.Code)
+		}
+		if w := call(server.handleCreateDraft, http.MethodPost, gin.H{"pixels": []gin.H{pixel(1, ""), pixel(1, "")}}); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected a repeated pixel to be rejected, got %d", w.Code)
+		}
+		w := call(server.handleCreateDraft, http.MethodPost, gin.H{"name": " Logo ", "pixels": []gin.H{pixel(2, "")}})
+		var created draftResponse
+		if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.Draft.Name != "Logo" {
+			t.Fatalf("unexpected create response %d: %s", w.Code, w.Body.String())
+		}
+		if w := call(server.handleCreateDraft, http.MethodPost, gin.H{"pixels": []gin.H{pixel(3, "")}}); w.Code != http.StatusCreated {
+			t.Fatalf("unexpected create status %d", w.Code)
+		}
+		if w := call(server.handleCreateDraft, http.MethodPost, gin.H{"pixels": []gin.H{pixel(3, "")}}); w.Code != http.StatusConflict {
+			t.Fatalf("expected the draft limit to apply, got %d", w.Code)
+		}
+		id := gin.Param{Key: "id", Value: strconv.FormatInt(created.Draft.ID, 10)}
+
+		// An unfinished draft is saved but cannot be bought.
+		if w := call(server.handlePurchaseDraft, http.MethodPost, nil, id); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected a pixel without a link to be refused, got %d %s", w.Code, w.Body.String())
+		}
+		w = call(server.handleUpdateDraft, http.MethodPut, gin.H{"name": "Logo", "pixels": []gin.H{pixel(1, "https://example.com"), pixel(2, "https://example.com")}}, id)
+		var updated draftResponse
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &updated) != nil || len(updated.Draft.Pixels) != 2 || updated.Draft.Pixels[0].URL != "https://example.com" {
+			t.Fatalf("unexpected update response %d: %s", w.Code, w.Body.String())
+		}
+		if owned, err := store.GetPixelsByOwner(ctx, user.ID); err != nil || len(owned) != 0 {
+			t.Fatalf("expected a draft to take no pixels, got %v %v", owned, err)
+		}
+
+		if w := call(server.handlePurchaseDraft, http.MethodPost, nil, id); w.Code != http.StatusOK {
+			t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+		}
+		if owned, err := store.GetPixelsByOwner(ctx, user.ID); err != nil || len(owned) != 2 {
+			t.Fatalf("expected the draft pixels to be bought, got %v %v", owned, err)
+		}
+		if w := call(server.handleGetDraft, http.MethodGet, nil, id); w.Code != http.StatusNotFound {
+			t.Fatalf("expected a purchased draft to be removed, got %d", w.Code)
+		}
+		w = call(server.handleListDrafts, http.MethodGet, nil)
+		var list struct {
+			Drafts []storage.PixelDraft `json:"drafts"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Drafts) != 1 || list.Drafts[0].Pixels[0].ID != 3 {
+			t.Fatalf("unexpected draft list %s", w.Body.String())
+		}
+		other := gin.Param{Key: "id", Value: strconv.FormatInt(list.Drafts[0].ID, 10)}
+		if w := call(server.handleDeleteDraft, http.MethodDelete, nil, other); w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected delete status %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestGiftPixelsChargesBuyerAndNotifiesRecipient(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		mailer := &noticeMailer{}
+		server.mailer = mailer
+		ctx := context.Background()
+		buyer, err := store.CreateUser(ctx, "buyer@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create buyer: %v", err)
+		}
+		recipient, err := store.CreateUser(ctx, "friend@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create recipient: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "GIFT-GIFT-GIFT-AAAA", 50); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, buyer.ID, "GIFT-GIFT-GIFT-AAAA"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(buyer.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		buy := func(giftTo string, ids ...int) *httptest.ResponseRecorder {
+			pixels := make([]gin.H, len(ids))
+			for i, id := range ids {
+				pixels[i] = gin.H{"id": id, "status": "taken", "color": "#abcdef", "url": "https://example.com/gift"}
+			}
+			payload, _ := json.Marshal(gin.H{"pixels": pixels, "gift_to": giftTo})
+			req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			w := httptest.NewRecorder()
+			server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+			return w
+		}
+
+		if w := buy("nobody@example.com", 1); w.Code != http.StatusNotFound {
+			t.Fatalf("expected an unknown recipient to be rejected, got %d %s", w.Code, w.Body.String())
+		}
+		if w := buy("buyer@example.com", 1); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected a gift to oneself to be rejected, got %d %s", w.Code, w.Body.String())
+		}
+		w := buy("Friend@Example.com", 1, 2)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected gift status %d %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			User    storage.User   `json:"user"`
+			Receipt map[string]any `json:"receipt"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.User.ID != buyer.ID || resp.User.Points != 30 || resp.Receipt["gift_to"] != "friend@example.com" {
+			t.Fatalf("expected the buyer to pay for the gift, got %s", w.Body.String())
+		}
+		owned, err := store.GetPixelsByOwner(ctx, recipient.ID)
+		if err != nil || len(owned) != 2 {
+			t.Fatalf("expected the recipient to own both pixels, got %+v, %v", owned, err)
+		}
+		if len(mailer.recipients) != 1 || mailer.recipients[0] != "friend@example.com" || !strings.Contains(mailer.notices[0].Body, "#abcdef") {
+			t.Fatalf("expected the recipient to be sent the design, got %v %+v", mailer.recipients, mailer.notices)
+		}
+
+		// Pixels that already have an owner cannot be gifted.
+		if w := buy("friend@example.com", 2); w.Code != http.StatusForbidden {
+			t.Fatalf("expected an owned pixel to be refused, got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelHistoryRecordsChanges(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if _, err := store.UpdatePixelForUser(ctx, admin.ID, storage.Pixel{ID: 2, Status: "taken", Color: "#111111", URL: "https://example.com"}); err != nil {
+		t.Fatalf("buy pixel: %v", err)
+	}
+	if _, err := store.UpdatePixelForUser(ctx, admin.ID, storage.Pixel{ID: 2, Status: "taken", Color: "#111111", URL: "https://example.com", Title: "Sklep"}); err != nil {
+		t.Fatalf("edit title: %v", err)
+	}
+	if _, err := store.UpdatePixelForUser(ctx, admin.ID, storage.Pixel{ID: 2, Status: "taken", Color: "#222222", URL: "https://example.com"}); err != nil {
+		t.Fatalf("recolor pixel: %v", err)
+	}
+	missing := int64(999)
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 3, Status: "taken", Color: "#333333", URL: "https://example.org", OwnerID: &missing}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	if _, err := store.CheckConsistency(ctx, true); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+
+	history := func(sessionID, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/pixels/"+id+"/history", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handlePixelHistory(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: id}}})
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) []storage.PixelChange {
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			History []storage.PixelChange `json:"history"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode history: %v", err)
+		}
+		return resp.History
+	}
+
+	changes := decode(history(sessionID, "2"))
+	if len(changes) != 2 || changes[0].Color != "#222222" || changes[1].Color != "#111111" {
+		t.Fatalf("expected the purchase and the recolor newest first, got %+v", changes)
+	}
+	if bought := changes[1]; bought.OwnerID == nil || *bought.OwnerID != admin.ID || bought.PreviousOwnerID != nil {
+		t.Fatalf("expected the purchase to record the new owner, got %+v", bought)
+	}
+	changes = decode(history(sessionID, "3"))
+	if len(changes) != 2 || changes[0].Status != "free" || changes[0].PreviousOwnerID == nil || *changes[0].PreviousOwnerID != missing {
+		t.Fatalf("expected the consistency repair to be recorded, got %+v", changes)
+	}
+	if w := history(sessionID, "abc"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an invalid pixel id, got %d", w.Code)
+	}
+
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	otherSession, err := server.sessions.Create(other.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if w := history(otherSession, "2"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected the history to be admin-only, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/account", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleAccount(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var account struct {
+		PixelHistory []storage.PixelChange `json:"pixel_history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &account); err != nil {
+		t.Fatalf("decode account: %v", err)
+	}
+	if len(account.PixelHistory) != 2 || account.PixelHistory[0].PixelID != 2 || account.PixelHistory[1].PixelID != 2 {
+		t.Fatalf("expected only the user's own pixel changes, got %+v", account.PixelHistory)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelHoldsKeepPixelsFromOtherBuyers(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.pixelHolds = config.PixelHolds{TTLMinutes: 5, MaxPixels: 3}
+		ctx := context.Background()
+		sessions := make(map[string]string)
+		for i, address := range []string{"first@example.com", "second@example.com"} {
+			user, err := store.CreateUser(ctx, address, "hash")
+			if err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			code := []string{"HOLD-HOLD-HOLD-AAAA", "HOLD-HOLD-HOLD-BBBB"}[i]
+			if err := store.CreateActivationCode(ctx, code, 50); err != nil {
+				t.Fatalf("create activation code: %v", err)
+			}
+			if _, _, err := store.RedeemActivationCode(ctx, user.ID, code); err != nil {
+				t.Fatalf("redeem activation code: %v", err)
+			}
+			if sessions[address], err = server.sessions.Create(user.ID); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+		}
+		call := func(handler gin.HandlerFunc, method, address string, body any) *httptest.ResponseRecorder {
+			payload, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, "/api/pixels/reserve", bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessions[address]})
+			w := httptest.NewRecorder()
+			handler(&gin.Context{Writer: w, Request: req})
+			return w
+		}
+		reserve := func(address string, ids ...int) *httptest.ResponseRecorder {
+			return call(server.handleReservePixels, http.MethodPost, address, gin.H{"pixel_ids": ids})
+		}
+		buy := func(address string, id int) *httptest.ResponseRecorder {
+			return call(server.handleUpdatePixel, http.MethodPost, address, gin.H{
+				"pixels": []gin.H{{"id": id, "status": "taken", "color": "#123456", "url": "https://example.com"}},
+			})
+		}
+
+		if w := reserve("first@example.com", 1, 2, 3, 4); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected more than maxPixels to be rejected, got %d", w.Code)
+		}
+		if w := reserve("first@example.com", 1, 2); w.Code != http.StatusOK {
+			t.Fatalf("unexpected reservation %d %s", w.Code, w.Body.String())
+		}
+		w := reserve("second@example.com", 2, 3)
+		var second struct {
+			Held        []int `json:"held"`
+			Unavailable []int `json:"unavailable"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &second); err != nil || len(second.Held) != 1 || second.Held[0] != 3 || len(second.Unavailable) != 1 || second.Unavailable[0] != 2 {
+			t.Fatalf("expected only pixel 3 to be held, got %d %s", w.Code, w.Body.String())
+		}
+		if w := reserve("second@example.com", 1); w.Code != http.StatusConflict {
+			t.Fatalf("expected a fully held selection to conflict, got %d", w.Code)
+		}
+
+		if w := buy("second@example.com", 1); w.Code != http.StatusConflict {
+			t.Fatalf("expected a held pixel to be refused to others, got %d %s", w.Code, w.Body.String())
+		}
+		if w := buy("first@example.com", 1); w.Code != http.StatusOK {
+			t.Fatalf("expected the holder to buy the pixel, got %d %s", w.Code, w.Body.String())
+		}
+
+		w = call(server.handleGetPixelHolds, http.MethodGet, "first@example.com", nil)
+		var holds struct {
+			Holds []storage.PixelHold `json:"holds"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &holds); err != nil || len(holds.Holds) != 1 || holds.Holds[0].PixelID != 2 {
+			t.Fatalf("expected buying to release the hold on pixel 1, got %s", w.Body.String())
+		}
+		if w := call(server.handleReleasePixelHolds, http.MethodDelete, "first@example.com", nil); w.Code != http.StatusNoContent {
+			t.Fatalf("unexpected release status %d", w.Code)
+		}
+		if w := buy("second@example.com", 2); w.Code != http.StatusOK {
+			t.Fatalf("expected a released pixel to be for sale, got %d %s", w.Code, w.Body.String())
+		}
+
+		// Expired holds are pruned and no longer block anybody.
+		first, err := store.GetUserByEmail(ctx, "first@example.com")
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		past := time.Now().Add(-time.Hour)
+		if _, err := store.HoldPixels(ctx, first.ID, []int{3}, past.Add(5*time.Minute), past); err != nil {
+			t.Fatalf("HoldPixels() error = %v", err)
+		}
+		if removed, err := store.DeleteExpiredPixelHolds(ctx, time.Now()); err != nil || removed != 1 {
+			t.Fatalf("DeleteExpiredPixelHolds() = %d, %v", removed, err)
+		}
+		if _, err := store.HoldPixels(ctx, first.ID, []int{3}, past.Add(5*time.Minute), past); err != nil {
+			t.Fatalf("HoldPixels() error = %v", err)
+		}
+		if w := buy("second@example.com", 3); w.Code != http.StatusOK {
+			t.Fatalf("expected an expired hold to be ignored, got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
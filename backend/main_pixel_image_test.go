@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPurchasePixelImage(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "IMAG-EUPL-OADI-MG01", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "IMAG-EUPL-OADI-MG01"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+
+	// A 4x2 logo: red on the left, transparent on the right, painted onto pixels 1 and 2.
+	logo := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		logo.Set(0, y, color.NRGBA{R: 0xff, A: 0xff})
+		logo.Set(1, y, color.NRGBA{R: 0xff, G: 0x22, A: 0xff})
+	}
+	upload := func(fields map[string]string, img image.Image) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for name, value := range fields {
+			_ = form.WriteField(name, value)
+		}
+		if img != nil {
+			part, _ := form.CreateFormFile("image", "logo.png")
+			if err := png.Encode(part, img); err != nil {
+				t.Fatalf("encode png: %v", err)
+			}
+		}
+		form.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels/image", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handlePurchasePixelImage(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	rect := map[string]string{"x": "1", "y": "0", "width": "2", "height": "1", "url": "https://example.com"}
+
+	if w := upload(rect, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an image, got %d", w.Code)
+	}
+	outside := map[string]string{"x": "999", "y": "0", "width": "2", "height": "1", "url": "https://example.com"}
+	if w := upload(outside, logo); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a rectangle outside the grid, got %d", w.Code)
+	}
+
+	w := upload(rect, logo)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	state, err := store.GetAllPixels(ctx)
+	if err != nil {
+		t.Fatalf("load pixels: %v", err)
+	}
+	for _, pixel := range state.Pixels {
+		switch pixel.ID {
+		case 1:
+			if pixel.Status != "taken" || pixel.Color != "#ff1100" || pixel.URL != "https://example.com" || pixel.OwnerID == nil || *pixel.OwnerID != admin.ID {
+				t.Fatalf("pixel 1 should carry the averaged logo color, got %+v", pixel)
+			}
+		case 2:
+			if pixel.Status != "free" {
+				t.Fatalf("transparent cells must not be bought, got %+v", pixel)
+			}
+		}
+	}
+}
+
+func TestPreviewPixelImage(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#000000", URL: "https://example.org", OwnerID: &other.ID}); err != nil {
+		t.Fatalf("take pixel: %v", err)
+	}
+
+	// A 3x1 logo with an opaque cell over each of pixels 1 and 2 and a transparent one over 3.
+	logo := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	logo.Set(0, 0, color.NRGBA{B: 0xff, A: 0xff})
+	logo.Set(1, 0, color.NRGBA{G: 0xff, A: 0xff})
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range map[string]string{"x": "1", "y": "0", "width": "3", "height": "1"} {
+		_ = form.WriteField(name, value)
+	}
+	part, _ := form.CreateFormFile("image", "logo.png")
+	if err := png.Encode(part, logo); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels/preview-image", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handlePreviewPixelImage(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+
+	var preview struct {
+		Pixels []struct {
+			ID    int    `json:"id"`
+			Color string `json:"color"`
+		} `json:"pixels"`
+		Unavailable []int `json:"unavailable"`
+		TotalPoints int64 `json:"total_points"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("decode preview: %v", err)
+	}
+	if len(preview.Pixels) != 2 || preview.Pixels[0].ID != 1 || preview.Pixels[0].Color != "#0000ff" || preview.Pixels[1].Color != "#00ff00" {
+		t.Fatalf("expected the opaque cells with their colors, got %s", w.Body.String())
+	}
+	if len(preview.Unavailable) != 1 || preview.Unavailable[0] != 2 || preview.TotalPoints != server.pixelCostPoints {
+		t.Fatalf("expected only pixel 1 to be charged, got %s", w.Body.String())
+	}
+	if owned, err := store.GetPixelsByOwner(ctx, other.ID); err != nil || len(owned) != 1 {
+		t.Fatalf("the preview must not change any pixel, got %+v, %v", owned, err)
+	}
+}
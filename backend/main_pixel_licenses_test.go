@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPurchaseStoresLicenseForRegion(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "LICE-NSEL-ICEN-SEL1", 100); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "LICE-NSEL-ICEN-SEL1"); err != nil {
+		t.Fatalf("redeem code: %v", err)
+	}
+
+	do := func(handler func(*gin.Context), method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	invalid := `{"pixels":[{"id":4,"status":"taken","color":"#ffffff","url":"https://example.com"}],"license":{"artwork_owner":" ","contact":"a@example.com"}}`
+	if w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", invalid); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid license to be rejected, got %d", w.Code)
+	}
+
+	purchase := `{"pixels":[{"id":2,"status":"taken","color":"#ffffff","url":"https://example.com"},{"id":3,"status":"taken","color":"#000000","url":"https://example.com"}],
+		"license":{"artwork_owner":" Jan Kowalski ","contact":"jan@example.com","statement":"Own work"}}`
+	w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", purchase)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		License *storage.PixelLicense `json:"license"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.License == nil || resp.License.ArtworkOwner != "Jan Kowalski" {
+		t.Fatalf("unexpected purchase body %s", w.Body.String())
+	}
+
+	w = do(server.handleAdminPixelLicenses, http.MethodGet, "/api/admin/pixel-licenses?pixel_id=3", "")
+	var listed struct {
+		Licenses []storage.PixelLicense `json:"licenses"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected admin response %d: %s", w.Code, w.Body.String())
+	}
+	if len(listed.Licenses) != 1 || len(listed.Licenses[0].PixelIDs) != 2 || listed.Licenses[0].PixelIDs[0] != 2 || listed.Licenses[0].Contact != "jan@example.com" {
+		t.Fatalf("unexpected licenses %+v", listed.Licenses)
+	}
+	if w := do(server.handleAdminPixelLicenses, http.MethodGet, "/api/admin/pixel-licenses", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected missing filter to be rejected, got %d", w.Code)
+	}
+
+	w = do(server.handleAccountExport, http.MethodGet, "/api/account/export", "")
+	var export accountExport
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if len(export.Licenses) != 1 || export.Licenses[0].Statement != "Own work" {
+		t.Fatalf("unexpected exported licenses %+v", export.Licenses)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestGetPixelOverlayHidesOwners(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	for i, email := range []string{"anna@example.com", "jan@example.com"} {
+		user, err := store.CreateUser(ctx, email, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: i + 1, Status: "taken", Color: "#ff0000", URL: "https://example.com", OwnerID: &user.ID}); err != nil {
+			t.Fatalf("take pixel %d: %v", i+1, err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.handleGetPixelOverlay(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/pixels/overlay"+query, nil)})
+		return w
+	}
+
+	w := get("?patterns=4")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+		Patterns int    `json:"patterns"`
+		RLE      string `json:"rle"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode overlay: %v", err)
+	}
+	// The two neighbouring pixels of different owners get different patterns and a boundary
+	// between them; the user ids never appear.
+	want := "1:0:0;1:1:15;1:2:15;999997:0:0"
+	if resp.Width != storage.GridWidth || resp.Height != storage.GridHeight || resp.Patterns != 4 || resp.RLE != want {
+		t.Fatalf("unexpected overlay %s", w.Body.String())
+	}
+
+	for _, query := range []string{"?patterns=1", "?patterns=33", "?patterns=x"} {
+		if w := get(query); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/linkpreview"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelPageShowsSitePreview(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.linkPreviews = linkpreview.NewFetcher(linkpreview.Config{AllowPrivateTargets: true})
+	ctx := context.Background()
+
+	fetches := 0
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<head><meta property="og:title" content="Sklep &lt;script&gt;Kot"><meta name="description" content="Najlepsze koty"></head>`))
+	}))
+	defer site.Close()
+
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#ff0000", URL: site.URL + "/shop", Description: `"><b>promocja`, OwnerID: &admin.ID}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	get := func(path, id string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, path, nil), Params: gin.Params{{Key: "id", Value: id}}})
+		return w
+	}
+
+	w := get("/pixel/2", "2", server.handlePixelPage)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	page := w.Body.String()
+	for _, want := range []string{
+		`<meta property="og:title" content="Sklep Kot">`,
+		`<meta property="og:description" content="&#34;&gt;&lt;b&gt;promocja">`,
+		`<p>Najlepsze koty</p>`,
+		`href="/go/2"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("page is missing %q:\n%s", want, page)
+		}
+	}
+
+	w = get("/api/pixels/2/preview", "2", server.handlePixelPreview)
+	var preview struct {
+		Pixel struct {
+			Host string `json:"host"`
+		} `json:"pixel"`
+		Site *linkpreview.Preview `json:"site"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil || w.Code != http.StatusOK || preview.Site == nil || preview.Site.Description != "Najlepsze koty" || preview.Pixel.Host != "127.0.0.1" {
+		t.Fatalf("unexpected preview %d %s (err %v)", w.Code, w.Body.String(), err)
+	}
+	if fetches != 1 {
+		t.Fatalf("expected the site to be fetched once, got %d", fetches)
+	}
+
+	if w := get("/pixel/1", "1", server.handlePixelPage); w.Code != http.StatusNotFound {
+		t.Fatalf("expected a free pixel to have no page, got %d", w.Code)
+	}
+	if w := get("/api/pixels/x/preview", "x", server.handlePixelPreview); w.Code != http.StatusNotFound {
+		t.Fatalf("expected an invalid id to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestPurchaseEnforcesPixelQuota(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	server.pixelQuota = 2
+	store.SetPixelQuota(2)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "QUOT-AQUO-TAQU-OTAQ", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "QUOT-AQUO-TAQU-OTAQ"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	buy := func(pixels ...PixelUpdate) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdatePixelRequest{Pixels: pixels})
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	taken := func(id int) PixelUpdate {
+		return PixelUpdate{ID: id, Status: "taken", Color: "#123456", URL: "https://example.com/"}
+	}
+	quota := func(handler gin.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/users/"+strconv.FormatInt(admin.ID, 10)+"/pixel-quota", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req, Params: gin.Params{{Key: "id", Value: strconv.FormatInt(admin.ID, 10)}}})
+		return w
+	}
+
+	// The second pixel of the batch fills the quota; the third is refused inside the same transaction.
+	w := buy(taken(1), taken(2), taken(3))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []PixelUpdateResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode purchase: %v", err)
+	}
+	if len(resp.Results) != 3 || resp.Results[1].Pixel == nil || resp.Results[2].Code != "pixel_quota_exceeded" {
+		t.Fatalf("unexpected results %s", w.Body.String())
+	}
+	charged, err := store.GetUserByID(ctx, admin.ID)
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	// Repainting an owned pixel does not count against the quota.
+	if w := buy(taken(1)); w.Code != http.StatusOK {
+		t.Fatalf("expected repainting to pass, got %d: %s", w.Code, w.Body.String())
+	}
+	w = buy(taken(3))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"pixel_quota_exceeded"`) {
+		t.Fatalf("expected the quota error code, got %d: %s", w.Code, w.Body.String())
+	}
+	user, err := store.GetUserByID(ctx, admin.ID)
+	if err != nil || user.Points != charged.Points {
+		t.Fatalf("expected a refused pixel not to be charged, got %d points, %v", user.Points, err)
+	}
+
+	if w := quota(server.handlePutPixelQuota, http.MethodPut, `{"max_pixels": -1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative quota to be rejected, got %d", w.Code)
+	}
+	if w := quota(server.handlePutPixelQuota, http.MethodPut, `{"max_pixels": 3}`); w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if w := buy(taken(3)); w.Code != http.StatusOK {
+		t.Fatalf("expected the override to allow a third pixel, got %d: %s", w.Code, w.Body.String())
+	}
+	var view struct {
+		Owned     int    `json:"owned"`
+		MaxPixels int    `json:"max_pixels"`
+		Source    string `json:"source"`
+	}
+	w = quota(server.handleGetPixelQuota, http.MethodGet, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.Owned != 3 || view.MaxPixels != 3 || view.Source != "admin" {
+		t.Fatalf("unexpected quota %s (%v)", w.Body.String(), err)
+	}
+
+	w = quota(server.handleDeletePixelQuota, http.MethodDelete, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil || view.MaxPixels != 2 || view.Source != "default" {
+		t.Fatalf("unexpected quota after delete %s (%v)", w.Body.String(), err)
+	}
+}
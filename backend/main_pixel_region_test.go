@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestGetPixelRegion(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	pixels := []storage.Pixel{
+		{ID: 4, Status: "taken", Color: "#ff0000", URL: "https://example.com/mine", OwnerID: &admin.ID},
+		{ID: 1004, Status: "taken", Color: "#00ff00", URL: "https://example.com/other", OwnerID: &other.ID},
+	}
+	for _, pixel := range pixels {
+		if err := store.InsertPixel(ctx, pixel); err != nil {
+			t.Fatalf("insert pixel %d: %v", pixel.ID, err)
+		}
+	}
+
+	get := func(query string, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/pixels/region?"+query, nil)
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		}
+		w := httptest.NewRecorder()
+		server.handleGetPixelRegion(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) []regionPixel {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var body struct {
+			Pixels []regionPixel `json:"pixels"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode region: %v", err)
+		}
+		return body.Pixels
+	}
+
+	cells := decode(get("x=3&y=0&w=2&h=2", sessionID))
+	if len(cells) != 4 {
+		t.Fatalf("expected 4 cells, got %d", len(cells))
+	}
+	ids := []int{3, 4, 1003, 1004}
+	for i, cell := range cells {
+		if cell.ID != ids[i] {
+			t.Fatalf("cell %d: expected id %d, got %d", i, ids[i], cell.ID)
+		}
+	}
+	if mine := cells[1]; mine.Status != "taken" || mine.Color != "#ff0000" || !mine.Mine || !mine.Available || mine.X != 4 || mine.Y != 0 {
+		t.Fatalf("unexpected own pixel %+v", mine)
+	}
+	if theirs := cells[3]; theirs.Status != "taken" || theirs.Mine || theirs.Available || theirs.X != 4 || theirs.Y != 1 {
+		t.Fatalf("unexpected foreign pixel %+v", theirs)
+	}
+	if free := cells[2]; free.Status != "free" || free.Mine || !free.Available || free.Color != "" {
+		t.Fatalf("unexpected missing pixel %+v", free)
+	}
+	if w := get("x=3&y=0&w=2&h=2", sessionID); w.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("unexpected cache control %q", w.Header().Get("Cache-Control"))
+	}
+
+	anonymous := decode(get("x=4&y=0&w=1&h=1", ""))
+	if len(anonymous) != 1 || anonymous[0].Mine || anonymous[0].Available {
+		t.Fatalf("unexpected anonymous view %+v", anonymous)
+	}
+
+	for _, query := range []string{"x=0&y=0&w=0&h=1", "x=999&y=0&w=2&h=1", "x=-1&y=0&w=1&h=1", "x=a&y=0&w=1&h=1", "x=0&y=0&w=100&h=100"} {
+		if w := get(query, sessionID); w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
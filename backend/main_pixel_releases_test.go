@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestReleasePixelsRefundsPurchasedPixels(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.rentals = config.Rentals{Enabled: true, PointsPerDay: 3, MaxDays: 30, WarnBeforeHours: 72}
+		server.pixelRefundFraction = 0.5
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "owner@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "FREE-FREE-FREE-FREE", 100); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "FREE-FREE-FREE-FREE"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		call := func(handler gin.HandlerFunc, path string, body any) *httptest.ResponseRecorder {
+			payload, _ := json.Marshal(body)
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			w := httptest.NewRecorder()
+			handler(&gin.Context{Writer: w, Request: req})
+			return w
+		}
+		pixel := func(id int) gin.H {
+			return gin.H{"id": id, "status": "taken", "color": "#123456", "url": "https://example.com"}
+		}
+		if w := call(server.handleUpdatePixel, "/api/pixels", gin.H{"pixels": []gin.H{pixel(1), pixel(2)}}); w.Code != http.StatusOK {
+			t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+		}
+		if w := call(server.handleUpdatePixel, "/api/pixels", gin.H{"pixels": []gin.H{pixel(3)}, "rental_days": 2}); w.Code != http.StatusOK {
+			t.Fatalf("unexpected rental status %d: %s", w.Code, w.Body.String())
+		}
+
+		type releaseResponse struct {
+			Released       []int        `json:"released"`
+			NotOwned       []int        `json:"not_owned"`
+			RefundedPoints int64        `json:"refunded_points"`
+			User           storage.User `json:"user"`
+		}
+		release := func(body gin.H) releaseResponse {
+			t.Helper()
+			w := call(server.handleReleasePixels, "/api/account/pixels/release", body)
+			var resp releaseResponse
+			if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+				t.Fatalf("unexpected release response %d: %s", w.Code, w.Body.String())
+			}
+			return resp
+		}
+
+		if w := call(server.handleReleasePixels, "/api/account/pixels/release", gin.H{"pixel_ids": []int{1}, "all": true}); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected pixel_ids combined with all to be rejected, got %d", w.Code)
+		}
+		if w := call(server.handleReleasePixels, "/api/account/pixels/release", gin.H{}); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected an empty selection to be rejected, got %d", w.Code)
+		}
+
+		// Half of the purchase price comes back; the rental is freed without a refund.
+		resp := release(gin.H{"pixel_ids": []int{1, 3}})
+		if len(resp.Released) != 2 || resp.RefundedPoints != 5 || resp.User.Points != 100-20-6+5 {
+			t.Fatalf("unexpected release %+v", resp)
+		}
+		resp = release(gin.H{"pixel_ids": []int{2, 1}})
+		if len(resp.Released) != 1 || resp.Released[0] != 2 || len(resp.NotOwned) != 1 || resp.NotOwned[0] != 1 || resp.RefundedPoints != 5 {
+			t.Fatalf("expected only pixel 2 to be released, got %+v", resp)
+		}
+		if resp = release(gin.H{"all": true}); len(resp.Released) != 0 || resp.RefundedPoints != 0 {
+			t.Fatalf("expected nothing left to release, got %+v", resp)
+		}
+
+		owned, err := store.GetPixelsByOwner(ctx, user.ID)
+		if err != nil || len(owned) != 0 {
+			t.Fatalf("expected no owned pixels, got %+v (err %v)", owned, err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestSearchPixelsFindsRegionsByKeyword(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	for _, id := range []int{5, 1001, 1002, 2001} {
+		if err := store.InsertPixel(ctx, storage.Pixel{ID: id, Status: "free"}); err != nil {
+			t.Fatalf("insert pixel %d: %v", id, err)
+		}
+	}
+	if err := store.CreateActivationCode(ctx, "SEAR-CHPI-XELS-SEA1", 100); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "SEAR-CHPI-XELS-SEA1"); err != nil {
+		t.Fatalf("redeem code: %v", err)
+	}
+
+	do := func(handler func(*gin.Context), method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+	search := func(query string) []pixelSearchRegion {
+		w := do(server.handleSearchPixels, http.MethodGet, "/api/pixels/search?q="+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected search status %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Results []pixelSearchRegion `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode search: %v", err)
+		}
+		return resp.Results
+	}
+
+	purchase := `{"pixels":[` +
+		`{"id":1001,"status":"taken","color":"#00ff00","url":"https://zolw.example.com","title":"Kawiarnia Żółw","description":"Najlepsza kawa w mieście"},` +
+		`{"id":1002,"status":"taken","color":"#00ff00","url":"https://zolw.example.com","title":"Kawiarnia Żółw","description":"Najlepsza kawa w mieście"},` +
+		`{"id":2001,"status":"taken","color":"#00ff00","url":"https://zolw.example.com","title":"Kawiarnia Żółw","description":"Najlepsza kawa w mieście"},` +
+		`{"id":5,"status":"taken","color":"#0000ff","url":"https://books.example.com","title":"Księgarnia"}]}`
+	if w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", purchase); w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+
+	results := search("KAW+zolw")
+	if len(results) != 1 {
+		t.Fatalf("expected one region, got %+v", results)
+	}
+	want := pixelSearchRegion{PixelID: 1001, Pixels: 3, X: 1, Y: 1, Width: 2, Height: 2, URL: "https://zolw.example.com", Title: "Kawiarnia Żółw", Description: "Najlepsza kawa w mieście"}
+	if results[0] != want {
+		t.Fatalf("unexpected region %+v, want %+v", results[0], want)
+	}
+	if results := search("ksiegarnia+kawa"); len(results) != 0 {
+		t.Fatalf("expected every word to be required, got %+v", results)
+	}
+	if results := search("books"); len(results) != 1 || results[0].PixelID != 5 {
+		t.Fatalf("expected the link to be searched, got %+v", results)
+	}
+
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 5, Status: "free"}); err != nil {
+		t.Fatalf("free pixel: %v", err)
+	}
+	if results := search("ksiegarnia"); len(results) != 0 {
+		t.Fatalf("expected freed pixel to leave the index, got %+v", results)
+	}
+
+	if w := do(server.handleSearchPixels, http.MethodGet, "/api/pixels/search?q=a+!", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected query without words to be rejected, got %d", w.Code)
+	}
+}
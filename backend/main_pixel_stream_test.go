@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestPixelStreamSendsPurchases(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	server.pixelFeed = NewPixelFeed()
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "STRE-AMST-REAM-STR1", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "STRE-AMST-REAM-STR1"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+
+	stream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.handlePixelStream(&gin.Context{Writer: w, Request: r})
+	}))
+	defer stream.Close()
+	resp, err := http.Get(stream.URL)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	for deadline := time.Now().Add(5 * time.Second); server.pixelFeed.Subscribers() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(`{"pixels":[{"id":2,"status":"taken","color":"#123456","url":"https://example.com"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var event, data string
+	for event != "pixels" || data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	var change pixelChange
+	if err := json.Unmarshal([]byte(data), &change); err != nil {
+		t.Fatalf("decode change %q: %v", data, err)
+	}
+	if change.Seq != 1 || len(change.IDs) != 1 || change.IDs[0] != 2 || len(change.Pixels) != 1 || change.Pixels[0].Color != "#123456" {
+		t.Fatalf("unexpected change %+v", change)
+	}
+}
+
+func TestPixelFeedDropsStalledSubscribers(t *testing.T) {
+	feed := NewPixelFeed()
+	stalled, _ := feed.Subscribe()
+	live, unsubscribe := feed.Subscribe()
+	for i := 0; i <= pixelFeedBuffer; i++ {
+		feed.Publish(pixelChange{IDs: []int{i}})
+		<-live
+	}
+	if feed.Subscribers() != 1 {
+		t.Fatalf("expected the stalled subscriber to be dropped, %d left", feed.Subscribers())
+	}
+	received := 0
+	for range stalled {
+		received++
+	}
+	if received != pixelFeedBuffer {
+		t.Fatalf("stalled subscriber should keep its buffered changes, got %d", received)
+	}
+	unsubscribe()
+	unsubscribe()
+	if feed.Subscribers() != 0 {
+		t.Fatal("unsubscribe must remove the subscriber")
+	}
+}
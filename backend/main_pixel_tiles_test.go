@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestGetPixelTile(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.tileSize = 2
+	for _, id := range []int{1002, 1004} {
+		if err := store.InsertPixel(context.Background(), storage.Pixel{ID: id, Status: "free"}); err != nil {
+			t.Fatalf("insert pixel %d: %v", id, err)
+		}
+	}
+
+	get := func(x, y string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.handleGetPixelTile(&gin.Context{
+			Writer:  w,
+			Request: httptest.NewRequest(http.MethodGet, "/api/pixels/tile/"+x+"/"+y+"?fields=id", nil),
+			Params:  gin.Params{{Key: "x", Value: x}, {Key: "y", Value: y}},
+		})
+		return w
+	}
+
+	w := get("1", "0")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var tile storage.PixelState
+	if err := json.Unmarshal(w.Body.Bytes(), &tile); err != nil {
+		t.Fatalf("decode tile: %v", err)
+	}
+	if tile.Width != 2 || tile.Height != 2 || len(tile.Pixels) != 3 || tile.Pixels[0].ID != 2 || tile.Pixels[1].ID != 3 || tile.Pixels[2].ID != 1002 {
+		t.Fatalf("unexpected tile %s", w.Body.String())
+	}
+
+	if w := get("0", "499"); w.Code != http.StatusOK || w.Body.String() != `{"width":2,"height":2,"pixels":[]}` {
+		t.Fatalf("unexpected bottom tile %d %s", w.Code, w.Body.String())
+	}
+	for _, coords := range [][2]string{{"500", "0"}, {"0", "500"}, {"-1", "0"}, {"a", "0"}} {
+		if w := get(coords[0], coords[1]); w.Code != http.StatusNotFound {
+			t.Fatalf("tile %v: expected 404, got %d", coords, w.Code)
+		}
+	}
+}
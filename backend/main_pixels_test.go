@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func getPixels(t *testing.T, server *Server, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.handleGetPixels(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, target, nil)})
+	return w
+}
+
+func TestGetPixelsFieldSelection(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+
+	w := getPixels(t, server, "/api/pixels?fields=id,status")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Pixels []map[string]any `json:"pixels"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Pixels) != 3 {
+		t.Fatalf("expected 3 pixels, got %d", len(resp.Pixels))
+	}
+	for _, pixel := range resp.Pixels {
+		if _, ok := pixel["updated_at"]; ok || len(pixel) != 2 {
+			t.Fatalf("expected only id and status, got %v", pixel)
+		}
+	}
+
+	full := getPixels(t, server, "/api/pixels")
+	if err := json.Unmarshal(full.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if _, ok := resp.Pixels[0]["updated_at"]; !ok {
+		t.Fatalf("expected all fields without ?fields, got %v", resp.Pixels[0])
+	}
+
+	if w := getPixels(t, server, "/api/pixels?fields=id,email"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown field, got %d", w.Code)
+	}
+}
+
+func TestGetPixelColors(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	if _, err := store.UpdatePixel(context.Background(), storage.Pixel{ID: 2, Status: "taken", Color: "#123456", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	server.handleGetPixelColors(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/pixels/colors", nil)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Colors []string `json:"colors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Colors) != storage.TotalPixels || resp.Colors[2] != "#123456" || resp.Colors[1] != "" {
+		t.Fatalf("unexpected colors: len=%d [1]=%q [2]=%q", len(resp.Colors), resp.Colors[1], resp.Colors[2])
+	}
+
+	w = httptest.NewRecorder()
+	server.handleGetPixelColors(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/pixels/colors?encoding=rle", nil)})
+	if want := `{"width":1000,"height":1000,"rle":"2:;1:#123456;999997:"}`; w.Body.String() != want {
+		t.Fatalf("unexpected RLE response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.handleGetPixelColors(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/pixels/colors?encoding=gzip", nil)})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown encoding, got %d", w.Code)
+	}
+}
+
+func TestGetPixelsFreeRanges(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	if _, err := store.UpdatePixel(context.Background(), storage.Pixel{ID: 2, Status: "taken", Color: "#123456", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	byQuery := getPixels(t, server, "/api/pixels?free=ranges&fields=id,status")
+	req := httptest.NewRequest(http.MethodGet, "/api/pixels?fields=id,status", nil)
+	req.Header.Set("Accept", freeRangesMediaType)
+	byHeader := httptest.NewRecorder()
+	server.handleGetPixels(&gin.Context{Writer: byHeader, Request: req})
+
+	want := `{"width":1000,"height":1000,"pixels":[{"id":2,"status":"taken"}],"free_ranges":[[1,1],[3,3]]}`
+	for name, w := range map[string]*httptest.ResponseRecorder{"query": byQuery, "accept": byHeader} {
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Fatalf("%s: unexpected response %d %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGetPixelsSince(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	since := time.Now().UTC().Add(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := store.UpdatePixel(context.Background(), storage.Pixel{ID: 3, Status: "taken", Color: "#654321", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	w := getPixels(t, server, "/api/pixels?since="+since.Format(time.RFC3339Nano))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Next   time.Time       `json:"next"`
+		Pixels []storage.Pixel `json:"pixels"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Pixels) != 1 || resp.Pixels[0].ID != 3 || resp.Pixels[0].Color != "#654321" {
+		t.Fatalf("expected only pixel 3, got %+v", resp.Pixels)
+	}
+	if resp.Next.IsZero() || resp.Next.After(time.Now()) {
+		t.Fatalf("unexpected next cursor %v", resp.Next)
+	}
+
+	if w := getPixels(t, server, "/api/pixels?since=yesterday"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid since, got %d", w.Code)
+	}
+}
+
+func TestGetPixelsBinaryRLE(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	if _, err := store.UpdatePixel(context.Background(), storage.Pixel{ID: 2, Status: "taken", Color: "#123456", URL: "https://example.com"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+	req.Header.Set("Accept", gridRLEMediaType)
+	w := httptest.NewRecorder()
+	server.handleGetPixels(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != gridRLEMediaType {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.Bytes()
+	if !bytes.HasPrefix(body, []byte(storage.PixelRLEMagic)) || !bytes.Contains(body, []byte("#123456")) || !bytes.Contains(body, []byte("https://example.com")) {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if full := getPixels(t, server, "/api/pixels"); len(body) >= full.Body.Len() {
+		t.Fatalf("binary grid (%d bytes) should be smaller than JSON (%d bytes)", len(body), full.Body.Len())
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPointsLedgerRecordsBalanceChanges(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		ctx := context.Background()
+		user, _ := buyPixelsForTest(t, server, store, "ledger@example.com", 1, 2)
+		release, err := store.ReleasePixelsForUser(ctx, user.ID, []int{1}, 1)
+		if err != nil {
+			t.Fatalf("release pixels: %v", err)
+		}
+
+		entries, err := store.ListLedgerEntries(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("list ledger entries: %v", err)
+		}
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 ledger entries, got %+v", entries)
+		}
+		if entry := entries[0]; entry.Kind != storage.LedgerRelease || entry.Delta != 10 || entry.Reference != "1" {
+			t.Fatalf("expected the release refund first, got %+v", entry)
+		}
+		if entry := entries[1]; entry.Kind != storage.LedgerPurchase || entry.Delta != -20 || entry.Reference != server.currentPrices().Version {
+			t.Fatalf("expected the purchase at the current price version, got %+v", entry)
+		}
+		if entry := entries[2]; entry.Kind != storage.LedgerActivationCode || entry.Delta != 1000 {
+			t.Fatalf("expected the redeemed code, got %+v", entry)
+		}
+		var balance int64
+		for _, entry := range entries {
+			balance += entry.Delta
+		}
+		if balance != release.User.Points {
+			t.Fatalf("ledger sums to %d, balance is %d", balance, release.User.Points)
+		}
+	})
+}
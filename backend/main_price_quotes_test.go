@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestPriceQuoteSurvivesPriceChange(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, "buyer@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "QUOT-QUOT-QUOT-QUOT", 50); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, user.ID, "QUOT-QUOT-QUOT-QUOT"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	server.priceQuotes, _ = newQuoteSigner("0123456789abcdef0123456789abcdef", 15*time.Minute)
+
+	request := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		c := &gin.Context{Writer: w, Request: req}
+		if method == http.MethodGet {
+			server.handlePriceQuote(c)
+		} else {
+			server.handleUpdatePixel(c)
+		}
+		return w
+	}
+	buy := func(id int, quote string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, "/api/pixels", gin.H{
+			"pixels": []gin.H{{"id": id, "status": "taken", "color": "#123456", "url": "https://example.com"}},
+			"quote":  quote,
+		})
+	}
+
+	w := request(http.MethodGet, "/api/pixels/quote", nil)
+	var quote struct {
+		Quote           string `json:"quote"`
+		PriceVersion    string `json:"price_version"`
+		PixelCostPoints int64  `json:"pixel_cost_points"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &quote); err != nil || w.Code != http.StatusOK || quote.PixelCostPoints != 10 || quote.Quote == "" {
+		t.Fatalf("unexpected quote %d %s (err %v)", w.Code, w.Body.String(), err)
+	}
+
+	// A new deployment raises the price; the quoted purchase is still charged the old one.
+	server.pixelCostPoints = 25
+	w = buy(1, quote.Quote)
+	var resp struct {
+		updatePixelResponse
+		Receipt struct {
+			PointsSpent  int64  `json:"points_spent"`
+			PriceVersion string `json:"price_version"`
+		} `json:"receipt"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase %d %s (err %v)", w.Code, w.Body.String(), err)
+	}
+	if resp.User.Points != 40 || resp.Receipt.PointsSpent != 10 || resp.Receipt.PriceVersion != quote.PriceVersion {
+		t.Fatalf("expected the quoted price to apply, got %s", w.Body.String())
+	}
+
+	entries, err := store.ListLedgerEntries(ctx, user.ID)
+	if err != nil || len(entries) == 0 || entries[0].Delta != -10 || entries[0].Reference != quote.PriceVersion {
+		t.Fatalf("expected the ledger to record the quoted price version, got %+v (err %v)", entries, err)
+	}
+
+	w = buy(2, "")
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.User.Points != 15 || resp.Receipt.PriceVersion == quote.PriceVersion {
+		t.Fatalf("expected an unquoted purchase to pay the current price, got %s", w.Body.String())
+	}
+
+	if w := buy(3, strings.Replace(quote.Quote, ".10.", ".1.", 1)); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "quote_invalid") {
+		t.Fatalf("expected a tampered quote to be rejected, got %d %s", w.Code, w.Body.String())
+	}
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := server.priceQuotes.verify(other.ID, quote.Quote, time.Now()); err == nil {
+		t.Fatal("expected a quote to be bound to its user")
+	}
+
+	server.priceQuotes.ttl = -time.Minute
+	w = request(http.MethodGet, "/api/pixels/quote", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &quote); err != nil {
+		t.Fatalf("decode quote: %v", err)
+	}
+	w = buy(3, quote.Quote)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"code":"quote_expired"`) || !strings.Contains(w.Body.String(), `"pixel_cost_points":25`) {
+		t.Fatalf("expected an expired quote to be answered with the current prices, got %d %s", w.Code, w.Body.String())
+	}
+}
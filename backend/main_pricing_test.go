@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/currency"
+)
+
+type pricedResponse struct {
+	PixelPrice *currency.Price `json:"pixel_price"`
+	Receipt    *struct {
+		Pixels      int             `json:"pixels"`
+		PointsSpent int64           `json:"points_spent"`
+		Price       *currency.Price `json:"price"`
+	} `json:"receipt"`
+}
+
+func enablePricingForTest(t *testing.T, server *Server) {
+	t.Helper()
+	converter, err := currency.NewConverter(currency.Config{PointValue: 0.1, Rates: map[string]float64{"EUR": 0.25}})
+	if err != nil {
+		t.Fatalf("new converter: %v", err)
+	}
+	server.currency = converter
+	server.displayCurrency = "PLN"
+}
+
+func TestHandleSessionIncludesPixelPrice(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	enablePricingForTest(t, server)
+
+	for query, want := range map[string]string{"": "1,00 zł", "?currency=eur": "0,25 €", "?currency=XYZ": "1,00 zł"} {
+		w := httptest.NewRecorder()
+		server.handleSession(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/session"+query, nil)})
+
+		var resp pricedResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.PixelPrice == nil || resp.PixelPrice.Formatted != want || resp.PixelPrice.Points != 10 {
+			t.Fatalf("query %q: expected price %q, got %+v", query, want, resp.PixelPrice)
+		}
+	}
+}
+
+func TestHandleUpdatePixelIncludesReceipt(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	enablePricingForTest(t, server)
+
+	user, err := store.CreateUser(context.Background(), "buyer@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.CreateActivationCode(context.Background(), "RCPT-TEST-CODE-0001", 30); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(context.Background(), user.ID, "RCPT-TEST-CODE-0001"); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	body := bytes.NewBufferString(`{"pixels":[{"id":1,"status":"taken","color":"#123456","url":"https://example.com/a"},{"id":2,"status":"taken","color":"#abcdef","url":"https://example.com/b"}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var resp pricedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Receipt == nil || resp.Receipt.Pixels != 2 || resp.Receipt.PointsSpent != 20 {
+		t.Fatalf("unexpected receipt %+v", resp.Receipt)
+	}
+	if resp.Receipt.Price == nil || resp.Receipt.Price.Formatted != "2,00 zł" {
+		t.Fatalf("unexpected receipt price %+v", resp.Receipt.Price)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestHandleUpdatePixelWritesInChunks(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.purchaseChunkSize = 2
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "user@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "ABCD-EFGH-IJKL-MNOP", 20); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "ABCD-EFGH-IJKL-MNOP"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+
+		body := `{"pixels":[
+			{"id":1,"status":"taken","color":"#ffffff","url":"https://example.com"},
+			{"id":-1,"status":"taken","color":"#ffffff","url":"https://example.com"},
+			{"id":2,"status":"taken","color":"#ffffff","url":"https://example.com"},
+			{"id":3,"status":"taken","color":"#ffffff","url":"https://example.com"},
+			{"id":4,"status":"taken","color":"#ffffff","url":"https://example.com"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+
+		var resp updatePixelResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if resp.User.Points != 0 || len(resp.Results) != 5 {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+		want := []struct {
+			id    int
+			taken bool
+			error string
+		}{
+			{1, true, ""},
+			{-1, false, "invalid pixel id"},
+			{2, true, ""},
+			{3, false, "brak wystarczającej"},
+			{4, false, "pixel not found"},
+		}
+		for i, result := range resp.Results {
+			if result.ID != want[i].id || (result.Pixel != nil) != want[i].taken || !strings.HasPrefix(result.Error, want[i].error) {
+				t.Fatalf("result %d: unexpected %+v", i, result)
+			}
+		}
+
+		owned, err := store.GetPixelsByOwner(ctx, user.ID)
+		if err != nil || len(owned) != 2 {
+			t.Fatalf("expected 2 owned pixels, got %d (err=%v)", len(owned), err)
+		}
+	})
+}
+
+func TestHandleUpdatePixelRejectsOversizedBody(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	server.purchaseMaxBytes = 64
+
+	body := `{"pixels":[` + strings.Repeat(`{"id":1,"status":"free"},`, 10) + `{"id":1,"status":"free"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "payload_too_large") {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+}
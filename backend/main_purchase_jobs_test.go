@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/jobs"
+)
+
+func TestLargePurchaseRunsAsJob(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	mailer := &noticeMailer{}
+	server.mailer = mailer
+	server.asyncPurchaseThreshold = 2
+	server.purchaseJobs = jobs.NewQueue(1, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		server.purchaseJobs.Wait()
+	})
+	server.purchaseJobs.Start(ctx)
+
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "JOBS-JOBS-JOBS-JOB1", 100); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "JOBS-JOBS-JOBS-JOB1"); err != nil {
+		t.Fatalf("redeem activation code: %v", err)
+	}
+
+	do := func(handler func(*gin.Context), method, target, body, session string, params gin.Params) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: session})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req, Params: params})
+		return w
+	}
+
+	if w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", `{"pixels":[{"id":2,"status":"taken","color":"#000000","url":"https://example.com"}]}`, sessionID, nil); w.Code != http.StatusOK {
+		t.Fatalf("small purchases stay synchronous, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", `{"pixels":[
+		{"id":3,"status":"taken","color":"#ffffff","url":"https://example.com"},
+		{"id":10,"status":"taken","color":"#ffffff","url":"https://example.com"}]}`, sessionID, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var queued struct {
+		Job       jobs.Task `json:"job"`
+		StatusURL string    `json:"status_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &queued); err != nil || queued.Job.ID == "" || queued.StatusURL != "/api/jobs/"+queued.Job.ID {
+		t.Fatalf("unexpected 202 body %s", w.Body.String())
+	}
+
+	params := gin.Params{{Key: "id", Value: queued.Job.ID}}
+	var polled struct {
+		Job struct {
+			Status string `json:"status"`
+			Result struct {
+				Receipt struct {
+					Pixels int `json:"pixels"`
+				} `json:"receipt"`
+				Results []PixelUpdateResult `json:"results"`
+			} `json:"result"`
+		} `json:"job"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for polled.Job.Status != jobs.TaskSucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish, last status %q", polled.Job.Status)
+		}
+		time.Sleep(time.Millisecond)
+		w := do(server.handleJob, http.MethodGet, "/api/jobs/"+queued.Job.ID, "", sessionID, params)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected job status %d: %s", w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &polled); err != nil {
+			t.Fatalf("decode job: %v", err)
+		}
+	}
+	if polled.Job.Result.Receipt.Pixels != 1 || len(polled.Job.Result.Results) != 2 || polled.Job.Result.Results[1].Error != "pixel not found" {
+		t.Fatalf("unexpected job result %+v", polled.Job.Result)
+	}
+	if len(mailer.notices) != 1 || mailer.recipients[0] != "admin@example.com" || mailer.notices[0].Subject != "Zakup pikseli zakończony" {
+		t.Fatalf("unexpected notices %+v to %v", mailer.notices, mailer.recipients)
+	}
+
+	other, err := store.CreateUser(ctx, "other@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	otherSession, err := server.sessions.Create(other.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if w := do(server.handleJob, http.MethodGet, "/api/jobs/"+queued.Job.ID, "", otherSession, params); w.Code != http.StatusNotFound {
+		t.Fatalf("jobs must only be visible to their owner, got %d", w.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/fcm"
+)
+
+type fakePush struct {
+	mu   sync.Mutex
+	sent map[string][]fcm.Message
+	gone map[string]bool
+}
+
+func (f *fakePush) Send(_ context.Context, token string, msg fcm.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.gone[token] {
+		return fcm.ErrUnregistered
+	}
+	if f.sent == nil {
+		f.sent = make(map[string][]fcm.Message)
+	}
+	f.sent[token] = append(f.sent[token], msg)
+	return nil
+}
+
+func TestPushDevicesReceiveNotifications(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	push := &fakePush{gone: map[string]bool{"stale-token": true}}
+
+	register := func(method, body string) int {
+		req := httptest.NewRequest(method, "/api/account/devices/push", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		c := &gin.Context{Writer: w, Request: req}
+		if method == http.MethodDelete {
+			server.handleDeletePushDevice(c)
+		} else {
+			server.handleRegisterPushDevice(c)
+		}
+		return w.Code
+	}
+	if code := register(http.MethodPost, `{"token":"phone-token","platform":"android"}`); code != http.StatusNotFound {
+		t.Fatalf("expected registration to be unavailable without FCM, got %d", code)
+	}
+	server.push = push
+	if code := register(http.MethodPost, `{"token":"phone-token","platform":"symbian"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown platform to be rejected, got %d", code)
+	}
+	for _, body := range []string{`{"token":"phone-token","platform":"android"}`, `{"token":"stale-token","platform":"ios"}`, `{"token":"tablet-token","platform":"ios"}`} {
+		if code := register(http.MethodPost, body); code != http.StatusOK {
+			t.Fatalf("register %s: got %d", body, code)
+		}
+	}
+	if code := register(http.MethodDelete, `{"token":"tablet-token"}`); code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", code)
+	}
+
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("get admin: %v", err)
+	}
+	server.notifyPush(ctx, admin.ID, fcm.Message{Title: "Zakup pikseli", Body: "Kupione piksele: 1"})
+
+	if len(push.sent) != 1 || len(push.sent["phone-token"]) != 1 || push.sent["phone-token"][0].Title != "Zakup pikseli" {
+		t.Fatalf("expected one notification on the registered phone, got %+v", push.sent)
+	}
+	devices, err := store.ListPushDevices(ctx, admin.ID)
+	if err != nil {
+		t.Fatalf("list devices: %v", err)
+	}
+	if len(devices) != 1 || devices[0].Token != "phone-token" {
+		t.Fatalf("expected the unregistered token to be forgotten, got %+v", devices)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+// queryBudget caps what one handler invocation may ask of the database.
+type queryBudget struct {
+	statements   int
+	transactions int
+}
+
+// expectQueryBudget runs handler with a request context that records every statement and
+// transaction the store issues, whichever SQL driver backs it, and fails the test when budget is
+// exceeded. It returns the response and the number of statements. Budgets that do not grow with
+// the number of pixels catch per-pixel queries or transactions creeping back in after a refactor.
+func expectQueryBudget(t *testing.T, name string, budget queryBudget, handler gin.HandlerFunc, req *http.Request) (*httptest.ResponseRecorder, int) {
+	t.Helper()
+	ctx, stats := sqltrace.WithStats(req.Context())
+	w := httptest.NewRecorder()
+	handler(&gin.Context{Writer: w, Request: req.WithContext(ctx)})
+	statements, _ := stats.Statements()
+	if statements > budget.statements || stats.Transactions() > budget.transactions {
+		summary, slowest := stats.Summary()
+		t.Fatalf("%s exceeded its budget of %d statements and %d transactions: %s, slowest %q", name, budget.statements, budget.transactions, summary, slowest)
+	}
+	return w, statements
+}
+
+func TestHandlersStayWithinQueryBudget(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "budget@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "BUDG-ETBU-DGET-BUDG", 10000); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "BUDG-ETBU-DGET-BUDG"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		for id := 4; id <= 100; id++ {
+			if err := store.InsertPixel(ctx, storage.Pixel{ID: id, Status: "free"}); err != nil {
+				t.Fatalf("insert pixel %d: %v", id, err)
+			}
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		request := func(method, target string, body []byte) *http.Request {
+			req := httptest.NewRequest(method, target, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			return req
+		}
+
+		// A purchase runs in one transaction and the same statements for 1 pixel as for 50.
+		var purchaseStatements []int
+		for _, ids := range [][2]int{{100, 100}, {1, 50}} {
+			var pixels []PixelUpdate
+			for id := ids[0]; id <= ids[1]; id++ {
+				pixels = append(pixels, PixelUpdate{ID: id, Status: "taken", Color: "#123456", URL: "https://example.com/"})
+			}
+			count := len(pixels)
+			body, _ := json.Marshal(UpdatePixelRequest{Pixels: pixels})
+			w, statements := expectQueryBudget(t, "purchase", queryBudget{statements: 20, transactions: 1}, server.handleUpdatePixel, request(http.MethodPost, "/api/pixels", body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("purchase of %d pixels: unexpected status %d: %s", count, w.Code, w.Body.String())
+			}
+			purchaseStatements = append(purchaseStatements, statements)
+		}
+		if purchaseStatements[0] != purchaseStatements[1] {
+			t.Fatalf("purchase of 1 pixel took %d statements, of 50 pixels %d", purchaseStatements[0], purchaseStatements[1])
+		}
+
+		// Reads cost the same with 51 owned pixels as with none.
+		reads := []struct {
+			name    string
+			handler gin.HandlerFunc
+			target  string
+		}{
+			{"grid", server.handleGetPixels, "/api/pixels"},
+			{"region", server.handleGetPixelRegion, "/api/pixels/region?x=0&y=0&w=60&h=2"},
+			{"account", server.handleAccount, "/api/account"},
+			{"stats", server.handleCanvasStats, "/api/stats"},
+		}
+		for _, read := range reads {
+			if w, _ := expectQueryBudget(t, read.name, queryBudget{statements: 5}, read.handler, request(http.MethodGet, read.target, nil)); w.Code != http.StatusOK {
+				t.Fatalf("%s: unexpected status %d: %s", read.name, w.Code, w.Body.String())
+			}
+		}
+
+		// So does releasing them.
+		var releaseStatements []int
+		for _, ids := range [][]int{{100}, {1, 2, 3, 4, 5, 6, 7, 8, 9, 10}} {
+			body, _ := json.Marshal(map[string][]int{"pixel_ids": ids})
+			w, statements := expectQueryBudget(t, "release", queryBudget{statements: 10, transactions: 1}, server.handleReleasePixels, request(http.MethodPost, "/api/account/pixels/release", body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("release of %d pixels: unexpected status %d: %s", len(ids), w.Code, w.Body.String())
+			}
+			releaseStatements = append(releaseStatements, statements)
+		}
+		if releaseStatements[0] != releaseStatements[1] {
+			t.Fatalf("release of 1 pixel took %d statements, of 10 pixels %d", releaseStatements[0], releaseStatements[1])
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func setReadOnly(t *testing.T, server *Server, sessionID, body string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/read-only", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handlePutReadOnly(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadOnlyModeBlocksWritesOnly(t *testing.T) {
+	server, _, sessionID := newAdminTestServer(t)
+	setReadOnly(t, server, sessionID, `{"enabled":true,"message":"Migracja bazy danych"}`)
+
+	buyReq := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewBufferString(`{"pixels":[{"id":1,"status":"taken","color":"#123456","url":"https://example.com"}]}`))
+	buyReq.Header.Set("Content-Type", "application/json")
+	buyReq.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handleUpdatePixel(&gin.Context{Writer: w, Request: buyReq})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 for purchase, got %d", w.Code)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["code"] != "read_only" || resp["error"] != "Migracja bazy danych" {
+		t.Fatalf("unexpected read-only response %v", resp)
+	}
+
+	resetReq := httptest.NewRequest(http.MethodPost, "/api/password-reset/confirm", bytes.NewBufferString(`{"token":"x","password":"secret123"}`))
+	resetReq.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	server.handlePasswordResetConfirm(&gin.Context{Writer: w, Request: resetReq})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503 for password change, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleGetPixels(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/pixels", nil)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected reads to keep working, got %d", w.Code)
+	}
+
+	setReadOnly(t, server, sessionID, `{"enabled":false}`)
+	if state := server.readOnlyStatus(buyReq.Context()); state != nil {
+		t.Fatalf("expected read-only mode to be disabled, got %+v", state)
+	}
+
+	server.readOnly = true
+	if state := server.readOnlyStatus(buyReq.Context()); state == nil || state.Source != "config" {
+		t.Fatalf("expected read-only mode from config, got %+v", state)
+	}
+}
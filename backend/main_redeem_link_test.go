@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestRedeemLinkHandoffFlow(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.redeemHandoffs = NewRedeemHandoffManager()
+
+	user, err := store.CreateUser(context.Background(), "scanner@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := store.CreateActivationCode(context.Background(), "QRQR-CODE-TEST-0001", 15); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+
+	// Anonymous visitor scans the QR code.
+	linkReq := httptest.NewRequest(http.MethodGet, "/redeem?code=qrqr-code-test-0001", nil)
+	linkW := httptest.NewRecorder()
+	server.handleRedeemLink(&gin.Context{Writer: linkW, Request: linkReq})
+
+	var handoffCookie *http.Cookie
+	for _, cookie := range linkW.Result().Cookies() {
+		if cookie.Name == redeemHandoffCookieName {
+			handoffCookie = cookie
+		}
+	}
+	if handoffCookie == nil || handoffCookie.Value == "" {
+		t.Fatalf("expected redeem handoff cookie to be set")
+	}
+
+	// After logging in, the SPA asks for the pending code.
+	pendingReq := httptest.NewRequest(http.MethodGet, "/api/activation-codes/pending", nil)
+	pendingReq.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	pendingReq.AddCookie(handoffCookie)
+	pendingW := httptest.NewRecorder()
+	server.handlePendingActivationCode(&gin.Context{Writer: pendingW, Request: pendingReq})
+	if pendingW.Code != http.StatusOK {
+		t.Fatalf("expected pending status 200, got %d: %s", pendingW.Code, pendingW.Body.String())
+	}
+	var pending struct {
+		Code  string `json:"code"`
+		Value int64  `json:"value"`
+	}
+	if err := json.Unmarshal(pendingW.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("unmarshal pending: %v", err)
+	}
+	if pending.Code != "QRQR-CODE-TEST-0001" || pending.Value != 15 {
+		t.Fatalf("unexpected pending payload: %+v", pending)
+	}
+
+	// Confirmation redeems the handed-off code without retyping it.
+	redeemReq := httptest.NewRequest(http.MethodPost, "/api/activation-codes/redeem", bytes.NewBufferString(`{"turnstile_token":"`+testTurnstileToken+`"}`))
+	redeemReq.Header.Set("Content-Type", "application/json")
+	redeemReq.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	redeemReq.AddCookie(handoffCookie)
+	redeemW := httptest.NewRecorder()
+	server.handleRedeemActivationCode(&gin.Context{Writer: redeemW, Request: redeemReq})
+	if redeemW.Code != http.StatusOK {
+		t.Fatalf("expected redeem status 200, got %d: %s", redeemW.Code, redeemW.Body.String())
+	}
+
+	if _, ok := server.redeemHandoffs.Claim(handoffCookie.Value, user.ID); ok {
+		t.Fatalf("expected handoff to be consumed after redemption")
+	}
+}
+
+func TestPendingActivationCodeLookupIsGuarded(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.redeemHandoffs = NewRedeemHandoffManager()
+	server.redemptionGuard = NewRedemptionGuard()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.redemptionGuard.now = func() time.Time { return now }
+
+	user, err := store.CreateUser(context.Background(), "guesser@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := store.CreateActivationCode(context.Background(), "QRQR-CODE-TEST-0001", 15); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+
+	// lookup stashes code through /redeem and asks for it back as the SPA does.
+	lookup := func(code string) *httptest.ResponseRecorder {
+		t.Helper()
+		linkW := httptest.NewRecorder()
+		server.handleRedeemLink(&gin.Context{Writer: linkW, Request: httptest.NewRequest(http.MethodGet, "/redeem?code="+code, nil)})
+		req := httptest.NewRequest(http.MethodGet, "/api/activation-codes/pending", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		for _, cookie := range linkW.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		server.handlePendingActivationCode(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	for i := 1; i < redemptionCaptchaThreshold; i++ {
+		if w := lookup("QRQR-CODE-TEST-000" + string(rune('1'+i))); w.Code != http.StatusGone {
+			t.Fatalf("expected a missing code to be reported gone, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := lookup("QRQR-CODE-TEST-0009"); w.Code != http.StatusGone || !bytes.Contains(w.Body.Bytes(), []byte(`"captcha":"challenge"`)) {
+		t.Fatalf("expected the misses to escalate to a challenge, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, guesses := server.redemptionGuard.Snapshot(); guesses != redemptionCaptchaThreshold {
+		t.Fatalf("expected every miss to count as a guess, got %d", guesses)
+	}
+
+	// Further misses make the source wait, and once it may try again a challenged source no
+	// longer learns the value of a code from the lookup.
+	if w := lookup("qrqr-code-test-0001"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the lookup to wait out the backoff, got %d: %s", w.Code, w.Body.String())
+	}
+	now = now.Add(redemptionBackoffBase + time.Second)
+	w := lookup("qrqr-code-test-0001")
+	var pending map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &pending); err != nil {
+		t.Fatalf("unmarshal pending: %v", err)
+	}
+	if w.Code != http.StatusOK || pending["code"] != "QRQR-CODE-TEST-0001" || pending["value"] != nil || pending["captcha"] != "challenge" {
+		t.Fatalf("unexpected pending response %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRedeemHandoffBoundToFirstUser(t *testing.T) {
+	manager := NewRedeemHandoffManager()
+	id, err := manager.Create("ABCD-EFGH-IJKL-MNOP", 0, "203.0.113.1")
+	if err != nil {
+		t.Fatalf("create handoff: %v", err)
+	}
+	if _, ok := manager.Claim(id, 1); !ok {
+		t.Fatalf("expected first claim to succeed")
+	}
+	if _, ok := manager.Claim(id, 2); ok {
+		t.Fatalf("expected claim by another user to fail")
+	}
+}
+
+func TestRedeemHandoffsAreCappedAndPruned(t *testing.T) {
+	manager := NewRedeemHandoffManager()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+
+	for i := 0; i < redeemHandoffMaxPerSource; i++ {
+		if _, err := manager.Create("ABCD-EFGH-IJKL-MNOP", 0, "203.0.113.1"); err != nil {
+			t.Fatalf("create handoff %d: %v", i, err)
+		}
+	}
+	if _, err := manager.Create("ABCD-EFGH-IJKL-MNOP", 0, "203.0.113.1"); !errors.Is(err, errRedeemHandoffLimit) {
+		t.Fatalf("expected the source cap to reject another handoff, got %v", err)
+	}
+	if _, err := manager.Create("ABCD-EFGH-IJKL-MNOP", 0, "203.0.113.2"); err != nil {
+		t.Fatalf("expected another source to be unaffected: %v", err)
+	}
+
+	if removed := manager.Prune(); removed != 0 {
+		t.Fatalf("expected fresh handoffs to be kept, pruned %d", removed)
+	}
+	now = now.Add(redeemHandoffTTL + time.Second)
+	if removed := manager.Prune(); removed != redeemHandoffMaxPerSource+1 {
+		t.Fatalf("expected all handoffs to expire, pruned %d", removed)
+	}
+	if len(manager.perSource) != 0 {
+		t.Fatalf("expected source counts to be released, got %v", manager.perSource)
+	}
+	if _, err := manager.Create("ABCD-EFGH-IJKL-MNOP", 0, "203.0.113.1"); err != nil {
+		t.Fatalf("expected the source to create handoffs again: %v", err)
+	}
+}
+
+func TestRedeemLinkCapsHandoffsPerSourceWithoutDeviceCookie(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.redeemHandoffs = NewRedeemHandoffManager()
+
+	open := func(remoteAddr string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/redeem?code=qrqr-code-test-0001", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		server.handleRedeemLink(&gin.Context{Writer: w, Request: req})
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == redeemHandoffCookieName {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A client that never sends the device cookie back still counts as one source.
+	for i := 0; i < redeemHandoffMaxPerSource; i++ {
+		if !open("198.51.100.7:40000") {
+			t.Fatalf("expected handoff %d to be stashed", i)
+		}
+	}
+	if open("198.51.100.7:40001") {
+		t.Fatal("expected the source cap to stop stashing handoffs")
+	}
+	if !open("198.51.100.8:40000") {
+		t.Fatal("expected another source to be unaffected")
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestRedemptionGuardBlocksSequentialGuessing(t *testing.T) {
+	guard := NewRedemptionGuard()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	keys := redemptionSourceKeys("203.0.113.5", "", 7)
+
+	guesses := []string{"AAAA-AAAA-AAAA-AAA1", "AAAA-AAAA-AAAA-AAA2", "AAAA-AAAA-AAAA-AAA3"}
+	for _, code := range guesses {
+		guard.RecordFailure(keys, code)
+		now = now.Add(time.Minute)
+	}
+	verdict := guard.Check(keys)
+	if verdict.retryAfter != 0 {
+		t.Fatalf("did not expect a block after %d guesses, got %v", len(guesses), verdict.retryAfter)
+	}
+	if !verdict.requireChallenge {
+		t.Fatalf("expected captcha escalation after %d failures", len(guesses))
+	}
+
+	guard.RecordFailure(keys, "AAAA-AAAA-AAAA-AAA4")
+	now = now.Add(time.Minute)
+	guard.RecordFailure(keys, "AAAA-AAAA-AAAA-AAA5")
+	verdict = guard.Check(keys)
+	if verdict.retryAfter != redemptionBlockDuration {
+		t.Fatalf("expected block for %v, got %v", redemptionBlockDuration, verdict.retryAfter)
+	}
+
+	alerts, total := guard.Snapshot()
+	if total != 5 {
+		t.Fatalf("expected 5 counted guesses, got %d", total)
+	}
+	if len(alerts) != 2 || !strings.Contains(alerts[0].Reason, "sequential") {
+		t.Fatalf("expected sequential alerts for ip and user, got %+v", alerts)
+	}
+
+	now = now.Add(redemptionWindow + time.Minute)
+	if verdict := guard.Check(keys); verdict.retryAfter != 0 || verdict.requireChallenge {
+		t.Fatalf("expected block and escalation to expire, got %+v", verdict)
+	}
+}
+
+func TestRedemptionGuardExponentialBackoff(t *testing.T) {
+	guard := NewRedemptionGuard()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	keys := redemptionSourceKeys("203.0.113.5", "", 7)
+
+	// Guesses far apart in the code space, so only the backoff applies.
+	guesses := []string{"QWER-TYUI-OPAS-DFGH", "ZXCV-BNMQ-WERT-YUIO", "POIU-YTRE-WQLK-JHGF", "LKJH-GFDS-AMNB-VCXZ", "ASDF-GHJK-LZXC-VBNM"}
+	want := []time.Duration{0, 0, 2 * time.Second, 4 * time.Second, 8 * time.Second}
+	for i, code := range guesses {
+		guard.RecordFailure(keys, code)
+		if got := guard.Check(keys).retryAfter; got != want[i] {
+			t.Fatalf("after failure %d: expected wait %v, got %v", i+1, want[i], got)
+		}
+		now = now.Add(want[i])
+	}
+
+	guard.RecordSuccess(keys)
+	if got := guard.Check(keys).retryAfter; got != 0 {
+		t.Fatalf("expected successful redemption to reset backoff, got %v", got)
+	}
+	if got := redemptionBackoff(20); got != redemptionBackoffMax {
+		t.Fatalf("expected backoff to be capped at %v, got %v", redemptionBackoffMax, got)
+	}
+}
+
+func TestRedemptionGuardForgetsIdleSources(t *testing.T) {
+	guard := NewRedemptionGuard()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	keys := redemptionSourceKeys("203.0.113.5", "device-1", 0)
+
+	if guard.Check(keys); len(guard.sources) != 0 {
+		t.Fatalf("expected a check not to track sources, got %d", len(guard.sources))
+	}
+	for i := 0; i < redemptionBlockThreshold; i++ {
+		guard.RecordFailure(keys, "AAAA-BBBB-CCCC-DDDD")
+	}
+	if len(guard.sources) != 2 {
+		t.Fatalf("expected 2 tracked sources, got %d", len(guard.sources))
+	}
+
+	now = now.Add(redemptionWindow + redemptionBlockDuration)
+	if verdict := guard.Check(keys); verdict.retryAfter != 0 || verdict.requireChallenge {
+		t.Fatalf("expected an idle source to be allowed, got %+v", verdict)
+	}
+	if len(guard.sources) != 0 {
+		t.Fatalf("expected idle sources to be removed, %d left", len(guard.sources))
+	}
+}
+
+func TestRedemptionGuardAlertsOnBulkRedemption(t *testing.T) {
+	guard := NewRedemptionGuard()
+	keys := redemptionSourceKeys("203.0.113.5", "device-1", 0)
+	for i := 0; i < redemptionSuccessAlertLimit; i++ {
+		guard.RecordSuccess(keys)
+	}
+
+	alerts, _ := guard.Snapshot()
+	if len(alerts) != 2 {
+		t.Fatalf("expected alerts for ip and device, got %+v", alerts)
+	}
+	if !guard.Check(keys).requireChallenge {
+		t.Fatalf("expected captcha escalation after bulk redemption")
+	}
+}
+
+func TestHandleRedeemActivationCode_EscalatesAndBlocks(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	server.redemptionGuard = NewRedemptionGuard()
+	now := time.Now()
+	server.redemptionGuard.now = func() time.Time { return now }
+	var action string
+	server.turnstileVerify = func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		return turnstileResponse{Success: true, Action: action}, nil
+	}
+
+	user, err := store.CreateUser(context.Background(), "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	sessionID, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := store.CreateActivationCode(context.Background(), "ZZZZ-ZZZZ-ZZZZ-ZZZZ", 10); err != nil {
+		t.Fatalf("create activation code: %v", err)
+	}
+
+	redeem := func(code string) *httptest.ResponseRecorder {
+		body := bytes.NewBufferString(`{"code":"` + code + `","turnstile_token":"` + testTurnstileToken + `"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/activation-codes/redeem", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		server.handleRedeemActivationCode(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	var last *httptest.ResponseRecorder
+	for _, code := range []string{"QWER-TYUI-OPAS-DFGH", "ZXCV-BNMQ-WERT-YUIO", "POIU-YTRE-WQLK-JHGF"} {
+		last = redeem(code)
+		if last.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for invalid code, got %d", last.Code)
+		}
+	}
+	if w := redeem("ZZZZ-ZZZZ-ZZZZ-ZZZZ"); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3" {
+		t.Fatalf("expected backoff after repeated invalid codes, got %d (Retry-After %q)", w.Code, w.Header().Get("Retry-After"))
+	}
+	now = now.Add(time.Minute)
+	var resp map[string]string
+	if err := json.Unmarshal(last.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["captcha"] != "challenge" {
+		t.Fatalf("expected captcha escalation hint, got %v", resp)
+	}
+
+	if w := redeem("ZZZZ-ZZZZ-ZZZZ-ZZZZ"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected escalated request without challenge action to fail, got %d", w.Code)
+	}
+	action = redeemChallengeAction
+	if w := redeem("ZZZZ-ZZZZ-ZZZZ-ZZZZ"); w.Code != http.StatusOK {
+		t.Fatalf("expected redemption with challenge token to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for i := 0; i < redemptionSequentialLimit+1; i++ {
+		if w := redeem("MNBV-CXZL-KJHG-FDS" + string(rune('A'+i))); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 for invalid code, got %d", w.Code)
+		}
+		now = now.Add(time.Minute)
+	}
+	last = redeem("ZZZZ-ZZZZ-ZZZZ-ZZZZ")
+	if last.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 once blocked, got %d", last.Code)
+	}
+	if last.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header on blocked response")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestAdminRefundFreesPixelsAndRestoresPoints(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.adminEmails = newAdminSet([]string{"admin@example.com"})
+		ctx := context.Background()
+		sessions := make(map[string]string)
+		users := make(map[string]storage.User)
+		for _, address := range []string{"admin@example.com", "owner@example.com"} {
+			user, err := store.CreateUser(ctx, address, "hash")
+			if err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+			users[address] = user
+			if sessions[address], err = server.sessions.Create(user.ID); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+		}
+		owner := users["owner@example.com"]
+		if err := store.CreateActivationCode(ctx, "BACK-BACK-BACK-BACK", 100); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, owner.ID, "BACK-BACK-BACK-BACK"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		call := func(handler gin.HandlerFunc, method, target, address string, body any) *httptest.ResponseRecorder {
+			payload, _ := json.Marshal(body)
+			req := httptest.NewRequest(method, target, bytes.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessions[address]})
+			w := httptest.NewRecorder()
+			handler(&gin.Context{Writer: w, Request: req})
+			return w
+		}
+		pixel := func(id int) gin.H {
+			return gin.H{"id": id, "status": "taken", "color": "#123456", "url": "https://example.com"}
+		}
+		if w := call(server.handleUpdatePixel, http.MethodPost, "/api/pixels", "owner@example.com", gin.H{"pixels": []gin.H{pixel(1), pixel(2)}}); w.Code != http.StatusOK {
+			t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+		}
+		refund := func(address string, body gin.H) *httptest.ResponseRecorder {
+			return call(server.handleCreateRefund, http.MethodPost, "/api/admin/refunds", address, body)
+		}
+
+		if w := refund("owner@example.com", gin.H{"user_id": owner.ID, "pixel_ids": []int{1}, "reason": "self"}); w.Code != http.StatusForbidden {
+			t.Fatalf("expected non-admins to be refused, got %d", w.Code)
+		}
+		if w := refund("admin@example.com", gin.H{"user_id": owner.ID, "pixel_ids": []int{1}}); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected a missing reason to be rejected, got %d", w.Code)
+		}
+		if w := refund("admin@example.com", gin.H{"user_id": owner.ID, "pixel_ids": []int{3}, "reason": "chargeback"}); w.Code != http.StatusConflict {
+			t.Fatalf("expected a pixel the user does not own to conflict, got %d %s", w.Code, w.Body.String())
+		}
+
+		w := refund("admin@example.com", gin.H{"user_id": owner.ID, "pixel_ids": []int{1, 3}, "reason": "chargeback"})
+		var created struct {
+			Refund storage.PixelRefund `json:"refund"`
+			User   storage.User        `json:"user"`
+		}
+		if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil {
+			t.Fatalf("unexpected refund response %d: %s", w.Code, w.Body.String())
+		}
+		if len(created.Refund.PixelIDs) != 1 || created.Refund.PixelIDs[0] != 1 || created.Refund.Points != 10 || created.User.Points != 100-20+10 {
+			t.Fatalf("expected pixel 1 refunded in full, got %+v", created)
+		}
+
+		// An explicit amount replaces the price recorded for the pixel.
+		w = refund("admin@example.com", gin.H{"user_id": owner.ID, "pixel_ids": []int{2}, "reason": "moderation", "points": 3})
+		if w.Code != http.StatusCreated || json.Unmarshal(w.Body.Bytes(), &created) != nil || created.User.Points != 93 {
+			t.Fatalf("unexpected refund response %d: %s", w.Code, w.Body.String())
+		}
+		if owned, err := store.GetPixelsByOwner(ctx, owner.ID); err != nil || len(owned) != 0 {
+			t.Fatalf("expected the pixels to be freed, got %v %v", owned, err)
+		}
+
+		w = call(server.handleListRefunds, http.MethodGet, "/api/admin/refunds?user_id="+strconv.FormatInt(owner.ID, 10), "admin@example.com", nil)
+		var list struct {
+			Refunds []storage.PixelRefund `json:"refunds"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Refunds) != 2 || list.Refunds[0].Reason != "moderation" || list.Refunds[1].AdminID != users["admin@example.com"].ID {
+			t.Fatalf("unexpected refund list %s", w.Body.String())
+		}
+
+		entries, err := store.ListLedgerEntries(ctx, owner.ID)
+		if err != nil || len(entries) < 2 {
+			t.Fatalf("list ledger entries: %+v %v", entries, err)
+		}
+		moderation, chargeback := entries[0], entries[1]
+		if moderation.Kind != storage.LedgerAdminRefund || moderation.Delta != 3 || moderation.Reason != "moderation" || moderation.Reference != strconv.FormatInt(list.Refunds[0].ID, 10) {
+			t.Fatalf("expected the explicit refund in the ledger, got %+v", moderation)
+		}
+		if chargeback.Delta != 10 || chargeback.Reason != "chargeback" {
+			t.Fatalf("expected the chargeback in the ledger, got %+v", chargeback)
+		}
+	})
+}
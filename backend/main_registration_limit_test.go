@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+func TestRegistrationLimitPerIPAndDevice(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+	server.registrationLimiter = NewRegistrationLimiter(config.RegistrationLimits{PerIPPerDay: 3, PerDevicePerDay: 2, ChallengeAfter: 2})
+	now := time.Now()
+	server.registrationLimiter.now = func() time.Time { return now }
+	var action string
+	server.turnstileVerify = func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		return turnstileResponse{Success: true, Action: action}, nil
+	}
+
+	register := func(n int, ip, device string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"email":"bot%d@example.com","password":"secret","turnstile_token":"%s"}`, n, testTurnstileToken)
+		req := httptest.NewRequest(http.MethodPost, "/api/register", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		if device != "" {
+			req.AddCookie(&http.Cookie{Name: deviceCookieName, Value: device})
+		}
+		w := httptest.NewRecorder()
+		server.handleRegister(&gin.Context{Writer: w, Request: req})
+		return w
+	}
+
+	for n := 1; n <= 2; n++ {
+		if w := register(n, "203.0.113.7", "device-a"); w.Code != http.StatusCreated {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+	}
+	w := register(3, "198.51.100.1", "device-a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected device limit from another IP, got %d", w.Code)
+	}
+	if w := register(3, "203.0.113.7", "device-b"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected captcha escalation for a busy IP, got %d", w.Code)
+	}
+	action = registerChallengeAction
+	if w := register(3, "203.0.113.7", "device-b"); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status with challenge token %d: %s", w.Code, w.Body.String())
+	}
+	if w := register(4, "203.0.113.7", "device-c"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected IP limit, got %d", w.Code)
+	}
+
+	// Clients without the device cookie are challenged as soon as their IP has signed up before.
+	action = ""
+	if w := register(4, "198.51.100.1", ""); w.Code != http.StatusCreated {
+		t.Fatalf("unexpected status for first signup from a new IP %d: %s", w.Code, w.Body.String())
+	}
+	if w := register(5, "198.51.100.1", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected captcha escalation for cookieless client, got %d", w.Code)
+	}
+
+	now = now.Add(registrationWindow + time.Minute)
+	if w := register(5, "203.0.113.7", "device-a"); w.Code != http.StatusCreated {
+		t.Fatalf("expected the limits to reset after a day, got %d: %s", w.Code, w.Body.String())
+	}
+}
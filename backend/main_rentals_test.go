@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestPixelRentalIsWarnedAndReleased(t *testing.T) {
+	runStoreTests(t, func(t *testing.T, server *Server, store storage.Store) {
+		server.rentals = config.Rentals{Enabled: true, PointsPerDay: 3, MaxDays: 30, WarnBeforeHours: 72}
+		mailer := &noticeMailer{}
+		server.mailer = mailer
+		ctx := context.Background()
+		user, err := store.CreateUser(ctx, "renter@example.com", "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := store.CreateActivationCode(ctx, "RENT-RENT-RENT-RENT", 20); err != nil {
+			t.Fatalf("create activation code: %v", err)
+		}
+		if _, _, err := store.RedeemActivationCode(ctx, user.ID, "RENT-RENT-RENT-RENT"); err != nil {
+			t.Fatalf("redeem activation code: %v", err)
+		}
+		sessionID, err := server.sessions.Create(user.ID)
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		rent := func(days int) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(gin.H{
+				"pixels":      []gin.H{{"id": 1, "status": "taken", "color": "#123456", "url": "https://example.com"}},
+				"rental_days": days,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/pixels", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			w := httptest.NewRecorder()
+			server.handleUpdatePixel(&gin.Context{Writer: w, Request: req})
+			return w
+		}
+
+		if w := rent(31); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected a rental above maxDays to be rejected, got %d", w.Code)
+		}
+		w := rent(2)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		var resp updatePixelResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.User.Points != 14 {
+			t.Fatalf("expected 2 days at 3 points to be charged, got %s (err %v)", w.Body.String(), err)
+		}
+		owned, err := store.GetPixelsByOwner(ctx, user.ID)
+		if err != nil || len(owned) != 1 || owned[0].ExpiresAt == nil {
+			t.Fatalf("expected one rented pixel, got %+v (err %v)", owned, err)
+		}
+		if left := time.Until(*owned[0].ExpiresAt); left < 47*time.Hour || left > 48*time.Hour {
+			t.Fatalf("unexpected rental end %s", owned[0].ExpiresAt)
+		}
+
+		// Ending within the warning window, the owner is emailed once.
+		for i := 0; i < 2; i++ {
+			if err := server.expirePixelRentals(ctx); err != nil {
+				t.Fatalf("expirePixelRentals() error = %v", err)
+			}
+		}
+		if len(mailer.notices) != 1 || mailer.recipients[0] != "renter@example.com" {
+			t.Fatalf("expected one expiry warning, got %+v", mailer.notices)
+		}
+
+		past := time.Now().Add(-time.Minute)
+		expired := storage.Pixel{ID: 2, Status: "taken", Color: "#654321", URL: "https://example.com", ExpiresAt: &past}
+		if _, _, err := store.UpdatePixelsForUserWithCost(ctx, user.ID, []storage.Pixel{expired}, 0, ""); err != nil {
+			t.Fatalf("rent expired pixel: %v", err)
+		}
+		if err := server.expirePixelRentals(ctx); err != nil {
+			t.Fatalf("expirePixelRentals() error = %v", err)
+		}
+		owned, err = store.GetPixelsByOwner(ctx, user.ID)
+		if err != nil || len(owned) != 1 || owned[0].ID != 1 {
+			t.Fatalf("expected only the running rental to remain, got %+v (err %v)", owned, err)
+		}
+		history, err := store.ListPixelHistory(ctx, 2, 1)
+		if err != nil || len(history) != 1 || history[0].Status != "free" || history[0].PreviousOwnerID == nil || *history[0].PreviousOwnerID != user.ID {
+			t.Fatalf("expected the release in the pixel history, got %+v (err %v)", history, err)
+		}
+		if len(mailer.notices) != 1 {
+			t.Fatalf("an expired pixel must not be warned about, got %d notices", len(mailer.notices))
+		}
+	})
+}
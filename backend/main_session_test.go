@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestSessionManagerRotate(t *testing.T) {
+	sessions := NewSessionManager()
+	old, err := sessions.Create(7)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	other, _ := sessions.Create(7)
+
+	rotated, err := sessions.Rotate(old, 7)
+	if err != nil || rotated == old {
+		t.Fatalf("Rotate() = %q, %v", rotated, err)
+	}
+	if _, ok := sessions.Get(old); ok {
+		t.Fatal("old session id still valid after rotation")
+	}
+	if userID, ok := sessions.Get(rotated); !ok || userID != 7 {
+		t.Fatalf("rotated session resolves to %d, %v", userID, ok)
+	}
+
+	if removed := sessions.DeleteUser(7, rotated); removed != 1 {
+		t.Fatalf("DeleteUser() removed %d sessions, want 1", removed)
+	}
+	if _, ok := sessions.Get(other); ok {
+		t.Fatal("other session of the user should be invalidated")
+	}
+	if _, ok := sessions.Get(rotated); !ok {
+		t.Fatal("kept session should stay valid")
+	}
+}
+
+func sessionCookieFrom(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie.Value
+		}
+	}
+	t.Fatal("response did not set a session cookie")
+	return ""
+}
+
+func TestSessionManagerIdleTimeout(t *testing.T) {
+	sessions := NewSessionManager()
+	sessions.idleTimeout = time.Hour
+	active, _ := sessions.Create(7)
+	idle, _ := sessions.Create(7)
+	stale, _ := sessions.Create(8)
+	sessions.sessions[idle].lastSeen = time.Now().Add(-2 * time.Hour)
+	sessions.sessions[stale].lastSeen = time.Now().Add(-2 * time.Hour)
+
+	listed := sessions.List(7, active)
+	if len(listed) != 1 || !listed[0].Current || listed[0].IdleExpiresAt == nil {
+		t.Fatalf("List() = %+v, want only the active session", listed)
+	}
+	if _, ok := sessions.Get(idle); ok {
+		t.Fatal("a session idle past the timeout must end")
+	}
+	if removed := sessions.PruneIdle(time.Now()); removed != 1 {
+		t.Fatalf("PruneIdle() removed %d sessions, want 1", removed)
+	}
+
+	// Using a session keeps it alive.
+	sessions.sessions[active].lastSeen = time.Now().Add(-59 * time.Minute)
+	if _, ok := sessions.Get(active); !ok {
+		t.Fatal("an active session must stay valid")
+	}
+	if seen := sessions.sessions[active].lastSeen; time.Since(seen) > time.Minute {
+		t.Fatalf("Get() did not record the activity, last seen %s", seen)
+	}
+}
+
+func TestLoginReplacesPresentedSessionCookie(t *testing.T) {
+	server, store, _ := newAdminTestServer(t)
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, "user@example.com", testLoginPasswordHash)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := store.MarkUserVerified(ctx, user.ID); err != nil {
+		t.Fatalf("verify user: %v", err)
+	}
+
+	fixated, err := server.sessions.Create(user.ID)
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	body := fmt.Sprintf(`{"email":"user@example.com","password":"%s","turnstile_token":"%s"}`, testLoginPassword, testTurnstileToken)
+	req := httptest.NewRequest(http.MethodPost, "/api/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: fixated})
+	w := httptest.NewRecorder()
+	server.handleLogin(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected login status %d: %s", w.Code, w.Body.String())
+	}
+
+	issued := sessionCookieFrom(t, w)
+	if issued == fixated {
+		t.Fatal("login reused the session id presented by the client")
+	}
+	if _, ok := server.sessions.Get(fixated); ok {
+		t.Fatal("presented session id still valid after login")
+	}
+	if userID, ok := server.sessions.Get(issued); !ok || userID != user.ID {
+		t.Fatalf("new session resolves to %d, %v", userID, ok)
+	}
+}
+
+func TestPasswordResetRotatesSessionAndEndsOthers(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	otherDevice, _ := server.sessions.Create(admin.ID)
+	if _, err := store.CreatePasswordResetToken(ctx, "reset-token", admin.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("create reset token: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"token":"reset-token","password":"new-secret","confirm_password":"new-secret","turnstile_token":"%s"}`, testTurnstileToken)
+	req := httptest.NewRequest(http.MethodPost, "/api/password-reset/confirm", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	w := httptest.NewRecorder()
+	server.handlePasswordResetConfirm(&gin.Context{Writer: w, Request: req})
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected confirm status %d: %s", w.Code, w.Body.String())
+	}
+
+	issued := sessionCookieFrom(t, w)
+	for _, stale := range []string{sessionID, otherDevice} {
+		if _, ok := server.sessions.Get(stale); ok {
+			t.Fatalf("session %s still valid after password change", stale)
+		}
+	}
+	if userID, ok := server.sessions.Get(issued); !ok || userID != admin.ID {
+		t.Fatalf("new session resolves to %d, %v", userID, ok)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage/sqlite"
+)
+
+func newSignedLinkTestServer(t *testing.T) (*Server, *sqlite.Store) {
+	t.Helper()
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	store.SetSkipPixelSeed(true)
+	if err := store.EnsureSchema(context.Background()); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	server := &Server{
+		store:                 store,
+		sessions:              NewSessionManager(),
+		mailer:                &fakeMailer{},
+		verificationTokenTTL:  time.Hour,
+		passwordResetTokenTTL: time.Hour,
+		linkSigner:            newLinkSigner(strings.Repeat("k", 32)),
+	}
+	enableTurnstileForTest(server)
+	return server, store
+}
+
+func TestSignedVerificationLinkIsSingleUse(t *testing.T) {
+	server, store := newSignedLinkTestServer(t)
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	token, err := server.issueVerificationToken(ctx, user)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	if _, err := store.GetVerificationToken(ctx, token); err == nil {
+		t.Fatalf("signed links must not be stored")
+	}
+
+	verify := func(token string) int {
+		w := httptest.NewRecorder()
+		server.handleVerifyAccount(&gin.Context{Writer: w, Request: httptest.NewRequest(http.MethodGet, "/api/verify?token="+token, nil)})
+		return w.Code
+	}
+	tampered := strings.Replace(token, fmt.Sprintf(".%d.", user.ID), fmt.Sprintf(".%d.", user.ID+1), 1)
+	if code := verify(tampered); code != http.StatusBadRequest {
+		t.Fatalf("expected a tampered link to be rejected, got %d", code)
+	}
+	if code := verify(token); code != http.StatusOK {
+		t.Fatalf("expected the link to verify the account, got %d", code)
+	}
+	if code := verify(token); code != http.StatusBadRequest {
+		t.Fatalf("expected a used link to be rejected, got %d", code)
+	}
+	if verified, err := store.GetUserByID(ctx, user.ID); err != nil || !verified.IsVerified {
+		t.Fatalf("expected the user to be verified, got %+v (err %v)", verified, err)
+	}
+
+	expired, err := server.linkSigner.sign(linkPurposeVerify, user, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if code := verify(expired); code != http.StatusBadRequest {
+		t.Fatalf("expected an expired link to be rejected, got %d", code)
+	}
+}
+
+func TestSignedPasswordResetLinkDiesWithPassword(t *testing.T) {
+	server, store := newSignedLinkTestServer(t)
+	ctx := context.Background()
+	user, err := store.CreateUser(ctx, "user@example.com", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	first, err := server.issuePasswordResetToken(ctx, user)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	second, err := server.issuePasswordResetToken(ctx, user)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+
+	confirm := func(token string) int {
+		body := bytes.NewBufferString(fmt.Sprintf(`{"token":"%s","password":"new-secret","confirm_password":"new-secret","turnstile_token":"%s"}`, token, testTurnstileToken))
+		req := httptest.NewRequest(http.MethodPost, "/api/password-reset/confirm", body)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.handlePasswordResetConfirm(&gin.Context{Writer: w, Request: req})
+		return w.Code
+	}
+	if verifyToken, err := server.issueVerificationToken(ctx, user); err != nil || confirm(verifyToken) != http.StatusBadRequest {
+		t.Fatalf("a verification link must not reset the password (err %v)", err)
+	}
+	if code := confirm(first); code != http.StatusOK {
+		t.Fatalf("expected the reset to succeed, got %d", code)
+	}
+	if code := confirm(second); code != http.StatusBadRequest {
+		t.Fatalf("expected links issued before the change to stop working, got %d", code)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/storage/sqlite"
+)
+
+func TestStartupHooksSeedOnlyEmptyBoard(t *testing.T) {
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	prepareStore(t, store)
+	ctx := context.Background()
+
+	if hooks := startupHooks(config.StartupHooks{Seed: "none"}); len(hooks) != 0 {
+		t.Fatalf("expected no hooks, got %d", len(hooks))
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seed.json.gz")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create seed file: %v", err)
+	}
+	zw := gzip.NewWriter(file)
+	state := storage.PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: []storage.Pixel{
+		{ID: 1, Status: "free"},
+		{ID: 2, Status: "taken", Color: "#112233", URL: "https://example.com/seed", Title: "Seed", OwnerID: new(int64)},
+	}}
+	if err := state.WriteJSON(zw); err != nil {
+		t.Fatalf("write seed: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close seed file: %v", err)
+	}
+
+	hooks := startupHooks(config.StartupHooks{Seed: "file", SeedFile: path})
+	if err := runStartupHooks(ctx, store, hooks); err != nil {
+		t.Fatalf("run startup hooks: %v", err)
+	}
+	pixels, err := store.GetPixelsInRect(ctx, 1, 0, 2, 1)
+	if err != nil {
+		t.Fatalf("get pixels: %v", err)
+	}
+	if len(pixels) != 2 || pixels[0].Status != "free" || pixels[1].Status != "taken" || pixels[1].Title != "Seed" || pixels[1].OwnerID != nil {
+		t.Fatalf("unexpected seeded pixels %+v", pixels)
+	}
+
+	// The board is no longer empty, so seeding again changes nothing.
+	if _, err := store.UpdatePixel(ctx, storage.Pixel{ID: 2, Status: "taken", Color: "#445566", URL: "https://example.com/changed"}); err != nil {
+		t.Fatalf("update pixel: %v", err)
+	}
+	if err := runStartupHooks(ctx, store, startupHooks(config.StartupHooks{Seed: "demo"})); err != nil {
+		t.Fatalf("run startup hooks: %v", err)
+	}
+	stats, err := store.GetBusinessStats(ctx)
+	if err != nil {
+		t.Fatalf("get stats: %v", err)
+	}
+	if stats.PixelsTaken != 1 {
+		t.Fatalf("expected the demo seed to be skipped, got %d taken pixels", stats.PixelsTaken)
+	}
+
+	missing := startupHooks(config.StartupHooks{Seed: "file", SeedFile: filepath.Join(dir, "missing.json")})
+	if err := runStartupHooks(ctx, store, missing); err == nil {
+		t.Fatal("expected a missing seed file to fail")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+type noticeMailer struct {
+	fakeMailer
+	notices    []email.Notice
+	recipients []string
+	// fail, when set, is returned instead of sending.
+	fail error
+}
+
+func (m *noticeMailer) SendNotice(ctx context.Context, recipient string, notice email.Notice) error {
+	if m.fail != nil {
+		return m.fail
+	}
+	m.recipients = append(m.recipients, recipient)
+	m.notices = append(m.notices, notice)
+	return nil
+}
+
+func TestTakedownHidesRegionUntilResolved(t *testing.T) {
+	server, store, sessionID := newAdminTestServer(t)
+	mailer := &noticeMailer{}
+	server.mailer = mailer
+	ctx := context.Background()
+	admin, err := store.GetUserByEmail(ctx, "admin@example.com")
+	if err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	if err := store.CreateActivationCode(ctx, "TAKE-DOWN-TAKE-DOW1", 100); err != nil {
+		t.Fatalf("create code: %v", err)
+	}
+	if _, _, err := store.RedeemActivationCode(ctx, admin.ID, "TAKE-DOWN-TAKE-DOW1"); err != nil {
+		t.Fatalf("redeem code: %v", err)
+	}
+
+	do := func(handler func(*gin.Context), method, target, body string, params gin.Params) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+		w := httptest.NewRecorder()
+		handler(&gin.Context{Writer: w, Request: req, Params: params})
+		return w
+	}
+	pixelAt := func(id int) storage.Pixel {
+		w := do(server.handleGetPixels, http.MethodGet, "/api/pixels", "", nil)
+		var state storage.PixelState
+		if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+			t.Fatalf("decode grid: %v", err)
+		}
+		for _, pixel := range state.Pixels {
+			if pixel.ID == id {
+				return pixel
+			}
+		}
+		t.Fatalf("pixel %d missing from grid", id)
+		return storage.Pixel{}
+	}
+	pixelColor := func(id int) string { return pixelAt(id).Color }
+
+	purchase := `{"pixels":[{"id":2,"status":"taken","color":"#ff0000","url":"https://example.com","title":"Zdjęcie"}]}`
+	if w := do(server.handleUpdatePixel, http.MethodPost, "/api/pixels", purchase, nil); w.Code != http.StatusOK {
+		t.Fatalf("unexpected purchase status %d: %s", w.Code, w.Body.String())
+	}
+
+	invalid := `{"claimant_name":"Anna","claimant_email":"anna@example.com","description":"My photo","pixel_ids":[2],"turnstile_token":"` + testTurnstileToken + `"}`
+	if w := do(server.handleCreateTakedown, http.MethodPost, "/api/takedowns", invalid, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected missing good faith statement to be rejected, got %d", w.Code)
+	}
+	body := `{"claimant_name":"Anna","claimant_email":"anna@example.com","work_url":"https://anna.example.com/photo","description":"My photo","pixel_ids":[3,2,2],"good_faith":true,"turnstile_token":"` + testTurnstileToken + `"}`
+	w := do(server.handleCreateTakedown, http.MethodPost, "/api/takedowns", body, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("unexpected takedown status %d: %s", w.Code, w.Body.String())
+	}
+	if got := pixelAt(2); got.Color != takedownHiddenColor || got.URL != "" || got.Title != "" {
+		t.Fatalf("expected contested pixel to be hidden, got %+v", got)
+	}
+	if len(mailer.notices) != 1 || mailer.recipients[0] != "admin@example.com" || !strings.Contains(mailer.notices[0].Body, "1 Twoich pikseli") {
+		t.Fatalf("unexpected owner notices %+v to %v", mailer.notices, mailer.recipients)
+	}
+
+	w = do(server.handleAdminTakedowns, http.MethodGet, "/api/admin/takedowns", "", nil)
+	var queue struct {
+		Takedowns []storage.Takedown `json:"takedowns"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &queue); err != nil || len(queue.Takedowns) != 1 {
+		t.Fatalf("unexpected queue %d: %s", w.Code, w.Body.String())
+	}
+	takedown := queue.Takedowns[0]
+	if len(takedown.PixelIDs) != 2 || takedown.PixelIDs[0] != 2 || takedown.Status != storage.TakedownStatusPending {
+		t.Fatalf("unexpected takedown %+v", takedown)
+	}
+	params := gin.Params{{Key: "id", Value: "1"}}
+
+	w = do(server.handleResolveTakedown, http.MethodPost, "/api/admin/takedowns/1/resolve", `{"decision":"rejected","note":"Owner holds a license"}`, params)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected resolve status %d: %s", w.Code, w.Body.String())
+	}
+	if got := pixelColor(2); got != "#ff0000" {
+		t.Fatalf("expected rejected takedown to restore pixel, got %q", got)
+	}
+	if len(mailer.notices) != 2 || !strings.Contains(mailer.notices[1].Body, "Owner holds a license") {
+		t.Fatalf("unexpected resolution notice %+v", mailer.notices)
+	}
+	if w := do(server.handleResolveTakedown, http.MethodPost, "/api/admin/takedowns/1/resolve", `{"decision":"upheld"}`, params); w.Code != http.StatusConflict {
+		t.Fatalf("expected second resolution to conflict, got %d", w.Code)
+	}
+
+	w = do(server.handleAdminTakedown, http.MethodGet, "/api/admin/takedowns/1", "", params)
+	var detail struct {
+		Takedown storage.Takedown        `json:"takedown"`
+		Events   []storage.TakedownEvent `json:"events"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &detail); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected detail %d: %s", w.Code, w.Body.String())
+	}
+	if detail.Takedown.ResolvedAt == nil || len(detail.Events) != 2 || detail.Events[1].Action != "rejected" || detail.Events[1].ActorID == nil || *detail.Events[1].ActorID != admin.ID {
+		t.Fatalf("unexpected detail %+v", detail)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+func TestTenantRouterSelectsBoardByHost(t *testing.T) {
+	board := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		})
+	}
+	verified := newCustomDomainRoutes()
+	pixel := 1
+	verified.replace([]storage.CustomDomain{{Domain: "vanity.example.org", UserID: 7, PixelID: &pixel}})
+	router := &tenantRouter{
+		primary: board("primary"),
+		hosts:   map[string]http.Handler{"piksele.example.com": board("partner")},
+		boards:  []tenantBoard{{handler: board("partner")}, {handler: board("other"), domains: verified}},
+	}
+	for host, want := range map[string]string{
+		"piksele.example.com":       "partner",
+		"Piksele.Example.com:8443":  "partner",
+		"piksele.example.com.":      "partner",
+		"kuppiksel.example.com":     "primary",
+		"other.piksele.example.com": "primary",
+		"vanity.example.org":        "other",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/pixels", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := w.Body.String(); got != want {
+			t.Fatalf("host %q went to %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestCheckTenantIsolation(t *testing.T) {
+	board := func(driver, target, snapshot string) *config.Config {
+		cfg := config.Default()
+		cfg.Database.Driver = driver
+		if driver == "mysql" {
+			cfg.Database.MySQL = &config.MySQLConfig{DSN: target}
+		} else {
+			cfg.Database.SQLitePath = target
+		}
+		cfg.GridCache.SnapshotPath = snapshot
+		return cfg
+	}
+	owners := []string{"primary board", `tenant "partner"`}
+
+	separate := []*config.Config{board("sqlite", "data/primary.db", "data/grid"), board("sqlite", "data/partner.db", "data/partner-grid")}
+	if err := checkTenantIsolation(separate, owners); err != nil {
+		t.Fatalf("checkTenantIsolation() error = %v", err)
+	}
+	for name, configs := range map[string][]*config.Config{
+		"database":           {board("sqlite", "data/primary.db", ""), board("sqlite", "./data/primary.db", "")},
+		"grid snapshot file": {board("sqlite", "data/primary.db", "data/grid"), board("sqlite", "data/partner.db", "data/grid")},
+	} {
+		err := checkTenantIsolation(configs, owners)
+		if err == nil || !strings.Contains(err.Error(), "the same "+name) {
+			t.Fatalf("expected a shared %s to be rejected, got %v", name, err)
+		}
+	}
+	err := checkTenantIsolation([]*config.Config{board("mysql", "user:secret@tcp(db)/pixels", ""), board("mysql", "user:secret@tcp(db)/pixels", "")}, owners)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("expected a shared MySQL database to be rejected without the DSN, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestTransactionIDIsAdoptedAndEchoed(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.turnstileVerify = func(ctx context.Context, secret, token, remoteIP string) (turnstileResponse, error) {
+		return turnstileResponse{Success: false, ErrorCodes: []string{"invalid-input-response"}}, nil
+	}
+	router := gin.Default()
+	router.Use(server.transactionIDMiddleware)
+	router.POST("/api/check", func(c *gin.Context) {
+		server.requireTurnstile(c, "token")
+	})
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	send := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/check", nil)
+		if id != "" {
+			req.Header.Set(transactionIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	const frontendID = "5f0c9a7e-3b1d-4c52-9e0a-2d7f1b6c8e43"
+	if w := send(frontendID); w.Header().Get(transactionIDHeader) != frontendID {
+		t.Fatalf("expected the frontend id to be echoed, got %q", w.Header().Get(transactionIDHeader))
+	}
+	if !strings.Contains(logs.String(), "turnstile verification failed: tx="+frontendID+" ") {
+		t.Fatalf("expected the frontend id in the log, got %q", logs.String())
+	}
+
+	for _, id := range []string{"", "short", "bad id\nwith newline", strings.Repeat("a", 65)} {
+		got := send(id).Header().Get(transactionIDHeader)
+		if got == id || !transactionIDPattern.MatchString(got) {
+			t.Fatalf("invalid id %q must be replaced by a generated one, got %q", id, got)
+		}
+	}
+}
+
+func TestUpstreamTransportForwardsTransactionID(t *testing.T) {
+	var forwarded []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.Header.Get(transactionIDHeader))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	client := &http.Client{Transport: &upstreamTransport{service: "test"}}
+	const id = "0b4c1d8e-6f2a-4e7b-9c3d-5a1e8f7b2c60"
+	for _, ctx := range []context.Context{context.WithValue(context.Background(), transactionIDKey{}, id), context.Background()} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("upstream call: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(forwarded) != 2 || forwarded[0] != id || forwarded[1] != "" {
+		t.Fatalf("expected the id only on the call made for a request, got %q", forwarded)
+	}
+	if !strings.Contains(logs.String(), "upstream: tx="+id+" service=test ") || !strings.Contains(logs.String(), "status=202 duration=") {
+		t.Fatalf("expected the call to be logged, got %q", logs.String())
+	}
+}
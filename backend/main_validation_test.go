@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+func TestRegisterAndResetReturnFieldErrors(t *testing.T) {
+	server, _, _ := newAdminTestServer(t)
+	server.disableVerificationEmail = true
+
+	cases := []struct {
+		name    string
+		handler func(*gin.Context)
+		body    string
+		want    []fieldError
+	}{
+		{
+			name:    "register missing fields",
+			handler: server.handleRegister,
+			body:    `{}`,
+			want:    []fieldError{{"email", fieldCodeRequired}, {"password", fieldCodeRequired}},
+		},
+		{
+			name:    "register bad email and short password",
+			handler: server.handleRegister,
+			body:    `{"email":"Jan <jan@example.com>","password":"abc"}`,
+			want:    []fieldError{{"email", fieldCodeInvalidFormat}, {"password", fieldCodeTooShort}},
+		},
+		{
+			name:    "register overlong password",
+			handler: server.handleRegister,
+			body:    `{"email":"jan@example.com","password":"` + strings.Repeat("x", maxPasswordBytes+1) + `"}`,
+			want:    []fieldError{{"password", fieldCodeTooLong}},
+		},
+		{
+			name:    "reset missing token and mismatch",
+			handler: server.handlePasswordResetConfirm,
+			body:    `{"password":"new-secret","confirm_password":"other-secret"}`,
+			want:    []fieldError{{"token", fieldCodeRequired}, {"confirm_password", fieldCodeMismatch}},
+		},
+		{
+			name:    "reset missing confirmation",
+			handler: server.handlePasswordResetConfirm,
+			body:    `{"token":"abc","password":"new"}`,
+			want:    []fieldError{{"password", fieldCodeTooShort}, {"confirm_password", fieldCodeRequired}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			tc.handler(&gin.Context{Writer: w, Request: req})
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Error  string       `json:"error"`
+				Code   string       `json:"code"`
+				Fields []fieldError `json:"fields"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Code != "validation_failed" || resp.Error == "" || !reflect.DeepEqual(resp.Fields, tc.want) {
+				t.Fatalf("unexpected response %+v, want fields %+v", resp, tc.want)
+			}
+		})
+	}
+}
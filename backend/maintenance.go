@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	maintenanceSettingName    = "maintenance_windows"
+	maxMaintenanceWindows     = 50
+	defaultMaintenanceMessage = "Trwają prace techniczne. Zakupy są chwilowo niedostępne."
+)
+
+// maintenanceWindow is a scheduled period during which the site is read-only.
+type maintenanceWindow struct {
+	ID       string    `json:"id"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Message  string    `json:"message"`
+}
+
+type maintenanceWindowRequest struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+	Message  string    `json:"message"`
+}
+
+func (s *Server) loadMaintenanceWindows(ctx context.Context) ([]maintenanceWindow, error) {
+	raw, err := s.store.GetSetting(ctx, maintenanceSettingName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return []maintenanceWindow{}, nil
+		}
+		return nil, err
+	}
+	var windows []maintenanceWindow
+	if err := json.Unmarshal([]byte(raw), &windows); err != nil {
+		return nil, fmt.Errorf("decode maintenance windows: %w", err)
+	}
+	return windows, nil
+}
+
+// saveMaintenanceWindows stores the windows sorted by start, dropping the ones that already ended.
+func (s *Server) saveMaintenanceWindows(ctx context.Context, windows []maintenanceWindow) error {
+	now := time.Now()
+	kept := make([]maintenanceWindow, 0, len(windows))
+	for _, window := range windows {
+		if window.EndsAt.After(now) {
+			kept = append(kept, window)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].StartsAt.Before(kept[j].StartsAt) })
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return fmt.Errorf("encode maintenance windows: %w", err)
+	}
+	return s.store.PutSetting(ctx, maintenanceSettingName, string(data))
+}
+
+// maintenanceStatus returns the window in progress (if any) and the next scheduled one.
+func (s *Server) maintenanceStatus(ctx context.Context) (active, upcoming *maintenanceWindow) {
+	windows, err := s.loadMaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("load maintenance windows: %v", err)
+		return nil, nil
+	}
+	now := time.Now()
+	for i := range windows {
+		window := windows[i]
+		switch {
+		case !window.EndsAt.After(now):
+			continue
+		case !window.StartsAt.After(now):
+			if active == nil {
+				active = &window
+			}
+		case upcoming == nil || window.StartsAt.Before(upcoming.StartsAt):
+			upcoming = &window
+		}
+	}
+	return active, upcoming
+}
+
+// rejectDuringMaintenance answers with 503 when a maintenance window is in progress.
+func (s *Server) rejectDuringMaintenance(c *gin.Context) bool {
+	active, _ := s.maintenanceStatus(c.Request.Context())
+	if active == nil {
+		return false
+	}
+	retryAfter := int(time.Until(active.EndsAt).Seconds()) + 1
+	c.Writer.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":       active.Message,
+		"code":        "maintenance",
+		"maintenance": active,
+	})
+	return true
+}
+
+func (s *Server) handleListMaintenanceWindows(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	windows, err := s.loadMaintenanceWindows(c.Request.Context())
+	if err != nil {
+		log.Printf("load maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load maintenance windows"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"windows": windows})
+}
+
+func (s *Server) handleCreateMaintenanceWindow(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if req.StartsAt.IsZero() || req.EndsAt.IsZero() || !req.EndsAt.After(req.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+		return
+	}
+	if !req.EndsAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maintenance window already ended"})
+		return
+	}
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+
+	ctx := c.Request.Context()
+	windows, err := s.loadMaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("load maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load maintenance windows"})
+		return
+	}
+	if len(windows) >= maxMaintenanceWindows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many scheduled maintenance windows"})
+		return
+	}
+	id, err := generateSessionID()
+	if err != nil {
+		log.Printf("create maintenance window: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create maintenance window"})
+		return
+	}
+	window := maintenanceWindow{ID: id[:16], StartsAt: req.StartsAt.UTC(), EndsAt: req.EndsAt.UTC(), Message: message}
+	if err := s.saveMaintenanceWindows(ctx, append(windows, window)); err != nil {
+		log.Printf("save maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save maintenance window"})
+		return
+	}
+
+	log.Printf("maintenance window scheduled: id=%s starts_at=%s ends_at=%s", window.ID, window.StartsAt.Format(time.RFC3339), window.EndsAt.Format(time.RFC3339))
+	c.JSON(http.StatusCreated, gin.H{"window": window})
+}
+
+func (s *Server) handleDeleteMaintenanceWindow(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	windows, err := s.loadMaintenanceWindows(ctx)
+	if err != nil {
+		log.Printf("load maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load maintenance windows"})
+		return
+	}
+	id := c.Param("id")
+	kept := make([]maintenanceWindow, 0, len(windows))
+	for _, window := range windows {
+		if window.ID != id {
+			kept = append(kept, window)
+		}
+	}
+	if len(kept) == len(windows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "maintenance window not found"})
+		return
+	}
+	if err := s.saveMaintenanceWindows(ctx, kept); err != nil {
+		log.Printf("save maintenance windows: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save maintenance windows"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
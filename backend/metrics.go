@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/example/kup-piksel/internal/metrics"
+	"github.com/example/kup-piksel/internal/storage/sqltrace"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// slowQueryHook logs statements that exceeded the configured threshold and counts them per backend.
+// Statements arrive with their literals already redacted, so user data never reaches the log.
+func slowQueryHook(registry *metrics.Registry, backend string) sqltrace.Hook {
+	counter := registry.Counter("kuppixel_db_slow_queries_total", "Database statements slower than the configured threshold.", "backend", backend)
+	return func(q sqltrace.SlowQuery) {
+		counter.Inc()
+		log.Printf("slow query: backend=%s elapsed=%s args=%d query=%q", backend, q.Elapsed, q.Args, q.Query)
+	}
+}
+
+// refreshBusinessMetrics copies the store's totals into gauges, so dashboards can chart sales and
+// balances from /metrics without database access.
+func (s *Server) refreshBusinessMetrics(ctx context.Context) error {
+	stats, err := s.store.GetBusinessStats(ctx)
+	if err != nil {
+		return fmt.Errorf("business metrics: %w", err)
+	}
+	s.metrics.Gauge("kuppixel_pixels_taken_total", "Pixels currently taken.").Set(stats.PixelsTaken)
+	s.metrics.Gauge("kuppixel_pixels_rented_total", "Taken pixels with a rental end date.").Set(stats.PixelsRented)
+	s.metrics.Gauge("kuppixel_users_total", "Registered accounts.").Set(stats.Users)
+	s.metrics.Gauge("kuppixel_users_verified_total", "Accounts with a verified email address.").Set(stats.UsersVerified)
+	s.metrics.Gauge("kuppixel_points_outstanding", "Points held by users and not spent yet.").Set(stats.PointsOutstanding)
+	return nil
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := s.metrics.WriteText(&buf); err != nil {
+		log.Printf("render metrics: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", metricsContentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// metricsHandler serves the primary board's metrics at /metrics and each tenant's at
+// /metrics/{tenant name}.
+func metricsHandler(primary *Server, tenants map[string]*Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", primary.serveMetrics)
+	for name, server := range tenants {
+		mux.HandleFunc("/metrics/"+name, server.serveMetrics)
+	}
+	return mux
+}
+
+// startMetricsListener serves metrics on the address from config rather than the public port,
+// since the gauges expose sales and user counts.
+func startMetricsListener(addr string, handler http.Handler) {
+	go func() {
+		log.Printf("metrics listener: addr=%s", addr)
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			log.Printf("metrics listener stopped: %v", err)
+		}
+	}()
+}
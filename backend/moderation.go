@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	// pixelStatusPendingReview is shown to the owner for pixels waiting in the moderation queue.
+	pixelStatusPendingReview  = "pending_review"
+	maxModerationPixels       = 2500
+	maxModerationReasonLength = 500
+)
+
+type moderationRequest struct {
+	PixelIDs []int `json:"pixel_ids"`
+	// Reason is sent to the owners of rejected pixels.
+	Reason string `json:"reason"`
+}
+
+// reviewChangedPixels queues the pixels of a purchase that need a review: those the owner did not
+// have before and those whose link changed. before holds the buyer's pixels ahead of the purchase.
+// It returns the ids it queued.
+func (s *Server) reviewChangedPixels(ctx context.Context, before map[int]string, updated []storage.Pixel) []int {
+	queued := make(map[int64][]int)
+	for _, pixel := range updated {
+		if pixel.Status != "taken" || pixel.OwnerID == nil {
+			continue
+		}
+		if url, owned := before[pixel.ID]; owned && url == pixel.URL {
+			continue
+		}
+		queued[*pixel.OwnerID] = append(queued[*pixel.OwnerID], pixel.ID)
+	}
+	ids := make([]int, 0)
+	now := time.Now()
+	for ownerID, pixelIDs := range queued {
+		// The pixels are already written, so a failure leaves them visible; it is only logged.
+		if err := s.store.QueuePixelReviews(ctx, ownerID, pixelIDs, now); err != nil {
+			log.Printf("moderation: queue pixels of user %d: %v", ownerID, err)
+			continue
+		}
+		ids = append(ids, pixelIDs...)
+	}
+	return ids
+}
+
+// hidePendingReview shows pixels waiting in the moderation queue as free.
+func (s *Server) hidePendingReview(ctx context.Context, state *storage.PixelState) error {
+	pending, err := s.store.ListPendingReviewPixelIDs(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	set := make(map[int]bool, len(pending))
+	for _, id := range pending {
+		set[id] = true
+	}
+	for i := range state.Pixels {
+		if set[state.Pixels[i].ID] {
+			state.Pixels[i] = storage.Pixel{ID: state.Pixels[i].ID, Status: "free", UpdatedAt: state.Pixels[i].UpdatedAt}
+		}
+	}
+	return nil
+}
+
+// markPendingReview gives the owner's queued pixels the pending_review status.
+func (s *Server) markPendingReview(ctx context.Context, pixels []storage.Pixel) error {
+	pending, err := s.store.ListPendingReviewPixelIDs(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	set := make(map[int]bool, len(pending))
+	for _, id := range pending {
+		set[id] = true
+	}
+	for i := range pixels {
+		if set[pixels[i].ID] {
+			pixels[i].Status = pixelStatusPendingReview
+		}
+	}
+	return nil
+}
+
+// handleListModeration returns the moderation queue, oldest first.
+func (s *Server) handleListModeration(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	reviews, err := s.store.ListPixelReviews(c.Request.Context())
+	if err != nil {
+		log.Printf("moderation: list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load moderation queue"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pixels": reviews, "enabled": s.moderation.Enabled})
+}
+
+// handleApproveModeration publishes the listed queued pixels on the grid.
+func (s *Server) handleApproveModeration(c *gin.Context) {
+	s.resolveModeration(c, storage.PixelReviewApproved)
+}
+
+// handleRejectModeration frees the listed queued pixels and gives their owners back the points
+// they paid. The reason is sent to the owners.
+func (s *Server) handleRejectModeration(c *gin.Context) {
+	s.resolveModeration(c, storage.PixelReviewRejected)
+}
+
+func (s *Server) resolveModeration(c *gin.Context, decision string) {
+	admin, ok := s.requireAdmin(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req moderationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	switch {
+	case len(req.PixelIDs) == 0 || len(req.PixelIDs) > maxModerationPixels:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("pixel_ids must list between 1 and %d pixels", maxModerationPixels)})
+		return
+	case utf8.RuneCountInString(req.Reason) > maxModerationReasonLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reason must be at most %d characters", maxModerationReasonLength)})
+		return
+	case decision == storage.PixelReviewRejected && req.Reason == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+	for _, id := range req.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel id"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	reviews, err := s.store.ResolvePixelReviews(ctx, req.PixelIDs, decision)
+	if err != nil {
+		log.Printf("moderation: %s admin_id=%d: %v", decision, admin.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve moderation"})
+		return
+	}
+	if len(reviews) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "none of the pixels are waiting for review", "code": "not_queued"})
+		return
+	}
+	ids := make([]int, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.PixelID
+	}
+	log.Printf("moderation: %s pixels=%d admin_id=%d", decision, len(ids), admin.ID)
+	s.gridChanged(ids...)
+	s.notifyModerationOwners(ctx, reviews, decision, req.Reason)
+	c.JSON(http.StatusOK, gin.H{"decision": decision, "pixels": reviews})
+}
+
+// notifyModerationOwners emails every owner the decision on their pixels. Failures are only logged.
+func (s *Server) notifyModerationOwners(ctx context.Context, reviews []storage.PixelReview, decision, reason string) {
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		log.Printf("moderation: mailer cannot send notices; owners not notified")
+		return
+	}
+	byOwner := make(map[int64][]storage.PixelReview)
+	for _, review := range reviews {
+		byOwner[review.UserID] = append(byOwner[review.UserID], review)
+	}
+	for ownerID, owned := range byOwner {
+		owner, err := s.store.GetUserByID(ctx, ownerID)
+		if err != nil {
+			log.Printf("moderation: load owner %d: %v", ownerID, err)
+			continue
+		}
+		notice := email.Notice{
+			Subject: "Twoje piksele zostały zatwierdzone",
+			Body:    fmt.Sprintf("Sprawdziliśmy Twoje piksele (%d) i są już widoczne na planszy.", len(owned)),
+		}
+		if decision == storage.PixelReviewRejected {
+			var refunded int64
+			for _, review := range owned {
+				refunded += review.RefundedPoints
+			}
+			notice = email.Notice{
+				Subject: "Twoje piksele zostały odrzucone",
+				Body: fmt.Sprintf("Sprawdziliśmy Twoje piksele (%d) i nie możemy ich opublikować, dlatego zostały zwolnione. Zwrócone punkty: %d.\n\nUzasadnienie: %s",
+					len(owned), refunded, reason),
+			}
+		}
+		if err := sender.SendNotice(ctx, owner.Email, notice); err != nil {
+			log.Printf("moderation: notify owner %d: %v", ownerID, err)
+		}
+	}
+}
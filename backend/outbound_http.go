@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/example/kup-piksel/internal/httpclient"
+)
+
+// outboundClient returns the client for calls to destination. Each call carries the transaction
+// id and is logged, retries included.
+func outboundClient(factory *httpclient.Factory, destination string) *http.Client {
+	return factory.Client(destination, &upstreamTransport{service: destination})
+}
+
+// outboundHostTransport wraps the transport of a client that dials user-supplied hosts through
+// netguard, so its calls are retried and counted like the others while keeping the guard. Each
+// host has its own circuit breaker.
+func outboundHostTransport(factory *httpclient.Factory, destination string) func(http.RoundTripper) http.RoundTripper {
+	return func(guarded http.RoundTripper) http.RoundTripper {
+		return factory.HostTransport(destination, &upstreamTransport{service: destination, base: guarded})
+	}
+}
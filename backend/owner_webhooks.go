@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+	"github.com/example/kup-piksel/internal/webhook"
+)
+
+const (
+	ownerEventPixelsPurchased = "pixels.purchased"
+	ownerEventPixelClicked    = "pixel.clicked"
+	ownerEventPixelsModerated = "pixels.moderated"
+
+	maxOwnerWebhookURLLength = 2048
+)
+
+type ownerWebhookRequest struct {
+	URL string `json:"url"`
+}
+
+// ownerWebhookEvent is the JSON body of every webhook delivery.
+type ownerWebhookEvent struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// notifyOwnerWebhook queues event for the owner's webhook, if they registered one. Delivery
+// happens in the background; failures are only logged.
+func (s *Server) notifyOwnerWebhook(ctx context.Context, ownerID int64, event string, data any) {
+	if s.ownerWebhooks == nil {
+		return
+	}
+	hook, err := s.store.GetOwnerWebhook(ctx, ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("owner webhook: load user_id=%d: %v", ownerID, err)
+		return
+	}
+	id := webhook.NewDeliveryID()
+	body, err := json.Marshal(ownerWebhookEvent{ID: id, Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("owner webhook: encode %s: %v", event, err)
+		return
+	}
+	if err := s.ownerWebhooks.Enqueue(webhook.Delivery{
+		ID:            id,
+		URL:           hook.URL,
+		Secret:        hook.Secret,
+		Event:         event,
+		Body:          body,
+		TransactionID: requestTransactionID(ctx),
+	}); err != nil {
+		log.Printf("owner webhook: queue %s user_id=%d: %v", event, ownerID, err)
+	}
+}
+
+func newOwnerWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validOwnerWebhookURL accepts absolute http(s) URLs without credentials.
+func validOwnerWebhookURL(raw string) bool {
+	if raw == "" || len(raw) > maxOwnerWebhookURLLength {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return false
+	}
+	return parsed.Scheme == "https" || parsed.Scheme == "http"
+}
+
+// requireOwnerWebhooks answers 404 while webhooks are disabled in the configuration.
+func (s *Server) requireOwnerWebhooks(c *gin.Context) bool {
+	if s.ownerWebhooks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhooks are disabled"})
+		return false
+	}
+	return true
+}
+
+// handleGetOwnerWebhook returns the signed-in user's webhook without its secret.
+func (s *Server) handleGetOwnerWebhook(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireOwnerWebhooks(c) {
+		return
+	}
+	hook, err := s.store.GetOwnerWebhook(c.Request.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not configured"})
+		return
+	}
+	if err != nil {
+		log.Printf("owner webhook: load user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhook": hook})
+}
+
+// handlePutOwnerWebhook sets the user's webhook URL. The signing secret is generated with the
+// first URL and returned only then; changing the URL keeps it.
+func (s *Server) handlePutOwnerWebhook(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireOwnerWebhooks(c) || s.rejectWrites(c) {
+		return
+	}
+	var req ownerWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	target := strings.TrimSpace(req.URL)
+	if !validOwnerWebhookURL(target) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) address"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	hook, err := s.store.GetOwnerWebhook(ctx, user.ID)
+	created := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !created {
+		log.Printf("owner webhook: load user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save webhook"})
+		return
+	}
+	hook.UserID, hook.URL = user.ID, target
+	if created {
+		if hook.Secret, err = newOwnerWebhookSecret(); err != nil {
+			log.Printf("owner webhook: generate secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save webhook"})
+			return
+		}
+	}
+	s.saveOwnerWebhook(c, hook, created)
+}
+
+// handleRotateOwnerWebhookSecret replaces the signing secret and returns the new one. Deliveries
+// already queued are signed with the old secret.
+func (s *Server) handleRotateOwnerWebhookSecret(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireOwnerWebhooks(c) || s.rejectWrites(c) {
+		return
+	}
+	hook, err := s.store.GetOwnerWebhook(c.Request.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not configured"})
+		return
+	}
+	if err != nil {
+		log.Printf("owner webhook: load user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate secret"})
+		return
+	}
+	if hook.Secret, err = newOwnerWebhookSecret(); err != nil {
+		log.Printf("owner webhook: generate secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate secret"})
+		return
+	}
+	s.saveOwnerWebhook(c, hook, true)
+}
+
+// saveOwnerWebhook stores hook and answers with it, including the secret when showSecret is set.
+func (s *Server) saveOwnerWebhook(c *gin.Context, hook storage.OwnerWebhook, showSecret bool) {
+	saved, err := s.store.PutOwnerWebhook(c.Request.Context(), hook)
+	if err != nil {
+		log.Printf("owner webhook: save user_id=%d: %v", hook.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save webhook"})
+		return
+	}
+	log.Printf("owner webhook: saved user_id=%d secret_changed=%t", saved.UserID, showSecret)
+	resp := gin.H{"webhook": saved}
+	if showSecret {
+		resp["secret"] = saved.Secret
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) handleDeleteOwnerWebhook(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requireOwnerWebhooks(c) || s.rejectWrites(c) {
+		return
+	}
+	if err := s.store.DeleteOwnerWebhook(c.Request.Context(), user.ID); err != nil {
+		log.Printf("owner webhook: delete user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
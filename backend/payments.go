@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/currency"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	paymentSignatureHeader = "X-Payment-Signature"
+	maxPaymentWebhookBody  = 64 << 10
+)
+
+type createPaymentRequest struct {
+	BundleID        string `json:"bundle_id"`
+	Currency        string `json:"currency"`
+	CountryOverride string `json:"country_override"`
+}
+
+type paymentWebhookRequest struct {
+	PaymentID   string `json:"payment_id"`
+	Status      string `json:"status"`
+	ProviderRef string `json:"provider_ref"`
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+}
+
+type bundlePrice struct {
+	Currency    string `json:"currency"`
+	AmountMinor int64  `json:"amount_minor"`
+	Formatted   string `json:"formatted"`
+}
+
+type bundleResponse struct {
+	ID     string        `json:"id"`
+	Points int64         `json:"points"`
+	Prices []bundlePrice `json:"prices"`
+}
+
+func (s *Server) findPaymentBundle(id string) (config.PaymentBundle, bool) {
+	for _, bundle := range s.paymentBundles {
+		if bundle.ID == id {
+			return bundle, true
+		}
+	}
+	return config.PaymentBundle{}, false
+}
+
+func (s *Server) handlePaymentBundles(c *gin.Context) {
+	only := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+
+	bundles := make([]bundleResponse, 0, len(s.paymentBundles))
+	for _, bundle := range s.paymentBundles {
+		entry := bundleResponse{ID: bundle.ID, Points: bundle.Points, Prices: make([]bundlePrice, 0, len(bundle.Prices))}
+		for code, amount := range bundle.Prices {
+			if only != "" && code != only {
+				continue
+			}
+			entry.Prices = append(entry.Prices, bundlePrice{Currency: code, AmountMinor: amount, Formatted: currency.Format(amount, code)})
+		}
+		if len(entry.Prices) == 0 {
+			continue
+		}
+		sort.Slice(entry.Prices, func(i, j int) bool { return entry.Prices[i].Currency < entry.Prices[j].Currency })
+		bundles = append(bundles, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+func (s *Server) handleCreatePayment(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if s.rejectWrites(c) {
+		return
+	}
+	if !s.requireAgeAttestation(c, user) {
+		return
+	}
+
+	var req createPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !s.requireAllowedCountry(c, "payment", user.Email, strings.TrimSpace(req.CountryOverride)) {
+		return
+	}
+	bundle, found := s.findPaymentBundle(strings.TrimSpace(req.BundleID))
+	if !found {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown bundle"})
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(req.Currency))
+	amount, priced := bundle.Prices[code]
+	if !priced {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bundle is not available in this currency"})
+		return
+	}
+
+	id, err := generateSessionID()
+	if err != nil {
+		log.Printf("create payment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment"})
+		return
+	}
+	payment := storage.Payment{
+		ID:          id[:32],
+		UserID:      user.ID,
+		BundleID:    bundle.ID,
+		Currency:    code,
+		AmountMinor: amount,
+		Points:      bundle.Points,
+		Status:      storage.PaymentStatusPending,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.store.CreatePayment(c.Request.Context(), payment); err != nil {
+		log.Printf("create payment for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create payment"})
+		return
+	}
+
+	log.Printf("payment created: id=%s user_id=%d bundle=%s currency=%s amount_minor=%d", payment.ID, user.ID, bundle.ID, code, amount)
+	c.JSON(http.StatusCreated, gin.H{"payment": payment, "formatted": currency.Format(amount, code)})
+}
+
+func (s *Server) handleListPayments(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+
+	payments, err := s.store.ListPaymentsByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("list payments for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load payments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payments": payments})
+}
+
+func validPaymentSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	provided, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, provided)
+}
+
+// handlePaymentWebhook receives signed status notifications from the payment provider.
+// Amount and currency must match the pending payment so points are only credited for what was charged.
+func (s *Server) handlePaymentWebhook(c *gin.Context) {
+	if s.paymentWebhookSecret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "payments are not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !validPaymentSignature(s.paymentWebhookSecret, body, c.Request.Header.Get(paymentSignatureHeader)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	var req paymentWebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	payment, err := s.store.GetPayment(ctx, req.PaymentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+			return
+		}
+		log.Printf("payment webhook: load payment %s: %v", req.PaymentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load payment"})
+		return
+	}
+	if !strings.EqualFold(req.Currency, payment.Currency) || req.AmountMinor != payment.AmountMinor {
+		log.Printf("payment webhook: amount mismatch id=%s expected=%d%s got=%d%s", payment.ID, payment.AmountMinor, payment.Currency, req.AmountMinor, strings.ToUpper(req.Currency))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount or currency mismatch"})
+		return
+	}
+
+	switch req.Status {
+	case storage.PaymentStatusCompleted:
+		payment, _, err = s.store.CompletePayment(ctx, payment.ID, req.ProviderRef)
+	case storage.PaymentStatusFailed:
+		payment, err = s.store.FailPayment(ctx, payment.ID, req.ProviderRef)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported status"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrPaymentNotPending) {
+			// Providers retry notifications; repeated deliveries are acknowledged without side effects.
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+			return
+		}
+		log.Printf("payment webhook: update payment %s: %v", req.PaymentID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update payment"})
+		return
+	}
+
+	log.Printf("payment %s: id=%s user_id=%d points=%d amount_minor=%d currency=%s", payment.Status, payment.ID, payment.UserID, payment.Points, payment.AmountMinor, payment.Currency)
+	c.JSON(http.StatusOK, gin.H{"status": payment.Status})
+}
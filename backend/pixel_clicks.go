@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	pixelClicksDefaultDays = 30
+	pixelClicksMaxDays     = 365
+)
+
+// visitorSalt keys the hashes that tell a pixel's daily unique visitors apart. The key is random
+// and replaced every UTC day, so a stored visitor cannot be traced back to an address or matched
+// across days; a restart starts a new key and may count a visitor twice on that day.
+type visitorSalt struct {
+	mu  sync.Mutex
+	day string
+	key []byte
+}
+
+// visitor returns the day's pseudonymous id of the client that sent r.
+func (v *visitorSalt) visitor(r *http.Request, now time.Time) string {
+	day := now.UTC().Format(time.DateOnly)
+	v.mu.Lock()
+	if v.day != day {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			v.mu.Unlock()
+			log.Printf("pixel redirect: generate visitor salt: %v", err)
+			return ""
+		}
+		v.day, v.key = day, key
+	}
+	key := v.key
+	v.mu.Unlock()
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(extractRemoteIP(r) + "|" + r.UserAgent()))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// handlePixelRedirect sends visitors of /go/:id on to the pixel's link, counting the click for the
+// pixel's owner on the way. Free pixels, pixels under a takedown and links that are not http(s)
+// are not redirected, so the endpoint cannot be used as an open redirect.
+func (s *Server) handlePixelRedirect(c *gin.Context) {
+	s.redirectToPixel(c, c.Param("id"), 0)
+}
+
+// redirectToPixel answers with a redirect to the link of the pixel rawID, counting the click.
+// When ownerID is not 0 the pixel must still belong to that user.
+func (s *Server) redirectToPixel(c *gin.Context, rawID string, ownerID int64) {
+	ctx := c.Request.Context()
+	pixel, target, err := s.loadPublicPixel(ctx, rawID)
+	if err == nil && ownerID != 0 && *pixel.OwnerID != ownerID {
+		err = errPixelNotPublic
+	}
+	if errors.Is(err, errPixelNotPublic) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("pixel redirect: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixel"})
+		return
+	}
+
+	// A failed count must not cost the owner the visitor, so the redirect happens regardless.
+	now := time.Now()
+	if err := s.store.RecordPixelClick(ctx, pixel.ID, *pixel.OwnerID, s.visitorSalt.visitor(c.Request, now), now); err != nil {
+		log.Printf("pixel redirect: record click pixel=%d: %v", pixel.ID, err)
+	}
+	s.notifyOwnerWebhook(ctx, *pixel.OwnerID, ownerEventPixelClicked, gin.H{"pixel_id": pixel.ID, "url": pixel.URL})
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.Writer.Header().Set("Location", target.String())
+	c.Status(http.StatusFound)
+}
+
+// handleAccountClicks returns the clicks on the signed-in user's pixels over the last ?days days
+// (30 by default), one entry per pixel that was clicked.
+func (s *Server) handleAccountClicks(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	days := pixelClicksDefaultDays
+	if raw := c.Query("days"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > pixelClicksMaxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
+		}
+		days = value
+	}
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1))
+	clicks, err := s.store.ListPixelClicksByOwner(c.Request.Context(), user.ID, since)
+	if err != nil {
+		log.Printf("pixel clicks: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load clicks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "clicks": clicks})
+}
+
+// handlePixelStats returns the clicks and daily unique visitors of one of the signed-in user's
+// pixels over the last ?days days (30 by default) as totals and a daily series. Only the current
+// owner sees a pixel's statistics, and only for the time they have owned it; unique visitors are
+// counted per day, so the total is the sum of the daily counts. Days without clicks are zeros.
+func (s *Server) handlePixelStats(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 || id >= storage.TotalPixels {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+		return
+	}
+	days := pixelClicksDefaultDays
+	if raw := c.Query("days"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > pixelClicksMaxDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
+		}
+		days = value
+	}
+
+	ctx := c.Request.Context()
+	pixels, err := s.store.GetPixelsInRect(ctx, id%storage.GridWidth, id/storage.GridWidth, 1, 1)
+	if err != nil {
+		log.Printf("pixel stats: load pixel %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixel"})
+		return
+	}
+	if len(pixels) == 0 || pixels[0].OwnerID == nil || *pixels[0].OwnerID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+		return
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1))
+	recorded, err := s.store.GetPixelClickDays(ctx, id, user.ID, since)
+	if err != nil {
+		log.Printf("pixel stats: list pixel=%d user_id=%d: %v", id, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load statistics"})
+		return
+	}
+	byDay := make(map[string]storage.PixelClickDay, len(recorded))
+	for _, day := range recorded {
+		byDay[day.Day] = day
+	}
+	series := make([]storage.PixelClickDay, days)
+	var clicks, visitors int64
+	for i := range series {
+		day := since.AddDate(0, 0, i).Format(time.DateOnly)
+		entry := byDay[day]
+		entry.Day = day
+		series[i] = entry
+		clicks += entry.Clicks
+		visitors += entry.Visitors
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"pixel_id":        id,
+		"days":            days,
+		"clicks":          clicks,
+		"unique_visitors": visitors,
+		"series":          series,
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/linkcheck"
+	"github.com/example/kup-piksel/internal/moderation"
+)
+
+const (
+	pixelTitleMaxLength       = 80
+	pixelDescriptionMaxLength = 280
+)
+
+// contentBlocklist holds lower-cased terms and regular expressions that pixel links, titles and
+// descriptions must not contain. Entries keeps the list as configured.
+type contentBlocklist struct {
+	Entries  []string
+	terms    []string
+	patterns []*regexp.Regexp
+}
+
+// newContentBlocklist builds a blocklist from entries; an entry written as /expression/ is matched
+// as a case-insensitive regular expression, any other as a phrase.
+func newContentBlocklist(entries []string) (*contentBlocklist, error) {
+	list := &contentBlocklist{Entries: []string{}}
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		list.Entries = append(list.Entries, entry)
+		if pattern, ok := config.BlockedTermPattern(entry); ok {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("blocked term %s: %w", entry, err)
+			}
+			list.patterns = append(list.patterns, re)
+			continue
+		}
+		list.terms = append(list.terms, strings.ToLower(entry))
+	}
+	return list, nil
+}
+
+// match returns the first blocked term or expression text contains, ignoring case.
+func (b *contentBlocklist) match(text string) (string, bool) {
+	if b == nil || text == "" {
+		return "", false
+	}
+	lower := strings.ToLower(text)
+	for _, term := range b.terms {
+		if strings.Contains(lower, term) {
+			return term, true
+		}
+	}
+	for _, re := range b.patterns {
+		if re.MatchString(text) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
+
+// pixelURLError explains why link is not an acceptable pixel link, or returns "" when it is an
+// absolute http(s) URL of a public host name or address without credentials.
+func pixelURLError(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "url must be an absolute http or https link"
+	}
+	if parsed.User != nil {
+		return "url must not contain credentials"
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			return "url must not point to a private address"
+		}
+		return ""
+	}
+	if !strings.Contains(host, ".") || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return "url must name a public host"
+	}
+	return ""
+}
+
+// linkDomainAllowed reports whether link points to one of pixelContent.allowedDomains or their
+// subdomains; every domain is allowed when the list is empty.
+func (s *Server) linkDomainAllowed(link string) bool {
+	if len(s.allowedLinkDomains) == 0 {
+		return true
+	}
+	for _, domain := range s.allowedLinkDomains {
+		if linksToDomain(link, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// pixelContentError explains why the link and texts of a taken pixel are rejected, or returns ""
+// when they are acceptable. Titles are a single line; descriptions may span lines. An empty link,
+// as in a draft, is left to the caller.
+func (s *Server) pixelContentError(url, title, description string) string {
+	if url != "" {
+		if reason := pixelURLError(url); reason != "" {
+			return reason
+		}
+		if !s.linkDomainAllowed(url) {
+			return "url domain is not allowed"
+		}
+	}
+	if utf8.RuneCountInString(title) > pixelTitleMaxLength {
+		return fmt.Sprintf("title must be at most %d characters", pixelTitleMaxLength)
+	}
+	if utf8.RuneCountInString(description) > pixelDescriptionMaxLength {
+		return fmt.Sprintf("description must be at most %d characters", pixelDescriptionMaxLength)
+	}
+	if strings.IndexFunc(title, unicode.IsControl) >= 0 {
+		return "title must not contain control characters"
+	}
+	if strings.IndexFunc(description, func(r rune) bool { return r != '\n' && unicode.IsControl(r) }) >= 0 {
+		return "description must not contain control characters"
+	}
+	for _, field := range []struct{ name, value string }{{"url", url}, {"title", title}, {"description", description}} {
+		if _, blocked := s.contentBlocklist.Load().match(field.value); blocked {
+			return field.name + " contains blocked content"
+		}
+	}
+	return ""
+}
+
+// linkChecker is satisfied by *linkcheck.Checker.
+type linkChecker interface {
+	Check(ctx context.Context, link string) error
+}
+
+// pixelLinkError checks that the site link points to answers when link checks are enabled and
+// explains why it is rejected, or returns "". Outcomes are remembered in checked, so a purchase
+// contacts each site once.
+func (s *Server) pixelLinkError(ctx context.Context, checked map[string]string, link string) string {
+	if s.linkChecker == nil {
+		return ""
+	}
+	if reason, ok := checked[link]; ok {
+		return reason
+	}
+	reason := ""
+	if err := s.linkChecker.Check(ctx, link); err != nil {
+		log.Printf("link check: url=%q: %v", link, err)
+		reason = "url is unreachable"
+		if errors.Is(err, linkcheck.ErrUnsupportedURL) {
+			reason = "url must be an absolute http or https link"
+		}
+	}
+	checked[link] = reason
+	return reason
+}
+
+// moderationVerdict is the outcome of one moderator review within a purchase.
+type moderationVerdict struct {
+	reason string
+	status int
+}
+
+// pixelModerationError passes the link and color of a pixel to the configured moderator and
+// explains why they are refused along with the status to answer, or returns "". Outcomes are
+// remembered in checked, so a purchase reviews each combination once.
+func (s *Server) pixelModerationError(ctx context.Context, checked map[moderation.Submission]moderationVerdict, submission moderation.Submission) (string, int) {
+	if s.moderator == nil {
+		return "", 0
+	}
+	if verdict, ok := checked[submission]; ok {
+		return verdict.reason, verdict.status
+	}
+	var verdict moderationVerdict
+	if err := s.moderator.Review(ctx, submission); err != nil {
+		log.Printf("content moderation: url=%q color=%q: %v", submission.URL, submission.Color, err)
+		switch {
+		case errors.Is(err, moderation.ErrRejected):
+			verdict = moderationVerdict{"content was rejected by moderation", http.StatusBadRequest}
+		case !s.moderationFailOpen:
+			verdict = moderationVerdict{"content could not be moderated, try again later", http.StatusServiceUnavailable}
+		}
+	}
+	checked[submission] = verdict
+	return verdict.reason, verdict.status
+}
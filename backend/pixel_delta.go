@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// pixelDeltaOverlap is subtracted from the cursor handed back to pollers. Pixel timestamps are
+// taken before their transaction commits and MySQL keeps them at second precision, so a cursor of
+// exactly "now" could skip a write that lands late; repeating a few pixels is harmless.
+const pixelDeltaOverlap = 2 * time.Second
+
+// handleGetPixelDelta serves GET /api/pixels?since=<RFC 3339 timestamp> with only the pixels
+// modified since then. Clients pass the returned "next" value as since on their next poll.
+func (s *Server) handleGetPixelDelta(c *gin.Context) {
+	since, err := time.Parse(time.RFC3339Nano, c.Query("since"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	next := time.Now().UTC().Add(-pixelDeltaOverlap)
+	pixels, err := s.store.GetPixelsModifiedSince(ctx, since)
+	if err != nil {
+		log.Printf("get pixel delta: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+	state := storage.PixelState{Pixels: pixels}
+	if err := s.hideContestedPixels(ctx, &state); err != nil {
+		log.Printf("get pixel delta: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"since":  since.UTC(),
+		"next":   next,
+		"pixels": state.Pixels,
+	})
+}
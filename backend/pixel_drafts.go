@@ -0,0 +1,241 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	maxDraftNameLength  = 100
+	maxDraftColorLength = 16
+	maxDraftURLLength   = 2048
+)
+
+type pixelDraftRequest struct {
+	Name   string               `json:"name"`
+	Pixels []storage.DraftPixel `json:"pixels"`
+}
+
+// purchaseDraftRequest carries the purchase options of POST /api/drafts/:id/purchase; the
+// pixels come from the draft.
+type purchaseDraftRequest struct {
+	License    *pixelLicenseRequest `json:"license,omitempty"`
+	RentalDays int                  `json:"rental_days,omitempty"`
+	Quote      string               `json:"quote,omitempty"`
+	GiftTo     string               `json:"gift_to,omitempty"`
+}
+
+// normalizeDraft trims the draft and checks it against the configured limits. Colors and links may be
+// left empty while the design is unfinished; the purchase rejects such pixels.
+func (s *Server) normalizeDraft(req *pixelDraftRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) > maxDraftNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxDraftNameLength)
+	}
+	if len(req.Pixels) == 0 {
+		return errors.New("no pixels provided")
+	}
+	if len(req.Pixels) > s.pixelDrafts.MaxPixels {
+		return fmt.Errorf("a draft may have at most %d pixels", s.pixelDrafts.MaxPixels)
+	}
+	seen := make(map[int]bool, len(req.Pixels))
+	for i := range req.Pixels {
+		pixel := &req.Pixels[i]
+		if pixel.ID < 0 || pixel.ID >= storage.TotalPixels || seen[pixel.ID] {
+			return errors.New("invalid or repeated pixel id")
+		}
+		seen[pixel.ID] = true
+		pixel.Color = strings.TrimSpace(pixel.Color)
+		pixel.URL = strings.TrimSpace(pixel.URL)
+		pixel.Title = strings.TrimSpace(pixel.Title)
+		pixel.Description = strings.TrimSpace(strings.ReplaceAll(pixel.Description, "\r\n", "\n"))
+		if len(pixel.Color) > maxDraftColorLength || len(pixel.URL) > maxDraftURLLength {
+			return errors.New("color or url is too long")
+		}
+		if reason := s.pixelContentError(pixel.URL, pixel.Title, pixel.Description); reason != "" {
+			return errors.New(reason)
+		}
+	}
+	return nil
+}
+
+// draftParam loads the signed-in user's draft named by the :id parameter, answering 404 for
+// drafts of other users.
+func (s *Server) draftParam(c *gin.Context, user storage.User) (storage.PixelDraft, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid draft id"})
+		return storage.PixelDraft{}, false
+	}
+	draft, err := s.store.GetPixelDraft(c.Request.Context(), user.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "draft not found"})
+		return storage.PixelDraft{}, false
+	}
+	if err != nil {
+		log.Printf("drafts: load user_id=%d id=%d: %v", user.ID, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load draft"})
+		return storage.PixelDraft{}, false
+	}
+	return draft, true
+}
+
+// handleListDrafts returns the signed-in user's drafts, most recently edited first.
+func (s *Server) handleListDrafts(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	drafts, err := s.store.ListPixelDrafts(c.Request.Context(), user.ID)
+	if err != nil {
+		log.Printf("drafts: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load drafts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"drafts": drafts})
+}
+
+// handleGetDraft returns one draft of the signed-in user.
+func (s *Server) handleGetDraft(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if draft, ok := s.draftParam(c, user); ok {
+		c.JSON(http.StatusOK, gin.H{"draft": draft})
+	}
+}
+
+// handleCreateDraft saves a pixel design for later without charging points or reserving pixels.
+func (s *Server) handleCreateDraft(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req pixelDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := s.normalizeDraft(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	existing, err := s.store.ListPixelDrafts(ctx, user.ID)
+	if err != nil {
+		log.Printf("drafts: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save draft"})
+		return
+	}
+	if len(existing) >= s.pixelDrafts.MaxPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "draft limit reached", "code": "draft_limit"})
+		return
+	}
+	draft, err := s.store.CreatePixelDraft(ctx, storage.PixelDraft{UserID: user.ID, Name: req.Name, Pixels: req.Pixels})
+	if err != nil {
+		log.Printf("drafts: create user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save draft"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"draft": draft})
+}
+
+// handleUpdateDraft replaces the name and pixels of one of the signed-in user's drafts.
+func (s *Server) handleUpdateDraft(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	draft, ok := s.draftParam(c, user)
+	if !ok {
+		return
+	}
+	var req pixelDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := s.normalizeDraft(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	draft.Name, draft.Pixels = req.Name, req.Pixels
+	updated, err := s.store.UpdatePixelDraft(c.Request.Context(), draft)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "draft not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("drafts: update user_id=%d id=%d: %v", user.ID, draft.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save draft"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"draft": updated})
+}
+
+// handleDeleteDraft removes one of the signed-in user's drafts.
+func (s *Server) handleDeleteDraft(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	draft, ok := s.draftParam(c, user)
+	if !ok {
+		return
+	}
+	if err := s.store.DeletePixelDraft(c.Request.Context(), user.ID, draft.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("drafts: delete user_id=%d id=%d: %v", user.ID, draft.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete draft"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// handlePurchaseDraft buys the pixels of a draft exactly like POST /api/pixels and answers the
+// same way. The draft is removed once any of its pixels was bought or the purchase was queued;
+// a purchase rejected as a whole leaves it in place.
+func (s *Server) handlePurchaseDraft(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) || !s.requireAgeAttestation(c, user) {
+		return
+	}
+	draft, ok := s.draftParam(c, user)
+	if !ok {
+		return
+	}
+	// The body is optional; without one the draft is bought for good at the current prices.
+	var options purchaseDraftRequest
+	if err := c.ShouldBindJSON(&options); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req := UpdatePixelRequest{License: options.License, RentalDays: options.RentalDays, Quote: options.Quote, GiftTo: options.GiftTo}
+	for _, pixel := range draft.Pixels {
+		req.Pixels = append(req.Pixels, PixelUpdate{
+			ID:          pixel.ID,
+			Status:      "taken",
+			Color:       pixel.Color,
+			URL:         pixel.URL,
+			Title:       pixel.Title,
+			Description: pixel.Description,
+		})
+	}
+	if !s.purchasePixels(c, user, req) {
+		return
+	}
+	if err := s.store.DeletePixelDraft(c.Request.Context(), user.ID, draft.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("drafts: delete purchased user_id=%d id=%d: %v", user.ID, draft.ID, err)
+	}
+}
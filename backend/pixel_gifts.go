@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// maxGiftNoticePixels caps how many pixels are listed one by one in the recipient's email.
+const maxGiftNoticePixels = 20
+
+// resolveGiftRecipient looks up the account named by req.GiftTo. The buyer pays for a gift while
+// the recipient becomes the owner, so only free pixels can be gifted. Without GiftTo it does nothing.
+func (s *Server) resolveGiftRecipient(c *gin.Context, user storage.User, req *UpdatePixelRequest) bool {
+	if strings.TrimSpace(req.GiftTo) == "" {
+		return true
+	}
+	recipient, err := s.findUserByEmail(c.Request.Context(), req.GiftTo)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found", "code": "recipient_not_found"})
+		return false
+	}
+	if err != nil {
+		log.Printf("pixel gift: find recipient for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipient"})
+		return false
+	}
+	if recipient.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot gift pixels to yourself", "code": "gift_to_self"})
+		return false
+	}
+	req.recipient = &recipient
+	return true
+}
+
+// notifyGiftRecipient emails the recipient the design of the pixels they were given. Failures are
+// only logged; the pixels are theirs either way.
+func (s *Server) notifyGiftRecipient(ctx context.Context, buyer, recipient storage.User, results []PixelUpdateResult) {
+	var design strings.Builder
+	given := 0
+	for _, result := range results {
+		if result.Pixel == nil || result.Pixel.Status != "taken" {
+			continue
+		}
+		given++
+		if given <= maxGiftNoticePixels {
+			fmt.Fprintf(&design, "\n- piksel %d: %s, %s", result.Pixel.ID, result.Pixel.Color, result.Pixel.URL)
+		}
+	}
+	if given > maxGiftNoticePixels {
+		fmt.Fprintf(&design, "\n- i %d więcej", given-maxGiftNoticePixels)
+	}
+	log.Printf("pixel gift: buyer_id=%d recipient_id=%d pixels=%d", buyer.ID, recipient.ID, given)
+
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		log.Printf("pixel gift: mailer cannot send notices; user %d not notified", recipient.ID)
+		return
+	}
+	notice := email.Notice{
+		Subject: "Otrzymałeś piksele w prezencie",
+		Body: fmt.Sprintf("Użytkownik %s kupił dla Ciebie piksele: %d. Są już Twoje i możesz je zmieniać na swoim koncie.\nProjekt:%s",
+			buyer.Email, given, design.String()),
+	}
+	if err := sender.SendNotice(ctx, recipient.Email, notice); err != nil {
+		log.Printf("pixel gift: notify user %d: %v", recipient.ID, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	pixelHistoryDefaultLimit = 100
+	pixelHistoryMaxLimit     = 1000
+)
+
+// handlePixelHistory returns a pixel's recorded status, color, link and owner changes, newest
+// first, for resolving disputes. ?limit caps the number of entries (100 by default).
+func (s *Server) handlePixelHistory(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 0 || id >= storage.TotalPixels {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+		return
+	}
+	limit := pixelHistoryDefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > pixelHistoryMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+			return
+		}
+		limit = value
+	}
+	history, err := s.store.ListPixelHistory(c.Request.Context(), id, limit)
+	if err != nil {
+		log.Printf("pixel history: list pixel=%d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pixel_id": id, "history": history})
+}
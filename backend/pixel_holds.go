@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// pixelHoldPruneInterval is how often expired holds are deleted. Expired holds stop blocking
+// purchases right away; pruning only keeps the table small.
+const pixelHoldPruneInterval = 10 * time.Minute
+
+type reservePixelsRequest struct {
+	PixelIDs []int `json:"pixel_ids"`
+}
+
+// handleReservePixels holds the selected free pixels for the signed-in user for a few minutes, so
+// other users cannot buy them while the purchase is finished. A new reservation replaces the
+// user's previous one; pixels that are taken or held by somebody else are reported as unavailable.
+func (s *Server) handleReservePixels(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req reservePixelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	ids := make([]int, 0, len(req.PixelIDs))
+	seen := make(map[int]bool, len(req.PixelIDs))
+	for _, id := range req.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid pixel id %d", id)})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > s.pixelHolds.MaxPixels {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("można zarezerwować od 1 do %d pikseli", s.pixelHolds.MaxPixels), "code": "hold_invalid"})
+		return
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Duration(s.pixelHolds.TTLMinutes) * time.Minute)
+	held, err := s.store.HoldPixels(c.Request.Context(), user.ID, ids, expiresAt, now)
+	if err != nil {
+		log.Printf("pixel holds: hold user_id=%d pixels=%d: %v", user.ID, len(ids), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reserve pixels"})
+		return
+	}
+	isHeld := make(map[int]bool, len(held))
+	for _, id := range held {
+		isHeld[id] = true
+	}
+	unavailable := make([]int, 0)
+	for _, id := range ids {
+		if !isHeld[id] {
+			unavailable = append(unavailable, id)
+		}
+	}
+	sort.Ints(unavailable)
+	if len(held) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "wybrane piksele są już zajęte lub zarezerwowane", "code": "pixels_unavailable", "unavailable": unavailable})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"held": held, "unavailable": unavailable, "expires_at": expiresAt})
+}
+
+// handleGetPixelHolds returns the signed-in user's pixels that are still held.
+func (s *Server) handleGetPixelHolds(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	holds, err := s.store.ListPixelHolds(c.Request.Context(), user.ID, time.Now())
+	if err != nil {
+		log.Printf("pixel holds: list user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load reservations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"holds": holds})
+}
+
+// handleReleasePixelHolds drops the signed-in user's reservation, e.g. when the purchase is abandoned.
+func (s *Server) handleReleasePixelHolds(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	if err := s.store.ReleasePixelHolds(c.Request.Context(), user.ID); err != nil {
+		log.Printf("pixel holds: release user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release reservation"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	// pixelImageMaxBytes bounds the uploaded file; logos are small.
+	pixelImageMaxBytes = 2 << 20
+	// pixelImageMaxSide bounds the decoded image so a tiny file cannot expand into a huge bitmap.
+	pixelImageMaxSide = 4096
+)
+
+// pixelImage is an uploaded image scaled onto a rectangle of the grid.
+type pixelImage struct {
+	x, y, width, height int
+	// colors holds the color of every cell row by row; "" marks a transparent cell.
+	colors []string
+}
+
+// pixels returns the opaque cells of the image as pixels of the grid with their colors.
+func (p pixelImage) pixels() []storage.Pixel {
+	pixels := make([]storage.Pixel, 0, len(p.colors))
+	for i, color := range p.colors {
+		if color == "" {
+			continue
+		}
+		id := (p.y+i/p.width)*storage.GridWidth + p.x + i%p.width
+		pixels = append(pixels, storage.Pixel{ID: id, Color: color})
+	}
+	return pixels
+}
+
+// readPixelImage parses the multipart form shared by the image endpoints: the PNG or JPEG as
+// "image" and the rectangle as "x", "y", "width" and "height". The image is scaled to the
+// rectangle by downsampleImage. It returns false when a response was written.
+func readPixelImage(c *gin.Context) (pixelImage, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, pixelImageMaxBytes+64<<10)
+	if err := c.Request.ParseMultipartForm(pixelImageMaxBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "obraz jest zbyt duży.", "code": "payload_too_large"})
+			return pixelImage{}, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return pixelImage{}, false
+	}
+	var rect [4]int
+	for i, name := range []string{"x", "y", "width", "height"} {
+		n, err := strconv.Atoi(strings.TrimSpace(c.Request.FormValue(name)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a number", name)})
+			return pixelImage{}, false
+		}
+		rect[i] = n
+	}
+	x, y, width, height := rect[0], rect[1], rect[2], rect[3]
+	if x < 0 || y < 0 || width <= 0 || height <= 0 || x+width > storage.GridWidth || y+height > storage.GridHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rectangle must lie within the grid"})
+		return pixelImage{}, false
+	}
+
+	file, _, err := c.Request.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return pixelImage{}, false
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image must be a PNG or JPEG", "code": "image_invalid"})
+		return pixelImage{}, false
+	}
+	if config.Width > pixelImageMaxSide || config.Height > pixelImageMaxSide {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("image must be at most %dx%d", pixelImageMaxSide, pixelImageMaxSide), "code": "image_invalid"})
+		return pixelImage{}, false
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image"})
+		return pixelImage{}, false
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image must be a PNG or JPEG", "code": "image_invalid"})
+		return pixelImage{}, false
+	}
+	painted := pixelImage{x: x, y: y, width: width, height: height, colors: downsampleImage(img, width, height)}
+	if len(painted.pixels()) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is fully transparent", "code": "image_invalid"})
+		return pixelImage{}, false
+	}
+	return painted, true
+}
+
+// handlePurchasePixelImage buys a rectangle of pixels painted with an uploaded PNG or JPEG read by
+// readPixelImage. The form also carries the link of every pixel as "url", optionally with a
+// "title" and "description". Cells that come out mostly transparent are not bought, so a logo
+// keeps its shape. The purchase itself is the same as POST /api/pixels.
+func (s *Server) handlePurchasePixelImage(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if s.rejectWrites(c) {
+		return
+	}
+	if !s.requireAgeAttestation(c, user) {
+		return
+	}
+
+	painted, ok := readPixelImage(c)
+	if !ok {
+		return
+	}
+	url := strings.TrimSpace(c.Request.FormValue("url"))
+	if url == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "taken pixels require color and url"})
+		return
+	}
+
+	req := UpdatePixelRequest{Quote: c.Request.FormValue("quote")}
+	if !s.resolvePurchasePrices(c, user, &req) {
+		return
+	}
+	for _, pixel := range painted.pixels() {
+		req.Pixels = append(req.Pixels, PixelUpdate{
+			ID:          pixel.ID,
+			Status:      "taken",
+			Color:       pixel.Color,
+			URL:         url,
+			Title:       c.Request.FormValue("title"),
+			Description: c.Request.FormValue("description"),
+		})
+	}
+	if s.purchaseJobs != nil && s.asyncPurchaseThreshold > 0 && len(req.Pixels) >= s.asyncPurchaseThreshold {
+		s.enqueuePurchase(c, user, req)
+		return
+	}
+
+	purchase := s.applyPixelUpdates(c.Request.Context(), user, req)
+	if !purchase.updated {
+		c.JSON(purchase.status(), purchase.response(nil, nil))
+		return
+	}
+	c.JSON(http.StatusOK, purchase.response(s.pointsPrice(c, purchase.costPoints), s.pointsPrice(c, purchase.spent)))
+}
+
+// handlePreviewPixelImage answers what POST /api/pixels/image would buy for the same form without
+// buying anything: every opaque cell with its color and the points it costs. Pixels the user
+// already owns are repainted for free and pixels of other users cannot be bought, so both are
+// listed apart and left out of the total.
+func (s *Server) handlePreviewPixelImage(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	painted, ok := readPixelImage(c)
+	if !ok {
+		return
+	}
+	req := UpdatePixelRequest{Quote: c.Request.FormValue("quote")}
+	if !s.resolvePurchasePrices(c, user, &req) {
+		return
+	}
+	current, err := s.store.GetPixelsInRect(c.Request.Context(), painted.x, painted.y, painted.width, painted.height)
+	if err != nil {
+		log.Printf("preview pixel image: load rect for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+	owners := make(map[int]int64, len(current))
+	for _, pixel := range current {
+		if pixel.OwnerID != nil {
+			owners[pixel.ID] = *pixel.OwnerID
+		}
+	}
+
+	pixels := painted.pixels()
+	cells := make([]gin.H, len(pixels))
+	owned, unavailable := make([]int, 0), make([]int, 0)
+	buy := 0
+	for i, pixel := range pixels {
+		cells[i] = gin.H{"id": pixel.ID, "color": pixel.Color}
+		owner, taken := owners[pixel.ID]
+		switch {
+		case !taken:
+			buy++
+		case owner == user.ID:
+			owned = append(owned, pixel.ID)
+		default:
+			unavailable = append(unavailable, pixel.ID)
+		}
+	}
+	total := int64(buy) * req.prices.PixelCostPoints
+	c.JSON(http.StatusOK, gin.H{
+		"pixels":            cells,
+		"owned":             owned,
+		"unavailable":       unavailable,
+		"pixel_cost_points": req.prices.PixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, req.prices.PixelCostPoints),
+		"total_points":      total,
+		"total_price":       s.pointsPrice(c, total),
+		"price_version":     req.prices.Version,
+	})
+}
+
+// downsampleImage scales img to width x height cells and returns their colors row by row as
+// "#rrggbb". Each cell is the alpha-weighted average of the source pixels it covers (or the
+// nearest source pixel when the image is smaller than the rectangle); cells that are less than
+// half opaque are returned as "".
+func downsampleImage(img image.Image, width, height int) []string {
+	bounds := img.Bounds()
+	colors := make([]string, width*height)
+	for cy := 0; cy < height; cy++ {
+		y0 := bounds.Min.Y + cy*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(cy+1)*bounds.Dy()/height, y0+1)
+		for cx := 0; cx < width; cx++ {
+			x0 := bounds.Min.X + cx*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(cx+1)*bounds.Dx()/width, x0+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					// RGBA is alpha-premultiplied, so the sums are already weighted by opacity.
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			if a*2 < n*0xffff {
+				continue
+			}
+			colors[cy*width+cx] = fmt.Sprintf("#%02x%02x%02x", r*0xff/a, g*0xff/a, b*0xff/a)
+		}
+	}
+	return colors
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	maxLicenseOwnerLength     = 200
+	maxLicenseContactLength   = 200
+	maxLicenseStatementLength = 2000
+)
+
+// pixelLicenseRequest is the optional rights declaration sent with a purchase.
+type pixelLicenseRequest struct {
+	ArtworkOwner string `json:"artwork_owner"`
+	Contact      string `json:"contact"`
+	Statement    string `json:"statement"`
+}
+
+// normalize trims the declaration and checks the required fields and lengths.
+func (r *pixelLicenseRequest) normalize() error {
+	r.ArtworkOwner = strings.TrimSpace(r.ArtworkOwner)
+	r.Contact = strings.TrimSpace(r.Contact)
+	r.Statement = strings.TrimSpace(r.Statement)
+	switch {
+	case r.ArtworkOwner == "":
+		return fmt.Errorf("license artwork_owner is required")
+	case r.Contact == "":
+		return fmt.Errorf("license contact is required")
+	case utf8.RuneCountInString(r.ArtworkOwner) > maxLicenseOwnerLength:
+		return fmt.Errorf("license artwork_owner must be at most %d characters", maxLicenseOwnerLength)
+	case utf8.RuneCountInString(r.Contact) > maxLicenseContactLength:
+		return fmt.Errorf("license contact must be at most %d characters", maxLicenseContactLength)
+	case utf8.RuneCountInString(r.Statement) > maxLicenseStatementLength:
+		return fmt.Errorf("license statement must be at most %d characters", maxLicenseStatementLength)
+	}
+	return nil
+}
+
+// handleAdminPixelLicenses lists license declarations for a pixel (?pixel_id=) or a user (?user_id=).
+func (s *Server) handleAdminPixelLicenses(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var (
+		licenses []storage.PixelLicense
+		err      error
+	)
+	pixelParam := strings.TrimSpace(c.Query("pixel_id"))
+	userParam := strings.TrimSpace(c.Query("user_id"))
+	switch {
+	case pixelParam != "":
+		pixelID, convErr := strconv.Atoi(pixelParam)
+		if convErr != nil || pixelID < 0 || pixelID >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel_id"})
+			return
+		}
+		licenses, err = s.store.ListPixelLicensesByPixel(ctx, pixelID)
+	case userParam != "":
+		userID, convErr := strconv.ParseInt(userParam, 10, 64)
+		if convErr != nil || userID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+		licenses, err = s.store.ListPixelLicensesByUser(ctx, userID)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pixel_id or user_id is required"})
+		return
+	}
+	if err != nil {
+		log.Printf("admin pixel licenses: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load licenses"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"licenses": licenses})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	overlayDefaultPatterns = 6
+	overlayMaxPatterns     = 32
+)
+
+// handleGetPixelOverlay returns the grid as an accessibility overlay: for every pixel id, the
+// pattern of its owner and the sides where ownership changes, so the frontend can draw hatching
+// and boundaries instead of relying on colors alone. Owners are only told apart, never
+// identified. ?patterns sets how many patterns the frontend has (2 to 32, 6 by default);
+// neighbouring owners get different ones whenever that many allow it.
+func (s *Server) handleGetPixelOverlay(c *gin.Context) {
+	patterns := overlayDefaultPatterns
+	if raw := c.Query("patterns"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 2 || value > overlayMaxPatterns {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "patterns must be between 2 and 32"})
+			return
+		}
+		patterns = value
+	}
+
+	key := fmt.Sprintf("overlay patterns=%d", patterns)
+	s.serveGrid(c, key, "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		state, err := s.loadGrid(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		return func(w io.Writer) error { return state.WriteOverlayRLE(w, patterns) }, nil
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/linkpreview"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// errPixelNotPublic is returned by loadPublicPixel for free pixels, pixels under a takedown and
+// pixels whose link is not an http(s) URL.
+var errPixelNotPublic = errors.New("pixel not found")
+
+// loadPublicPixel returns pixel id with its parsed link when it may be shown and linked to.
+func (s *Server) loadPublicPixel(ctx context.Context, rawID string) (storage.Pixel, *url.URL, error) {
+	id, err := strconv.Atoi(rawID)
+	if err != nil || id < 0 || id >= storage.TotalPixels {
+		return storage.Pixel{}, nil, errPixelNotPublic
+	}
+	pixels, err := s.store.GetPixelsInRect(ctx, id%storage.GridWidth, id/storage.GridWidth, 1, 1)
+	if err != nil {
+		return storage.Pixel{}, nil, fmt.Errorf("load pixel %d: %w", id, err)
+	}
+	state := storage.PixelState{Width: 1, Height: 1, Pixels: pixels}
+	if err := s.hideContestedPixels(ctx, &state); err != nil {
+		return storage.Pixel{}, nil, fmt.Errorf("load takedowns: %w", err)
+	}
+	if len(state.Pixels) == 0 || state.Pixels[0].Status != "taken" || state.Pixels[0].URL == "" || state.Pixels[0].OwnerID == nil {
+		return storage.Pixel{}, nil, errPixelNotPublic
+	}
+	pixel := state.Pixels[0]
+	target, err := url.Parse(pixel.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return storage.Pixel{}, nil, errPixelNotPublic
+	}
+	return pixel, target, nil
+}
+
+// sitePreview returns what the pixel's link says about itself, or nil while previews are
+// disabled or the site could not be read.
+func (s *Server) sitePreview(ctx context.Context, link string) *linkpreview.Preview {
+	if s.linkPreviews == nil {
+		return nil
+	}
+	preview, err := s.linkPreviews.Get(ctx, link)
+	if err != nil {
+		if !errors.Is(err, linkpreview.ErrBudgetExceeded) {
+			log.Printf("link preview: %v", err)
+		}
+		return nil
+	}
+	if preview.Title == "" && preview.Description == "" {
+		return nil
+	}
+	return &preview
+}
+
+// handlePixelPreview returns a taken pixel with its site's title and description for hover cards.
+func (s *Server) handlePixelPreview(c *gin.Context) {
+	pixel, target, err := s.loadPublicPixel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errPixelNotPublic) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "pixel not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("pixel preview: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixel"})
+		return
+	}
+	c.Writer.Header().Set("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"pixel": gin.H{
+			"id":          pixel.ID,
+			"color":       pixel.Color,
+			"url":         pixel.URL,
+			"host":        target.Hostname(),
+			"title":       pixel.Title,
+			"description": pixel.Description,
+		},
+		"site": s.sitePreview(c.Request.Context(), pixel.URL),
+	})
+}
+
+var pixelPageTemplate = template.Must(template.New("pixel").Parse(`<!doctype html>
+<html lang="pl">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} – KupPiksel</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="KupPiksel">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<style>
+body{font-family:system-ui,sans-serif;background:#0f172a;color:#e2e8f0;margin:0;display:flex;justify-content:center}
+main{max-width:36rem;padding:3rem 1.5rem}
+.swatch{width:4rem;height:4rem;border-radius:.5rem;border:1px solid #334155}
+section{border-left:3px solid #334155;padding-left:1rem;color:#94a3b8}
+a{color:#38bdf8}
+</style>
+</head>
+<body>
+<main>
+<div class="swatch" style="background-color: {{.Color}}"></div>
+<h1>{{.Title}}</h1>
+{{with .PixelDescription}}<p>{{.}}</p>{{end}}
+{{with .Site}}<section>
+{{with .SiteName}}<p><small>{{.}}</small></p>{{end}}
+{{with .Title}}<h2>{{.}}</h2>{{end}}
+{{with .Description}}<p>{{.}}</p>{{end}}
+</section>{{end}}
+<p><a href="/go/{{.ID}}" rel="nofollow noopener">Przejdź do {{.Host}}</a></p>
+<p><a href="/">Zobacz tablicę pikseli</a></p>
+</main>
+</body>
+</html>
+`))
+
+const pixelPageNotFound = `<!doctype html>
+<html lang="pl"><head><meta charset="utf-8"><title>Nie znaleziono piksela – KupPiksel</title></head>
+<body><p>Ten piksel nie istnieje lub jest wolny. <a href="/">Zobacz tablicę pikseli</a></p></body></html>
+`
+
+type pixelPage struct {
+	ID               int
+	Title            string
+	Description      string
+	PixelDescription string
+	Color            string
+	Host             string
+	Site             *linkpreview.Preview
+}
+
+// handlePixelPage renders /pixel/:id, a page about one pixel with Open Graph tags so links to it
+// unfurl in chats and social networks.
+func (s *Server) handlePixelPage(c *gin.Context) {
+	pixel, target, err := s.loadPublicPixel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, errPixelNotPublic) {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(pixelPageNotFound))
+		return
+	}
+	if err != nil {
+		log.Printf("pixel page: %v", err)
+		c.String(http.StatusInternalServerError, "failed to load pixel")
+		return
+	}
+
+	page := pixelPage{
+		ID:               pixel.ID,
+		Title:            pixel.Title,
+		PixelDescription: pixel.Description,
+		Description:      pixel.Description,
+		Color:            pixel.Color,
+		Host:             target.Hostname(),
+		Site:             s.sitePreview(c.Request.Context(), pixel.URL),
+	}
+	if page.Site != nil {
+		page.Title = firstNonEmpty(page.Title, page.Site.Title)
+		page.Description = firstNonEmpty(page.Description, page.Site.Description)
+	}
+	page.Title = firstNonEmpty(page.Title, fmt.Sprintf("Piksel #%d", pixel.ID))
+	page.Description = firstNonEmpty(page.Description, fmt.Sprintf("Piksel #%d na tablicy KupPiksel prowadzi do %s.", pixel.ID, page.Host))
+
+	var body bytes.Buffer
+	if err := pixelPageTemplate.Execute(&body, page); err != nil {
+		log.Printf("pixel page: render %d: %v", pixel.ID, err)
+		c.String(http.StatusInternalServerError, "failed to render pixel")
+		return
+	}
+	c.Writer.Header().Set("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body.Bytes())
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+type pixelQuotaRequest struct {
+	MaxPixels *int `json:"max_pixels"`
+}
+
+// loadQuotaUser returns the user named by the :id parameter. It returns false when a response was
+// written.
+func (s *Server) loadQuotaUser(c *gin.Context) (storage.User, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return storage.User{}, false
+	}
+	user, err := s.store.GetUserByID(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return storage.User{}, false
+	}
+	if err != nil {
+		log.Printf("pixel quota: load user %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+		return storage.User{}, false
+	}
+	return user, true
+}
+
+// writePixelQuota answers with the quota in force for user, where max_pixels 0 means no limit,
+// and whether it is the configured default or an admin override.
+func (s *Server) writePixelQuota(c *gin.Context, user storage.User) {
+	ctx := c.Request.Context()
+	maxPixels, source := s.pixelQuota, "default"
+	override, err := s.store.GetUserPixelQuota(ctx, user.ID)
+	switch {
+	case err == nil:
+		maxPixels, source = override, "admin"
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("pixel quota: load override of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixel quota"})
+		return
+	}
+	owned, err := s.store.GetPixelsByOwner(ctx, user.ID)
+	if err != nil {
+		log.Printf("pixel quota: load pixels of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixel quota"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": user.ID, "owned": len(owned), "max_pixels": maxPixels, "source": source})
+}
+
+// handleGetPixelQuota returns how many pixels the user owns and may own.
+func (s *Server) handleGetPixelQuota(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	if user, ok := s.loadQuotaUser(c); ok {
+		s.writePixelQuota(c, user)
+	}
+}
+
+// handlePutPixelQuota overrides the pixel quota of one user, e.g. for a partner filling a large
+// area; max_pixels 0 lifts the limit for them. Pixels they already own are kept when it is
+// lowered, but they cannot acquire more until they are back under it.
+func (s *Server) handlePutPixelQuota(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	user, ok := s.loadQuotaUser(c)
+	if !ok {
+		return
+	}
+	var req pixelQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MaxPixels == nil || *req.MaxPixels < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_pixels must be a number of at least 0"})
+		return
+	}
+	if err := s.store.SetUserPixelQuota(c.Request.Context(), user.ID, *req.MaxPixels); err != nil {
+		log.Printf("pixel quota: save override of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save pixel quota"})
+		return
+	}
+	log.Printf("pixel quota of user %d set to %d by %s", user.ID, *req.MaxPixels, admin.Email)
+	s.writePixelQuota(c, user)
+}
+
+// handleDeletePixelQuota puts the user back on the configured quota.
+func (s *Server) handleDeletePixelQuota(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	user, ok := s.loadQuotaUser(c)
+	if !ok {
+		return
+	}
+	if err := s.store.DeleteUserPixelQuota(c.Request.Context(), user.ID); err != nil {
+		log.Printf("pixel quota: delete override of user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete pixel quota"})
+		return
+	}
+	log.Printf("pixel quota override of user %d removed by %s", user.ID, admin.Email)
+	s.writePixelQuota(c, user)
+}
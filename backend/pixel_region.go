@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// pixelRegionMaxArea bounds GET /api/pixels/region; the selection UI asks for the few cells
+// around the pointer, not for screens of the grid.
+const pixelRegionMaxArea = 4096
+
+// regionPixel is one cell of GET /api/pixels/region. Owners are never named; Mine tells the
+// viewer their own pixels apart.
+type regionPixel struct {
+	ID          int        `json:"id"`
+	X           int        `json:"x"`
+	Y           int        `json:"y"`
+	Status      string     `json:"status"`
+	Color       string     `json:"color,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	Mine        bool       `json:"mine"`
+	// Available is false when a purchase by the viewer would fail because somebody else owns
+	// the pixel, even while it is shown as free awaiting review.
+	Available bool `json:"available"`
+}
+
+// handleGetPixelRegion returns every cell of the window ?x, ?y, ?w, ?h (at most 4096 cells) with
+// its details, whether the signed-in viewer owns it and whether they could buy or repaint it, so
+// drag selection can be checked as it grows without loading the grid. The pixels come from one
+// query bounded by the window.
+func (s *Server) handleGetPixelRegion(c *gin.Context) {
+	var bounds [4]int
+	for i, name := range []string{"x", "y", "w", "h"} {
+		value, err := strconv.Atoi(c.Query(name))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a number", name)})
+			return
+		}
+		bounds[i] = value
+	}
+	x, y, width, height := bounds[0], bounds[1], bounds[2], bounds[3]
+	if x < 0 || y < 0 || width <= 0 || height <= 0 || x+width > storage.GridWidth || y+height > storage.GridHeight {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "region must lie within the grid"})
+		return
+	}
+	if width*height > pixelRegionMaxArea {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("region must cover at most %d pixels", pixelRegionMaxArea)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var viewerID int64
+	if user, _, ok := s.getSessionUser(c); ok {
+		viewerID = user.ID
+	}
+	pixels, err := s.store.GetPixelsInRect(ctx, x, y, width, height)
+	if err != nil {
+		log.Printf("get pixel region: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+	// Ownership is read before hiding, which turns pixels awaiting review into free ones.
+	owners := make(map[int]int64, len(pixels))
+	for _, pixel := range pixels {
+		if pixel.OwnerID != nil {
+			owners[pixel.ID] = *pixel.OwnerID
+		}
+	}
+	state := storage.PixelState{Width: width, Height: height, Pixels: pixels}
+	if err := s.hideContestedPixels(ctx, &state); err != nil {
+		log.Printf("get pixel region: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load pixels"})
+		return
+	}
+	byID := make(map[int]storage.Pixel, len(state.Pixels))
+	for _, pixel := range state.Pixels {
+		byID[pixel.ID] = pixel
+	}
+
+	cells := make([]regionPixel, 0, width*height)
+	for row := y; row < y+height; row++ {
+		for col := x; col < x+width; col++ {
+			id := row*storage.GridWidth + col
+			cell := regionPixel{ID: id, X: col, Y: row, Status: "free"}
+			if pixel, ok := byID[id]; ok && pixel.Status == "taken" {
+				cell.Status, cell.Color, cell.URL, cell.Title, cell.Description = pixel.Status, pixel.Color, pixel.URL, pixel.Title, pixel.Description
+				if !pixel.UpdatedAt.IsZero() {
+					updated := pixel.UpdatedAt
+					cell.UpdatedAt = &updated
+				}
+			}
+			owner, owned := owners[id]
+			cell.Mine = owned && viewerID > 0 && owner == viewerID
+			cell.Available = !owned || cell.Mine
+			cells = append(cells, cell)
+		}
+	}
+	c.Writer.Header().Set("Cache-Control", "private, no-cache")
+	c.JSON(http.StatusOK, gin.H{"x": x, "y": y, "w": width, "h": height, "pixels": cells})
+}
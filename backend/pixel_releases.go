@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+type releasePixelsRequest struct {
+	PixelIDs []int `json:"pixel_ids"`
+	// All releases every pixel of the caller; it cannot be combined with PixelIDs.
+	All bool `json:"all"`
+}
+
+// handleReleasePixels frees the listed pixels of the signed-in user, or all of them, in one
+// transaction and refunds the configured share of what was paid for them. Listed pixels the user
+// does not own are reported back and left alone.
+func (s *Server) handleReleasePixels(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req releasePixelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if req.All == (len(req.PixelIDs) > 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide either pixel_ids or all"})
+		return
+	}
+	for _, id := range req.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel id"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	release, err := s.store.ReleasePixelsForUser(ctx, user.ID, req.PixelIDs, s.pixelRefundFraction)
+	if err != nil {
+		log.Printf("release pixels: user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to release pixels"})
+		return
+	}
+
+	now := time.Now().UTC()
+	released := make([]int, len(release.Pixels))
+	freed := make([]storage.Pixel, len(release.Pixels))
+	isReleased := make(map[int]bool, len(release.Pixels))
+	for i, pixel := range release.Pixels {
+		released[i] = pixel.ID
+		freed[i] = storage.Pixel{ID: pixel.ID, Status: "free", UpdatedAt: now}
+		isReleased[pixel.ID] = true
+	}
+	notOwned := make([]int, 0)
+	for _, id := range req.PixelIDs {
+		if !isReleased[id] {
+			notOwned = append(notOwned, id)
+			isReleased[id] = true
+		}
+	}
+	if len(freed) > 0 {
+		s.pixelsChanged(ctx, freed)
+		log.Printf("release pixels: user_id=%d pixels=%d refunded_points=%d", user.ID, len(released), release.RefundedPoints)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"released":        released,
+		"not_owned":       notOwned,
+		"refunded_points": release.RefundedPoints,
+		"user":            sanitizeUser(release.User),
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	pixelSearchDefaultLimit = 20
+	pixelSearchMaxLimit     = 100
+	// pixelSearchMaxTerms and pixelSearchMinTermLength keep queries cheap for the index.
+	pixelSearchMaxTerms      = 8
+	pixelSearchMinTermLength = 2
+	// pixelSearchMaxPixels caps the matching pixels grouped into regions for one query.
+	pixelSearchMaxPixels = 5000
+)
+
+// pixelSearchRegion is one ad found by GET /api/pixels/search: the pixels sharing a link, title and
+// description, with the bounding box they cover on the grid.
+type pixelSearchRegion struct {
+	PixelID     int    `json:"pixel_id"`
+	Pixels      int    `json:"pixels"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// pixelSearchTerms splits query into lower-cased words of letters and digits, dropping duplicates
+// and words shorter than pixelSearchMinTermLength.
+func pixelSearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(word) < pixelSearchMinTermLength || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == pixelSearchMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// groupSearchRegions merges pixels with the same link, title and description into regions, keeping
+// the order in which each region was first found.
+func groupSearchRegions(pixels []storage.Pixel) []pixelSearchRegion {
+	type regionKey struct{ url, title, description string }
+	type bounds struct{ minX, minY, maxX, maxY int }
+	index := make(map[regionKey]int)
+	var regions []pixelSearchRegion
+	var boxes []bounds
+	for _, pixel := range pixels {
+		x, y := pixel.ID%storage.GridWidth, pixel.ID/storage.GridWidth
+		key := regionKey{pixel.URL, pixel.Title, pixel.Description}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(regions)
+			regions = append(regions, pixelSearchRegion{PixelID: pixel.ID, URL: pixel.URL, Title: pixel.Title, Description: pixel.Description})
+			boxes = append(boxes, bounds{x, y, x, y})
+			i = len(regions) - 1
+		}
+		regions[i].Pixels++
+		box := &boxes[i]
+		box.minX, box.minY = min(box.minX, x), min(box.minY, y)
+		box.maxX, box.maxY = max(box.maxX, x), max(box.maxY, y)
+	}
+	for i, box := range boxes {
+		regions[i].X, regions[i].Y = box.minX, box.minY
+		regions[i].Width, regions[i].Height = box.maxX-box.minX+1, box.maxY-box.minY+1
+	}
+	return regions
+}
+
+// handleSearchPixels finds ads whose title, description or link contain words starting with every
+// word of ?q, ignoring case and diacritics. Matching pixels are grouped into regions, best matches
+// first; ?limit caps the number of regions (20 by default). Pixels hidden by a takedown or waiting
+// for review are never returned.
+func (s *Server) handleSearchPixels(c *gin.Context) {
+	terms := pixelSearchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must contain at least one word of 2 or more letters"})
+		return
+	}
+	limit := pixelSearchDefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > pixelSearchMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = value
+	}
+
+	ctx := c.Request.Context()
+	pixels, err := s.store.SearchPixels(ctx, terms, pixelSearchMaxPixels)
+	if err != nil {
+		log.Printf("pixel search: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search pixels"})
+		return
+	}
+	state := storage.PixelState{Width: storage.GridWidth, Height: storage.GridHeight, Pixels: pixels}
+	if err := s.hideContestedPixels(ctx, &state); err != nil {
+		log.Printf("pixel search: hide contested pixels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search pixels"})
+		return
+	}
+	visible := state.Pixels[:0]
+	for _, pixel := range state.Pixels {
+		if pixel.Status == "taken" && pixel.URL != "" {
+			visible = append(visible, pixel)
+		}
+	}
+
+	regions := groupSearchRegions(visible)
+	if len(regions) > limit {
+		regions = regions[:limit]
+	}
+	if regions == nil {
+		regions = []pixelSearchRegion{}
+	}
+	c.JSON(http.StatusOK, gin.H{"query": strings.Join(terms, " "), "results": regions})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	pixelStreamHeartbeat = 25 * time.Second
+	// pixelStreamRetry is the reconnect delay EventSource clients are told to use.
+	pixelStreamRetry = 3 * time.Second
+	// pixelFeedBuffer is how many changes a subscriber may lag behind before it is dropped.
+	pixelFeedBuffer = 64
+)
+
+// pixelChange is one event of the live pixel feed. Pixels carries the new public states when the
+// writer knew them; otherwise clients refetch the listed ids.
+type pixelChange struct {
+	Seq    uint64          `json:"seq"`
+	IDs    []int           `json:"ids"`
+	Pixels []storage.Pixel `json:"pixels,omitempty"`
+}
+
+// PixelFeed broadcasts pixel changes to live subscribers. A subscriber that falls more than
+// pixelFeedBuffer changes behind is disconnected so writers never wait on a stalled client;
+// it reconnects and refetches the grid.
+type PixelFeed struct {
+	mu          sync.Mutex
+	seq         uint64
+	subscribers map[chan pixelChange]struct{}
+}
+
+func NewPixelFeed() *PixelFeed {
+	return &PixelFeed{subscribers: make(map[chan pixelChange]struct{})}
+}
+
+// Subscribe returns a channel receiving every change published from now on and a function
+// ending the subscription. The channel is closed when the subscriber is dropped or cancelled.
+func (f *PixelFeed) Subscribe() (<-chan pixelChange, func()) {
+	ch := make(chan pixelChange, pixelFeedBuffer)
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish assigns the next sequence number to change and delivers it to all subscribers.
+func (f *PixelFeed) Publish(change pixelChange) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	change.Seq = f.seq
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribers returns the number of live subscribers.
+func (f *PixelFeed) Subscribers() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers)
+}
+
+// pixelsChanged is gridChanged for writes whose resulting states are known, so subscribers
+// receive them directly and the in-memory grid is updated without a query. Pixels under a
+// takedown are published as the grid shows them.
+func (s *Server) pixelsChanged(ctx context.Context, pixels []storage.Pixel) {
+	ids := make([]int, len(pixels))
+	for i, pixel := range pixels {
+		ids[i] = pixel.ID
+	}
+	s.gridSnapshot.Apply(pixels)
+	s.purgeGrid(ids)
+	if s.pixelFeed.Subscribers() == 0 {
+		return
+	}
+	state := storage.PixelState{Pixels: append([]storage.Pixel(nil), pixels...)}
+	if err := s.hideContestedPixels(ctx, &state); err != nil {
+		log.Printf("pixel stream: load hidden pixels: %v", err)
+		s.pixelFeed.Publish(pixelChange{IDs: ids})
+		return
+	}
+	s.pixelFeed.Publish(pixelChange{IDs: ids, Pixels: state.Pixels})
+}
+
+// handlePixelStream is the Server-Sent Events variant of the live pixel channel for clients
+// without WebSocket support. Every change is sent as a "pixels" event and a "heartbeat" event
+// keeps idle connections open through proxies.
+func (s *Server) handlePixelStream(c *gin.Context) {
+	events, unsubscribe := s.pixelFeed.Subscribe()
+	defer unsubscribe()
+
+	flusher, ok := startEventStream(c)
+	if !ok {
+		return
+	}
+
+	heartbeat := time.NewTicker(pixelStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case change, open := <-events:
+			if !open {
+				return
+			}
+			data, marshalErr := json.Marshal(change)
+			if marshalErr != nil {
+				log.Printf("pixel stream: encode change %d: %v", change.Seq, marshalErr)
+				continue
+			}
+			_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: pixels\ndata: %s\n\n", change.Seq, data)
+		case now := <-heartbeat.C:
+			_, err = fmt.Fprintf(c.Writer, "event: heartbeat\ndata: {\"time\":%q}\n\n", now.UTC().Format(time.RFC3339))
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// startEventStream sends the Server-Sent Events response headers and the reconnect delay.
+// It returns false when the response cannot be streamed or the client is gone.
+func startEventStream(c *gin.Context) (http.Flusher, bool) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return nil, false
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(c.Writer, "retry: %d\n\n", pixelStreamRetry.Milliseconds()); err != nil {
+		return nil, false
+	}
+	flusher.Flush()
+	return flusher, true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const defaultTileSize = 100
+
+func (s *Server) pixelTileSize() int {
+	if s.tileSize > 0 {
+		return s.tileSize
+	}
+	return defaultTileSize
+}
+
+// handleGetPixelTile returns the pixels of one square tile so the frontend can load only the
+// visible part of the canvas. Tile (x, y) covers columns x*size to (x+1)*size-1 and the same
+// rows; tiles at the right and bottom edge are cut to the grid. Pixel ids stay grid-wide, and
+// ?fields works as for GET /api/pixels.
+func (s *Server) handleGetPixelTile(c *gin.Context) {
+	fields, err := storage.ParsePixelFields(c.Query("fields"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	size := s.pixelTileSize()
+	tileX, errX := strconv.Atoi(c.Param("x"))
+	tileY, errY := strconv.Atoi(c.Param("y"))
+	if errX != nil || errY != nil || tileX < 0 || tileY < 0 || tileX*size >= storage.GridWidth || tileY*size >= storage.GridHeight {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tile not found"})
+		return
+	}
+	x, y := tileX*size, tileY*size
+	width, height := min(size, storage.GridWidth-x), min(size, storage.GridHeight-y)
+
+	key := fmt.Sprintf("tile size=%d x=%d y=%d fields=%d", size, tileX, tileY, fields)
+	s.serveGrid(c, key, "application/json", func(ctx context.Context) (func(io.Writer) error, error) {
+		pixels, err := s.store.GetPixelsInRect(ctx, x, y, width, height)
+		if err != nil {
+			return nil, err
+		}
+		state := storage.PixelState{Width: width, Height: height, Pixels: pixels}
+		if err := s.hideContestedPixels(ctx, &state); err != nil {
+			return nil, err
+		}
+		return func(w io.Writer) error { return state.WriteJSONFields(w, fields) }, nil
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+var (
+	errPriceQuoteInvalid = errors.New("price quote is invalid")
+	errPriceQuoteExpired = errors.New("price quote expired")
+)
+
+// priceList is the set of prices a purchase is charged by. Its version changes whenever one of
+// the prices does, so instances configured alike agree on it.
+type priceList struct {
+	Version         string `json:"price_version"`
+	PixelCostPoints int64  `json:"pixel_cost_points"`
+	PointsPerDay    int64  `json:"rental_points_per_day"`
+}
+
+func newPriceList(pixelCost, pointsPerDay int64) priceList {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("pixel=%d;day=%d", pixelCost, pointsPerDay)))
+	return priceList{Version: "p" + hex.EncodeToString(sum[:4]), PixelCostPoints: pixelCost, PointsPerDay: pointsPerDay}
+}
+
+// currentPrices returns the prices configured on this instance.
+func (s *Server) currentPrices() priceList {
+	return newPriceList(s.pixelCostPoints, s.rentals.PointsPerDay)
+}
+
+// quoteSigner issues price quotes of the form version.pixelCost.pointsPerDay.expiryUnix.mac. The
+// quote carries its prices, so an instance honours quotes for prices it no longer charges as long
+// as they are signed with the shared secret and not expired. The MAC covers the user id, so a
+// quote cannot be passed on to other accounts.
+type quoteSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// newQuoteSigner returns a signer for secret, or for a random key when secret is empty; quotes
+// are then only honoured by the instance that issued them.
+func newQuoteSigner(secret string, ttl time.Duration) (*quoteSigner, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("generate price quote key: %w", err)
+		}
+	}
+	return &quoteSigner{secret: key, ttl: ttl}, nil
+}
+
+func (q *quoteSigner) sign(userID int64, prices priceList, now time.Time) (string, time.Time) {
+	expires := time.Unix(now.Add(q.ttl).Unix(), 0).UTC()
+	payload := prices.Version + "." + strconv.FormatInt(prices.PixelCostPoints, 10) + "." +
+		strconv.FormatInt(prices.PointsPerDay, 10) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + q.mac(userID, payload), expires
+}
+
+func (q *quoteSigner) mac(userID int64, payload string) string {
+	mac := hmac.New(sha256.New, q.secret)
+	mac.Write([]byte("quote." + strconv.FormatInt(userID, 10) + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the prices locked by token for userID.
+func (q *quoteSigner) verify(userID int64, token string, now time.Time) (priceList, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return priceList{}, errPriceQuoteInvalid
+	}
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(parts[4]), []byte(q.mac(userID, payload))) {
+		return priceList{}, errPriceQuoteInvalid
+	}
+	pixelCost, errCost := strconv.ParseInt(parts[1], 10, 64)
+	pointsPerDay, errDay := strconv.ParseInt(parts[2], 10, 64)
+	expiry, errExpiry := strconv.ParseInt(parts[3], 10, 64)
+	if errCost != nil || errDay != nil || errExpiry != nil {
+		return priceList{}, errPriceQuoteInvalid
+	}
+	if !now.Before(time.Unix(expiry, 0)) {
+		return priceList{}, errPriceQuoteExpired
+	}
+	return priceList{Version: parts[0], PixelCostPoints: pixelCost, PointsPerDay: pointsPerDay}, nil
+}
+
+// priceQuote renders a fresh quote of the current prices for user.
+func (s *Server) priceQuote(c *gin.Context, user storage.User) gin.H {
+	prices := s.currentPrices()
+	token, expires := s.priceQuotes.sign(user.ID, prices, time.Now())
+	quote := gin.H{
+		"quote":             token,
+		"price_version":     prices.Version,
+		"pixel_cost_points": prices.PixelCostPoints,
+		"pixel_price":       s.pointsPrice(c, prices.PixelCostPoints),
+		"expires_at":        expires,
+	}
+	if s.rentals.Enabled {
+		quote["rental_points_per_day"] = prices.PointsPerDay
+	}
+	return quote
+}
+
+// handlePriceQuote locks the current prices for the signed-in user. Sending the quote with a
+// purchase charges these prices even if they change before the purchase arrives.
+func (s *Server) handlePriceQuote(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if s.priceQuotes == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "price quotes are disabled"})
+		return
+	}
+	c.Writer.Header().Set("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, s.priceQuote(c, user))
+}
+
+// resolvePurchasePrices settles which prices req is charged: those of its quote, or the current
+// ones without a quote. An expired quote is answered with 409 and a fresh quote, so the buyer can
+// confirm the new prices; it returns false when a response was written.
+func (s *Server) resolvePurchasePrices(c *gin.Context, user storage.User, req *UpdatePixelRequest) bool {
+	prices := s.currentPrices()
+	if req.Quote != "" {
+		if s.priceQuotes == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "price quotes are disabled", "code": "quote_invalid"})
+			return false
+		}
+		quoted, err := s.priceQuotes.verify(user.ID, req.Quote, time.Now())
+		switch {
+		case errors.Is(err, errPriceQuoteExpired):
+			c.JSON(http.StatusConflict, gin.H{"error": "wycena wygasła. Sprawdź aktualne ceny i potwierdź zakup ponownie.", "code": "quote_expired", "current": s.priceQuote(c, user)})
+			return false
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid price quote", "code": "quote_invalid"})
+			return false
+		}
+		if quoted.Version != prices.Version {
+			log.Printf("price quote: user_id=%d honouring price_version=%s current=%s", user.ID, quoted.Version, prices.Version)
+		}
+		prices = quoted
+	}
+	req.prices = &prices
+	return true
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/currency"
+)
+
+// pointsPrice returns the value of points in the currency requested via ?currency= or the
+// configured display currency. Pricing is informational, so failures are logged and yield nil.
+func (s *Server) pointsPrice(c *gin.Context, points int64) *currency.Price {
+	if s.currency == nil {
+		return nil
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(c.Query("currency")))
+	if code == "" {
+		code = s.displayCurrency
+	}
+	price, err := s.currency.Price(c.Request.Context(), points, code)
+	if errors.Is(err, currency.ErrUnsupportedCurrency) && code != s.displayCurrency {
+		price, err = s.currency.Price(c.Request.Context(), points, s.displayCurrency)
+	}
+	if err != nil {
+		log.Printf("price points: points=%d currency=%s err=%v", points, code, err)
+		return nil
+	}
+	return &price
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/jobs"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	purchaseJobKind     = "pixel_purchase"
+	purchaseJobWorkers  = 2
+	purchaseJobCapacity = 50
+	// purchaseJobRetention is how long a finished job can still be polled.
+	purchaseJobRetention = 24 * time.Hour
+)
+
+// enqueuePurchase hands a large purchase to the job queue and answers 202 with the job to poll,
+// so the request does not run into gateway timeouts. It reports whether the purchase was queued.
+func (s *Server) enqueuePurchase(c *gin.Context, user storage.User, req UpdatePixelRequest) bool {
+	task, err := s.purchaseJobs.Submit(purchaseJobKind, user.ID, func(ctx context.Context) (any, error) {
+		purchase := s.applyPixelUpdates(ctx, user, req)
+		s.notifyPurchaseDone(ctx, user, len(req.Pixels), purchase)
+		if !purchase.updated {
+			return purchase.response(nil, nil), errors.New(purchase.errorMessage())
+		}
+		return purchase.response(nil, nil), nil
+	})
+	if err != nil {
+		if errors.Is(err, jobs.ErrQueueFull) {
+			c.Writer.Header().Set("Retry-After", "30")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "zbyt wiele zakupów w kolejce. Spróbuj ponownie za chwilę.", "code": "jobs_busy"})
+			return false
+		}
+		log.Printf("purchase job: submit for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue purchase"})
+		return false
+	}
+
+	log.Printf("purchase job: queued id=%s user_id=%d pixels=%d", task.ID, user.ID, len(req.Pixels))
+	c.JSON(http.StatusAccepted, gin.H{
+		"job":        task,
+		"status_url": "/api/jobs/" + task.ID,
+	})
+	return true
+}
+
+// notifyPurchaseDone emails the buyer once an asynchronous purchase finished. Failures are only logged.
+func (s *Server) notifyPurchaseDone(ctx context.Context, user storage.User, requested int, purchase pixelPurchase) {
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		log.Printf("purchase job: mailer cannot send notices; user %d not notified", user.ID)
+		return
+	}
+	notice := email.Notice{
+		Subject: "Zakup pikseli zakończony",
+		Body: fmt.Sprintf("Przetworzyliśmy Twój zakup %d pikseli. Kupione piksele: %d, wykorzystane punkty: %d.\nSzczegóły znajdziesz na swoim koncie.",
+			requested, purchase.purchased, purchase.spent),
+	}
+	if !purchase.updated {
+		notice.Subject = "Zakup pikseli nie powiódł się"
+		notice.Body = fmt.Sprintf("Nie udało się zrealizować zakupu %d pikseli: %s\nPunkty nie zostały pobrane.", requested, purchase.errorMessage())
+	}
+	if err := sender.SendNotice(ctx, user.Email, notice); err != nil {
+		log.Printf("purchase job: notify user %d: %v", user.ID, err)
+	}
+}
+
+// handleJob reports the status of a background job to the user who started it.
+func (s *Server) handleJob(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	if s.purchaseJobs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	task, found := s.purchaseJobs.Get(c.Param("id"))
+	if !found || task.OwnerID != user.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": task})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/fcm"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const maxPushTokenLength = 255
+
+var pushPlatforms = map[string]bool{"android": true, "ios": true, "web": true}
+
+// pushSender delivers a notification to one device; *fcm.Client implements it.
+type pushSender interface {
+	Send(ctx context.Context, deviceToken string, msg fcm.Message) error
+}
+
+type pushDeviceRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+// notifyPush sends msg to every device of the user. Tokens FCM no longer knows are forgotten;
+// other failures are only logged.
+func (s *Server) notifyPush(ctx context.Context, userID int64, msg fcm.Message) {
+	if s.push == nil {
+		return
+	}
+	devices, err := s.store.ListPushDevices(ctx, userID)
+	if err != nil {
+		log.Printf("push: list devices user_id=%d: %v", userID, err)
+		return
+	}
+	for _, device := range devices {
+		err := s.push.Send(ctx, device.Token, msg)
+		switch {
+		case errors.Is(err, fcm.ErrUnregistered):
+			log.Printf("push: forgetting unregistered device user_id=%d platform=%s", userID, device.Platform)
+			if err := s.store.DeletePushDevice(ctx, userID, device.Token); err != nil {
+				log.Printf("push: delete device user_id=%d: %v", userID, err)
+			}
+		case err != nil:
+			log.Printf("push: send user_id=%d platform=%s: %v", userID, device.Platform, err)
+		}
+	}
+}
+
+// requirePush answers 404 while no FCM key is configured.
+func (s *Server) requirePush(c *gin.Context) bool {
+	if s.push == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "push notifications are disabled"})
+		return false
+	}
+	return true
+}
+
+func bindPushDevice(c *gin.Context) (pushDeviceRequest, bool) {
+	var req pushDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return pushDeviceRequest{}, false
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" || len(req.Token) > maxPushTokenLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid device token"})
+		return pushDeviceRequest{}, false
+	}
+	return req, true
+}
+
+// handleRegisterPushDevice registers the app's FCM token for the signed-in user. Registering a
+// token again refreshes it; a token registered by another user moves to this one.
+func (s *Server) handleRegisterPushDevice(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requirePush(c) || s.rejectWrites(c) {
+		return
+	}
+	req, ok := bindPushDevice(c)
+	if !ok {
+		return
+	}
+	platform := strings.ToLower(strings.TrimSpace(req.Platform))
+	if !pushPlatforms[platform] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "platform must be android, ios or web"})
+		return
+	}
+	device, err := s.store.RegisterPushDevice(c.Request.Context(), storage.PushDevice{Token: req.Token, UserID: user.ID, Platform: platform})
+	if err != nil {
+		log.Printf("push: register device user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register device"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"device": device})
+}
+
+// handleDeletePushDevice stops notifications to a device of the signed-in user, e.g. on logout.
+func (s *Server) handleDeletePushDevice(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok || !s.requirePush(c) || s.rejectWrites(c) {
+		return
+	}
+	req, ok := bindPushDevice(c)
+	if !ok {
+		return
+	}
+	if err := s.store.DeletePushDevice(c.Request.Context(), user.ID, req.Token); err != nil {
+		log.Printf("push: delete device user_id=%d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete device"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	readOnlySettingName    = "read_only"
+	defaultReadOnlyMessage = "Serwis działa chwilowo w trybie tylko do odczytu. Spróbuj ponownie za kilka minut."
+)
+
+// readOnlyState describes why writes are currently disabled.
+type readOnlyState struct {
+	Message   string    `json:"message"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+type readOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// readOnlyStatus returns the active read-only state set in config or by an admin, or nil.
+func (s *Server) readOnlyStatus(ctx context.Context) *readOnlyState {
+	if s.readOnly {
+		return &readOnlyState{Message: defaultReadOnlyMessage, Source: "config"}
+	}
+	raw, err := s.store.GetSetting(ctx, readOnlySettingName)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("load read-only flag: %v", err)
+		}
+		return nil
+	}
+	var state readOnlyState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		log.Printf("decode read-only flag: %v", err)
+		return nil
+	}
+	return &state
+}
+
+// rejectWrites answers with 503 when the site is read-only, either explicitly or because a
+// maintenance window is in progress. Reads and logging in keep working.
+func (s *Server) rejectWrites(c *gin.Context) bool {
+	if state := s.readOnlyStatus(c.Request.Context()); state != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": state.Message, "code": "read_only"})
+		return true
+	}
+	return s.rejectDuringMaintenance(c)
+}
+
+func (s *Server) handlePutReadOnly(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if !req.Enabled {
+		if err := s.store.DeleteSetting(ctx, readOnlySettingName); err != nil {
+			log.Printf("clear read-only flag: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update read-only mode"})
+			return
+		}
+		log.Printf("read-only mode disabled: admin_id=%d", admin.ID)
+		c.JSON(http.StatusOK, gin.H{"read_only": s.readOnlyStatus(ctx)})
+		return
+	}
+
+	state := readOnlyState{Message: strings.TrimSpace(req.Message), Source: "admin", UpdatedAt: time.Now().UTC()}
+	if state.Message == "" {
+		state.Message = defaultReadOnlyMessage
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("encode read-only flag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update read-only mode"})
+		return
+	}
+	if err := s.store.PutSetting(ctx, readOnlySettingName, string(data)); err != nil {
+		log.Printf("save read-only flag: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update read-only mode"})
+		return
+	}
+
+	log.Printf("read-only mode enabled: admin_id=%d", admin.ID)
+	c.JSON(http.StatusOK, gin.H{"read_only": s.readOnlyStatus(ctx)})
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/activationcode"
+)
+
+const (
+	redeemHandoffCookieName    = "kup_pixel_redeem"
+	redeemHandoffTTL           = 30 * time.Minute
+	redeemHandoffPruneInterval = time.Minute
+	// The /redeem route is public, so pending handoffs are capped per client source (IP bucket)
+	// and overall. The device cookie is no use here: a client that sends none gets a new one.
+	redeemHandoffMaxPerSource = 5
+	redeemHandoffMaxTotal     = 10000
+)
+
+// errRedeemHandoffLimit is returned by Create while the source or the server holds the most
+// pending handoffs allowed.
+var errRedeemHandoffLimit = errors.New("too many pending redeem handoffs")
+
+// redeemHandoff links an activation code from a deep link to the browser that opened it.
+type redeemHandoff struct {
+	code      string
+	userID    int64
+	source    string
+	expiresAt time.Time
+}
+
+// RedeemHandoffManager keeps activation codes opened via /redeem links until the user confirms them.
+type RedeemHandoffManager struct {
+	mu        sync.Mutex
+	handoffs  map[string]redeemHandoff
+	perSource map[string]int
+	now       func() time.Time
+}
+
+func NewRedeemHandoffManager() *RedeemHandoffManager {
+	return &RedeemHandoffManager{handoffs: make(map[string]redeemHandoff), perSource: make(map[string]int), now: time.Now}
+}
+
+// Create stores a new handoff for the code opened from source and returns its identifier. It
+// fails with errRedeemHandoffLimit while the source or the server is at its cap; expired handoffs
+// keep counting until Prune removes them.
+func (m *RedeemHandoffManager) Create(code string, userID int64, source string) (string, error) {
+	id, err := generateSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.handoffs) >= redeemHandoffMaxTotal || m.perSource[source] >= redeemHandoffMaxPerSource {
+		return "", errRedeemHandoffLimit
+	}
+	m.handoffs[id] = redeemHandoff{code: code, userID: userID, source: source, expiresAt: m.now().Add(redeemHandoffTTL)}
+	m.perSource[source]++
+	return id, nil
+}
+
+// Prune removes expired handoffs and returns how many were removed.
+func (m *RedeemHandoffManager) Prune() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	removed := 0
+	for id, handoff := range m.handoffs {
+		if now.After(handoff.expiresAt) {
+			m.remove(id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// remove forgets a handoff. Callers hold mu.
+func (m *RedeemHandoffManager) remove(id string) {
+	handoff, ok := m.handoffs[id]
+	if !ok {
+		return
+	}
+	delete(m.handoffs, id)
+	if m.perSource[handoff.source] <= 1 {
+		delete(m.perSource, handoff.source)
+	} else {
+		m.perSource[handoff.source]--
+	}
+}
+
+// Claim returns the code for a handoff, binding it to the user on first use.
+// A handoff already bound to another user is treated as missing.
+func (m *RedeemHandoffManager) Claim(id string, userID int64) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	handoff, ok := m.handoffs[id]
+	if !ok {
+		return "", false
+	}
+	if m.now().After(handoff.expiresAt) {
+		m.remove(id)
+		return "", false
+	}
+	if handoff.userID != 0 && handoff.userID != userID {
+		return "", false
+	}
+	handoff.userID = userID
+	m.handoffs[id] = handoff
+	return handoff.code, true
+}
+
+func (m *RedeemHandoffManager) Delete(id string) {
+	m.mu.Lock()
+	m.remove(id)
+	m.mu.Unlock()
+}
+
+func setRedeemHandoffCookie(c *gin.Context, id string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     redeemHandoffCookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   int(redeemHandoffTTL / time.Second),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearRedeemHandoffCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     redeemHandoffCookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func readRedeemHandoffCookie(r *http.Request) string {
+	cookie, err := r.Cookie(redeemHandoffCookieName)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(cookie.Value)
+}
+
+// claimPendingRedeemCode returns the activation code handed off to the current browser, if any.
+func (s *Server) claimPendingRedeemCode(c *gin.Context, userID int64) (string, string, bool) {
+	if s.redeemHandoffs == nil {
+		return "", "", false
+	}
+	id := readRedeemHandoffCookie(c.Request)
+	if id == "" {
+		return "", "", false
+	}
+	code, ok := s.redeemHandoffs.Claim(id, userID)
+	return id, code, ok
+}
+
+// handleRedeemLink serves QR deep links (/redeem?code=...). The code is stashed server-side and
+// linked to the browser via a cookie; the SPA then asks /api/activation-codes/pending for the
+// code and redeems it after the user confirms and passes the Turnstile check. Once the client
+// source or the server holds too many pending codes, the page is served without stashing another one.
+func (s *Server) handleRedeemLink(c *gin.Context) {
+	code := activationcode.Normalize(c.Query("code"))
+	if s.codeFormat.Valid(code) && s.redeemHandoffs != nil {
+		var userID int64
+		if user, _, ok := s.getSessionUser(c); ok {
+			userID = user.ID
+		}
+		source := s.clientSource(c.Request)
+		id, err := s.redeemHandoffs.Create(code, userID, source)
+		if errors.Is(err, errRedeemHandoffLimit) {
+			log.Printf("redeem link: handoff rejected ip=%s: %v", source, err)
+		} else if err != nil {
+			log.Printf("create redeem handoff: %v", err)
+		} else {
+			setRedeemHandoffCookie(c, id)
+			log.Printf("redeem link: handoff created user_id=%d", userID)
+		}
+	}
+	serveIndex(c)
+}
+
+// handlePendingActivationCode returns the code handed off to this browser for confirmation along
+// with its value. Looking the code up tells whether it exists, so the lookup is throttled like a
+// redemption: a blocked source is refused, a missing code counts as a failed guess with the
+// RedemptionGuard, and once the source has to solve a challenge only the code is returned and the
+// Turnstile-protected redemption decides.
+func (s *Server) handlePendingActivationCode(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+
+	handoffID, code, ok := s.claimPendingRedeemCode(c, user.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "brak oczekującego kodu"})
+		return
+	}
+
+	sourceKeys := redemptionSourceKeys(s.clientSource(c.Request), redemptionDeviceID(c), user.ID)
+	verdict := s.redemptionGuard.Check(sourceKeys)
+	if verdict.retryAfter > 0 {
+		c.Writer.Header().Set("Retry-After", strconv.Itoa(int(verdict.retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "zbyt wiele nieudanych prób. Spróbuj ponownie później."})
+		return
+	}
+	if verdict.requireChallenge {
+		c.JSON(http.StatusOK, gin.H{"code": code, "captcha": "challenge"})
+		return
+	}
+
+	record, err := s.store.GetActivationCode(c.Request.Context(), code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.redemptionGuard.RecordFailure(sourceKeys, code)
+			s.redeemHandoffs.Delete(handoffID)
+			clearRedeemHandoffCookie(c)
+			response := gin.H{"error": "kod nie istnieje lub został już wykorzystany.", "code": code}
+			if s.redemptionGuard.Check(sourceKeys).requireChallenge {
+				response["captcha"] = "challenge"
+			}
+			c.JSON(http.StatusGone, response)
+			return
+		}
+		log.Printf("pre-validate activation code for user %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "nie udało się sprawdzić kodu"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": record.Code, "value": record.Value})
+}
+
+func (s *Server) handleCancelPendingActivationCode(c *gin.Context) {
+	if id := readRedeemHandoffCookie(c.Request); id != "" && s.redeemHandoffs != nil {
+		s.redeemHandoffs.Delete(id)
+	}
+	clearRedeemHandoffCookie(c)
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+const (
+	deviceCookieName   = "kup_pixel_device"
+	deviceCookieMaxAge = 365 * 24 * 60 * 60
+
+	redemptionWindow            = time.Hour
+	redemptionBlockDuration     = 15 * time.Minute
+	redemptionCaptchaThreshold  = 3
+	redemptionBlockThreshold    = 10
+	redemptionSequentialLimit   = 4
+	redemptionSuccessAlertLimit = 10
+	redemptionBackoffFree       = 2
+	redemptionBackoffBase       = 2 * time.Second
+	redemptionBackoffMax        = 10 * time.Minute
+	maxRedemptionAlerts         = 200
+	// redemptionSweepSize is the number of tracked sources above which idle ones are dropped.
+	redemptionSweepSize = 10000
+
+	// redeemChallengeAction is the Turnstile action the frontend sets on the interactive
+	// widget it shows once a source has been escalated.
+	redeemChallengeAction = "redeem-challenge"
+)
+
+// redemptionSource tracks recent redemption activity of a single IP, device or account.
+type redemptionSource struct {
+	failures     []time.Time
+	successes    []time.Time
+	lastFailed   string
+	sequential   int
+	streak       int
+	nextAttempt  time.Time
+	blockedUntil time.Time
+}
+
+// RedemptionAlert is raised for admins when a source shows a suspicious redemption pattern.
+type RedemptionAlert struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Reason string    `json:"reason"`
+}
+
+// RedemptionGuard applies fraud heuristics to activation code redemption: it counts invalid
+// guesses, detects sequential guessing and bulk redemption, escalates the captcha and
+// temporarily blocks sources that look like they are brute forcing the code space.
+// Consecutive invalid codes additionally make a source wait exponentially longer between attempts.
+type RedemptionGuard struct {
+	mu      sync.Mutex
+	sources map[string]*redemptionSource
+	alerts  []RedemptionAlert
+	guesses int64
+	now     func() time.Time
+}
+
+func NewRedemptionGuard() *RedemptionGuard {
+	return &RedemptionGuard{sources: make(map[string]*redemptionSource), now: time.Now}
+}
+
+// redemptionVerdict describes how a redemption attempt from a set of sources must be handled.
+type redemptionVerdict struct {
+	retryAfter       time.Duration
+	requireChallenge bool
+}
+
+func redemptionSourceKeys(ip, device string, userID int64) []string {
+	keys := make([]string, 0, 3)
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	if device != "" {
+		keys = append(keys, "device:"+device)
+	}
+	if userID > 0 {
+		keys = append(keys, "user:"+strconv.FormatInt(userID, 10))
+	}
+	return keys
+}
+
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+// idle reports whether the source has nothing left to enforce: no events inside the window and
+// no block or backoff running.
+func (src *redemptionSource) idle(now time.Time) bool {
+	return len(src.failures) == 0 && len(src.successes) == 0 && !src.blockedUntil.After(now) && !src.nextAttempt.After(now)
+}
+
+// source returns the state for key with events outside the window dropped, or nil when key is
+// not tracked. Sources that went idle are forgotten. Callers hold mu.
+func (g *RedemptionGuard) source(key string, now time.Time) *redemptionSource {
+	src, ok := g.sources[key]
+	if !ok {
+		return nil
+	}
+	cutoff := now.Add(-redemptionWindow)
+	src.failures = pruneBefore(src.failures, cutoff)
+	src.successes = pruneBefore(src.successes, cutoff)
+	if len(src.failures) == 0 {
+		src.streak = 0
+	}
+	if src.idle(now) {
+		delete(g.sources, key)
+		return nil
+	}
+	return src
+}
+
+// track returns the state for key, starting to track it when needed. Idle sources of other keys
+// are swept once too many are tracked. Callers hold mu.
+func (g *RedemptionGuard) track(key string, now time.Time) *redemptionSource {
+	if src := g.source(key, now); src != nil {
+		return src
+	}
+	if len(g.sources) >= redemptionSweepSize {
+		for other := range g.sources {
+			g.source(other, now)
+		}
+	}
+	src := &redemptionSource{}
+	g.sources[key] = src
+	return src
+}
+
+// Check reports how long the caller has to wait before the next attempt (zero when allowed)
+// and whether it must solve an interactive challenge.
+func (g *RedemptionGuard) Check(keys []string) redemptionVerdict {
+	var verdict redemptionVerdict
+	if g == nil {
+		return verdict
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for _, key := range keys {
+		src := g.source(key, now)
+		if src == nil {
+			continue
+		}
+		for _, until := range []time.Time{src.blockedUntil, src.nextAttempt} {
+			if wait := until.Sub(now); wait > verdict.retryAfter {
+				verdict.retryAfter = wait
+			}
+		}
+		if len(src.failures) >= redemptionCaptchaThreshold || len(src.successes) >= redemptionSuccessAlertLimit {
+			verdict.requireChallenge = true
+		}
+	}
+	return verdict
+}
+
+// RecordFailure counts an invalid code guess against all keys and applies blocks and alerts.
+func (g *RedemptionGuard) RecordFailure(keys []string, code string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	g.guesses++
+	for _, key := range keys {
+		src := g.track(key, now)
+		src.failures = append(src.failures, now)
+		src.streak++
+		if src.streak > redemptionBackoffFree {
+			src.nextAttempt = now.Add(redemptionBackoff(src.streak - redemptionBackoffFree))
+		}
+		if src.lastFailed != "" && codesLookSequential(src.lastFailed, code) {
+			src.sequential++
+		} else {
+			src.sequential = 0
+		}
+		src.lastFailed = code
+
+		switch {
+		case src.sequential >= redemptionSequentialLimit:
+			g.block(key, src, now, fmt.Sprintf("sequential code guessing (%d similar attempts)", src.sequential+1))
+		case len(src.failures) >= redemptionBlockThreshold:
+			g.block(key, src, now, fmt.Sprintf("%d invalid codes within %s", len(src.failures), redemptionWindow))
+		}
+	}
+}
+
+// RecordSuccess notes a redeemed code and alerts when a single source redeems unusually many.
+func (g *RedemptionGuard) RecordSuccess(keys []string) {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for _, key := range keys {
+		src := g.track(key, now)
+		src.successes = append(src.successes, now)
+		src.sequential = 0
+		src.streak = 0
+		src.nextAttempt = time.Time{}
+		if len(src.successes) == redemptionSuccessAlertLimit {
+			g.alert(key, now, fmt.Sprintf("%d codes redeemed within %s", len(src.successes), redemptionWindow))
+		}
+	}
+}
+
+// redemptionBackoff returns the delay after the n-th penalised failure in a row.
+func redemptionBackoff(n int) time.Duration {
+	delay := redemptionBackoffBase
+	for i := 1; i < n; i++ {
+		delay *= 2
+		if delay >= redemptionBackoffMax {
+			return redemptionBackoffMax
+		}
+	}
+	return delay
+}
+
+func (g *RedemptionGuard) block(key string, src *redemptionSource, now time.Time, reason string) {
+	if src.blockedUntil.After(now) {
+		return
+	}
+	src.blockedUntil = now.Add(redemptionBlockDuration)
+	g.alert(key, now, reason+"; blocked for "+redemptionBlockDuration.String())
+}
+
+func (g *RedemptionGuard) alert(key string, now time.Time, reason string) {
+	log.Printf("redemption alert: source=%s reason=%q", key, reason)
+	g.alerts = append(g.alerts, RedemptionAlert{Time: now.UTC(), Source: key, Reason: reason})
+	if len(g.alerts) > maxRedemptionAlerts {
+		g.alerts = g.alerts[len(g.alerts)-maxRedemptionAlerts:]
+	}
+}
+
+// Snapshot returns the recorded alerts (newest first) and the total number of invalid guesses.
+func (g *RedemptionGuard) Snapshot() ([]RedemptionAlert, int64) {
+	if g == nil {
+		return []RedemptionAlert{}, 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	alerts := make([]RedemptionAlert, len(g.alerts))
+	for i, alert := range g.alerts {
+		alerts[len(g.alerts)-1-i] = alert
+	}
+	return alerts, g.guesses
+}
+
+// codesLookSequential reports whether two guesses differ in at most two characters,
+// which is typical for enumerating the code space.
+func codesLookSequential(a, b string) bool {
+	if len(a) != len(b) || a == b {
+		return false
+	}
+	diff := 0
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			diff++
+		}
+	}
+	return diff <= 2
+}
+
+// redemptionDeviceID returns the device identifier cookie, issuing a new one when missing.
+func redemptionDeviceID(c *gin.Context) string {
+	if cookie, err := c.Request.Cookie(deviceCookieName); err == nil && cookie.Value != "" && len(cookie.Value) <= 64 {
+		return cookie.Value
+	}
+	id, err := generateSessionID()
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     deviceCookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   deviceCookieMaxAge,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+func (s *Server) handleRedemptionAlerts(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+
+	alerts, guesses := s.redemptionGuard.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":          alerts,
+		"invalid_guesses": guesses,
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const maxRefundReasonLength = 500
+
+type refundRequest struct {
+	UserID   int64  `json:"user_id"`
+	PixelIDs []int  `json:"pixel_ids"`
+	Reason   string `json:"reason"`
+	// Points overrides the credited amount; by default the user gets back what was recorded as
+	// paid for the freed pixels.
+	Points *int64 `json:"points"`
+}
+
+// handleCreateRefund lets an admin take pixels back from a user, e.g. after a chargeback or a
+// moderation decision, and credit the user points for them. The reason is stored with the refund.
+func (s *Server) handleCreateRefund(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok || s.rejectWrites(c) {
+		return
+	}
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	switch {
+	case req.UserID <= 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	case req.Reason == "" || utf8.RuneCountInString(req.Reason) > maxRefundReasonLength:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required and limited to 500 characters"})
+		return
+	case len(req.PixelIDs) == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pixel_ids is required"})
+		return
+	case req.Points != nil && *req.Points < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "points cannot be negative"})
+		return
+	}
+	for _, id := range req.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid pixel id"})
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	if _, err := s.store.GetUserByID(ctx, req.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("refund: load user_id=%d: %v", req.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund pixels"})
+		return
+	}
+	refund, release, err := s.store.RefundPixels(ctx, storage.PixelRefund{
+		UserID:   req.UserID,
+		AdminID:  admin.ID,
+		PixelIDs: req.PixelIDs,
+		Reason:   req.Reason,
+	}, req.Points)
+	if err != nil {
+		log.Printf("refund: user_id=%d admin_id=%d: %v", req.UserID, admin.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refund pixels"})
+		return
+	}
+	if len(release.Pixels) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user owns none of the pixels", "code": "not_owned"})
+		return
+	}
+
+	now := time.Now().UTC()
+	freed := make([]storage.Pixel, len(release.Pixels))
+	for i, pixel := range release.Pixels {
+		freed[i] = storage.Pixel{ID: pixel.ID, Status: "free", UpdatedAt: now}
+	}
+	s.pixelsChanged(ctx, freed)
+	log.Printf("refund: id=%d user_id=%d admin_id=%d pixels=%d points=%d", refund.ID, refund.UserID, refund.AdminID, len(refund.PixelIDs), refund.Points)
+	c.JSON(http.StatusCreated, gin.H{"refund": refund, "user": sanitizeUser(release.User)})
+}
+
+// handleListRefunds lists refunds newest first, optionally only those of ?user_id=.
+func (s *Server) handleListRefunds(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	var userID int64
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		userID = id
+	}
+	refunds, err := s.store.ListPixelRefunds(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("refund: list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load refunds"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"refunds": refunds})
+}
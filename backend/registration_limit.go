@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+const (
+	registrationWindow = 24 * time.Hour
+	// registrationSweepSize is the number of tracked sources above which stale ones are dropped.
+	registrationSweepSize = 10000
+
+	// registerChallengeAction is the Turnstile action of the interactive widget the frontend
+	// shows when registration from a source has been escalated.
+	registerChallengeAction = "register-challenge"
+)
+
+// RegistrationLimiter counts accounts created per IP address and device cookie over a rolling
+// day. Busy sources first have to solve the interactive captcha and are refused once they
+// reach the daily limit. The counters live in memory, so a restart resets them.
+type RegistrationLimiter struct {
+	mu      sync.Mutex
+	limits  config.RegistrationLimits
+	sources map[string][]time.Time
+	now     func() time.Time
+}
+
+func NewRegistrationLimiter(limits config.RegistrationLimits) *RegistrationLimiter {
+	return &RegistrationLimiter{limits: limits, sources: make(map[string][]time.Time), now: time.Now}
+}
+
+// registrationVerdict describes how a registration attempt must be handled.
+type registrationVerdict struct {
+	retryAfter       time.Duration
+	requireChallenge bool
+	reason           string
+}
+
+// recent returns the registrations of key inside the window. Callers hold mu.
+func (l *RegistrationLimiter) recent(key string, now time.Time) []time.Time {
+	times := pruneBefore(l.sources[key], now.Add(-registrationWindow))
+	if len(times) == 0 {
+		delete(l.sources, key)
+		return nil
+	}
+	l.sources[key] = times
+	return times
+}
+
+// Check evaluates a registration from ip and device. knownDevice is false when the client did
+// not present a device cookie, which scripted signups rarely keep between requests.
+func (l *RegistrationLimiter) Check(ip, device string, knownDevice bool) registrationVerdict {
+	var verdict registrationVerdict
+	if l == nil {
+		return verdict
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, source := range []struct {
+		key   string
+		limit int
+	}{
+		{"ip:" + ip, l.limits.PerIPPerDay},
+		{"device:" + device, l.limits.PerDevicePerDay},
+	} {
+		if source.key == "ip:" || source.key == "device:" {
+			continue
+		}
+		times := l.recent(source.key, now)
+		if source.limit > 0 && len(times) >= source.limit {
+			if wait := times[len(times)-source.limit].Add(registrationWindow).Sub(now); wait > verdict.retryAfter {
+				verdict.retryAfter = wait
+				verdict.reason = source.key
+			}
+		}
+		if l.limits.ChallengeAfter > 0 && len(times) >= l.limits.ChallengeAfter {
+			verdict.requireChallenge = true
+		}
+	}
+	if !knownDevice && l.limits.ChallengeAfter > 0 && len(l.recent("ip:"+ip, now)) > 0 {
+		verdict.requireChallenge = true
+	}
+	return verdict
+}
+
+// Record counts a created account against ip and device.
+func (l *RegistrationLimiter) Record(ip, device string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.sources) > registrationSweepSize {
+		for key := range l.sources {
+			l.recent(key, now)
+		}
+	}
+	if ip != "" {
+		l.sources["ip:"+ip] = append(l.recent("ip:"+ip, now), now)
+	}
+	if device != "" {
+		l.sources["device:"+device] = append(l.recent("device:"+device, now), now)
+	}
+}
+
+// checkRegistrationLimit applies the limiter to the current request and answers it when the
+// source is over its daily limit. It returns the source identifiers to record on success and
+// the Turnstile action the request has to carry.
+func (s *Server) checkRegistrationLimit(c *gin.Context) (ip, device, challengeAction string, ok bool) {
+	_, cookieErr := c.Request.Cookie(deviceCookieName)
+	ip, device = s.clientSource(c.Request), redemptionDeviceID(c)
+	verdict := s.registrationLimiter.Check(ip, device, cookieErr == nil)
+	if verdict.retryAfter > 0 {
+		log.Printf("registration limit: blocked source=%s ip=%s retry_after=%s", verdict.reason, ip, verdict.retryAfter.Round(time.Second))
+		c.Writer.Header().Set("Retry-After", strconv.Itoa(int(verdict.retryAfter.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Z tego urządzenia lub sieci utworzono dziś zbyt wiele kont. Spróbuj ponownie później.",
+			"code":  "registration_limited",
+		})
+		return "", "", "", false
+	}
+	if verdict.requireChallenge {
+		challengeAction = registerChallengeAction
+	}
+	return ip, device, challengeAction, true
+}
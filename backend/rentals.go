@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/fcm"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	// pixelRentalCheckInterval is how often owners are warned about ending rentals and expired
+	// pixels are freed.
+	pixelRentalCheckInterval = 10 * time.Minute
+
+	ownerEventRentalEnding = "rental.ending"
+)
+
+// purchaseTerms returns the points charged per pixel under prices and when the pixels are
+// released. Without rentalDays pixels are bought for good at the regular price.
+func (s *Server) purchaseTerms(prices priceList, rentalDays int, now time.Time) (int64, *time.Time, error) {
+	switch {
+	case rentalDays == 0:
+		return prices.PixelCostPoints, nil, nil
+	case !s.rentals.Enabled:
+		return 0, nil, errors.New("wynajem pikseli jest wyłączony")
+	case rentalDays < 0 || rentalDays > s.rentals.MaxDays:
+		return 0, nil, fmt.Errorf("okres wynajmu musi wynosić od 1 do %d dni", s.rentals.MaxDays)
+	}
+	expiresAt := now.UTC().Add(time.Duration(rentalDays) * 24 * time.Hour)
+	return int64(rentalDays) * prices.PointsPerDay, &expiresAt, nil
+}
+
+// expirePixelRentals frees the pixels whose rental has ended and warns owners whose rentals end
+// within the configured window. Notification failures are only logged.
+func (s *Server) expirePixelRentals(ctx context.Context) error {
+	now := time.Now()
+	released, err := s.store.ReleaseExpiredPixels(ctx, now)
+	if err != nil {
+		return fmt.Errorf("release expired pixels: %w", err)
+	}
+	if len(released) > 0 {
+		freed := make([]storage.Pixel, len(released))
+		for i, pixel := range released {
+			freed[i] = storage.Pixel{ID: pixel.ID, Status: "free", UpdatedAt: now.UTC()}
+		}
+		s.pixelsChanged(ctx, freed)
+		log.Printf("pixel rentals: released %d expired pixels", len(released))
+	}
+
+	ending, err := s.store.ClaimExpiringPixels(ctx, now.Add(time.Duration(s.rentals.WarnBeforeHours)*time.Hour))
+	if err != nil {
+		return fmt.Errorf("claim expiring pixels: %w", err)
+	}
+	for ownerID, pixels := range pixelsByOwner(ending) {
+		s.notifyRentalEnding(ctx, ownerID, pixels)
+	}
+	return nil
+}
+
+func pixelsByOwner(pixels []storage.Pixel) map[int64][]storage.Pixel {
+	owned := make(map[int64][]storage.Pixel)
+	for _, pixel := range pixels {
+		if pixel.OwnerID != nil {
+			owned[*pixel.OwnerID] = append(owned[*pixel.OwnerID], pixel)
+		}
+	}
+	return owned
+}
+
+// notifyRentalEnding tells the owner by email and push which of their rented pixels are about
+// to be freed.
+func (s *Server) notifyRentalEnding(ctx context.Context, ownerID int64, pixels []storage.Pixel) {
+	sort.Slice(pixels, func(i, j int) bool { return pixels[i].ExpiresAt.Before(*pixels[j].ExpiresAt) })
+	first := pixels[0].ExpiresAt.UTC()
+	go s.notifyPush(context.WithoutCancel(ctx), ownerID, fcm.Message{
+		Title: "Wynajem pikseli dobiega końca",
+		Body:  fmt.Sprintf("Wynajem %d pikseli kończy się %s.", len(pixels), first.Format("2006-01-02 15:04 MST")),
+		Data:  map[string]string{"event": ownerEventRentalEnding, "pixels": strconv.Itoa(len(pixels))},
+	})
+
+	sender, ok := s.mailer.(email.NoticeSender)
+	if !ok {
+		log.Printf("pixel rentals: mailer cannot send notices; owner %d not emailed", ownerID)
+		return
+	}
+	owner, err := s.store.GetUserByID(ctx, ownerID)
+	if err != nil {
+		log.Printf("pixel rentals: load owner %d: %v", ownerID, err)
+		return
+	}
+	notice := email.Notice{
+		Subject: "Wynajem pikseli dobiega końca",
+		Body: fmt.Sprintf("Wynajem %d Twoich pikseli kończy się wkrótce, pierwszy %s. Po tym czasie piksele zostaną zwolnione i będą mogły zostać kupione przez innych.\n\nSzczegóły znajdziesz na swoim koncie.",
+			len(pixels), first.Format("2006-01-02 15:04 MST")),
+	}
+	if err := sender.SendNotice(ctx, owner.Email, notice); err != nil {
+		log.Printf("pixel rentals: notify owner %d: %v", ownerID, err)
+	}
+}
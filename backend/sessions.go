@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+// sessionPruneInterval is how often sessions past the idle timeout are dropped from memory.
+const sessionPruneInterval = 10 * time.Minute
+
+// sessionInfo describes a session in the account's session list. ID is a fingerprint of the
+// session id, which itself is never shown.
+type sessionInfo struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	// IdleExpiresAt is when the session ends unless it is used again; nil without an idle timeout.
+	IdleExpiresAt *time.Time `json:"idle_expires_at,omitempty"`
+	Current       bool       `json:"current"`
+}
+
+func sessionFingerprint(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// List returns the live sessions of userID, most recently used first; current marks the one with
+// that id.
+func (m *SessionManager) List(userID int64, current string) []sessionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	sessions := make([]sessionInfo, 0)
+	for id, sess := range m.sessions {
+		if sess.userID != userID || m.idle(sess, now) {
+			continue
+		}
+		info := sessionInfo{
+			ID:         sessionFingerprint(id),
+			CreatedAt:  sess.createdAt.UTC(),
+			LastSeenAt: sess.lastSeen.UTC(),
+			Current:    id == current,
+		}
+		if m.idleTimeout > 0 {
+			expires := sess.lastSeen.Add(m.idleTimeout).UTC()
+			info.IdleExpiresAt = &expires
+		}
+		sessions = append(sessions, info)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions
+}
+
+// PruneIdle removes the sessions idle for longer than the idle timeout by now and returns how
+// many it removed.
+func (m *SessionManager) PruneIdle(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for id, sess := range m.sessions {
+		if m.idle(sess, now) {
+			delete(m.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (m *SessionManager) idle(sess *session, now time.Time) bool {
+	return m.idleTimeout > 0 && now.Sub(sess.lastSeen) > m.idleTimeout
+}
+
+// handleListSessions lists the signed-in user's sessions with their last activity.
+func (s *Server) handleListSessions(c *gin.Context) {
+	user, ok := s.requireUser(c)
+	if !ok {
+		return
+	}
+	current, _, _ := readSessionCookie(c.Request)
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":             s.sessions.List(user.ID, current),
+		"idle_timeout_minutes": int(s.sessions.idleTimeout / time.Minute),
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/config"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+// demoPixels are painted by the "demo" seed so a fresh development board is not blank.
+var demoPixels = []storage.Pixel{
+	{ID: 500500, Status: "taken", Color: "#ff4d4f", URL: "https://example.com"},
+	{ID: 250250, Status: "taken", Color: "#36cfc9", URL: "https://minecraft.net"},
+	{ID: 750750, Status: "taken", Color: "#722ed1", URL: "https://github.com"},
+}
+
+// startupHook is a step run once per boot after the schema is ready. run reports what it applied
+// for the log.
+type startupHook struct {
+	name string
+	run  func(ctx context.Context, store storage.Store) (string, error)
+}
+
+// startupHooks returns the hooks enabled by cfg in the order they run.
+func startupHooks(cfg config.StartupHooks) []startupHook {
+	var hooks []startupHook
+	switch cfg.Seed {
+	case "demo":
+		hooks = append(hooks, startupHook{name: "seed demo", run: func(ctx context.Context, store storage.Store) (string, error) {
+			return seedEmptyBoard(ctx, store, demoPixels)
+		}})
+	case "file":
+		path := cfg.SeedFile
+		hooks = append(hooks, startupHook{name: "seed file", run: func(ctx context.Context, store storage.Store) (string, error) {
+			pixels, err := readSeedFile(path)
+			if err != nil {
+				return "", err
+			}
+			applied, err := seedEmptyBoard(ctx, store, pixels)
+			if err != nil {
+				return "", err
+			}
+			return applied + " from " + path, nil
+		}})
+	}
+	return hooks
+}
+
+// runStartupHooks runs hooks in order, logging what each applied, and stops at the first failure.
+func runStartupHooks(ctx context.Context, store storage.Store, hooks []startupHook) error {
+	if len(hooks) == 0 {
+		log.Printf("startup hooks: none enabled")
+		return nil
+	}
+	for _, hook := range hooks {
+		applied, err := hook.run(ctx, store)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hook.name, err)
+		}
+		log.Printf("startup hook %s: %s", hook.name, applied)
+	}
+	return nil
+}
+
+// seedEmptyBoard paints the taken pixels of seed, without owners, unless some pixel is taken
+// already, so a seed never overwrites a board in use and restarts do not repaint it.
+func seedEmptyBoard(ctx context.Context, store storage.Store, seed []storage.Pixel) (string, error) {
+	stats, err := store.GetBusinessStats(ctx)
+	if err != nil {
+		return "", fmt.Errorf("count taken pixels: %w", err)
+	}
+	if stats.PixelsTaken > 0 {
+		return fmt.Sprintf("skipped, %d pixels already taken", stats.PixelsTaken), nil
+	}
+	seeded := 0
+	for _, pixel := range seed {
+		if !strings.EqualFold(pixel.Status, "taken") {
+			continue
+		}
+		pixel.OwnerID, pixel.ExpiresAt = nil, nil
+		if _, err := store.UpdatePixel(ctx, pixel); err != nil {
+			return "", fmt.Errorf("seed pixel %d: %w", pixel.ID, err)
+		}
+		seeded++
+	}
+	return fmt.Sprintf("seeded %d pixels", seeded), nil
+}
+
+// readSeedFile decodes a grid snapshot such as a canvas archive blob, decompressing it when the
+// name ends in .gz.
+func readSeedFile(path string) ([]storage.Pixel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var state storage.PixelState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return state.Pixels, nil
+}
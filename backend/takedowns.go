@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+
+	"github.com/example/kup-piksel/internal/email"
+	"github.com/example/kup-piksel/internal/storage"
+)
+
+const (
+	maxTakedownPixels            = 2500
+	maxTakedownNameLength        = 200
+	maxTakedownDescriptionLength = 5000
+	maxTakedownNoteLength        = 2000
+	// takedownHiddenColor replaces the color of contested pixels on the public grid.
+	takedownHiddenColor = "#d9d9d9"
+)
+
+type takedownRequest struct {
+	ClaimantName  string `json:"claimant_name"`
+	ClaimantEmail string `json:"claimant_email"`
+	WorkURL       string `json:"work_url"`
+	Description   string `json:"description"`
+	PixelIDs      []int  `json:"pixel_ids"`
+	// GoodFaith confirms the claimant believes in good faith that the use is not authorized.
+	GoodFaith bool   `json:"good_faith"`
+	Token     string `json:"turnstile_token"`
+}
+
+type resolveTakedownRequest struct {
+	Decision string `json:"decision"`
+	Note     string `json:"note"`
+}
+
+// takedown validates the request and converts it to a pending storage.Takedown.
+func (r takedownRequest) takedown() (storage.Takedown, error) {
+	name := strings.TrimSpace(r.ClaimantName)
+	address := strings.ToLower(strings.TrimSpace(r.ClaimantEmail))
+	description := strings.TrimSpace(r.Description)
+	workURL := strings.TrimSpace(r.WorkURL)
+	switch {
+	case name == "" || utf8.RuneCountInString(name) > maxTakedownNameLength:
+		return storage.Takedown{}, fmt.Errorf("claimant_name is required and must be at most %d characters", maxTakedownNameLength)
+	case description == "" || utf8.RuneCountInString(description) > maxTakedownDescriptionLength:
+		return storage.Takedown{}, fmt.Errorf("description is required and must be at most %d characters", maxTakedownDescriptionLength)
+	case !r.GoodFaith:
+		return storage.Takedown{}, errors.New("the good faith statement must be confirmed")
+	}
+	if _, err := mail.ParseAddress(address); err != nil {
+		return storage.Takedown{}, errors.New("invalid claimant_email")
+	}
+	if workURL != "" {
+		parsed, err := url.Parse(workURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return storage.Takedown{}, errors.New("work_url must be an http(s) URL")
+		}
+	}
+
+	if len(r.PixelIDs) == 0 || len(r.PixelIDs) > maxTakedownPixels {
+		return storage.Takedown{}, fmt.Errorf("pixel_ids must list between 1 and %d pixels", maxTakedownPixels)
+	}
+	seen := make(map[int]bool, len(r.PixelIDs))
+	ids := make([]int, 0, len(r.PixelIDs))
+	for _, id := range r.PixelIDs {
+		if id < 0 || id >= storage.TotalPixels {
+			return storage.Takedown{}, fmt.Errorf("invalid pixel id %d", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	return storage.Takedown{
+		PixelIDs:      ids,
+		ClaimantName:  name,
+		ClaimantEmail: address,
+		WorkURL:       workURL,
+		Description:   description,
+	}, nil
+}
+
+// handleCreateTakedown accepts a public takedown request and hides the contested pixels until it is reviewed.
+func (s *Server) handleCreateTakedown(c *gin.Context) {
+	if s.rejectWrites(c) {
+		return
+	}
+	var req takedownRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	takedown, err := req.takedown()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !s.requireTurnstile(c, req.Token) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	takedown, err = s.store.CreateTakedown(ctx, takedown)
+	if err != nil {
+		log.Printf("takedown: create: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to submit takedown request"})
+		return
+	}
+	log.Printf("takedown: submitted id=%d pixels=%d claimant=%s", takedown.ID, len(takedown.PixelIDs), takedown.ClaimantEmail)
+	s.gridChanged(takedown.PixelIDs...)
+	s.notifyTakedownOwners(ctx, takedown, func(count int) email.Notice {
+		return email.Notice{
+			Subject: fmt.Sprintf("Zgłoszenie naruszenia praw #%d dotyczące Twoich pikseli", takedown.ID),
+			Body: fmt.Sprintf(
+				"Otrzymaliśmy zgłoszenie naruszenia praw autorskich obejmujące %d Twoich pikseli. Do czasu rozpatrzenia zgłoszenia ich treść jest ukryta na planszy.\n\nJeśli masz prawa do tej grafiki, odpowiedz na tę wiadomość i podaj numer zgłoszenia #%d.",
+				count, takedown.ID,
+			),
+		}
+	})
+
+	c.JSON(http.StatusCreated, gin.H{"id": takedown.ID, "status": takedown.Status})
+}
+
+// handleAdminTakedowns lists takedowns for review; ?status= defaults to pending, "all" lists every request.
+func (s *Server) handleAdminTakedowns(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	status := strings.ToLower(strings.TrimSpace(c.Query("status")))
+	switch status {
+	case "":
+		status = storage.TakedownStatusPending
+	case "all":
+		status = ""
+	case storage.TakedownStatusPending, storage.TakedownStatusUpheld, storage.TakedownStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
+		return
+	}
+	takedowns, err := s.store.ListTakedowns(c.Request.Context(), status)
+	if err != nil {
+		log.Printf("takedown: list: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load takedowns"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"takedowns": takedowns})
+}
+
+// handleAdminTakedown returns one takedown with its resolution log and the license declarations of the contested pixels.
+func (s *Server) handleAdminTakedown(c *gin.Context) {
+	if _, ok := s.requireAdmin(c); !ok {
+		return
+	}
+	takedown, ok := s.loadTakedownParam(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	events, err := s.store.ListTakedownEvents(ctx, takedown.ID)
+	if err != nil {
+		log.Printf("takedown: events for %d: %v", takedown.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load takedown"})
+		return
+	}
+	licenses := make([]storage.PixelLicense, 0)
+	seen := make(map[int64]bool)
+	for _, pixelID := range takedown.PixelIDs {
+		found, err := s.store.ListPixelLicensesByPixel(ctx, pixelID)
+		if err != nil {
+			log.Printf("takedown: licenses for pixel %d: %v", pixelID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load takedown"})
+			return
+		}
+		for _, license := range found {
+			if !seen[license.ID] {
+				seen[license.ID] = true
+				licenses = append(licenses, license)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"takedown": takedown, "events": events, "licenses": licenses})
+}
+
+// handleResolveTakedown records the admin's decision. Upheld takedowns keep the pixels hidden;
+// rejected ones restore them.
+func (s *Server) handleResolveTakedown(c *gin.Context) {
+	admin, ok := s.requireAdmin(c)
+	if !ok {
+		return
+	}
+	var req resolveTakedownRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	decision := strings.ToLower(strings.TrimSpace(req.Decision))
+	if decision != storage.TakedownStatusUpheld && decision != storage.TakedownStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision must be upheld or rejected"})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(note) > maxTakedownNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("note must be at most %d characters", maxTakedownNoteLength)})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "takedown not found"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	takedown, err := s.store.ResolveTakedown(ctx, id, decision, admin.ID, note)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "takedown not found"})
+		return
+	case errors.Is(err, storage.ErrTakedownNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "takedown was already resolved"})
+		return
+	case err != nil:
+		log.Printf("takedown: resolve %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve takedown"})
+		return
+	}
+	log.Printf("takedown: resolved id=%d decision=%s admin_id=%d", takedown.ID, decision, admin.ID)
+	s.gridChanged(takedown.PixelIDs...)
+
+	outcome := "Zgłoszenie zostało odrzucone, a treść Twoich pikseli jest ponownie widoczna."
+	if decision == storage.TakedownStatusUpheld {
+		outcome = "Zgłoszenie zostało uznane, dlatego treść Twoich pikseli pozostaje ukryta."
+	}
+	if note != "" {
+		outcome += "\n\nUzasadnienie: " + note
+	}
+	s.notifyTakedownOwners(ctx, takedown, func(count int) email.Notice {
+		return email.Notice{
+			Subject: fmt.Sprintf("Rozstrzygnięcie zgłoszenia #%d", takedown.ID),
+			Body:    fmt.Sprintf("Rozpatrzyliśmy zgłoszenie #%d dotyczące %d Twoich pikseli. %s", takedown.ID, count, outcome),
+		}
+	})
+
+	c.JSON(http.StatusOK, gin.H{"takedown": takedown})
+}
+
+func (s *Server) loadTakedownParam(c *gin.Context) (storage.Takedown, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "takedown not found"})
+		return storage.Takedown{}, false
+	}
+	takedown, err := s.store.GetTakedown(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "takedown not found"})
+		return storage.Takedown{}, false
+	}
+	if err != nil {
+		log.Printf("takedown: load %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load takedown"})
+		return storage.Takedown{}, false
+	}
+	return takedown, true
+}
+
+// notifyTakedownOwners emails every owner of a contested pixel and calls their webhook. Failures
+// are logged; the takedown itself is already stored.
+func (s *Server) notifyTakedownOwners(ctx context.Context, takedown storage.Takedown, notice func(count int) email.Notice) {
+	sender, canEmail := s.mailer.(email.NoticeSender)
+	if !canEmail {
+		log.Printf("takedown: mailer cannot send notices; owners of takedown %d not emailed", takedown.ID)
+		if s.ownerWebhooks == nil {
+			return
+		}
+	}
+	state, err := s.store.GetAllPixels(ctx)
+	if err != nil {
+		log.Printf("takedown: load pixels for %d: %v", takedown.ID, err)
+		return
+	}
+	contested := make(map[int]bool, len(takedown.PixelIDs))
+	for _, id := range takedown.PixelIDs {
+		contested[id] = true
+	}
+	owned := make(map[int64][]int)
+	for _, pixel := range state.Pixels {
+		if contested[pixel.ID] && pixel.OwnerID != nil {
+			owned[*pixel.OwnerID] = append(owned[*pixel.OwnerID], pixel.ID)
+		}
+	}
+	for ownerID, pixelIDs := range owned {
+		s.notifyOwnerWebhook(ctx, ownerID, ownerEventPixelsModerated, gin.H{
+			"takedown_id": takedown.ID,
+			"status":      takedown.Status,
+			"pixel_ids":   pixelIDs,
+		})
+		if !canEmail {
+			continue
+		}
+		owner, err := s.store.GetUserByID(ctx, ownerID)
+		if err != nil {
+			log.Printf("takedown: load owner %d: %v", ownerID, err)
+			continue
+		}
+		if err := sender.SendNotice(ctx, owner.Email, notice(len(pixelIDs))); err != nil {
+			log.Printf("takedown: notify owner %d of takedown %d: %v", ownerID, takedown.ID, err)
+		}
+	}
+}
+
+// hideContestedPixels blanks pixels under a pending or upheld takedown before the grid is served.
+// Pixels waiting in the moderation queue are shown as free.
+func (s *Server) hideContestedPixels(ctx context.Context, state *storage.PixelState) error {
+	if err := s.hidePendingReview(ctx, state); err != nil {
+		return err
+	}
+	hidden, err := s.store.ListHiddenPixelIDs(ctx)
+	if err != nil || len(hidden) == 0 {
+		return err
+	}
+	set := make(map[int]bool, len(hidden))
+	for _, id := range hidden {
+		set[id] = true
+	}
+	for i := range state.Pixels {
+		if set[state.Pixels[i].ID] && state.Pixels[i].Status == "taken" {
+			state.Pixels[i].Color = takedownHiddenColor
+			state.Pixels[i].URL = ""
+			state.Pixels[i].Title = ""
+			state.Pixels[i].Description = ""
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/kup-piksel/internal/config"
+)
+
+// tenantRouter hands each request to the billboard of the domain in its Host header: a host of a
+// tenant from the config, or a custom domain verified on a tenant's board. Other hosts are served
+// by the primary board of the main config file.
+type tenantRouter struct {
+	primary http.Handler
+	hosts   map[string]http.Handler
+	boards  []tenantBoard
+	// servers maps tenant names to their boards, for the metrics listener.
+	servers map[string]*Server
+}
+
+type tenantBoard struct {
+	handler http.Handler
+	// domains is nil when the tenant has custom domains disabled.
+	domains *customDomainRoutes
+}
+
+func (t *tenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := requestHost(r)
+	if board, ok := t.hosts[host]; ok {
+		board.ServeHTTP(w, r)
+		return
+	}
+	for _, board := range t.boards {
+		if board.domains == nil {
+			continue
+		}
+		if _, ok := board.domains.lookup(host); ok {
+			board.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	t.primary.ServeHTTP(w, r)
+}
+
+// requestHost returns the lower-cased Host header without port and trailing dot.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// newTenantRouter loads the config of every tenant of cfg and builds its board. The returned
+// function closes the tenants' stores.
+func newTenantRouter(ctx context.Context, cfg *config.Config, primary http.Handler) (*tenantRouter, func(), error) {
+	router := &tenantRouter{primary: primary, hosts: make(map[string]http.Handler), servers: make(map[string]*Server)}
+	configs := []*config.Config{cfg}
+	owners := []string{"primary board"}
+	var closers []func()
+	closeAll := func() {
+		for _, closeStore := range closers {
+			closeStore()
+		}
+	}
+	for i := range cfg.Tenants {
+		tenant := &cfg.Tenants[i]
+		tenantCfg, err := config.Load(tenant.ConfigPath)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("tenant %q: %w", tenant.Name, err)
+		}
+		if len(tenantCfg.Tenants) > 0 {
+			closeAll()
+			return nil, nil, fmt.Errorf("tenant %q: a tenant config cannot declare tenants", tenant.Name)
+		}
+		configs = append(configs, tenantCfg)
+		owners = append(owners, fmt.Sprintf("tenant %q", tenant.Name))
+		if err := checkTenantIsolation(configs, owners); err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+
+		log.Printf("tenant %s: hosts=%s config_path=%s base_url=%s", tenant.Name, strings.Join(tenant.Hosts, ","), tenant.ConfigPath, tenant.BaseURL)
+		server, board, closeStore := newBoard(ctx, tenantCfg, tenant.ConfigPath, tenant, nil)
+		closers = append(closers, closeStore)
+		router.servers[tenant.Name] = server
+		for _, host := range tenant.Hosts {
+			router.hosts[host] = board
+		}
+		router.boards = append(router.boards, tenantBoard{handler: board, domains: server.domainRoutes})
+	}
+	return router, closeAll, nil
+}
+
+// checkTenantEnvironment refuses the environment overrides that would point every board at the
+// same database.
+func checkTenantEnvironment() error {
+	for _, name := range []string{"PIXEL_DB_PATH", "PIXEL_MYSQL_DSN"} {
+		if os.Getenv(name) != "" {
+			return fmt.Errorf("%s cannot be used with tenants; set the database in each config file", name)
+		}
+	}
+	return nil
+}
+
+// checkTenantIsolation makes sure no two boards share a database or grid snapshot file, which
+// would mix their users and pixels. owners names the board of each config for the error.
+func checkTenantIsolation(configs []*config.Config, owners []string) error {
+	used := make(map[string]string)
+	for i, cfg := range configs {
+		resources := [][2]string{{"database", boardDatabase(cfg)}}
+		if path := cfg.GridCache.SnapshotPath; path != "" {
+			resources = append(resources, [2]string{"grid snapshot file", filepath.Clean(path)})
+		}
+		for _, resource := range resources {
+			key := resource[0] + " " + resource[1]
+			if other, taken := used[key]; taken {
+				// The database may be a DSN with a password, so only its kind is named.
+				return fmt.Errorf("%s and %s use the same %s", other, owners[i], resource[0])
+			}
+			used[key] = owners[i]
+		}
+	}
+	return nil
+}
+
+// boardDatabase identifies the database openConfiguredStore opens for cfg.
+func boardDatabase(cfg *config.Config) string {
+	if cfg == nil || cfg.Database == nil {
+		cfg = config.Default()
+	}
+	if cfg.Database.Driver == "mysql" {
+		return "mysql " + selectMySQLDSN(cfg.Database)
+	}
+	path := resolveSQLitePath(cfg)
+	if path == "" {
+		path = defaultDBPath
+	}
+	return "sqlite " + filepath.Clean(path)
+}
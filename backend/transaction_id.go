@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+// transactionIDHeader carries the id joining frontend events (such as Turnstile debug events)
+// with backend log lines of the same request.
+const transactionIDHeader = "X-Transaction-ID"
+
+// transactionIDPattern accepts UUIDs and similar opaque ids without letting clients inject
+// spaces or separators into log lines.
+var transactionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+type transactionIDKey struct{}
+
+// transactionStatusWriter remembers the response status of a request.
+type transactionStatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *transactionStatusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *transactionStatusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *transactionStatusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// transactionIDMiddleware adopts a well-formed X-Transaction-ID from the client or generates
+// one, stores it in the request context and echoes it on every response. Server errors are
+// logged with the id so they show up in GET /api/admin/errors under the id the user reports.
+func (s *Server) transactionIDMiddleware(c *gin.Context) {
+	id := c.Request.Header.Get(transactionIDHeader)
+	if !transactionIDPattern.MatchString(id) {
+		id = newTransactionID()
+	}
+	c.Writer.Header().Set(transactionIDHeader, id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), transactionIDKey{}, id))
+	writer := &transactionStatusWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	if writer.status >= http.StatusInternalServerError {
+		log.Printf("request failed: tx=%s method=%s path=%s status=%d", id, c.Request.Method, c.Request.URL.Path, writer.status)
+	}
+}
+
+func newTransactionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "unavailable"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// transactionID returns the id of the request ctx belongs to, or "-" outside a request.
+func transactionID(ctx context.Context) string {
+	if id, ok := ctx.Value(transactionIDKey{}).(string); ok {
+		return id
+	}
+	return "-"
+}
+
+// requestTransactionID is like transactionID but returns "" outside a request.
+func requestTransactionID(ctx context.Context) string {
+	if id, ok := ctx.Value(transactionIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// upstreamTransport sends the transaction id of the request context along to an upstream
+// service and logs the status and latency of every call, so its logs line up with ours.
+type upstreamTransport struct {
+	service string
+	base    http.RoundTripper
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := transactionID(req.Context())
+	if id != "-" && req.Header.Get(transactionIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(transactionIDHeader, id)
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	started := time.Now()
+	resp, err := base.RoundTrip(req)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("upstream: tx=%s service=%s host=%s duration=%s err=%v", id, t.service, req.URL.Host, elapsed, err)
+		return nil, err
+	}
+	log.Printf("upstream: tx=%s service=%s host=%s status=%d duration=%s", id, t.service, req.URL.Host, resp.StatusCode, elapsed)
+	return resp, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	gin "github.com/gin-gonic/gin"
+)
+
+// Password policy enforced at registration and password reset.
+const (
+	minPasswordLength = 6
+	// maxPasswordBytes is bcrypt's input limit.
+	maxPasswordBytes = 72
+)
+
+// Field validation codes returned in the "fields" array of a validation error.
+const (
+	fieldCodeRequired      = "required"
+	fieldCodeInvalidFormat = "invalid_format"
+	fieldCodeTooShort      = "too_short"
+	fieldCodeTooLong       = "too_long"
+	fieldCodeMismatch      = "mismatch"
+)
+
+// fieldError tells the frontend which input to highlight and why.
+type fieldError struct {
+	Field string `json:"field"`
+	Code  string `json:"code"`
+}
+
+type fieldErrors []fieldError
+
+func (e *fieldErrors) add(field, code string) {
+	*e = append(*e, fieldError{Field: field, Code: code})
+}
+
+// email checks that value is a bare address such as user@example.com.
+func (e *fieldErrors) email(field, value string) {
+	if value == "" {
+		e.add(field, fieldCodeRequired)
+		return
+	}
+	parsed, err := mail.ParseAddress(value)
+	if err != nil || parsed.Name != "" || !strings.EqualFold(parsed.Address, value) {
+		e.add(field, fieldCodeInvalidFormat)
+	}
+}
+
+func (e *fieldErrors) password(field, value string) {
+	switch {
+	case value == "":
+		e.add(field, fieldCodeRequired)
+	case utf8.RuneCountInString(value) < minPasswordLength:
+		e.add(field, fieldCodeTooShort)
+	case len(value) > maxPasswordBytes:
+		e.add(field, fieldCodeTooLong)
+	}
+}
+
+func (e *fieldErrors) required(field, value string) {
+	if value == "" {
+		e.add(field, fieldCodeRequired)
+	}
+}
+
+// respond writes a 400 with the field errors and reports whether there were any.
+func (e fieldErrors) respond(c *gin.Context, message string) bool {
+	if len(e) == 0 {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": message, "code": "validation_failed", "fields": e})
+	return true
+}